@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mboxContinue  bool
+	mboxSkip      bool
+	mboxAbort     bool
+	mboxSummarize bool
+)
+
+var applyMboxCmd = &cobra.Command{
+	Use:         "apply-mbox <file>",
+	Short:       "Apply a patch series received by email",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Apply the patches in a mailbox file, such as one saved from a mailing
+list or produced by 'git format-patch --stdout', as commits on the current
+branch. Authorship and messages come from the emails; cover letters are
+skipped.
+
+Patches are applied one at a time with a three-way merge. When one doesn't
+apply cleanly, resolve the conflicts (for example with 'sage resolve') and
+run 'sage apply-mbox --continue'.`,
+	Example: `  sage apply-mbox series.mbox
+  sage apply-mbox series.mbox --summarize
+  sage apply-mbox --continue
+  sage apply-mbox --abort`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+
+		if mboxContinue || mboxSkip || mboxAbort {
+			if len(args) > 0 {
+				return fmt.Errorf("--continue, --skip and --abort don't take a file")
+			}
+			series, err := app.LoadMboxSeries(g)
+			if err != nil {
+				return err
+			}
+			if series == nil {
+				return fmt.Errorf("no patch series is being applied")
+			}
+			switch {
+			case mboxAbort:
+				if err := app.AbortMboxSeries(g, series); err != nil {
+					return err
+				}
+				fmt.Printf("%s Aborted; the branch is back at %s\n", ui.Green("✓"), shortRef(series.OrigHead))
+				return nil
+			case mboxSkip:
+				return reportMbox(series, app.SkipMboxPatch(g, series, printApplied(series)))
+			default:
+				return continueMbox(g, series)
+			}
+		}
+
+		if len(args) == 0 {
+			return fmt.Errorf("give the mailbox file to apply")
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+		patches, err := app.ParseMbox(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+
+		fmt.Printf("%s %d patch%s in %s\n", ui.Sage("Series:"), len(patches), pluralizeEs(len(patches)), args[0])
+		for i, p := range patches {
+			fmt.Printf("  %s %s %s\n", ui.Gray(fmt.Sprintf("%d/%d", i+1, len(patches))), p.Subject, ui.Gray("("+p.Author+")"))
+		}
+
+		if mboxSummarize {
+			if err := summarizeMbox(patches); err != nil {
+				ui.Warning(fmt.Sprintf("No summary: %v", err))
+			}
+			proceed, err := ui.AskConfirm("Apply this series?", true)
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				return nil
+			}
+		}
+
+		series, err := app.StartMboxSeries(g, args[0], patches)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		return reportMbox(series, app.ApplyMboxSeries(g, series, printApplied(series)))
+	},
+}
+
+// continueMbox commits the resolved patch and applies the rest of the series
+func continueMbox(g git.Service, series *app.MboxSeries) error {
+	return reportMbox(series, app.ContinueMboxSeries(g, series, printApplied(series)))
+}
+
+func printApplied(series *app.MboxSeries) func(int, app.MboxPatch) {
+	return func(i int, p app.MboxPatch) {
+		fmt.Printf("%s %s %s\n", ui.Green("✓"), ui.Gray(fmt.Sprintf("%d/%d", i+1, len(series.Patches))), p.Subject)
+	}
+}
+
+// reportMbox explains how to go on when a patch stops the series
+func reportMbox(series *app.MboxSeries, err error) error {
+	if errors.Is(err, app.ErrPatchConflict) {
+		p := series.Patches[series.Next]
+		fmt.Printf("%s %s %s\n", ui.Red("✗"), ui.Gray(fmt.Sprintf("%d/%d", series.Next+1, len(series.Patches))), p.Subject)
+		fmt.Println()
+		fmt.Println(ui.Yellow("This patch has conflicts."))
+		fmt.Println(ui.Gray("Resolve them with 'sage resolve', then run 'sage apply-mbox --continue'."))
+		fmt.Println(ui.Gray("Use --skip to drop this patch or --abort to undo the whole series."))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	n := len(series.Patches)
+	fmt.Printf("\n%s Applied %d patch%s from %s\n", ui.Green("✓"), n, pluralizeEs(n), series.Source)
+	return nil
+}
+
+func summarizeMbox(patches []app.MboxPatch) error {
+	if !config.AIEnabled("") {
+		return fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+	}
+	client := aiClient()
+	if !client.Configured() {
+		return fmt.Errorf("AI API key not configured. Please set it using 'sage config set ai_api_key YOUR_KEY'")
+	}
+
+	spinner := ui.NewSpinner()
+	spinner.Start("Summarizing the series...")
+	summary, err := app.SummarizeMbox(client, patches)
+	if err != nil {
+		spinner.StopFail()
+		return err
+	}
+	spinner.StopSuccess()
+	fmt.Printf("\n%s\n%s\n\n", ui.Sage("Summary:"), summary)
+	return nil
+}
+
+// pluralizeEs returns "es" unless n is 1, for words like "patch"
+func pluralizeEs(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "es"
+}
+
+func init() {
+	rootCmd.AddCommand(applyMboxCmd)
+	applyMboxCmd.Flags().BoolVar(&mboxContinue, "continue", false, "Commit the resolved patch and apply the rest of the series")
+	applyMboxCmd.Flags().BoolVar(&mboxSkip, "skip", false, "Drop the patch that stopped and apply the rest")
+	applyMboxCmd.Flags().BoolVar(&mboxAbort, "abort", false, "Stop and move the branch back to where it was before the series")
+	applyMboxCmd.Flags().BoolVar(&mboxSummarize, "summarize", false, "Ask AI to summarize the series before applying it")
+	applyMboxCmd.MarkFlagsMutuallyExclusive("continue", "skip", "abort")
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/audit"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	auditSince  string
+	auditFormat string
+	auditOutput string
+)
+
+// mutatingAnnotation marks the commands that change the repository or remote
+// state and are therefore recorded in the audit log. The value lists, comma
+// separated, the flags a command only makes changes with, "!" marking those
+// it must run without; empty means every run counts.
+const mutatingAnnotation = "sage/mutating"
+
+// mutates reports whether this run of cmd is one to audit
+func mutates(cmd *cobra.Command) bool {
+	flags, ok := cmd.Annotations[mutatingAnnotation]
+	if !ok {
+		return false
+	}
+	for _, name := range strings.Split(flags, ",") {
+		if name == "" {
+			continue
+		}
+		want := !strings.HasPrefix(name, "!")
+		f := cmd.Flags().Lookup(strings.TrimPrefix(name, "!"))
+		set := f != nil && f.Changed && f.Value.String() != "false"
+		if set != want {
+			return false
+		}
+	}
+	return true
+}
+
+// pendingAudit holds the state captured before a mutating command runs
+var pendingAudit *audit.Entry
+
+// beginAudit captures the state before a mutating command runs
+func beginAudit(cmd *cobra.Command, args []string) {
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if !mutates(cmd) || config.Get("audit.enabled", true) == "false" {
+		return
+	}
+
+	g := git.NewShellGit()
+	if inRepo, _ := g.IsRepo(); !inRepo {
+		return
+	}
+
+	var flags []audit.Flag
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags = append(flags, audit.Flag{Name: f.Name, Value: f.Value.String(), Bool: f.Value.Type() == "bool"})
+	})
+	e := &audit.Entry{
+		Timestamp: time.Now().UTC(),
+		Command:   name,
+		Args:      audit.RedactArgs(flags, args),
+	}
+	if email, err := g.GetConfigValue("user.email"); err == nil {
+		e.User = strings.TrimSpace(email)
+	}
+	if info, err := repoinfo.Default().Repo(); err == nil {
+		e.Repo = info.FullName()
+	} else if remote, err := g.GetConfigValue("remote.origin.url"); err == nil {
+		e.Repo = strings.TrimSpace(remote)
+	}
+	e.Branch, _ = g.CurrentBranch()
+	e.RefBefore, _ = g.GetCommitHash("HEAD")
+	pendingAudit = e
+}
+
+// finishAudit records the outcome of the command started with beginAudit
+func finishAudit(runErr error) {
+	if pendingAudit == nil {
+		return
+	}
+	e := pendingAudit
+	pendingAudit = nil
+
+	g := git.NewShellGit()
+	e.Duration = time.Since(e.Timestamp).Round(time.Millisecond).String()
+	e.RefAfter, _ = g.GetCommitHash("HEAD")
+	e.Success = runErr == nil
+	if runErr != nil {
+		e.Error = runErr.Error()
+	}
+
+	if err := audit.Append(g, *e); err != nil {
+		ui.Warnf("Failed to write audit log: %v\n", err)
+	}
+	if url := config.Get("audit.webhook", true); url != "" {
+		if err := audit.Forward(url, *e); err != nil {
+			ui.Warnf("%v\n", err)
+		}
+	}
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the log of operations sage has performed",
+	Long: `Every mutating sage command (commit, push, sync, PR changes, ...) is recorded
+in an append-only audit log at .git/.sage/audit.log, including who ran it,
+the branch, and HEAD before and after. Flags are recorded by name, and
+their values and the command's arguments are left out, so messages and
+tokens never reach the log or the webhook.
+
+Disable recording with 'sage config set audit.enabled false'. Set
+'audit.webhook' to a URL to also POST each entry as JSON.`,
+}
+
+var auditShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show recorded operations",
+	Example: `  # Everything from the last week
+  sage audit show --since 7d`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := loadAuditEntries()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println(ui.Yellow("No audit entries found."))
+			return nil
+		}
+
+		for _, e := range entries {
+			status := ui.Green("✓")
+			if !e.Success {
+				status = ui.Red("✗")
+			}
+			fmt.Printf("%s %s  %s  %s\n",
+				status,
+				ui.Gray(e.Timestamp.Local().Format("2006-01-02 15:04:05")),
+				ui.Bold(e.Command),
+				ui.Blue(e.User))
+			if e.Branch != "" {
+				fmt.Printf("    branch: %s  %s → %s\n", e.Branch, shortRef(e.RefBefore), shortRef(e.RefAfter))
+			}
+			if e.Error != "" {
+				fmt.Printf("    %s\n", ui.Red(firstLine(e.Error)))
+			}
+		}
+		return nil
+	},
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export recorded operations as JSON or CSV",
+	Example: `  # Export the last 30 days as CSV
+  sage audit export --since 30d --format csv --output audit.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := loadAuditEntries()
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if auditOutput != "" {
+			f, err := os.Create(auditOutput)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", auditOutput, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch auditFormat {
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if entries == nil {
+				entries = []audit.Entry{}
+			}
+			return enc.Encode(entries)
+		case "csv":
+			cw := csv.NewWriter(w)
+			_ = cw.Write([]string{"timestamp", "command", "args", "user", "repo", "branch", "ref_before", "ref_after", "success", "error"})
+			for _, e := range entries {
+				_ = cw.Write([]string{
+					e.Timestamp.Format(time.RFC3339),
+					e.Command,
+					strings.Join(e.Args, " "),
+					e.User,
+					e.Repo,
+					e.Branch,
+					e.RefBefore,
+					e.RefAfter,
+					strconv.FormatBool(e.Success),
+					e.Error,
+				})
+			}
+			cw.Flush()
+			return cw.Error()
+		default:
+			return fmt.Errorf("unsupported format %q (use json or csv)", auditFormat)
+		}
+	},
+}
+
+func loadAuditEntries() ([]audit.Entry, error) {
+	since, err := audit.ParseSince(auditSince, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return audit.Read(git.NewShellGit(), since)
+}
+
+func shortRef(ref string) string {
+	if len(ref) > 7 {
+		return ref[:7]
+	}
+	if ref == "" {
+		return "-"
+	}
+	return ref
+}
+
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditShowCmd)
+	auditCmd.AddCommand(auditExportCmd)
+
+	auditCmd.PersistentFlags().StringVar(&auditSince, "since", "", "Only include entries since a time (e.g. 24h, 7d, 2024-01-31)")
+	auditExportCmd.Flags().StringVar(&auditFormat, "format", "json", "Output format (json or csv)")
+	auditExportCmd.Flags().StringVarP(&auditOutput, "output", "o", "", "Write to a file instead of stdout")
+}
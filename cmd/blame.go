@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	blameInteractive bool
+	blameOffline     bool
+)
+
+var blameCmd = &cobra.Command{
+	Use:   "blame <file>",
+	Short: "Show who changed each line of a file, with its PR and tickets",
+	Long: `Blame a file like git blame, showing for each line the commit that last
+changed it, its author and age, the pull request that brought it in and
+any ticket IDs (PROJ-123, or whatever commit.ticket_pattern matches) in the
+commit subject or the PR's title and branch.
+
+PRs named in squash or merge commit subjects are used as they are; others
+are looked up on GitHub or GitLab, unless you pass --offline.
+
+With --interactive, type to search the lines, then pick one to browse its
+commit, open its PR in the browser or copy its SHA.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if blameInteractive && !term.IsTerminal(int(os.Stdin.Fd())) {
+			return exitcode.Errorf(exitcode.Usage, "--interactive needs a terminal")
+		}
+		g := git.NewShellGit()
+		var ghc gh.Client
+		if !blameOffline {
+			ghc = optionalForgeClient()
+		}
+
+		spinner := ui.NewSpinner()
+		spinner.Start("Blaming " + args[0] + "...")
+		entries, err := app.Blame(g, ghc, args[0])
+		spinner.Stop()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println(ui.Gray(args[0] + " is empty"))
+			return nil
+		}
+
+		rows := blameRows(entries)
+		if blameInteractive {
+			return browseBlame(g, entries, rows)
+		}
+		for _, r := range rows {
+			fmt.Println(r)
+		}
+		return nil
+	},
+}
+
+// blameRows lays the entries out in aligned columns: commit, author, age,
+// PR, tickets, line number and text
+func blameRows(entries []app.BlameEntry) []string {
+	cells := make([][4]string, len(entries))
+	var widths [4]int
+	for i, e := range entries {
+		c := [4]string{shortRef(e.Hash), truncate(e.Author, 16), formatAge(time.Since(e.AuthorTime)), ""}
+		if e.Uncommitted() {
+			c = [4]string{"uncommitted", "", "", ""}
+		}
+		var attribution []string
+		if e.PR != nil {
+			attribution = append(attribution, fmt.Sprintf("#%d", e.PR.Number))
+		}
+		c[3] = strings.Join(append(attribution, e.Tickets...), " ")
+		for j, s := range c {
+			widths[j] = max(widths[j], len([]rune(s)))
+		}
+		cells[i] = c
+	}
+
+	lineWidth := len(fmt.Sprint(entries[len(entries)-1].Line))
+	rows := make([]string, len(entries))
+	for i, c := range cells {
+		cols := []string{ui.Yellow(pad(c[0], widths[0])), ui.White(pad(c[1], widths[1])), ui.Gray(pad(c[2], widths[2]))}
+		if widths[3] > 0 {
+			cols = append(cols, ui.Blue(pad(c[3], widths[3])))
+		}
+		cols = append(cols, ui.Gray(fmt.Sprintf("%*d", lineWidth, entries[i].Line)), entries[i].Text)
+		rows[i] = strings.Join(cols, " ")
+	}
+	return rows
+}
+
+// pad fills s out with spaces to width characters
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-len([]rune(s))))
+}
+
+// browseBlame lets the user search the blamed lines and act on the commit
+// behind one
+func browseBlame(g git.Service, entries []app.BlameEntry, rows []string) error {
+	for {
+		filter := survey.WithFilter(func(query, _ string, i int) bool {
+			return app.FuzzyMatch(query, blameSearchText(entries[i]))
+		})
+		choice, err := ui.AskSelectIndex("Pick a line (type to search code, authors, PRs and tickets):", rows,
+			"drop --interactive to print the blame", survey.WithPageSize(20), filter)
+		if err != nil {
+			return err
+		}
+
+		e := entries[choice]
+		if e.Uncommitted() {
+			fmt.Println(ui.Gray("That line isn't committed yet.\n"))
+			continue
+		}
+		done, err := blameActions(g, e)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// blameSearchText is what the blame browser's filter searches for a line
+func blameSearchText(e app.BlameEntry) string {
+	parts := []string{e.Text, e.Hash, e.Author, e.Summary}
+	if e.PR != nil {
+		parts = append(parts, fmt.Sprintf("#%d", e.PR.Number), e.PR.Title)
+	}
+	return strings.Join(append(parts, e.Tickets...), " ")
+}
+
+// blameActions asks what to do with the commit behind a line. It reports
+// whether the browser should close.
+func blameActions(g git.Service, e app.BlameEntry) (bool, error) {
+	fmt.Printf("\n%s %s\n", ui.Yellow(e.Hash), ui.Bold(e.Summary))
+	fmt.Printf("%s %s, %s\n", ui.Gray("Author:"), e.Author, e.AuthorTime.Format("Mon Jan 02 2006 15:04"))
+	if e.PR != nil {
+		fmt.Printf("%s #%d %s\n", ui.Gray("PR:"), e.PR.Number, e.PR.Title)
+	}
+	if len(e.Tickets) > 0 {
+		fmt.Printf("%s %s\n", ui.Gray("Tickets:"), strings.Join(e.Tickets, ", "))
+	}
+	fmt.Println()
+
+	const (
+		browse  = "Browse the commit"
+		openPR  = "Open the PR in the browser"
+		open    = "Open the commit in the browser"
+		copySHA = "Copy SHA"
+		back    = "Back to the lines"
+	)
+	options := []string{browse}
+	if e.PR != nil {
+		options = append(options, openPR)
+	}
+	options = append(options, open, copySHA, back)
+	action, err := ui.AskSelect("What do you want to do?", options, "", "drop --interactive to print the blame")
+	if err != nil {
+		return false, err
+	}
+
+	switch action {
+	case browse:
+		c, err := readCommit(g, e.Hash)
+		if err != nil {
+			return false, err
+		}
+		return commitActions(g, c)
+	case openPR:
+		url := e.PR.HTMLURL
+		if url == "" {
+			// PRs found in commit subjects come without their page
+			pr, err := app.GetPRDetails(forgeClient(), e.PR.Number)
+			if err != nil {
+				return false, err
+			}
+			url = pr.HTMLURL
+		}
+		openInBrowser(url)
+	case open:
+		url, err := app.CommitURL(repoinfo.Default(), e.Hash)
+		if err != nil {
+			return false, err
+		}
+		openInBrowser(url)
+	case copySHA:
+		copyToClipboard(e.Hash, "the hash of "+shortRef(e.Hash))
+	}
+	fmt.Println()
+	return false, nil
+}
+
+// readCommit reads a single commit with the files it touched
+func readCommit(g git.Service, hash string) (git.Commit, error) {
+	log, err := g.Log(git.LogOptions{Range: hash, Files: true})
+	if err != nil {
+		return git.Commit{}, err
+	}
+	defer log.Close()
+	page, err := log.Page(1)
+	if err != nil {
+		return git.Commit{}, err
+	}
+	if len(page) == 0 {
+		return git.Commit{}, fmt.Errorf("commit %s not found", shortRef(hash))
+	}
+	return page[0], nil
+}
+
+func init() {
+	rootCmd.AddCommand(blameCmd)
+	blameCmd.Flags().BoolVarP(&blameInteractive, "interactive", "i", false, "Search the lines and act on the commit behind one")
+	blameCmd.Flags().BoolVar(&blameOffline, "offline", false, "Only use PRs named in commit subjects, without asking the forge")
+}
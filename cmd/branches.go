@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	branchesRemote     bool
+	branchesRemoteName string
+	branchesList       bool
+	branchesSort       string
+)
+
+var branchesCmd = &cobra.Command{
+	Use:         "branches [filter]",
+	Short:       "List branches, or browse and check out remote ones",
+	Annotations: map[string]string{mutatingAnnotation: "remote,!list"},
+	Long: `List local branches with their last commit and who made it, how far
+each is ahead of (↑) and behind (↓) its upstream, whether that upstream is
+gone from the remote, and the pull request sage last saw for it. Nothing is
+fetched: run 'sage sync' or 'git fetch' first for up-to-date counts. Sort
+with --sort: recent (the default), stale or name.
+
+With --remote, list the branches on the remote without fetching them, along
+with their open pull requests, and pick one to fetch and check out on its
+own. Last commits are shown for branches already fetched and, on GitHub, up
+to 50 others; narrow the list with a filter to see them for the rest.`,
+	Example: `  sage branches
+  sage branches --sort stale
+  sage branches --remote
+  sage branches --remote fix/ --list`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		filter := ""
+		if len(args) == 1 {
+			filter = args[0]
+		}
+
+		if !branchesRemote {
+			branches, err := app.ListLocalBranches(g, filter, branchesSort)
+			if err != nil {
+				return err
+			}
+			current, _ := g.CurrentBranch()
+			for _, b := range branches {
+				marker := " "
+				if b.Name == current {
+					marker = ui.Green("*")
+				}
+				fmt.Printf("%s %s\n", marker, localBranchLine(b))
+			}
+			return nil
+		}
+
+		var ghc gh.Client
+		if branchesRemoteName == "origin" {
+			ghc = optionalGitHubClient()
+		}
+		branches, err := app.ListRemoteBranches(g, ghc, branchesRemoteName, filter)
+		if err != nil {
+			return err
+		}
+		if len(branches) == 0 {
+			fmt.Println(ui.Gray("No matching branches on " + branchesRemoteName))
+			return nil
+		}
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+		if branchesList || !interactive || batch.Enabled() {
+			for _, b := range branches {
+				fmt.Println(branchLine(b))
+			}
+			return nil
+		}
+
+		options := make([]string, len(branches))
+		byOption := make(map[string]app.BranchInfo, len(branches))
+		for i, b := range branches {
+			options[i] = branchLine(b)
+			byOption[options[i]] = b
+		}
+		choice, err := ui.AskSelect(fmt.Sprintf("Check out a branch from %s (%d):", branchesRemoteName, len(branches)), options,
+			"", "pass --list, or check one out with 'sage switch <branch>'", survey.WithPageSize(15))
+		if err != nil {
+			return err
+		}
+		b := byOption[choice]
+		if err := app.CheckoutRemoteBranch(g, branchesRemoteName, b.Name); err != nil {
+			return err
+		}
+		fmt.Printf("%s Switched to %s\n", ui.Green("✓"), ui.Blue(b.Name))
+		return nil
+	},
+}
+
+// branchLine describes a branch on one line: name, age, author, subject and
+// any open pull request
+func branchLine(b app.BranchInfo) string {
+	line := ui.White(b.Name)
+	if !b.Date.IsZero() {
+		line += " " + ui.Gray(fmt.Sprintf("%s by %s: %s", formatAge(time.Since(b.Date)), b.Author, b.Subject))
+	}
+	if b.PR != nil {
+		line += " " + ui.Sage(fmt.Sprintf("#%d", b.PR.Number))
+	}
+	if b.Local && branchesRemote {
+		line += " " + ui.Gray("(local)")
+	}
+	return line
+}
+
+// localBranchLine describes a local branch on one line: name, where it
+// stands against its upstream, age, last committer, subject and pull request
+func localBranchLine(b app.BranchInfo) string {
+	line := ui.White(b.Name)
+	switch {
+	case b.UpstreamGone:
+		line += " " + ui.Red("[upstream gone]")
+	case b.Upstream == "":
+		line += " " + ui.Gray("[no upstream]")
+	case b.Ahead > 0 || b.Behind > 0:
+		var counts []string
+		if b.Ahead > 0 {
+			counts = append(counts, ui.Green(fmt.Sprintf("↑%d", b.Ahead)))
+		}
+		if b.Behind > 0 {
+			counts = append(counts, ui.Yellow(fmt.Sprintf("↓%d", b.Behind)))
+		}
+		line += " " + strings.Join(counts, " ")
+	}
+	line += " " + ui.Gray(fmt.Sprintf("%s by %s: %s", formatAge(time.Since(b.Date)), b.Committer, b.Subject))
+	if b.CachedPR != nil {
+		line += fmt.Sprintf(" %s %s", ui.Sage(fmt.Sprintf("#%d", b.CachedPR.Number)), cachedPRState(b.CachedPR))
+	}
+	return line
+}
+
+func init() {
+	rootCmd.AddCommand(branchesCmd)
+	branchesCmd.Flags().BoolVarP(&branchesRemote, "remote", "r", false, "Browse the remote's branches without fetching them all")
+	branchesCmd.Flags().StringVar(&branchesRemoteName, "remote-name", "origin", "Remote to browse with --remote")
+	branchesCmd.Flags().BoolVar(&branchesList, "list", false, "With --remote, print the branches instead of picking one to check out")
+	branchesCmd.Flags().StringVar(&branchesSort, "sort", app.BranchSortRecent, "Order local branches by: recent, stale or name")
+	_ = branchesCmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions([]string{app.BranchSortRecent, app.BranchSortStale, app.BranchSortName}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// openInBrowser opens url in the default browser. Where there is none, as
+// over SSH, the URL is printed to open by hand.
+func openInBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("%s %s\n", ui.Gray("Open in your browser:"), ui.Blue(url))
+		return
+	}
+	_ = cmd.Process.Release()
+	fmt.Printf("%s Opened %s\n", ui.Green("✓"), url)
+}
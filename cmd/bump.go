@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var bumpNoCommit bool
+
+var bumpCmd = &cobra.Command{
+	Use:         "bump <major|minor|patch|version>",
+	Short:       "Bump the version in your version files",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Update the version everywhere the repository keeps it and commit the
+change as "chore(release): vX.Y.Z".
+
+Version files are listed in .sage/versions.yaml. A file can hold only the
+version, or the version can be found with a regex (the first capture group)
+or a JSON path:
+
+  files:
+    - path: VERSION
+    - path: package.json
+      json: version
+    - path: internal/version/version.go
+      regex: 'Version = "([^"]+)"'
+
+Without the file, a VERSION file at the repository root is used.
+
+Bumping a prerelease releases it when it is already what the bump would
+reach: 1.3.0-rc.1 becomes 1.3.0 with minor or patch, and 2.0.0-rc.1 becomes
+2.0.0 with major too.`,
+	Example: `  sage bump patch
+  sage bump 2.0.0-rc.1 --no-commit`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		files, err := app.LoadVersionFiles(g)
+		if err != nil {
+			return err
+		}
+
+		// Read the current version first so major/minor/patch have a base
+		current, _, err := app.PlanVersionBump(g, files, "0.0.0")
+		if err != nil {
+			return err
+		}
+		next, err := app.NextVersion(current, args[0])
+		if err != nil {
+			return err
+		}
+		_, bumps, err := app.PlanVersionBump(g, files, next)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range bumps {
+			fmt.Printf("  %s %s → %s\n", ui.White(b.File.Path), ui.Gray(b.Old), ui.Green(next))
+		}
+		if err := app.ApplyVersionBump(g, bumps, next, bumpNoCommit); err != nil {
+			return err
+		}
+		if bumpNoCommit {
+			fmt.Printf("%s Bumped %s → %s\n", ui.Green("✓"), current, next)
+		} else {
+			fmt.Printf("%s Committed %s\n", ui.Green("✓"), app.ReleaseCommitMessage(next))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bumpCmd)
+	bumpCmd.Flags().BoolVar(&bumpNoCommit, "no-commit", false, "Update the files without committing")
+}
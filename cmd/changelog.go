@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changelogType    string
+	changelogSummary string
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Manage changelog entries",
+	Long: `Record changes as they happen instead of writing the changelog at release time.
+
+Each entry is a small file under .changes/ committed with the change itself,
+so entries never conflict. A release collects them into CHANGELOG.md.`,
+}
+
+var changelogAddCmd = &cobra.Command{
+	Use:         "add",
+	Short:       "Add a changelog entry for the current change",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Example: `  sage changelog add
+  sage changelog add --type fixed -m "Sync no longer drops stashed changes"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+
+		if changelogType == "" {
+			t, err := ui.AskSelect("Type of change:", app.ChangeTypes, "", "pass --type")
+			if err != nil {
+				return err
+			}
+			changelogType = t
+		}
+		if changelogSummary == "" {
+			summary, err := ui.AskInput("Summary (as it should read in the changelog):", "", "pass -m", survey.WithValidator(survey.Required))
+			if err != nil {
+				return err
+			}
+			changelogSummary = summary
+		}
+
+		path, err := app.AddFragment(g, changelogType, changelogSummary)
+		if err != nil {
+			return err
+		}
+		root, _ := g.GetRepoPath()
+		if rel, err := filepath.Rel(root, path); err == nil {
+			path = rel
+		}
+		fmt.Printf("%s Added %s\n", ui.Green("✓"), path)
+		fmt.Println(ui.Gray("Commit it with your change; 'sage pr create' adds it to the PR description."))
+		return nil
+	},
+}
+
+var changelogListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show pending changelog entries",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		frags, err := app.ListFragments(git.NewShellGit())
+		if err != nil {
+			return err
+		}
+		if len(frags) == 0 {
+			fmt.Println(ui.Gray("No pending changelog entries."))
+			return nil
+		}
+		fmt.Print(app.RenderFragments(frags, 2))
+		return nil
+	},
+}
+
+var changelogCollectCmd = &cobra.Command{
+	Use:         "collect <version>",
+	Short:       "Move pending entries into CHANGELOG.md under a release heading",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Args:        cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		frags, err := app.CollectChangelog(git.NewShellGit(), args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		noun := "entries"
+		if len(frags) == 1 {
+			noun = "entry"
+		}
+		fmt.Printf("%s Collected %d %s into %s\n", ui.Green("✓"), len(frags), noun, app.ChangelogFile)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.AddCommand(changelogAddCmd)
+	changelogCmd.AddCommand(changelogListCmd)
+	changelogCmd.AddCommand(changelogCollectCmd)
+
+	changelogAddCmd.Flags().StringVarP(&changelogType, "type", "t", "", "Type of change: added, changed, deprecated, removed, fixed or security")
+	changelogAddCmd.Flags().StringVarP(&changelogSummary, "message", "m", "", "Summary of the change")
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ciWhyCheck string
+	ciWhyNoAI  bool
+	ciWhyLines int
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Inspect CI results for pull requests",
+}
+
+var ciWhyCmd = &cobra.Command{
+	Use:   "why [pr-num]",
+	Short: "Explain why a pull request's checks failed",
+	Long: `Download the logs of a pull request's failed GitHub Actions jobs, pick out
+the lines around the errors, and ask AI for a short explanation with commands
+to reproduce the failure locally.
+
+Without a PR number the current branch's PR is used. Checks from other CI
+providers are listed with a link, since their logs aren't available through
+the GitHub API.`,
+	Example: `  sage ci why
+  sage ci why 42 --check test
+  sage ci why --no-ai`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := githubClient()
+		g := git.NewShellGit()
+
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
+		failures, err := app.FailedChecks(ghc, num, ciWhyLines)
+		if err != nil {
+			return err
+		}
+		if ciWhyCheck != "" {
+			var matched []app.CIFailure
+			for _, f := range failures {
+				if strings.EqualFold(f.Check.Name, ciWhyCheck) {
+					matched = append(matched, f)
+				}
+			}
+			if len(matched) == 0 {
+				return fmt.Errorf("no failed check named %q on PR #%d", ciWhyCheck, num)
+			}
+			failures = matched
+		}
+		if len(failures) == 0 {
+			fmt.Printf("%s No failed checks on PR #%d\n", ui.Green("✓"), num)
+			return nil
+		}
+
+		var client *ai.Client
+		if !ciWhyNoAI {
+			client = ciAIClient()
+		}
+		var changed []string
+		if client != nil {
+			if files, err := ghc.ListPRFiles(num); err == nil {
+				for _, f := range files {
+					changed = append(changed, f.Filename)
+				}
+			}
+		}
+
+		for i, f := range failures {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s %s %s\n", ui.Red("✗"), ui.Bold(f.Check.Name), ui.Gray(f.Check.Conclusion))
+			if f.Check.HTMLURL != "" {
+				fmt.Println(ui.Blue(f.Check.HTMLURL))
+			}
+			if f.Log == "" {
+				fmt.Println(ui.Gray(f.Reason))
+				continue
+			}
+			fmt.Println()
+			fmt.Println(ui.Gray(indent(f.Log, "  ")))
+
+			if client == nil {
+				continue
+			}
+			spinner := ui.NewSpinner()
+			spinner.Start("Asking AI what went wrong...")
+			explanation, err := app.ExplainCIFailure(client, f, changed)
+			if err != nil {
+				spinner.StopFail()
+				ui.Warning(fmt.Sprintf("No AI explanation: %v", err))
+				continue
+			}
+			spinner.StopSuccess()
+			fmt.Printf("\n%s\n%s\n", ui.Sage("Why it failed:"), ui.RenderMarkdown(explanation))
+		}
+		return nil
+	},
+}
+
+// ciAIClient returns an AI client, or nil with a hint when AI can't be used
+func ciAIClient() *ai.Client {
+	if !config.AIEnabled("") {
+		return nil
+	}
+	client := aiClient()
+	if !client.Configured() {
+		fmt.Println(ui.Gray("Set an AI API key (sage config set ai_api_key YOUR_KEY) for an explanation of each failure."))
+		return nil
+	}
+	return client
+}
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciWhyCmd)
+	ciWhyCmd.Flags().StringVar(&ciWhyCheck, "check", "", "Only explain the failed check with this name")
+	ciWhyCmd.Flags().BoolVar(&ciWhyNoAI, "no-ai", false, "Only show the error lines from the logs")
+	ciWhyCmd.Flags().IntVar(&ciWhyLines, "lines", 60, "Maximum log lines to show for each failed check")
+}
@@ -2,9 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/ai"
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
@@ -12,26 +18,50 @@ import (
 )
 
 var (
-	cleanNoRemote bool
+	cleanNoRemote      bool
+	cleanNoIssues      bool
+	cleanAbandonedDays int
+	cleanOutput        string
 )
 
 var cleanCmd = &cobra.Command{
-	Use:   "clean",
-	Short: "Clean up merged branches",
+	Use:         "clean",
+	Short:       "Clean up merged branches",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Clean up branches that have been merged or whose PRs have been closed.
 This includes:
 - Branches that are merged into the default branch
 - Branches whose PRs were closed or merged through GitHub
-- Remote branches that were deleted`,
+- Remote branches that were deleted
+
+Before deleting a branch with a significant amount of unmerged work older than
+clean.abandoned_days (30 by default), sage offers to open a GitHub issue
+describing the work, with an AI summary when AI is set up, so the ideas
+aren't lost.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
-		ghc := gh.NewClient()
+		ghc := githubClient()
 
 		info, err := app.FindCleanableBranches(g, ghc)
 		if err != nil {
 			return err
 		}
 
+		// The plan on its own, for scripts; only with --dry-run, as nothing is asked
+		if structuredOutput(cmd) {
+			if !dryrun.Enabled() {
+				return exitcode.Errorf(exitcode.Usage, "--output %s lists what would be cleaned; add --dry-run", cleanOutput)
+			}
+			if cleanNoRemote || info.RemoteBranches == nil {
+				info.RemoteBranches = []string{}
+			}
+			if info.LocalBranches == nil {
+				info.LocalBranches = []string{}
+			}
+			_, err := printStructured(cleanOutput, info)
+			return err
+		}
+
 		if len(info.LocalBranches) == 0 && len(info.RemoteBranches) == 0 {
 			fmt.Println(ui.Green("No branches to clean."))
 			return nil
@@ -52,11 +82,8 @@ This includes:
 			}
 		}
 
-		var confirm bool
-		prompt := &survey.Confirm{
-			Message: "Delete these branches?",
-		}
-		if err := survey.AskOne(prompt, &confirm); err != nil {
+		confirm, err := ui.ConfirmAction("Delete these branches?")
+		if err != nil {
 			return err
 		}
 		if !confirm {
@@ -64,6 +91,15 @@ This includes:
 			return nil
 		}
 
+		if !cleanNoIssues && len(info.LocalBranches) > 0 {
+			keep, err := offerAbandonedIssues(g, ghc, info)
+			if err != nil {
+				return err
+			}
+			info.LocalBranches = withoutBranches(info.LocalBranches, keep)
+			info.RemoteBranches = withoutBranches(info.RemoteBranches, keep)
+		}
+
 		// Delete local branches
 		if len(info.LocalBranches) > 0 {
 			results := app.DeleteLocalBranches(g, info.LocalBranches)
@@ -92,7 +128,103 @@ This includes:
 	},
 }
 
+// offerAbandonedIssues asks, for each branch with abandoned work, whether to
+// record the work in an issue before deleting it. It returns the branches to
+// keep, including any whose issue couldn't be created.
+func offerAbandonedIssues(g git.Service, ghc gh.Client, info *app.CleanableBranches) (map[string]bool, error) {
+	days := cleanAbandonedDays
+	if days < 0 {
+		days = app.AbandonedDays()
+	}
+	work := app.FindAbandonedWork(g, info.LocalBranches, info.DefaultBranch, time.Duration(days)*24*time.Hour)
+
+	const (
+		openIssue = "Open an issue, then delete it"
+		justDrop  = "Delete it without an issue"
+		keepIt    = "Keep the branch"
+	)
+	keep := map[string]bool{}
+	for _, w := range work {
+		last := w.LastCommit()
+		fmt.Printf("\n%s %s has %d unmerged commit%s %s, last commit %s: %s\n",
+			ui.Yellow("!"), ui.Bold(w.Branch), len(w.Commits), ui.Plural(len(w.Commits)),
+			ui.Gray(fmt.Sprintf("(+%d/-%d)", w.Added, w.Deleted)), formatAge(time.Since(last.Date)), last.Subject)
+
+		// Unmerged work is only thrown away when someone says so
+		if batch.Enabled() {
+			batch.Note("keeping %s; run sage clean in a terminal to decide", w.Branch)
+			keep[w.Branch] = true
+			continue
+		}
+
+		var choice string
+		prompt := &survey.Select{
+			Message: "Record this work before deleting the branch?",
+			Options: []string{openIssue, justDrop, keepIt},
+		}
+		if err := survey.AskOne(prompt, &choice); err != nil {
+			return nil, err
+		}
+		switch choice {
+		case keepIt:
+			keep[w.Branch] = true
+			continue
+		case justDrop:
+			continue
+		}
+
+		summary := ""
+		if client := abandonedAIClient(); client != nil {
+			spinner := ui.NewSpinner()
+			spinner.Start("Summarizing the work...")
+			s, err := app.SummarizeAbandonedWork(g, client, w)
+			if err != nil {
+				spinner.StopFail()
+				ui.Warning(fmt.Sprintf("No AI summary: %v", err))
+			} else {
+				spinner.StopSuccess()
+				summary = s
+			}
+		}
+
+		issue, err := ghc.CreateIssue(app.AbandonedIssueTitle(w), app.AbandonedIssueBody(w, summary), nil)
+		if err != nil {
+			fmt.Printf("%s Could not open an issue for %s, keeping it: %v\n", ui.Red("✗"), w.Branch, err)
+			keep[w.Branch] = true
+			continue
+		}
+		fmt.Printf("%s Opened issue #%d %s\n", ui.Green("✓"), issue.Number, ui.Blue(issue.HTMLURL))
+	}
+	return keep, nil
+}
+
+// abandonedAIClient returns an AI client when AI is enabled and configured
+func abandonedAIClient() *ai.Client {
+	if !config.AIEnabled("") {
+		return nil
+	}
+	client := aiClient()
+	if !client.Configured() {
+		return nil
+	}
+	return client
+}
+
+// withoutBranches returns branches minus those in drop
+func withoutBranches(branches []string, drop map[string]bool) []string {
+	var kept []string
+	for _, br := range branches {
+		if !drop[br] {
+			kept = append(kept, br)
+		}
+	}
+	return kept
+}
+
 func init() {
 	rootCmd.AddCommand(cleanCmd)
 	cleanCmd.Flags().BoolVar(&cleanNoRemote, "no-remote", false, "Skip deleting remote branches")
+	cleanCmd.Flags().BoolVar(&cleanNoIssues, "no-issues", false, "Don't offer to open issues for abandoned work")
+	cleanCmd.Flags().IntVar(&cleanAbandonedDays, "abandoned-days", -1, "Age in days after which unmerged work counts as abandoned (default: clean.abandoned_days or 30)")
+	addOutputFlag(cleanCmd, &cleanOutput)
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/forge"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// API clients are built the first time a command needs one. Building a
+// GitHub client can mean parsing remotes and running 'gh auth token', which
+// local operations shouldn't pay for.
+var (
+	githubClient = lazy(gh.NewClient)
+	forgeClient  = lazy(forge.NewClient)
+	aiClient     = lazy(func() *ai.Client {
+		return ai.NewClient("", ai.NewConfigAdapter(config.Get))
+	})
+	// issueTracker is nil when no tracker is configured. Ticket links are
+	// extras, so a broken setup is a warning rather than a failure.
+	issueTracker = lazy(func() issues.Tracker {
+		t, err := issues.New()
+		if err != nil {
+			if !errors.Is(err, issues.ErrNotConfigured) {
+				ui.Warnf("Ticket links are off: %v\n", err)
+			}
+			return nil
+		}
+		return t
+	})
+)
+
+// lazy returns an accessor that calls build once, on first use, and returns
+// its result from then on. A panic from build (no token, no remote) is
+// repeated on every call rather than turning into a nil client.
+func lazy[T any](build func() T) func() T {
+	var (
+		once    sync.Once
+		client  T
+		failure any
+	)
+	return func() T {
+		once.Do(func() {
+			defer func() { failure = recover() }()
+			client = build()
+		})
+		if failure != nil {
+			panic(failure)
+		}
+		return client
+	}
+}
+
+// optionalGitHubClient returns a GitHub client, or nil if one can't be configured
+func optionalGitHubClient() (c gh.Client) {
+	defer func() {
+		if recover() != nil {
+			c = nil
+		}
+	}()
+	return githubClient()
+}
+
+// optionalForgeClient returns a client for the repository's forge, or nil if
+// one can't be configured
+func optionalForgeClient() (c gh.Client) {
+	defer func() {
+		if recover() != nil {
+			c = nil
+		}
+	}()
+	return forgeClient()
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/clipboard"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// copyToClipboard copies text for a --copy flag. Not having a clipboard, as
+// over SSH, only earns a note since the text has been printed anyway.
+func copyToClipboard(text, what string) {
+	err := clipboard.Copy(text)
+	switch {
+	case errors.Is(err, clipboard.ErrUnavailable):
+		fmt.Println(ui.Gray(fmt.Sprintf("No clipboard available; %s not copied", what)))
+	case err != nil:
+		ui.Warning(fmt.Sprintf("Couldn't copy %s: %v", what, err))
+	default:
+		fmt.Printf("%s Copied %s to the clipboard\n", ui.Green("✓"), what)
+	}
+}
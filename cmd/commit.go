@@ -4,8 +4,11 @@ import (
 	"fmt"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/crazywolf132/sage/internal/ui/hunks"
 	"github.com/spf13/cobra"
 )
 
@@ -17,13 +20,19 @@ var (
 	commitAI           bool
 	commitAutoAccept   bool
 	commitAmend        bool
+	commitSubjectOnly  bool
 	commitOnlyStaged   bool
 	commitInteractive  bool
+	commitOnly         []string
+	commitEdit         bool
+	commitCopy         bool
+	commitSplit        bool
 )
 
 var commitCmd = &cobra.Command{
-	Use:   "commit [message]",
-	Short: "Stage and commit changes",
+	Use:         "commit [message]",
+	Short:       "Stage and commit changes",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Stage and commit changes in one step.
 
 Examples:
@@ -42,8 +51,26 @@ Examples:
   # Interactive selection of files to stage during commit
   sage commit -i "docs: update readme"
   
+  # Commit only paths under pkg/, leaving other changes alone
+  sage commit --only 'pkg/...' "refactor(pkg): simplify loader"
+
+  # Write the message in $EDITOR, starting from an AI suggestion
+  sage commit --edit --ai
+
+  # Generate a message with AI and copy it instead of committing
+  sage commit --copy
+
+  # Let AI split what's staged into a series of commits, shown one by one
+  # to commit, reword or give other hunks first
+  sage commit --split
+
   # Amend the last commit with updated files or commit message
   sage commit --amend "refactor: update commit message"
+
+  # Amend and let AI rewrite the message for the whole amended commit,
+  # or only its subject line, keeping a hand-written body
+  sage commit --amend --ai
+  sage commit --amend --ai --subject-only
   
 When files have been manually staged with 'git add' or 'sage stage', 
 Sage will detect this and give you smart options to either:
@@ -59,6 +86,49 @@ Sage will detect this and give you smart options to either:
 
 		g := git.NewShellGit()
 
+		if commitCopy {
+			if commitMessage != "" {
+				return fmt.Errorf("--copy generates the message, so don't give one")
+			}
+			msg, err := app.SuggestCommitMessage(g)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n\n", msg)
+			copyToClipboard(msg, "the commit message")
+			return nil
+		}
+
+		if commitSplit {
+			if commitMessage != "" || commitAmend || commitInteractive || len(commitOnly) > 0 {
+				return exitcode.Errorf(exitcode.Usage, "--split writes its own messages and commits what's staged; stage with 'sage stage' first instead of passing files or a message")
+			}
+			created, err := app.CommitSplit(g, app.SplitOptions{
+				Pick:       hunks.Pick,
+				AutoAccept: commitAutoAccept,
+				Tracker:    issueTracker(),
+			})
+			if err != nil {
+				return err
+			}
+			if dryrun.Enabled() {
+				fmt.Printf("%s Would create %d commits\n", ui.Green("✓"), len(created))
+				return nil
+			}
+			fmt.Printf("%s Created %d commits\n", ui.Green("✓"), len(created))
+			if commitPush && len(created) > 0 {
+				branch, err := g.CurrentBranch()
+				if err != nil {
+					return err
+				}
+				if err := g.Push(branch, false); err != nil {
+					return err
+				}
+				fmt.Printf("%s Pushed %s\n", ui.Green("✓"), branch)
+			}
+			return nil
+		}
+
 		res, err := app.Commit(g, app.CommitOptions{
 			Message:         commitMessage,
 			AllowEmpty:      commitEmpty,
@@ -67,14 +137,22 @@ Sage will detect this and give you smart options to either:
 			UseAI:           commitAI,
 			AutoAccept:      commitAutoAccept,
 			Amend:           commitAmend,
+			SubjectOnly:     commitSubjectOnly,
 			OnlyStaged:      commitOnlyStaged,
 			Interactive:     commitInteractive,
+			Only:            commitOnly,
+			Edit:            commitEdit,
+			Tracker:         issueTracker(),
 		})
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("%s Created commit", ui.Green("✓"))
+		verb := "Created commit"
+		if dryrun.Enabled() {
+			verb = "Would create commit"
+		}
+		fmt.Printf("%s %s", ui.Green("✓"), verb)
 		if res.Pushed {
 			fmt.Printf(" and pushed")
 		}
@@ -97,8 +175,9 @@ Sage will detect this and give you smart options to either:
 			fmt.Printf("%s %d files changed\n", ui.Bold("Total:"), stats.TotalStaged)
 		}
 
-		// Remind user about unstaged files if any
-		if stats.TotalUnstaged > 0 {
+		// Remind user about unstaged files if any; in a dry run nothing was
+		// staged, so the count would include the files it meant to commit
+		if stats.TotalUnstaged > 0 && !dryrun.Enabled() {
 			fmt.Printf("\n%s You still have %d unstaged files. Use %s to commit them.\n",
 				ui.Yellow("!"),
 				stats.TotalUnstaged,
@@ -115,9 +194,16 @@ func init() {
 	commitCmd.Flags().BoolVarP(&commitPush, "push", "p", false, "Push after commit")
 	commitCmd.Flags().BoolVarP(&commitConventional, "conventional", "c", false, "Use conventional commit format")
 	commitCmd.Flags().BoolVarP(&commitAI, "ai", "a", false, "Use AI to generate commit message")
-	commitCmd.Flags().BoolVarP(&commitAutoAccept, "yes", "y", false, "Automatically accept AI-generated commit message")
+	commitCmd.Flags().BoolVar(&commitAutoAccept, "accept", false, "Automatically accept AI-generated commit message (-y does too, and turns other prompts off)")
 	commitCmd.Flags().BoolVar(&commitAmend, "amend", false, "Amend the last commit")
+	commitCmd.Flags().BoolVar(&commitSubjectOnly, "subject-only", false, "With --amend --ai, regenerate only the subject line and keep the body")
 	commitCmd.Flags().BoolVarP(&commitOnlyStaged, "only-staged", "s", false, "Commit only staged changes (don't automatically stage all files)")
 	commitCmd.Flags().BoolVarP(&commitInteractive, "interactive", "i", false, "Interactively select files to commit")
+	commitCmd.Flags().StringArrayVar(&commitOnly, "only", nil, "Commit only paths matching this pattern (repeatable, supports globs and dir/...)")
+	commitCmd.Flags().BoolVarP(&commitEdit, "edit", "e", false, "Compose the message in your editor with the changes listed")
 	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Commit message")
+	commitCmd.Flags().BoolVar(&commitCopy, "copy", false, "Generate a commit message with AI and copy it to the clipboard without committing")
+	commitCmd.Flags().BoolVar(&commitSplit, "split", false, "Let AI split the staged changes into a series of commits, reviewed one by one")
+	commitCmd.MarkFlagsMutuallyExclusive("only", "interactive")
+	commitCmd.MarkFlagsMutuallyExclusive("split", "copy")
 }
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// compareCommitLimit is how many commits are listed for each side
+const compareCommitLimit = 15
+
+var (
+	compareJSON   bool
+	compareOutput string
+	compareWeb    bool
+	compareList   bool
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <base> [head]",
+	Short: "Compare two branches before merging or retargeting",
+	Long: `Shows how head stands against base: the commits each has that the other
+doesn't, their merge base, the files merging head into base would change and
+the ones it would conflict on. head defaults to the current branch, and a
+branch that only exists on origin is compared as origin has it.
+
+In a terminal, pick a file afterwards to read its diff. Use --web to open the
+comparison on GitHub instead, where a pull request can be opened from it.`,
+	Example: `  sage compare main
+  sage compare release/2.1 release/2.2
+  sage compare develop feature/login --json
+  sage compare main --web`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+
+		head := ""
+		if len(args) == 2 {
+			head = args[1]
+		} else {
+			cur, err := g.CurrentBranch()
+			if err != nil {
+				return err
+			}
+			head = cur
+		}
+
+		if compareWeb {
+			url, err := app.CompareURL(repoinfo.Default(), args[0], head)
+			if err != nil {
+				return err
+			}
+			openInBrowser(url)
+			return nil
+		}
+
+		base, err := app.CompareRef(g, args[0])
+		if err != nil {
+			return err
+		}
+		if head, err = app.CompareRef(g, head); err != nil {
+			return err
+		}
+		c, err := app.CompareBranches(g, base, head)
+		if err != nil {
+			return err
+		}
+
+		if compareJSON {
+			compareOutput = "json"
+		}
+		if ok, err := printStructured(compareOutput, c); ok || err != nil {
+			return err
+		}
+
+		printComparison(c)
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+		if compareList || !interactive || batch.Enabled() || len(c.Files) == 0 {
+			return nil
+		}
+		return browseComparison(g, c)
+	},
+}
+
+func printComparison(c *app.Comparison) {
+	fmt.Printf("\n%s %s %s %s\n", ui.Bold(ui.Sage("Comparing")), ui.Blue(c.Base), ui.Gray("←"), ui.Blue(c.Head))
+	if c.MergeBase == nil {
+		fmt.Printf("%s\n", ui.Yellow("The branches share no history"))
+	} else {
+		fmt.Printf("%s %s %s %s\n", ui.Gray("Merge base:"), ui.Yellow(c.MergeBase.ShortHash), c.MergeBase.Message,
+			ui.Gray("("+formatAge(time.Since(c.MergeBase.Date))+")"))
+	}
+
+	printCompareCommits(c.Head, c.Base, c.HeadOnly)
+	printCompareCommits(c.Base, c.Head, c.BaseOnly)
+
+	fmt.Println()
+	if len(c.Files) == 0 {
+		fmt.Println(ui.Gray("No file changes to merge"))
+	} else {
+		fmt.Printf("%s %s\n", ui.Bold(fmt.Sprintf("%d %s changed", len(c.Files), "file"+ui.Plural(len(c.Files)))),
+			ui.Green(fmt.Sprintf("+%d", c.Added))+" "+ui.Red(fmt.Sprintf("-%d", c.Deleted)))
+		for _, f := range c.Files {
+			fmt.Printf("  %s\n", comparedFileLine(f))
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case !c.ConflictsChecked:
+		fmt.Println(ui.Gray("Conflicts can't be predicted with this version of git (2.38 or later needed)"))
+	case len(c.Conflicts) == 0:
+		fmt.Printf("%s Merging %s into %s would not conflict\n", ui.Green("✓"), c.Head, c.Base)
+	default:
+		fmt.Printf("%s Merging %s into %s would conflict in %d %s:\n", ui.Red("✗"), c.Head, c.Base,
+			len(c.Conflicts), "file"+ui.Plural(len(c.Conflicts)))
+		for _, f := range c.Conflicts {
+			fmt.Printf("  %s\n", ui.Red(f))
+		}
+	}
+	fmt.Println()
+}
+
+// printCompareCommits lists the commits on one side the other doesn't have
+func printCompareCommits(side, other string, commits []app.CommitInfo) {
+	fmt.Println()
+	if len(commits) == 0 {
+		fmt.Printf("%s\n", ui.Gray(fmt.Sprintf("%s has no commits %s doesn't", side, other)))
+		return
+	}
+	fmt.Printf("%s\n", ui.Bold(fmt.Sprintf("%s has %d %s %s doesn't:", side, len(commits), "commit"+ui.Plural(len(commits)), other)))
+	for i, c := range commits {
+		if i == compareCommitLimit {
+			fmt.Printf("  %s\n", ui.Gray(fmt.Sprintf("… and %d more", len(commits)-i)))
+			break
+		}
+		fmt.Printf("  %s %s %s %s\n", ui.Sage("●"), ui.Yellow(c.ShortHash), c.Message, ui.Gray("@"+strings.Split(c.AuthorName, " ")[0]))
+	}
+}
+
+func comparedFileLine(f app.ComparedFile) string {
+	if f.Binary {
+		return fmt.Sprintf("%s %s", f.Path, ui.Gray("(binary)"))
+	}
+	return fmt.Sprintf("%s %s %s", f.Path, ui.Green(fmt.Sprintf("+%d", f.Added)), ui.Red(fmt.Sprintf("-%d", f.Deleted)))
+}
+
+// browseComparison lets the user pick files to read the diff of
+func browseComparison(g git.Service, c *app.Comparison) error {
+	diffs, err := app.CompareFileDiffs(g, c.Base, c.Head)
+	if err != nil {
+		return err
+	}
+	const done = "Done"
+	options := make([]string, 0, len(c.Files)+1)
+	byOption := make(map[string]string, len(c.Files))
+	for _, f := range c.Files {
+		o := comparedFileLine(f)
+		if slices.Contains(c.Conflicts, f.Path) {
+			o += " " + ui.Red("conflicts")
+		}
+		options = append(options, o)
+		byOption[o] = f.Path
+	}
+	options = append(options, done)
+
+	for {
+		choice, err := ui.AskSelect("Read the diff of a file:", options, done, "", survey.WithPageSize(15))
+		if err != nil {
+			return err
+		}
+		if choice == done {
+			return nil
+		}
+		if err := ui.Page(ui.HighlightDiff(diffs[byOption[choice]])); err != nil {
+			return err
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().BoolVar(&compareJSON, "json", false, "Print the comparison as JSON (same as --output json)")
+	addOutputFlag(compareCmd, &compareOutput)
+	compareCmd.Flags().BoolVarP(&compareWeb, "web", "w", false, "Open the comparison on GitHub")
+	compareCmd.Flags().BoolVar(&compareList, "list", false, "Print the comparison without offering to browse the diffs")
+	compareCmd.MarkFlagsMutuallyExclusive("json", "output")
+	compareCmd.MarkFlagsMutuallyExclusive("web", "json")
+	compareCmd.MarkFlagsMutuallyExclusive("web", "output")
+}
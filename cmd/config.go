@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
@@ -12,6 +16,10 @@ import (
 
 var (
 	useLocalConfig bool
+	configBranch   string
+	configEnv      bool
+	configSecrets  string
+	configFix      bool
 )
 
 var configCmd = &cobra.Command{
@@ -24,8 +32,12 @@ Configuration is stored both globally and per-repository:
 - Local: Applies only to the current repository
 
 Sage looks for configuration in this order:
-1. Local repository config
-2. Global config
+1. Branch overrides matching the current branch (local, then global)
+2. Local repository config
+3. Global config
+
+Branch overrides live in [branch."<pattern>"] tables of either config file
+and are managed with --branch, e.g. to turn AI off on release branches.
 
 You can add custom configurations for new features with 'sage config set'.`,
 	Example: `  # View all config values
@@ -37,8 +49,8 @@ You can add custom configurations for new features with 'sage config set'.`,
   # Set a local config value (only for this repo)
   sage config set --local git.default_branch develop
 
-  # Set a merge strategy preference
-  sage config set git.merge.strategy merge  # Options: merge, rebase
+  # Set how sync brings in the parent branch
+  sage config set sync.strategy merge  # Options: merge, rebase
 
   # Always create PRs as drafts
   sage config set pr.draft true
@@ -46,6 +58,10 @@ You can add custom configurations for new features with 'sage config set'.`,
   # Set default PR reviewers
   sage config set pr.reviewers "user1,user2"
 
+  # Never use AI on release branches, always squash hotfixes
+  sage config set --local --branch 'release/*' ai.enabled false
+  sage config set --local --branch 'hotfix/**' git.merge_method squash
+
   # Enable experimental features
   sage config set experimental.rerere true`,
 	Args: cobra.MaximumNArgs(1),
@@ -67,6 +83,9 @@ Use --local to read from the local repository config instead (only works inside
 		}
 
 		val := config.Get(args[0], useLocalConfig)
+		if configBranch != "" {
+			val = config.GetForBranch(args[0], configBranch)
+		}
 		if val == "" {
 			fmt.Println(ui.Gray("not set"))
 		} else {
@@ -77,9 +96,10 @@ Use --local to read from the local repository config instead (only works inside
 }
 
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
-	Args:  cobra.ExactArgs(2),
-	Short: "Set a config value",
+	Use:         "set <key> <value>",
+	Args:        cobra.ExactArgs(2),
+	Short:       "Set a config value",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Set a configuration value. By default, saves to the global config.
 Use --local to save to the local repository config instead (only works inside git repositories).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -92,7 +112,12 @@ Use --local to save to the local repository config instead (only works inside gi
 		}
 
 		key, value := args[0], args[1]
-		err := config.Set(key, value, !useLocalConfig)
+		var err error
+		if configBranch != "" {
+			err = config.SetBranch(configBranch, key, value, !useLocalConfig)
+		} else {
+			err = config.Set(key, value, !useLocalConfig)
+		}
 		if err != nil {
 			return err
 		}
@@ -100,7 +125,16 @@ Use --local to save to the local repository config instead (only works inside gi
 		if useLocalConfig {
 			location = "local"
 		}
-		fmt.Printf("%s %s=%s (%s config)\n", ui.Green("Set"), key, value, location)
+		if configBranch != "" {
+			location += " config, branches matching " + configBranch
+		} else {
+			location += " config"
+		}
+		if config.IsSensitive(key) && !useLocalConfig {
+			fmt.Printf("%s %s (kept in the %s)\n", ui.Green("Set"), key, config.SecretStore())
+			return nil
+		}
+		fmt.Printf("%s %s=%s (%s)\n", ui.Green("Set"), key, value, location)
 		return nil
 	},
 }
@@ -114,18 +148,42 @@ var configListCmd = &cobra.Command{
 
 		// AI Configuration
 		fmt.Printf("\n%s\n", ui.Bold("AI Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.provider"),
+			"The AI service to use: openai, anthropic or ollama (a local model)",
+			"Default:", ui.Gray("openai"))
 		fmt.Printf("  %s\n    %s\n    %s %s\n",
 			ui.White("ai.model"),
 			"The AI model to use for generating content",
-			"Default:", ui.Gray("gpt-4"))
+			"Default:", ui.Gray("gpt-4o, claude-sonnet-4-5 or llama3.1, by provider"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.model.commit, ai.model.pr, ai.model.review"),
+			"Models for commit messages, PR content and reviewing changes (conflicts, CI logs)",
+			"Default:", ui.Gray("ai.model"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.temperature"),
+			"How inventive the answers are, from 0 (predictable) upwards",
+			"Default:", ui.Gray("the provider's"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.max_tokens"),
+			"The longest answer to accept, in tokens",
+			"Default:", ui.Gray("the provider's, 4096 for anthropic"))
 		fmt.Printf("  %s\n    %s\n    %s %s\n",
 			ui.White("ai.base_url"),
 			"Base URL for the AI API endpoint",
-			"Default:", ui.Gray("https://api.openai.com/v1"))
+			"Default:", ui.Gray("the provider's, e.g. https://api.openai.com/v1"))
 		fmt.Printf("  %s\n    %s\n    %s %s\n",
 			ui.White("ai.api_key"),
-			"API key for the AI service (can also be set via OPENAI_API_KEY env var)",
+			"API key for the AI service, kept in the OS keychain (can also be set via OPENAI_API_KEY or ANTHROPIC_API_KEY)",
 			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.context_tokens"),
+			"How many tokens of prompt to send; larger diffs are trimmed to fit",
+			"Default:", ui.Gray("the provider's, 8192 for ollama"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ai.enabled"),
+			"Set to false to refuse --ai (useful as a branch override)",
+			"Default:", ui.Gray("true"))
 
 		// Git Configuration
 		fmt.Printf("\n%s\n", ui.Bold("Git Settings:"))
@@ -138,11 +196,155 @@ var configListCmd = &cobra.Command{
 			"Default merge method for PRs (merge, squash, rebase)",
 			"Default:", ui.Gray("merge"))
 
+		// Sync Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Sync Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("sync.strategy"),
+			"How sync integrates the parent branch (merge, rebase)",
+			"Default:", ui.Gray("automatic"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("sync.push"),
+			"Set to false to skip pushing after sync (like --no-push)",
+			"Default:", ui.Gray("true"))
+
+		// Commit Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Commit Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("commit.timestamp_round"),
+			"Round author and committer dates of 'sage commit' down to the minute, hour or day, for privacy",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("commit.timezone"),
+			"Record commit dates in this timezone instead of your local one (UTC, Europe/Berlin, +0200)",
+			"Default:", ui.Gray("local timezone"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("commit.message_pattern"),
+			"Regex every commit message must match; sage commit refuses others",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("commit.message_warn_pattern"),
+			"Regex commit messages should match; sage commit warns about others",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("commit.ticket_pattern"),
+			"Regex finding the ticket ID in branch names, for {{ticket}} in .sage/commit-template and ticket links",
+			"Default:", ui.Gray("[A-Z][A-Z0-9]+-[0-9]+, or the number in fix/123-name"))
+
+		// Start Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Start Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("start.fetch"),
+			"What 'sage start' fetches first: full (all remotes), minimal (the default branch from origin) or none",
+			"Default:", ui.Gray("minimal"))
+
+		// Secret Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Secret Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("secrets.blocked_files"),
+			"Globs of files that are never staged or committed unless listed in .sage/secret-allow (comma-separated)",
+			"Default:", ui.Gray(".env*,*.pem,id_rsa*"))
+
+		// Clean Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Clean Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("clean.abandoned_days"),
+			"Days after which unmerged work on a deleted branch counts as abandoned; sage clean offers to open an issue for it",
+			"Default:", ui.Gray("30"))
+
+		// Doctor Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Doctor Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("doctor.email_domains"),
+			"Email domains commits to this repository should be made from; sage doctor warns when user.email isn't one (comma-separated)",
+			"Default:", ui.Gray("the domain most other authors use"))
+
 		// GitHub Configuration
 		fmt.Printf("\n%s\n", ui.Bold("GitHub Settings:"))
 		fmt.Printf("  %s\n    %s\n    %s %s\n",
 			ui.White("github.token"),
-			"GitHub personal access token (can also be set via SAGE_GITHUB_TOKEN or GITHUB_TOKEN env vars)",
+			"GitHub personal access token, kept in the OS keychain (can also be set via SAGE_GITHUB_TOKEN or GITHUB_TOKEN env vars)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.accounts.<name>"),
+			"Token for one of several GitHub accounts, e.g. github.accounts.work (see 'sage whoami --help')",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.identity.<owner|host/owner|host|*>"),
+			"Which stored account to use for an owner's repositories or a host",
+			"Default:", ui.Gray("none; GITHUB_TOKEN or the GitHub CLI"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.auth"),
+			"How to authenticate with GitHub: token, or app to use a GitHub App installation",
+			"Default:", ui.Gray("token"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.app.id"),
+			"The GitHub App's ID, with github.auth set to app",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.app.installation_id"),
+			"The ID of the App's installation on your organisation",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("github.app.private_key"),
+			"Path to the App's PEM private key, or the key itself",
+			"Default:", ui.Gray("none"))
+
+		// Forge Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Forge Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("forge.type"),
+			"Where 'sage pr' manages pull requests (github, gitlab, bitbucket)",
+			"Default:", ui.Gray("detected from origin's host, otherwise github"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("gitlab.token"),
+			"GitLab personal access token with api scope (can also be set via SAGE_GITLAB_TOKEN or GITLAB_TOKEN env vars)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("bitbucket.username"),
+			"Bitbucket username to use with bitbucket.app_password (or BITBUCKET_USERNAME)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("bitbucket.app_password"),
+			"Bitbucket app password with pull request read/write access (or BITBUCKET_APP_PASSWORD)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("bitbucket.token"),
+			"Bitbucket repository, workspace or OAuth access token, used instead of an app password (or BITBUCKET_TOKEN)",
+			"Default:", ui.Gray("none"))
+
+		// Issue Tracker Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Issue Tracker Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("issues.provider"),
+			"Where the tickets named in branch names live (jira, linear)",
+			"Default:", ui.Gray("whichever of JIRA and Linear has settings"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("jira.base_url"),
+			"Your JIRA site, e.g. https://acme.atlassian.net (or JIRA_BASE_URL)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("jira.email"),
+			"Email to use with a JIRA Cloud API token; leave unset for a Server access token (or JIRA_EMAIL)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("jira.token"),
+			"JIRA API token (or JIRA_API_TOKEN)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("linear.token"),
+			"Linear personal API key (or LINEAR_API_KEY)",
+			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("issues.link_commits"),
+			"Add a Refs: trailer linking the branch's ticket to commit messages",
+			"Default:", ui.Gray("true"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("issues.link_prs"),
+			"Add the branch's ticket to PR descriptions",
+			"Default:", ui.Gray("true"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("issues.transition_on_merge"),
+			"Status (or JIRA transition) to move the branch's ticket to after 'sage pr merge', e.g. Done",
 			"Default:", ui.Gray("none"))
 
 		// PR Configuration
@@ -159,11 +361,46 @@ var configListCmd = &cobra.Command{
 			ui.White("pr.labels"),
 			"Default labels to apply to PRs (comma-separated)",
 			"Default:", ui.Gray("none"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("pr.suggest_reviewers"),
+			"Without CODEOWNERS or pr.reviewers, suggest reviewers from the history of the changed files",
+			"Default:", ui.Gray("true"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("pr.squash_conventional"),
+			"Require squash merge titles from 'sage pr merge' to be conventional commits",
+			"Default:", ui.Gray("false"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("pr.branch_defaults.<pattern>"),
+			"Labels and reviewers for PRs from matching branches, e.g. pr.branch_defaults.fix/* \"label:bug, reviewer:@org/maintainers\"",
+			"Default:", ui.Gray("none"))
+
+		// UI Configuration
+		fmt.Printf("\n%s\n", ui.Bold("UI Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ui.notify"),
+			"How long commands (sync, push, pr merge, deploy...) tell you they finished: off, bell, desktop or all",
+			"Default:", ui.Gray("off"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("ui.notify_after"),
+			"How long a command runs before it notifies (--notify always does)",
+			"Default:", ui.Gray("30s"))
+
+		// Update Configuration
+		fmt.Printf("\n%s\n", ui.Bold("Update Settings:"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("update.channel"),
+			"Which releases 'sage update' installs and sage tells you about: stable, or beta for prereleases too",
+			"Default:", ui.Gray("stable"))
+		fmt.Printf("  %s\n    %s\n    %s %s\n",
+			ui.White("update.check_interval"),
+			"How often sage looks for a new release in the background and mentions it: daily, weekly or never",
+			"Default:", ui.Gray("daily"))
 
 		fmt.Printf("\n%s\n", ui.Bold("Usage:"))
 		fmt.Printf("  Set a value:   %s\n", ui.White("sage config set <key> <value>"))
 		fmt.Printf("  Get a value:   %s\n", ui.White("sage config get <key>"))
 		fmt.Printf("  Remove a value: %s\n", ui.White("sage config unset <key>"))
+		fmt.Printf("  Per branch:    %s\n", ui.White("sage config set --branch 'release/*' <key> <value>"))
 		fmt.Printf("  List values:   %s\n", ui.White("sage config list"))
 		fmt.Printf("  View experimental: %s\n\n", ui.White("sage config experimental"))
 
@@ -172,9 +409,10 @@ var configListCmd = &cobra.Command{
 }
 
 var configUnsetCmd = &cobra.Command{
-	Use:   "unset <key>",
-	Args:  cobra.ExactArgs(1),
-	Short: "Remove a config value",
+	Use:         "unset <key>",
+	Args:        cobra.ExactArgs(1),
+	Short:       "Remove a config value",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Remove a configuration value. By default, removes from the global config.
 Use --local to remove from the local repository config instead (only works inside git repositories).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -187,7 +425,117 @@ Use --local to remove from the local repository config instead (only works insid
 		}
 
 		key := args[0]
-		err := config.Unset(key, !useLocalConfig)
+		var err error
+		if configBranch != "" {
+			err = config.UnsetBranch(configBranch, key, !useLocalConfig)
+		} else {
+			err = config.Unset(key, !useLocalConfig)
+		}
+		if err != nil {
+			return err
+		}
+		location := "global"
+		if useLocalConfig {
+			location = "local"
+		}
+		if configBranch != "" {
+			location += " config, branches matching " + configBranch
+		} else {
+			location += " config"
+		}
+		fmt.Printf("%s Removed %s (%s)\n", ui.Green("✓"), key, location)
+		return nil
+	},
+}
+
+var configExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the effective config to share or bootstrap CI",
+	Long: `Print the configuration in force here, global with this repository's
+values on top, as TOML that 'sage config import' reads back. With --env it
+prints shell export lines instead, which 'sage config import --env' picks up.
+
+Secrets are never exported. By default they become references to
+SAGE_CONFIG_* environment variables, filled in from the environment on
+import; --secrets mask writes a placeholder instead and --secrets omit
+leaves them out.`,
+	Example: `  # Share a team setup
+  sage config export > sage.toml
+
+  # Bootstrap CI from environment variables
+  sage config export --env > sage.env`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mode := config.SecretMode(configSecrets)
+		switch mode {
+		case config.SecretsReference, config.SecretsMask, config.SecretsOmit:
+		default:
+			return exitcode.Errorf(exitcode.Usage, "invalid --secrets %q (use reference, mask or omit)", configSecrets)
+		}
+
+		settings := config.Effective()
+		if configEnv {
+			fmt.Print(settings.Env(mode))
+			return nil
+		}
+		b, err := settings.TOML(mode)
+		if err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		fmt.Print(string(b))
+		return nil
+	},
+}
+
+var configImportCmd = &cobra.Command{
+	Use:         "import [file]",
+	Short:       "Load config exported with 'sage config export'",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Load settings from a TOML export (or any sage config file), from stdin
+when the file is - , or with --env from SAGE_CONFIG_* environment variables.
+Values land in the global config, or this repository's with --local, over
+whatever is there.
+
+Values of the form env:NAME are read from the environment variable NAME.
+Masked secrets, and secrets imported with --local, are skipped.`,
+	Example: `  sage config import sage.toml
+  sage config import --local team.toml
+  SAGE_CONFIG_PR__DRAFT=true sage config import --env`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if useLocalConfig {
+			g := git.NewShellGit()
+			inRepo, _ := g.IsRepo()
+			if !inRepo {
+				return fmt.Errorf("--local flag can only be used inside a git repository")
+			}
+		}
+
+		var settings config.Settings
+		switch {
+		case configEnv && len(args) > 0:
+			return exitcode.Errorf(exitcode.Usage, "give a file or --env, not both")
+		case configEnv:
+			settings = config.SettingsFromEnv(os.Environ())
+		case len(args) == 0:
+			return exitcode.Errorf(exitcode.Usage, "give a file to import, - for stdin, or --env")
+		default:
+			var b []byte
+			var err error
+			if args[0] == "-" {
+				b, err = io.ReadAll(os.Stdin)
+			} else {
+				b, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", args[0], err)
+			}
+			if settings, err = config.ParseSettings(b); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", args[0], err)
+			}
+		}
+
+		res, err := config.Import(settings, !useLocalConfig, os.Getenv)
 		if err != nil {
 			return err
 		}
@@ -195,7 +543,10 @@ Use --local to remove from the local repository config instead (only works insid
 		if useLocalConfig {
 			location = "local"
 		}
-		fmt.Printf("%s Removed %s (%s config)\n", ui.Green("✓"), key, location)
+		fmt.Printf("%s Imported %d setting%s into the %s config\n", ui.Green("✓"), res.Imported, ui.Plural(res.Imported), location)
+		for _, skipped := range res.Skipped {
+			fmt.Printf("  %s %s\n", ui.Yellow("skipped"), skipped)
+		}
 		return nil
 	},
 }
@@ -305,6 +656,129 @@ func getFeatureDescription(name string) string {
 	}
 }
 
+var configSecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Move stored API keys and tokens into the OS keychain",
+	Long: `API keys and tokens set with 'sage config set', such as ai.api_key and
+github.token, are kept in the OS keychain: the macOS Keychain, the Windows
+Credential Manager, or a Secret Service keyring such as GNOME Keyring
+(through libsecret's secret-tool). Without one, or when it can't be reached,
+they go to a file encrypted for this machine next to the global config.
+Set SAGE_SECRETS_BACKEND=file to always use the file.
+
+This shows where secrets are kept and moves any still encrypted in the
+config file, from older versions of sage, into the store.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("Secrets are kept in the %s\n", ui.Bold(config.SecretStore()))
+		moved, err := config.MigrateSecrets()
+		for _, key := range moved {
+			fmt.Printf("%s %s\n", ui.Green("Moved"), key)
+		}
+		if err != nil {
+			return err
+		}
+		if len(moved) == 0 {
+			fmt.Println(ui.Gray("No secrets left in the config file"))
+		}
+		return nil
+	},
+}
+
+var configDoctorCmd = &cobra.Command{
+	Use:         "doctor",
+	Short:       "Find and repair problems in your config",
+	Annotations: map[string]string{mutatingAnnotation: "fix"},
+	Long: `Look through the global config, this repository's, and the secrets
+store for:
+- keys older versions of sage read, renamed or no longer used
+- values sage can't read, or only reads in one spelling (True for true)
+- API keys and tokens outside the secrets store: encrypted in the config
+  file by an older version, in plain text, in the local config, or
+  encrypted with a key this machine doesn't have
+- config files other users can write to, and a secrets file they can read
+
+--fix shows what it will change and asks first, then backs up the config
+and secrets files (next to them, ending in .bak) before changing anything.
+Values it can't fix are left for you. Keys in sections sage doesn't use
+are your own and are left alone.
+
+Problems left unfixed make it exit with code 7.`,
+	Example: `  sage config doctor
+  sage config doctor --fix`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issues := config.Check()
+		if len(issues) == 0 {
+			fmt.Printf("%s No problems found in your config\n", ui.Green("✓"))
+			return nil
+		}
+
+		fixable := 0
+		for _, i := range issues {
+			where := i.Scope
+			if i.Branch != "" {
+				where += fmt.Sprintf(" [branch.%q]", i.Branch)
+			}
+			what := i.Problem
+			if i.Key != "" {
+				what = ui.White(i.Key) + ": " + i.Problem
+			}
+			icon := ui.Red("✗")
+			if i.Fixable() {
+				icon = ui.Yellow("!")
+				fixable++
+			}
+			fmt.Printf("%s %s %s\n", icon, ui.Gray(where), what)
+			if i.Fixable() {
+				fmt.Printf("    %s %s\n", ui.Gray("fix:"), i.Fix)
+			}
+		}
+		fmt.Println()
+
+		if !configFix || fixable == 0 {
+			if fixable > 0 {
+				fmt.Printf("Run %s to fix %d of them\n", ui.Blue("sage config doctor --fix"), fixable)
+			}
+			return exitcode.Errorf(exitcode.Threshold, "%d problem%s found in your config", len(issues), ui.Plural(len(issues)))
+		}
+		if dryrun.Enabled() {
+			for _, i := range issues {
+				if !i.Fixable() {
+					continue
+				}
+				if i.Key != "" {
+					dryrun.Record("%s config, %s: %s", i.Scope, i.Key, i.Fix)
+				} else {
+					dryrun.Record("%s: %s", i.Problem, i.Fix)
+				}
+			}
+			return nil
+		}
+		confirm, err := ui.ConfirmAction(fmt.Sprintf("Apply %d fix%s?", fixable, pluralizeEs(fixable)))
+		if err != nil {
+			return err
+		}
+		if !confirm {
+			fmt.Println(ui.Gray("Aborted."))
+			return nil
+		}
+
+		backups, err := config.Repair(issues)
+		for _, b := range backups {
+			fmt.Printf("%s %s\n", ui.Gray("Backed up to"), b)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Fixed %d problem%s\n", ui.Green("✓"), fixable, ui.Plural(fixable))
+		if left := len(issues) - fixable; left > 0 {
+			return exitcode.Errorf(exitcode.Threshold, "%d problem%s left to fix by hand", left, ui.Plural(left))
+		}
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configGetCmd)
@@ -312,9 +786,24 @@ func init() {
 	configCmd.AddCommand(configListCmd)
 	configCmd.AddCommand(configUnsetCmd)
 	configCmd.AddCommand(configExperimentalCmd)
+	configCmd.AddCommand(configExportCmd)
+	configCmd.AddCommand(configImportCmd)
+	configCmd.AddCommand(configSecretsCmd)
+	configCmd.AddCommand(configDoctorCmd)
 
 	// Add --local flag to get, set, and unset commands
 	configGetCmd.Flags().BoolVarP(&useLocalConfig, "local", "l", false, "Use local repository config")
 	configSetCmd.Flags().BoolVarP(&useLocalConfig, "local", "l", false, "Use local repository config")
 	configUnsetCmd.Flags().BoolVarP(&useLocalConfig, "local", "l", false, "Use local repository config")
+	configImportCmd.Flags().BoolVarP(&useLocalConfig, "local", "l", false, "Import into the local repository config")
+
+	configExportCmd.Flags().BoolVar(&configEnv, "env", false, "Print shell export lines instead of TOML")
+	configExportCmd.Flags().StringVar(&configSecrets, "secrets", string(config.SecretsReference), "How to export secrets: reference, mask or omit")
+	configImportCmd.Flags().BoolVar(&configEnv, "env", false, "Import SAGE_CONFIG_* environment variables")
+
+	configGetCmd.Flags().StringVar(&configBranch, "branch", "", "Show the value that applies on this branch")
+	configSetCmd.Flags().StringVar(&configBranch, "branch", "", "Only apply to branches matching this pattern (e.g. 'release/*')")
+	configUnsetCmd.Flags().StringVar(&configBranch, "branch", "", "Remove the override for this branch pattern")
+
+	configDoctorCmd.Flags().BoolVar(&configFix, "fix", false, "Repair what can be repaired, after backing up the files")
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui/dash"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var dashInterval time.Duration
+
+var dashCmd = &cobra.Command{
+	Use:   "dash",
+	Short: "Full-screen dashboard of the branch, its PR, recent commits and stashes",
+	Long: `Show a live dashboard of the current branch: changes and how far it is
+ahead of or behind its upstream, its open pull request with checks and
+reviews, recent commits and stashes.
+
+The repository is reread every couple of seconds. The pull request is
+fetched every --interval, and less often when GitHub's rate limit runs low,
+so leaving the dashboard open never uses more than a quarter of the API
+requests left. Press r to refresh now and q to quit.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			return exitcode.Errorf(exitcode.Usage, "sage dash needs a terminal; use 'sage status --output json' in scripts")
+		}
+
+		g := git.NewShellGit()
+		opts := dash.Options{
+			Local: func() (*app.DashLocal, error) { return app.GetDashLocal(g) },
+		}
+		if ghc := optionalGitHubClient(); ghc != nil {
+			opts.PR = func(branch string) (*app.DashPR, error) { return app.GetDashPR(ghc, branch) }
+			opts.PRInterval = func() time.Duration {
+				rl, ok := gh.LastRateLimit()
+				return app.DashPollInterval(dashInterval, rl, ok, time.Now())
+			}
+			opts.RateLimit = func() string {
+				rl, ok := gh.LastRateLimit()
+				if !ok {
+					return ""
+				}
+				return fmt.Sprintf("%d/%d API requests left", rl.Remaining, rl.Limit)
+			}
+		}
+		return dash.Run(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dashCmd)
+	dashCmd.Flags().DurationVar(&dashInterval, "interval", 30*time.Second, "How often to fetch the pull request when the rate limit allows")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var deployConfirm string
+
+var deployCmd = &cobra.Command{
+	Use:         "deploy [target]",
+	Short:       "Push the current branch to a named deploy branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Update a deploy branch (e.g. deploy/staging) with the current branch and push it.
+
+Targets are configured as 'deploy.<name>' with a value of "<remote> <branch>":
+
+  sage config set deploy.staging "origin deploy/staging"
+  sage config set deploy.production "origin deploy/production"
+
+If the target can be fast-forwarded it is pushed directly; otherwise the current
+branch is merged into it. Targets named in 'deploy.protected' (default:
+production, prod) require typing the target name to confirm, or passing it with
+--confirm in scripts. Every deploy is
+recorded so 'sage undo' can roll the deploy branch back, or delete it again
+if the deploy created it.
+
+Run without a target to list the configured targets.`,
+	Example: `  sage deploy staging
+  sage deploy production
+  sage deploy production --confirm production --non-interactive`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return listDeployTargets()
+		}
+
+		target, err := app.GetDeployTarget(args[0])
+		if err != nil {
+			return err
+		}
+
+		g := git.NewShellGit()
+		branch, err := g.CurrentBranch()
+		if err != nil {
+			return err
+		}
+
+		if deployConfirm != "" && deployConfirm != target.Name {
+			return exitcode.Errorf(exitcode.Usage, "--confirm %s doesn't match the target %s", deployConfirm, target.Name)
+		}
+		if deployConfirm == "" {
+			ok, err := confirmDeploy(target, branch)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println(ui.Yellow("Deploy cancelled."))
+				return nil
+			}
+		}
+
+		res, err := app.Deploy(g, target)
+		if err != nil {
+			return err
+		}
+
+		dest := fmt.Sprintf("%s/%s", target.Remote, target.Branch)
+		switch res.Mode {
+		case "up-to-date":
+			fmt.Printf("%s %s is already up to date\n", ui.Green("✓"), dest)
+		case "created":
+			fmt.Printf("%s Created %s at %s\n", ui.Green("✓"), dest, shortRef(res.Deployed))
+		default:
+			fmt.Printf("%s Deployed %s to %s (%s, %s → %s)\n", ui.Green("✓"),
+				branch, target.Name, res.Mode, shortRef(res.Previous), shortRef(res.Deployed))
+		}
+		return nil
+	},
+}
+
+func confirmDeploy(t app.DeployTarget, branch string) (bool, error) {
+	dest := fmt.Sprintf("%s/%s", t.Remote, t.Branch)
+	if !t.Protected {
+		return ui.ConfirmAction(fmt.Sprintf("Deploy %s to %s (%s)?", branch, t.Name, dest))
+	}
+
+	fmt.Printf("%s %s is a protected target.\n", ui.Yellow("!"), ui.Bold(t.Name))
+	typed, err := ui.AskInput(fmt.Sprintf("Type '%s' to deploy %s to %s:", t.Name, branch, dest), "",
+		fmt.Sprintf("pass --confirm %s", t.Name))
+	return typed == t.Name, err
+}
+
+func listDeployTargets() error {
+	targets, err := app.ListDeployTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println(ui.Yellow("No deploy targets configured."))
+		fmt.Printf("Add one with %s\n", ui.Blue(`sage config set deploy.staging "origin deploy/staging"`))
+		return nil
+	}
+	fmt.Println(ui.Bold("Deploy targets:"))
+	for _, t := range targets {
+		line := fmt.Sprintf("  %-12s %s/%s", t.Name, t.Remote, t.Branch)
+		if t.Protected {
+			line += ui.Yellow(" (protected)")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(deployCmd)
+	deployCmd.Flags().StringVar(&deployConfirm, "confirm", "", "Deploy without asking, naming the target to confirm it (needed for protected targets in scripts)")
+}
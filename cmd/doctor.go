@@ -0,0 +1,446 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/crazywolf132/sage/internal/update"
+	"github.com/crazywolf132/sage/internal/version"
+	goversion "github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorInstall   bool
+	doctorFailOn    string
+	doctorFix       bool
+	doctorStaleDays int
+)
+
+// doctorCheck is the outcome of one doctor check
+type doctorCheck struct {
+	name   string
+	status string // ok, warn or fail
+	detail string // further lines say how to fix it
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that sage and its environment are set up correctly",
+	Long: `Run a series of checks and report anything that needs attention:
+- git is installed
+- the current directory is a repository with commits
+- no lock file left by a crashed git blocks the repository
+- git fsck finds no broken refs
+- HEAD is on a branch, and the branch tracks one on a remote
+- no local branch has gone untouched for longer than clean.abandoned_days
+  (30 by default; --stale-days overrides it)
+- no commit waiting to be pushed adds a file of 5 MiB or more
+- every hook in the hooks directory can run
+- git doesn't need to pack loose objects (gc)
+- user.email is from a domain the repository expects: doctor.email_domains,
+  or else the one most other authors use
+- a GitHub repository and token can be found
+- with --verify-install, the installed sage binary matches the published
+  release checksum (a mismatch means a modified or unofficial build); this
+  downloads the release archive, so it only runs when asked for
+
+--fix makes the safe repairs: it removes stale lock files, checks out the
+branch a detached HEAD is on, sets a missing upstream to the branch of the
+same name on origin, makes hooks executable and runs git gc. Everything else
+comes with the commands to fix it.
+
+Failed checks make it exit with code 7. --fail-on warn also counts warnings,
+--fail-on never only reports.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch doctorFailOn {
+		case "fail", "warn", "never":
+		default:
+			return exitcode.Errorf(exitcode.Usage, "invalid --fail-on %q (use fail, warn or never)", doctorFailOn)
+		}
+
+		checks := []doctorCheck{checkGit(), checkRepository()}
+		if inRepo, _ := git.NewShellGit().IsRepo(); inRepo {
+			checks = append(checks, checkLocks(), checkRefs(), checkBase(),
+				checkHead(), checkStaleBranches(), checkLargeFiles(), checkHooks(), checkGC(), checkEmail())
+		}
+		checks = append(checks, checkGitHub())
+		if doctorInstall {
+			checks = append(checks, checkInstall())
+		}
+
+		failed, warned := 0, 0
+		for _, c := range checks {
+			icon := ui.Green("✓")
+			switch c.status {
+			case "warn":
+				icon = ui.Yellow("!")
+				warned++
+			case "fail":
+				icon = ui.Red("✗")
+				failed++
+			}
+			detail, hints, _ := strings.Cut(c.detail, "\n")
+			fmt.Printf("%s %-12s %s\n", icon, c.name, ui.Gray(detail))
+			for _, h := range strings.Split(hints, "\n") {
+				if h != "" {
+					fmt.Printf("  %-12s %s\n", "", h)
+				}
+			}
+		}
+
+		switch {
+		case doctorFailOn == "never":
+		case failed > 0:
+			return exitcode.Errorf(exitcode.Threshold, "%d check%s failed", failed, ui.Plural(failed))
+		case doctorFailOn == "warn" && warned > 0:
+			return exitcode.Errorf(exitcode.Threshold, "%d check%s raised warnings", warned, ui.Plural(warned))
+		}
+		return nil
+	},
+}
+
+func checkGit() doctorCheck {
+	out, err := git.NewShellGit().Run("--version")
+	if err != nil {
+		return doctorCheck{"git", "fail", "git is not installed or not on PATH"}
+	}
+	return doctorCheck{"git", "ok", strings.TrimSpace(out)}
+}
+
+func checkRepository() doctorCheck {
+	g := git.NewShellGit()
+	if inRepo, _ := g.IsRepo(); !inRepo {
+		return doctorCheck{"repository", "warn", "not inside a git repository"}
+	}
+	if has, err := g.HasCommits(); err == nil && !has {
+		return doctorCheck{"repository", "warn", "no commits yet; run 'sage init'"}
+	}
+	branch, _ := g.CurrentBranch()
+	return doctorCheck{"repository", "ok", "on " + branch}
+}
+
+// checkLocks looks for lock files a crashed git left behind, removing them
+// with --fix
+func checkLocks() doctorCheck {
+	locks, err := app.FindLocks(git.NewShellGit())
+	if err != nil {
+		return doctorCheck{"locks", "warn", fmt.Sprintf("could not look for lock files: %v", err)}
+	}
+	var stale []app.GitLock
+	for _, l := range locks {
+		if l.Stale {
+			stale = append(stale, l)
+		}
+	}
+	if len(stale) == 0 {
+		if len(locks) > 0 {
+			return doctorCheck{"locks", "ok", fmt.Sprintf("%d lock file%s in use by a running git", len(locks), ui.Plural(len(locks)))}
+		}
+		return doctorCheck{"locks", "ok", "no lock files"}
+	}
+	if doctorFix {
+		if removed := removeStaleLocks(stale); removed == len(stale) {
+			return doctorCheck{"locks", "ok", fmt.Sprintf("removed %d stale lock file%s", removed, ui.Plural(removed))}
+		}
+	}
+
+	var hints []string
+	for _, l := range stale {
+		hints = append(hints, describeLock(l))
+	}
+	hints = append(hints, "Run 'sage doctor --fix' to remove "+pluralThem(len(stale)))
+	return doctorCheck{"locks", "fail", fmt.Sprintf("%d stale lock file%s will make git commands fail\n%s",
+		len(stale), ui.Plural(len(stale)), strings.Join(hints, "\n"))}
+}
+
+// checkRefs asks git fsck for refs pointing at missing or invalid commits
+func checkRefs() doctorCheck {
+	g := git.NewShellGit()
+	broken, err := app.FindBrokenRefs(g)
+	if err != nil {
+		return doctorCheck{"refs", "warn", fmt.Sprintf("git fsck could not run: %v", err)}
+	}
+	if len(broken) == 0 {
+		return doctorCheck{"refs", "ok", "git fsck found no broken refs"}
+	}
+
+	var hints []string
+	for _, r := range broken {
+		hints = append(hints, fmt.Sprintf("%s: %s", ui.White(r.Ref), r.Problem))
+		if r.LastGood != "" {
+			hints = append(hints, fmt.Sprintf("  restore its last good commit: %s", ui.Blue(fmt.Sprintf("git update-ref %s %s", r.Ref, r.LastGood))))
+		}
+		if r.Ref != "HEAD" {
+			hints = append(hints, fmt.Sprintf("  or delete it: %s", ui.Blue("git update-ref -d "+r.Ref)))
+		}
+	}
+	return doctorCheck{"refs", "fail", fmt.Sprintf("%d broken ref%s\n%s",
+		len(broken), ui.Plural(len(broken)), strings.Join(hints, "\n"))}
+}
+
+// checkBase checks that the trunk branches sync against is on origin
+func checkBase() doctorCheck {
+	base, err := app.BaseBranch(git.NewShellGit(), "")
+	switch {
+	case errors.Is(err, app.ErrBaseMissing):
+		return doctorCheck{"base", "fail", err.Error()}
+	case err != nil:
+		return doctorCheck{"base", "warn", "origin has no default branch; set one with 'sage config set sync.base <branch>'"}
+	}
+	return doctorCheck{"base", "ok", "syncing against " + base}
+}
+
+// checkHead checks that HEAD is on a branch and that the branch tracks one
+// on a remote, so push and sync know where it goes
+func checkHead() doctorCheck {
+	g := git.NewShellGit()
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return doctorCheck{"head", "warn", fmt.Sprintf("could not read HEAD: %v", err)}
+	}
+	if branch == "HEAD" {
+		// Rebasing detaches HEAD on purpose
+		if rebasing, _ := g.IsRebasing(); rebasing {
+			return doctorCheck{"head", "ok", "detached while a rebase is in progress"}
+		}
+		at, _ := g.BranchesAt("HEAD")
+		if len(at) == 1 && doctorFix {
+			if err := g.Checkout(at[0]); err == nil {
+				return doctorCheck{"head", "ok", "checked out " + at[0] + ", which HEAD was detached at"}
+			}
+		}
+		hint := "Create a branch to keep any commits made here: " + ui.Blue("sage start <name>")
+		if len(at) == 1 {
+			hint = fmt.Sprintf("%s is at the same commit; run 'sage doctor --fix' or %s", at[0], ui.Blue("git checkout "+at[0]))
+		}
+		return doctorCheck{"head", "warn", "HEAD is detached, so new commits belong to no branch\n" + hint}
+	}
+
+	upstream, err := g.Upstream(branch)
+	if err != nil {
+		return doctorCheck{"upstream", "warn", fmt.Sprintf("could not read %s's upstream: %v", branch, err)}
+	}
+	if upstream != "" {
+		return doctorCheck{"upstream", "ok", branch + " tracks " + upstream}
+	}
+	if _, err := g.Run("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err == nil {
+		if doctorFix {
+			if _, err := g.Run("branch", "--set-upstream-to=origin/"+branch, branch); err == nil {
+				return doctorCheck{"upstream", "ok", fmt.Sprintf("%s now tracks origin/%s", branch, branch)}
+			}
+		}
+		return doctorCheck{"upstream", "warn", fmt.Sprintf("%s doesn't track origin/%s\nRun 'sage doctor --fix' or %s",
+			branch, branch, ui.Blue(fmt.Sprintf("git branch --set-upstream-to=origin/%s", branch)))}
+	}
+	return doctorCheck{"upstream", "warn", branch + " has no upstream\nPush it with " + ui.Blue("sage push")}
+}
+
+// checkStaleBranches lists the local branches nobody has committed to for
+// a while
+func checkStaleBranches() doctorCheck {
+	g := git.NewShellGit()
+	days := doctorStaleDays
+	if days < 0 {
+		days = app.AbandonedDays()
+	}
+	base, err := app.BaseBranch(g, "")
+	if err != nil {
+		return doctorCheck{"branches", "ok", "skipped; no base branch to compare branches with"}
+	}
+	current, _ := g.CurrentBranch()
+	stale, err := app.StaleBranches(g, base, current, days)
+	if err != nil {
+		return doctorCheck{"branches", "warn", fmt.Sprintf("could not list branches: %v", err)}
+	}
+	if len(stale) == 0 {
+		return doctorCheck{"branches", "ok", fmt.Sprintf("no branches older than %d day%s", days, ui.Plural(days))}
+	}
+
+	var hints []string
+	for i, b := range stale {
+		if i == 5 {
+			hints = append(hints, ui.Gray(fmt.Sprintf("...and %d more", len(stale)-i)))
+			break
+		}
+		hints = append(hints, fmt.Sprintf("%s last commit %s, %d ahead of %s",
+			ui.White(b.Name), formatAge(time.Since(b.LastCommit)), b.Ahead, base))
+	}
+	hints = append(hints, "Delete merged and gone ones with "+ui.Blue("sage clean"))
+	return doctorCheck{"branches", "warn", fmt.Sprintf("%d branch%s untouched for more than %d day%s\n%s",
+		len(stale), pluralizeEs(len(stale)), days, ui.Plural(days), strings.Join(hints, "\n"))}
+}
+
+// checkLargeFiles looks for large files in commits that haven't been pushed,
+// while they can still be taken out of history
+func checkLargeFiles() doctorCheck {
+	g := git.NewShellGit()
+	blobs, err := app.UnpushedLargeFiles(g)
+	if err != nil {
+		return doctorCheck{"large files", "warn", fmt.Sprintf("could not look for large files: %v", err)}
+	}
+	if len(blobs) == 0 {
+		return doctorCheck{"large files", "ok", "no unpushed commit adds a file over " + formatSize(app.LargeFileSize)}
+	}
+
+	var hints []string
+	for _, b := range blobs {
+		path := b.Path
+		if path == "" {
+			path = b.Hash
+		}
+		hints = append(hints, fmt.Sprintf("%s %s", ui.White(path), ui.Gray(formatSize(b.Size))))
+	}
+	hints = append(hints, "Once pushed, they're in every clone for good. Take them out of the commits")
+	hints = append(hints, "(for example with "+ui.Blue("git rebase -i")+") or track them with Git LFS first")
+	return doctorCheck{"large files", "warn", fmt.Sprintf("%d large file%s in commits not pushed yet\n%s",
+		len(blobs), ui.Plural(len(blobs)), strings.Join(hints, "\n"))}
+}
+
+// checkHooks looks for hooks git would skip or fail to run, making the ones
+// that only lack the executable bit executable with --fix
+func checkHooks() doctorCheck {
+	broken, err := app.FindBrokenHooks(git.NewShellGit())
+	if err != nil {
+		return doctorCheck{"hooks", "warn", fmt.Sprintf("could not read the hooks: %v", err)}
+	}
+	if doctorFix {
+		var left []app.BrokenHook
+		for _, h := range broken {
+			if !h.Fixable || app.FixHook(h.Path) != nil {
+				left = append(left, h)
+			}
+		}
+		if fixed := len(broken) - len(left); fixed > 0 && len(left) == 0 {
+			return doctorCheck{"hooks", "ok", fmt.Sprintf("made %d hook%s executable", fixed, ui.Plural(fixed))}
+		}
+		broken = left
+	}
+	if len(broken) == 0 {
+		return doctorCheck{"hooks", "ok", "all hooks can run"}
+	}
+
+	var hints []string
+	fixable := 0
+	for _, h := range broken {
+		hints = append(hints, fmt.Sprintf("%s %s", ui.White(filepath.Base(h.Path)), h.Problem))
+		if h.Fixable {
+			fixable++
+		}
+	}
+	if fixable > 0 {
+		hints = append(hints, "Run 'sage doctor --fix' to make "+pluralThem(fixable)+" executable")
+	}
+	return doctorCheck{"hooks", "fail", fmt.Sprintf("%d hook%s won't run\n%s",
+		len(broken), ui.Plural(len(broken)), strings.Join(hints, "\n"))}
+}
+
+// checkGC checks whether loose objects or packs have piled up, running
+// git gc with --fix
+func checkGC() doctorCheck {
+	g := git.NewShellGit()
+	counts, err := g.CountObjects()
+	if err != nil {
+		return doctorCheck{"gc", "warn", fmt.Sprintf("could not count objects: %v", err)}
+	}
+	if !app.NeedsGC(counts) {
+		return doctorCheck{"gc", "ok", fmt.Sprintf("%d loose object%s, %d pack%s",
+			counts.Loose, ui.Plural(counts.Loose), counts.Packs, ui.Plural(counts.Packs))}
+	}
+	if doctorFix {
+		if _, err := g.Run("gc", "--quiet"); err == nil {
+			return doctorCheck{"gc", "ok", fmt.Sprintf("packed %d loose object%s and %d pack%s",
+				counts.Loose, ui.Plural(counts.Loose), counts.Packs, ui.Plural(counts.Packs))}
+		}
+	}
+	return doctorCheck{"gc", "warn", fmt.Sprintf("%d loose object%s (%s) and %d pack%s slow git down\nRun 'sage doctor --fix' or %s",
+		counts.Loose, ui.Plural(counts.Loose), formatSize(counts.LooseSize), counts.Packs, ui.Plural(counts.Packs), ui.Blue("git gc"))}
+}
+
+// checkEmail checks that commits are made with an address from a domain
+// the repository expects
+func checkEmail() doctorCheck {
+	check, err := app.CheckEmailDomain(git.NewShellGit())
+	switch {
+	case err != nil || check.Email == "":
+		return doctorCheck{"email", "warn", "user.email isn't set\nSet it with " + ui.Blue("git config user.email <address>")}
+	case check.Mismatch:
+		return doctorCheck{"email", "warn", fmt.Sprintf("committing as %s, but this repository expects @%s\nSet the address for this repository with %s",
+			check.Email, strings.Join(check.Expected, " or @"), ui.Blue("git config user.email <address>"))}
+	}
+	return doctorCheck{"email", "ok", "committing as " + check.Email}
+}
+
+// formatSize gives a size in bytes as KiB, MiB or GiB
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func checkGitHub() doctorCheck {
+	r := repoinfo.Default()
+	info, err := r.Repo()
+	if err != nil {
+		return doctorCheck{"github", "warn", "no GitHub remote found"}
+	}
+	token := r.TokenFor(info.Host, info.Owner)
+	if token.Err != nil {
+		return doctorCheck{"github", "fail", fmt.Sprintf("%s found but the %s gave no token: %v", info.FullName(), token.Source, token.Err)}
+	}
+	if token.Value == "" {
+		return doctorCheck{"github", "fail", fmt.Sprintf("%s found but no token; set SAGE_GITHUB_TOKEN or run 'gh auth login'", info.FullName())}
+	}
+	return doctorCheck{"github", "ok", fmt.Sprintf("%s (token from %s)", info.FullName(), token.Source)}
+}
+
+func checkInstall() doctorCheck {
+	v := version.Get()
+	if version.Version == "" {
+		return doctorCheck{"install", "warn", fmt.Sprintf("%s was built locally (go install or source), not downloaded from a release", v)}
+	}
+	if parsed, err := goversion.NewVersion(v); err != nil || parsed.Prerelease() != "" {
+		return doctorCheck{"install", "warn", fmt.Sprintf("development build %s; nothing to compare against", v)}
+	}
+
+	// The binary is compared whether or not cosign can check the signature
+	rel, err := update.FetchRelease(v, true)
+	if err != nil {
+		return doctorCheck{"install", "warn", fmt.Sprintf("could not fetch release %s checksums: %v", v, err)}
+	}
+	ok, err := update.VerifyInstalled(rel)
+	if err != nil {
+		return doctorCheck{"install", "warn", fmt.Sprintf("could not verify binary: %v", err)}
+	}
+	if !ok {
+		return doctorCheck{"install", "fail", fmt.Sprintf("binary does not match release %s; it may be modified or an unofficial build", v)}
+	}
+	detail := fmt.Sprintf("binary matches release %s", v)
+	if rel.Signed {
+		detail += " (signature verified)"
+	}
+	return doctorCheck{"install", "ok", detail}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorInstall, "verify-install", false, "Download this version's release and check the installed binary matches it")
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Make the safe repairs: stale locks, detached HEAD, missing upstream, hook permissions and gc")
+	doctorCmd.Flags().IntVar(&doctorStaleDays, "stale-days", -1, "Days after which a branch counts as stale (default: clean.abandoned_days or 30)")
+	doctorCmd.Flags().StringVar(&doctorFailOn, "fail-on", "fail", "Exit with code 7 on: fail, warn (warnings too) or never")
+}
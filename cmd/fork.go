@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forkUpstream string
+	forkRebase   bool
+	forkBranches []string
+	forkAll      bool
+	forkNoPush   bool
+)
+
+var forkCmd = &cobra.Command{
+	Use:   "fork",
+	Short: "Work with forks of upstream repositories",
+}
+
+var forkSyncCmd = &cobra.Command{
+	Use:         "sync",
+	Short:       "Update your fork's default branch from upstream",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Synchronize your fork with the repository it was forked from.
+
+This is the local equivalent of GitHub's "Sync fork" button. It:
+
+1. Fetches the upstream remote
+2. Fast-forwards your default branch to upstream's default branch
+3. Pushes the updated default branch to origin
+4. With --rebase, rebases the branch you were on onto it
+
+Name other branches with --branch, or use --all to rebase every branch
+that tracks a remote branch. Rebased branches with a remote copy are
+pushed with --force-with-lease, so commits pushed by someone else are
+never overwritten; any that could not be pushed are listed.
+
+Branches that fail to rebase cleanly are left untouched and reported,
+so you can update them with 'sage sync' and resolve conflicts there.`,
+	Example: `  # Update the default branch from upstream
+  sage fork sync
+
+  # Also rebase the branch you are on
+  sage fork sync --rebase
+
+  # Rebase every branch that tracks a remote branch
+  sage fork sync --rebase --all
+
+  # Rebase only specific branches
+  sage fork sync --rebase --branch feature/a --branch feature/b`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+
+		result, err := app.SyncFork(g, app.ForkSyncOptions{
+			Upstream: forkUpstream,
+			Rebase:   forkRebase,
+			Branches: forkBranches,
+			All:      forkAll,
+			NoPush:   forkNoPush,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.Updated {
+			fmt.Printf("%s Fast-forwarded '%s' to %s/%s\n", ui.Green("✓"), result.DefaultBranch, forkUpstream, result.DefaultBranch)
+		} else {
+			fmt.Printf("%s '%s' is already up to date with %s\n", ui.Green("✓"), result.DefaultBranch, forkUpstream)
+		}
+		if result.Pushed {
+			fmt.Printf("%s Pushed '%s' to origin\n", ui.Green("✓"), result.DefaultBranch)
+		}
+		for _, br := range result.Rebased {
+			fmt.Printf("%s Rebased '%s'\n", ui.Green("✓"), br)
+		}
+		for _, br := range result.PushedBranches {
+			fmt.Printf("%s Pushed '%s' to origin\n", ui.Green("✓"), br)
+		}
+		if len(result.Unpushed) > 0 {
+			fmt.Println(ui.Yellow("\nThese branches were rebased but their remote copies still have the old commits:"))
+			for _, br := range result.Unpushed {
+				fmt.Printf("  %s\n", br)
+			}
+			fmt.Printf("\nCheck each one and push it with '%s'.\n", ui.Blue("sage push --force"))
+		}
+		if len(result.Conflicted) > 0 {
+			fmt.Println(ui.Yellow("\nThese branches have conflicts and were left unchanged:"))
+			for _, br := range result.Conflicted {
+				fmt.Printf("  %s\n", br)
+			}
+			fmt.Printf("\nCheck each one out and run '%s' to resolve them.\n", ui.Blue("sage sync"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forkCmd)
+	forkCmd.AddCommand(forkSyncCmd)
+
+	forkSyncCmd.Flags().StringVar(&forkUpstream, "upstream", "upstream", "Name of the upstream remote")
+	forkSyncCmd.Flags().BoolVar(&forkRebase, "rebase", false, "Rebase feature branches onto the updated default branch")
+	forkSyncCmd.Flags().StringArrayVar(&forkBranches, "branch", nil, "Branch to rebase (repeatable, defaults to the current branch)")
+	forkSyncCmd.Flags().BoolVar(&forkAll, "all", false, "Rebase every branch that tracks a remote branch")
+	forkSyncCmd.Flags().BoolVar(&forkNoPush, "no-push", false, "Don't push the updated branches to origin")
+}
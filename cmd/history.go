@@ -2,26 +2,37 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 )
 
 var (
-	historyLimit int
-	showStats    bool
-	showAll      bool
+	historyLimit  int
+	showStats     bool
+	showAll       bool
+	historyCopy   bool
+	historyBrowse bool
+	historyOutput string
 )
 
 var historyCmd = &cobra.Command{
 	Use:   "history [branch]",
 	Short: "Show a beautiful commit history",
 	Long: `Displays a formatted log of commits on the current or specified branch.
-You can limit the number of commits, show stats, etc.`,
+You can limit the number of commits, show stats, etc.
+
+With --interactive, browse the history as a graph instead: type to search
+commit messages, authors and changed files, then pick a commit to check it
+out, revert it, cherry-pick it onto another branch, copy its SHA or open it
+on GitHub.`,
 	Args:    cobra.MaximumNArgs(1),
 	Aliases: []string{"hist", "log", "l"},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -31,10 +42,19 @@ You can limit the number of commits, show stats, etc.`,
 		if len(args) == 1 {
 			branch = args[0]
 		}
+		if historyBrowse {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				return exitcode.Errorf(exitcode.Usage, "--interactive needs a terminal")
+			}
+			return browseHistory(g, branch, showAll)
+		}
 		hist, err := app.GetHistory(g, branch, historyLimit, showStats, showAll)
 		if err != nil {
 			return err
 		}
+		if ok, err := printStructured(historyOutput, hist); ok || err != nil {
+			return err
+		}
 
 		fmt.Printf("\n%s %s\n", ui.Bold(ui.Sage("Branch History:")), ui.Yellow(hist.BranchName))
 		if len(hist.Commits) == 0 {
@@ -69,6 +89,10 @@ You can limit the number of commits, show stats, etc.`,
 			}
 		}
 
+		if historyCopy {
+			fmt.Println()
+			copyToClipboard(hist.Commits[0].Hash, "the hash of "+hist.Commits[0].ShortHash)
+		}
 		return nil
 	},
 }
@@ -78,4 +102,9 @@ func init() {
 	historyCmd.Flags().IntVarP(&historyLimit, "number", "n", 0, "Limit to last N commits")
 	historyCmd.Flags().BoolVarP(&showStats, "stats", "s", false, "Show file change statistics")
 	historyCmd.Flags().BoolVarP(&showAll, "all", "a", false, "Show all commits including merges from other branches")
+	historyCmd.Flags().BoolVar(&historyCopy, "copy", false, "Copy the newest commit's hash to the clipboard")
+	historyCmd.Flags().BoolVarP(&historyBrowse, "interactive", "i", false, "Browse and search the history as a graph, and act on a commit")
+	addOutputFlag(historyCmd, &historyOutput)
+	historyCmd.MarkFlagsMutuallyExclusive("output", "interactive")
+	historyCmd.MarkFlagsMutuallyExclusive("output", "copy")
 }
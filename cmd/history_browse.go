@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// historyPageSize is how many commits the browser reads at a time
+const historyPageSize = 100
+
+const loadMoreOption = "… load more commits"
+
+// browseHistory lets the user search the log of branch, drawn as a graph,
+// and act on a commit
+func browseHistory(g git.Service, branch string, all bool) error {
+	log, err := g.Log(git.LogOptions{Range: branch, All: all, Files: true})
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	var (
+		commits []git.Commit
+		options []string
+		search  []string
+		graph   app.HistoryGraph
+		more    = true
+	)
+	load := func() error {
+		page, err := log.Page(historyPageSize)
+		if err != nil {
+			return err
+		}
+		more = len(page) == historyPageSize
+		for _, c := range page {
+			commits = append(commits, c)
+			options = append(options, fmt.Sprintf("%s%s %s · %s, %s",
+				graph.Row(c), c.ShortHash(), c.Subject, c.Author, formatAge(time.Since(c.Date))))
+			search = append(search, app.CommitSearchText(c))
+		}
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		fmt.Println(ui.Green("No commits found."))
+		return nil
+	}
+
+	for {
+		choices := options
+		if more {
+			choices = append(choices[:len(choices):len(choices)], loadMoreOption)
+		}
+		filter := survey.WithFilter(func(query, _ string, i int) bool {
+			return i >= len(search) || app.FuzzyMatch(query, search[i])
+		})
+		choice, err := ui.AskSelectIndex("Pick a commit (type to search messages, authors and files):", choices,
+			"drop --interactive to print the history", survey.WithPageSize(20), filter)
+		if err != nil {
+			return err
+		}
+
+		if choice >= len(commits) {
+			if err := load(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		done, err := commitActions(g, commits[choice])
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// commitActions shows a commit and asks what to do with it. It reports
+// whether the browser should close.
+func commitActions(g git.Service, c git.Commit) (bool, error) {
+	fmt.Printf("\n%s %s\n", ui.Yellow(c.Hash), ui.Bold(c.Subject))
+	fmt.Printf("%s %s <%s>, %s\n", ui.Gray("Author:"), c.Author, c.Email, c.Date.Format("Mon Jan 02 2006 15:04"))
+	if c.Body != "" {
+		fmt.Printf("\n%s\n", c.Body)
+	}
+	for i, f := range c.Files {
+		if i == 10 {
+			fmt.Println(ui.Gray(fmt.Sprintf("  … and %d more", len(c.Files)-i)))
+			break
+		}
+		fmt.Printf("  %s\n", ui.White(f.Path))
+	}
+	fmt.Println()
+
+	const (
+		showDiff   = "Show the diff"
+		checkout   = "Check out (detached)"
+		revert     = "Revert on the current branch"
+		cherryPick = "Cherry-pick onto another branch"
+		copySHA    = "Copy SHA"
+		open       = "Open on GitHub"
+		back       = "Back to the list"
+	)
+	action, err := ui.AskSelect("What do you want to do?", []string{showDiff, checkout, revert, cherryPick, copySHA, open, back},
+		"", "drop --interactive to print the history")
+	if err != nil {
+		return false, err
+	}
+
+	switch action {
+	case showDiff:
+		diff, err := app.CommitDiff(g, c)
+		if err != nil {
+			return false, err
+		}
+		if err := ui.Page(ui.HighlightDiff(diff)); err != nil {
+			return false, err
+		}
+		return commitActions(g, c)
+	case checkout:
+		if err := app.CheckoutCommit(g, c.Hash); err != nil {
+			return false, err
+		}
+		fmt.Printf("%s Checked out %s\n", ui.Green("✓"), c.ShortHash())
+		fmt.Printf("\nTip: Run %s to start a branch from here\n\n", ui.Blue("sage start <name>"))
+	case revert:
+		if err := app.RevertCommit(g, c); err != nil {
+			return false, err
+		}
+		fmt.Printf("%s Reverted %s\n", ui.Green("✓"), c.ShortHash())
+	case cherryPick:
+		branch, err := pickBranch(g)
+		if err != nil {
+			return false, err
+		}
+		if err := app.CherryPickTo(g, c, branch); err != nil {
+			return false, err
+		}
+		fmt.Printf("%s Cherry-picked %s onto %s\n", ui.Green("✓"), c.ShortHash(), branch)
+	case copySHA:
+		copyToClipboard(c.Hash, "the hash of "+c.ShortHash())
+		fmt.Println()
+		return false, nil
+	case open:
+		url, err := app.CommitURL(repoinfo.Default(), c.Hash)
+		if err != nil {
+			return false, err
+		}
+		openInBrowser(url)
+		fmt.Println()
+		return false, nil
+	case back:
+		return false, nil
+	}
+	return true, nil
+}
+
+// pickBranch asks which local branch to use
+func pickBranch(g git.Service) (string, error) {
+	branches, err := g.ListBranches()
+	if err != nil {
+		return "", err
+	}
+	return ui.AskSelect("Onto which branch?", branches, "", "cherry-pick it with 'sage pick <sha>' instead", survey.WithPageSize(15))
+}
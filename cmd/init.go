@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initMessage string
+	initBranch  string
+	initGitHub  bool
+	initName    string
+	initPrivate bool
+)
+
+var initCmd = &cobra.Command{
+	Use:         "init",
+	Short:       "Set up a new repository with its first commit",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Make a brand-new repository ready for the rest of sage.
+
+This:
+1. Runs git init if the directory isn't a repository yet
+2. Names the default branch (--branch, git.default_branch, init.defaultBranch, or main)
+3. Creates the initial commit from the files in the working tree
+4. With --github, creates the repository on GitHub, adds it as origin and pushes
+
+Most sage commands need at least one commit, so run this straight after git init.`,
+	Example: `  # Commit what's here on the default branch
+  sage init
+
+  # Also publish to GitHub as a private repository
+  sage init --github --private
+
+  # Create it under an organisation with a custom first message
+  sage init --github --name my-org/my-repo -m "chore: scaffold project"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+
+		res, err := app.Bootstrap(g, app.BootstrapOptions{
+			Message:      initMessage,
+			Branch:       initBranch,
+			CreateGitHub: initGitHub,
+			RepoName:     initName,
+			Private:      initPrivate,
+		})
+		if res != nil {
+			if res.Initialized {
+				fmt.Printf("%s Initialised empty repository\n", ui.Green("✓"))
+			}
+			if res.Commit != "" {
+				files := "no files"
+				if res.Files > 0 {
+					files = fmt.Sprintf("%d file%s", res.Files, ui.Plural(res.Files))
+				}
+				fmt.Printf("%s Created initial commit %s on %s (%s)\n",
+					ui.Green("✓"), ui.Yellow(shortRef(res.Commit)), ui.Sage(res.Branch), files)
+			}
+			if res.Repository != nil {
+				fmt.Printf("%s Created %s\n", ui.Green("✓"), ui.Blue(res.Repository.HTMLURL))
+			}
+			if res.Pushed {
+				fmt.Printf("%s Pushed %s to origin\n", ui.Green("✓"), res.Branch)
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().StringVarP(&initMessage, "message", "m", "", "Initial commit message (default \"Initial commit\")")
+	initCmd.Flags().StringVarP(&initBranch, "branch", "b", "", "Name of the default branch")
+	initCmd.Flags().BoolVar(&initGitHub, "github", false, "Create the repository on GitHub and push to it")
+	initCmd.Flags().StringVar(&initName, "name", "", "GitHub repository name, or org/name (default: directory name)")
+	initCmd.Flags().BoolVar(&initPrivate, "private", false, "Make the GitHub repository private")
+}
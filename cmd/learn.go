@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var learnKeep bool
+
+// learnStep is one stage of the tutorial. Steps with a Command are run by the
+// learner in another terminal and then checked with Verify; steps with Run
+// are carried out by the tutorial itself.
+type learnStep struct {
+	Title   string
+	Explain string
+	Command string
+	Hint    string
+	Setup   func(*app.Sandbox) error
+	Verify  func(*app.Sandbox) error
+	Run     func(*app.Sandbox) error
+}
+
+var learnCmd = &cobra.Command{
+	Use:   "learn",
+	Short: "Learn sage's workflow in a sandbox repository",
+	Long: `Walk through the everyday sage workflow in a throwaway repository: start a
+branch, stage and commit a change, sync with a teammate's conflicting change,
+and open a pull request. Each step is checked before moving on.
+
+Nothing touches your own repositories or GitHub; the sandbox lives in a
+temporary directory and is removed at the end unless --keep is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sb, err := app.NewSandbox()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if learnKeep {
+				fmt.Printf("\nThe sandbox is still at %s\n", ui.White(sb.Root))
+				return
+			}
+			sb.Cleanup()
+		}()
+
+		fmt.Println(ui.Bold(ui.Sage("Welcome to sage!")))
+		fmt.Printf(`
+This tutorial made a small repository for you to practice in:
+
+  %s
+
+Open another terminal, go there, and run the commands each step asks for:
+
+  %s
+
+`, ui.White(sb.Repo), ui.Blue("cd "+sb.Repo))
+
+		steps := learnSteps(learnAIReady())
+		for i, step := range steps {
+			fmt.Printf("%s %s\n\n", ui.Sage(fmt.Sprintf("Step %d/%d:", i+1, len(steps))), ui.Bold(step.Title))
+			fmt.Println(step.Explain)
+
+			if step.Setup != nil {
+				if err := step.Setup(sb); err != nil {
+					return err
+				}
+			}
+			if step.Run != nil {
+				if err := step.Run(sb); err != nil {
+					return err
+				}
+				fmt.Println()
+				continue
+			}
+
+			fmt.Printf("\n  %s\n\n", ui.Blue(step.Command))
+			done, err := waitForStep(sb, step)
+			if err != nil {
+				return err
+			}
+			if !done {
+				fmt.Println(ui.Gray("See you next time. Run 'sage learn' to start again."))
+				return nil
+			}
+			fmt.Println()
+		}
+
+		fmt.Println(ui.Bold(ui.Sage("That's the core workflow!")))
+		fmt.Println(`From here, try 'sage status', 'sage history' and 'sage undo' in your own
+repositories, and 'sage --help' for everything else.`)
+		return nil
+	},
+}
+
+// waitForStep asks the learner to run the step's command and checks the
+// result, returning false if they chose to quit
+func waitForStep(sb *app.Sandbox, step learnStep) (bool, error) {
+	const (
+		done = "Done, check it"
+		hint = "Show a hint"
+		skip = "Skip this step"
+		quit = "Quit the tutorial"
+	)
+	for {
+		var choice string
+		prompt := &survey.Select{
+			Message: "Run the command above, then:",
+			Options: []string{done, hint, skip, quit},
+		}
+		if err := survey.AskOne(prompt, &choice); err != nil {
+			return false, err
+		}
+		switch choice {
+		case hint:
+			fmt.Println(ui.Gray(step.Hint))
+			continue
+		case skip:
+			return true, nil
+		case quit:
+			return false, nil
+		}
+		if err := step.Verify(sb); err != nil {
+			fmt.Printf("%s Not yet: %v\n", ui.Yellow("!"), err)
+			continue
+		}
+		fmt.Printf("%s Nice!\n", ui.Green("✓"))
+		return true, nil
+	}
+}
+
+// learnAIReady reports whether AI is set up, so the commit step can use it
+func learnAIReady() bool {
+	if !config.AIEnabled("") {
+		return false
+	}
+	return aiClient().Configured()
+}
+
+func learnSteps(useAI bool) []learnStep {
+	const branch = "learn/greeting"
+
+	commit := learnStep{
+		Title: "Commit it",
+		Explain: `'sage commit' stages and commits in one go. With -s it commits only what
+you staged. Since AI isn't set up, give the message yourself (set an API key
+with 'sage config set ai_api_key KEY' to have AI write them).`,
+		Command: `sage commit -s "docs: change the greeting"`,
+		Hint:    "Commit the staged README.md with -s and a message.",
+		Verify:  func(sb *app.Sandbox) error { return sb.VerifyCommitted("README.md") },
+	}
+	if useAI {
+		commit.Explain = `'sage commit' stages and commits in one go. With -s it commits only what
+you staged, and --ai writes the message from your diff; accept it or edit it.`
+		commit.Command = "sage commit -s --ai"
+	}
+
+	return []learnStep{
+		{
+			Title: "Start a branch",
+			Explain: `Work happens on branches. 'sage start' creates one from the latest main and
+switches to it.`,
+			Command: "sage start " + branch,
+			Hint:    "Run the command from inside the sandbox directory.",
+			Verify:  func(sb *app.Sandbox) error { return sb.VerifyBranch(branch) },
+		},
+		{
+			Title: "Change a file and stage it",
+			Explain: `Edit README.md in the sandbox and change the "Greeting: hello" line to any
+greeting you like. Then stage it; 'sage stage' without arguments lets you pick
+files from a list.`,
+			Command: "sage stage README.md",
+			Hint:    `Save README.md with a different greeting before staging it.`,
+			Verify:  func(sb *app.Sandbox) error { return sb.VerifyStaged("README.md") },
+		},
+		commit,
+		{
+			Title: "Sync with your team",
+			Explain: `Meanwhile, your teammate Sam changed the same greeting and pushed it to main.
+'sage sync' brings your branch up to date with main, and will stop at the
+conflict. Resolve it with 'sage resolve', which walks you through each
+conflicted file, then finish with 'sage sync --continue'.`,
+			Command: "sage sync",
+			Hint:    "After 'sage sync' stops, run 'sage resolve' and pick a version of the greeting, then 'sage sync --continue'.",
+			Setup:   func(sb *app.Sandbox) error { return sb.TeammatePushesConflict() },
+			Verify:  func(sb *app.Sandbox) error { return sb.VerifySynced() },
+		},
+		{
+			Title: "Open a pull request",
+			Explain: `Finally, 'sage pr create' pushes the branch and opens a pull request. The
+sandbox has no GitHub repository, so this step runs the same flow against a
+pretend GitHub.`,
+			Run: func(sb *app.Sandbox) error {
+				title := "Change the greeting"
+				if err := survey.AskOne(&survey.Input{Message: "Pull request title:", Default: title}, &title); err != nil {
+					return err
+				}
+				pr, err := sb.CreatePR(title, "Practice pull request from 'sage learn'.")
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s Created PR #%d: %s %s\n", ui.Green("✓"), pr.Number, pr.HTMLURL, ui.Gray("(pretend)"))
+				fmt.Println(ui.Gray("In a real repository, 'sage pr create --ai' can write the description too."))
+				return nil
+			},
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(learnCmd)
+	learnCmd.Flags().BoolVar(&learnKeep, "keep", false, "Keep the sandbox repository afterwards")
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// explainLocks follows up a failed git command: when lock files are in the
+// way, it says which and why, and offers to remove the ones no process holds
+func explainLocks(err error) {
+	var gitErr *git.Error
+	if !errors.As(err, &gitErr) {
+		return
+	}
+	g := git.NewShellGit()
+	locks, findErr := app.FindLocks(g)
+	if findErr != nil {
+		return
+	}
+	if len(locks) == 0 {
+		if errors.Is(err, git.ErrLocked) {
+			fmt.Fprintf(os.Stderr, "%s The lock git was waiting on is gone now; run the command again.\n", ui.Yellow("!"))
+		}
+		return
+	}
+	// Locks no process holds are only worth mentioning when they got in the way
+	stale := 0
+	for _, l := range locks {
+		if l.Stale {
+			stale++
+		}
+	}
+	if stale == 0 && !errors.Is(err, git.ErrLocked) {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	for _, l := range locks {
+		fmt.Fprintf(os.Stderr, "%s %s\n", ui.Yellow("!"), describeLock(l))
+	}
+	if stale == 0 {
+		fmt.Fprintln(os.Stderr, ui.Gray("  Wait for that git process to finish, or stop it, then run the command again."))
+		return
+	}
+
+	// Removing files is left to doctor --fix when nobody is there to answer
+	if batch.Enabled() || !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "  Run %s to remove %s.\n", ui.Blue("sage doctor --fix"), pluralThem(stale))
+		return
+	}
+	remove, err := ui.AskConfirm(fmt.Sprintf("Remove the stale lock%s?", ui.Plural(stale)), true)
+	if err != nil || !remove {
+		return
+	}
+	if removeStaleLocks(locks) == stale {
+		fmt.Fprintln(os.Stderr, "Run the command again.")
+	}
+}
+
+// describeLock says what a lock file is and whether it is in use
+func describeLock(l app.GitLock) string {
+	created := "created " + formatAge(l.Age)
+	switch {
+	case l.Stale:
+		return fmt.Sprintf("%s is left over from a git process that didn't finish (%s, nothing holds it)", l.Path, created)
+	case l.PID != 0:
+		return fmt.Sprintf("%s may be in use by git (pid %d, %s)", l.Path, l.PID, created)
+	default:
+		return fmt.Sprintf("%s was %s; another git command may still be using it", l.Path, created)
+	}
+}
+
+// removeStaleLocks removes the stale locks among locks and returns how many
+// went
+func removeStaleLocks(locks []app.GitLock) int {
+	removed := 0
+	for _, l := range locks {
+		if !l.Stale {
+			continue
+		}
+		if err := app.RemoveLock(l.Path); err != nil {
+			ui.Warnf("%v\n", err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s Removed %s\n", ui.Green("✓"), l.Path)
+		removed++
+	}
+	return removed
+}
+
+func pluralThem(n int) string {
+	if n == 1 {
+		return "it"
+	}
+	return "them"
+}
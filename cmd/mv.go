@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:         "mv <source> <destination>",
+	Short:       "Rename a file, including case-only and unicode renames",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Rename a tracked file like git mv. Renames that only change case
+(Foo.go -> foo.go) or unicode normalization go through a temporary name,
+so they work on case-insensitive filesystems such as macOS's.
+
+If the file was already renamed outside git, the rename is staged instead.`,
+	Example: `  sage mv Readme.md README.md`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		src, dst := args[0], args[1]
+		if err := app.MovePath(g, src, dst); err != nil {
+			return err
+		}
+		fmt.Printf("%s Renamed %s to %s\n", ui.Green("✓"), src, dst)
+		if msg := app.IgnoreCaseMismatch(g); msg != "" && app.IsCaseOrFormRename(src, dst) {
+			fmt.Printf("%s %s\n", ui.Yellow("⚠"), msg)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/notify"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// notifyDone notifies when this run finishes, however long it took
+var notifyDone bool
+
+// longCommands are the commands that wait on the network and can take
+// minutes; with ui.notify set, they notify once they run past
+// ui.notify_after. Others only notify with --notify, since time spent at
+// their prompts isn't worth one.
+var longCommands = map[string]bool{
+	"sync":         true,
+	"push":         true,
+	"fork sync":    true,
+	"stack sync":   true,
+	"stack submit": true,
+	"stack merge":  true,
+	"pr merge":     true,
+	"ci why":       true,
+	"deploy":       true,
+	"release":      true,
+	"update":       true,
+}
+
+// defaultNotifyAfter is how long a command runs before it notifies
+const defaultNotifyAfter = 30 * time.Second
+
+// pendingNotify is the command running and when it started
+var pendingNotify struct {
+	name  string
+	start time.Time
+}
+
+// beginNotify notes the command starting, for finishNotify
+func beginNotify(cmd *cobra.Command) {
+	pendingNotify.name = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	pendingNotify.start = time.Now()
+}
+
+// finishNotify rings the bell or shows a desktop notification, as
+// ui.notify says, when the command started with beginNotify ran long or
+// --notify asked for it
+func finishNotify(runErr error) {
+	name := pendingNotify.name
+	if name == "" || dryrun.Enabled() {
+		return
+	}
+	pendingNotify.name = ""
+	elapsed := time.Since(pendingNotify.start)
+
+	mode, err := notify.ParseMode(config.Get("ui.notify", true))
+	if err != nil {
+		ui.Warnf("%v\n", err)
+		return
+	}
+	if notifyDone {
+		if mode == notify.Off {
+			mode = notify.All
+		}
+	} else if mode == notify.Off || !longCommands[name] || elapsed < notifyAfter() {
+		return
+	}
+
+	title := "sage " + name
+	message := "Finished in " + elapsed.Round(time.Second).String()
+	if runErr != nil {
+		title += " failed"
+		message = strings.SplitN(runErr.Error(), "\n", 2)[0]
+	}
+	var bell io.Writer = io.Discard
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		bell = os.Stderr
+	}
+	if err := notify.Notify(mode, bell, title, message); err != nil {
+		ui.Warnf("Failed to notify: %v\n", err)
+	}
+}
+
+// notifyAfter reads ui.notify_after
+func notifyAfter() time.Duration {
+	v := config.Get("ui.notify_after", true)
+	if v == "" {
+		return defaultNotifyAfter
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ui.Warnf("Invalid ui.notify_after %q, using %s\n", v, defaultNotifyAfter)
+		return defaultNotifyAfter
+	}
+	return d
+}
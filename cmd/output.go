@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+// outputAnnotation marks the --output flags that pick a format, as opposed
+// to commands whose --output names a file
+const outputAnnotation = "sage-output-format"
+
+// addOutputFlag gives a read command --output text|json|yaml
+func addOutputFlag(c *cobra.Command, format *string) {
+	c.Flags().StringVarP(format, "output", "o", "text", "Output format: text, json or yaml")
+	_ = c.Flags().SetAnnotation("output", outputAnnotation, []string{"true"})
+	_ = c.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"text", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// structuredOutput reports whether cmd was asked for JSON or YAML, which
+// nothing else may be printed around
+func structuredOutput(cmd *cobra.Command) bool {
+	if f := cmd.Flags().Lookup("json"); f != nil && f.Value.String() == "true" {
+		return true
+	}
+	f := cmd.Flags().Lookup("output")
+	if f == nil || f.Annotations[outputAnnotation] == nil {
+		return false
+	}
+	return f.Value.String() == "json" || f.Value.String() == "yaml"
+}
+
+// printStructured writes v as JSON or YAML when format asks for it and
+// reports whether it did; "text" leaves the printing to the caller
+func printStructured(format string, v any) (bool, error) {
+	switch format {
+	case "", "text":
+		return false, nil
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return true, enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		defer enc.Close()
+		return true, enc.Encode(v)
+	default:
+		return false, exitcode.Errorf(exitcode.Usage, "unknown output format %q (want text, json or yaml)", format)
+	}
+}
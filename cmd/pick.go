@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	pickContinue bool
+	pickAbort    bool
+)
+
+var pickCmd = &cobra.Command{
+	Use:         "pick <commit>...",
+	Short:       "Cherry-pick commits onto the current branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Apply the changes of one or more commits onto the current branch, each as
+a new commit. Ranges such as main~3..main pick every commit in them, oldest
+first; merges are picked against their first parent.
+
+Uncommitted changes are stashed first and restored afterwards, and the undo
+journal gets a snapshot, so 'sage undo -i' can take the whole pick back.
+When a commit conflicts, the pick stops: resolve the files, then run
+'sage pick --continue', or 'sage pick --abort' to put the branch back.`,
+	Example: `  sage pick 1a2b3c4
+  sage pick feature~2..feature
+  sage pick --continue`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPick(cmd, args, app.PickCommits)
+	},
+}
+
+var revertCmd = &cobra.Command{
+	Use:         "revert <commit>...",
+	Short:       "Undo commits with new commits that reverse them",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Commit the inverse of one or more commits on the current branch, newest
+first. Ranges work as they do for 'sage pick', and uncommitted changes are
+stashed and restored the same way. When a revert conflicts, resolve the
+files and run 'sage revert --continue', or 'sage revert --abort'.`,
+	Example: `  sage revert 1a2b3c4
+  sage revert HEAD~3..HEAD`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPick(cmd, args, app.RevertCommits)
+	},
+}
+
+// runPick starts, continues or aborts a cherry-pick or revert
+func runPick(cmd *cobra.Command, args []string, start func(git.Service, []string) (*app.PickResult, error)) error {
+	g := git.NewShellGit()
+	switch {
+	case pickContinue || pickAbort:
+		if len(args) > 0 {
+			return exitcode.Errorf(exitcode.Usage, "--continue and --abort take no commits")
+		}
+		if pickAbort {
+			op, err := app.AbortPick(g)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s Aborted the %s\n", ui.Green("✓"), op)
+			return nil
+		}
+		op, err := app.ContinuePick(g)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Finished the %s\n", ui.Green("✓"), op)
+		return nil
+	case len(args) == 0:
+		return exitcode.Errorf(exitcode.Usage, "%s needs at least one commit", cmd.CommandPath())
+	}
+
+	res, err := start(g, args)
+	if err != nil {
+		return err
+	}
+	verb := "Picked"
+	if res.Op == git.OpRevert {
+		verb = "Reverted"
+	}
+	branch, _ := g.CurrentBranch()
+	fmt.Printf("%s %s %d commit%s on %s\n", ui.Green("✓"), verb, len(res.Commits), ui.Plural(len(res.Commits)), ui.Blue(branch))
+	if out, err := g.Run("log", "--format=%h %s", "-n", strconv.Itoa(len(res.Commits))); err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			hash, subject, _ := strings.Cut(line, " ")
+			fmt.Printf("  %s %s\n", ui.Yellow(hash), subject)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(pickCmd, revertCmd)
+	for _, c := range []*cobra.Command{pickCmd, revertCmd} {
+		c.Flags().BoolVar(&pickContinue, "continue", false, "Carry on once the conflicts are resolved")
+		c.Flags().BoolVar(&pickAbort, "abort", false, "Stop and put the branch back where it was")
+		c.MarkFlagsMutuallyExclusive("continue", "abort")
+	}
+}
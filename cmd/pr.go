@@ -1,12 +1,26 @@
 package cmd
 
 import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/forge"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+// prRepo names the repository PR commands operate on instead of the local checkout
+var prRepo string
+
+// prRepoAnnotation marks what a PR command needs that --repo can't provide:
+// "checkout" for commands that work on the local repository, "number" for
+// commands that otherwise find the PR from the current branch
+const prRepoAnnotation = "sage/pr-repo"
+
 var prCmd = &cobra.Command{
-	Use:   "pr",
-	Short: "Manage pull requests",
+	Use:         "pr",
+	Short:       "Manage pull requests",
+	Annotations: map[string]string{prRepoAnnotation: "number"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If --help is provided, show help
 		if cmd.Flags().Changed("help") {
@@ -17,6 +31,33 @@ var prCmd = &cobra.Command{
 	},
 }
 
+// applyPRRepo points the GitHub client at --repo, if given, and rejects
+// uses that need the local checkout
+func applyPRRepo(cmd *cobra.Command, args []string) error {
+	flag := cmd.Flags().Lookup("repo")
+	if flag == nil || !flag.Changed {
+		return nil
+	}
+	if forge.Type() != forge.GitHub {
+		return fmt.Errorf("--repo only works with GitHub repositories")
+	}
+	if err := repoinfo.Default().Override(prRepo); err != nil {
+		return err
+	}
+
+	switch cmd.Annotations[prRepoAnnotation] {
+	case "checkout":
+		return fmt.Errorf("'%s' needs a local checkout and can't be used with --repo", cmd.CommandPath())
+	case "number":
+		if len(args) == 0 {
+			return fmt.Errorf("a PR number is required with --repo, e.g. %s 42 --repo %s", cmd.CommandPath(), prRepo)
+		}
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(prCmd)
+	prCmd.PersistentFlags().BoolVar(&ui.RawMarkdown, "raw", false, "Print PR descriptions as raw markdown")
+	prCmd.PersistentFlags().StringVar(&prRepo, "repo", "", "Work on owner/name (or host/owner/name) through the GitHub API instead of the local repository")
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var prApproveCmd = &cobra.Command{
+	Use:         "approve [pr-num]",
+	Short:       "Approve a PR",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Approve a pull request.
+If no PR number is provided, approves the PR for the current branch.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+
+		var num int
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid PR number: %v", err)
+			}
+			num = n
+		} else {
+			branch, err := git.NewShellGit().CurrentBranch()
+			if err != nil {
+				return err
+			}
+			pr, err := ghc.GetPRForBranch(branch)
+			if err != nil {
+				return err
+			}
+			if pr == nil {
+				return exitcode.Errorf(exitcode.NotFound, "no open PR found for branch '%s'; give the PR number: sage pr approve <number>", branch)
+			}
+			num = pr.Number
+		}
+
+		if err := app.ApprovePR(ghc, num); err != nil {
+			return fmt.Errorf("failed to approve PR #%d: %w", num, err)
+		}
+		fmt.Printf("%s Approved PR #%d\n", ui.Green("✓"), num)
+		return nil
+	},
+}
+
+func init() {
+	prCmd.AddCommand(prApproveCmd)
+}
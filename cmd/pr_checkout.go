@@ -5,23 +5,23 @@ import (
 	"strconv"
 
 	"github.com/crazywolf132/sage/internal/app"
-	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var prCheckoutCmd = &cobra.Command{
-	Use:   "checkout <pr-num>",
-	Short: "Check out PR locally",
-	Args:  cobra.ExactArgs(1),
+	Use:         "checkout <pr-num>",
+	Short:       "Check out PR locally",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "checkout"},
+	Args:        cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		num, err := strconv.Atoi(args[0])
 		if err != nil {
 			return err
 		}
 		g := git.NewShellGit()
-		ghc := gh.NewClient()
+		ghc := forgeClient()
 		branch, err := app.CheckoutPR(g, ghc, num)
 		if err != nil {
 			return err
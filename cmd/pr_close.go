@@ -6,20 +6,21 @@ import (
 	"strings"
 
 	"github.com/crazywolf132/sage/internal/app"
-	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var prCloseCmd = &cobra.Command{
-	Use:   "close [pr-num]",
-	Short: "Close a PR without merging",
+	Use:         "close [pr-num]",
+	Aliases:     []string{"decline"},
+	Short:       "Close a PR without merging",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
 	Long: `Close a pull request without merging it.
 If no PR number is provided, attempts to close the PR for the current branch.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ghc := gh.NewClient()
 		g := git.NewShellGit()
 
 		var num int
@@ -44,7 +45,7 @@ If no PR number is provided, attempts to close the PR for the current branch.`,
 				return fmt.Errorf("You're on the %s branch which typically doesn't have a PR.\nTo close a specific PR, provide its number: sage pr close <number>", defaultBranch)
 			}
 
-			pr, err := ghc.GetPRForBranch(branch)
+			pr, err := forgeClient().GetPRForBranch(branch)
 			if err != nil {
 				if strings.Contains(err.Error(), "Bad credentials") || strings.Contains(err.Error(), "401") {
 					return fmt.Errorf("GitHub authentication failed.\n\nTo authenticate, either:\n1. Login with GitHub CLI: gh auth login\n2. Create a token: https://github.com/settings/tokens/new?scopes=repo&description=sage\n3. Set the token:\n   - Run: sage config set github_token YOUR_TOKEN\n   - Or set GITHUB_TOKEN environment variable")
@@ -52,12 +53,13 @@ If no PR number is provided, attempts to close the PR for the current branch.`,
 				return err
 			}
 			if pr == nil {
-				return fmt.Errorf("No open PR found for branch '%s'.\n\nTo create a PR: sage pr create\nTo close a specific PR: sage pr close <number>\nTo list open PRs: sage pr list", branch)
+				return exitcode.Errorf(exitcode.NotFound, "No open PR found for branch '%s'.\n\nTo create a PR: sage pr create\nTo close a specific PR: sage pr close <number>\nTo list open PRs: sage pr list", branch)
 			}
 			num = pr.Number
 		}
 
 		// Get PR details to check status
+		ghc := forgeClient()
 		pr, err := ghc.GetPRDetails(num)
 		if err != nil {
 			if strings.Contains(err.Error(), "Bad credentials") || strings.Contains(err.Error(), "401") {
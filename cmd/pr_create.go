@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/forge"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
@@ -20,26 +29,76 @@ var (
 	prLabels    []string
 	useTUI      bool
 	prUseAI     bool
+	prAllowWip  bool
+	prCopy      bool
+	prNoReopen  bool
 )
 
 // prCreateCmd is "sage pr create"
 var prCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create a new PR on GitHub (interactive if flags not provided)",
+	Use:         "create",
+	Short:       "Create a new PR on GitHub (interactive if flags not provided)",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "checkout"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
-		ghc := gh.NewClient()
+		ghc := forgeClient()
+
+		// Fill unset flags from config, which may be overridden for this branch
+		if !cmd.Flags().Changed("draft") {
+			prDraft = config.Get("pr.draft", true) == "true"
+		}
+		if len(prReviewers) == 0 {
+			prReviewers = splitConfigList(config.Get("pr.reviewers", true))
+		}
+		if len(prLabels) == 0 {
+			prLabels = splitConfigList(config.Get("pr.labels", true))
+		}
+
+		// Team rules for this kind of branch are added when the PR is created,
+		// and take the place of AI label and history reviewer suggestions
+		var branchDefaults app.PRDefaults
+		if branch, err := g.CurrentBranch(); err == nil {
+			if branchDefaults, _, err = app.BranchPRDefaults(branch); err != nil {
+				return err
+			}
+
+			// Offer to bring back a PR closed without merging rather than
+			// opening a duplicate of it. Bitbucket can't reopen declined PRs.
+			if !prNoReopen && forge.Type() != forge.Bitbucket && !batch.Enabled() && term.IsTerminal(int(os.Stdin.Fd())) {
+				if closed, _ := app.ClosedPRForBranch(ghc, branch); closed != nil {
+					reopened, err := offerReopen(g, ghc, closed)
+					if err != nil || reopened {
+						return err
+					}
+				}
+			}
+		}
+
+		// PRs go to the trunk unless --base says otherwise
+		if prBase == "" {
+			base, err := app.BaseBranch(g, "")
+			if errors.Is(err, app.ErrBaseMissing) {
+				return err
+			}
+			if err == nil {
+				prBase = base
+			}
+		}
+
+		if prUseAI && !config.AIEnabled("") {
+			return fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+		}
 
 		// If AI flag is set, generate PR content first
 		if prUseAI {
-			aiForm, err := ui.GenerateAIPRContent(g, ghc)
+			aiForm, err := ui.GenerateAIPRContent(g, ghc, prBase, aiClient())
 			if err != nil {
 				return fmt.Errorf("failed to generate AI content: %w", err)
 			}
 			prTitle = aiForm.Title
 			prBody = aiForm.Body
 			// Don't override other flags if they were explicitly set
-			if len(prLabels) == 0 {
+			if len(prLabels) == 0 && len(branchDefaults.Labels) == 0 {
 				prLabels = aiForm.Labels
 			}
 			if len(prReviewers) == 0 {
@@ -47,8 +106,19 @@ var prCreateCmd = &cobra.Command{
 			}
 		}
 
+		// Without CODEOWNERS, suggest the people who know the changed files best
+		if len(prReviewers) == 0 && len(branchDefaults.Reviewers) == 0 &&
+			config.Get("pr.suggest_reviewers", true) != "false" && !app.HasCodeOwners(g) {
+			prReviewers = suggestReviewers(g, ghc, prBase)
+		}
+
+		// Without prompts only the title is required; the body may stay empty
+		if batch.Enabled() && prTitle == "" {
+			return batch.NeedsInput("sage pr create needs a title", "pass --title, or --ai to generate one")
+		}
+
 		// Show interactive form if required fields are missing
-		if prTitle == "" || prBody == "" {
+		if !batch.Enabled() && (prTitle == "" || prBody == "") {
 			form, err := ui.AskPRForm(ui.PRForm{
 				Title:     prTitle,
 				Body:      prBody,
@@ -76,6 +146,8 @@ var prCreateCmd = &cobra.Command{
 			Draft:     prDraft,
 			Labels:    prLabels,
 			Reviewers: prReviewers,
+			AllowWip:  prAllowWip,
+			Tracker:   issueTracker(),
 		}
 		pr, err := app.CreatePullRequest(g, ghc, opts)
 		if err != nil {
@@ -83,10 +155,70 @@ var prCreateCmd = &cobra.Command{
 		}
 
 		fmt.Printf("%s Created PR #%d: %s\n", ui.Green("✓"), pr.Number, pr.HTMLURL)
+		if prCopy {
+			copyToClipboard(pr.HTMLURL, "the PR URL")
+		}
 		return nil
 	},
 }
 
+// offerReopen asks whether to reopen pr, the branch's closed PR, and does
+// so if the answer is yes
+func offerReopen(g git.Service, ghc gh.Client, pr *gh.PullRequest) (bool, error) {
+	fmt.Printf("%s PR #%d %q from this branch was closed without merging\n", ui.Yellow("!"), pr.Number, pr.Title)
+	var reopen bool
+	if err := survey.AskOne(&survey.Confirm{Message: "Reopen it instead of creating a new PR?", Default: true}, &reopen); err != nil {
+		return false, err
+	}
+	if !reopen {
+		return false, nil
+	}
+	if err := app.ReopenBranchPR(g, ghc, pr); err != nil {
+		return false, fmt.Errorf("failed to reopen PR #%d: %w", pr.Number, err)
+	}
+	fmt.Printf("%s Reopened PR #%d: %s\n", ui.Green("✓"), pr.Number, pr.HTMLURL)
+	if prCopy {
+		copyToClipboard(pr.HTMLURL, "the PR URL")
+	}
+	return true, nil
+}
+
+// suggestReviewers prints reviewers suggested from the history of the changed
+// files and returns their logins
+func suggestReviewers(g git.Service, ghc gh.Client, base string) []string {
+	if base == "" {
+		if def, err := g.DefaultBranch(); err == nil {
+			base = def
+		} else {
+			base = "main"
+		}
+	}
+	suggestions, err := app.SuggestReviewers(g, ghc, "origin/"+base, 3)
+	if err != nil || len(suggestions) == 0 {
+		return nil
+	}
+
+	fmt.Println(ui.Sage("Suggested reviewers, from the history of the changed files:"))
+	var logins []string
+	for _, s := range suggestions {
+		fmt.Printf("  %s %s\n", ui.Bold(s.Login), ui.Gray(fmt.Sprintf("(%d commit%s on %d file%s, last %s)",
+			s.Commits, ui.Plural(s.Commits), s.Files, ui.Plural(s.Files), formatAge(time.Since(s.LastTouched)))))
+		logins = append(logins, s.Login)
+	}
+	return logins
+}
+
+// splitConfigList parses a comma-separated config value
+func splitConfigList(value string) []string {
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func init() {
 	prCmd.AddCommand(prCreateCmd)
 
@@ -97,4 +229,7 @@ func init() {
 	prCreateCmd.Flags().StringSliceVar(&prReviewers, "reviewer", nil, "Add one or more reviewers")
 	prCreateCmd.Flags().StringSliceVar(&prLabels, "label", nil, "Add one or more labels")
 	prCreateCmd.Flags().BoolVarP(&prUseAI, "ai", "a", false, "Use AI to generate PR content")
+	prCreateCmd.Flags().BoolVar(&prAllowWip, "allow-wip", false, "Allow wip checkpoint commits in the PR")
+	prCreateCmd.Flags().BoolVar(&prCopy, "copy", false, "Copy the new PR's URL to the clipboard")
+	prCreateCmd.Flags().BoolVar(&prNoReopen, "no-reopen", false, "Don't offer to reopen a closed PR from this branch")
 }
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	prDiffStat    bool
+	prDiffFile    string
+	prDiffNoPager bool
+)
+
+var prDiffCmd = &cobra.Command{
+	Use:         "diff [pr-num]",
+	Short:       "Browse the files changed by a pull request",
+	Annotations: map[string]string{prRepoAnnotation: "number"},
+	Long: `Show a tree of the files a pull request changes, with added and removed
+line counts, and pick files from it to read their diff.
+
+With --stat only the tree is printed. With --file, or when output isn't a
+terminal, diffs are printed directly.`,
+	Example: `  sage pr diff 42
+  sage pr diff 42 --stat
+  sage pr diff 42 --file internal/app/sync.go`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+		g := git.NewShellGit()
+
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
+		files, err := ghc.ListPRFiles(num)
+		if err != nil {
+			return fmt.Errorf("failed to list files of PR #%d: %w", num, err)
+		}
+		if len(files) == 0 {
+			fmt.Println(ui.Gray("This pull request doesn't change any files."))
+			return nil
+		}
+		rows := app.BuildFileTree(files)
+
+		if prDiffFile != "" {
+			for _, f := range files {
+				if f.Filename == prDiffFile {
+					return showPatch(f)
+				}
+			}
+			return fmt.Errorf("PR #%d doesn't change %s", num, prDiffFile)
+		}
+
+		if prDiffStat {
+			fmt.Print(renderFileTree(num, files, rows))
+			return nil
+		}
+
+		interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+		if !interactive {
+			fmt.Print(renderFileTree(num, files, rows))
+			for _, f := range files {
+				fmt.Println()
+				fmt.Print(ui.HighlightDiff(app.FilePatch(f)))
+			}
+			return nil
+		}
+		return browseFiles(num, files, rows)
+	},
+}
+
+// renderFileTree prints the changed files as a tree with per-row counts
+func renderFileTree(num int, files []gh.PRFile, rows []app.FileTreeRow) string {
+	var b strings.Builder
+	adds, dels := 0, 0
+	for _, f := range files {
+		adds += f.Additions
+		dels += f.Deletions
+	}
+	fmt.Fprintf(&b, "%s #%d: %d file%s, %s %s\n", ui.Sage("Pull Request"), num, len(files), ui.Plural(len(files)),
+		ui.Green(fmt.Sprintf("+%d", adds)), ui.Red(fmt.Sprintf("-%d", dels)))
+	for _, r := range rows {
+		name := r.Name
+		if r.File == nil {
+			name = ui.Blue(name)
+		}
+		fmt.Fprintf(&b, "%s %s%s\n", diffstatBar(r.Additions, r.Deletions), ui.Gray(r.Prefix), name)
+	}
+	return b.String()
+}
+
+// browseFiles lets the user pick files from the tree and pages their diffs
+func browseFiles(num int, files []gh.PRFile, rows []app.FileTreeRow) error {
+	const done = "Done"
+	fmt.Print(renderFileTree(num, files, rows))
+
+	var options []string
+	byOption := map[string]gh.PRFile{}
+	for _, r := range rows {
+		if r.File == nil {
+			continue
+		}
+		opt := fmt.Sprintf("%s  %s", r.File.Filename, ui.Gray(fmt.Sprintf("+%d -%d", r.Additions, r.Deletions)))
+		options = append(options, opt)
+		byOption[opt] = *r.File
+	}
+	options = append(options, done)
+
+	for {
+		var choice string
+		if err := survey.AskOne(&survey.Select{
+			Message:  "Show the diff of:",
+			Options:  options,
+			PageSize: 15,
+		}, &choice); err != nil {
+			return err
+		}
+		if choice == done {
+			return nil
+		}
+		if err := showPatch(byOption[choice]); err != nil {
+			return err
+		}
+	}
+}
+
+func showPatch(f gh.PRFile) error {
+	out := ui.HighlightDiff(app.FilePatch(f))
+	if prDiffNoPager {
+		fmt.Print(out)
+		return nil
+	}
+	return ui.Page(out)
+}
+
+func init() {
+	prCmd.AddCommand(prDiffCmd)
+	prDiffCmd.Flags().BoolVar(&prDiffStat, "stat", false, "Only show the tree of changed files with line counts")
+	prDiffCmd.Flags().StringVar(&prDiffFile, "file", "", "Show the diff of one file")
+	prDiffCmd.Flags().BoolVar(&prDiffNoPager, "no-pager", false, "Print diffs directly instead of using a pager")
+}
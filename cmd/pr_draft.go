@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var prReadyCmd = &cobra.Command{
+	Use:         "ready [pr-num]",
+	Short:       "Mark a draft PR as ready for review",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Mark a draft pull request as ready for review, which notifies its reviewers.
+If no PR number is provided, uses the current branch's PR.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPRDraft(args, false)
+	},
+}
+
+var prDraftCmd = &cobra.Command{
+	Use:         "draft [pr-num]",
+	Short:       "Convert a PR back to a draft",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Convert a pull request back to a draft, so it can't be merged and reviewers
+aren't asked for a review until it is marked ready again with 'sage pr ready'.
+If no PR number is provided, uses the current branch's PR.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPRDraft(args, true)
+	},
+}
+
+// setPRDraft moves the PR named by args, or the current branch's, to or
+// from draft
+func setPRDraft(args []string, draft bool) error {
+	ghc := forgeClient()
+	num, err := resolvePRNumber(git.NewShellGit(), ghc, args)
+	if err != nil {
+		return err
+	}
+
+	changed, err := app.SetPRDraft(ghc, num, draft)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !changed && draft:
+		fmt.Printf("PR #%d is already a draft\n", num)
+	case !changed:
+		fmt.Printf("PR #%d is already ready for review\n", num)
+	case draft:
+		fmt.Printf("%s Converted PR #%d to a draft\n", ui.Green("✓"), num)
+	default:
+		fmt.Printf("%s Marked PR #%d as ready for review\n", ui.Green("✓"), num)
+	}
+	return nil
+}
+
+func init() {
+	prCmd.AddCommand(prReadyCmd)
+	prCmd.AddCommand(prDraftCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	editTitle           string
+	editBody            string
+	editBase            string
+	editAddLabels       []string
+	editRemoveLabels    []string
+	editAddReviewers    []string
+	editRemoveReviewers []string
+)
+
+var prEditCmd = &cobra.Command{
+	Use:         "edit [pr-num]",
+	Short:       "Edit a PR's title, description, base, labels or reviewers",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Edit a pull request. If no PR number is provided, uses the current branch's PR.
+
+Without any flags the title and description open in your editor: the first
+line is the title and the rest the description. Labels and reviewers can be
+added and removed; reviewers given as org/team are teams.`,
+	Example: `  sage pr edit
+  sage pr edit 42 --title "Fix login redirect"
+  sage pr edit --add-label bug --remove-label needs-triage
+  sage pr edit --add-reviewer alice --add-reviewer my-org/frontend --remove-reviewer bob
+  sage pr edit --base develop`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+		g := git.NewShellGit()
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
+
+		edit := app.PREdit{
+			Base:            editBase,
+			AddLabels:       editAddLabels,
+			RemoveLabels:    editRemoveLabels,
+			AddReviewers:    editAddReviewers,
+			RemoveReviewers: editRemoveReviewers,
+		}
+		if cmd.Flags().Changed("title") {
+			edit.Title = &editTitle
+		}
+		if cmd.Flags().Changed("body") {
+			edit.Body = &editBody
+		}
+
+		if edit.Empty() {
+			if batch.Enabled() || !term.IsTerminal(int(os.Stdin.Fd())) {
+				return fmt.Errorf("nothing to change; pass --title, --body, --base, or a label or reviewer flag")
+			}
+			pr, err := ghc.GetPRDetails(num)
+			if err != nil {
+				return err
+			}
+			title, body, err := app.ComposePRInEditor(app.ResolveEditor(g), pr.Title, pr.Body)
+			if err != nil {
+				return err
+			}
+			edit.Title, edit.Body = &title, &body
+		}
+
+		if err := app.EditPR(ghc, num, edit); err != nil {
+			return err
+		}
+		fmt.Printf("%s Updated PR #%d\n", ui.Green("✓"), num)
+		return nil
+	},
+}
+
+func init() {
+	prCmd.AddCommand(prEditCmd)
+
+	prEditCmd.Flags().StringVarP(&editTitle, "title", "t", "", "Set the PR's title")
+	prEditCmd.Flags().StringVarP(&editBody, "body", "b", "", "Set the PR's description")
+	prEditCmd.Flags().StringVar(&editBase, "base", "", "Change the branch the PR merges into")
+	prEditCmd.Flags().StringSliceVar(&editAddLabels, "add-label", nil, "Add labels")
+	prEditCmd.Flags().StringSliceVar(&editRemoveLabels, "remove-label", nil, "Remove labels")
+	prEditCmd.Flags().StringSliceVar(&editAddReviewers, "add-reviewer", nil, "Request reviews from users or org/team")
+	prEditCmd.Flags().StringSliceVar(&editRemoveReviewers, "remove-reviewer", nil, "Withdraw review requests")
+}
@@ -4,18 +4,30 @@ import (
 	"fmt"
 
 	"github.com/crazywolf132/sage/internal/app"
-	"github.com/crazywolf132/sage/internal/gh"
-	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var listState string
+var (
+	listState  string
+	listJSON   bool
+	listClosed bool
+	listMine   bool
+	listOutput string
+)
 
 var prListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List pull requests",
+	Example: `  # Your recently closed PRs, to find one to reopen
+  sage pr list --closed --mine`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ghc := gh.NewClient()
+		ghc := forgeClient()
+		if listClosed {
+			if cmd.Flags().Changed("state") && listState != "closed" {
+				return fmt.Errorf("--closed can't be combined with --state %s", listState)
+			}
+			listState = "closed"
+		}
 		if listState == "" {
 			listState = "open"
 		}
@@ -23,14 +35,30 @@ var prListCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if listMine {
+			login, err := ghc.CurrentUser()
+			if err != nil {
+				return fmt.Errorf("failed to look up your username: %w", err)
+			}
+			prs = app.MinePRs(prs, login)
+		}
+		rows := make([]prRow, 0, len(prs))
 		for _, pr := range prs {
-			fmt.Printf("%s #%d [%s] %s\n", ui.Sage("•"), pr.Number, pr.State, pr.Title)
+			rows = append(rows, prRowFromPR(pr))
+		}
+		if listJSON {
+			listOutput = "json"
 		}
-		return nil
+		return printPRRows(rows, listOutput)
 	},
 }
 
 func init() {
 	prCmd.AddCommand(prListCmd)
 	prListCmd.Flags().StringVar(&listState, "state", "open", "PRs by state (open, closed, all)")
+	prListCmd.Flags().BoolVar(&listJSON, "json", false, "Print the pull requests as JSON (same as --output json)")
+	addOutputFlag(prListCmd, &listOutput)
+	prListCmd.MarkFlagsMutuallyExclusive("json", "output")
+	prListCmd.Flags().BoolVar(&listClosed, "closed", false, "List closed PRs (same as --state closed)")
+	prListCmd.Flags().BoolVar(&listMine, "mine", false, "Only list PRs you opened")
 }
@@ -2,17 +2,28 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
 )
 
 var (
-	prMergeMethod string
+	prMergeMethod       string
+	prMergeAI           bool
+	prMergeConventional bool
+	prMergeAuto         bool
 )
 
 // parsePRNumber converts a string PR number to int
@@ -29,13 +40,27 @@ func parsePRNumber(s string) (int, error) {
 
 // prMergeCmd represents the "sage pr merge" command
 var prMergeCmd = &cobra.Command{
-	Use:   "merge [pr-number]",
-	Short: "Merge a pull request",
+	Use:         "merge [pr-number]",
+	Short:       "Merge a pull request",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
 	Long: `Merge a pull request. If no PR number is provided, attempts to merge the PR for the current branch.
-Supports different merge methods: merge (default), squash, or rebase.`,
+Supports different merge methods: merge (default), squash, or rebase.
+
+Squash merges get a commit message made from the PR: its title with the PR
+number, and its description without template comments, unchecked checklist
+items and empty sections. With --ai the body summarizes the PR's commits
+instead. With --conventional (or pr.squash_conventional=true) the title must
+be a conventional commit, and you are asked to fix it if it isn't.
+
+With --auto, GitHub merges the PR itself once its required checks and
+reviews pass. When the base branch uses a merge queue, the PR is added to
+the queue instead, with or without --auto, and its position is shown.`,
+	Example: `  sage pr merge
+  sage pr merge 42 --method squash
+  sage pr merge --auto`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
-		ghc := gh.NewClient()
+		ghc := forgeClient()
 
 		var prNum int
 		var err error
@@ -79,12 +104,66 @@ Supports different merge methods: merge (default), squash, or rebase.`,
 			return fmt.Errorf("PR #%d is closed", prNum)
 		}
 
+		// git.merge_method (which may be overridden for the PR's branch) replaces the default
+		method := prMergeMethod
+		if !cmd.Flags().Changed("method") {
+			if m := config.GetForBranch("git.merge_method", pr.Head.Ref); m != "" {
+				method = m
+			}
+		}
+
+		if !cmd.Flags().Changed("conventional") {
+			prMergeConventional = config.Get("pr.squash_conventional", true) == "true"
+		}
+		squash := func() error {
+			msg, err := squashMessage(ghc, pr)
+			if err != nil {
+				return err
+			}
+			return app.MergeSquash(ghc, prNum, msg)
+		}
+
+		// Branches with a merge queue only take PRs through it, and --auto
+		// leaves the merge to GitHub once checks and reviews pass
+		opts, optsErr := ghc.GetMergeOptions(prNum)
+		if prMergeAuto && optsErr != nil {
+			return fmt.Errorf("failed to check how PR #%d can be merged: %w", prNum, optsErr)
+		}
+		if prMergeAuto || (optsErr == nil && opts.MergeQueue) {
+			var compose func() (app.SquashMessage, error)
+			if method == "squash" {
+				compose = func() (app.SquashMessage, error) { return squashMessage(ghc, pr) }
+			}
+			res, err := app.AutoMergePR(ghc, prNum, opts, method, compose)
+			if err != nil {
+				return err
+			}
+			switch {
+			case res.Queued && res.Already:
+				fmt.Printf("ℹ PR #%d is already in the merge queue for %s (position %d)\n", prNum, pr.Base.Ref, res.Position)
+			case res.Queued:
+				fmt.Printf("✓ Added PR #%d to the merge queue for %s (position %d)\n", prNum, pr.Base.Ref, res.Position)
+			case res.Already:
+				fmt.Printf("ℹ Auto-merge is already on for PR #%d\n", prNum)
+			case !res.Ready:
+				fmt.Printf("✓ Auto-merge enabled: PR #%d will be merged (%s) once its checks and reviews pass\n", prNum, method)
+			}
+			if !res.Ready {
+				return nil
+			}
+			fmt.Printf("ℹ PR #%d can be merged now; merging it\n", prNum)
+		}
+
 		// Try to merge with the specified method
-		if err := app.MergePR(ghc, prNum, prMergeMethod); err != nil {
+		if method == "squash" {
+			if err := squash(); err != nil {
+				return fmt.Errorf("failed to squash merge: %w", err)
+			}
+		} else if err := app.MergePR(ghc, prNum, method); err != nil {
 			// Check for specific error cases and provide helpful messages
 			if err.Error() == "merge commits are not allowed on this repository" {
 				fmt.Printf("ℹ Merge commits are not allowed. Trying squash merge instead...\n")
-				if err := app.MergePR(ghc, prNum, "squash"); err != nil {
+				if err := squash(); err != nil {
 					return fmt.Errorf("failed to squash merge: %w", err)
 				}
 			} else {
@@ -94,15 +173,29 @@ Supports different merge methods: merge (default), squash, or rebase.`,
 
 		fmt.Printf("✓ Successfully merged PR #%d\n", prNum)
 
-		// Cleanup: delete the local branch if we're on it
+		// Move the branch's ticket along, if issues.transition_on_merge says to
+		if id, status, err := app.TransitionBranchTicket(issueTracker(), pr.Head.Ref); err != nil {
+			fmt.Printf("⚠ %v\n", err)
+		} else if id != "" {
+			fmt.Printf("✓ Moved %s to %s\n", id, status)
+		}
+
+		// Cleanup: delete the local branch if we're on it. With --repo the
+		// local repository, if any, is a different one.
+		if repoinfo.Default().Overridden() {
+			return nil
+		}
 		currentBranch, _ := g.CurrentBranch()
 		if currentBranch == pr.Head.Ref {
-			defaultBranch, err := g.DefaultBranch()
-			if err != nil {
-				defaultBranch = "main" // fallback
+			// Switch to the branch the PR went into
+			defaultBranch := pr.Base.Ref
+			if defaultBranch == "" {
+				var err error
+				if defaultBranch, err = g.DefaultBranch(); err != nil {
+					defaultBranch = "main" // fallback
+				}
 			}
 
-			// Switch to default branch
 			if err := g.RunInteractive("switch", defaultBranch); err != nil {
 				fmt.Printf("⚠ Failed to switch to %s branch: %v\n", defaultBranch, err)
 				return nil
@@ -121,7 +214,57 @@ Supports different merge methods: merge (default), squash, or rebase.`,
 	},
 }
 
+// squashMessage composes the squash commit for pr, checks its title and
+// shows it before merging
+func squashMessage(ghc gh.Client, pr *gh.PullRequest) (app.SquashMessage, error) {
+	msg := app.ComposeSquashMessage(pr)
+
+	if prMergeAI {
+		if !config.AIEnabled(pr.Head.Ref) {
+			return msg, fmt.Errorf("AI features are disabled for %s (ai.enabled=false)", pr.Head.Ref)
+		}
+		client := aiClient()
+		if !client.Configured() {
+			return msg, fmt.Errorf("AI API key not configured. Please set it using 'sage config set ai_api_key YOUR_KEY'")
+		}
+		spinner := ui.NewSpinner()
+		spinner.Start("Summarizing the PR's commits")
+		body, err := app.SummarizeSquashCommits(ghc, client, pr)
+		if err != nil {
+			spinner.StopFail()
+			ui.Warnf("Couldn't summarize the commits, using the PR description: %v\n", err)
+		} else {
+			spinner.StopSuccess()
+			msg.Body = strings.TrimSpace(body)
+		}
+	}
+
+	if prMergeConventional {
+		if err := app.ValidateSquashTitle(msg.Title); err != nil {
+			if batch.Enabled() || !term.IsTerminal(int(os.Stdin.Fd())) {
+				return msg, err
+			}
+			fmt.Printf("%s %v\n", ui.Yellow("!"), err)
+			validate := func(v interface{}) error { return app.ValidateSquashTitle(v.(string)) }
+			title, err := ui.AskInput("Squash commit title:", msg.Title, "give the PR a conventional title", survey.WithValidator(validate))
+			if err != nil {
+				return msg, err
+			}
+			msg.Title = title
+		}
+	}
+
+	fmt.Printf("%s Squash commit:\n  %s\n", ui.Sage("ℹ"), ui.Bold(msg.Title))
+	if msg.Body != "" {
+		fmt.Println(ui.Gray(indent(msg.Body, "  ")))
+	}
+	return msg, nil
+}
+
 func init() {
 	prCmd.AddCommand(prMergeCmd)
 	prMergeCmd.Flags().StringVarP(&prMergeMethod, "method", "m", "merge", "Merge method: merge, squash, or rebase")
+	prMergeCmd.Flags().BoolVar(&prMergeAI, "ai", false, "For squash merges, have AI summarize the PR's commits for the commit body")
+	prMergeCmd.Flags().BoolVarP(&prMergeConventional, "conventional", "c", false, "For squash merges, require a conventional commit title")
+	prMergeCmd.Flags().BoolVar(&prMergeAuto, "auto", false, "Have GitHub merge the PR once its checks and reviews pass")
 }
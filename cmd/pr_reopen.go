@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var prReopenCmd = &cobra.Command{
+	Use:         "reopen [pr-num]",
+	Short:       "Reopen a closed PR",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Reopen a pull request that was closed without merging.
+If no PR number is provided, reopens the PR most recently closed on the current branch.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+
+		var num int
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid PR number: %v", err)
+			}
+			num = n
+		} else {
+			branch, err := git.NewShellGit().CurrentBranch()
+			if err != nil {
+				return err
+			}
+			pr, err := app.ClosedPRForBranch(ghc, branch)
+			if err != nil {
+				return err
+			}
+			if pr == nil {
+				return exitcode.Errorf(exitcode.NotFound, "no closed PR found for branch '%s'; give the PR number: sage pr reopen <number>", branch)
+			}
+			num = pr.Number
+		}
+
+		pr, err := ghc.GetPRDetails(num)
+		if err != nil {
+			return fmt.Errorf("failed to get PR details: %w", err)
+		}
+		if pr.Merged {
+			return fmt.Errorf("PR #%d was merged and can't be reopened", num)
+		}
+		if pr.State == "open" {
+			return fmt.Errorf("PR #%d is already open", num)
+		}
+
+		if err := app.ReopenPR(ghc, num); err != nil {
+			return fmt.Errorf("failed to reopen PR #%d: %w", num, err)
+		}
+		fmt.Printf("%s Reopened PR #%d: %s\n", ui.Green("✓"), num, pr.HTMLURL)
+		return nil
+	},
+}
+
+func init() {
+	prCmd.AddCommand(prReopenCmd)
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	prReviewAll            bool
+	prReviewApprove        bool
+	prReviewRequestChanges bool
+	prReviewComment        bool
+	prReviewMessage        string
+)
+
+var prReviewCmd = &cobra.Command{
+	Use:         "review [pr-num]",
+	Short:       "Read, reply to and resolve review threads, then approve or request changes",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `Browse a pull request's unresolved review threads with the code they are on.
+Pick a thread to reply to it, resolve it, or both; then submit your own review.
+If no PR number is provided, it uses the PR associated with the current branch.
+
+With --approve, --request-changes or --comment the review is submitted right
+away, with the message from -m, and no threads are shown:
+
+  sage pr review 42 --approve
+  sage pr review 42 --request-changes -m "Needs tests for the empty case"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+		num, err := resolvePRNumber(git.NewShellGit(), ghc, args)
+		if err != nil {
+			return err
+		}
+
+		event := ""
+		switch {
+		case prReviewApprove:
+			event = gh.ReviewEventApprove
+		case prReviewRequestChanges:
+			event = gh.ReviewEventRequestChanges
+		case prReviewComment:
+			event = gh.ReviewEventComment
+		}
+		if event != "" {
+			if err := app.SubmitReview(ghc, num, event, prReviewMessage); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s PR #%d\n", ui.Green("✓"), reviewVerb(event), num)
+			return nil
+		}
+
+		if !batch.Enabled() && !term.IsTerminal(int(os.Stdin.Fd())) {
+			return exitcode.Errorf(exitcode.Usage, "sage pr review needs a terminal; use --approve, --request-changes or --comment in scripts, or 'sage pr todos' to list threads")
+		}
+		return reviewThreads(ghc, num)
+	},
+}
+
+// reviewHint says how to review without the thread browser
+const reviewHint = "use --approve, --request-changes or --comment, or list threads with sage pr todos"
+
+// reviewThreads runs the thread browser for PR num
+func reviewThreads(ghc gh.Client, num int) error {
+	threads, err := app.ReviewThreads(ghc, num, prReviewAll)
+	if err != nil {
+		return err
+	}
+
+	const (
+		submit = "Submit my review…"
+		done   = "Done"
+	)
+	for {
+		options := make([]string, 0, len(threads)+2)
+		for _, t := range threads {
+			options = append(options, threadSummary(t))
+		}
+		if len(threads) == 0 {
+			fmt.Printf("%s No unresolved threads on PR #%d\n", ui.Green("✓"), num)
+		}
+		options = append(options, submit, done)
+
+		choice, err := ui.AskSelectIndex(fmt.Sprintf("PR #%d: %d thread%s", num, len(threads), ui.Plural(len(threads))), options,
+			reviewHint, survey.WithPageSize(15))
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case choice == len(threads):
+			submitted, err := askReview(ghc, num)
+			if err != nil || submitted {
+				return err
+			}
+		case choice == len(threads)+1:
+			return nil
+		default:
+			resolved, err := threadActions(ghc, num, &threads[choice])
+			if err != nil {
+				return err
+			}
+			if resolved && !prReviewAll {
+				threads = append(threads[:choice], threads[choice+1:]...)
+			}
+		}
+	}
+}
+
+// threadSummary is a thread's line in the list
+func threadSummary(t gh.ReviewThread) string {
+	first := t.Comments[0]
+	body, _, _ := strings.Cut(strings.TrimSpace(first.Body), "\n")
+	if len(body) > 60 {
+		body = body[:57] + "..."
+	}
+	s := fmt.Sprintf("%s:%d · @%s: %s", t.Path, t.Line, first.User, body)
+	if n := len(t.Comments) - 1; n > 0 {
+		s += fmt.Sprintf(" (%d repl%s)", n, map[bool]string{true: "y", false: "ies"}[n == 1])
+	}
+	if t.Resolved {
+		s += " ✓"
+	}
+	return s
+}
+
+// threadActions shows a thread and acts on it. It reports whether the
+// thread was resolved.
+func threadActions(ghc gh.Client, num int, t *gh.ReviewThread) (bool, error) {
+	fmt.Printf("\n%s %s\n", ui.White(fmt.Sprintf("%s:%d", t.Path, t.Line)), ui.Gray(map[bool]string{true: "(outdated)", false: ""}[t.Outdated]))
+	for _, line := range strings.Split(ui.HighlightHunk(t.Path, strings.Join(app.ThreadContext(*t), "\n")), "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Println()
+	for _, c := range t.Comments {
+		fmt.Printf("  %s %s\n", ui.Blue("@"+c.User), ui.Gray(c.Time.Local().Format("Jan 2, 15:04")))
+		fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimSpace(c.Body), "\n", "\n    "))
+	}
+	fmt.Println()
+
+	const (
+		reply        = "Reply"
+		replyResolve = "Reply and resolve"
+		resolve      = "Resolve"
+		back         = "Back to the threads"
+	)
+	options := []string{reply, replyResolve, resolve, back}
+	if t.Resolved {
+		options = []string{reply, back}
+	}
+	action, err := ui.AskSelect("What do you want to do?", options, "", reviewHint)
+	if err != nil {
+		return false, err
+	}
+
+	if action == reply || action == replyResolve {
+		body, err := ui.AskText("Reply:", reviewHint)
+		if err != nil {
+			return false, err
+		}
+		if strings.TrimSpace(body) == "" {
+			fmt.Println(ui.Gray("Nothing to send."))
+			return false, nil
+		}
+		if err := app.ReplyToThread(ghc, num, *t, body); err != nil {
+			return false, err
+		}
+		t.Comments = append(t.Comments, gh.ReviewComment{User: "you", Body: body})
+		fmt.Printf("%s Replied\n", ui.Green("✓"))
+	}
+	if action == resolve || action == replyResolve {
+		if err := app.ResolveThread(ghc, *t); err != nil {
+			return false, err
+		}
+		t.Resolved = true
+		fmt.Printf("%s Resolved\n", ui.Green("✓"))
+		return true, nil
+	}
+	return false, nil
+}
+
+// askReview asks for a verdict and message and submits them. It reports
+// whether a review was sent.
+func askReview(ghc gh.Client, num int) (bool, error) {
+	const cancel = "Not yet"
+	verdicts := map[string]string{
+		"Approve":         gh.ReviewEventApprove,
+		"Request changes": gh.ReviewEventRequestChanges,
+		"Comment":         gh.ReviewEventComment,
+	}
+	verdict, err := ui.AskSelect("Your review:", []string{"Approve", "Request changes", "Comment", cancel}, "", reviewHint)
+	if err != nil || verdict == cancel {
+		return false, err
+	}
+	event := verdicts[verdict]
+
+	msg := "Message (optional):"
+	var opts []survey.AskOpt
+	if event != gh.ReviewEventApprove {
+		msg = "Message:"
+		opts = append(opts, survey.WithValidator(survey.Required))
+	}
+	body, err := ui.AskText(msg, reviewHint, opts...)
+	if err != nil {
+		return false, err
+	}
+	if err := app.SubmitReview(ghc, num, event, body); err != nil {
+		return false, err
+	}
+	fmt.Printf("%s %s PR #%d\n", ui.Green("✓"), reviewVerb(event), num)
+	return true, nil
+}
+
+func reviewVerb(event string) string {
+	switch event {
+	case gh.ReviewEventApprove:
+		return "Approved"
+	case gh.ReviewEventRequestChanges:
+		return "Requested changes on"
+	default:
+		return "Commented on"
+	}
+}
+
+func init() {
+	prCmd.AddCommand(prReviewCmd)
+	prReviewCmd.Flags().BoolVar(&prReviewAll, "all", false, "Include resolved threads")
+	prReviewCmd.Flags().BoolVar(&prReviewApprove, "approve", false, "Approve the PR without browsing threads")
+	prReviewCmd.Flags().BoolVar(&prReviewRequestChanges, "request-changes", false, "Request changes without browsing threads (needs -m)")
+	prReviewCmd.Flags().BoolVar(&prReviewComment, "comment", false, "Leave a review comment without browsing threads (needs -m)")
+	prReviewCmd.Flags().StringVarP(&prReviewMessage, "message", "m", "", "Review message")
+	prReviewCmd.MarkFlagsMutuallyExclusive("approve", "request-changes", "comment")
+}
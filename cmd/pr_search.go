@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchOrg        string
+	searchAuthor     string
+	searchLabels     []string
+	searchInvolvesMe bool
+	searchState      string
+	searchLimit      int
+	searchJSON       bool
+)
+
+var prSearchCmd = &cobra.Command{
+	Use:   "search [text]",
+	Short: "Search pull requests across an organization",
+	Long: `Search pull requests in every repository of an organization, by author,
+label, text, or whether they involve you (as author, assignee, reviewer, or
+in a comment). The organization defaults to the owner of the current
+repository, or of --repo.`,
+	Example: `  sage pr search --involves-me
+  sage pr search --author octocat --state merged
+  sage pr search "rate limit" --label bug --org acme --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		org := searchOrg
+		if org == "" {
+			info, err := repoinfo.Default().Repo()
+			if err != nil {
+				return fmt.Errorf("couldn't tell which organization to search; pass --org: %w", err)
+			}
+			org = info.Owner
+		}
+
+		items, total, err := app.SearchPRs(githubClient(), app.PRSearchOptions{
+			Org:        org,
+			Author:     searchAuthor,
+			Labels:     searchLabels,
+			Text:       strings.Join(args, " "),
+			InvolvesMe: searchInvolvesMe,
+			State:      searchState,
+		}, searchLimit)
+		if err != nil {
+			return err
+		}
+
+		rows := make([]prRow, 0, len(items))
+		for _, item := range items {
+			rows = append(rows, prRowFromSearch(item))
+		}
+		format := "text"
+		if searchJSON {
+			format = "json"
+		}
+		if err := printPRRows(rows, format); err != nil {
+			return err
+		}
+		if !searchJSON {
+			if len(rows) == 0 {
+				fmt.Println(ui.Gray("No pull requests found in " + org))
+			} else if total > len(rows) {
+				fmt.Fprintln(os.Stderr, ui.Gray(fmt.Sprintf("Showing %d of %d; raise --limit to see more", len(rows), total)))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	prCmd.AddCommand(prSearchCmd)
+	prSearchCmd.Flags().StringVar(&searchOrg, "org", "", "Organization or user to search (default: owner of the current repository)")
+	prSearchCmd.Flags().StringVar(&searchAuthor, "author", "", "Only PRs by this user (@me for yourself)")
+	prSearchCmd.Flags().StringSliceVar(&searchLabels, "label", nil, "Only PRs with this label (repeatable)")
+	prSearchCmd.Flags().BoolVar(&searchInvolvesMe, "involves-me", false, "Only PRs you authored, were assigned, reviewed or commented on")
+	prSearchCmd.Flags().StringVar(&searchState, "state", "open", "PRs by state (open, closed, merged, all)")
+	prSearchCmd.Flags().IntVar(&searchLimit, "limit", 30, "Maximum number of PRs to show (up to 1000)")
+	prSearchCmd.Flags().BoolVar(&searchJSON, "json", false, "Print the pull requests as JSON")
+}
@@ -2,80 +2,140 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	prStatusFailOn   string
+	prStatusWatch    bool
+	prStatusInterval time.Duration
 )
 
 var prStatusCmd = &cobra.Command{
-	Use:   "status [pr-num]",
-	Short: "Show detailed status of a pull request",
-	Long: `Display comprehensive information about a pull request including:
-- Title and description
-- Current status (open/closed/merged)
-- Review status
-- CI/CD checks status
-- Branch information
-- Timeline of events`,
+	Use:         "status [pr-num]",
+	Short:       "Show checks, reviews and mergeability of a pull request",
+	Annotations: map[string]string{prRepoAnnotation: "number"},
+	Long: `Display where a pull request stands, by default the current branch's:
+- Status, branches and whether it can be merged or has conflicts
+- Each CI check, with how long it ran and a link to it
+- Each reviewer's decision, and who still has to review
+- Approvals the base branch requires that are still missing
+- Description and recent commits
+
+With --watch, the checks are polled until they have all finished.
+
+With --fail-on, it exits with code 7 when the PR isn't ready: 'failure' when
+a check failed or a reviewer requested changes, 'pending' also while checks
+are still running. Useful as a gate in scripts and CI, after --watch.`,
+	Example: `  sage pr status
+  sage pr status 42
+  sage pr status --watch --fail-on failure`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ghc := gh.NewClient()
+		switch prStatusFailOn {
+		case "", app.FailOnFailure, app.FailOnPending:
+		default:
+			return exitcode.Errorf(exitcode.Usage, "invalid --fail-on %q (use %s or %s)", prStatusFailOn, app.FailOnFailure, app.FailOnPending)
+		}
+		if prStatusInterval < time.Second {
+			return exitcode.Errorf(exitcode.Usage, "--interval must be at least 1s")
+		}
+
+		ghc := forgeClient()
 		g := git.NewShellGit()
 
-		var num int
-		var err error
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
 
-		if len(args) == 1 {
-			// PR number provided
-			num, err = strconv.Atoi(args[0])
-			if err != nil {
-				return err
-			}
-		} else {
-			// Use current branch's PR
-			branch, err := g.CurrentBranch()
-			if err != nil {
-				return err
-			}
+		st, err := app.GetPRStatus(ghc, num)
+		if err != nil {
+			return err
+		}
 
-			// List PRs for this branch
-			prs, err := ghc.ListPRs("open")
-			if err != nil {
+		if prStatusWatch {
+			if err := watchPRStatus(ghc, st); err != nil {
 				return err
 			}
-
-			// Find PR for current branch
-			var found bool
-			for _, pr := range prs {
-				if pr.Head.Ref == branch {
-					num = pr.Number
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				return fmt.Errorf("no PR number provided and no PR found for current branch %q", branch)
-			}
+		} else {
+			printPRStatus(st, true)
 		}
 
-		details, err := app.GetPRDetails(ghc, num)
+		if prStatusFailOn == "" {
+			return nil
+		}
+		reasons, err := app.PRFailOnReasons(ghc, st.PR, prStatusFailOn)
 		if err != nil {
 			return err
 		}
-
-		printPRStatus(details)
+		if len(reasons) > 0 {
+			return exitcode.Errorf(exitcode.Threshold, "PR #%d isn't ready (--fail-on %s):\n  %s",
+				num, prStatusFailOn, strings.Join(reasons, "\n  "))
+		}
 		return nil
 	},
 }
 
-func printPRStatus(pr *gh.PullRequest) {
+// watchPRStatus redraws the status until no check is still running. Off a
+// terminal, only the final status is printed.
+func watchPRStatus(ghc gh.Client, st *app.PRStatus) error {
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	for {
+		pending := st.ChecksPending()
+		if tty {
+			fmt.Print("\033[H\033[2J")
+			printPRStatus(st, !pending)
+			if pending {
+				fmt.Printf("\n%s\n", ui.Gray(fmt.Sprintf("Checking again every %s; Ctrl+C to stop.", prStatusInterval)))
+			}
+		} else if !pending {
+			printPRStatus(st, true)
+		}
+		if !pending {
+			return nil
+		}
+		time.Sleep(prStatusInterval)
+		if err := st.Refresh(ghc); err != nil {
+			return err
+		}
+	}
+}
+
+// resolvePRNumber returns the PR number given as the first argument, or the
+// open PR for the current branch when no argument is given
+func resolvePRNumber(g git.Service, ghc gh.Client, args []string) (int, error) {
+	if len(args) == 1 {
+		return strconv.Atoi(args[0])
+	}
+
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return 0, err
+	}
+
+	pr, err := app.PRForBranch(ghc, branch)
+	if err != nil {
+		return 0, err
+	}
+	return pr.Number, nil
+}
+
+// printPRStatus shows the PR's state, checks and reviews; full adds its
+// description and recent commits
+func printPRStatus(st *app.PRStatus, full bool) {
+	pr := st.PR
 	// Title section
 	fmt.Printf("\n%s #%d: %s\n", ui.Sage("Pull Request"), pr.Number, ui.White(pr.Title))
 	fmt.Printf("%s\n\n", ui.Blue(pr.HTMLURL))
@@ -107,44 +167,63 @@ func printPRStatus(pr *gh.PullRequest) {
 	// Branch information
 	fmt.Printf("Branch: %s → %s\n", ui.Yellow(pr.Head.Ref), ui.Yellow(pr.Base.Ref))
 
+	// Mergeability
+	if pr.State == "open" {
+		merge, known := st.MergeState()
+		mergeColor := ui.Sage
+		switch {
+		case !known:
+			mergeColor = ui.Gray
+		case strings.HasPrefix(merge, "has conflicts"):
+			mergeColor = ui.Red
+		case merge != "ready to merge" && merge != "no conflicts":
+			mergeColor = ui.Yellow
+		}
+		fmt.Printf("Merge:  %s\n", mergeColor(merge))
+	}
+
+	// Approvals the base branch requires
+	if st.RequiredApprovals > 0 {
+		approvals := fmt.Sprintf("%d of %d required", st.Approvals(), st.RequiredApprovals)
+		if missing := st.MissingApprovals(); missing > 0 {
+			fmt.Printf("Approvals: %s %s\n", ui.Yellow(approvals), ui.Gray(fmt.Sprintf("(%d more needed)", missing)))
+		} else {
+			fmt.Printf("Approvals: %s\n", ui.Sage(approvals))
+		}
+	}
+
 	// Review status
-	if len(pr.Reviews) > 0 {
+	if len(st.Reviewers) > 0 {
 		fmt.Printf("\n%s\n", ui.Sage("Reviews:"))
-		for _, review := range pr.Reviews {
-			var reviewColor func(string) string = ui.White
-			switch review.State {
+		for _, r := range st.Reviewers {
+			switch r.State {
 			case "APPROVED":
-				reviewColor = ui.Sage
+				fmt.Printf("  %s @%s approved\n", ui.Sage("✓"), r.Login)
 			case "CHANGES_REQUESTED":
-				reviewColor = ui.Red
-			case "COMMENTED":
-				reviewColor = ui.Blue
+				fmt.Printf("  %s @%s requested changes\n", ui.Red("✗"), r.Login)
+			case "DISMISSED":
+				fmt.Printf("  %s @%s's review was dismissed\n", ui.Gray("-"), r.Login)
+			case app.ReviewCommented:
+				fmt.Printf("  %s @%s commented\n", ui.Blue("•"), r.Login)
+			case app.ReviewPending:
+				fmt.Printf("  %s @%s hasn't reviewed yet\n", ui.Yellow("○"), r.Login)
 			}
-			fmt.Printf("  %s by @%s\n", reviewColor(review.State), review.User.Login)
 		}
 	}
 
 	// CI Status
 	if len(pr.Checks) > 0 {
-		fmt.Printf("\n%s\n", ui.Sage("Checks:"))
-		for _, check := range pr.Checks {
-			var checkColor func(string) string = ui.White
-			switch check.Status {
-			case "success":
-				checkColor = ui.Sage
-			case "failure":
-				checkColor = ui.Red
-			case "pending":
-				checkColor = ui.Yellow
-			}
-			fmt.Printf("  %s: %s\n", check.Name, checkColor(check.Status))
-		}
+		printPRChecks(pr.Checks)
+	}
+
+	if !full {
+		return
 	}
 
 	// Description
 	if pr.Body != "" {
 		fmt.Printf("\n%s\n", ui.Sage("Description:"))
-		fmt.Printf("%s\n", pr.Body)
+		fmt.Printf("%s\n", ui.RenderMarkdown(pr.Body))
 	}
 
 	// Timeline (recent commits)
@@ -167,6 +246,61 @@ func printPRStatus(pr *gh.PullRequest) {
 	}
 }
 
+// printPRChecks lists the checks with how long each ran and where to see it
+func printPRChecks(checks []gh.Check) {
+	var passed, failed, running int
+	width := 0
+	for _, c := range checks {
+		width = max(width, len([]rune(c.Name)))
+	}
+	now := time.Now()
+	fmt.Printf("\n%s\n", ui.Sage("Checks:"))
+	for _, c := range checks {
+		var mark string
+		switch {
+		case c.Status != "completed":
+			mark = ui.Yellow("●")
+			running++
+		case c.Failed():
+			mark = ui.Red("✗")
+			failed++
+		case c.Conclusion == "success":
+			mark = ui.Sage("✓")
+			passed++
+		default:
+			// Skipped, neutral or cancelled
+			mark = ui.Gray("-")
+			passed++
+		}
+		took := ""
+		if d := app.CheckDuration(c, now); d > 0 {
+			took = d.Round(time.Second).String()
+		}
+		line := fmt.Sprintf("  %s %s %s", mark, pad(c.Name, width), ui.Gray(pad(took, 8)))
+		if c.Status != "completed" {
+			line += " " + ui.Yellow(strings.ReplaceAll(c.Status, "_", " "))
+		} else if c.Conclusion != "success" && c.Conclusion != "" {
+			line += " " + ui.Gray(strings.ReplaceAll(c.Conclusion, "_", " "))
+		}
+		if c.HTMLURL != "" {
+			line += " " + ui.Blue(c.HTMLURL)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	summary := []string{fmt.Sprintf("%d passed", passed)}
+	if failed > 0 {
+		summary = append(summary, ui.Red(fmt.Sprintf("%d failed", failed)))
+	}
+	if running > 0 {
+		summary = append(summary, ui.Yellow(fmt.Sprintf("%d running", running)))
+	}
+	fmt.Printf("  %s\n", strings.Join(summary, ", "))
+}
+
 func init() {
 	prCmd.AddCommand(prStatusCmd)
+	prStatusCmd.Flags().StringVar(&prStatusFailOn, "fail-on", "", "Exit with code 7 when checks or reviews reach this level: failure or pending")
+	prStatusCmd.Flags().BoolVarP(&prStatusWatch, "watch", "w", false, "Keep checking until every check has finished")
+	prStatusCmd.Flags().DurationVar(&prStatusInterval, "interval", 15*time.Second, "How often --watch checks")
 }
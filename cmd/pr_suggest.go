@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	prSuggestAll       bool
+	prSuggestCommit    bool
+	prSuggestList      bool
+	prSuggestNoResolve bool
+)
+
+var prApplySuggestionCmd = &cobra.Command{
+	Use:         "apply-suggestion [pr-num]",
+	Short:       "Apply reviewers' suggested changes from a PR's review comments",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
+	Long: `List the suggestion blocks in a pull request's unresolved review threads,
+show the change each proposes, and apply the ones you pick to your working
+tree. Their threads are marked resolved afterwards (--no-resolve keeps them
+open). If no PR number is provided, it uses the PR for the current branch,
+which has to be checked out.
+
+With --commit each suggestion becomes a commit of its own, with the reviewer
+as co-author, like applying it on GitHub does. Suggestions on threads that
+are outdated, or whose lines can't be found any more, are left alone.
+
+  sage pr apply-suggestion --list
+  sage pr apply-suggestion 42 --all --commit`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+		g := git.NewShellGit()
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
+
+		suggestions, err := app.PendingSuggestions(ghc, num)
+		if err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			fmt.Printf("%s No pending suggestions on PR #%d\n", ui.Green("✓"), num)
+			return nil
+		}
+		for i, s := range suggestions {
+			printSuggestion(i+1, s)
+		}
+		if prSuggestList {
+			return nil
+		}
+
+		pr, err := app.GetPRDetails(ghc, num)
+		if err != nil {
+			return err
+		}
+		if branch, _ := g.CurrentBranch(); branch != pr.Head.Ref {
+			return fmt.Errorf("PR #%d is on %s, not the current branch; check it out first with 'sage pr checkout %d'", num, pr.Head.Ref, num)
+		}
+
+		picked := suggestions
+		if !prSuggestAll {
+			if batch.Enabled() || !term.IsTerminal(int(os.Stdin.Fd())) {
+				return batch.NeedsInput("sage pr apply-suggestion needs to know which suggestions to apply", "pass --all")
+			}
+			if picked, err = pickSuggestions(suggestions); err != nil || len(picked) == 0 {
+				return err
+			}
+		}
+
+		results, err := app.ApplySuggestions(g, ghc, picked, prSuggestCommit, !prSuggestNoResolve)
+		if err != nil {
+			return err
+		}
+		applied := 0
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Printf("%s %s: %v\n", ui.Red("✗"), r.Suggestion.Label(), r.Err)
+				continue
+			}
+			applied++
+			note := ""
+			if r.Resolved {
+				note = ui.Gray(" (resolved)")
+			}
+			fmt.Printf("%s %s%s\n", ui.Green("✓"), r.Suggestion.Label(), note)
+		}
+
+		switch {
+		case applied == 0:
+			return fmt.Errorf("no suggestions could be applied")
+		case prSuggestCommit:
+			ui.Info(fmt.Sprintf("Committed %d suggestion%s; push them with 'sage push'", applied, ui.Plural(applied)))
+		default:
+			ui.Info(fmt.Sprintf("Applied %d suggestion%s to the working tree; review them with 'git diff', then commit", applied, ui.Plural(applied)))
+		}
+		return nil
+	},
+}
+
+// printSuggestion shows a suggestion as the lines it takes out and puts in
+func printSuggestion(n int, s app.ReviewSuggestion) {
+	fmt.Printf("\n%s %s\n", ui.Bold(fmt.Sprintf("%d.", n)), ui.White(s.Label()))
+	for _, l := range s.Old {
+		fmt.Printf("  %s\n", ui.Red("-"+l))
+	}
+	for _, l := range s.New {
+		fmt.Printf("  %s\n", ui.Green("+"+l))
+	}
+}
+
+// pickSuggestions asks which suggestions to apply
+func pickSuggestions(suggestions []app.ReviewSuggestion) ([]app.ReviewSuggestion, error) {
+	options := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		options[i] = fmt.Sprintf("%d. %s", i+1, s.Label())
+	}
+	fmt.Println()
+	var chosen []int
+	prompt := &survey.MultiSelect{Message: "Apply which suggestions?", Options: options, PageSize: 15}
+	if err := survey.AskOne(prompt, &chosen); err != nil {
+		return nil, err
+	}
+	picked := make([]app.ReviewSuggestion, 0, len(chosen))
+	for _, i := range chosen {
+		picked = append(picked, suggestions[i])
+	}
+	return picked, nil
+}
+
+func init() {
+	prCmd.AddCommand(prApplySuggestionCmd)
+	prApplySuggestionCmd.Flags().BoolVar(&prSuggestAll, "all", false, "Apply every pending suggestion without asking")
+	prApplySuggestionCmd.Flags().BoolVar(&prSuggestCommit, "commit", false, "Commit each suggestion with the reviewer as co-author")
+	prApplySuggestionCmd.Flags().BoolVar(&prSuggestList, "list", false, "Only show the pending suggestions")
+	prApplySuggestionCmd.Flags().BoolVar(&prSuggestNoResolve, "no-resolve", false, "Leave the threads unresolved")
+	prApplySuggestionCmd.MarkFlagsMutuallyExclusive("list", "all")
+}
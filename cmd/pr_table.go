@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// prRow is one pull request as shown by pr list and pr search
+type prRow struct {
+	Repo      string    `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Number    int       `json:"number" yaml:"number"`
+	Title     string    `json:"title" yaml:"title"`
+	State     string    `json:"state" yaml:"state"`
+	Author    string    `json:"author" yaml:"author"`
+	URL       string    `json:"url" yaml:"url"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// prState folds drafts and merges into the state column
+func prState(state string, draft, merged bool) string {
+	switch {
+	case merged:
+		return "merged"
+	case draft && state == "open":
+		return "draft"
+	}
+	return state
+}
+
+func prRowFromPR(pr gh.PullRequest) prRow {
+	return prRow{
+		Number:    pr.Number,
+		Title:     pr.Title,
+		State:     prState(pr.State, pr.Draft, pr.Merged || pr.MergedAt != nil),
+		Author:    pr.User.Login,
+		URL:       pr.HTMLURL,
+		UpdatedAt: pr.UpdatedAt,
+	}
+}
+
+func prRowFromSearch(item gh.PRSearchItem) prRow {
+	return prRow{
+		Repo:      item.Repo(),
+		Number:    item.Number,
+		Title:     item.Title,
+		State:     prState(item.State, item.Draft, item.Merged()),
+		Author:    item.User.Login,
+		URL:       item.HTMLURL,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+// printPRRows prints rows as JSON, or as an aligned table. Rows from other
+// repositories are shown as owner/name#number.
+func printPRRows(rows []prRow, format string) error {
+	if rows == nil {
+		rows = []prRow{}
+	}
+	if ok, err := printStructured(format, rows); ok || err != nil {
+		return err
+	}
+
+	refWidth, stateWidth, authorWidth := 0, 0, 0
+	refs := make([]string, len(rows))
+	for i, r := range rows {
+		refs[i] = fmt.Sprintf("#%d", r.Number)
+		if r.Repo != "" {
+			refs[i] = r.Repo + refs[i]
+		}
+		refWidth = max(refWidth, utf8.RuneCountInString(refs[i]))
+		stateWidth = max(stateWidth, len(r.State))
+		authorWidth = max(authorWidth, utf8.RuneCountInString(r.Author))
+	}
+
+	for i, r := range rows {
+		state := fmt.Sprintf("%-*s", stateWidth, r.State)
+		switch r.State {
+		case "open":
+			state = ui.Green(state)
+		case "merged":
+			state = ui.Sage(state)
+		case "closed":
+			state = ui.Red(state)
+		default:
+			state = ui.Gray(state)
+		}
+		updated := ""
+		if !r.UpdatedAt.IsZero() {
+			updated = ui.Gray(formatAge(time.Since(r.UpdatedAt)))
+		}
+		fmt.Printf("%s  %s  %s  %s  %s\n",
+			ui.White(fmt.Sprintf("%-*s", refWidth, refs[i])),
+			state,
+			fmt.Sprintf("%-*s", authorWidth, r.Author),
+			strings.TrimSpace(r.Title),
+			updated)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
@@ -20,8 +21,9 @@ var (
 )
 
 var prTodosCmd = &cobra.Command{
-	Use:   "todos [pr-num]",
-	Short: "Show unresolved comment threads (uses current branch's PR if no number specified)",
+	Use:         "todos [pr-num]",
+	Short:       "Show unresolved comment threads (uses current branch's PR if no number specified)",
+	Annotations: map[string]string{prRepoAnnotation: "number"},
 	Long: `Display unresolved comment threads from a pull request in an organized view.
 If no PR number is provided, it uses the PR associated with the current branch.
 
@@ -36,7 +38,7 @@ Flags:
   --time    Show timestamps for each comment`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ghc := gh.NewClient()
+		ghc := forgeClient()
 		g := git.NewShellGit()
 
 		var num int
@@ -72,7 +74,7 @@ Flags:
 			}
 
 			if !found {
-				return fmt.Errorf("no PR number provided and no PR found for current branch %q", branch)
+				return exitcode.Errorf(exitcode.NotFound, "no PR number provided and no PR found for current branch %q", branch)
 			}
 		}
 
@@ -161,8 +163,8 @@ Flags:
 				if showDiff && t.CodeContext != "" {
 					// Show code context with syntax highlighting
 					fmt.Printf("    %s\n", ui.Gray("Code context:"))
-					for _, line := range strings.Split(t.CodeContext, "\n") {
-						fmt.Printf("      %s\n", ui.Gray(line))
+					for _, line := range strings.Split(ui.HighlightHunk(file, t.CodeContext), "\n") {
+						fmt.Printf("      %s\n", line)
 					}
 					fmt.Println()
 				}
@@ -5,8 +5,8 @@ import (
 	"strconv"
 
 	"github.com/crazywolf132/sage/internal/app"
-	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -21,15 +21,20 @@ var (
 )
 
 var prUpdateCmd = &cobra.Command{
-	Use:   "update [pr-num]",
-	Short: "Update a pull request's fields",
+	Use:         "update [pr-num]",
+	Short:       "Update a pull request's fields",
+	Annotations: map[string]string{mutatingAnnotation: "", prRepoAnnotation: "number"},
 	Long: `Update various fields of a pull request. If no PR number is provided, uses the current branch's PR.
 	
 You can update the title, body, draft status, labels, and reviewers. With the --ai flag, 
 it will automatically update the PR body and labels based on the latest commits.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ghc := gh.NewClient()
+		if updateAI && repoinfo.Default().Overridden() {
+			return fmt.Errorf("--ai reads the PR's commits from the local checkout and can't be used with --repo")
+		}
+
+		ghc := forgeClient()
 		g := git.NewShellGit()
 
 		var num int
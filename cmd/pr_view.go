@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var prViewNoPager bool
+
+var prViewCmd = &cobra.Command{
+	Use:         "view [pr-num]",
+	Short:       "Read a pull request: description, commits, files, reviews and threads",
+	Annotations: map[string]string{prRepoAnnotation: "number"},
+	Long: `Render a full pull request in a pager without changing anything:
+- Description (markdown rendered; use --raw for the original text)
+- Commits
+- Changed files with a diffstat
+- Reviews and checks
+- Open comment threads
+
+Without a number, the PR for the current branch is shown.`,
+	Example: `  sage pr view 42
+  sage pr view 42 --no-pager --raw`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ghc := forgeClient()
+		g := git.NewShellGit()
+
+		num, err := resolvePRNumber(g, ghc, args)
+		if err != nil {
+			return err
+		}
+
+		view, err := app.GetPRView(ghc, num)
+		if err != nil {
+			return err
+		}
+
+		out := renderPRView(view)
+		if prViewNoPager {
+			fmt.Print(out)
+			return nil
+		}
+		return ui.Page(out)
+	},
+}
+
+func renderPRView(v *app.PRView) string {
+	var b strings.Builder
+	pr := v.PR
+
+	status := pr.State
+	if pr.Draft {
+		status = "draft"
+	}
+	if pr.Merged {
+		status = "merged"
+	}
+	fmt.Fprintf(&b, "%s #%d: %s\n", ui.Sage("Pull Request"), pr.Number, ui.Bold(pr.Title))
+	fmt.Fprintf(&b, "%s  %s → %s  %s\n", strings.ToUpper(status), ui.Yellow(pr.Head.Ref), ui.Yellow(pr.Base.Ref), ui.Blue(pr.HTMLURL))
+
+	section := func(title string) {
+		fmt.Fprintf(&b, "\n%s\n", ui.Sage(title))
+	}
+
+	section("Description")
+	if strings.TrimSpace(pr.Body) == "" {
+		fmt.Fprintln(&b, ui.Gray("No description provided."))
+	} else {
+		fmt.Fprintln(&b, ui.RenderMarkdown(pr.Body))
+	}
+
+	var commits []gh.TimelineEvent
+	for _, e := range pr.Timeline {
+		if e.Event == "committed" {
+			commits = append(commits, e)
+		}
+	}
+	if len(commits) > 0 {
+		section(fmt.Sprintf("Commits (%d)", len(commits)))
+		for _, c := range commits {
+			fmt.Fprintf(&b, "  %s %s %s\n", ui.Yellow(shortRef(c.SHA)), firstLine(c.Message), ui.Gray("@"+c.Actor.Login))
+		}
+	}
+
+	if len(v.Files) > 0 {
+		adds, dels := 0, 0
+		for _, f := range v.Files {
+			adds += f.Additions
+			dels += f.Deletions
+		}
+		section(fmt.Sprintf("Files (%d, %s %s)", len(v.Files), ui.Green(fmt.Sprintf("+%d", adds)), ui.Red(fmt.Sprintf("-%d", dels))))
+		for _, f := range v.Files {
+			fmt.Fprintf(&b, "  %s %s\n", diffstatBar(f.Additions, f.Deletions), f.Filename)
+		}
+	}
+
+	if len(pr.Reviews) > 0 {
+		section("Reviews")
+		for _, r := range pr.Reviews {
+			color := ui.White
+			switch r.State {
+			case "APPROVED":
+				color = ui.Sage
+			case "CHANGES_REQUESTED":
+				color = ui.Red
+			case "COMMENTED":
+				color = ui.Blue
+			}
+			fmt.Fprintf(&b, "  %s by @%s\n", color(r.State), r.User.Login)
+			if body := strings.TrimSpace(r.Body); body != "" {
+				fmt.Fprintln(&b, indent(ui.RenderMarkdown(body), "    "))
+			}
+		}
+	}
+
+	if len(pr.Checks) > 0 {
+		section("Checks")
+		for _, c := range pr.Checks {
+			fmt.Fprintf(&b, "  %s: %s\n", c.Name, c.Status)
+		}
+	}
+
+	if len(v.Threads) > 0 {
+		section(fmt.Sprintf("Open threads (%d)", len(v.Threads)))
+		for _, t := range v.Threads {
+			fmt.Fprintf(&b, "  %s\n", ui.Bold(fmt.Sprintf("%s:%d", t.Path, t.Line)))
+			for _, c := range t.Comments {
+				fmt.Fprintf(&b, "    %s %s\n", ui.Blue("@"+c.User), ui.Gray(c.Time.Local().Format("2006-01-02 15:04")))
+				fmt.Fprintln(&b, indent(ui.RenderMarkdown(c.Body), "      "))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// diffstatBar renders a fixed-width "+12 -3 ++++--" summary for one file
+func diffstatBar(adds, dels int) string {
+	const width = 10
+	total := adds + dels
+	plus, minus := adds, dels
+	if total > width {
+		plus = adds * width / total
+		minus = width - plus
+	}
+	counts := fmt.Sprintf("%6s %6s", fmt.Sprintf("+%d", adds), fmt.Sprintf("-%d", dels))
+	bar := ui.Green(strings.Repeat("+", plus)) + ui.Red(strings.Repeat("-", minus)) + strings.Repeat(" ", width-plus-minus)
+	return counts + " " + bar
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+func init() {
+	prCmd.AddCommand(prViewCmd)
+	prViewCmd.Flags().BoolVar(&prViewNoPager, "no-pager", false, "Print directly instead of using a pager")
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	protectDryRun bool
+)
+
+var protectCmd = &cobra.Command{
+	Use:         "protect [branch]",
+	Short:       "Configure GitHub branch protection",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Set up branch protection on GitHub: required reviews, required status
+checks, linear history and force-push/deletion rules.
+
+The desired settings come from .sage/protection.yaml when it has an entry for
+the branch (names or globs such as release/*), otherwise you are prompted for
+them. The difference from the current settings is shown before anything is
+changed.
+
+  branches:
+    main:
+      required_reviews: 1
+      dismiss_stale_reviews: true
+      required_checks: [build, test]
+      strict_checks: true
+      linear_history: true
+      allow_force_pushes: false
+
+Without a branch, the default branch is protected.`,
+	Example: `  sage protect
+  sage protect release/2.0 --dry-run`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		ghc := githubClient()
+
+		branch := ""
+		if len(args) > 0 {
+			branch = args[0]
+		} else {
+			db, err := g.DefaultBranch()
+			if err != nil {
+				return fmt.Errorf("failed to determine default branch; pass one explicitly: %w", err)
+			}
+			branch = db
+		}
+
+		current, err := ghc.GetBranchProtection(branch)
+		if err != nil {
+			return fmt.Errorf("failed to read protection for %s: %w", branch, err)
+		}
+
+		desired, err := app.LoadProtection(g, branch)
+		if err != nil {
+			return err
+		}
+		if desired == nil {
+			desired, err = promptProtection(branch, current)
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := app.DiffProtection(current, *desired)
+		if len(changes) == 0 {
+			fmt.Printf("%s %s is already protected as requested\n", ui.Green("✓"), ui.Sage(branch))
+			return nil
+		}
+
+		fmt.Printf("%s %s\n", ui.Bold("Protection changes for"), ui.Sage(branch))
+		for _, c := range changes {
+			fmt.Printf("  %-32s %s → %s\n", c.Setting, ui.Red(c.From), ui.Green(c.To))
+		}
+
+		if protectDryRun {
+			fmt.Println(ui.Gray("\nDry run: nothing was changed."))
+			return nil
+		}
+
+		ok, err := ui.ConfirmAction(fmt.Sprintf("Apply these settings to %s?", branch))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(ui.Yellow("Protection unchanged."))
+			return nil
+		}
+
+		if err := app.ApplyProtection(ghc, branch, *desired); err != nil {
+			return err
+		}
+		fmt.Printf("%s Updated protection for %s\n", ui.Green("✓"), ui.Sage(branch))
+		return nil
+	},
+}
+
+// promptProtection asks for each setting, starting from the current protection
+func promptProtection(branch string, current *gh.BranchProtection) (*gh.BranchProtection, error) {
+	if batch.Enabled() {
+		return nil, batch.NeedsInput(fmt.Sprintf("%s has no entry in .sage/protection.yaml", branch), "add one there to choose its settings")
+	}
+	bp := gh.BranchProtection{RequiredReviews: 1, LinearHistory: true}
+	if current != nil {
+		bp = *current
+	}
+
+	fmt.Printf("No entry for %s in .sage/protection.yaml; choose the settings to apply.\n", ui.Sage(branch))
+
+	reviews := strconv.Itoa(bp.RequiredReviews)
+	checks := strings.Join(bp.RequiredChecks, ", ")
+	qs := []*survey.Question{
+		{
+			Name:   "reviews",
+			Prompt: &survey.Input{Message: "Required approving reviews (0-6):", Default: reviews},
+			Validate: func(ans interface{}) error {
+				n, err := strconv.Atoi(ans.(string))
+				if err != nil || n < 0 || n > 6 {
+					return fmt.Errorf("enter a number between 0 and 6")
+				}
+				return nil
+			},
+		},
+		{Name: "stale", Prompt: &survey.Confirm{Message: "Dismiss stale reviews on new pushes?", Default: bp.DismissStaleReviews}},
+		{Name: "checks", Prompt: &survey.Input{Message: "Required status checks (comma separated):", Default: checks}},
+		{Name: "strict", Prompt: &survey.Confirm{Message: "Require the branch to be up to date before merging?", Default: bp.StrictChecks}},
+		{Name: "linear", Prompt: &survey.Confirm{Message: "Require linear history?", Default: bp.LinearHistory}},
+		{Name: "force", Prompt: &survey.Confirm{Message: "Allow force pushes?", Default: bp.AllowForcePushes}},
+		{Name: "admins", Prompt: &survey.Confirm{Message: "Apply rules to administrators too?", Default: bp.EnforceAdmins}},
+	}
+	answers := struct {
+		Reviews string
+		Stale   bool
+		Checks  string
+		Strict  bool
+		Linear  bool
+		Force   bool
+		Admins  bool
+	}{}
+	if err := survey.Ask(qs, &answers); err != nil {
+		return nil, err
+	}
+
+	bp.RequiredReviews, _ = strconv.Atoi(answers.Reviews)
+	bp.DismissStaleReviews = answers.Stale
+	bp.RequiredChecks = nil
+	for _, c := range strings.Split(answers.Checks, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			bp.RequiredChecks = append(bp.RequiredChecks, c)
+		}
+	}
+	bp.StrictChecks = answers.Strict
+	bp.LinearHistory = answers.Linear
+	bp.AllowForcePushes = answers.Force
+	bp.EnforceAdmins = answers.Admins
+	return &bp, nil
+}
+
+func init() {
+	rootCmd.AddCommand(protectCmd)
+	protectCmd.Flags().BoolVar(&protectDryRun, "dry-run", false, "Show the changes without applying them")
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	purgePaths    []string
+	purgePatterns []string
+	purgeRemote   string
+	purgeNoPush   bool
+	purgeForce    bool
+)
+
+var purgeCmd = &cobra.Command{
+	Use:         "purge",
+	Short:       "Remove committed secrets from the entire history",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Rewrite every branch and tag to remove a file or redact a pattern, then
+force-push the rewritten branches.
+
+Paths are removed from every commit. Patterns are regular expressions whose
+matches are replaced with ***REMOVED*** in every file. Rewriting uses
+git-filter-repo; if it isn't installed, sage prints the exact commands to run.
+
+Each remote branch is force-pushed with a lease on the commit it pointed to
+before the rewrite, so any work pushed in the meantime is never overwritten.
+
+Without prompts (--non-interactive) sage won't rewrite history unless
+--force says so, and doesn't push unless --force is given as well.
+
+Purging does not make a leaked secret safe again: rotate it.`,
+	Example: `  # Remove a key file from all history
+  sage purge --path config/key.pem
+
+  # Redact a token wherever it appears
+  sage purge --pattern 'ghp_[A-Za-z0-9]{36}'
+
+  # In a script, rewrite and push without asking
+  sage purge --path config/key.pem --force --non-interactive`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		opts := app.PurgeOptions{
+			Paths:    purgePaths,
+			Patterns: purgePatterns,
+			Remote:   purgeRemote,
+		}
+
+		plan, err := app.PlanPurge(g, opts)
+		if err != nil {
+			return err
+		}
+		if len(plan.Commits) == 0 {
+			fmt.Println(ui.Green("✓ Nothing in history matches; there is nothing to purge."))
+			return nil
+		}
+		fmt.Printf("%s %d commit(s) contain the purged content.\n", ui.Yellow("!"), len(plan.Commits))
+
+		if !plan.FilterRepo {
+			fmt.Printf("\n%s git-filter-repo is not installed. Run these commands to purge manually:\n\n", ui.Yellow("!"))
+			for _, step := range plan.ManualSteps {
+				fmt.Printf("  %s\n", ui.Blue(step))
+			}
+			printPurgeReminders()
+			return nil
+		}
+
+		// Rewriting can't be undone, so batch mode only does it when told to
+		proceed := purgeForce
+		if !proceed {
+			if batch.Enabled() {
+				return batch.NeedsInput("rewriting every branch and tag needs confirming", "pass --force")
+			}
+			if proceed, err = ui.AskConfirm("Rewrite ALL local branches and tags? This cannot be undone.", false); err != nil {
+				return err
+			}
+		}
+		if !proceed {
+			fmt.Println(ui.Yellow("Purge cancelled."))
+			return nil
+		}
+
+		if err := app.Purge(g, opts, plan); err != nil {
+			return err
+		}
+		fmt.Printf("%s History rewritten\n", ui.Green("✓"))
+
+		if !purgeNoPush && len(plan.RemoteTips) > 0 {
+			push := purgeForce && batch.Enabled()
+			if !push {
+				push, err = ui.AskConfirm(fmt.Sprintf("Force-push %d rewritten branch(es) to %s?", len(plan.RemoteTips), purgeRemote), false)
+				if err != nil {
+					return err
+				}
+			}
+			if push {
+				pushed, err := app.PushPurged(g, opts, plan)
+				for _, b := range pushed {
+					fmt.Printf("%s Pushed %s\n", ui.Green("✓"), b)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		printPurgeReminders()
+		return nil
+	},
+}
+
+func printPurgeReminders() {
+	fmt.Printf("\n%s\n", ui.Bold("Before you move on:"))
+	for _, r := range app.PurgeReminders() {
+		fmt.Printf("  • %s\n", r)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(purgeCmd)
+	purgeCmd.Flags().StringArrayVar(&purgePaths, "path", nil, "File to remove from all history (repeatable)")
+	purgeCmd.Flags().StringArrayVar(&purgePatterns, "pattern", nil, "Regular expression to redact from all history (repeatable)")
+	purgeCmd.Flags().StringVar(&purgeRemote, "remote", "origin", "Remote to force-push rewritten branches to")
+	purgeCmd.Flags().BoolVar(&purgeNoPush, "no-push", false, "Rewrite locally without pushing")
+	purgeCmd.Flags().BoolVar(&purgeForce, "force", false, "Rewrite without asking, and without prompts push too (unless --no-push)")
+}
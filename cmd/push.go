@@ -2,29 +2,45 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/dryrun"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
-
-	"github.com/AlecAivazis/survey/v2"
 )
 
 var (
-	forcePush bool
-	skipYes   bool
+	forcePush       bool
+	pushWip         bool
+	pushTags        bool
+	pushAllBranches bool
+	pushRemote      string
 )
 
 var pushCmd = &cobra.Command{
-	Use:   "push",
-	Short: "Push changes to remote",
+	Use:         "push [refspec...]",
+	Short:       "Push changes to remote",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Push the current branch to origin.
+
+With refspecs, --tags or --all-branches, push those refs instead. The refs are
+validated and listed for confirmation first, and the result for each ref is
+reported.`,
+	Example: `  sage push
+  sage push feature:review/feature
+  sage push --tags
+  sage push --all-branches`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
-		if forcePush && !skipYes {
+		if len(args) > 0 || pushTags || pushAllBranches {
+			return pushRefs(g, args)
+		}
+		if forcePush {
 			fmt.Println(ui.Red("WARNING: You're about to force-push."))
-			var confirm bool
-			if err := survey.AskOne(&survey.Confirm{Message: "Are you sure?"}, &confirm); err != nil {
+			confirm, err := ui.ConfirmAction("Are you sure?")
+			if err != nil {
 				return err
 			}
 			if !confirm {
@@ -32,10 +48,13 @@ var pushCmd = &cobra.Command{
 				return nil
 			}
 		}
-		err := app.PushCurrentBranch(g, forcePush)
+		err := app.PushCurrentBranch(g, forcePush, pushWip)
 		if err != nil {
 			return err
 		}
+		if dryrun.Enabled() {
+			return nil
+		}
 		if forcePush {
 			fmt.Println(ui.Green("Force-pushed current branch."))
 		} else {
@@ -45,8 +64,67 @@ var pushCmd = &cobra.Command{
 	},
 }
 
+// pushRefs pushes explicit refspecs, tags or all tracked branches after confirmation
+func pushRefs(g git.Service, refspecs []string) error {
+	plans, err := app.PlanRefPush(g, app.RefPushOptions{
+		Remote:      pushRemote,
+		Refspecs:    refspecs,
+		Tags:        pushTags,
+		AllBranches: pushAllBranches,
+		Force:       forcePush,
+		AllowWip:    pushWip,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(ui.Bold("About to push:"))
+	for _, p := range plans {
+		for _, spec := range p.Refspecs {
+			fmt.Printf("  %s → %s\n", spec, p.Remote)
+		}
+		if p.Tags {
+			fmt.Printf("  all tags → %s\n", p.Remote)
+		}
+	}
+	if forcePush {
+		fmt.Println(ui.Red("Remote refs will be overwritten (with lease)."))
+	}
+
+	confirm, err := ui.ConfirmAction("Push these refs?")
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		fmt.Println(ui.Gray("Cancelled."))
+		return nil
+	}
+
+	results, err := app.PushRefs(g, plans)
+	rejected := 0
+	for _, r := range results {
+		ref := strings.TrimPrefix(strings.TrimPrefix(r.Destination, "refs/heads/"), "refs/")
+		if r.OK() {
+			fmt.Printf("%s %s %s\n", ui.Green("✓"), ref, ui.Gray(r.Status))
+		} else {
+			rejected++
+			fmt.Printf("%s %s %s\n", ui.Red("✗"), ref, ui.Red(r.Summary))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if rejected > 0 {
+		return fmt.Errorf("%d ref%s rejected", rejected, ui.Plural(rejected))
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(pushCmd)
 	pushCmd.Flags().BoolVarP(&forcePush, "force", "f", false, "Force push")
-	pushCmd.Flags().BoolVarP(&skipYes, "yes", "y", false, "Skip confirmation for force push")
+	pushCmd.Flags().BoolVar(&pushWip, "allow-wip", false, "Allow pushing wip checkpoint commits")
+	pushCmd.Flags().BoolVar(&pushTags, "tags", false, "Push all tags")
+	pushCmd.Flags().BoolVar(&pushAllBranches, "all-branches", false, "Push every branch that has an upstream")
+	pushCmd.Flags().StringVar(&pushRemote, "remote", "origin", "Remote for refspecs and tags")
 }
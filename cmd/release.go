@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	releaseSkipExisting bool
+	releasePreRelease   string
+	releaseNoPublish    bool
+)
+
+var releaseCmd = &cobra.Command{
+	Use:         "release [major|minor|patch|version]",
+	Short:       "Cut a release: changelog, tag and GitHub release",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Release the current branch. The next version comes from the conventional
+commits since the last release tag: breaking changes bump the major version
+(the minor one before 1.0.0), feat commits the minor one and anything else
+the patch; pass major, minor, patch or a version to choose it yourself.
+
+The release's features, fixes and other changes, along with any pending
+'sage changelog' entries, go into CHANGELOG.md under a new heading. That is
+committed as "chore(release): vX.Y.Z" and tagged with an annotated tag
+holding the notes, then the branch and tag are pushed and a GitHub release is
+published with the same notes.
+
+--pre-release makes a pre-release such as v1.3.0-rc.1, numbered on from the
+last one. Use --dry-run to see the version and notes without changing
+anything.`,
+	Example: `  sage release
+  sage release --dry-run
+  sage release minor --pre-release
+  sage release 2.0.0 --pre-release beta --no-publish`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		opts := app.ReleaseOptions{PreRelease: releasePreRelease}
+		if len(args) == 1 {
+			opts.Bump = args[0]
+		}
+		if clean, err := g.IsClean(); err != nil {
+			return err
+		} else if !clean && !dryrun.Enabled() {
+			return fmt.Errorf("commit or stash your changes before releasing")
+		}
+
+		plan, err := app.PlanRelease(g, opts)
+		if err != nil {
+			return err
+		}
+		since := "the first commit"
+		if plan.Previous != "" {
+			since = plan.Previous
+		}
+		how := plan.Bump
+		if how == "" {
+			how = "explicit version"
+		}
+		fmt.Printf("%s %s %s\n\n", ui.Bold("Releasing"), ui.Green(plan.Tag),
+			ui.Gray(fmt.Sprintf("(%s, %d commit%s since %s)", how, len(plan.Commits), ui.Plural(len(plan.Commits)), since)))
+		if plan.Notes != "" {
+			fmt.Println(plan.Notes)
+		} else {
+			fmt.Println(ui.Gray("No features or fixes to list in the changelog."))
+		}
+		fmt.Println()
+
+		if !dryrun.Enabled() {
+			confirm, err := ui.ConfirmAction("Release " + plan.Tag + "?")
+			if err != nil {
+				return err
+			}
+			if !confirm {
+				fmt.Println(ui.Gray("Aborted."))
+				return nil
+			}
+		}
+
+		if err := app.ApplyRelease(g, plan, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("%s Updated %s and tagged %s\n", ui.Green("✓"), app.ChangelogFile, ui.Blue(plan.Tag))
+		if releaseNoPublish {
+			fmt.Println(ui.Gray("Push it with 'git push origin " + plan.Tag + "' when you're ready."))
+			return nil
+		}
+
+		release, err := app.PublishRelease(g, githubClient(), plan)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Published %s\n", ui.Green("✓"), ui.Blue(plan.Tag))
+		if release.HTMLURL != "" {
+			fmt.Printf("\n%s\n", release.HTMLURL)
+		}
+		return nil
+	},
+}
+
+var releaseUploadCmd = &cobra.Command{
+	Use:         "upload <tag> <files...>",
+	Short:       "Upload build artifacts to a release",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Attach files to the GitHub release for <tag>. Globs are expanded, so
+'sage release upload v1.2.3 "dist/*"' works even when the shell doesn't.
+
+Content types are picked from the file extension, or from the file's first
+bytes when the extension is unknown. An asset with the same name is replaced
+unless --skip-existing is given. Failed uploads are retried a few times.`,
+	Example: `  sage release upload v1.2.3 dist/*
+  sage release upload v1.2.3 dist/sage_linux_amd64.tar.gz checksums.txt --skip-existing`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tag := args[0]
+		files, err := app.ReleaseAssetFiles(args[1:])
+		if err != nil {
+			return err
+		}
+
+		ghc := githubClient()
+		release, err := ghc.GetReleaseByTag(tag)
+		if err != nil {
+			return fmt.Errorf("failed to find the release for %s: %w", tag, err)
+		}
+
+		interactive := term.IsTerminal(int(os.Stdout.Fd()))
+		var failed int
+		for _, f := range files {
+			var progress app.UploadProgress
+			if interactive {
+				progress = func(sent, total int64) {
+					fmt.Printf("\r  %s %s", uploadBar(sent, total), ui.Gray(f.Name))
+				}
+			}
+
+			res, err := app.UploadReleaseAsset(ghc, release, f, !releaseSkipExisting, progress)
+			if interactive && !res.Skipped {
+				fmt.Print("\r\033[K")
+			}
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("%s %s: %v\n", ui.Red("✗"), f.Name, err)
+			case res.Skipped:
+				fmt.Printf("%s %s already exists, skipped\n", ui.Gray("•"), f.Name)
+			default:
+				verb := "Uploaded"
+				if res.Replaced {
+					verb = "Replaced"
+				}
+				retries := ""
+				if res.Attempts > 1 {
+					retries = ui.Gray(fmt.Sprintf(" after %d attempts", res.Attempts))
+				}
+				fmt.Printf("%s %s %s %s%s\n", ui.Green("✓"), verb, ui.Blue(f.Name),
+					ui.Gray(fmt.Sprintf("(%s, %s)", formatBytes(f.Size), f.ContentType)), retries)
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d file%s failed to upload", failed, len(files), ui.Plural(len(files)))
+		}
+		if release.HTMLURL != "" {
+			fmt.Printf("\n%s\n", release.HTMLURL)
+		}
+		return nil
+	},
+}
+
+// uploadBar draws a progress bar with the share of total already sent
+func uploadBar(sent, total int64) string {
+	const width = 30
+	frac := 1.0
+	if total > 0 {
+		frac = float64(sent) / float64(total)
+	}
+	filled := int(frac * width)
+	return fmt.Sprintf("[%s%s] %3.0f%% %s/%s", ui.Green(strings.Repeat("█", filled)),
+		strings.Repeat("░", width-filled), frac*100, formatBytes(sent), formatBytes(total))
+}
+
+// formatBytes renders n bytes in the largest unit that keeps it above 1
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseUploadCmd)
+	releaseCmd.Flags().StringVar(&releasePreRelease, "pre-release", "", "Make a pre-release with this identifier (rc when given alone)")
+	releaseCmd.Flags().Lookup("pre-release").NoOptDefVal = "rc"
+	releaseCmd.Flags().BoolVar(&releaseNoPublish, "no-publish", false, "Commit and tag locally without pushing or creating the GitHub release")
+	releaseUploadCmd.Flags().BoolVar(&releaseSkipExisting, "skip-existing", false, "Leave assets that already exist instead of replacing them")
+}
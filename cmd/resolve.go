@@ -8,14 +8,18 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 )
 
 // resolveCmd represents the resolve command
 var resolveCmd = &cobra.Command{
-	Use:   "resolve",
-	Short: "Interactively resolve merge conflicts",
+	Use:         "resolve",
+	Short:       "Interactively resolve merge conflicts",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Sage resolve helps you handle merge conflicts interactively.
 
 When you encounter conflicts during a merge, rebase, or sync operation,
@@ -26,14 +30,16 @@ You can specify an editor with the --editor flag, or it will use your
 Git-configured editor, or fall back to the EDITOR environment variable.`,
 	Example: `  sage resolve               # List and resolve all conflicts
   sage resolve --editor vim  # Use vim to edit conflict files
-  sage resolve --auto        # Attempt to auto-resolve simple conflicts`,
+  sage resolve --auto        # Attempt to auto-resolve simple conflicts
+  sage resolve --ai          # Review AI-proposed resolutions hunk by hunk`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
 
 		autoResolve, _ := cmd.Flags().GetBool("auto")
 		editorCmd, _ := cmd.Flags().GetString("editor")
+		useAI, _ := cmd.Flags().GetBool("ai")
 
-		return resolveConflicts(g, editorCmd, autoResolve)
+		return resolveConflicts(g, editorCmd, autoResolve, useAI)
 	},
 }
 
@@ -41,10 +47,11 @@ func init() {
 	rootCmd.AddCommand(resolveCmd)
 	resolveCmd.Flags().StringP("editor", "e", "", "Specify which editor to use for conflict resolution")
 	resolveCmd.Flags().BoolP("auto", "a", false, "Attempt to automatically resolve simple conflicts")
+	resolveCmd.Flags().Bool("ai", false, "Ask AI to propose a resolution for each conflicted hunk")
 }
 
 // resolveConflicts handles the conflict resolution process
-func resolveConflicts(g git.Service, editorCmd string, autoResolve bool) error {
+func resolveConflicts(g git.Service, editorCmd string, autoResolve bool, useAI bool) error {
 	// Check if we're in a merge or rebase state
 	isMerging, err := g.IsMerging()
 	if err != nil {
@@ -56,7 +63,12 @@ func resolveConflicts(g git.Service, editorCmd string, autoResolve bool) error {
 		return err
 	}
 
-	if !isMerging && !isRebasing {
+	isApplying, err := g.IsApplyingPatches()
+	if err != nil {
+		return err
+	}
+
+	if !isMerging && !isRebasing && !isApplying {
 		return fmt.Errorf("No merge or rebase in progress. Nothing to resolve")
 	}
 
@@ -125,6 +137,34 @@ func resolveConflicts(g git.Service, editorCmd string, autoResolve bool) error {
 		}
 	}
 
+	if useAI && !config.AIEnabled("") {
+		ui.Warning("AI is disabled on this branch, skipping AI suggestions")
+		useAI = false
+	}
+	if useAI {
+		client := aiClient()
+		if !client.Configured() {
+			ui.Warning("No AI API key found, skipping AI suggestions")
+		} else {
+			for _, file := range conflictFiles {
+				if err := resolveFileWithAI(g, client, file); err != nil {
+					ui.Error(fmt.Sprintf("%s: %v", file, err))
+				}
+			}
+
+			conflicts, _ = g.ListConflictedFiles()
+			if strings.TrimSpace(conflicts) == "" {
+				ui.Success("All conflicts resolved successfully!")
+				return continueOperation(g, isMerging, isRebasing)
+			}
+			conflictFiles = strings.Split(strings.TrimSpace(conflicts), "\n")
+			ui.Info(fmt.Sprintf("Still %d file(s) with conflicts:", len(conflictFiles)))
+			for i, file := range conflictFiles {
+				fmt.Printf("%d: %s\n", i+1, file)
+			}
+		}
+	}
+
 	// Determine which editor to use
 	editor := determineEditor(g, editorCmd)
 
@@ -197,6 +237,76 @@ func resolveConflicts(g git.Service, editorCmd string, autoResolve bool) error {
 	}
 }
 
+// resolveFileWithAI walks through each conflicted hunk in a file, offering ours,
+// theirs, or an AI-proposed merge. Nothing is written without the user choosing it.
+func resolveFileWithAI(g git.Service, client *ai.Client, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	hunks := app.ParseConflictHunks(content)
+	if len(hunks) == 0 {
+		return nil
+	}
+	app.LoadConflictBases(g, file, hunks)
+
+	ui.Info(fmt.Sprintf("%s: %d conflicted hunk(s)", ui.Bold(file), len(hunks)))
+	resolutions := map[int][]string{}
+
+	for i, h := range hunks {
+		fmt.Printf("\n%s\n", ui.Bold(fmt.Sprintf("Hunk %d/%d (line %d)", i+1, len(hunks), h.Start+1)))
+		fmt.Println(ui.Green("ours (" + h.OursLabel + "):"))
+		fmt.Println(strings.Join(h.Ours, "\n"))
+		fmt.Println(ui.Blue("theirs (" + h.TheirsLabel + "):"))
+		fmt.Println(strings.Join(h.Theirs, "\n"))
+
+		options := []string{"Keep ours", "Keep theirs", "Skip (resolve manually)"}
+		spinner := ui.NewSpinner()
+		spinner.Start("Asking AI for a resolution...")
+		suggestion, aiErr := app.SuggestHunkResolution(client, file, h)
+		if aiErr != nil {
+			spinner.StopFail()
+			ui.Warning(fmt.Sprintf("No AI suggestion: %v", aiErr))
+		} else {
+			spinner.StopSuccess()
+			fmt.Println(ui.Sage("AI proposal (change relative to ours):"))
+			fmt.Println(ui.HighlightHunk(file, strings.TrimRight(app.FormatHunkDiff(h.Ours, suggestion), "\n")))
+			options = append([]string{"Use AI proposal"}, options...)
+		}
+
+		choice, err := ui.AskSelect("How should this hunk be resolved?", options, "Skip (resolve manually)", "")
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case "Use AI proposal":
+			resolutions[i] = suggestion
+		case "Keep ours":
+			resolutions[i] = h.Ours
+		case "Keep theirs":
+			resolutions[i] = h.Theirs
+		}
+	}
+
+	if len(resolutions) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(file, []byte(app.ApplyHunkResolutions(content, hunks, resolutions)), 0644); err != nil {
+		return err
+	}
+
+	if len(resolutions) == len(hunks) {
+		if _, err := g.Run("add", "--", file); err != nil {
+			return fmt.Errorf("failed to mark as resolved: %w", err)
+		}
+		ui.Success(fmt.Sprintf("Resolved %s", file))
+	} else {
+		ui.Info(fmt.Sprintf("Resolved %d of %d hunks in %s", len(resolutions), len(hunks), file))
+	}
+	return nil
+}
+
 // determineEditor gets the editor to use based on priority:
 // 1. Command line flag
 // 2. Git config core.editor
@@ -207,20 +317,7 @@ func determineEditor(g git.Service, editorFlag string) string {
 		return editorFlag
 	}
 
-	// Try to get from git config
-	gitEditor, err := g.GetConfigValue("core.editor")
-	if err == nil && gitEditor != "" {
-		return gitEditor
-	}
-
-	// Try environment variable
-	envEditor := os.Getenv("EDITOR")
-	if envEditor != "" {
-		return envEditor
-	}
-
-	// Default
-	return "vim"
+	return app.ResolveEditor(g)
 }
 
 // openFileInEditor opens the specified file in the given editor
@@ -250,8 +347,13 @@ func attemptAutoResolve(g git.Service, filePath string) error {
 	return fmt.Errorf("auto-resolution not implemented")
 }
 
-// continueOperation continues the current merge/rebase operation
+// continueOperation continues the current merge/rebase operation, or the
+// patch series being applied by apply-mbox
 func continueOperation(g git.Service, isMerging, isRebasing bool) error {
+	if series, _ := app.LoadMboxSeries(g); series != nil && series.Stopped {
+		ui.Info("Continuing patch series...")
+		return continueMbox(g, series)
+	}
 	if isMerging {
 		ui.Info("Continuing merge...")
 		return g.MergeContinue()
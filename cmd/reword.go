@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	rewordMessage     string
+	rewordAI          bool
+	rewordSubjectOnly bool
+	rewordAutoAccept  bool
+)
+
+var rewordCmd = &cobra.Command{
+	Use:         "reword [commit]",
+	Short:       "Change the message of a commit on the current branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Changes a commit's message without touching its changes. The commit defaults
+to the last one; an older one on the current branch can be reworded too, and
+the commits after it keep their changes and messages.
+
+Without a message the current one opens in your editor, or with --ai a new one
+is written from the commit's diff, building on the current message. Use
+--subject-only to replace just the subject line and keep a hand-written body.`,
+	Example: `  sage reword -m "fix: handle empty config"
+  sage reword --ai
+  sage reword 3f2a9c1 --ai --subject-only
+  sage reword 3f2a9c1 --subject-only -m "feat(auth): add device login"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := app.RewordOptions{
+			Message:     rewordMessage,
+			UseAI:       rewordAI,
+			SubjectOnly: rewordSubjectOnly,
+			AutoAccept:  rewordAutoAccept,
+		}
+		if len(args) == 1 {
+			opts.Commit = args[0]
+		}
+
+		res, err := app.Reword(git.NewShellGit(), opts)
+		if err != nil {
+			return err
+		}
+
+		if dryrun.Enabled() {
+			fmt.Printf("%s Would reword the commit: %s\n", ui.Green("✓"), firstLine(res.Message))
+			return nil
+		}
+		fmt.Printf("%s Reworded %s: %s\n", ui.Green("✓"), ui.Yellow(res.Hash[:min(len(res.Hash), 8)]), firstLine(res.Message))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rewordCmd)
+	rewordCmd.Flags().StringVarP(&rewordMessage, "message", "m", "", "The new commit message")
+	rewordCmd.Flags().BoolVarP(&rewordAI, "ai", "a", false, "Rewrite the message with AI")
+	rewordCmd.Flags().BoolVar(&rewordSubjectOnly, "subject-only", false, "Replace only the subject line, keeping the body")
+	rewordCmd.Flags().BoolVar(&rewordAutoAccept, "accept", false, "Automatically accept the AI-generated message (-y does too, and turns other prompts off)")
+	rewordCmd.MarkFlagsMutuallyExclusive("message", "ai")
+}
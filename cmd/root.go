@@ -1,10 +1,19 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/batch"
 	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/crazywolf132/sage/internal/update"
 	"github.com/crazywolf132/sage/internal/version"
@@ -17,6 +26,38 @@ var noGitHubCommands = map[string]bool{
 	"completion": true,
 	"help":       true,
 	"version":    true,
+	"update":     true,
+	"doctor":     true,
+	"ws":         true,
+}
+
+// dryRun routes every mutating git command and GitHub API call through the
+// dry-run executor, printing it instead of running it
+var dryRun bool
+
+// nonInteractive turns prompts off for scripts and CI, as SAGE_NONINTERACTIVE
+// does. -y/--yes is the same flag, so no command defines its own.
+var nonInteractive bool
+
+// verbose prints every GitHub API call with the quota it leaves; sync's own
+// --verbose turns it on too
+var verbose bool
+
+// Commands that still work before the first commit
+var emptyRepoCommands = map[string]bool{
+	"init":       true,
+	"config":     true,
+	"completion": true,
+	"help":       true,
+	"version":    true,
+	"commit":     true,
+	"stage":      true,
+	"status":     true,
+	"audit":      true,
+	"update":     true,
+	"doctor":     true,
+	"learn":      true,
+	"ws":         true,
 }
 
 var rootCmd = &cobra.Command{
@@ -42,11 +83,26 @@ To enable shell completion:
 	Version:       version.Get(),
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Load config (global + local) once
 		if err := config.LoadAllConfigs(); err != nil {
 			ui.Warnf("Failed to load config: %v\n", err)
 		}
+		loadGitHubAccounts()
+		loadGitHubApp()
+		beginNotify(cmd)
+		ui.SetSyntaxTheme(config.Get("ui.syntax_theme", true))
+		if nonInteractive {
+			batch.Enable()
+		}
+		if v, _ := cmd.Flags().GetBool("verbose"); v {
+			gh.Verbose = os.Stderr
+		}
+
+		// --repo on PR commands replaces the repository found from the remotes
+		if err := applyPRRepo(cmd, args); err != nil {
+			return err
+		}
 
 		// Only sync git config features if we're in a git repository
 		g := git.NewShellGit()
@@ -57,16 +113,93 @@ To enable shell completion:
 			}
 		}
 
-		// Check for updates using the public GitHub API
-		_ = update.CheckForUpdatesPublic(version.Get())
+		// Check for updates using the public GitHub API. JSON and YAML
+		// output is left alone so it still parses.
+		machine := structuredOutput(cmd)
+		if !machine {
+			_ = update.CheckForUpdatesPublic(version.Get())
+		}
+
+		// Most commands need history; point new repositories at 'sage init'
+		name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+		if inRepo && name != cmd.Root().Name() && !emptyRepoCommands[strings.Fields(name)[0]] && app.IsEmptyRepo(g) {
+			return app.ErrNoCommits
+		}
+
+		// sync and protect have their own --dry-run, which shadows this one
+		if dryRun {
+			dryrun.Enable(os.Stdout)
+			if !machine {
+				fmt.Println(ui.Yellow("Dry run: changes are printed, not made."))
+			}
+			return nil
+		}
+
+		beginAudit(cmd, args)
+		return nil
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if structuredOutput(cmd) {
+			return
+		}
+		if dryrun.Enabled() {
+			if len(dryrun.Actions()) == 0 {
+				fmt.Println(ui.Gray("Dry run: nothing would change."))
+			}
+			return
+		}
+		printSuggestions(cmd)
+	},
+}
+
+// printSuggestions shows the most relevant next commands after a command succeeds.
+// It is opt-in via the ui.suggestions config key.
+func printSuggestions(cmd *cobra.Command) {
+	if config.Get("ui.suggestions", true) != "true" {
+		return
+	}
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if noGitHubCommands[strings.Fields(name)[0]] || repoinfo.Default().Overridden() {
+		return
+	}
+	// Gathering them costs several git calls, so only commands that change
+	// the repository, and status, which is where you look for what's next,
+	// are followed by them
+	if _, mutating := cmd.Annotations[mutatingAnnotation]; !mutating && name != "status" {
+		return
+	}
+
+	g := git.NewShellGit()
+	if inRepo, _ := g.IsRepo(); !inRepo {
+		return
+	}
+
+	state, err := app.GatherSuggestionState(g, optionalGitHubClient, name)
+	if err != nil {
+		return
+	}
+	suggestions := app.SuggestNextSteps(state, 2)
+	if len(suggestions) == 0 {
+		return
+	}
+
+	fmt.Println(ui.Gray("\nNext steps:"))
+	for _, s := range suggestions {
+		fmt.Printf("  %s %s\n", ui.Sage(s.Command), ui.Gray("— "+s.Reason))
+	}
 }
 
 func init() {
 	rootCmd.SetUsageTemplate(ui.ColorHeadings(rootCmd.UsageTemplate()))
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt: take the default answer, or fail when a flag is needed instead (also SAGE_NONINTERACTIVE=1)")
+	rootCmd.PersistentFlags().BoolVarP(&nonInteractive, "yes", "y", false, "Same as --non-interactive")
+	rootCmd.PersistentFlags().BoolVar(&notifyDone, "notify", false, "Ring the bell or show a desktop notification when the command finishes (see ui.notify)")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log GitHub API calls, retries and the rate limit left to stderr")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the git commands and GitHub API calls that would change anything instead of running them")
 
 	// Add completion command
 	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(exitCodesCmd)
 }
 
 // completionCmd represents the completion command
@@ -128,6 +261,68 @@ PowerShell:
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// The error carries the exit code sage should finish with (see exitcode.Of).
 func Execute() error {
-	return rootCmd.Execute()
+	markUsageErrors(rootCmd)
+	err := execute()
+	if err != nil {
+		explainLocks(err)
+		explainAccount(err)
+	}
+	finishAudit(err)
+	finishNotify(err)
+	return err
+}
+
+// execute runs the command line, turning the panics the GitHub, GitLab and
+// Bitbucket clients raise when no repository or token can be found into errors
+func execute() (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		msg, ok := r.(string)
+		if !ok {
+			panic(r)
+		}
+		code := exitcode.Failure
+		if strings.Contains(msg, "token not found") || strings.Contains(msg, "credentials not found") {
+			code = exitcode.Auth
+		}
+		err = exitcode.New(code, errors.New(strings.TrimSpace(msg)))
+	}()
+	_, err = rootCmd.ExecuteC()
+	return err
+}
+
+// markUsageErrors makes invalid arguments and flags exit with exitcode.Usage
+func markUsageErrors(c *cobra.Command) {
+	c.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return exitcode.New(exitcode.Usage, err)
+	})
+	if validate := c.Args; validate != nil {
+		c.Args = func(cmd *cobra.Command, args []string) error {
+			return exitcode.New(exitcode.Usage, validate(cmd, args))
+		}
+	}
+	for _, sub := range c.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+var exitCodesCmd = &cobra.Command{
+	Use:   "exit-codes",
+	Short: "Exit codes sage finishes with, for scripts and CI",
+	Long:  exitCodesHelp(),
+}
+
+// exitCodesHelp lists the exit codes for 'sage help exit-codes'
+func exitCodesHelp() string {
+	var b strings.Builder
+	b.WriteString("Sage exits with one of these codes. They are stable, so scripts and CI can\nrely on them; commands with --fail-on exit with 7 when the threshold is reached.\n\n")
+	for _, c := range exitcode.All {
+		fmt.Fprintf(&b, "  %d  %-11s %s\n", c.Code, c.Name, c.Description)
+	}
+	return strings.TrimRight(b.String(), "\n")
 }
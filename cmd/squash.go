@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/crazywolf132/sage/internal/app"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
@@ -16,8 +15,9 @@ var (
 )
 
 var squashCmd = &cobra.Command{
-	Use:   "squash [commit]",
-	Short: "Squash commits interactively",
+	Use:         "squash [commit]",
+	Short:       "Squash commits interactively",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Squash commits using interactive rebase.
 If --all flag is used, squashes all commits in the branch (except on head branch).
 Otherwise, squashes from the specified commit or prompts for selection.`,
@@ -30,18 +30,14 @@ Otherwise, squashes from the specified commit or prompts for selection.`,
 			startCommit = args[0]
 		} else if !squashAll {
 			// Get commit history for selection
-			history, err := g.Log("HEAD", 10, false, false)
+			history, err := g.Commits(git.CommitsOptions{Range: "HEAD", Limit: 10})
 			if err != nil {
 				return fmt.Errorf("failed to get commit history: %w", err)
 			}
 
-			// Parse commit history
-			commits := make([]string, 0)
-			for _, line := range strings.Split(strings.TrimSpace(history), "\n") {
-				parts := strings.Split(line, "\x00")
-				if len(parts) >= 4 {
-					commits = append(commits, fmt.Sprintf("%s %s", parts[0][:8], parts[3]))
-				}
+			commits := make([]string, 0, len(history))
+			for _, c := range history {
+				commits = append(commits, fmt.Sprintf("%s %s", c.Hash[:8], c.Subject))
 			}
 
 			if len(commits) == 0 {
@@ -49,12 +45,8 @@ Otherwise, squashes from the specified commit or prompts for selection.`,
 			}
 
 			// Prompt for commit selection
-			var selected string
-			prompt := &survey.Select{
-				Message: "Select commit to squash from:",
-				Options: commits,
-			}
-			if err := survey.AskOne(prompt, &selected); err != nil {
+			selected, err := ui.AskSelect("Select commit to squash from:", commits, "", "name it: sage squash <commit>, or pass --all")
+			if err != nil {
 				return err
 			}
 
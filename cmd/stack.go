@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/stack"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stackDraft         bool
+	stackMergeMethod   string
+	stackMergeContinue bool
+	stackMergeAbort    bool
+	stackMergeTimeout  time.Duration
+)
+
+var stackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Work with stacks of dependent branches",
+	Long: `Build a change as a stack of small branches, each on top of the one before,
+and review them as a chain of pull requests.
+
+'sage stack create' starts a branch on top of the current one. After changing
+a branch lower in the stack, 'sage stack sync' rebases everything above it,
+and 'sage stack submit' pushes the stack and points each PR at the branch
+below it. Once the stack is approved, 'sage stack merge' merges it bottom up.
+Stacks are recorded in .git/.sage/stack.json.`,
+}
+
+var stackCreateCmd = &cobra.Command{
+	Use:         "create <branch>",
+	Short:       "Create a branch stacked on the current one",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Args:        cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		parent, err := g.CurrentBranch()
+		if err != nil {
+			return err
+		}
+		if err := app.StackCreate(g, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("%s Created %s on top of %s\n", ui.Green("✓"), ui.Blue(args[0]), ui.Blue(parent))
+		return nil
+	},
+}
+
+var stackListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Show your stacks as trees",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		st, err := stack.Load(g)
+		if err != nil {
+			return err
+		}
+		roots := st.Roots()
+		if len(roots) == 0 {
+			fmt.Println(ui.Gray("No stacks yet. Start one with 'sage stack create <branch>'."))
+			return nil
+		}
+		current, _ := g.CurrentBranch()
+		for _, root := range roots {
+			fmt.Println(stackLabel(g, root, current))
+			printStackChildren(g, st, root, current, "")
+		}
+		return nil
+	},
+}
+
+// printStackChildren prints the branches stacked on branch as a tree
+func printStackChildren(g git.Service, st *stack.Stack, branch, current, prefix string) {
+	children := st.Children(branch)
+	for i, child := range children {
+		connector, next := "├── ", "│   "
+		if i == len(children)-1 {
+			connector, next = "└── ", "    "
+		}
+		fmt.Println(ui.Gray(prefix+connector) + stackLabel(g, child, current))
+		printStackChildren(g, st, child, current, prefix+next)
+	}
+}
+
+// stackLabel names a branch in the tree, with its PR when sage knows it
+func stackLabel(g git.Service, branch, current string) string {
+	label := ui.White(branch)
+	if branch == current {
+		label = ui.Green(branch + " *")
+	}
+	if pr, err := app.GetCachedPR(g, branch); err == nil && pr != nil {
+		label += " " + ui.Gray(fmt.Sprintf("#%d %s", pr.Number, pr.State))
+	}
+	return label
+}
+
+var stackSyncCmd = &cobra.Command{
+	Use:         "sync",
+	Short:       "Rebase each branch of the current stack onto its parent",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Rebase every branch in the current stack whose parent has moved, bottom to
+top, so each branch again sits on the latest version of the one below it.
+A branch whose parent was deleted, for example after it was merged, moves
+onto the next branch down.
+
+Update the bottom branch (usually with 'sage sync' on it) first to bring the
+whole stack up to date with it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		results, err := app.StackSync(g)
+		for _, r := range results {
+			if r.Reparent != "" {
+				fmt.Printf("%s %s moved from %s (gone) onto %s\n", ui.Yellow("!"), r.Branch, r.Reparent, r.Parent)
+			}
+			if r.Rebased {
+				fmt.Printf("%s Restacked %s onto %s\n", ui.Green("✓"), ui.Blue(r.Branch), r.Parent)
+			} else {
+				fmt.Printf("%s %s is up to date with %s\n", ui.Gray("•"), r.Branch, r.Parent)
+			}
+		}
+		return err
+	},
+}
+
+var stackSubmitCmd = &cobra.Command{
+	Use:         "submit",
+	Short:       "Push the current stack and open or update a PR for each branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		results, err := app.StackSubmit(g, forgeClient(), stackDraft)
+		for _, r := range results {
+			switch {
+			case r.Created:
+				fmt.Printf("%s Created PR #%d for %s: %s\n", ui.Green("✓"), r.PR.Number, ui.Blue(r.Branch), r.PR.HTMLURL)
+			case r.Retargeted:
+				fmt.Printf("%s PR #%d now targets %s\n", ui.Green("✓"), r.PR.Number, r.PR.Base.Ref)
+			default:
+				fmt.Printf("%s PR #%d for %s is up to date\n", ui.Gray("•"), r.PR.Number, r.Branch)
+			}
+		}
+		return err
+	},
+}
+
+var stackMergeCmd = &cobra.Command{
+	Use:         "merge [branch]",
+	Short:       "Merge the PRs of a stack bottom up, restacking as it goes",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Merge the pull requests of the current branch (or the given one) and every
+branch below it in its stack, starting at the bottom.
+
+For each branch, sage points its PR at the branch the stack is built on,
+waits for its checks to pass, and merges it. The branches above are then
+restacked onto the updated base and pushed, their PRs are retargeted, and the
+merged branch is deleted locally and on origin.
+
+Progress is saved in .git/.sage/stack-merge.json. When a check fails, a
+restack conflicts or GitHub refuses a merge, sage stops; fix the problem and
+run 'sage stack merge --continue', or '--abort' to stop for good (what was
+merged stays merged).`,
+	Example: `  sage stack merge
+  sage stack merge --method squash
+  sage stack merge --continue`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g := git.NewShellGit()
+		if stackMergeAbort {
+			if err := app.AbortStackMerge(g); err != nil {
+				return err
+			}
+			fmt.Printf("%s Stopped merging the stack; merged PRs stay merged\n", ui.Green("✓"))
+			return nil
+		}
+
+		ghc := githubClient()
+		var m *app.StackMerge
+		var err error
+		if stackMergeContinue {
+			if m, err = app.LoadStackMerge(g); err != nil {
+				return err
+			}
+			if m == nil {
+				return fmt.Errorf("no stack merge in progress")
+			}
+		} else {
+			var branch string
+			if len(args) == 1 {
+				branch = args[0]
+			}
+			method := stackMergeMethod
+			if !cmd.Flags().Changed("method") {
+				if v := config.Get("git.merge_method", true); v != "" {
+					method = v
+				}
+			}
+			if m, err = app.StartStackMerge(g, ghc, branch, method); err != nil {
+				return err
+			}
+			fmt.Printf("%s Merging %d PRs into %s: %s\n", ui.Sage("ℹ"), len(m.Branches), ui.Blue(m.Root), strings.Join(m.Branches, " → "))
+		}
+
+		opts := app.StackMergeOptions{Timeout: stackMergeTimeout, Poll: 15 * time.Second}
+		err = app.RunStackMerge(g, ghc, m, opts, func(step string) {
+			fmt.Printf("%s %s\n", ui.Green("✓"), step)
+		})
+		printStackMergeReport(m)
+		if err != nil {
+			return fmt.Errorf("%w\nProgress is saved; 'sage stack merge --continue' carries on", err)
+		}
+		return nil
+	},
+}
+
+// printStackMergeReport lists what a stack merge has merged and deleted so far
+func printStackMergeReport(m *app.StackMerge) {
+	if len(m.Merged) == 0 {
+		return
+	}
+	fmt.Printf("\n%s\n", ui.Bold("Merged:"))
+	for _, pr := range m.Merged {
+		fmt.Printf("  #%d %s %s\n", pr.Number, ui.Blue(pr.Branch), ui.Gray(pr.URL))
+	}
+	if len(m.Deleted) > 0 {
+		fmt.Printf("%s %s\n", ui.Bold("Deleted branches:"), strings.Join(m.Deleted, ", "))
+	}
+	if left := m.Branches[m.Next:]; len(left) > 0 {
+		fmt.Printf("%s %s\n", ui.Bold("Still to merge:"), strings.Join(left, ", "))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(stackCmd)
+	stackCmd.AddCommand(stackCreateCmd)
+	stackCmd.AddCommand(stackListCmd)
+	stackCmd.AddCommand(stackSyncCmd)
+	stackCmd.AddCommand(stackSubmitCmd)
+	stackSubmitCmd.Flags().BoolVar(&stackDraft, "draft", false, "Open new PRs as drafts")
+	stackCmd.AddCommand(stackMergeCmd)
+	stackMergeCmd.Flags().StringVarP(&stackMergeMethod, "method", "m", "merge", "Merge method: merge, squash, or rebase (default git.merge_method)")
+	stackMergeCmd.Flags().BoolVar(&stackMergeContinue, "continue", false, "Carry on with a stack merge that stopped")
+	stackMergeCmd.Flags().BoolVar(&stackMergeAbort, "abort", false, "Forget a stack merge that stopped")
+	stackMergeCmd.Flags().DurationVar(&stackMergeTimeout, "timeout", 30*time.Minute, "How long to wait for each PR's checks")
+	stackMergeCmd.MarkFlagsMutuallyExclusive("continue", "abort")
+}
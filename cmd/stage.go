@@ -2,25 +2,36 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/crazywolf132/sage/internal/ui/hunks"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
 	stageAll      bool
 	stagePatterns []string
 	stageAI       bool
+	stagePatch    bool
 )
 
 var stageCmd = &cobra.Command{
-	Use:   "stage [patterns...]",
-	Short: "Stage files for commit",
+	Use:         "stage [patterns...]",
+	Short:       "Stage files for commit",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Stage files for commit. Without arguments, shows an interactive file selector.
 With patterns, stages all files matching the glob patterns.
 
+With --patch, shows every unstaged hunk of the matching files instead, with
+syntax highlighting. Pick hunks with space or y/n, split hunks shown together
+with s, and press l to pick single lines of a hunk. Enter stages what was
+picked; the rest of each file is left unstaged.
+
 After staging files, use 'sage commit --only-staged' to commit only the staged changes.
 
 Examples:
@@ -33,6 +44,10 @@ Examples:
   # Stage specific files by pattern
   sage stage "*.go" "cmd/*.go"
 
+  # Pick hunks, or single lines, of the changes to stage
+  sage stage --patch
+  sage stage --patch "*.go"
+
   # Stage everything
   sage stage -a
   sage stage --all`,
@@ -41,7 +56,7 @@ Examples:
 
 		// If --all flag is provided, stage everything
 		if stageAll {
-			return g.StageAll()
+			return app.StageAll(g)
 		}
 
 		// If patterns are provided as arguments, use them
@@ -49,7 +64,15 @@ Examples:
 			stagePatterns = args
 		}
 
-		err := app.StageFiles(g, stagePatterns, stageAI)
+		var err error
+		if stagePatch {
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				return exitcode.Errorf(exitcode.Usage, "picking hunks needs a terminal; pass paths or patterns to stage whole files")
+			}
+			err = app.StageHunks(g, stagePatterns, hunks.Pick)
+		} else {
+			err = app.StageFiles(g, stagePatterns, stageAI)
+		}
 		if err == nil {
 			// If staging was successful, remind about committing with --only-staged
 			fmt.Printf("\nTip: Use %s to commit only these staged changes\n", ui.Blue("sage commit --only-staged"))
@@ -63,4 +86,7 @@ func init() {
 	stageCmd.Flags().BoolVarP(&stageAll, "all", "a", false, "Stage all changes")
 	stageCmd.Flags().StringSliceVarP(&stagePatterns, "pattern", "p", nil, "Glob patterns to match files to stage")
 	stageCmd.Flags().BoolVar(&stageAI, "ai", false, "Use AI to group changes by functionality")
+	stageCmd.Flags().BoolVar(&stagePatch, "patch", false, "Pick hunks or single lines to stage")
+	stageCmd.MarkFlagsMutuallyExclusive("patch", "ai")
+	stageCmd.MarkFlagsMutuallyExclusive("patch", "all")
 }
@@ -10,12 +10,14 @@ import (
 )
 
 var (
-	startNoPush bool
+	startNoPush  bool
+	startNoFetch bool
 )
 
 var startCmd = &cobra.Command{
-	Use:   "start <branch>",
-	Short: "Create & switch to a new branch from default branch",
+	Use:         "start <branch>",
+	Short:       "Create & switch to a new branch from default branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Create a new branch from the default branch (usually main), switch to it, and push it to remote.
 	
 Examples:
@@ -23,12 +25,22 @@ Examples:
   sage start feature/awesome
 
   # Create a new branch without pushing
-  sage start feature/local --no-push`,
+  sage start feature/local --no-push
+
+  # Branch from the local default branch without fetching
+  sage start feature/offline --no-fetch
+
+Before branching, sage fetches just the default branch from origin. Set
+start.fetch to 'full' to fetch every remote instead, or 'none' to never fetch.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		newBranch := args[0]
 		g := git.NewShellGit()
-		if err := app.StartBranch(g, newBranch, !startNoPush); err != nil {
+		fetch := app.StartFetchMode()
+		if startNoFetch {
+			fetch = app.StartFetchNone
+		}
+		if err := app.StartBranch(g, newBranch, !startNoPush, fetch); err != nil {
 			return err
 		}
 		fmt.Printf("%s Created & switched to '%s'\n", ui.Green("✓"), newBranch)
@@ -42,4 +54,5 @@ Examples:
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.Flags().BoolVar(&startNoPush, "no-push", false, "Don't push branch after creation")
+	startCmd.Flags().BoolVar(&startNoFetch, "no-fetch", false, "Branch from the local default branch without fetching")
 }
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/crazywolf132/sage/internal/app"
 	"github.com/crazywolf132/sage/internal/git"
@@ -10,79 +12,180 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusRefresh          bool
+	statusOutput           string
+	statusCommitSubmodules bool
+)
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show repository status",
+	Long: `Show an overview of the current branch: changes grouped by staged and
+unstaged, how far it is ahead of or behind its upstream, any merge or rebase
+in progress, and its pull request.
+
+Submodules checked out at a commit other than the one recorded are listed
+too; --commit-submodules records the commits they're at in a commit of
+their own.
+
+PR state comes from sage's local cache so status stays fast and offline;
+use --refresh to ask GitHub for the latest.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
-		st, err := app.GetRepoStatus(g)
+		if statusRefresh {
+			if ghc := optionalGitHubClient(); ghc != nil {
+				if branch, err := g.CurrentBranch(); err == nil {
+					if _, err := app.RefreshCachedPR(g, ghc, branch); err != nil {
+						fmt.Fprintf(os.Stderr, "%s Could not refresh PR: %v\n", ui.Yellow("!"), err)
+					}
+				}
+			}
+		}
+
+		if statusCommitSubmodules {
+			bumps, err := app.CommitSubmoduleBumps(g)
+			if err != nil {
+				return err
+			}
+			if len(bumps) == 0 {
+				ui.Info("No submodule has moved from its recorded commit")
+			} else {
+				ui.Success(fmt.Sprintf("Committed the bump of %d submodule%s", len(bumps), ui.Plural(len(bumps))))
+			}
+		}
+
+		ov, err := app.GetStatusOverview(g)
 		if err != nil {
 			return err
 		}
+		if ok, err := printStructured(statusOutput, ov); ok || err != nil {
+			return err
+		}
+		renderStatus(ov)
+		return nil
+	},
+}
 
-		// Header with branch info
-		fmt.Printf("\n%s Repository Status\n", ui.Bold(ui.Sage("📊")))
-		fmt.Printf("\n%s %s\n", ui.Bold("Branch:"), ui.Yellow(st.Branch))
+// renderStatus prints a status overview
+func renderStatus(ov *app.StatusOverview) {
+	fmt.Printf("\n%s Repository Status\n", ui.Bold(ui.Sage("📊")))
+	fmt.Printf("\n%s %s", ui.Bold("Branch:"), ui.Yellow(ov.Branch))
+	switch {
+	case ov.Upstream == "":
+		fmt.Printf(" %s\n", ui.Gray("(no upstream)"))
+	case ov.Ahead == 0 && ov.Behind == 0:
+		fmt.Printf(" %s\n", ui.Gray("(up to date with "+ov.Upstream+")"))
+	default:
+		fmt.Printf(" %s\n", ui.Gray(fmt.Sprintf("(↑%d ↓%d %s)", ov.Ahead, ov.Behind, ov.Upstream)))
+	}
 
-		// Clean state
-		if len(st.Changes) == 0 {
-			fmt.Printf("\n%s %s\n\n", ui.Green("✓"), ui.Bold("Working directory is clean"))
-			return nil
+	if ov.PR != nil {
+		fmt.Printf("%s #%d %s %s %s\n", ui.Bold("PR:"), ov.PR.Number, ov.PR.Title, cachedPRState(ov.PR),
+			ui.Gray("(checked "+formatAge(time.Since(ov.PR.CheckedAt))+")"))
+	}
+
+	if ov.Operation != "" {
+		fmt.Printf("\n%s %s in progress", ui.Yellow("⚠"), ui.Bold(ov.Operation))
+		if len(ov.Conflicts) > 0 {
+			fmt.Printf(" with %d conflicted file%s", len(ov.Conflicts), ui.Plural(len(ov.Conflicts)))
 		}
+		fmt.Printf(" %s\n", ui.Gray(fmt.Sprintf("(resolve, then 'sage resolve' or '%s')", ov.Continue)))
+	}
 
-		// Group changes by type
-		staged := make([]app.FileChange, 0)
-		unstaged := make([]app.FileChange, 0)
-		untracked := make([]app.FileChange, 0)
+	printSubmodules(ov.Submodules)
 
-		for _, c := range st.Changes {
-			if c.Symbol == "?" {
-				untracked = append(untracked, c)
-				continue
-			}
+	if len(ov.Changes) == 0 {
+		fmt.Printf("\n%s %s\n\n", ui.Green("✓"), ui.Bold("Working directory is clean"))
+		return
+	}
 
-			// Check if the change is staged, unstaged, or both
-			if strings.HasPrefix(c.Description, "Unstaged") {
-				unstaged = append(unstaged, c)
-			} else if strings.HasPrefix(c.Description, "Staged+Unstaged") {
-				// For files that are both staged and unstaged, show in both sections
-				staged = append(staged, c)
-				unstaged = append(unstaged, c)
-			} else if strings.HasPrefix(c.Description, "Staged") {
-				staged = append(staged, c)
-			} else {
-				// If no clear prefix, assume it's unstaged
-				unstaged = append(unstaged, c)
-			}
-		}
+	printChanges(ui.Bold(ui.Red("Conflicts:")), ov.Conflicts)
+	printChanges(ui.Bold(ui.Sage("Staged Changes:")), ov.Staged)
+	printChanges(ui.Bold(ui.Yellow("Changes not staged:")), ov.Unstaged)
+	printChanges(ui.Bold(ui.Blue("Untracked files:")), ov.Untracked)
+	printClashes(ov)
+	fmt.Println()
+}
 
-		// Print changes by section
-		if len(staged) > 0 {
-			fmt.Printf("\n%s\n", ui.Bold(ui.Sage("Staged Changes:")))
-			for _, c := range staged {
-				symbol := getSymbolEmoji(c.Symbol)
-				fmt.Printf("  %s %s\n", symbol, ui.White(c.File))
-			}
+// printSubmodules lists the submodules that drifted from their recorded
+// commit or conflict, and counts the ones not checked out
+func printSubmodules(subs []app.SubmoduleState) {
+	uninitialized, listed := 0, false
+	for _, s := range subs {
+		if s.State == "uninitialized" {
+			uninitialized++
+			continue
 		}
-
-		if len(unstaged) > 0 {
-			fmt.Printf("\n%s\n", ui.Bold(ui.Yellow("Changes not staged:")))
-			for _, c := range unstaged {
-				symbol := getSymbolEmoji(c.Symbol)
-				fmt.Printf("  %s %s\n", symbol, ui.White(c.File))
-			}
+		if !listed {
+			fmt.Printf("\n%s\n", ui.Bold(ui.Yellow("Submodules:")))
+			listed = true
+		}
+		what := fmt.Sprintf("at %s, %s recorded", shortRef(s.Current), shortRef(s.Recorded))
+		if s.State == "conflicted" {
+			what = "conflicted; check out the commit to keep and stage it"
 		}
+		fmt.Printf("  %s %s %s\n", ui.Yellow("↻"), ui.White(s.Path), ui.Gray(what))
+	}
+	if listed {
+		fmt.Printf("  %s\n", ui.Gray("Record the new commits with 'sage status --commit-submodules', or go back with 'git submodule update'"))
+	}
+	if uninitialized > 0 {
+		fmt.Printf("\n%s %s\n", ui.Gray("○"), ui.Gray(fmt.Sprintf("%d submodule%s not checked out ('git submodule update --init' fetches them)", uninitialized, ui.Plural(uninitialized))))
+	}
+}
 
-		if len(untracked) > 0 {
-			fmt.Printf("\n%s\n", ui.Bold(ui.Blue("Untracked files:")))
-			for _, c := range untracked {
-				fmt.Printf("  %s %s\n", "📄", ui.White(c.File))
-			}
+// printClashes warns about paths that only differ in case or unicode form,
+// usually a rename the filesystem didn't let git see
+func printClashes(ov *app.StatusOverview) {
+	for _, c := range ov.Clashes {
+		what := "differ only in case"
+		if c.Kind == app.ClashUnicode {
+			what = "differ only in unicode normalization"
 		}
+		fmt.Printf("\n%s %s %s\n", ui.Yellow("⚠"), strings.Join(c.Paths, " and "), what)
+		fmt.Printf("  %s\n", ui.Gray(fmt.Sprintf("If this is a rename, record it with 'sage mv %s %s'", c.Paths[0], c.Paths[1])))
+	}
+	if ov.IgnoreCase != "" {
+		fmt.Printf("\n%s %s\n", ui.Yellow("⚠"), ov.IgnoreCase)
+	}
+}
 
-		fmt.Println()
-		return nil
-	},
+func printChanges(heading string, changes []app.FileChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Printf("\n%s\n", heading)
+	for _, c := range changes {
+		fmt.Printf("  %s %s\n", getSymbolEmoji(c.Symbol), ui.White(c.File))
+	}
+}
+
+// formatAge renders how long ago something happened, e.g. "5m ago"
+// cachedPRState is a cached pull request's state, colored
+func cachedPRState(pr *app.CachedPR) string {
+	switch {
+	case pr.Merged:
+		return ui.Sage("merged")
+	case pr.Draft:
+		return ui.Gray("draft")
+	case pr.State == "open":
+		return ui.Green(pr.State)
+	}
+	return ui.Red(pr.State)
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
 }
 
 func getSymbolEmoji(symbol string) string {
@@ -95,6 +198,10 @@ func getSymbolEmoji(symbol string) string {
 		return "🗑️"
 	case "R":
 		return "📋"
+	case "U":
+		return "⚔️"
+	case "?":
+		return "📄"
 	default:
 		return "•"
 	}
@@ -102,4 +209,7 @@ func getSymbolEmoji(symbol string) string {
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusRefresh, "refresh", false, "Fetch the branch's PR state from GitHub before showing it")
+	statusCmd.Flags().BoolVar(&statusCommitSubmodules, "commit-submodules", false, "Commit the commits drifted submodules are checked out at")
+	addOutputFlag(statusCmd, &statusOutput)
 }
@@ -1,20 +1,20 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/crazywolf132/sage/internal/app"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/spf13/cobra"
-
-	"strings"
-
-	"github.com/AlecAivazis/survey/v2"
 )
 
 var switchCmd = &cobra.Command{
-	Use:     "switch [branch]",
-	Short:   "Switch to existing branch (or prompt)",
-	Args:    cobra.MaximumNArgs(1),
-	Aliases: []string{"sw", "checkout", "co"},
+	Use:         "switch [branch]",
+	Short:       "Switch to existing branch (or prompt)",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Args:        cobra.MaximumNArgs(1),
+	Aliases:     []string{"sw", "checkout", "co"},
 	Example: `  # Switch to a branch (with auto-completion)
   sage switch feature/awesome
 
@@ -37,13 +37,11 @@ var switchCmd = &cobra.Command{
 			if len(branches) == 0 {
 				return nil
 			}
-			err := survey.AskOne(&survey.Select{
-				Message: "Pick a branch:",
-				Options: branches,
-			}, &target)
+			picked, err := ui.AskSelect("Pick a branch:", branches, "", "name it: sage switch <branch>")
 			if err != nil {
 				return err
 			}
+			target = picked
 		}
 		return app.SwitchBranch(g, target)
 	},
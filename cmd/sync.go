@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/spf13/cobra"
 )
@@ -13,11 +14,15 @@ var (
 	syncDryRun   bool
 	syncVerbose  bool
 	syncTarget   string
+	syncPaths    []string
+	syncSparse   bool
+	syncTake     bool
 )
 
 var syncCmd = &cobra.Command{
-	Use:   "sync",
-	Short: "Synchronize your branch with the main branch",
+	Use:         "sync",
+	Short:       "Synchronize your branch with the main branch",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Synchronize your branch with updates from the parent branch.
 
 The 'sync' command intelligently pulls changes from the parent branch
@@ -29,7 +34,16 @@ and ensures your work stays up to date. It automatically:
 4. Resolves conflicts when possible
 
 By default, it uses the main branch as the parent branch, but you
-can specify any branch with the --target flag.`,
+can specify any branch with the --target flag.
+
+In a large monorepo, --paths limits sync to the directories you work on
+(globs such as services/*/api work too). It still fetches everything, but
+previews only the incoming commits that touch those paths. Conflicts
+anywhere else are listed, and you're asked whether to settle them by taking
+the parent branch's version, which drops your changes to those files; no,
+and runs without prompts, stop the sync as usual. --take-incoming says yes
+up front. --sparse uses the directories of a sparse checkout (cone mode)
+instead, trimming the checkout back after.`,
 	Example: `  # Sync with the main branch (default)
   sage sync
 
@@ -39,6 +53,10 @@ can specify any branch with the --target flag.`,
   # Sync without pushing changes
   sage sync --no-push
 
+  # Only care about your team's corner of a monorepo
+  sage sync --paths services/payments
+  sage sync --sparse --take-incoming
+
   # Resume after resolving conflicts
   sage sync --continue
 
@@ -47,6 +65,10 @@ can specify any branch with the --target flag.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		g := git.NewShellGit()
 
+		if !cmd.Flags().Changed("no-push") && config.Get("sync.push", true) == "false" {
+			syncNoPush = true
+		}
+
 		// Run sync with options
 		opts := app.SyncOptions{
 			TargetBranch: syncTarget,
@@ -55,6 +77,9 @@ can specify any branch with the --target flag.`,
 			Verbose:      syncVerbose,
 			Abort:        syncAbort,
 			Continue:     syncContinue,
+			Paths:        syncPaths,
+			Sparse:       syncSparse,
+			TakeIncoming: syncTake,
 		}
 
 		if err := app.SyncBranch(g, opts); err != nil {
@@ -76,9 +101,14 @@ func init() {
 	syncCmd.Flags().BoolVar(&syncNoPush, "no-push", false, "Skip pushing changes to remote")
 	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Preview sync operations without making changes")
 	syncCmd.Flags().BoolVar(&syncVerbose, "verbose", false, "Show detailed operation logs")
+	syncCmd.Flags().StringSliceVar(&syncPaths, "paths", nil, "Only preview the incoming commits that touch these paths")
+	syncCmd.Flags().BoolVar(&syncSparse, "sparse", false, "Scope the sync to the sparse checkout's directories")
+	syncCmd.Flags().BoolVar(&syncTake, "take-incoming", false, "Settle conflicts outside --paths or --sparse with the parent's version, dropping your changes to those files")
 
 	// Make certain flags mutually exclusive
 	syncCmd.MarkFlagsMutuallyExclusive("abort", "continue")
 	syncCmd.MarkFlagsMutuallyExclusive("dry-run", "continue")
 	syncCmd.MarkFlagsMutuallyExclusive("dry-run", "abort")
+	syncCmd.MarkFlagsMutuallyExclusive("paths", "abort")
+	syncCmd.MarkFlagsMutuallyExclusive("sparse", "abort")
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/app"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/crazywolf132/sage/internal/undo"
@@ -13,26 +14,55 @@ import (
 )
 
 var (
-	undoID      string
-	showHistory bool
+	undoID          string
+	showHistory     bool
+	undoInteractive bool
+	undoListGraph   bool
 )
 
 var undoCmd = &cobra.Command{
-	Use:   "undo",
-	Short: "Undo your last Git operation",
+	Use:         "undo [operation-id]",
+	Short:       "Undo your last Git operation",
+	Annotations: map[string]string{mutatingAnnotation: ""},
 	Long: `Undo your last Git operation safely.
 
 Just run 'sage undo' and we'll help you fix your last Git operation.
-No need to remember complex Git commands - we'll handle it for you!`,
+No need to remember complex Git commands - we'll handle it for you!
+
+Before commit, wip, sync, squash and clean change anything, sage snapshots
+HEAD, the index, uncommitted changes, the stash and every branch tip.
+'sage undo --interactive' shows that timeline and restores any point in it,
+and recovers deleted branches from the reflog.
+
+Give an operation's ID (from 'sage undo list') to undo that one even with
+later operations on top of it. A commit nothing came after is reset away;
+otherwise a revert commit undoes it and leaves the later work in place.
+Operations others build on, such as a deploy deployed over since, are
+refused.`,
 	Example: `  # Fix your last Git operation
   sage undo
 
+  # Undo one operation from further back
+  sage undo list --graph
+  sage undo 3f2a1b0c
+
   # See what you can undo
-  sage undo --history`,
+  sage undo --history
+
+  # Pick any earlier point to go back to, or a deleted branch to recover
+  sage undo --interactive`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		spinner := ui.NewSpinner()
 		g := git.NewShellGit()
 		s := undo.NewService(g)
+		if len(args) == 1 {
+			undoID = args[0]
+		}
+
+		if undoInteractive {
+			return handleJournalUndo(g)
+		}
 
 		// Load history
 		spinner.Start("Looking up your recent Git operations...")
@@ -59,22 +89,155 @@ No need to remember complex Git commands - we'll handle it for you!`,
 
 		// Handle undo by ID
 		if undoID != "" {
-			return handleUndoByID(s, ops, undoID)
+			return handleUndoByID(g, s, undoID)
 		}
 
 		// Interactive mode - show last operation with clear preview
-		return handleInteractiveUndo(s, ops)
+		return handleInteractiveUndo(g, ops)
+	},
+}
+
+var undoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded operations and how each would be undone",
+	Long: `List the recorded operations, newest first, with how 'sage undo <id>'
+would undo each: resetting it away, reverting it with a new commit, or not
+at all while later operations build on it.
+
+With --graph, show which operations build on which: later commits on the
+same branch that change the same files, reverts, and deploys made over
+earlier ones.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := undo.NewService(git.NewShellGit())
+		if err := s.LoadHistory("."); err != nil {
+			return fmt.Errorf("couldn't load Git history: %w", err)
+		}
+		all := s.AnalyzeAll()
+		if len(all) == 0 {
+			fmt.Printf("\n%s Nothing to undo yet\n\n", ui.Yellow("!"))
+			return nil
+		}
+		if undoListGraph {
+			showUndoGraph(all, s.Dependencies())
+			return nil
+		}
+		fmt.Println()
+		for _, a := range all {
+			fmt.Printf("%s  %-40s %s\n", ui.Yellow(a.Op.ID[:8]), truncate(a.Op.Description, 40), ui.Gray(formatTimestamp(a.Op.Timestamp)))
+			fmt.Printf("          %s\n", describeReversal(a))
+		}
+		fmt.Println()
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(undoCmd)
+	undoCmd.AddCommand(undoListCmd)
+	undoListCmd.Flags().BoolVar(&undoListGraph, "graph", false, "Show which operations build on which")
 	undoCmd.Flags().StringVarP(&undoID, "id", "i", "", "Undo a specific operation by ID")
 	undoCmd.Flags().BoolVarP(&showHistory, "history", "H", false, "See what you can undo")
+	undoCmd.Flags().BoolVar(&undoInteractive, "interactive", false, "Pick any snapshot from the timeline to restore, or a deleted branch to recover")
+}
+
+func handleJournalUndo(g git.Service) error {
+	j := undo.NewJournal(g)
+	if err := j.Load(); err != nil {
+		return fmt.Errorf("couldn't load the undo journal: %w", err)
+	}
+	deleted, err := j.FindDeletedBranches()
+	if err != nil {
+		return err
+	}
+	if len(j.Snapshots) == 0 && len(deleted) == 0 {
+		fmt.Printf("\n%s Nothing to go back to yet\n\n", ui.Yellow("!"))
+		return nil
+	}
+
+	fmt.Printf("\n%s\n\n", ui.Bold("Timeline"))
+	var options []string
+	for _, snap := range j.Snapshots {
+		options = append(options, describeSnapshot(snap))
+	}
+	for _, b := range deleted {
+		options = append(options, fmt.Sprintf("Recover deleted branch %s (%s)", b.Name, shortRef(b.Commit)))
+	}
+
+	choice, err := ui.AskSelectIndex("Restore the repository to the state before:", options,
+		"undo one operation with 'sage undo <id>' instead", survey.WithPageSize(15))
+	if err != nil {
+		return err
+	}
+
+	if choice >= len(j.Snapshots) {
+		b := deleted[choice-len(j.Snapshots)]
+		if err := j.RecoverBranch(b); err != nil {
+			return err
+		}
+		fmt.Printf("\n%s Recovered %s at %s\n", ui.Green("✓"), b.Name, shortRef(b.Commit))
+		fmt.Printf("\nTip: Run %s to switch to it\n\n", ui.Blue("sage switch "+b.Name))
+		return nil
+	}
+
+	snap := j.Snapshots[choice]
+	fmt.Printf("\n%s\n", ui.Bold("Here's what we'll do:"))
+	if snap.Branch != "" {
+		fmt.Printf("• Check out %s at %s\n", snap.Branch, shortRef(snap.Head))
+	} else {
+		fmt.Printf("• Check out %s (detached)\n", shortRef(snap.Head))
+	}
+	fmt.Printf("• Move branches back to where they were, recreating deleted ones\n")
+	if snap.WorkTree != "" {
+		fmt.Printf("• Bring back the uncommitted changes you had\n")
+	}
+	fmt.Printf("• Snapshot the current state first, so you can come back to it\n\n")
+
+	proceed, err := ui.AskConfirm("Restore this point?", true)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		fmt.Printf("\n%s Operation cancelled. Your repository is unchanged.\n\n", ui.Yellow("!"))
+		return nil
+	}
+
+	spinner := ui.NewSpinner()
+	spinner.Start("Restoring...")
+	if err := app.RestoreSnapshot(g, j, snap); err != nil {
+		spinner.StopFail()
+		return fmt.Errorf("couldn't restore: %w", err)
+	}
+	spinner.StopSuccess()
+	fmt.Printf("\n%s Restored the state before %s\n\n", ui.Green("✓"), snap.Command)
+	return nil
 }
 
-func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
-	lastOp := ops[0] // Most recent operation
+func describeSnapshot(snap undo.Snapshot) string {
+	where := shortRef(snap.Head)
+	if snap.Branch != "" {
+		where = snap.Branch + " @ " + where
+	}
+	desc := fmt.Sprintf("%-16s %-24s %s", formatTimestamp(snap.Timestamp), snap.Command, where)
+	if snap.WorkTree != "" {
+		desc += " (+ uncommitted changes)"
+	}
+	return desc
+}
+
+func handleInteractiveUndo(g git.Service, ops []undo.Operation) error {
+	// Most recent operation that hasn't been undone
+	var lastOp undo.Operation
+	for _, op := range ops {
+		if !op.Undone {
+			lastOp = op
+			break
+		}
+	}
+	if lastOp.ID == "" {
+		fmt.Printf("\n%s Everything recorded has been undone already\n\n", ui.Yellow("!"))
+		return nil
+	}
 
 	// Header
 	fmt.Printf("\n%s\n\n", ui.Bold("Let's undo your last Git operation"))
@@ -93,6 +256,8 @@ func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
 		if lastOp.Metadata.Branch != "" {
 			fmt.Printf("%s %s\n", ui.Yellow("On branch:"), lastOp.Metadata.Branch)
 		}
+	case "deploy":
+		fmt.Printf("%s %s/%s\n", ui.Yellow("Target:"), lastOp.Metadata.Extra["remote"], lastOp.Metadata.Branch)
 	}
 	fmt.Println()
 
@@ -115,6 +280,10 @@ func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
 		fmt.Printf("1. Restore your deleted branch\n")
 		fmt.Printf("2. Recover all associated commits\n")
 		fmt.Printf("3. Keep your current branch unchanged\n")
+	case "deploy":
+		fmt.Printf("1. Move the deploy branch back to its previous commit\n")
+		fmt.Printf("2. Push it with a lease, so newer deploys are never overwritten\n")
+		fmt.Printf("3. Leave your local branches unchanged\n")
 	}
 	fmt.Println()
 
@@ -126,12 +295,8 @@ func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
 	fmt.Println()
 
 	// Ask for confirmation
-	var proceed bool
-	prompt := &survey.Confirm{
-		Message: "Ready to undo?",
-		Default: true,
-	}
-	if err := survey.AskOne(prompt, &proceed); err != nil {
+	proceed, err := ui.AskConfirm("Ready to undo?", true)
+	if err != nil {
 		return err
 	}
 	if !proceed {
@@ -143,7 +308,7 @@ func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
 	spinner := ui.NewSpinner()
 	spinner.Start("Undoing last operation safely...")
 
-	if err := s.UndoOperation(lastOp.ID); err != nil {
+	if _, err := app.UndoByID(g, lastOp.ID); err != nil {
 		spinner.StopFail()
 		return fmt.Errorf("couldn't undo the operation: %v", err)
 	}
@@ -177,21 +342,25 @@ func handleInteractiveUndo(s *undo.Service, ops []undo.Operation) error {
 		fmt.Printf("\n%s Try these commands:\n", ui.Bold("Next Steps"))
 		fmt.Printf("• %s to switch to the restored branch\n", ui.Blue("sage switch <branch>"))
 		fmt.Printf("• %s to see all branches\n", ui.Blue("git branch"))
+	case "deploy":
+		fmt.Printf("• The deploy branch was rolled back\n")
+		fmt.Printf("\n%s Try these commands:\n", ui.Bold("Next Steps"))
+		fmt.Printf("• %s to deploy again when ready\n", ui.Blue("sage deploy <target>"))
 	}
 	fmt.Println()
 
 	return nil
 }
 
-func handleUndoByID(s *undo.Service, ops []undo.Operation, id string) error {
-	// Find the operation
-	op := findOperationByID(ops, id)
-	if op.ID == "" {
-		fmt.Printf("\n%s Couldn't find that operation\n", ui.Yellow("!"))
-		fmt.Printf("\nTip: Use 'sage undo --history' to see available operations\n")
+func handleUndoByID(g git.Service, s *undo.Service, id string) error {
+	op, err := s.Find(id)
+	if err != nil {
+		fmt.Printf("\n%s %v\n", ui.Yellow("!"), err)
+		fmt.Printf("\nTip: Use 'sage undo list' to see available operations\n")
 		fmt.Printf("Or just run 'sage undo' to fix your last operation\n\n")
 		return nil
 	}
+	a := s.Analyze(op)
 
 	// Show what we found
 	fmt.Printf("\n%s Found this operation:\n\n", ui.Bold("Undo"))
@@ -200,15 +369,21 @@ func handleUndoByID(s *undo.Service, ops []undo.Operation, id string) error {
 	if op.Metadata.Branch != "" {
 		fmt.Printf("%s %s\n", ui.Yellow("Branch:"), op.Metadata.Branch)
 	}
+	fmt.Printf("%s %s\n", ui.Yellow("How:"), describeReversal(a))
+	for _, dep := range a.Dependents {
+		fmt.Printf("  %s %s %s\n", ui.Gray("built on by"), dep.ID[:8], dep.Description)
+	}
+	if len(a.Overlapping) > 0 {
+		fmt.Printf("  %s %s\n", ui.Gray("same files changed in"), strings.Join(a.Overlapping, ", "))
+	}
 	fmt.Println()
+	if a.Reversal == undo.ReversalBlocked {
+		return fmt.Errorf("can't undo %s on its own", op.ID[:8])
+	}
 
 	// Confirm
-	var proceed bool
-	prompt := &survey.Confirm{
-		Message: "Undo this operation?",
-		Default: true,
-	}
-	if err := survey.AskOne(prompt, &proceed); err != nil {
+	proceed, err := ui.AskConfirm("Undo this operation?", true)
+	if err != nil {
 		return err
 	}
 	if !proceed {
@@ -220,17 +395,92 @@ func handleUndoByID(s *undo.Service, ops []undo.Operation, id string) error {
 	spinner := ui.NewSpinner()
 	spinner.Start("Undoing operation safely...")
 
-	if err := s.UndoOperation(op.ID); err != nil {
+	if _, err := app.UndoByID(g, op.ID); err != nil {
 		spinner.StopFail()
 		return fmt.Errorf("couldn't undo the operation: %v", err)
 	}
 
 	spinner.StopSuccess()
 	fmt.Printf("\n%s Operation undone successfully!\n", ui.Green("✓"))
+	if a.Reversal == undo.ReversalRevert {
+		fmt.Printf("\nA revert commit undid it; 'sage undo' takes that back too.\n\n")
+		return nil
+	}
 	fmt.Printf("\nTip: Run 'git status' to see your repository's current state\n\n")
 	return nil
 }
 
+// describeReversal says how 'sage undo <id>' would undo an operation
+func describeReversal(a undo.Analysis) string {
+	switch a.Reversal {
+	case undo.ReversalReset:
+		return ui.Green("reset") + " " + ui.Gray(a.Reason)
+	case undo.ReversalRevert:
+		return ui.Blue("revert") + " " + ui.Gray(a.Reason)
+	case undo.ReversalDirect:
+		return ui.Green("undo") + " " + ui.Gray(a.Reason)
+	}
+	if a.Op.Undone {
+		return ui.Gray("undone")
+	}
+	return ui.Red("blocked") + " " + ui.Gray(a.Reason)
+}
+
+// showUndoGraph prints each operation nothing builds on, with what it
+// builds on beneath it
+func showUndoGraph(all []undo.Analysis, deps map[string][]string) {
+	byID := map[string]undo.Analysis{}
+	builtOn := map[string]bool{}
+	for _, a := range all {
+		byID[a.Op.ID] = a
+		for _, id := range deps[a.Op.ID] {
+			builtOn[id] = true
+		}
+	}
+
+	shown := map[string]bool{}
+	var walk func(a undo.Analysis, prefix, branch string)
+	walk = func(a undo.Analysis, prefix, branch string) {
+		line := fmt.Sprintf("%s  %s", ui.Yellow(a.Op.ID[:8]), truncate(a.Op.Description, 50))
+		if a.Op.Metadata.Branch != "" {
+			line += " " + ui.Gray("on "+a.Op.Metadata.Branch)
+		}
+		if shown[a.Op.ID] {
+			fmt.Printf("%s%s%s %s\n", prefix, branch, line, ui.Gray("(above)"))
+			return
+		}
+		shown[a.Op.ID] = true
+		fmt.Printf("%s%s%s  %s\n", prefix, branch, line, describeReversal(a))
+
+		switch branch {
+		case "├─ ":
+			prefix += "│  "
+		case "└─ ":
+			prefix += "   "
+		}
+		children := deps[a.Op.ID]
+		for i, id := range children {
+			child, ok := byID[id]
+			if !ok {
+				continue
+			}
+			if i == len(children)-1 {
+				walk(child, prefix, "└─ ")
+			} else {
+				walk(child, prefix, "├─ ")
+			}
+		}
+	}
+
+	fmt.Printf("\n%s\n\n", ui.Bold("Operations, newest first, over the ones they build on"))
+	for _, a := range all {
+		if !builtOn[a.Op.ID] {
+			walk(a, "", "")
+		}
+	}
+	fmt.Println()
+}
+
 func showUndoHistory(ops []undo.Operation) {
 	fmt.Printf("\n%s\n\n", ui.Bold("Recent Git Operations"))
 
@@ -239,7 +489,11 @@ func showUndoHistory(ops []undo.Operation) {
 			break
 		}
 
-		fmt.Printf("%s %s\n", ui.Yellow("•"), op.Description)
+		if op.Undone {
+			fmt.Printf("%s %s %s\n", ui.Yellow("•"), op.Description, ui.Gray("(undone)"))
+		} else {
+			fmt.Printf("%s %s\n", ui.Yellow("•"), op.Description)
+		}
 		fmt.Printf("  %s %s\n", ui.Gray("Type:"), strings.Title(op.Category))
 		fmt.Printf("  %s %s\n", ui.Gray("When:"), formatTimestamp(op.Timestamp))
 		fmt.Printf("  %s %s\n", ui.Gray("ID:"), op.ID[:8])
@@ -254,7 +508,7 @@ func showUndoHistory(ops []undo.Operation) {
 				}
 				fmt.Printf("  %s %s\n", ui.Gray("Files:"), strings.Join(files, ", "))
 			}
-		case "merge", "rebase":
+		case "merge", "rebase", "deploy":
 			if op.Metadata.Branch != "" {
 				fmt.Printf("  %s %s\n", ui.Gray("Branch:"), op.Metadata.Branch)
 			}
@@ -268,7 +522,8 @@ func showUndoHistory(ops []undo.Operation) {
 
 	fmt.Printf("\n%s\n", ui.Bold("How to undo:"))
 	fmt.Printf("1. Just run: %s to undo your last operation\n", ui.Blue("sage undo"))
-	fmt.Printf("2. Or undo a specific one: %s\n", ui.Blue("sage undo --id <ID>"))
+	fmt.Printf("2. Or undo a specific one: %s\n", ui.Blue("sage undo <ID>"))
+	fmt.Printf("3. See what builds on what: %s\n", ui.Blue("sage undo list --graph"))
 	fmt.Println()
 }
 
@@ -279,23 +534,24 @@ func formatTimestamp(t time.Time) string {
 		return "just now"
 	case duration < time.Hour:
 		mins := int(duration.Minutes())
-		return fmt.Sprintf("%d minute%s ago", mins, pluralize(mins))
+		return fmt.Sprintf("%d minute%s ago", mins, ui.Plural(mins))
 	case duration < 24*time.Hour:
 		hours := int(duration.Hours())
-		return fmt.Sprintf("%d hour%s ago", hours, pluralize(hours))
+		return fmt.Sprintf("%d hour%s ago", hours, ui.Plural(hours))
 	case duration < 7*24*time.Hour:
 		days := int(duration.Hours() / 24)
-		return fmt.Sprintf("%d day%s ago", days, pluralize(days))
+		return fmt.Sprintf("%d day%s ago", days, ui.Plural(days))
 	default:
 		return t.Format("Mon Jan 02 15:04:05")
 	}
 }
 
-func pluralize(n int) string {
-	if n == 1 {
-		return ""
+// truncate shortens s to n characters, ending it with an ellipsis
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
 	}
-	return "s"
+	return s
 }
 
 func findOperationByID(ops []undo.Operation, id string) undo.Operation {
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/crazywolf132/sage/internal/update"
+	"github.com/crazywolf132/sage/internal/version"
+	goversion "github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateVersion       string
+	updateChannel       string
+	updateSkipSignature bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update sage to the latest verified release",
+	Long: `Download a sage release and replace the running binary with it.
+
+The release archive is checked against the release's checksums.txt, and
+checksums.txt against its cosign signature, before anything is installed.
+Verifying the signature needs cosign. --insecure-skip-signature installs
+on the checksum alone, which a compromised release page could forge too.
+
+Releases come from the stable channel unless update.channel (or --channel)
+is beta, which includes prereleases. sage also looks for new releases in
+the background and mentions them at most once per update.check_interval
+(daily, weekly or never).`,
+	Example: `  sage update
+  sage update --channel beta
+  sage update --version 1.4.0`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := strings.TrimPrefix(updateVersion, "v")
+		if target == "" {
+			channel := updateChannel
+			if channel == "" {
+				var err error
+				if channel, err = update.Channel(); err != nil {
+					return err
+				}
+			} else if channel != update.ChannelStable && channel != update.ChannelBeta {
+				return exitcode.Errorf(exitcode.Usage, "--channel must be %s or %s", update.ChannelStable, update.ChannelBeta)
+			}
+			latest, err := update.LatestVersionOn(channel)
+			if err != nil {
+				return fmt.Errorf("failed to find the latest %s release: %w", channel, err)
+			}
+			target = latest
+		}
+
+		current := version.Get()
+		if cur, err := goversion.NewVersion(current); err == nil && updateVersion == "" {
+			if latest, err := goversion.NewVersion(target); err == nil && !latest.GreaterThan(cur) {
+				fmt.Printf("%s sage %s is the latest release\n", ui.Green("✓"), current)
+				return nil
+			}
+		}
+
+		rel, err := update.FetchRelease(target, updateSkipSignature)
+		if errors.Is(err, update.ErrUnverified) {
+			return fmt.Errorf("%w\nInstall cosign (https://docs.sigstore.dev/cosign/system_config/installation/) to verify it, or pass --insecure-skip-signature to trust the checksum alone", err)
+		}
+		if err != nil {
+			return err
+		}
+		if rel.Signed {
+			fmt.Printf("%s Verified signature of checksums.txt for %s\n", ui.Green("✓"), target)
+		} else {
+			ui.Warning("NOT verifying the signature of checksums.txt (--insecure-skip-signature): only its checksum vouches for this binary")
+		}
+
+		ok, err := ui.AskConfirm(fmt.Sprintf("Update sage %s → %s?", current, target), true)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(ui.Gray("Cancelled."))
+			return nil
+		}
+
+		binary, err := rel.DownloadBinary()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Checksum of %s matches the release\n", ui.Green("✓"), rel.Archive)
+
+		path, err := update.InstallBinary(binary)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Installed sage %s at %s\n", ui.Green("✓"), target, path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().StringVar(&updateVersion, "version", "", "Install a specific release instead of the latest")
+	updateCmd.Flags().StringVar(&updateChannel, "channel", "", "Release channel to update from: stable or beta (default update.channel)")
+	updateCmd.Flags().BoolVar(&updateSkipSignature, "insecure-skip-signature", false, "Install without verifying the release signature, when cosign isn't available")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/forge"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show which GitHub account sage uses here",
+	Long: `Show the GitHub repository sage talks to, the token it uses for it and the
+account that token belongs to, along with your stored accounts.
+
+Keep several accounts, such as work and personal, and pick one per owner or
+host:
+
+  sage config set github.accounts.work <token>
+  sage config set github.accounts.personal <token>
+  sage config set github.identity.acme work          # repositories owned by acme
+  sage config set github.identity.git.acme.dev work  # a GitHub Enterprise host
+  sage config set 'github.identity.*' personal       # everything else
+
+Organisations that don't allow personal access tokens can have sage
+authenticate as a GitHub App installation instead:
+
+  sage config set github.auth app
+  sage config set github.app.id 123456
+  sage config set github.app.installation_id 7890123
+  sage config set github.app.private_key ~/.config/sage/app.pem
+
+SAGE_GITHUB_TOKEN still beats every account, and the App.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := repoinfo.Default()
+		accounts := r.Accounts()
+
+		info, err := r.Repo()
+		if err != nil {
+			fmt.Printf("%s %s\n", ui.Yellow("!"), "No GitHub repository here")
+		} else {
+			token := r.TokenFor(info.Host, info.Owner)
+			whoamiRow("Repository", info.FullName()+" "+ui.Gray("on "+info.Host))
+			if token.Err != nil {
+				whoamiRow("Token", ui.Red(fmt.Sprintf("none from the %s: %v", token.Source, token.Err)))
+				return exitcode.Errorf(exitcode.Auth, "no GitHub token for %s", info.FullName())
+			}
+			if token.Value == "" {
+				whoamiRow("Token", ui.Red("none; set SAGE_GITHUB_TOKEN, store an account or run 'gh auth login'"))
+				return exitcode.Errorf(exitcode.Auth, "no GitHub token for %s", info.FullName())
+			}
+			whoamiRow("Token from", token.Source)
+			if !token.Expires.IsZero() {
+				// An installation token acts as the App, not as a user
+				whoamiRow("Expires", token.Expires.Local().Format("15:04:05"))
+			} else if forge.Type() == forge.GitHub {
+				login, err := githubClient().CurrentUser()
+				if err != nil {
+					return fmt.Errorf("failed to look up the token's account: %w", err)
+				}
+				whoamiRow("Logged in", ui.Bold(login))
+			}
+		}
+
+		if len(accounts.Tokens) == 0 {
+			return nil
+		}
+		fmt.Printf("\n%s\n", ui.Bold("Stored accounts:"))
+		for _, name := range sortedNames(accounts.Tokens) {
+			var used []string
+			for pattern, account := range accounts.Use {
+				if account == name {
+					used = append(used, pattern)
+				}
+			}
+			sort.Strings(used)
+			where := "not mapped to any owner or host"
+			if len(used) > 0 {
+				where = "for " + strings.Join(used, ", ")
+			}
+			fmt.Printf("  %s %s\n", ui.White(name), ui.Gray(where))
+		}
+		return nil
+	},
+}
+
+// loadGitHubAccounts hands the accounts stored in config to the resolver.
+// Tokens only live in the secrets store; mappings may be per repository.
+func loadGitHubAccounts() {
+	a := repoinfo.Accounts{Tokens: map[string]string{}, Use: map[string]string{}, Token: config.Get("github.token", false)}
+	for k, v := range config.GetPrefixed("github.accounts.", false) {
+		if v != "" {
+			a.Tokens[strings.TrimPrefix(k, "github.accounts.")] = v
+		}
+	}
+	for k, v := range config.GetPrefixed("github.identity.", true) {
+		if v != "" {
+			a.Use[strings.TrimPrefix(k, "github.identity.")] = v
+		}
+	}
+	repoinfo.Default().SetAccounts(a)
+}
+
+// loadGitHubApp has the resolver authenticate as a GitHub App installation
+// when github.auth is "app". github.app.private_key is the path to the App's
+// PEM file, or the PEM itself.
+func loadGitHubApp() {
+	if config.Get("github.auth", false) != "app" {
+		return
+	}
+	key := config.Get("github.app.private_key", false)
+	if !strings.Contains(key, "-----BEGIN") {
+		if rest, ok := strings.CutPrefix(key, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				key = filepath.Join(home, rest)
+			}
+		}
+		data, err := os.ReadFile(key)
+		if err != nil {
+			ui.Warnf("Failed to read the GitHub App private key: %v\n", err)
+			return
+		}
+		key = string(data)
+	}
+	repoinfo.Default().SetApp(&repoinfo.App{
+		ID:             config.Get("github.app.id", false),
+		InstallationID: config.Get("github.app.installation_id", false),
+		PrivateKey:     []byte(key),
+	})
+}
+
+// explainAccount follows up a GitHub request refused for want of access:
+// with several accounts stored, the repository may belong to another one
+func explainAccount(err error) {
+	if code := exitcode.Of(err); code != exitcode.Auth && code != exitcode.NotFound {
+		return
+	}
+	r := repoinfo.Default()
+	accounts := r.Accounts()
+	if len(accounts.Tokens) < 2 || forge.Type() != forge.GitHub {
+		return
+	}
+	info, repoErr := r.Repo()
+	if repoErr != nil {
+		return
+	}
+	token := r.TokenFor(info.Host, info.Owner)
+	var others []string
+	for _, name := range sortedNames(accounts.Tokens) {
+		if name != token.Account {
+			others = append(others, name)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\n%s GitHub refused %s with the token from the %s.\n", ui.Yellow("!"), info.FullName(), token.Source)
+	fmt.Fprintf(os.Stderr, "  If it belongs to another of your accounts (%s), map its owner to that account:\n", strings.Join(others, ", "))
+	fmt.Fprintf(os.Stderr, "  %s\n", ui.Blue(fmt.Sprintf("sage config set github.identity.%s %s", info.Owner, others[0])))
+}
+
+func whoamiRow(label, value string) {
+	fmt.Printf("%s %s\n", ui.Gray(fmt.Sprintf("%-11s", label)), value)
+}
+
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var wipCmd = &cobra.Command{
+	Use:         "wip [note]",
+	Short:       "Checkpoint all changes in a quick wip commit",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Commit everything in the working tree with a 'wip:' message.
+
+Hooks and AI are skipped so checkpoints are instant. Wip commits are
+blocked from 'sage push' and 'sage pr create'; use 'sage unwip' to turn
+them back into changes before committing for real.`,
+	Example: `  sage wip
+  sage wip trying a new parser`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		msg, err := app.CreateWip(git.NewShellGit(), strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", ui.Green("✓"), msg)
+		return nil
+	},
+}
+
+var unwipCmd = &cobra.Command{
+	Use:         "unwip",
+	Short:       "Undo the latest wip commits, keeping their changes",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := app.Unwip(git.NewShellGit())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Unwound %d wip commit%s; changes are staged\n", ui.Green("✓"), n, ui.Plural(n))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(wipCmd)
+	rootCmd.AddCommand(unwipCmd)
+}
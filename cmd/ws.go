@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+var (
+	wsFile          string
+	wsOnly          []string
+	wsParallel      int
+	wsAddName       string
+	wsCleanNoRemote bool
+	wsPRState       string
+	wsPROutput      string
+)
+
+var wsCmd = &cobra.Command{
+	Use:     "ws",
+	Aliases: []string{"workspace"},
+	Short:   "Run sync, status, clean and pr list across several repositories",
+	Long: `Work across a set of repositories at once, such as a team's microservices.
+The repositories are listed in a workspace manifest, workspace.toml in sage's
+config directory (~/.config/sage/workspace.toml on Linux) unless --file
+points elsewhere:
+
+  parallel = 4            # repositories worked on at once (default 8)
+
+  [[repo]]
+  path = "~/src/billing"
+
+  [[repo]]
+  name = "web"            # defaults to the directory's name
+  path = "frontend/web"   # relative paths are from the manifest
+
+Each command runs in every repository concurrently, with prompts off, and
+the results are gathered into one table. A repository that fails is marked
+as such without stopping the others; the command fails if any did.`,
+}
+
+var wsAddCmd = &cobra.Command{
+	Use:   "add [path]",
+	Short: "Add a repository to the workspace",
+	Long: `Add the repository at path, or the current one, to the workspace manifest,
+creating the manifest if there isn't one yet.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		path, err := app.WorkspacePath(wsFile)
+		if err != nil {
+			return err
+		}
+		repo, err := app.AddToWorkspace(path, dir, wsAddName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s Added %s (%s) to %s\n", ui.Green("✓"), ui.Blue(repo.Name), repo.Path, path)
+		return nil
+	},
+}
+
+var wsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the workspace's repositories",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repos, _, err := workspaceRepos()
+		if err != nil {
+			return err
+		}
+		rows := make([]wsRow, len(repos))
+		for i, r := range repos {
+			rows[i] = wsRow{repo: r.Name, cells: []string{r.Path}}
+		}
+		printWorkspaceTable(rows)
+		return nil
+	},
+}
+
+var wsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the branch and changes of every repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := runInWorkspace("Reading status", "status", "--output", "json")
+		if err != nil {
+			return err
+		}
+		rows := make([]wsRow, len(results))
+		for i, res := range results {
+			rows[i] = wsRow{repo: res.Repo.Name, err: res.Err}
+			if res.Err != nil {
+				continue
+			}
+			var st app.StatusOverview
+			if err := res.DecodeJSON(&st); err != nil {
+				rows[i].err = err
+				continue
+			}
+			rows[i].cells = workspaceStatusCells(st)
+		}
+		printWorkspaceTable(rows)
+		return workspaceFailures(results)
+	},
+}
+
+// workspaceStatusCells sums a repository's status up as its branch, where it
+// stands against its upstream, its changes and its PR
+func workspaceStatusCells(st app.StatusOverview) []string {
+	tracking := ui.Gray("no upstream")
+	if st.Upstream != "" {
+		tracking = ui.Green("up to date")
+		if st.Ahead > 0 || st.Behind > 0 {
+			tracking = ui.Yellow(fmt.Sprintf("↑%d ↓%d", st.Ahead, st.Behind))
+		}
+	}
+
+	var changes []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{len(st.Conflicts), "conflicted"}, {len(st.Staged), "staged"}, {len(st.Unstaged), "modified"}, {len(st.Untracked), "untracked"}} {
+		if c.n > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	changed := ui.Green("clean")
+	if len(changes) > 0 {
+		changed = ui.Yellow(strings.Join(changes, ", "))
+	}
+	if st.Operation != "" {
+		changed = ui.Red(st.Operation+" in progress") + ", " + changed
+	}
+
+	pr := ""
+	if st.PR != nil {
+		pr = ui.Blue(fmt.Sprintf("#%d", st.PR.Number))
+	}
+	return []string{ui.White(st.Branch), tracking, changed, pr}
+}
+
+var wsSyncCmd = &cobra.Command{
+	Use:         "sync",
+	Short:       "Sync the current branch of every repository",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Run 'sage sync' in every repository of the workspace. A repository that
+stops on conflicts is reported as failed and left for you to resolve with
+'sage sync --continue' there.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := runInWorkspace("Syncing", "sync")
+		if err != nil {
+			return err
+		}
+		printWorkspaceResults(results)
+		return workspaceFailures(results)
+	},
+}
+
+var wsCleanCmd = &cobra.Command{
+	Use:         "clean",
+	Short:       "Delete merged and closed branches in every repository",
+	Annotations: map[string]string{mutatingAnnotation: ""},
+	Long: `Find the branches 'sage clean' would delete in every repository, show them,
+and once you confirm, clean them all. With --dry-run only the plan is shown.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		planArgs := []string{"clean", "--dry-run", "--output", "json"}
+		cleanArgs := []string{"clean"}
+		if wsCleanNoRemote {
+			planArgs = append(planArgs, "--no-remote")
+			cleanArgs = append(cleanArgs, "--no-remote")
+		}
+		results, err := runInWorkspace("Finding branches to clean", planArgs...)
+		if err != nil {
+			return err
+		}
+
+		rows := make([]wsRow, len(results))
+		var todo []string
+		for i, res := range results {
+			rows[i] = wsRow{repo: res.Repo.Name, err: res.Err}
+			if res.Err != nil {
+				continue
+			}
+			var plan app.CleanableBranches
+			if err := res.DecodeJSON(&plan); err != nil {
+				rows[i].err = err
+				continue
+			}
+			branches := append(plan.LocalBranches, prefixAll("origin/", plan.RemoteBranches)...)
+			if len(branches) == 0 {
+				rows[i].cells = []string{ui.Green("nothing to clean")}
+				continue
+			}
+			rows[i].cells = []string{strings.Join(branches, ", ")}
+			todo = append(todo, res.Repo.Name)
+		}
+		printWorkspaceTable(rows)
+		if len(todo) == 0 || dryrun.Enabled() {
+			return workspaceFailures(results)
+		}
+
+		confirm, err := ui.ConfirmAction(fmt.Sprintf("Delete these branches in %d repo%s?", len(todo), ui.Plural(len(todo))))
+		if err != nil || !confirm {
+			return err
+		}
+		fmt.Println()
+		wsOnly = todo
+		cleaned, err := runInWorkspace("Cleaning", cleanArgs...)
+		if err != nil {
+			return err
+		}
+		printWorkspaceResults(cleaned)
+		if err := workspaceFailures(cleaned); err != nil {
+			return err
+		}
+		return workspaceFailures(results)
+	},
+}
+
+var wsPRCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Work with the pull requests of every repository",
+}
+
+var wsPRListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pull requests of every repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := runInWorkspace("Listing pull requests", "pr", "list", "--state", wsPRState, "--output", "json")
+		if err != nil {
+			return err
+		}
+		var prs []prRow
+		var failed []wsRow
+		for _, res := range results {
+			var rows []prRow
+			if res.Err == nil {
+				res.Err = res.DecodeJSON(&rows)
+			}
+			if res.Err != nil {
+				failed = append(failed, wsRow{repo: res.Repo.Name, err: res.Err})
+				continue
+			}
+			for _, r := range rows {
+				r.Repo = res.Repo.Name
+				prs = append(prs, r)
+			}
+		}
+		if err := printPRRows(prs, wsPROutput); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			if structuredOutput(cmd) {
+				for _, f := range failed {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", f.repo, f.err)
+				}
+			} else {
+				fmt.Println()
+				printWorkspaceTable(failed)
+			}
+			return fmt.Errorf("%d of %d repos failed", len(failed), len(results))
+		}
+		return nil
+	},
+}
+
+// workspaceRepos loads the manifest and picks the repositories --only names
+func workspaceRepos() ([]app.WorkspaceRepo, *app.Workspace, error) {
+	path, err := app.WorkspacePath(wsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	ws, err := app.LoadWorkspace(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	repos, err := ws.Members(wsOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(repos) == 0 {
+		return nil, nil, fmt.Errorf("the workspace in %s has no repositories; add some with 'sage ws add <path>'", path)
+	}
+	return repos, ws, nil
+}
+
+// runInWorkspace runs sage with args in every repository picked, showing a
+// spinner while it waits
+func runInWorkspace(doing string, args ...string) ([]app.WorkspaceResult, error) {
+	repos, ws, err := workspaceRepos()
+	if err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the sage executable: %w", err)
+	}
+	parallel := ws.Parallel
+	if wsParallel > 0 {
+		parallel = wsParallel
+	}
+
+	spinner := ui.NewSpinner()
+	spinner.Start(fmt.Sprintf("%s in %d repo%s...", doing, len(repos), ui.Plural(len(repos))))
+	results := app.RunInWorkspace(repos, parallel, append([]string{exe}, args...)...)
+	spinner.Stop()
+	return results, nil
+}
+
+// wsRow is a line of a workspace table: a repository and either its cells
+// or the error it failed with
+type wsRow struct {
+	repo  string
+	cells []string
+	err   error
+}
+
+// printWorkspaceTable prints rows with their columns aligned
+func printWorkspaceTable(rows []wsRow) {
+	var widths []int
+	for _, r := range rows {
+		for j, c := range append([]string{r.repo}, r.cells...) {
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], ui.VisibleLen(c))
+		}
+	}
+
+	for _, r := range rows {
+		if r.err != nil {
+			fmt.Printf("%s %s  %s\n", ui.Red("✗"), ui.Bold(padVisible(r.repo, widths[0])), ui.Red(r.err.Error()))
+			continue
+		}
+		cols := []string{padVisible(r.repo, widths[0])}
+		for j, c := range r.cells {
+			if j < len(r.cells)-1 {
+				c = padVisible(c, widths[j+1])
+			}
+			cols = append(cols, c)
+		}
+		line := fmt.Sprintf("%s %s  %s", ui.Green("✓"), ui.Bold(cols[0]), strings.Join(cols[1:], "  "))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+// padVisible pads s, which may hold color codes, to width visible characters
+func padVisible(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-ui.VisibleLen(s)))
+}
+
+// printWorkspaceResults shows what a command said last in each repository
+func printWorkspaceResults(results []app.WorkspaceResult) {
+	rows := make([]wsRow, len(results))
+	for i, res := range results {
+		rows[i] = wsRow{repo: res.Repo.Name, cells: []string{ui.Gray(res.Summary())}, err: res.Err}
+	}
+	printWorkspaceTable(rows)
+}
+
+// workspaceFailures is the error for the repositories a command failed in
+func workspaceFailures(results []app.WorkspaceResult) error {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d repos failed", failed, len(results))
+}
+
+func prefixAll(prefix string, items []string) []string {
+	out := make([]string, len(items))
+	for i, s := range items {
+		out[i] = prefix + s
+	}
+	return out
+}
+
+func init() {
+	rootCmd.AddCommand(wsCmd)
+	wsCmd.PersistentFlags().StringVar(&wsFile, "file", "", "Workspace manifest to use instead of workspace.toml in the config directory")
+	wsCmd.PersistentFlags().StringSliceVar(&wsOnly, "only", nil, "Only work on the named repos (comma-separated)")
+	wsCmd.PersistentFlags().IntVarP(&wsParallel, "parallel", "j", 0, "Repos to work on at once (default: the manifest's parallel, or 8)")
+
+	wsCmd.AddCommand(wsAddCmd, wsListCmd, wsStatusCmd, wsSyncCmd, wsCleanCmd, wsPRCmd)
+	wsAddCmd.Flags().StringVar(&wsAddName, "name", "", "Name to show the repo by (default: its directory's name)")
+	wsCleanCmd.Flags().BoolVar(&wsCleanNoRemote, "no-remote", false, "Skip deleting remote branches")
+
+	wsPRCmd.AddCommand(wsPRListCmd)
+	wsPRListCmd.Flags().StringVar(&wsPRState, "state", "open", "PRs by state (open, closed, all)")
+	addOutputFlag(wsPRListCmd, &wsPROutput)
+}
@@ -1,12 +1,12 @@
 package ai
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -29,19 +29,49 @@ func NewConfigAdapter(getFn func(key string, useLocal bool) string) ConfigGetter
 	return &configAdapter{getFn: getFn}
 }
 
-// Client is used to send requests to an AI provider following the OpenAI Chat API spec.
+// Task is a kind of work the client does, which can have its own model
+type Task string
+
+// Tasks, picking their model with ai.model.<task>
+const (
+	TaskCommit Task = "commit" // commit messages
+	TaskPR     Task = "pr"     // PR titles, descriptions and labels
+	TaskReview Task = "review" // reading changes: conflicts, CI logs, branches, patch series
+)
+
+// Client sends requests to an AI provider: OpenAI or an API following its
+// Chat spec, Anthropic, or a local Ollama server.
 type Client struct {
-	BaseURL    string
-	APIKey     string
-	Model      string
-	config     ConfigGetter
-	httpClient *http.Client
+	Provider string // ai.provider; "" means OpenAI
+	BaseURL  string
+	APIKey   string
+	Model    string
+	// Models overrides Model for some tasks, from ai.model.<task>
+	Models map[Task]string
+	// Temperature is ai.temperature; nil leaves it to the provider
+	Temperature *float64
+	// MaxTokens caps the answer, from ai.max_tokens; 0 leaves it to the
+	// provider
+	MaxTokens int
+	// ContextTokens is the prompt budget, from ai.context_tokens; 0 means
+	// the provider's default
+	ContextTokens int
+	config        ConfigGetter
+	httpClient    *http.Client
+
+	// Set by ForTask and Streaming
+	task    Task
+	ctx     context.Context
+	onToken func(string)
 }
 
 // GenerateRequest matches the minimal payload expected by the Chat API.
 type GenerateRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Stream      bool      `json:"stream,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
 }
 
 // Message is a single message in the conversation.
@@ -74,40 +104,208 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-// NewClient creates a new Ollama client.
+// NewClient creates a client for the provider ai.provider names, with its
+// default model, URL and API key environment variable unless configured.
 func NewClient(baseURL string, config ConfigGetter) *Client {
+	name := strings.ToLower(strings.TrimSpace(config.Get("ai.provider", false)))
+	p, err := providerNamed(name)
+	if err != nil {
+		// Reported when the client is used
+		p = openAIProvider{}
+	}
+
 	model := config.Get("ai.model", false)
 	if model == "" {
-		model = "gpt-4o"
+		model = p.defaultModel()
 	}
 
 	// Use passed baseURL first, then config, then default
 	if baseURL == "" {
 		baseURL = config.Get("ai.base_url", false)
 		if baseURL == "" {
-			baseURL = "https://api.openai.com/v1"
+			baseURL = p.defaultBaseURL()
 		}
 	}
 
 	// Check for API key in order of priority:
 	// 1. Local config (if in a git repo)
-	// 2. Global config
-	// 3. Environment variable
+	// 2. Global config, which keeps it in the OS keychain
+	// 3. The provider's environment variable
 	apiKey := config.Get("ai.api_key", true) // Try local config first
 	if apiKey == "" {
 		apiKey = config.Get("ai.api_key", false) // Try global config
-		if apiKey == "" {
-			apiKey = os.Getenv("OPENAI_API_KEY") // Finally, try environment
+		if apiKey == "" && p.keyEnv() != "" {
+			apiKey = os.Getenv(p.keyEnv()) // Finally, try environment
+		}
+	}
+
+	models := map[Task]string{}
+	for _, task := range []Task{TaskCommit, TaskPR, TaskReview} {
+		if m := config.Get("ai.model."+string(task), false); m != "" {
+			models[task] = m
 		}
 	}
 
+	// Values that don't parse are left to the provider
+	var temperature *float64
+	if t, err := strconv.ParseFloat(config.Get("ai.temperature", false), 64); err == nil && t >= 0 {
+		temperature = &t
+	}
+	maxTokens, _ := strconv.Atoi(config.Get("ai.max_tokens", false))
+	contextTokens, _ := strconv.Atoi(config.Get("ai.context_tokens", false))
+
 	return &Client{
-		BaseURL:    baseURL,
-		APIKey:     apiKey,
-		Model:      model,
-		config:     config,
-		httpClient: &http.Client{},
+		Provider:      name,
+		BaseURL:       baseURL,
+		APIKey:        apiKey,
+		Model:         model,
+		Models:        models,
+		Temperature:   temperature,
+		MaxTokens:     max(maxTokens, 0),
+		ContextTokens: max(contextTokens, 0),
+		config:        config,
+		httpClient:    &http.Client{},
+	}
+}
+
+// ForTask returns a copy of the client whose Complete and Stream calls use
+// the model for task
+func (c *Client) ForTask(task Task) *Client {
+	cp := *c
+	cp.task = task
+	return &cp
+}
+
+// modelFor returns the model to use for task
+func (c *Client) modelFor(task Task) string {
+	if m := c.Models[task]; m != "" {
+		return m
+	}
+	return c.Model
+}
+
+// Configured reports whether the client can be used: the provider is known
+// and has the API key it needs
+func (c *Client) Configured() bool {
+	p, err := providerNamed(c.Provider)
+	return err == nil && (p.keyEnv() == "" || c.APIKey != "")
+}
+
+// missingKeyError explains how to give the provider an API key
+func missingKeyError(p provider) error {
+	return fmt.Errorf("AI features require an API key. You can set it by either:\n"+
+		"1. Setting the %s environment variable\n"+
+		"2. Running: sage config set ai.api_key <your-api-key>\n"+
+		"3. If using a different AI provider, also set it and its URL: sage config set ai.provider anthropic|ollama, sage config set ai.base_url <api-url>\n"+
+		"4. Or run a model locally without a key: sage config set ai.provider ollama\n\n"+
+		"Note: If you've already set the API key and are seeing this error, try setting it again as there might have been an issue with the encryption.", p.keyEnv())
+}
+
+// contextTokens is the prompt budget for provider p
+func (c *Client) contextTokens(p provider) int {
+	if c.ContextTokens > 0 {
+		return c.ContextTokens
 	}
+	return p.contextTokens()
+}
+
+// fitPrompt cuts the middle out of a prompt longer than budget bytes,
+// keeping its start and the instructions at its end
+func fitPrompt(prompt string, budget int) string {
+	const marker = "\n[truncated]\n"
+	const keepTail = 2048
+	if len(prompt) <= budget || budget <= keepTail+len(marker) {
+		return prompt
+	}
+	head := budget - keepTail - len(marker)
+	return prompt[:head] + marker + prompt[len(prompt)-keepTail:]
+}
+
+// Streaming returns a copy of the client whose requests are cancelled with
+// ctx and, when onToken is set, stream their answers to it as they're
+// written. onToken sees the raw answer, before any clean-up the Generate
+// methods do.
+func (c *Client) Streaming(ctx context.Context, onToken func(string)) *Client {
+	cp := *c
+	cp.ctx = ctx
+	cp.onToken = onToken
+	return &cp
+}
+
+// chat sends a system message and prompt to the provider and returns the
+// answer. With onToken set, or the client Streaming, the answer is streamed
+// to it as it's written.
+func (c *Client) chat(task Task, system, prompt string, onToken func(string)) (string, error) {
+	if onToken == nil {
+		onToken = c.onToken
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	p, err := providerNamed(c.Provider)
+	if err != nil {
+		return "", err
+	}
+	if p.keyEnv() != "" && c.APIKey == "" {
+		return "", missingKeyError(p)
+	}
+
+	contextTokens := c.contextTokens(p)
+	req, err := p.newRequest(c.BaseURL, c.APIKey, chatRequest{
+		Model:         c.modelFor(task),
+		Temperature:   c.Temperature,
+		MaxTokens:     c.MaxTokens,
+		System:        system,
+		Prompt:        fitPrompt(prompt, contextTokens*bytesPerToken-len(system)),
+		Stream:        onToken != nil,
+		ContextTokens: contextTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	httpClient := c.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("invalid API key or unauthorized request. Please check your API key and provider settings:\n"+
+			"• Current API URL: %s\n"+
+			"• To update API key: sage config set ai.api_key <your-api-key>\n"+
+			"• To update API URL: sage config set ai.base_url <api-url>\n"+
+			"Provider error: %s", c.BaseURL, string(bodyBytes))
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API request failed (status %d). Provider response: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var r reply
+	if onToken != nil {
+		r, err = p.readStream(resp.Body, onToken)
+	} else {
+		r, err = p.readReply(resp.Body)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if r.Finish != "stop" {
+		return "", fmt.Errorf("incomplete response: %s", r.Finish)
+	}
+	return r.Content, nil
 }
 
 // SetHTTPClient sets a custom HTTP client for testing
@@ -117,17 +315,39 @@ func (c *Client) SetHTTPClient(client *http.Client) {
 
 // GenerateCommitMessage sends the diff and a prompt to the AI provider and returns a commit message.
 func (c *Client) GenerateCommitMessage(diff string) (string, error) {
-	if c.APIKey == "" {
-		return "", fmt.Errorf("AI features require an API key. You can set it by either:\n" +
-			"1. Setting the OPENAI_API_KEY environment variable\n" +
-			"2. Running: sage config set ai.api_key <your-api-key>\n" +
-			"3. If using a different AI provider, also set the base URL: sage config set ai.base_url <api-url>\n\n" +
-			"Note: If you've already set the API key and are seeing this error, try setting it again as there might have been an issue with the encryption.")
-	}
+	return c.commitMessage(TaskCommit, diff, "")
+}
+
+// RewriteCommitMessage writes a new message for an amended commit from the
+// diff of the whole commit, keeping what still holds of its previous message.
+func (c *Client) RewriteCommitMessage(diff, previous string) (string, error) {
+	return c.commitMessage(TaskCommit, diff, `
+
+The commit previously had the message below. Keep what still describes the changes, such as ticket references or the reasoning in its body, and correct the rest. If it had a body, give the new message one too, after a blank line.
+
+Previous message:
+`+previous)
+}
+
+// GenerateCommitSubject writes just the subject line for a commit whose
+// hand-written body stays as it is.
+func (c *Client) GenerateCommitSubject(diff, body string) (string, error) {
+	subject, err := c.commitMessage(TaskCommit, diff, `
 
-	// OpenAI (or compatible) providers have a maximum allowed content length.
-	const maxAllowed = 1048576
+The commit's body was written by hand and will be kept. Write only the subject line to go above it, agreeing with it.
 
+Body:
+`+body)
+	if err != nil {
+		return "", err
+	}
+	subject, _, _ = strings.Cut(subject, "\n")
+	return strings.TrimSpace(subject), nil
+}
+
+// commitMessage asks for a commit message for diff, with context added after
+// the diff
+func (c *Client) commitMessage(task Task, diff, context string) (string, error) {
 	// Define the static parts of the prompt.
 	staticPrefix := `You are a helpful git commit message generator. Your task is to analyze the following code changes and generate a clear, meaningful commit message that follows the Conventional Commits specification.
 
@@ -168,81 +388,16 @@ Code changes to analyze:
 
 Respond with ONLY the commit message, no additional text or formatting.`
 
-	// Calculate the maximum allowed length for the diff portion.
-	allowedDiffLength := maxAllowed - (len(staticPrefix) + len(staticSuffix))
-	if len(diff) > allowedDiffLength {
-		diff = diff[:allowedDiffLength] + "\n[diff truncated]"
-	}
-
-	// Construct the full prompt.
-	prompt := staticPrefix + diff + staticSuffix
-
-	// Build the request with two messages.
-	reqBody := GenerateRequest{
-		Model: c.Model,
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a helpful git commit message generator that follows the Conventional Commits specification.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
+	// Construct the full prompt; chat fits an overlong diff into the
+	// provider's context.
+	prompt := staticPrefix + diff + context + staticSuffix
 
-	url := fmt.Sprintf("%s/chat/completions", c.BaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	response, err := c.chat(task, "You are a helpful git commit message generator that follows the Conventional Commits specification.", prompt, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("invalid API key or unauthorized request. Please check your API key and provider settings:\n"+
-			"• Current API URL: %s\n"+
-			"• To update API key: sage config set ai.api_key <your-api-key>\n"+
-			"• To update API URL: sage config set ai.base_url <api-url>\n"+
-			"Provider error: %s", c.BaseURL, string(bodyBytes))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed (status %d). Provider response: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var genResp GenerateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(genResp.Choices) == 0 {
-		return "", fmt.Errorf("no response generated")
-	}
-
-	if genResp.Choices[0].FinishReason != "stop" {
-		return "", fmt.Errorf("incomplete response: %s", genResp.Choices[0].FinishReason)
+		return "", err
 	}
 
-	// Assemble the commit message from the response.
-	response := genResp.Choices[0].Message.Content
+	// Strip anything that looks like a tag from the response.
 	for {
 		start := strings.Index(response, "<")
 		if start == -1 {
@@ -260,10 +415,6 @@ Respond with ONLY the commit message, no additional text or formatting.`
 
 // GeneratePRDescription sends the diff and commits to generate a PR description
 func (c *Client) GeneratePRDescription(commits, diff string) (string, error) {
-	if c.APIKey == "" {
-		return "", fmt.Errorf("API key not found. Set OPENAI_API_KEY environment variable or configure in .sage/config.toml")
-	}
-
 	prompt := fmt.Sprintf(`You are a technical writer creating a comprehensive pull request description. Analyze the following commits and code changes to create a detailed, well-structured PR description.
 
 Guidelines:
@@ -310,63 +461,10 @@ Changes:
 
 Generate a PR description following the above structure and guidelines. Use proper markdown formatting.`, commits, diff)
 
-	// Build the request
-	reqBody := GenerateRequest{
-		Model: c.Model,
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a technical writer that creates clear, comprehensive pull request descriptions. Focus on clarity, completeness, and proper structure.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/chat/completions", c.BaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if c.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	}
-
-	resp, err := c.httpClient.Do(req)
+	description, err := c.chat(TaskPR, "You are a technical writer that creates clear, comprehensive pull request descriptions. Focus on clarity, completeness, and proper structure.", prompt, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status code: %d. Response: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var genResp GenerateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&genResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(genResp.Choices) == 0 {
-		return "", fmt.Errorf("no response generated")
-	}
-
-	if genResp.Choices[0].FinishReason != "stop" {
-		return "", fmt.Errorf("incomplete response: %s", genResp.Choices[0].FinishReason)
-	}
-
-	// Get the generated description
-	description := genResp.Choices[0].Message.Content
 
 	// Clean up any potential HTML-like tags
 	description = strings.ReplaceAll(description, "<", "\\<")
@@ -400,7 +498,7 @@ Changes:
 
 Return ONLY the exact label names from the list above, separated by commas. Do not add any new labels.`, commits, diff)
 
-	response, err := c.GenerateCommitMessage(prompt)
+	response, err := c.commitMessage(TaskPR, prompt, "")
 	if err != nil {
 		return nil, err
 	}
@@ -460,5 +558,25 @@ Changes:
 
 Return only the conventional commit title, no additional text or formatting.`, commits, diff)
 
-	return c.GenerateCommitMessage(prompt)
+	return c.commitMessage(TaskPR, prompt, "")
+}
+
+// Complete sends a free-form prompt with the given system instructions and returns the raw response.
+// It uses the model for the client's task, if ForTask gave it one.
+func (c *Client) Complete(system, prompt string) (string, error) {
+	out, err := c.chat(c.task, system, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Stream is Complete, calling onToken with each piece of the answer as it
+// arrives. Providers that can't stream hand it over in one piece.
+func (c *Client) Stream(system, prompt string, onToken func(string)) (string, error) {
+	out, err := c.chat(c.task, system, prompt, onToken)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
 }
@@ -469,3 +469,44 @@ type mockTransport struct {
 func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return m.response, m.err
 }
+
+func TestComplete(t *testing.T) {
+	client := &Client{
+		BaseURL: "https://api.test.com",
+		APIKey:  "test-key",
+		Model:   "gpt-4",
+		config:  &mockConfig{},
+	}
+
+	resp := createMockResponse(http.StatusOK, &GenerateResponse{
+		Choices: []Choice{
+			{
+				Message:      Message{Content: "  <resolved>\nline\n"},
+				FinishReason: "stop",
+			},
+		},
+	})
+	client.SetHTTPClient(&http.Client{Transport: &mockTransport{response: resp}})
+
+	out, err := client.Complete("system", "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, "<resolved>\nline", out)
+
+	client.APIKey = ""
+	_, err = client.Complete("system", "prompt")
+	assert.Error(t, err)
+}
+
+func TestRegenerateCommitMessage(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.provider": "ollama"},
+		`{"message":{"content":"fix: handle empty config\nextra line"},"done":true}`)
+
+	subject, err := client.GenerateCommitSubject("diff", "Hand-written body.")
+	assert.NoError(t, err)
+	assert.Equal(t, "fix: handle empty config", subject)
+	assert.Contains(t, rt.sentBody["messages"].([]interface{})[1].(map[string]interface{})["content"], "Hand-written body.")
+
+	_, err = client.RewriteCommitMessage("diff", "feat: old message")
+	assert.NoError(t, err)
+	assert.Contains(t, rt.sentBody["messages"].([]interface{})[1].(map[string]interface{})["content"], "Previous message:\nfeat: old message")
+}
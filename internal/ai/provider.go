@@ -0,0 +1,416 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Providers sage can talk to, picked with ai.provider
+const (
+	ProviderOpenAI    = "openai"    // OpenAI, or any API following its Chat Completions schema
+	ProviderAnthropic = "anthropic" // Anthropic's Messages API
+	ProviderOllama    = "ollama"    // a local Ollama server's /api/chat
+)
+
+// bytesPerToken is a rough count of prompt bytes per token, for fitting
+// prompts into a provider's context window
+const bytesPerToken = 4
+
+// chatRequest is a single-turn conversation, the only kind sage has
+type chatRequest struct {
+	Model         string
+	Temperature   *float64 // nil for the provider's default
+	MaxTokens     int      // 0 for the provider's default
+	System        string
+	Prompt        string
+	Stream        bool
+	ContextTokens int
+}
+
+// reply is a provider's answer. Finish is "stop" for a complete answer;
+// anything else, such as "length", means it was cut off.
+type reply struct {
+	Content string
+	Finish  string
+}
+
+// provider speaks one AI API: it builds the HTTP request for a chat and
+// reads the answer back, whole or streamed token by token
+type provider interface {
+	defaultBaseURL() string
+	defaultModel() string
+	// contextTokens is the prompt budget when ai.context_tokens isn't set
+	contextTokens() int
+	// keyEnv is the environment variable holding the API key, or "" when
+	// the provider needs none
+	keyEnv() string
+	newRequest(baseURL, apiKey string, r chatRequest) (*http.Request, error)
+	readReply(body io.Reader) (reply, error)
+	readStream(body io.Reader, onToken func(string)) (reply, error)
+}
+
+// providers maps ai.provider values to their implementations
+var providers = map[string]provider{
+	ProviderOpenAI:    openAIProvider{},
+	ProviderAnthropic: anthropicProvider{},
+	ProviderOllama:    ollamaProvider{},
+}
+
+// providerNamed returns the provider for an ai.provider value, OpenAI when
+// it is empty
+func providerNamed(name string) (provider, error) {
+	if name == "" {
+		name = ProviderOpenAI
+	}
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown ai.provider %q (want openai, anthropic or ollama)", name)
+	}
+	return p, nil
+}
+
+func jsonRequest(url string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// sseEvents calls fn with the data of each server-sent event in body
+func sseEvents(body io.Reader, fn func(data []byte) error) error {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue
+		}
+		if err := fn([]byte(strings.TrimSpace(data))); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// openAIProvider speaks the Chat Completions API
+type openAIProvider struct{}
+
+func (openAIProvider) defaultBaseURL() string { return "https://api.openai.com/v1" }
+func (openAIProvider) defaultModel() string   { return "gpt-4o" }
+func (openAIProvider) contextTokens() int     { return 262144 }
+func (openAIProvider) keyEnv() string         { return "OPENAI_API_KEY" }
+
+func (openAIProvider) newRequest(baseURL, apiKey string, r chatRequest) (*http.Request, error) {
+	req, err := jsonRequest(baseURL+"/chat/completions", GenerateRequest{
+		Model: r.Model,
+		Messages: []Message{
+			{Role: "system", Content: r.System},
+			{Role: "user", Content: r.Prompt},
+		},
+		Stream:      r.Stream,
+		Temperature: r.Temperature,
+		MaxTokens:   r.MaxTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return req, nil
+}
+
+// openAIChunk is one event of a streamed answer
+type openAIChunk struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (openAIProvider) readReply(body io.Reader) (reply, error) {
+	var resp GenerateResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return reply{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return reply{}, fmt.Errorf("no response generated")
+	}
+	return reply{Content: resp.Choices[0].Message.Content, Finish: resp.Choices[0].FinishReason}, nil
+}
+
+// readStream reads server-sent chunks until [DONE]. Compatible APIs that
+// ignore "stream" answer with a plain reply, handed over in one piece.
+func (p openAIProvider) readStream(body io.Reader, onToken func(string)) (reply, error) {
+	br := bufio.NewReader(body)
+	if first, err := br.Peek(1); err == nil && first[0] == '{' {
+		r, err := p.readReply(br)
+		if err == nil {
+			onToken(r.Content)
+		}
+		return r, err
+	}
+
+	var r reply
+	var text strings.Builder
+	errDone := errors.New("done")
+	err := sseEvents(br, func(data []byte) error {
+		if string(data) == "[DONE]" {
+			return errDone
+		}
+		var chunk openAIChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("provider error: %s", chunk.Error.Message)
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				text.WriteString(c.Delta.Content)
+				onToken(c.Delta.Content)
+			}
+			if c.FinishReason != "" {
+				r.Finish = c.FinishReason
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errDone) {
+		err = nil
+	}
+	r.Content = text.String()
+	return r, err
+}
+
+// anthropicProvider speaks Anthropic's Messages API
+type anthropicProvider struct{}
+
+const (
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens caps the answer when ai.max_tokens doesn't, as the
+	// Messages API requires a cap
+	anthropicMaxTokens = 4096
+)
+
+func (anthropicProvider) defaultBaseURL() string { return "https://api.anthropic.com/v1" }
+func (anthropicProvider) defaultModel() string   { return "claude-sonnet-4-5" }
+func (anthropicProvider) contextTokens() int     { return 150000 }
+func (anthropicProvider) keyEnv() string         { return "ANTHROPIC_API_KEY" }
+
+type anthropicRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// anthropicEvent is one event of a streamed answer
+type anthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (anthropicProvider) newRequest(baseURL, apiKey string, r chatRequest) (*http.Request, error) {
+	maxTokens := r.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = anthropicMaxTokens
+	}
+	req, err := jsonRequest(baseURL+"/messages", anthropicRequest{
+		Model:       r.Model,
+		MaxTokens:   maxTokens,
+		Temperature: r.Temperature,
+		System:      r.System,
+		Messages:    []Message{{Role: "user", Content: r.Prompt}},
+		Stream:      r.Stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+// anthropicFinish maps a stop_reason to reply.Finish
+func anthropicFinish(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	}
+	return stopReason
+}
+
+func (anthropicProvider) readReply(body io.Reader) (reply, error) {
+	var resp anthropicResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return reply{}, err
+	}
+	var text strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return reply{}, fmt.Errorf("no response generated")
+	}
+	return reply{Content: text.String(), Finish: anthropicFinish(resp.StopReason)}, nil
+}
+
+func (anthropicProvider) readStream(body io.Reader, onToken func(string)) (reply, error) {
+	var r reply
+	var text strings.Builder
+	err := sseEvents(body, func(data []byte) error {
+		var ev anthropicEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return err
+		}
+		switch ev.Type {
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" {
+				text.WriteString(ev.Delta.Text)
+				onToken(ev.Delta.Text)
+			}
+		case "message_delta":
+			r.Finish = anthropicFinish(ev.Delta.StopReason)
+		case "error":
+			return fmt.Errorf("provider error: %s", ev.Error.Message)
+		}
+		return nil
+	})
+	r.Content = text.String()
+	return r, err
+}
+
+// ollamaProvider speaks a local Ollama server's chat API
+type ollamaProvider struct{}
+
+func (ollamaProvider) defaultBaseURL() string { return "http://localhost:11434" }
+func (ollamaProvider) defaultModel() string   { return "llama3.1" }
+
+// contextTokens is small as local models often run with little memory;
+// it is also passed on as num_ctx, since Ollama's default is smaller still
+func (ollamaProvider) contextTokens() int { return 8192 }
+func (ollamaProvider) keyEnv() string     { return "" }
+
+type ollamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Options  struct {
+		NumCtx      int      `json:"num_ctx,omitempty"`
+		NumPredict  int      `json:"num_predict,omitempty"`
+		Temperature *float64 `json:"temperature,omitempty"`
+	} `json:"options"`
+}
+
+// ollamaChunk is the whole answer, or one line of a streamed one
+type ollamaChunk struct {
+	Message    Message `json:"message"`
+	Done       bool    `json:"done"`
+	DoneReason string  `json:"done_reason"`
+	Error      string  `json:"error"`
+}
+
+func (c ollamaChunk) finish() string {
+	if c.DoneReason == "" {
+		return "stop"
+	}
+	return c.DoneReason
+}
+
+func (ollamaProvider) newRequest(baseURL, apiKey string, r chatRequest) (*http.Request, error) {
+	body := ollamaRequest{
+		Model: r.Model,
+		Messages: []Message{
+			{Role: "system", Content: r.System},
+			{Role: "user", Content: r.Prompt},
+		},
+		Stream: r.Stream,
+	}
+	body.Options.NumCtx = r.ContextTokens
+	body.Options.NumPredict = r.MaxTokens
+	body.Options.Temperature = r.Temperature
+	req, err := jsonRequest(strings.TrimSuffix(baseURL, "/")+"/api/chat", body)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		// For servers behind an authenticating proxy
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return req, nil
+}
+
+func (ollamaProvider) readReply(body io.Reader) (reply, error) {
+	var c ollamaChunk
+	if err := json.NewDecoder(body).Decode(&c); err != nil {
+		return reply{}, err
+	}
+	if c.Error != "" {
+		return reply{}, fmt.Errorf("provider error: %s", c.Error)
+	}
+	return reply{Content: c.Message.Content, Finish: c.finish()}, nil
+}
+
+func (ollamaProvider) readStream(body io.Reader, onToken func(string)) (reply, error) {
+	var r reply
+	var text strings.Builder
+	dec := json.NewDecoder(body)
+	for {
+		var c ollamaChunk
+		if err := dec.Decode(&c); err == io.EOF {
+			break
+		} else if err != nil {
+			return reply{}, err
+		}
+		if c.Error != "" {
+			return reply{}, fmt.Errorf("provider error: %s", c.Error)
+		}
+		text.WriteString(c.Message.Content)
+		if c.Message.Content != "" {
+			onToken(c.Message.Content)
+		}
+		if c.Done {
+			r.Finish = c.finish()
+			break
+		}
+	}
+	r.Content = text.String()
+	return r, nil
+}
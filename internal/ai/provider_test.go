@@ -0,0 +1,243 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport answers every request with body and keeps the last
+// request, and its body, for inspection
+type recordingTransport struct {
+	body     string
+	req      *http.Request
+	sentBody map[string]interface{}
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.req = req
+	data, _ := io.ReadAll(req.Body)
+	r.sentBody = nil
+	_ = json.Unmarshal(data, &r.sentBody)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(r.body))}, nil
+}
+
+func providerClient(t *testing.T, values map[string]string, body string) (*Client, *recordingTransport) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	client := NewClient("", &mockConfig{values: values})
+	rt := &recordingTransport{body: body}
+	client.SetHTTPClient(&http.Client{Transport: rt})
+	return client, rt
+}
+
+func TestNewClientProviderDefaults(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+
+	c := NewClient("", &mockConfig{values: map[string]string{"ai.provider": "Anthropic"}})
+	assert.Equal(t, "https://api.anthropic.com/v1", c.BaseURL)
+	assert.Equal(t, "claude-sonnet-4-5", c.Model)
+	assert.Equal(t, "anthropic-key", c.APIKey)
+	assert.True(t, c.Configured())
+
+	c = NewClient("", &mockConfig{values: map[string]string{"ai.provider": "ollama", "ai.context_tokens": "4096"}})
+	assert.Equal(t, "http://localhost:11434", c.BaseURL)
+	assert.Equal(t, "llama3.1", c.Model)
+	assert.Equal(t, 4096, c.ContextTokens)
+	assert.True(t, c.Configured(), "ollama needs no key")
+}
+
+func TestUnknownProvider(t *testing.T) {
+	client, _ := providerClient(t, map[string]string{"ai.provider": "bard", "ai.api_key": "key"}, "")
+	assert.False(t, client.Configured())
+	_, err := client.Complete("system", "prompt")
+	assert.ErrorContains(t, err, `unknown ai.provider "bard"`)
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.provider": "anthropic", "ai.api_key": "key"},
+		`{"content":[{"type":"text","text":"feat: add login"}],"stop_reason":"end_turn"}`)
+
+	out, err := client.Complete("be brief", "diff")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add login", out)
+
+	assert.Equal(t, "https://api.anthropic.com/v1/messages", rt.req.URL.String())
+	assert.Equal(t, "key", rt.req.Header.Get("x-api-key"))
+	assert.Equal(t, anthropicVersion, rt.req.Header.Get("anthropic-version"))
+	assert.Empty(t, rt.req.Header.Get("Authorization"))
+	assert.Equal(t, "be brief", rt.sentBody["system"])
+	assert.Equal(t, float64(anthropicMaxTokens), rt.sentBody["max_tokens"])
+	assert.Len(t, rt.sentBody["messages"], 1)
+
+	rt.body = `{"content":[{"type":"text","text":"feat: add"}],"stop_reason":"max_tokens"}`
+	_, err = client.Complete("be brief", "diff")
+	assert.ErrorContains(t, err, "incomplete response: length")
+}
+
+func TestAnthropicStream(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.provider": "anthropic", "ai.api_key": "key"}, strings.Join([]string{
+		"event: message_start",
+		`data: {"type":"message_start"}`,
+		"",
+		"event: content_block_delta",
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"fix: "}}`,
+		"",
+		"event: content_block_delta",
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"typo"}}`,
+		"",
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+		"",
+	}, "\n"))
+
+	var tokens []string
+	out, err := client.Stream("system", "prompt", func(s string) { tokens = append(tokens, s) })
+	require.NoError(t, err)
+	assert.Equal(t, "fix: typo", out)
+	assert.Equal(t, []string{"fix: ", "typo"}, tokens)
+	assert.Equal(t, true, rt.sentBody["stream"])
+
+	rt.body = `data: {"type":"error","error":{"message":"overloaded"}}` + "\n"
+	_, err = client.Stream("system", "prompt", func(string) {})
+	assert.ErrorContains(t, err, "overloaded")
+}
+
+func TestOllamaProvider(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.provider": "ollama", "ai.base_url": "http://gpu-box:11434/"},
+		`{"message":{"role":"assistant","content":"docs: update readme"},"done":true,"done_reason":"stop"}`)
+
+	out, err := client.Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "docs: update readme", out)
+	assert.Equal(t, "http://gpu-box:11434/api/chat", rt.req.URL.String())
+	assert.Empty(t, rt.req.Header.Get("Authorization"))
+	assert.Equal(t, false, rt.sentBody["stream"])
+	assert.Equal(t, map[string]interface{}{"num_ctx": float64(8192)}, rt.sentBody["options"])
+
+	rt.body = `{"message":{"content":"docs: "},"done":false}
+{"message":{"content":"readme"},"done":false}
+{"message":{"content":""},"done":true,"done_reason":"stop"}
+`
+	var tokens []string
+	out, err = client.Stream("system", "prompt", func(s string) { tokens = append(tokens, s) })
+	require.NoError(t, err)
+	assert.Equal(t, "docs: readme", out)
+	assert.Equal(t, []string{"docs: ", "readme"}, tokens)
+
+	rt.body = `{"error":"model \"llama3.1\" not found"}`
+	_, err = client.Complete("system", "prompt")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestFitPrompt(t *testing.T) {
+	short := "short prompt"
+	assert.Equal(t, short, fitPrompt(short, 100))
+
+	long := strings.Repeat("d", 10000) + strings.Repeat("i", 2048)
+	fitted := fitPrompt(long, 4096)
+	assert.Len(t, fitted, 4096)
+	assert.Contains(t, fitted, "[truncated]")
+	assert.True(t, strings.HasSuffix(fitted, strings.Repeat("i", 2048)), "the instructions at the end survive")
+}
+
+func TestOpenAIStream(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.api_key": "key"}, strings.Join([]string{
+		`data: {"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		"",
+		`data: {"choices":[{"delta":{"content":"## Summary"}}]}`,
+		"",
+		`data: {"choices":[{"delta":{"content":"\nAdds login"}}]}`,
+		"",
+		`data: {"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		"",
+		"data: [DONE]",
+		"",
+	}, "\n"))
+
+	var tokens []string
+	out, err := client.Stream("system", "prompt", func(s string) { tokens = append(tokens, s) })
+	require.NoError(t, err)
+	assert.Equal(t, "## Summary\nAdds login", out)
+	assert.Equal(t, []string{"## Summary", "\nAdds login"}, tokens)
+	assert.Equal(t, true, rt.sentBody["stream"])
+
+	// Compatible servers that don't stream answer all at once
+	rt.body = `{"choices":[{"message":{"content":"whole answer"},"finish_reason":"stop"}]}`
+	tokens = nil
+	out, err = client.Stream("system", "prompt", func(s string) { tokens = append(tokens, s) })
+	require.NoError(t, err)
+	assert.Equal(t, "whole answer", out)
+	assert.Equal(t, []string{"whole answer"}, tokens)
+
+	// A stream cut off before it finishes is incomplete
+	rt.body = `data: {"choices":[{"delta":{"content":"half"}}]}` + "\n"
+	_, err = client.Stream("system", "prompt", func(string) {})
+	assert.ErrorContains(t, err, "incomplete response")
+}
+
+func TestStreamingCancelled(t *testing.T) {
+	client, _ := providerClient(t, map[string]string{"ai.api_key": "key"}, `{"choices":[{"message":{"content":"x"},"finish_reason":"stop"}]}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Streaming(ctx, func(string) {}).GenerateCommitMessage("diff")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGenerationSettings(t *testing.T) {
+	values := map[string]string{
+		"ai.api_key":        "key",
+		"ai.model":          "gpt-4o-mini",
+		"ai.model.pr":       "gpt-4o",
+		"ai.model.review":   "o3",
+		"ai.temperature":    "0.2",
+		"ai.max_tokens":     "500",
+		"ai.model.unknown":  "ignored",
+		"ai.context_tokens": "not a number",
+	}
+	client, rt := providerClient(t, values, `{"choices":[{"message":{"content":"feat: x"},"finish_reason":"stop"}]}`)
+
+	_, err := client.GenerateCommitMessage("diff")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", rt.sentBody["model"], "commit messages fall back to ai.model")
+	assert.Equal(t, 0.2, rt.sentBody["temperature"])
+	assert.Equal(t, float64(500), rt.sentBody["max_tokens"])
+
+	_, err = client.GeneratePRTitle("commits", "diff")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", rt.sentBody["model"])
+
+	_, err = client.ForTask(TaskReview).Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "o3", rt.sentBody["model"])
+	_, err = client.Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", rt.sentBody["model"])
+
+	values["ai.provider"] = "anthropic"
+	client, rt = providerClient(t, values, `{"content":[{"type":"text","text":"x"}],"stop_reason":"end_turn"}`)
+	_, err = client.Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, rt.sentBody["temperature"])
+	assert.Equal(t, float64(500), rt.sentBody["max_tokens"])
+
+	values["ai.provider"] = "ollama"
+	client, rt = providerClient(t, values, `{"message":{"content":"x"},"done":true}`)
+	_, err = client.Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"num_ctx": float64(8192), "num_predict": float64(500), "temperature": 0.2}, rt.sentBody["options"])
+}
+
+func TestGenerationSettingsUnset(t *testing.T) {
+	client, rt := providerClient(t, map[string]string{"ai.api_key": "key"}, `{"choices":[{"message":{"content":"x"},"finish_reason":"stop"}]}`)
+	_, err := client.Complete("system", "prompt")
+	require.NoError(t, err)
+	assert.NotContains(t, rt.sentBody, "temperature")
+	assert.NotContains(t, rt.sentBody, "max_tokens")
+}
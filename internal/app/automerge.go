@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// AutoMergeResult is what AutoMergePR did
+type AutoMergeResult struct {
+	Queued   bool // the PR is in its base branch's merge queue
+	Position int  // its place there, as GitHub numbers it
+	Already  bool // it was queued, or auto-merge was on, before
+	// Ready means there is nothing to wait for: GitHub won't turn
+	// auto-merge on for a PR that can be merged now, so merge it instead
+	Ready bool
+}
+
+// AutoMergePR has GitHub merge the PR once its checks and reviews pass:
+// through the merge queue when its base branch has one, otherwise with
+// auto-merge and method. squash, for squash merges, composes the commit;
+// it is only called when auto-merge is being turned on.
+func AutoMergePR(ghc gh.Client, num int, opts *gh.MergeOptions, method string, squash func() (SquashMessage, error)) (*AutoMergeResult, error) {
+	if opts.Mergeable == "CONFLICTING" {
+		return nil, fmt.Errorf("PR #%d has conflicts with its base branch; run 'sage sync' on it first", num)
+	}
+
+	if opts.MergeQueue {
+		if opts.Queued {
+			return &AutoMergeResult{Queued: true, Position: opts.QueuePosition, Already: true}, nil
+		}
+		pos, err := ghc.EnqueuePR(num)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add PR #%d to the merge queue: %w", num, err)
+		}
+		return &AutoMergeResult{Queued: true, Position: pos}, nil
+	}
+
+	if opts.AutoMergeEnabled {
+		return &AutoMergeResult{Already: true}, nil
+	}
+	if opts.MergeState == "clean" || opts.MergeState == "has_hooks" {
+		return &AutoMergeResult{Ready: true}, nil
+	}
+	if !opts.AutoMergeAllowed {
+		return nil, fmt.Errorf("auto-merge is turned off for this repository; turn on 'Allow auto-merge' in its settings, or merge without --auto")
+	}
+
+	var title, body string
+	if squash != nil {
+		msg, err := squash()
+		if err != nil {
+			return nil, err
+		}
+		title, body = msg.Title, msg.Body
+	}
+	if err := ghc.EnableAutoMerge(num, method, title, body); err != nil {
+		return nil, fmt.Errorf("failed to enable auto-merge: %w", err)
+	}
+	return &AutoMergeResult{}, nil
+}
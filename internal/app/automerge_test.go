@@ -0,0 +1,63 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// fakeAutoMerge records auto-merge and merge queue requests
+type fakeAutoMerge struct {
+	gh.Client
+	enabled  string // method auto-merge was turned on with
+	title    string
+	enqueued bool
+}
+
+func (f *fakeAutoMerge) EnableAutoMerge(num int, method, title, message string) error {
+	f.enabled, f.title = method, title
+	return nil
+}
+
+func (f *fakeAutoMerge) EnqueuePR(num int) (int, error) {
+	f.enqueued = true
+	return 3, nil
+}
+
+func TestAutoMergePR(t *testing.T) {
+	squash := func() (SquashMessage, error) { return SquashMessage{Title: "feat: x (#1)"}, nil }
+
+	// Queued branches take the PR through the queue, whatever the method
+	f := &fakeAutoMerge{}
+	res, err := AutoMergePR(f, 1, &gh.MergeOptions{MergeQueue: true, MergeState: "clean"}, "squash", squash)
+	if err != nil || !f.enqueued || !res.Queued || res.Position != 3 || f.enabled != "" {
+		t.Fatalf("queue: got %+v, %v, enqueued %v", res, err, f.enqueued)
+	}
+	f = &fakeAutoMerge{}
+	res, err = AutoMergePR(f, 1, &gh.MergeOptions{MergeQueue: true, Queued: true, QueuePosition: 2}, "merge", nil)
+	if err != nil || f.enqueued || !res.Already || res.Position != 2 {
+		t.Fatalf("already queued: got %+v, %v", res, err)
+	}
+
+	// Otherwise auto-merge is turned on, with the squash commit
+	f = &fakeAutoMerge{}
+	res, err = AutoMergePR(f, 1, &gh.MergeOptions{AutoMergeAllowed: true, MergeState: "blocked"}, "squash", squash)
+	if err != nil || res.Ready || res.Already || f.enabled != "squash" || f.title != "feat: x (#1)" {
+		t.Fatalf("auto-merge: got %+v, %v, %+v", res, err, f)
+	}
+
+	// A PR ready now is merged now
+	f = &fakeAutoMerge{}
+	res, err = AutoMergePR(f, 1, &gh.MergeOptions{AutoMergeAllowed: true, MergeState: "clean"}, "merge", nil)
+	if err != nil || !res.Ready || f.enabled != "" {
+		t.Fatalf("ready: got %+v, %v", res, err)
+	}
+
+	if _, err := AutoMergePR(&fakeAutoMerge{}, 1, &gh.MergeOptions{MergeState: "blocked"}, "merge", nil); err == nil || !strings.Contains(err.Error(), "turned off") {
+		t.Errorf("expected auto-merge to be refused, got %v", err)
+	}
+	if _, err := AutoMergePR(&fakeAutoMerge{}, 1, &gh.MergeOptions{MergeQueue: true, Mergeable: "CONFLICTING"}, "merge", nil); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("expected conflicts to stop it, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// ErrBaseMissing means sync.base names a branch origin doesn't have
+var ErrBaseMissing = errors.New("sync.base names a branch origin doesn't have")
+
+// BaseBranch returns the trunk work on branch is synced against, cleaned
+// against and opened as PRs into: sync.base, set for the repository or
+// overridden per branch, or else origin's default branch. branch "" means
+// the current one.
+func BaseBranch(g git.Service, branch string) (string, error) {
+	base := strings.TrimPrefix(strings.TrimSpace(config.GetForBranch("sync.base", branch)), "origin/")
+	if base == "" {
+		return g.DefaultBranch()
+	}
+	if !onOrigin(g, base) {
+		return "", exitcode.New(exitcode.NotFound, fmt.Errorf("%w: %s (push it, or change it with 'sage config set sync.base <branch>')", ErrBaseMissing, base))
+	}
+	return base, nil
+}
+
+// baseOrMain is BaseBranch for callers that carry on with "main" when origin
+// has no default branch; a misconfigured sync.base is still an error
+func baseOrMain(g git.Service, branch string) (string, error) {
+	base, err := BaseBranch(g, branch)
+	if errors.Is(err, ErrBaseMissing) {
+		return "", err
+	}
+	if err != nil {
+		return "main", nil
+	}
+	return base, nil
+}
+
+// onOrigin reports whether origin has branch, asking it when there is no
+// remote-tracking ref yet
+func onOrigin(g git.Service, branch string) bool {
+	if _, err := g.Run("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err == nil {
+		return true
+	}
+	_, err := g.Run("ls-remote", "--exit-code", "--heads", "origin", branch)
+	return err == nil
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// maxBlamePRLookups bounds the API calls one blame makes; a long-lived file
+// can have been touched by thousands of commits
+const maxBlamePRLookups = 200
+
+// prInSubject finds the PR number GitHub puts in squash and merge commits:
+// "Fix login (#42)" or "Merge pull request #42 from ..."
+var prInSubject = regexp.MustCompile(`\(#(\d+)\)$|^Merge pull request #(\d+)`)
+
+// BlameEntry is a blamed line with the pull request and tickets behind the
+// commit that last changed it
+type BlameEntry struct {
+	git.BlameLine
+	// PR is nil when no pull request is known. One found from the commit
+	// subject alone has only its number and title.
+	PR      *gh.PullRequest
+	Tickets []string
+}
+
+// Blame blames path and attributes each commit to its pull request and
+// ticket IDs. PRs come from the commit subject when it names one, or else
+// from ghc, which may be nil to stay offline.
+func Blame(g git.Service, ghc gh.Client, path string) ([]BlameEntry, error) {
+	lines, err := g.BlamePorcelain(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame %s: %w", path, err)
+	}
+
+	type attribution struct {
+		pr      *gh.PullRequest
+		tickets []string
+	}
+	byCommit := map[string]attribution{}
+	lookups := 0
+	entries := make([]BlameEntry, len(lines))
+	for i, l := range lines {
+		entries[i].BlameLine = l
+		if l.Uncommitted() {
+			continue
+		}
+		a, ok := byCommit[l.Hash]
+		if !ok {
+			a.pr = prFromSubject(l.Summary)
+			if a.pr == nil && ghc != nil && lookups < maxBlamePRLookups {
+				lookups++
+				pr, err := commitPR(ghc, l.Hash)
+				if err != nil {
+					ui.Warning(fmt.Sprintf("Couldn't look up pull requests: %v", err))
+					ghc = nil
+				}
+				a.pr = pr
+			}
+			a.tickets, err = blameTickets(l.Summary, a.pr)
+			if err != nil {
+				return nil, err
+			}
+			byCommit[l.Hash] = a
+		}
+		entries[i].PR = a.pr
+		entries[i].Tickets = a.tickets
+	}
+	if lookups == maxBlamePRLookups {
+		ui.Info(fmt.Sprintf("Looked up pull requests for the %d most recent commits only", maxBlamePRLookups))
+	}
+	return entries, nil
+}
+
+// prFromSubject returns the PR a squash or merge commit subject names
+func prFromSubject(subject string) *gh.PullRequest {
+	m := prInSubject.FindStringSubmatch(subject)
+	if m == nil {
+		return nil
+	}
+	num, _ := strconv.Atoi(m[1] + m[2])
+	title := strings.TrimSpace(strings.TrimSuffix(subject, m[0]))
+	if m[2] != "" {
+		title = ""
+	}
+	return &gh.PullRequest{Number: num, Title: title}
+}
+
+// commitPR asks the forge which PR brought sha in, preferring a merged one
+// over others that merely carry it
+func commitPR(ghc gh.Client, sha string) (*gh.PullRequest, error) {
+	prs, err := ghc.PRsForCommit(sha)
+	if err != nil || len(prs) == 0 {
+		return nil, err
+	}
+	for i := range prs {
+		if prs[i].Merged || prs[i].MergedAt != nil {
+			return &prs[i], nil
+		}
+	}
+	return &prs[0], nil
+}
+
+// blameTickets collects the ticket IDs in a commit subject and its PR's
+// title and branch
+func blameTickets(subject string, pr *gh.PullRequest) ([]string, error) {
+	text := subject
+	if pr != nil {
+		text += "\n" + pr.Title + "\n" + pr.Head.Ref
+	}
+	return issues.IDsIn(text)
+}
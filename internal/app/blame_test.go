@@ -0,0 +1,81 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// fakeCommitPRs answers PRsForCommit from a map and counts the calls
+type fakeCommitPRs struct {
+	gh.Client
+	prs   map[string][]gh.PullRequest
+	calls int
+}
+
+func (f *fakeCommitPRs) PRsForCommit(sha string) ([]gh.PullRequest, error) {
+	f.calls++
+	return f.prs[sha], nil
+}
+
+func TestPrFromSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		num     int
+		title   string
+	}{
+		{"feat: login (#42)", 42, "feat: login"},
+		{"Merge pull request #7 from rae/PROJ-1-fix", 7, ""},
+		{"fix #3 in parser", 0, ""},
+	}
+	for _, tt := range tests {
+		pr := prFromSubject(tt.subject)
+		switch {
+		case tt.num == 0 && pr != nil:
+			t.Errorf("prFromSubject(%q) = #%d, want none", tt.subject, pr.Number)
+		case tt.num != 0 && (pr == nil || pr.Number != tt.num || pr.Title != tt.title):
+			t.Errorf("prFromSubject(%q) = %+v, want #%d %q", tt.subject, pr, tt.num, tt.title)
+		}
+	}
+}
+
+func TestBlame(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("app.txt", "one\ntwo\n", "feat: PROJ-1 add app (#12)")
+	r.commit("app.txt", "one\n2\nthree\n", "fix: tweak")
+	tweak := r.rev("HEAD")
+	r.write("app.txt", "one\n2\nthree\nfour\n")
+
+	merged := gh.PullRequest{Number: 15, Title: "Tweak the app", Merged: true}
+	merged.Head.Ref = "eng-4/ENG-4-tweak"
+	fake := &fakeCommitPRs{prs: map[string][]gh.PullRequest{tweak: {{Number: 14}, merged}}}
+
+	entries, err := Blame(git.NewShellGit(), fake, "app.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("%d lines, want 4", len(entries))
+	}
+	if e := entries[0]; e.PR == nil || e.PR.Number != 12 || strings.Join(e.Tickets, ",") != "PROJ-1" {
+		t.Errorf("line 1 = %+v, want PR #12 and PROJ-1 from the subject", e)
+	}
+	for _, e := range entries[1:3] {
+		if e.PR == nil || e.PR.Number != 15 || strings.Join(e.Tickets, ",") != "ENG-4" {
+			t.Errorf("line %d = %+v, want the merged PR #15 and its branch's ENG-4", e.Line, e)
+		}
+	}
+	if e := entries[3]; !e.Uncommitted() || e.PR != nil || e.Text != "four" {
+		t.Errorf("line 4 = %+v, want the uncommitted line", e)
+	}
+	if fake.calls != 1 {
+		t.Errorf("%d lookups, want one for the commit without a PR in its subject", fake.calls)
+	}
+
+	offline, err := Blame(git.NewShellGit(), nil, "app.txt")
+	if err != nil || offline[1].PR != nil || offline[0].PR == nil {
+		t.Errorf("offline blame = %+v, %v", offline, err)
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// ErrNoCommits is returned by commands that need history when HEAD is unborn
+var ErrNoCommits = fmt.Errorf("this repository has no commits yet; run 'sage init' to create the first commit")
+
+// BootstrapOptions controls how an empty repository is set up
+type BootstrapOptions struct {
+	Message string // initial commit message; defaults to "Initial commit"
+	Branch  string // default branch name; defaults to git.default_branch, init.defaultBranch, then main
+
+	// CreateGitHub creates RepoName on GitHub, adds it as origin and pushes
+	CreateGitHub bool
+	RepoName     string // "repo" or "org/repo"
+	Private      bool
+}
+
+// BootstrapResult describes what Bootstrap did
+type BootstrapResult struct {
+	Initialized bool // git init was run
+	Branch      string
+	Commit      string
+	Files       int // files included in the initial commit
+	Repository  *gh.Repository
+	Pushed      bool
+}
+
+// IsEmptyRepo reports whether the current directory is a git repository without any commits
+func IsEmptyRepo(g git.Service) bool {
+	if inRepo, _ := g.IsRepo(); !inRepo {
+		return false
+	}
+	has, err := g.HasCommits()
+	return err == nil && !has
+}
+
+// InitialBranchName returns the branch a new repository should start on
+func InitialBranchName(g git.Service) string {
+	if b := strings.TrimSpace(config.Get("git.default_branch", false)); b != "" {
+		return b
+	}
+	if b, err := g.GetConfigValue("init.defaultBranch"); err == nil && strings.TrimSpace(b) != "" {
+		return strings.TrimSpace(b)
+	}
+	return "main"
+}
+
+// Bootstrap turns the current directory into a usable repository: it runs git
+// init if needed, names the default branch, creates the initial commit from
+// whatever is in the working tree, and optionally publishes it to GitHub.
+func Bootstrap(g git.Service, opts BootstrapOptions) (*BootstrapResult, error) {
+	res := &BootstrapResult{}
+
+	inRepo, _ := g.IsRepo()
+	if !inRepo {
+		if _, err := g.Run("init"); err != nil {
+			return nil, fmt.Errorf("failed to initialise repository: %w", err)
+		}
+		res.Initialized = true
+	} else if has, err := g.HasCommits(); err != nil {
+		return nil, err
+	} else if has {
+		return nil, fmt.Errorf("repository already has commits; nothing to bootstrap")
+	}
+
+	res.Branch = opts.Branch
+	if res.Branch == "" {
+		res.Branch = InitialBranchName(g)
+	}
+	// HEAD is unborn, so pointing it at the new name renames the branch
+	if _, err := g.Run("symbolic-ref", "HEAD", "refs/heads/"+res.Branch); err != nil {
+		return nil, fmt.Errorf("failed to set default branch to %s: %w", res.Branch, err)
+	}
+
+	if err := StageAll(g); err != nil {
+		return nil, fmt.Errorf("failed to stage files: %w", err)
+	}
+	staged, err := g.Run("diff", "--cached", "--name-only")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	if s := strings.TrimSpace(staged); s != "" {
+		res.Files = len(strings.Split(s, "\n"))
+	}
+
+	msg := opts.Message
+	if msg == "" {
+		msg = "Initial commit"
+	}
+	if err := g.Commit(msg, res.Files == 0, false); err != nil {
+		return nil, fmt.Errorf("failed to create initial commit: %w", err)
+	}
+	res.Commit, _ = g.GetCommitHash("HEAD")
+
+	if !opts.CreateGitHub {
+		return res, nil
+	}
+
+	name := opts.RepoName
+	if name == "" {
+		path, err := g.GetRepoPath()
+		if err != nil {
+			return res, fmt.Errorf("failed to determine repository name: %w", err)
+		}
+		name = path[strings.LastIndexAny(path, `/\`)+1:]
+	}
+
+	if _, err := g.Run("remote", "get-url", "origin"); err == nil {
+		return res, fmt.Errorf("remote 'origin' already exists; push with 'sage push' instead")
+	}
+
+	repo, err := gh.CreateRepository(name, opts.Private)
+	if err != nil {
+		return res, fmt.Errorf("failed to create GitHub repository: %w", err)
+	}
+	res.Repository = repo
+
+	if _, err := g.Run("remote", "add", "origin", repo.CloneURL); err != nil {
+		return res, fmt.Errorf("failed to add remote: %w", err)
+	}
+	if _, err := g.Run("push", "--set-upstream", "origin", res.Branch); err != nil {
+		return res, fmt.Errorf("failed to push %s: %w", res.Branch, err)
+	}
+	// Record origin/HEAD so DefaultBranch works without another fetch
+	_, _ = g.Run("remote", "set-head", "origin", res.Branch)
+	res.Pushed = true
+
+	return res, nil
+}
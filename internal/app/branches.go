@@ -0,0 +1,287 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+const (
+	// maxRemoteCommitLookups caps how many branches have their last commit
+	// read from GitHub when it isn't available locally
+	maxRemoteCommitLookups = 50
+	// remoteLookupWorkers is how many GitHub commit lookups run at once
+	remoteLookupWorkers = 8
+)
+
+// BranchInfo is a branch with what is known about its last commit and pull
+// request. For remote branches that is whatever can be found without
+// fetching them.
+type BranchInfo struct {
+	Name    string
+	SHA     string
+	Subject string
+	Author  string
+	Date    time.Time // zero when the last commit wasn't looked up
+	Local   bool      // a local branch of the same name exists
+	PR      *gh.PullRequest
+
+	// Set for local branches only
+	Committer    string    // who made the last commit
+	Upstream     string    // the branch it tracks, like origin/main
+	Ahead        int       // commits it has that its upstream doesn't
+	Behind       int       // commits its upstream has that it doesn't
+	UpstreamGone bool      // its upstream was deleted on the remote
+	CachedPR     *CachedPR // its pull request, as sage last saw it
+}
+
+// Ways to order local branches
+const (
+	BranchSortRecent = "recent" // most recently committed first
+	BranchSortStale  = "stale"  // least recently committed first
+	BranchSortName   = "name"   // alphabetically
+)
+
+// refInfo is a ref's commit as read from for-each-ref
+type refInfo struct {
+	sha, subject, author, committer string
+	date                            time.Time
+	upstream, track                 string // %(upstream:short) and %(upstream:track,nobracket)
+}
+
+// readRefs lists refs under prefix, keyed by their name without it
+func readRefs(g git.Service, prefix string) (map[string]refInfo, error) {
+	out, err := g.Run("for-each-ref", "--format=%(refname)%00%(objectname)%00%(committerdate:unix)%00%(authorname)%00%(contents:subject)%00%(committername)%00%(upstream:short)%00%(upstream:track,nobracket)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]refInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.Split(line, "\x00")
+		if len(f) < 8 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(f[2], 10, 64)
+		refs[strings.TrimPrefix(f[0], prefix)] = refInfo{
+			sha: f[1], date: time.Unix(ts, 0), author: f[3], subject: f[4],
+			committer: f[5], upstream: f[6], track: f[7],
+		}
+	}
+	return refs, nil
+}
+
+// parseTrack reads %(upstream:track,nobracket), which is empty when the
+// branch is level with its upstream, "gone" when the upstream was deleted
+// and otherwise like "ahead 2, behind 1"
+func parseTrack(track string) (ahead, behind int, gone bool) {
+	if track == "gone" {
+		return 0, 0, true
+	}
+	for _, part := range strings.Split(track, ", ") {
+		if n, ok := strings.CutPrefix(part, "ahead "); ok {
+			ahead, _ = strconv.Atoi(n)
+		} else if n, ok := strings.CutPrefix(part, "behind "); ok {
+			behind, _ = strconv.Atoi(n)
+		}
+	}
+	return ahead, behind, false
+}
+
+// ListLocalBranches lists the local branches whose names contain filter, with
+// how they stand against their upstreams and any pull request sage has cached
+// for them, all read without touching the network. sortBy is one of the
+// BranchSort orders, most recently committed first when empty.
+func ListLocalBranches(g git.Service, filter, sortBy string) ([]BranchInfo, error) {
+	refs, err := readRefs(g, "refs/heads/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	// The list is still useful without PRs, so ignore a broken cache
+	prs, _ := loadPRCache(g)
+
+	branches := make([]BranchInfo, 0, len(refs))
+	for name, r := range refs {
+		if !matchesBranchFilter(name, filter) {
+			continue
+		}
+		b := BranchInfo{
+			Name: name, SHA: r.sha, Subject: r.subject, Author: r.author, Date: r.date, Local: true,
+			Committer: r.committer, Upstream: r.upstream,
+		}
+		b.Ahead, b.Behind, b.UpstreamGone = parseTrack(r.track)
+		if pr, ok := prs[name]; ok {
+			b.CachedPR = &pr
+		}
+		branches = append(branches, b)
+	}
+
+	switch sortBy {
+	case "", BranchSortRecent:
+		sortBranches(branches)
+	case BranchSortStale:
+		sort.SliceStable(branches, func(i, j int) bool {
+			a, b := branches[i], branches[j]
+			if !a.Date.Equal(b.Date) {
+				return a.Date.Before(b.Date)
+			}
+			return a.Name < b.Name
+		})
+	case BranchSortName:
+		sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	default:
+		return nil, fmt.Errorf("unknown sort order %q (use %s, %s or %s)", sortBy, BranchSortRecent, BranchSortStale, BranchSortName)
+	}
+	return branches, nil
+}
+
+// matchesBranchFilter reports whether name contains filter, ignoring case
+func matchesBranchFilter(name, filter string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(filter))
+}
+
+// sortBranches orders branches newest first, with those of unknown age last
+func sortBranches(branches []BranchInfo) {
+	sort.SliceStable(branches, func(i, j int) bool {
+		a, b := branches[i], branches[j]
+		if a.Date.IsZero() != b.Date.IsZero() {
+			return !a.Date.IsZero()
+		}
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.Name < b.Name
+	})
+}
+
+// ListRemoteBranches lists the branches on remote whose names contain filter,
+// using ls-remote so nothing is fetched. Last commits come from local or
+// remote-tracking refs when they are up to date, and from GitHub for up to
+// maxRemoteCommitLookups others; ghc may be nil to skip GitHub entirely.
+func ListRemoteBranches(g git.Service, ghc gh.Client, remote, filter string) ([]BranchInfo, error) {
+	var prs map[string]gh.PullRequest
+	var wg sync.WaitGroup
+	if ghc != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Without PR info the list is still useful, so ignore failures
+			prs, _ = ghc.OpenPRsByHead()
+		}()
+	}
+
+	out, err := g.Run("ls-remote", "--heads", remote)
+	if err != nil {
+		wg.Wait()
+		return nil, fmt.Errorf("failed to list branches on %s: %w", remote, err)
+	}
+	tracking, _ := readRefs(g, "refs/remotes/"+remote+"/")
+	local, _ := readRefs(g, "refs/heads/")
+
+	var branches []BranchInfo
+	var lookups []int
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		sha, ref, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(ref, "refs/heads/")
+		if !matchesBranchFilter(name, filter) {
+			continue
+		}
+		b := BranchInfo{Name: name, SHA: sha}
+		_, b.Local = local[name]
+		if info, ok := tracking[name]; ok && info.sha == sha {
+			b.Subject, b.Author, b.Date = info.subject, info.author, info.date
+		} else if info, ok := local[name]; ok && info.sha == sha {
+			b.Subject, b.Author, b.Date = info.subject, info.author, info.date
+		} else {
+			lookups = append(lookups, len(branches))
+		}
+		branches = append(branches, b)
+	}
+
+	if ghc != nil {
+		if len(lookups) > maxRemoteCommitLookups {
+			lookups = lookups[:maxRemoteCommitLookups]
+		}
+		lookupCommits(ghc, branches, lookups)
+	}
+
+	wg.Wait()
+	for i := range branches {
+		if pr, ok := prs[branches[i].Name]; ok {
+			branches[i].PR = &pr
+		}
+	}
+
+	sortBranches(branches)
+	return branches, nil
+}
+
+// lookupCommits fills in the last commit of branches[idx] from GitHub
+func lookupCommits(ghc gh.Client, branches []BranchInfo, idx []int) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < remoteLookupWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				c, err := ghc.GetCommit(branches[i].SHA)
+				if err != nil {
+					continue
+				}
+				b := &branches[i]
+				b.Subject, b.Author, b.Date = c.Subject, c.AuthorName, c.Date
+				if c.AuthorLogin != "" {
+					b.Author = c.AuthorLogin
+				}
+			}
+		}()
+	}
+	for _, i := range idx {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+// refExists reports whether ref names an existing ref
+func refExists(g git.Service, ref string) bool {
+	_, err := g.Run("rev-parse", "--verify", "--quiet", ref)
+	return err == nil
+}
+
+// CheckoutRemoteBranch fetches just branch from remote and switches to a
+// local branch tracking it. An existing local branch is switched to as is.
+func CheckoutRemoteBranch(g git.Service, remote, branch string) error {
+	if refExists(g, "refs/heads/"+branch) {
+		return g.Checkout(branch)
+	}
+	if _, err := g.Run("fetch", remote, branch); err != nil {
+		return fmt.Errorf("failed to fetch %s from %s: %w", branch, remote, err)
+	}
+
+	// fetch updates the remote-tracking ref when the remote's refspec covers
+	// the branch; single-branch clones don't, so start from FETCH_HEAD instead
+	if refExists(g, "refs/remotes/"+remote+"/"+branch) {
+		if _, err := g.Run("checkout", "--track", remote+"/"+branch); err != nil {
+			return fmt.Errorf("failed to check out %s: %w", branch, err)
+		}
+		return nil
+	}
+	if _, err := g.Run("checkout", "-b", branch, "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", branch, err)
+	}
+	if _, err := g.Run("config", "branch."+branch+".remote", remote); err != nil {
+		return err
+	}
+	_, err := g.Run("config", "branch."+branch+".merge", "refs/heads/"+branch)
+	return err
+}
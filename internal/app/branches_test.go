@@ -0,0 +1,69 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		track         string
+		ahead, behind int
+		gone          bool
+	}{
+		{"", 0, 0, false},
+		{"gone", 0, 0, true},
+		{"ahead 3", 3, 0, false},
+		{"behind 2", 0, 2, false},
+		{"ahead 1, behind 12", 1, 12, false},
+	}
+	for _, tt := range tests {
+		ahead, behind, gone := parseTrack(tt.track)
+		if ahead != tt.ahead || behind != tt.behind || gone != tt.gone {
+			t.Errorf("parseTrack(%q) = %d, %d, %v, want %d, %d, %v", tt.track, ahead, behind, gone, tt.ahead, tt.behind, tt.gone)
+		}
+	}
+}
+
+func TestListLocalBranchesTracking(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("feature.txt", "feature\n", "Feature work")
+	r.git("push", "-u", "origin", "feature")
+	r.commit("feature.txt", "more\n", "More feature work")
+	r.git("checkout", "-b", "merged", "main")
+	r.git("push", "-u", "origin", "merged")
+	r.git("push", "origin", "--delete", "merged")
+
+	branches, err := ListLocalBranches(git.NewShellGit(), "", BranchSortName)
+	if err != nil {
+		t.Fatalf("ListLocalBranches: %v", err)
+	}
+	byName := map[string]BranchInfo{}
+	var names []string
+	for _, b := range branches {
+		byName[b.Name] = b
+		names = append(names, b.Name)
+	}
+	if len(names) != 3 || names[0] != "feature" || names[1] != "main" || names[2] != "merged" {
+		t.Fatalf("branches = %v, want feature, main and merged by name", names)
+	}
+
+	if b := byName["feature"]; b.Upstream != "origin/feature" || b.Ahead != 1 || b.Behind != 0 || b.UpstreamGone {
+		t.Errorf("feature = %+v, want one ahead of origin/feature", b)
+	}
+	if b := byName["feature"]; b.Committer != "Sage Test" || b.Subject != "More feature work" {
+		t.Errorf("feature's last commit = %q by %q", b.Subject, b.Committer)
+	}
+	if b := byName["merged"]; !b.UpstreamGone {
+		t.Errorf("merged = %+v, want its upstream gone", b)
+	}
+	if b := byName["main"]; b.Upstream != "origin/main" || b.Ahead != 0 || b.Behind != 0 {
+		t.Errorf("main = %+v, want level with origin/main", b)
+	}
+
+	if _, err := ListLocalBranches(git.NewShellGit(), "", "size"); err == nil {
+		t.Error("an unknown sort order was accepted")
+	}
+}
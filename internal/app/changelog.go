@@ -0,0 +1,259 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// ChangesDir holds changelog fragments, one file per change, until a release
+// collects them into CHANGELOG.md
+const ChangesDir = ".changes"
+
+// ChangelogFile is the changelog fragments are collected into
+const ChangelogFile = "CHANGELOG.md"
+
+// ChangeTypes are the fragment types, in the order they appear in the changelog
+var ChangeTypes = []string{"added", "changed", "deprecated", "removed", "fixed", "security"}
+
+// Fragment is a single pending changelog entry
+type Fragment struct {
+	Path    string `yaml:"-"`
+	Type    string `yaml:"type"`
+	Summary string `yaml:"-"`
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func changesDir(g git.Service) (string, error) {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, ChangesDir), nil
+}
+
+func validChangeType(t string) bool {
+	for _, ct := range ChangeTypes {
+		if ct == t {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFragment writes a new fragment under .changes/ and returns its path
+func AddFragment(g git.Service, changeType, summary string) (string, error) {
+	changeType = strings.ToLower(strings.TrimSpace(changeType))
+	summary = strings.TrimSpace(summary)
+	if !validChangeType(changeType) {
+		return "", fmt.Errorf("unknown change type %q (use one of: %s)", changeType, strings.Join(ChangeTypes, ", "))
+	}
+	if summary == "" {
+		return "", fmt.Errorf("a changelog entry needs a summary")
+	}
+
+	dir, err := changesDir(g)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", ChangesDir, err)
+	}
+
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(summary), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	name := fmt.Sprintf("%s-%s.md", time.Now().Format("20060102-150405"), slug)
+	path := filepath.Join(dir, name)
+
+	content := fmt.Sprintf("---\ntype: %s\n---\n%s\n", changeType, summary)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write changelog entry: %w", err)
+	}
+	return path, nil
+}
+
+// parseFragment reads a fragment's front matter and summary
+func parseFragment(path string, data []byte) (Fragment, error) {
+	frag := Fragment{Path: path}
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return frag, fmt.Errorf("%s: missing front matter", path)
+	}
+	header, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		return frag, fmt.Errorf("%s: unterminated front matter", path)
+	}
+	if err := yaml.Unmarshal(header, &frag); err != nil {
+		return frag, fmt.Errorf("%s: %w", path, err)
+	}
+	frag.Type = strings.ToLower(frag.Type)
+	if !validChangeType(frag.Type) {
+		return frag, fmt.Errorf("%s: unknown change type %q", path, frag.Type)
+	}
+	frag.Summary = strings.TrimSpace(string(body))
+	if frag.Summary == "" {
+		return frag, fmt.Errorf("%s: empty summary", path)
+	}
+	return frag, nil
+}
+
+// ListFragments returns the pending fragments, oldest first
+func ListFragments(g git.Service) ([]Fragment, error) {
+	dir, err := changesDir(g)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var frags []Fragment
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".md" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		frag, err := parseFragment(path, data)
+		if err != nil {
+			return nil, err
+		}
+		frags = append(frags, frag)
+	}
+	sort.Slice(frags, func(i, j int) bool { return frags[i].Path < frags[j].Path })
+	return frags, nil
+}
+
+// BranchFragments returns the fragments added on the current branch since it
+// left base. ok is false when the repository doesn't use fragments at all.
+func BranchFragments(g git.Service, base string) (frags []Fragment, ok bool, err error) {
+	dir, err := changesDir(g)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, false, nil
+	}
+
+	out, err := g.Run("diff", "--name-only", "--diff-filter=A", base+"...HEAD", "--", ChangesDir)
+	if err != nil {
+		return nil, true, err
+	}
+	added := map[string]bool{}
+	for _, f := range strings.Split(strings.TrimSpace(out), "\n") {
+		if f != "" {
+			added[filepath.Base(f)] = true
+		}
+	}
+
+	all, err := ListFragments(g)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, f := range all {
+		if added[filepath.Base(f.Path)] {
+			frags = append(frags, f)
+		}
+	}
+	return frags, true, nil
+}
+
+// RenderFragments renders fragments as changelog sections under a heading of
+// the given level
+func RenderFragments(frags []Fragment, level int) string {
+	var b strings.Builder
+	for _, t := range ChangeTypes {
+		var items []string
+		for _, f := range frags {
+			if f.Type == t {
+				items = append(items, f.Summary)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", level), strings.ToUpper(t[:1])+t[1:])
+		for _, item := range items {
+			// Continuation lines stay inside the bullet
+			fmt.Fprintf(&b, "- %s\n", strings.ReplaceAll(item, "\n", "\n  "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CollectChangelog writes every pending fragment into CHANGELOG.md as a new
+// release section and deletes the fragments. It returns the fragments used.
+func CollectChangelog(g git.Service, version string, date time.Time) ([]Fragment, error) {
+	frags, err := ListFragments(g)
+	if err != nil {
+		return nil, err
+	}
+	if len(frags) == 0 {
+		return nil, fmt.Errorf("no changelog entries in %s", ChangesDir)
+	}
+
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(root, ChangelogFile)
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	section := fmt.Sprintf("## [%s] - %s\n\n%s", strings.TrimPrefix(version, "v"), date.Format("2006-01-02"), RenderFragments(frags, 3))
+	if err := os.WriteFile(path, []byte(insertRelease(string(existing), section)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ChangelogFile, err)
+	}
+
+	for _, f := range frags {
+		if err := os.Remove(f.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", f.Path, err)
+		}
+	}
+	return frags, nil
+}
+
+// insertRelease puts a release section above the newest one, keeping any
+// title and intro at the top of the changelog
+func insertRelease(changelog, section string) string {
+	if strings.TrimSpace(changelog) == "" {
+		return "# Changelog\n\n" + section
+	}
+	if strings.HasPrefix(changelog, "## ") {
+		return section + changelog
+	}
+	i := strings.Index(changelog, "\n## ")
+	if i < 0 {
+		return strings.TrimRight(changelog, "\n") + "\n\n" + section
+	}
+	// Keep an "Unreleased" section on top
+	if strings.HasPrefix(strings.ToLower(changelog[i+1:]), "## [unreleased]") {
+		if j := strings.Index(changelog[i+1:], "\n## "); j >= 0 {
+			i += j + 1
+		} else {
+			return strings.TrimRight(changelog, "\n") + "\n\n" + section
+		}
+	}
+	return changelog[:i+1] + section + changelog[i+1:]
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// maxCILogPromptSize caps how much of a failing log is sent to the AI
+const maxCILogPromptSize = 10000
+
+var (
+	// logTimestamp is the prefix Actions puts on every log line
+	logTimestamp = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(?:\.\d+)?Z ?`)
+	ansiEscape   = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// errorLine matches lines that usually mark where a job went wrong
+	errorLine = regexp.MustCompile(`##\[error\]|(?i)\berror\b[:\]]|^\s*(?:FAIL|FAILED)\b|--- FAIL|^panic:|(?i)\bfatal:|npm ERR!|Traceback \(most recent call last\)|(?i)\bexception\b|✗|✖`)
+)
+
+// CIFailure is a failed check with the part of its log that explains it
+type CIFailure struct {
+	Check  gh.CheckRun
+	Log    string // the error region; empty when the log isn't available
+	Reason string // why Log is empty
+}
+
+// FailedChecks returns the failed check runs of a pull request, with the error
+// region of each Actions job's log
+func FailedChecks(ghc gh.Client, num int, maxLines int) ([]CIFailure, error) {
+	runs, err := ghc.ListPRCheckRuns(num)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checks: %w", err)
+	}
+
+	var failures []CIFailure
+	for _, run := range runs {
+		if !run.Failed() {
+			continue
+		}
+		f := CIFailure{Check: run}
+		if !run.FromActions() {
+			f.Reason = fmt.Sprintf("reported by %s; its logs aren't available through the GitHub API", run.App.Slug)
+		} else if log, err := ghc.GetJobLog(run.ID); err != nil {
+			f.Reason = fmt.Sprintf("couldn't download the log: %v", err)
+		} else {
+			f.Log = ExtractErrorRegion(log, maxLines)
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+// ExtractErrorRegion picks the lines of a CI log around its errors: a few
+// lines before and after each error line, with overlapping windows merged.
+// Without any recognizable error, the end of the log is returned.
+func ExtractErrorRegion(log string, maxLines int) string {
+	const before, after = 8, 4
+
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	for i, l := range lines {
+		l = logTimestamp.ReplaceAllString(strings.TrimRight(l, "\r"), "")
+		lines[i] = ansiEscape.ReplaceAllString(l, "")
+	}
+
+	var hits []int
+	for i, l := range lines {
+		// The closing "exit code" line only repeats that the step failed
+		if errorLine.MatchString(l) && !strings.Contains(l, "Process completed with exit code") {
+			hits = append(hits, i)
+		}
+	}
+	if len(hits) == 0 {
+		start := len(lines) - maxLines
+		if start < 0 {
+			start = 0
+		}
+		return strings.Join(lines[start:], "\n")
+	}
+
+	var out []string
+	end := -1 // last line already included
+	for _, h := range hits {
+		from, to := h-before, h+after
+		if from <= end {
+			from = end + 1
+		} else if end >= 0 {
+			out = append(out, "…")
+		}
+		if from < 0 {
+			from = 0
+		}
+		if to >= len(lines) {
+			to = len(lines) - 1
+		}
+		for i := from; i <= to; i++ {
+			out = append(out, lines[i])
+		}
+		if to > end {
+			end = to
+		}
+		if len(out) >= maxLines {
+			out = append(out[:maxLines], "…")
+			break
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// ExplainCIFailure asks the AI why a check failed and how to reproduce the
+// failure locally
+func ExplainCIFailure(client *ai.Client, f CIFailure, changedFiles []string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Check: %s\n", f.Check.Name)
+	if len(changedFiles) > 0 {
+		fmt.Fprintf(&b, "Files changed by the pull request:\n%s\n", strings.Join(changedFiles, "\n"))
+	}
+	fmt.Fprintf(&b, "\nLog excerpt:\n```\n%s\n```\n", truncateForPrompt(f.Log, maxCILogPromptSize))
+
+	return client.ForTask(ai.TaskReview).Complete(
+		`You help developers understand why a CI job failed. From the log excerpt, explain the most likely cause in two or three sentences, naming the failing test, file or command when the log shows it. Then list the shell commands to reproduce the failure locally under "Reproduce:". Don't guess beyond what the log supports; say so when the cause is unclear.`,
+		b.String(),
+	)
+}
@@ -2,16 +2,31 @@ package app
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 )
 
+const (
+	// defaultAbandonedDays is how old unmerged work must be before sage clean
+	// offers to record it in an issue, when clean.abandoned_days isn't set
+	defaultAbandonedDays = 30
+	// significantChangeLines is the smallest unmerged change worth an issue
+	significantChangeLines = 20
+	// maxAbandonedDiffSize caps how much of the diff is sent to the AI
+	maxAbandonedDiffSize = 12000
+)
+
 type CleanableBranches struct {
-	LocalBranches  []string
-	RemoteBranches []string
+	LocalBranches  []string `json:"local_branches" yaml:"local_branches"`
+	RemoteBranches []string `json:"remote_branches" yaml:"remote_branches"`
+	DefaultBranch  string   `json:"default_branch" yaml:"default_branch"`
 }
 
 type DeletionResult struct {
@@ -25,12 +40,27 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 		return nil, err
 	}
 
+	// Listing PRs is the slowest lookup and doesn't depend on the fetch, so run it alongside
+	var prs []gh.PullRequest
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		if prs, err = ghc.ListPRs("all"); err != nil {
+			// If GitHub API fails, continue with just git info
+			prs = nil
+		}
+	}()
+	defer wg.Wait()
+
 	// Fetch latest remote info
 	if err := g.FetchAll(); err != nil {
 		return nil, fmt.Errorf("failed to fetch remote updates: %w", err)
 	}
 
-	// Get current and default branches
+	// Get current and default branches; branches are merged once they are in
+	// the trunk, which may not be the default branch
 	db, err := g.DefaultBranch()
 	if err != nil {
 		db = "main"
@@ -39,6 +69,10 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 	if err != nil {
 		return nil, err
 	}
+	base, err := baseOrMain(g, cur)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get all local branches
 	branches, err := g.ListBranches()
@@ -47,7 +81,7 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 	}
 
 	// Get merged branches (via git)
-	merged, err := g.MergedBranches(db)
+	merged, err := g.MergedBranches(base)
 	if err != nil {
 		return nil, err
 	}
@@ -56,12 +90,8 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 		mergedMap[br] = true
 	}
 
-	// Get all PRs to check closed/merged status
-	prs, err := ghc.ListPRs("all")
-	if err != nil {
-		// If GitHub API fails, continue with just git info
-		prs = nil
-	}
+	// Wait for the PR list to check closed/merged status
+	wg.Wait()
 
 	// Build map of branches with closed/merged PRs
 	closedPRBranches := make(map[string]bool)
@@ -92,7 +122,7 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 
 	for _, br := range branches {
 		// Skip current and default branches
-		if br == db || br == cur || br == "" {
+		if br == db || br == base || br == cur || br == "" {
 			continue
 		}
 
@@ -111,10 +141,14 @@ func FindCleanableBranches(g git.Service, ghc gh.Client) (*CleanableBranches, er
 	return &CleanableBranches{
 		LocalBranches:  localToDelete,
 		RemoteBranches: remoteToDelete,
+		DefaultBranch:  base,
 	}, nil
 }
 
 func DeleteLocalBranches(g git.Service, branches []string) []DeletionResult {
+	if len(branches) > 0 {
+		JournalSnapshot(g, "sage clean")
+	}
 	results := make([]DeletionResult, len(branches))
 	var wg sync.WaitGroup
 	wg.Add(len(branches))
@@ -146,4 +180,100 @@ func DeleteRemoteBranches(g git.Service, branches []string) []DeletionResult {
 	return results
 }
 
-// TODO: add support for deleting remote branches
+// AbandonedWork is unmerged work on a branch that is about to be deleted
+type AbandonedWork struct {
+	Branch  string
+	Base    string
+	Commits []git.Commit // newest first
+	Added   int
+	Deleted int
+}
+
+// LastCommit returns the newest commit of the unmerged work
+func (w AbandonedWork) LastCommit() git.Commit {
+	return w.Commits[0]
+}
+
+// AbandonedDays returns clean.abandoned_days, the age in days after which
+// unmerged work counts as abandoned
+func AbandonedDays() int {
+	if v := config.Get("clean.abandoned_days", true); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultAbandonedDays
+}
+
+// FindAbandonedWork returns the branches holding a significant amount of work
+// missing from base whose last commit is older than minAge
+func FindAbandonedWork(g git.Service, branches []string, base string, minAge time.Duration) []AbandonedWork {
+	var work []AbandonedWork
+	for _, br := range branches {
+		commits, err := g.Commits(git.CommitsOptions{Range: base + ".." + br, Stats: true})
+		if err != nil || len(commits) == 0 {
+			continue
+		}
+		if time.Since(commits[0].Date) < minAge {
+			continue
+		}
+		w := AbandonedWork{Branch: br, Base: base, Commits: commits}
+		for _, c := range commits {
+			for _, f := range c.Files {
+				w.Added += f.Added
+				w.Deleted += f.Deleted
+			}
+		}
+		if w.Added+w.Deleted < significantChangeLines {
+			continue
+		}
+		work = append(work, w)
+	}
+	return work
+}
+
+// AbandonedIssueTitle returns the title of the issue recording a branch's work
+func AbandonedIssueTitle(w AbandonedWork) string {
+	return fmt.Sprintf("Unfinished work from branch %s", w.Branch)
+}
+
+// AbandonedIssueBody describes a branch's unmerged work: the summary, if any,
+// its commits and how to get the code back
+func AbandonedIssueBody(w AbandonedWork, summary string) string {
+	last := w.LastCommit()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The branch `%s` was deleted with %d unmerged commit", w.Branch, len(w.Commits))
+	if len(w.Commits) != 1 {
+		b.WriteString("s")
+	}
+	fmt.Fprintf(&b, " (+%d/-%d lines) not in `%s`.\n\n", w.Added, w.Deleted, w.Base)
+	if summary != "" {
+		fmt.Fprintf(&b, "## Summary\n\n%s\n\n", strings.TrimSpace(summary))
+	}
+	b.WriteString("## Commits\n\n")
+	for _, c := range w.Commits {
+		fmt.Fprintf(&b, "- %s %s (%s, %s)\n", c.ShortHash(), c.Subject, c.Author, c.Date.Format("2006-01-02"))
+	}
+	fmt.Fprintf(&b, "\n## Restoring it\n\nThe last commit was `%s`. While it still exists in a clone, bring the branch back with:\n\n", last.Hash)
+	fmt.Fprintf(&b, "```\ngit branch %s %s\n```\n", w.Branch, last.Hash)
+	return b.String()
+}
+
+// SummarizeAbandonedWork asks the AI what the unmerged work on a branch was
+// trying to do
+func SummarizeAbandonedWork(g git.Service, client *ai.Client, w AbandonedWork) (string, error) {
+	diff, err := g.Run("diff", w.Base+"..."+w.Branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Branch: %s\n\nCommits:\n%s\n", w.Branch, git.FormatCommits(w.Commits))
+	fmt.Fprintf(&b, "Diff:\n```diff\n%s\n```\n", truncateForPrompt(diff, maxAbandonedDiffSize))
+
+	return client.ForTask(ai.TaskReview).Complete(
+		`You summarize unmerged work from a git branch that is being deleted, so its ideas can be picked up later. In a short paragraph and a few bullet points, describe what the work set out to do, what it got done, and what looks unfinished. Use Markdown. Don't invent details the commits and diff don't show.`,
+		b.String(),
+	)
+}
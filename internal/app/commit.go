@@ -2,13 +2,18 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/batch"
 	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/issues"
 	"github.com/crazywolf132/sage/internal/ui"
 )
 
@@ -95,10 +100,21 @@ var sensitivePatterns = []SensitivePattern{
 	},
 }
 
+// sensitiveRegexps holds sensitivePatterns compiled, in the same order
+var sensitiveRegexps = func() []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(sensitivePatterns))
+	for i, p := range sensitivePatterns {
+		res[i] = regexp.MustCompile(p.Pattern)
+	}
+	return res
+}()
+
 // Finding represents a detected sensitive data match
 type Finding struct {
 	Pattern  *SensitivePattern
 	Line     string
+	File     string
+	LineNum  int
 	Category string
 }
 
@@ -120,12 +136,23 @@ type CommitOptions struct {
 	AutoAccept bool
 	// ChangeType allows overriding the commit type (feat, fix, etc)
 	ChangeType string
-	// Amend determines if the last commit should be amended
+	// Amend determines if the last commit should be amended. With UseAI the
+	// message is regenerated from the whole amended commit.
 	Amend bool
+	// SubjectOnly, when amending with UseAI, regenerates only the subject
+	// line and keeps the existing body
+	SubjectOnly bool
 	// OnlyStaged determines if only staged changes should be committed
 	OnlyStaged bool
 	// Interactive determines if the user should interactively select files
 	Interactive bool
+	// Only restricts the commit to paths matching these patterns (globs, dir/..., or prefixes),
+	// bypassing the staged/unstaged decision tree
+	Only []string
+	// Edit opens $EDITOR to compose the message, pre-filled with Message or an AI suggestion
+	Edit bool
+	// Tracker, when set, links the branch's ticket from the message
+	Tracker issues.Tracker
 }
 
 // CommitResult contains the outcome of a commit operation.
@@ -179,18 +206,15 @@ func detectSensitiveData(g git.Service) ([]Finding, error) {
 	}
 
 	var findings []Finding
-	for _, pattern := range sensitivePatterns {
-		matches, err := g.GrepDiff(diff, pattern.Pattern)
-		if err != nil {
-			continue
-		}
-		for _, match := range matches {
-			findings = append(findings, Finding{
-				Pattern:  &pattern,
-				Line:     match,
-				Category: pattern.Category,
-			})
-		}
+	for _, m := range g.GrepDiff(diff, sensitiveRegexps) {
+		pattern := &sensitivePatterns[m.Pattern]
+		findings = append(findings, Finding{
+			Pattern:  pattern,
+			Line:     strings.TrimSpace(m.Text),
+			File:     m.File,
+			LineNum:  m.Line,
+			Category: pattern.Category,
+		})
 	}
 
 	return findings, nil
@@ -204,7 +228,7 @@ func formatFindings(findings []Finding) string {
 
 	var critical, high, medium []string
 	for _, f := range findings {
-		msg := fmt.Sprintf("- %s: %s", f.Pattern.Message, f.Line)
+		msg := fmt.Sprintf("- %s at %s:%d: %s", f.Pattern.Message, f.File, f.LineNum, f.Line)
 		switch f.Pattern.Level {
 		case Critical:
 			critical = append(critical, msg)
@@ -240,6 +264,7 @@ func formatFindings(findings []Finding) string {
 	result.WriteString("1. Remove sensitive data from the changes\n")
 	result.WriteString("2. Consider using environment variables or a secrets manager\n")
 	result.WriteString("3. If these are test credentials, use placeholder values\n")
+	result.WriteString("4. If a secret was already committed, remove it from history with 'sage purge'\n")
 
 	return result.String()
 }
@@ -253,22 +278,49 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 	// Check if only-staged should be the default from config
 	if !opts.OnlyStaged && !opts.Interactive {
 		// Check if user has configured a default behavior for commit
-		onlyStagedDefault := config.Get("commit.only_staged_default", false) == "true"
+		onlyStagedDefault := config.Get("commit.only_staged_default", true) == "true"
 		if onlyStagedDefault {
 			opts.OnlyStaged = true
 		}
 	}
 
+	if opts.UseAI && !config.AIEnabled("") {
+		return result, fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+	}
+
+	if !opts.Edit && config.Get("commit.editor", true) == "true" && opts.Message == "" {
+		opts.Edit = true
+	}
+
+	// Without prompts the message has to come from a flag or AI
+	if batch.Enabled() {
+		if opts.Interactive {
+			return result, batch.NeedsInput("sage commit -i picks files interactively", "name them with --only instead")
+		}
+		if opts.Message == "" && !opts.UseAI && !opts.Amend {
+			return result, batch.NeedsInput("sage commit needs a message", "pass -m, or --ai to generate one")
+		}
+		opts.Edit = false
+		opts.AutoAccept = true
+	}
+
+	if opts.SubjectOnly && !(opts.Amend && opts.UseAI) {
+		return result, fmt.Errorf("--subject-only regenerates the subject of the last commit, so use it with --amend --ai")
+	}
+
 	// If amend is set, check that there is a previous commit.
+	var previousMessage string
 	if opts.Amend {
 		// Get the last commit message.
 		lastMessageOutput, err := g.Run("log", "--format=%B", "-n", "1")
 		if err != nil {
 			return result, fmt.Errorf("failed to get last commit message: %w", err)
 		}
-		// If no message is set, use the last commit message by default.
-		if opts.Message == "" {
-			opts.Message = strings.TrimSpace(lastMessageOutput)
+		previousMessage = strings.TrimSpace(lastMessageOutput)
+		// If no message is set, use the last commit message by default,
+		// unless AI is to write a new one from it.
+		if opts.Message == "" && !opts.UseAI {
+			opts.Message = previousMessage
 		}
 	}
 
@@ -287,6 +339,9 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 
 		// For high/medium findings, show warning but allow commit
 		ui.Warning(formatFindings(findings))
+		if !opts.AllowEmpty && batch.Enabled() {
+			return result, fmt.Errorf("commit cancelled due to sensitive data; commit interactively to go ahead anyway")
+		}
 		if !opts.AllowEmpty {
 			var proceed bool
 			prompt := &survey.Confirm{
@@ -315,9 +370,11 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 	// Parse files from status to track both staged and unstaged
 	var unstagedFiles []string
 	var stagedFiles []string
+	var untrackedFiles []string
+	var partiallyStaged []string
 	stats := CommitResultStats{}
 
-	for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+	for _, line := range porcelainLines(status) {
 		if line == "" {
 			continue
 		}
@@ -342,10 +399,18 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 			stats.TotalStaged++
 		}
 
+		// Staged and changed again since: hunks picked with 'git add -p'
+		if x != ' ' && x != '?' && y != ' ' {
+			partiallyStaged = append(partiallyStaged, filePath)
+		}
+
 		// Check if file has unstaged changes (Y is not space)
 		if y != ' ' {
 			hasUnstagedChanges = true
 			unstagedFiles = append(unstagedFiles, filePath)
+			if x == '?' {
+				untrackedFiles = append(untrackedFiles, filePath)
+			}
 			stats.TotalUnstaged++
 		}
 	}
@@ -355,37 +420,52 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 		return result, fmt.Errorf("no changes to commit")
 	}
 
+	// Refuse staged files that usually hold secrets before asking for a message
+	if err := CheckStagedFiles(g); err != nil {
+		return result, err
+	}
+
+	// Path-restricted mode: stage and commit only the matching paths
+	var onlyPaths []string
+	if len(opts.Only) > 0 {
+		if opts.Amend {
+			return result, fmt.Errorf("--only cannot be combined with --amend")
+		}
+		onlyPaths, stats, err = stageOnlyPaths(g, status, opts.Only)
+		if err != nil {
+			return result, err
+		}
+		opts.Interactive = false
+		opts.OnlyStaged = true
+		hasStagedChanges = true
+	}
+
 	// Interactive mode: Let the user select which files to stage
 	if opts.Interactive && hasUnstagedChanges {
 		fmt.Println(ui.Bold("Select files to stage for this commit:"))
 
-		// Create checkboxes for all unstaged files
-		fileOptions := make([]string, 0, len(unstagedFiles))
-		defaultSelected := make([]string, 0)
-
-		// Include all files, with special handling for dot directories
-		for _, file := range unstagedFiles {
-			fileOptions = append(fileOptions, file)
-			// Auto-select .github files to ensure they're visible and included by default
-			if strings.Contains(file, ".github/") {
-				defaultSelected = append(defaultSelected, file)
-			}
-		}
-
 		// If there are no unstaged files but the interactive flag is set
-		if len(fileOptions) == 0 {
+		if len(unstagedFiles) == 0 {
 			fmt.Println(ui.Yellow("No unstaged files to select. Proceeding with already staged files."))
 			opts.OnlyStaged = true
 		} else {
-			// Ask user which files to stage
-			var selectedFiles []string
-			prompt := &survey.MultiSelect{
-				Message: "Select files to stage:",
-				Options: fileOptions,
-				Default: defaultSelected,
+			if ignored, err := offerIgnores(g, untrackedFiles); err != nil {
+				return result, fmt.Errorf("canceled: %w", err)
+			} else if ignored {
+				return Commit(g, opts)
 			}
 
-			if err := survey.AskOne(prompt, &selectedFiles); err != nil {
+			files := make([]FileStatus, 0, len(unstagedFiles))
+			for _, file := range unstagedFiles {
+				files = append(files, FileStatus{Path: file})
+			}
+
+			// Ask user which files to stage, auto-selecting .github files to
+			// ensure they're visible and included by default
+			selectedFiles, err := selectFiles("Select files to stage:", files, func(file string) bool {
+				return strings.Contains(file, ".github/")
+			})
+			if err != nil {
 				return result, fmt.Errorf("canceled: %w", err)
 			}
 
@@ -398,11 +478,11 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 					return result, fmt.Errorf("no files selected to commit")
 				}
 			} else {
-				// Stage the selected files - use Run method to stage each file
-				for _, file := range selectedFiles {
-					if _, err := g.Run("add", file); err != nil {
-						return result, fmt.Errorf("failed to stage %s: %w", file, err)
-					}
+				if err := CheckBlockedFiles(g, selectedFiles); err != nil {
+					return result, err
+				}
+				if err := stagePaths(g, selectedFiles); err != nil {
+					return result, err
 				}
 
 				fmt.Printf("%s Staged %d file(s)\n", ui.Green("✓"), len(selectedFiles))
@@ -414,9 +494,25 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 		}
 	}
 
+	// Files staged hunk by hunk would lose that selection to staging
+	// everything, so commit only what's staged unless told otherwise
+	stagingChosen := false
+	if !opts.OnlyStaged && len(partiallyStaged) > 0 && !opts.Interactive && len(onlyPaths) == 0 {
+		keep, err := keepPartialStaging(partiallyStaged)
+		if err != nil {
+			return result, err
+		}
+		opts.OnlyStaged = keep
+		stagingChosen = true
+	}
+
 	// Smart mode: If there are staged changes but --only-staged flag wasn't explicitly set,
 	// and there are also unstaged changes, ask the user what they want to do
-	if !opts.OnlyStaged && hasStagedChanges && hasUnstagedChanges && !opts.Interactive {
+	if !opts.OnlyStaged && hasStagedChanges && hasUnstagedChanges && !opts.Interactive && len(onlyPaths) == 0 && batch.Enabled() {
+		batch.Note("committing only the staged changes")
+		opts.OnlyStaged = true
+	}
+	if !opts.OnlyStaged && hasStagedChanges && hasUnstagedChanges && !opts.Interactive && len(onlyPaths) == 0 && !stagingChosen {
 		var choice string
 		prompt := &survey.Select{
 			Message: "You have both staged and unstaged changes. What would you like to do?",
@@ -442,7 +538,7 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 			if err == nil && stagedDiff != "" {
 				// Find up to 5 files to show as a summary
 				var stagedFiles []string
-				for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+				for _, line := range porcelainLines(status) {
 					if line == "" {
 						continue
 					}
@@ -470,7 +566,7 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 
 			fmt.Println("\n" + ui.Bold("Unstaged changes (will NOT be committed):"))
 			var unstagedFiles []string
-			for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+			for _, line := range porcelainLines(status) {
 				if line == "" {
 					continue
 				}
@@ -531,7 +627,7 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 
 	// Get list of changed files for metadata
 	var files []string
-	for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+	for _, line := range porcelainLines(status) {
 		if line == "" {
 			continue
 		}
@@ -542,28 +638,64 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 		}
 		files = append(files, path)
 	}
+	if len(onlyPaths) > 0 {
+		files = onlyPaths
+	}
+
+	// The repository's commit template, applied to messages not written in
+	// the editor, which starts from it instead
+	var tmpl *commitTemplate
+	if !opts.Amend {
+		if tmpl, err = loadCommitTemplate(g, branch, files); err != nil {
+			return result, err
+		}
+	}
+
+	// Compose the message in the editor, seeded with any message or AI suggestion
+	if opts.Edit {
+		diff, err := messageDiff(g, opts, onlyPaths)
+		if err != nil {
+			return result, err
+		}
+		initial := opts.Message
+		if initial == "" && opts.UseAI {
+			// Stopping the AI leaves the message to be written from scratch
+			if initial, err = suggestMessage(diff, previousMessage, opts.SubjectOnly); err != nil && !errors.Is(err, context.Canceled) {
+				return result, err
+			}
+		}
+		if tmpl != nil {
+			initial = tmpl.apply(initial)
+			tmpl = nil
+		}
+		if opts.Message, err = composeMessageInEditor(g, initial, diff, opts.UseConventional); err != nil {
+			return result, err
+		}
+	}
 
 	// If no commit message was provided...
 	if opts.Message == "" {
 		if opts.UseAI {
-			diff, err := g.GetDiff()
+			diff, err := messageDiff(g, opts, onlyPaths)
 			if err != nil {
-				return result, fmt.Errorf("failed to get diff: %w", err)
-			}
-			client := ai.NewClient("", ai.NewConfigAdapter(config.Get))
-			if client.APIKey == "" {
-				return result, fmt.Errorf("AI features require an OpenAI API key")
-			}
-			aiMsg, err := client.GenerateCommitMessage(diff)
-			if err != nil {
-				return result, fmt.Errorf("failed to generate AI commit message: %w", err)
+				return result, err
 			}
-			fmt.Printf("Generated commit message: %q\n", aiMsg)
-			if opts.AutoAccept {
+			aiMsg, err := suggestMessage(diff, previousMessage, opts.SubjectOnly)
+			switch {
+			case errors.Is(err, context.Canceled) && !batch.Enabled():
+				fmt.Println(ui.Yellow("Stopped generating; write the message yourself"))
+				if opts.Message, err = askCommitMessage(opts.UseConventional); err != nil {
+					return result, err
+				}
+			case err != nil:
+				return result, err
+			case opts.AutoAccept:
 				// Automatically accept the AI message
+				fmt.Printf("Generated commit message: %q\n", aiMsg)
 				opts.Message = aiMsg
-			} else {
+			default:
 				// Otherwise, prompt the user.
+				fmt.Printf("Generated commit message: %q\n", aiMsg)
 				var choice string
 				err = survey.AskOne(&survey.Select{
 					Message: "Choose an option:",
@@ -576,7 +708,6 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 				switch choice {
 				case "Accept":
 					opts.Message = aiMsg
-					break
 				case "Change type":
 					newType := ""
 					err = survey.AskOne(&survey.Select{
@@ -589,37 +720,14 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 						return result, err
 					}
 					opts.Message = changeCommitType(aiMsg, newType)
-					break
 				case "Enter manually":
-					msg, scope, ctype, err := ui.AskCommitMessage(opts.UseConventional)
-					if err != nil {
+					if opts.Message, err = askCommitMessage(opts.UseConventional); err != nil {
 						return result, err
 					}
-					if opts.UseConventional {
-						if scope != "" {
-							opts.Message = fmt.Sprintf("%s(%s): %s", ctype, scope, msg)
-						} else {
-							opts.Message = fmt.Sprintf("%s: %s", ctype, msg)
-						}
-					} else {
-						opts.Message = msg
-					}
 				}
 			}
-		} else {
-			msg, scope, ctype, err := ui.AskCommitMessage(opts.UseConventional)
-			if err != nil {
-				return result, err
-			}
-			if opts.UseConventional {
-				if scope != "" {
-					opts.Message = fmt.Sprintf("%s(%s): %s", ctype, scope, msg)
-				} else {
-					opts.Message = fmt.Sprintf("%s: %s", ctype, msg)
-				}
-			} else {
-				opts.Message = msg
-			}
+		} else if opts.Message, err = askCommitMessage(opts.UseConventional); err != nil {
+			return result, err
 		}
 	}
 
@@ -627,27 +735,54 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 	if opts.ChangeType != "" {
 		opts.Message = changeCommitType(opts.Message, opts.ChangeType)
 	}
+	if tmpl != nil {
+		opts.Message = tmpl.apply(opts.Message)
+	}
+	opts.Message = linkTicket(opts.Message, branchTicket(opts.Tracker, branch))
+	if err := checkCommitMessage(opts.Message); err != nil {
+		return result, err
+	}
 
 	// Stage all changes if not using only staged changes
 	if !opts.OnlyStaged {
-		if err := g.StageAll(); err != nil {
+		if err := StageAll(g); err != nil {
 			return result, err
 		}
 	}
+	if err := CheckStagedFiles(g); err != nil {
+		return result, err
+	}
 
-	// Create the commit with the final message and options
-	if opts.Amend {
-		if shellGit, ok := g.(*git.ShellGit); ok {
-			if err := shellGit.CommitAmend(opts.Message, opts.AllowEmpty, !opts.OnlyStaged); err != nil {
-				return result, fmt.Errorf("failed to amend commit: %w", err)
+	JournalSnapshot(g, "sage commit")
+
+	// Create the commit with the final message and options, with the
+	// timestamps normalized if the user opted into that
+	err = withCommitDate(func() error {
+		if opts.Amend {
+			if shellGit, ok := g.(*git.ShellGit); ok {
+				if err := shellGit.CommitAmend(opts.Message, opts.AllowEmpty, !opts.OnlyStaged); err != nil {
+					return fmt.Errorf("failed to amend commit: %w", err)
+				}
+				return nil
 			}
-		} else {
-			return result, fmt.Errorf("amend flag is not supported for this git implementation")
+			return fmt.Errorf("amend flag is not supported for this git implementation")
+		}
+		if len(onlyPaths) > 0 {
+			if err := g.CommitPaths(opts.Message, opts.AllowEmpty, onlyPaths); err != nil {
+				return fmt.Errorf("failed to commit: %w", err)
+			}
+			return nil
 		}
-	} else {
 		if err := g.Commit(opts.Message, opts.AllowEmpty, !opts.OnlyStaged); err != nil {
-			return result, fmt.Errorf("failed to commit: %w", err)
+			return fmt.Errorf("failed to commit: %w", err)
 		}
+		return nil
+	})
+	if errors.Is(err, git.ErrMergeConflict) {
+		return result, fmt.Errorf("%w\nResolve the conflicts first, for example with 'sage resolve'", err)
+	}
+	if err != nil {
+		return result, err
 	}
 
 	// Record the operation in undo history
@@ -669,3 +804,253 @@ func Commit(g git.Service, opts CommitOptions) (CommitResult, error) {
 
 	return result, nil
 }
+
+// commitDiff returns the diff the commit message should describe
+func commitDiff(g git.Service, onlyPaths []string) (string, error) {
+	var diff string
+	var err error
+	if len(onlyPaths) > 0 {
+		diff, err = g.Run(append([]string{"diff", "--cached", "--"}, onlyPaths...)...)
+	} else {
+		diff, err = g.GetDiff()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	return diff, nil
+}
+
+// keepPartialStaging tells the user which files are only partly staged and
+// asks whether to commit just the staged hunks, which batch mode and the
+// default answer do. It returns false only when they choose to commit
+// everything.
+func keepPartialStaging(files []string) (bool, error) {
+	shown := files
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	if batch.Enabled() {
+		batch.Note("committing only the staged hunks of %s", strings.Join(shown, ", "))
+		return true, nil
+	}
+
+	fmt.Println(ui.Bold("Partly staged (some hunks staged, others not):"))
+	for _, f := range shown {
+		fmt.Println("  " + ui.Yellow(f))
+	}
+	if len(files) > len(shown) {
+		fmt.Printf("  ... and %d more\n", len(files)-len(shown))
+	}
+
+	const keep = "Commit only the staged hunks"
+	const all = "Stage the rest too and commit everything"
+	var choice string
+	if err := survey.AskOne(&survey.Select{
+		Message: "Keep your partial staging?",
+		Options: []string{keep, all, "Cancel"},
+		Default: keep,
+	}, &choice); err != nil {
+		return false, fmt.Errorf("canceled: %w", err)
+	}
+	switch choice {
+	case keep:
+		return true, nil
+	case all:
+		return false, nil
+	}
+	return false, fmt.Errorf("commit canceled")
+}
+
+// askCommitMessage prompts for a message, in conventional form if asked for
+func askCommitMessage(conventional bool) (string, error) {
+	msg, scope, ctype, err := ui.AskCommitMessage(conventional)
+	if err != nil {
+		return "", err
+	}
+	if !conventional {
+		return msg, nil
+	}
+	if scope != "" {
+		return fmt.Sprintf("%s(%s): %s", ctype, scope, msg), nil
+	}
+	return fmt.Sprintf("%s: %s", ctype, msg), nil
+}
+
+// messageDiff returns the diff the commit message should describe: the
+// changes being committed or, when amending, the whole amended commit
+func messageDiff(g git.Service, opts CommitOptions, onlyPaths []string) (string, error) {
+	if !opts.Amend {
+		return commitDiff(g, onlyPaths)
+	}
+	parents, err := g.Run("log", "-1", "--format=%P")
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	if parent, _, _ := strings.Cut(strings.TrimSpace(parents), " "); parent != "" {
+		args := []string{"diff"}
+		if opts.OnlyStaged {
+			args = append(args, "--cached")
+		}
+		diff, err := g.Run(append(args, parent)...)
+		if err != nil {
+			return "", fmt.Errorf("failed to get diff: %w", err)
+		}
+		return diff, nil
+	}
+
+	// A root commit has no parent to diff against: give what it already
+	// holds followed by the changes being added to it
+	committed, err := g.Run("show", "--format=", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	adding, err := commitDiff(g, nil)
+	if err != nil {
+		return "", err
+	}
+	return committed + adding, nil
+}
+
+// suggestMessage asks AI for a commit message for diff. When the commit
+// had a previous message it is rewritten, or with subjectOnly just its
+// subject is, keeping the body.
+func suggestMessage(diff, previous string, subjectOnly bool) (string, error) {
+	if previous == "" {
+		return generateAICommitMessage(diff)
+	}
+	client, err := commitAIClient()
+	if err != nil {
+		return "", err
+	}
+	if subjectOnly {
+		_, body, _ := strings.Cut(previous, "\n")
+		body = strings.TrimSpace(body)
+		subject, err := previewAI(client, "Writing the commit subject", func(c *ai.Client) (string, error) {
+			return c.GenerateCommitSubject(diff, body)
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to generate AI commit subject: %w", err)
+		}
+		if body == "" {
+			return subject, nil
+		}
+		return subject + "\n\n" + body, nil
+	}
+	msg, err := previewAI(client, "Rewriting the commit message", func(c *ai.Client) (string, error) {
+		return c.RewriteCommitMessage(diff, previous)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate AI commit message: %w", err)
+	}
+	return msg, nil
+}
+
+// commitAIClient returns the AI client commit messages are written with
+func commitAIClient() (*ai.Client, error) {
+	client := ai.NewClient("", ai.NewConfigAdapter(config.Get))
+	if !client.Configured() {
+		return nil, fmt.Errorf("AI features require an API key, or a local model (sage config set ai.provider ollama)")
+	}
+	return client, nil
+}
+
+// generateAICommitMessage asks the configured AI provider for a commit message
+func generateAICommitMessage(diff string) (string, error) {
+	client, err := commitAIClient()
+	if err != nil {
+		return "", err
+	}
+	msg, err := previewAI(client, "Writing the commit message", func(c *ai.Client) (string, error) {
+		return c.GenerateCommitMessage(diff)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate AI commit message: %w", err)
+	}
+	return msg, nil
+}
+
+// previewAI runs generate with a copy of client that streams its answer to
+// a live preview, which Ctrl+C stops
+func previewAI(client *ai.Client, title string, generate func(c *ai.Client) (string, error)) (string, error) {
+	var out string
+	err := ui.StreamAI(title, func(ctx context.Context, onToken func(string)) error {
+		var err error
+		out, err = generate(client.Streaming(ctx, onToken))
+		return err
+	})
+	return out, err
+}
+
+// SuggestCommitMessage generates a message for the staged changes, or all
+// changes when nothing is staged, without committing anything
+func SuggestCommitMessage(g git.Service) (string, error) {
+	if !config.AIEnabled("") {
+		return "", fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+	}
+	diff, err := commitDiff(g, nil)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", fmt.Errorf("no changes to describe")
+	}
+	return generateAICommitMessage(diff)
+}
+
+// porcelainLines splits git status --porcelain output into its lines. Only
+// the trailing newline is trimmed: each line starts with a two-letter
+// status, and the first one's leading space (an unstaged change) matters.
+func porcelainLines(status string) []string {
+	return strings.Split(strings.TrimRight(status, "\n"), "\n")
+}
+
+// stageOnlyPaths stages the changed files matching the --only patterns and
+// returns them along with stats describing the commit they will produce.
+func stageOnlyPaths(g git.Service, status string, patterns []string) ([]string, CommitResultStats, error) {
+	var stats CommitResultStats
+	var matched []string
+
+	for _, line := range porcelainLines(status) {
+		if len(line) < 4 {
+			continue
+		}
+		x, y := line[0], line[1]
+		path := strings.TrimSpace(line[3:])
+
+		// For renames, commit both the removal of the old path and the new path
+		candidates := []string{path}
+		if strings.Contains(path, " -> ") {
+			parts := strings.SplitN(path, " -> ", 2)
+			candidates = []string{parts[1], parts[0]}
+		}
+
+		if len(FilterPathspecs(patterns, candidates)) == 0 {
+			stats.TotalUnstaged++
+			continue
+		}
+		matched = append(matched, candidates...)
+
+		switch {
+		case x == '?' || x == 'A':
+			stats.StagedAdded++
+		case x == 'D' || y == 'D':
+			stats.StagedDeleted++
+		default:
+			stats.StagedModified++
+		}
+		stats.TotalStaged++
+	}
+
+	if len(matched) == 0 {
+		return nil, stats, fmt.Errorf("no changes match %s", strings.Join(patterns, ", "))
+	}
+
+	if err := CheckBlockedFiles(g, matched); err != nil {
+		return nil, stats, err
+	}
+	if _, err := g.Run(append([]string{"add", "-A", "--"}, matched...)...); err != nil {
+		return nil, stats, fmt.Errorf("failed to stage matching paths: %w", err)
+	}
+
+	return matched, stats, nil
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// scissorsLine marks the start of the diff in an editor buffer; everything after it is ignored
+const scissorsLine = "# ------------------------ >8 ------------------------"
+
+var conventionalSubject = regexp.MustCompile(`^[a-z]+(\([^)]+\))?!?: \S`)
+
+// ResolveEditor returns the editor git itself would use: GIT_EDITOR, core.editor,
+// VISUAL, EDITOR, then vi.
+func ResolveEditor(g git.Service) string {
+	if e := os.Getenv("GIT_EDITOR"); e != "" {
+		return e
+	}
+	if e, err := g.GetConfigValue("core.editor"); err == nil && strings.TrimSpace(e) != "" {
+		return strings.TrimSpace(e)
+	}
+	if e := os.Getenv("VISUAL"); e != "" {
+		return e
+	}
+	if e := os.Getenv("EDITOR"); e != "" {
+		return e
+	}
+	return "vi"
+}
+
+// composeMessageInEditor opens the user's editor on a COMMIT_EDITMSG buffer
+// pre-filled with initial, a summary of the changes, and (with commit.verbose)
+// the diff, then returns the cleaned-up message.
+func composeMessageInEditor(g git.Service, initial, diff string, conventional bool) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to locate git directory: %w", err)
+	}
+	path := filepath.Join(strings.TrimSpace(gitDir), "COMMIT_EDITMSG")
+
+	verbose, _ := g.GetConfigValue("commit.verbose")
+	buf := buildEditorBuffer(initial, diff, strings.TrimSpace(verbose) == "true")
+	if err := os.WriteFile(path, []byte(buf), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fields := strings.Fields(ResolveEditor(g))
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor exited with an error: %w", err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	msg := parseEditedMessage(string(edited))
+	if msg == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	if conventional && !conventionalSubject.MatchString(strings.SplitN(msg, "\n", 2)[0]) {
+		return "", fmt.Errorf("commit subject %q is not a conventional commit (type(scope): description)", strings.SplitN(msg, "\n", 2)[0])
+	}
+	return msg, nil
+}
+
+// buildEditorBuffer lays out the buffer like git commit does
+func buildEditorBuffer(initial, diff string, verbose bool) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(initial))
+	b.WriteString("\n\n")
+	b.WriteString("# Please enter the commit message for your changes. Lines starting\n")
+	b.WriteString("# with '#' will be ignored, and an empty message aborts the commit.\n")
+
+	if files := diffFiles(diff); len(files) > 0 {
+		b.WriteString("#\n# Changes to be committed:\n")
+		for _, f := range files {
+			b.WriteString("#\t" + f + "\n")
+		}
+	}
+
+	if verbose && strings.TrimSpace(diff) != "" {
+		b.WriteString("#\n")
+		b.WriteString(scissorsLine + "\n")
+		b.WriteString("# Do not modify or remove the line above.\n")
+		b.WriteString("# Everything below it will be ignored.\n")
+		b.WriteString(diff)
+	}
+	return b.String()
+}
+
+// parseEditedMessage strips comments and everything below the scissors line,
+// trims trailing whitespace, and collapses repeated blank lines
+func parseEditedMessage(buf string) string {
+	if i := strings.Index(buf, scissorsLine); i >= 0 {
+		buf = buf[:i]
+	}
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(strings.ReplaceAll(buf, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank || len(lines) == 0 {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// diffFiles lists the files touched by a unified diff
+func diffFiles(diff string) []string {
+	var files []string
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				files = append(files, line[i+3:])
+			}
+		}
+	}
+	return files
+}
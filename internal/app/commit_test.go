@@ -0,0 +1,57 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestCommitKeepsPartialStaging(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("notes.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\n", "Add notes")
+	batch.Enable()
+	t.Cleanup(batch.Disable)
+
+	// Stage the change at the top but not the one at the bottom, as
+	// 'git add -p' would
+	r.write("notes.txt", "ONE\ntwo\nthree\nfour\nfive\nsix\nseven\n")
+	r.git("add", "notes.txt")
+	r.write("notes.txt", "ONE\ntwo\nthree\nfour\nfive\nsix\nSEVEN\n")
+
+	if _, err := Commit(git.NewShellGit(), CommitOptions{Message: "Capitalise the first note"}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := r.git("show", "HEAD:notes.txt"); got != "ONE\ntwo\nthree\nfour\nfive\nsix\nseven" {
+		t.Errorf("committed notes.txt =\n%s\nwant only the staged hunk", got)
+	}
+	if got := r.read("notes.txt"); got != "ONE\ntwo\nthree\nfour\nfive\nsix\nSEVEN\n" {
+		t.Errorf("notes.txt = %q, want the unstaged hunk still there", got)
+	}
+	if got := r.git("status", "--porcelain"); got != "M notes.txt" {
+		t.Errorf("status = %q, want the unstaged hunk left unstaged", got)
+	}
+}
+
+func TestCommitOnlyUnstagedFirstFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "a\n", "Add a")
+	r.commit("b.txt", "b\n", "Add b")
+	batch.Enable()
+	t.Cleanup(batch.Disable)
+
+	// a.txt comes first in the status, as " M a.txt"; its leading space
+	// has to survive for the path to be read right
+	r.write("a.txt", "A\n")
+	r.write("b.txt", "B\n")
+
+	if _, err := Commit(git.NewShellGit(), CommitOptions{Message: "Capitalise a", Only: []string{"a.txt"}}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD"); got != "a.txt" {
+		t.Errorf("committed %q, want only a.txt", got)
+	}
+	if got := r.git("status", "--porcelain"); got != "M b.txt" {
+		t.Errorf("status = %q, want b.txt left unstaged", got)
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+)
+
+// CommitDate returns the timestamp sage records as a commit's author and
+// committer date when the commit.timestamp_round or commit.timezone settings
+// ask for it. ok is false when neither is set, and git picks the time itself.
+func CommitDate(now time.Time) (date time.Time, ok bool, err error) {
+	round := strings.TrimSpace(config.Get("commit.timestamp_round", true))
+	zone := strings.TrimSpace(config.Get("commit.timezone", true))
+	if round == "" && zone == "" {
+		return now, false, nil
+	}
+
+	if zone != "" {
+		loc, err := commitLocation(zone)
+		if err != nil {
+			return now, false, err
+		}
+		now = now.In(loc)
+	}
+
+	// Round on the zone's clock; Truncate would round the absolute time,
+	// which is off by the half hour in zones such as +0530
+	y, mo, d := now.Date()
+	h, mi, _ := now.Clock()
+	switch round {
+	case "", "none":
+	case "minute":
+		now = time.Date(y, mo, d, h, mi, 0, 0, now.Location())
+	case "hour":
+		now = time.Date(y, mo, d, h, 0, 0, 0, now.Location())
+	case "day":
+		now = time.Date(y, mo, d, 0, 0, 0, 0, now.Location())
+	default:
+		return now, false, fmt.Errorf("invalid commit.timestamp_round %q (use minute, hour or day)", round)
+	}
+	return now, true, nil
+}
+
+// commitLocation parses commit.timezone: UTC, an IANA name such as
+// Europe/Berlin, or a fixed offset such as +0200
+func commitLocation(zone string) (*time.Location, error) {
+	if t, err := time.Parse("-0700", zone); err == nil {
+		return t.Location(), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit.timezone %q: use UTC, a name like Europe/Berlin or an offset like +0200", zone)
+	}
+	return loc, nil
+}
+
+// withCommitDate runs commit with GIT_AUTHOR_DATE and GIT_COMMITTER_DATE set
+// to the normalized commit date, when one is configured
+func withCommitDate(commit func() error) error {
+	date, ok, err := CommitDate(time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return commit()
+	}
+
+	stamp := date.Format(time.RFC1123Z)
+	for _, key := range []string{"GIT_AUTHOR_DATE", "GIT_COMMITTER_DATE"} {
+		if prev, set := os.LookupEnv(key); set {
+			defer os.Setenv(key, prev)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Setenv(key, stamp)
+	}
+	return commit()
+}
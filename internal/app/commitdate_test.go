@@ -0,0 +1,113 @@
+package app
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+)
+
+// setCommitDateConfig sets commit.timestamp_round and commit.timezone in
+// the test repository's config, unsetting them after the test
+func setCommitDateConfig(t *testing.T, round, zone string) {
+	t.Helper()
+	for key, value := range map[string]string{"commit.timestamp_round": round, "commit.timezone": zone} {
+		if value == "" {
+			continue
+		}
+		if err := config.Set(key, value, false); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = config.Unset(key, false) })
+	}
+}
+
+func TestCommitDate(t *testing.T) {
+	newTestRepo(t)
+	now := time.Date(2024, 3, 9, 22, 47, 31, 500, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+
+	tests := []struct {
+		name, round, zone string
+		want              time.Time
+		ok                bool
+	}{
+		{"unset", "", "", now, false},
+		{"none", "none", "", now, true},
+		{"minute", "minute", "", time.Date(2024, 3, 9, 22, 47, 0, 0, time.UTC), true},
+		{"hour", "hour", "", time.Date(2024, 3, 9, 22, 0, 0, 0, time.UTC), true},
+		{"day", "day", "", time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), true},
+		{"utc", "", "UTC", now, true},
+		{"named zone", "", "Europe/Berlin", now.In(berlin), true},
+		// The day is the zone's: 22:47 UTC is already the 10th in +0200
+		{"offset rounded to day", "day", "+0200", time.Date(2024, 3, 10, 0, 0, 0, 0, time.FixedZone("", 2*60*60)), true},
+		{"negative offset", "hour", "-0530", time.Date(2024, 3, 9, 17, 0, 0, 0, time.FixedZone("", -(5*60+30)*60)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCommitDateConfig(t, tt.round, tt.zone)
+			got, ok, err := CommitDate(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("CommitDate = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+			_, gotOffset := got.Zone()
+			_, wantOffset := tt.want.Zone()
+			if gotOffset != wantOffset {
+				t.Errorf("offset = %d, want %d", gotOffset, wantOffset)
+			}
+		})
+	}
+}
+
+func TestCommitDateRejectsBadSettings(t *testing.T) {
+	newTestRepo(t)
+	tests := []struct {
+		name, round, zone string
+	}{
+		{"unknown rounding", "week", ""},
+		{"unknown zone", "", "Mars/Olympus_Mons"},
+		{"offset out of range", "", "+2500"},
+		{"offset with colon", "", "+02:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCommitDateConfig(t, tt.round, tt.zone)
+			if _, _, err := CommitDate(time.Now()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestWithCommitDateSetsAndRestoresEnv(t *testing.T) {
+	newTestRepo(t)
+	setCommitDateConfig(t, "day", "UTC")
+	t.Setenv("GIT_AUTHOR_DATE", "earlier")
+	os.Unsetenv("GIT_COMMITTER_DATE")
+
+	var author, committer string
+	err := withCommitDate(func() error {
+		author, committer = os.Getenv("GIT_AUTHOR_DATE"), os.Getenv("GIT_COMMITTER_DATE")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, err := time.Parse(time.RFC1123Z, author)
+	if err != nil || committer != author || date.Hour() != 0 || date.Minute() != 0 {
+		t.Errorf("commit ran with author %q and committer %q, want the same midnight", author, committer)
+	}
+	if got := os.Getenv("GIT_AUTHOR_DATE"); got != "earlier" {
+		t.Errorf("GIT_AUTHOR_DATE = %q after the commit, want it restored", got)
+	}
+	if _, set := os.LookupEnv("GIT_COMMITTER_DATE"); set {
+		t.Error("GIT_COMMITTER_DATE left set after the commit")
+	}
+}
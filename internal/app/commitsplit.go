@@ -0,0 +1,580 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// SplitPlan divides the staged changes into a series of commits. Each line
+// of every hunk belongs to one commit; files git shows no hunks for, such
+// as binary files, belong to one as a whole.
+type SplitPlan struct {
+	// Base is HEAD when the plan was made, and Tree the staged tree the
+	// last commit ends on
+	Base, Tree string
+	Files      []FileHunks
+	Messages   []string
+
+	// lines[f][h][l] is the commit line l of hunk h of file f goes in;
+	// whole[f] is the commit a file without hunks goes in
+	lines [][][]int
+	whole []int
+}
+
+// splitUnit is a hunk, or a file without hunks when hunk is -1, as the AI
+// is shown them
+type splitUnit struct {
+	file, hunk int
+}
+
+// maxSplitHunkLines is how much of each hunk the AI is shown
+const maxSplitHunkLines = 30
+
+// newSplitPlan puts every change in one commit
+func newSplitPlan(base, tree string, files []FileHunks) *SplitPlan {
+	p := &SplitPlan{Base: base, Tree: tree, Files: files, Messages: []string{""}}
+	p.lines = make([][][]int, len(files))
+	p.whole = make([]int, len(files))
+	for f, fh := range files {
+		p.lines[f] = make([][]int, len(fh.Hunks))
+		for h, hunk := range fh.Hunks {
+			p.lines[f][h] = make([]int, len(hunk.Lines))
+		}
+	}
+	return p
+}
+
+func (p *SplitPlan) units() []splitUnit {
+	var out []splitUnit
+	for f, fh := range p.Files {
+		if len(fh.Hunks) == 0 {
+			out = append(out, splitUnit{f, -1})
+		}
+		for h := range fh.Hunks {
+			out = append(out, splitUnit{f, h})
+		}
+	}
+	return out
+}
+
+func (p *SplitPlan) assign(u splitUnit, commit int) {
+	if u.hunk < 0 {
+		p.whole[u.file] = commit
+		return
+	}
+	for l := range p.lines[u.file][u.hunk] {
+		p.lines[u.file][u.hunk][l] = commit
+	}
+}
+
+// fileShare counts the changes of file f that go in commits up to k, out
+// of all of them
+func (p *SplitPlan) fileShare(f, k int) (upTo, total int) {
+	if len(p.Files[f].Hunks) == 0 {
+		if p.whole[f] <= k {
+			return 1, 1
+		}
+		return 0, 1
+	}
+	for _, hunk := range p.lines[f] {
+		for _, c := range hunk {
+			total++
+			if c <= k {
+				upTo++
+			}
+		}
+	}
+	return upTo, total
+}
+
+// selectWhere copies the files with the lines whose commit passes keep
+// selected
+func (p *SplitPlan) selectWhere(keep func(commit int) bool) []FileHunks {
+	out := make([]FileHunks, len(p.Files))
+	for f, fh := range p.Files {
+		out[f] = fh
+		out[f].Hunks = make([]Hunk, len(fh.Hunks))
+		for h, hunk := range fh.Hunks {
+			out[f].Hunks[h] = hunk
+			out[f].Hunks[h].Lines = make([]HunkLine, len(hunk.Lines))
+			for l, line := range hunk.Lines {
+				line.Selected = keep(p.lines[f][h][l])
+				out[f].Hunks[h].Lines[l] = line
+			}
+		}
+	}
+	return out
+}
+
+// CommitFiles lists the files commit k changes, with the lines it adds and
+// removes in each
+func (p *SplitPlan) CommitFiles(k int) []string {
+	var out []string
+	for f, fh := range p.Files {
+		if len(fh.Hunks) == 0 {
+			if p.whole[f] == k {
+				out = append(out, fh.Path)
+			}
+			continue
+		}
+		adds, dels := 0, 0
+		for h, hunk := range fh.Hunks {
+			for l, line := range hunk.Lines {
+				if p.lines[f][h][l] != k {
+					continue
+				}
+				if line.Op == '+' {
+					adds++
+				} else {
+					dels++
+				}
+			}
+		}
+		if adds+dels > 0 {
+			out = append(out, fmt.Sprintf("%s %s %s", fh.Path, ui.Green(fmt.Sprintf("+%d", adds)), ui.Red(fmt.Sprintf("-%d", dels))))
+		}
+	}
+	return out
+}
+
+// CommitDiff shows what commit k changes
+func (p *SplitPlan) CommitDiff(k int) string {
+	diff := HunkPatch(p.selectWhere(func(c int) bool { return c == k }))
+	for f, fh := range p.Files {
+		if len(fh.Hunks) == 0 && p.whole[f] == k {
+			diff += fmt.Sprintf("%s: binary file, empty file or mode change\n", fh.Path)
+		}
+	}
+	return diff
+}
+
+// Reassign lets pick choose the lines of commit k among those no earlier
+// commit takes. Lines it had and loses move to the next commit, and
+// commits left empty are dropped. Files without hunks stay where they are.
+func (p *SplitPlan) Reassign(k int, pick func([]FileHunks) ([]FileHunks, error)) error {
+	// The picker is shown only the lines still to commit; where tells
+	// where each came from
+	type origin struct{ f, h, l int }
+	var shown []FileHunks
+	var where [][][]origin
+	for f, fh := range p.Files {
+		file := FileHunks{Path: fh.Path, Header: fh.Header}
+		var fileWhere [][]origin
+		for h, hunk := range fh.Hunks {
+			cp := hunk
+			cp.Lines = nil
+			var hunkWhere []origin
+			for l, line := range hunk.Lines {
+				if c := p.lines[f][h][l]; c >= k {
+					line.Selected = c == k
+					cp.Lines = append(cp.Lines, line)
+					hunkWhere = append(hunkWhere, origin{f, h, l})
+				}
+			}
+			if len(cp.Lines) > 0 {
+				file.Hunks = append(file.Hunks, cp)
+				fileWhere = append(fileWhere, hunkWhere)
+			}
+		}
+		if len(file.Hunks) > 0 {
+			shown = append(shown, file)
+			where = append(where, fileWhere)
+		}
+	}
+	if len(shown) == 0 {
+		return nil
+	}
+
+	picked, err := pick(shown)
+	if err != nil || picked == nil {
+		return err
+	}
+	for i, fh := range picked {
+		for j, hunk := range fh.Hunks {
+			for m, line := range hunk.Lines {
+				o := where[i][j][m]
+				switch c := &p.lines[o.f][o.h][o.l]; {
+				case line.Selected:
+					*c = k
+				case *c == k:
+					*c = k + 1
+				}
+			}
+		}
+	}
+	if k+1 == len(p.Messages) {
+		p.Messages = append(p.Messages, "")
+	}
+	p.dropEmpty()
+	return nil
+}
+
+// dropEmpty removes commits with no changes, renumbering the rest
+func (p *SplitPlan) dropEmpty() {
+	used := make([]bool, len(p.Messages))
+	for f := range p.Files {
+		if len(p.Files[f].Hunks) == 0 {
+			used[p.whole[f]] = true
+		}
+		for _, hunk := range p.lines[f] {
+			for _, c := range hunk {
+				used[c] = true
+			}
+		}
+	}
+	renumber := make([]int, len(p.Messages))
+	var messages []string
+	for c, u := range used {
+		renumber[c] = len(messages)
+		if u {
+			messages = append(messages, p.Messages[c])
+		}
+	}
+	p.Messages = messages
+	for f := range p.Files {
+		p.whole[f] = renumber[p.whole[f]]
+		for _, hunk := range p.lines[f] {
+			for l, c := range hunk {
+				hunk[l] = renumber[c]
+			}
+		}
+	}
+}
+
+// splitAnswer is what the AI is asked to answer with
+type splitAnswer struct {
+	Commits []struct {
+		Message string `json:"message"`
+		Hunks   []int  `json:"hunks"`
+	} `json:"commits"`
+}
+
+// applySplitAnswer fills the plan in from the AI's answer. Hunks it
+// numbers twice stay in the first commit that has them, and hunks it
+// leaves out go in the last.
+func (p *SplitPlan) applySplitAnswer(answer string) error {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("the AI didn't answer with a plan")
+	}
+	var a splitAnswer
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &a); err != nil {
+		return fmt.Errorf("failed to read the AI's plan: %w", err)
+	}
+
+	units := p.units()
+	placed := make([]bool, len(units))
+	var messages []string
+	for _, c := range a.Commits {
+		k := len(messages)
+		n := 0
+		for _, id := range c.Hunks {
+			if id < 1 || id > len(units) || placed[id-1] {
+				continue
+			}
+			placed[id-1] = true
+			p.assign(units[id-1], k)
+			n++
+		}
+		if n > 0 {
+			messages = append(messages, strings.TrimSpace(c.Message))
+		}
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("the AI's plan has no commits")
+	}
+	for i, u := range units {
+		if !placed[i] {
+			p.assign(u, len(messages)-1)
+		}
+	}
+	p.Messages = messages
+	return nil
+}
+
+// splitPrompt numbers the plan's hunks for the AI
+func (p *SplitPlan) splitPrompt() string {
+	var b strings.Builder
+	b.WriteString("Staged changes, by numbered hunk:\n")
+	for i, u := range p.units() {
+		fh := p.Files[u.file]
+		if u.hunk < 0 {
+			fmt.Fprintf(&b, "\n[%d] %s (whole file: binary, empty or a mode change)\n", i+1, fh.Path)
+			continue
+		}
+		h := fh.Hunks[u.hunk]
+		fmt.Fprintf(&b, "\n[%d] %s %s\n", i+1, fh.Path, h.Header())
+		for n, l := range h.Lines {
+			if n == maxSplitHunkLines {
+				fmt.Fprintf(&b, "... %d more lines\n", len(h.Lines)-n)
+				break
+			}
+			b.WriteString(string(l.Op) + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+// PlanSplit reads the staged changes and asks the AI to divide them into
+// a series of commits that each make one coherent change
+func PlanSplit(g git.Service, client *ai.Client) (*SplitPlan, error) {
+	base, err := g.Run("rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("--split needs a commit to build on: %w", err)
+	}
+	tree, err := g.Run("write-tree")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the staged changes: %w", err)
+	}
+	diff, err := g.Run("diff", "--cached", "-U0", "--no-color", "--no-ext-diff", "--no-renames")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the staged changes: %w", err)
+	}
+	names, err := g.Run("diff", "--cached", "--name-only", "--no-renames")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the staged files: %w", err)
+	}
+
+	files := ParseHunks(diff)
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[f.Path] = true
+	}
+	for _, name := range strings.Split(strings.TrimSpace(names), "\n") {
+		if name != "" && !seen[name] {
+			files = append(files, FileHunks{Path: name})
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no staged changes to split")
+	}
+
+	p := newSplitPlan(strings.TrimSpace(base), strings.TrimSpace(tree), files)
+	answer, err := previewAI(client, "Planning the commits", func(c *ai.Client) (string, error) {
+		return c.ForTask(ai.TaskCommit).Complete(
+			`You split staged changes into a series of small commits, each one coherent change, ordered so that each builds on the ones before it. Keep hunks that depend on each other together. Every hunk goes in exactly one commit. Answer with only JSON: {"commits": [{"message": "<commit message>", "hunks": [<hunk numbers>]}]}. Messages are conventional commits with an imperative subject of at most 72 characters.`,
+			p.splitPrompt(),
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan the commits: %w", err)
+	}
+	if err := p.applySplitAnswer(answer); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// stageUpTo fills the index with Base and the changes of commits up to k:
+// files taken whole from Tree, and the picked lines of the rest patched in
+func (p *SplitPlan) stageUpTo(g git.Service, k int) error {
+	if _, err := g.Run("read-tree", p.Base); err != nil {
+		return err
+	}
+	upToK := p.selectWhere(func(c int) bool { return c <= k })
+	var partial []FileHunks
+	for f, fh := range p.Files {
+		upTo, total := p.fileShare(f, k)
+		switch {
+		case upTo == 0:
+		case upTo == total:
+			entry, err := g.Run("ls-tree", p.Tree, "--", fh.Path)
+			if err != nil {
+				return err
+			}
+			// mode type hash\tpath
+			if fields := strings.Fields(entry); len(fields) >= 3 {
+				_, err = g.Run("update-index", "--add", "--cacheinfo", fields[0]+","+fields[2]+","+fh.Path)
+			} else {
+				_, err = g.Run("update-index", "--force-remove", "--", fh.Path)
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			partial = append(partial, upToK[f])
+		}
+	}
+	_, err := ApplyHunks(g, partial)
+	return err
+}
+
+// SplitOptions configure CommitSplit
+type SplitOptions struct {
+	// Pick chooses hunks and lines, as 'sage stage --patch' does
+	Pick func([]FileHunks) ([]FileHunks, error)
+	// AutoAccept creates the commits as planned without asking
+	AutoAccept bool
+	// Tracker, when set, links the branch's ticket from the messages
+	Tracker issues.Tracker
+}
+
+// CommitSplit asks the AI to divide the staged changes into a series of
+// commits, then shows each one to be committed, reworded or given other
+// hunks before it's created. Stopping part way leaves the rest staged.
+// It returns the messages of the commits created.
+func CommitSplit(g git.Service, opts SplitOptions) ([]string, error) {
+	if !config.AIEnabled("") {
+		return nil, fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+	}
+	if batch.Enabled() {
+		opts.AutoAccept = true
+	}
+	client, err := commitAIClient()
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := g.Run("diff", "--cached", "--name-only")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the staged files: %w", err)
+	}
+	if strings.TrimSpace(staged) == "" {
+		fmt.Printf("%s Nothing staged, so staging every change to split\n", ui.Yellow("!"))
+		if err := StageAll(g); err != nil {
+			return nil, err
+		}
+	}
+	if err := CheckStagedFiles(g); err != nil {
+		return nil, err
+	}
+	findings, err := detectSensitiveData(g)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		if f.Pattern.Level == Critical || batch.Enabled() {
+			return nil, fmt.Errorf(formatFindings(findings))
+		}
+	}
+	if len(findings) > 0 {
+		ui.Warning(formatFindings(findings))
+	}
+
+	p, err := PlanSplit(g, client)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	ticket := branchTicket(opts.Tracker, branch)
+
+	if dryrun.Enabled() {
+		for k, msg := range p.Messages {
+			printSplitCommit(p, k)
+			dryrun.Record("git %s", dryrun.Quote([]string{"commit", "-m", msg}))
+		}
+		return p.Messages, nil
+	}
+
+	JournalSnapshot(g, "sage commit --split")
+	var created []string
+	for k := 0; k < len(p.Messages); {
+		printSplitCommit(p, k)
+		if !opts.AutoAccept {
+			choice, err := ui.AskSelect("Create this commit?",
+				[]string{"Commit", "Edit the message", "Change its hunks", "Show the diff", "Stop here, leaving the rest staged"},
+				"", "pass --accept to create every commit as planned")
+			if err != nil {
+				return created, restoreSplitIndex(g, p, err)
+			}
+			switch choice {
+			case "Edit the message":
+				msg, err := ui.AskInput("Commit message:", p.Messages[k], "pass --accept to create every commit as planned", survey.WithValidator(survey.Required))
+				if err != nil {
+					return created, restoreSplitIndex(g, p, err)
+				}
+				p.Messages[k] = strings.TrimSpace(msg)
+				continue
+			case "Change its hunks":
+				if err := p.Reassign(k, opts.Pick); err != nil {
+					return created, restoreSplitIndex(g, p, err)
+				}
+				continue
+			case "Show the diff":
+				if err := ui.Page(ui.HighlightDiff(p.CommitDiff(k))); err != nil {
+					fmt.Println(ui.HighlightDiff(p.CommitDiff(k)))
+				}
+				continue
+			case "Stop here, leaving the rest staged":
+				return created, restoreSplitIndex(g, p, nil)
+			}
+		}
+
+		msg := p.Messages[k]
+		if msg == "" {
+			if msg, err = generateAICommitMessage(p.CommitDiff(k)); err != nil {
+				return created, restoreSplitIndex(g, p, err)
+			}
+			p.Messages[k] = msg
+		}
+		msg = linkTicket(msg, ticket)
+		if err := checkCommitMessage(msg); err != nil {
+			return created, restoreSplitIndex(g, p, err)
+		}
+		if err := p.stageUpTo(g, k); err != nil {
+			return created, restoreSplitIndex(g, p, fmt.Errorf("failed to stage commit %d: %w", k+1, err))
+		}
+		if err := withCommitDate(func() error { return g.Commit(msg, false, false) }); err != nil {
+			return created, restoreSplitIndex(g, p, fmt.Errorf("failed to commit: %w", err))
+		}
+		if err := RecordOperation(g, "commit", msg, "git commit", "commit", p.commitPaths(k), branch, msg, false, ""); err != nil {
+			ui.Warning("Failed to record operation in undo history")
+		}
+		fmt.Printf("%s Created commit %d of %d: %s\n\n", ui.Green("✓"), k+1, len(p.Messages), firstLine(msg))
+		created = append(created, msg)
+		k++
+	}
+
+	// The last commit holds everything that was staged
+	if tree, err := g.Run("write-tree"); err == nil && strings.TrimSpace(tree) != p.Tree {
+		ui.Warning("The commits don't add up to what was staged; the difference is left staged")
+		return created, restoreSplitIndex(g, p, nil)
+	}
+	return created, nil
+}
+
+// restoreSplitIndex stages everything that was staged again, so what the
+// commits so far didn't take is left staged, and passes err on
+func restoreSplitIndex(g git.Service, p *SplitPlan, err error) error {
+	if _, rerr := g.Run("read-tree", p.Tree); rerr != nil && err == nil {
+		err = fmt.Errorf("failed to restore the staged changes: %w", rerr)
+	}
+	return err
+}
+
+func (p *SplitPlan) commitPaths(k int) []string {
+	var out []string
+	for f, fh := range p.Files {
+		if upTo, _ := p.fileShare(f, k); upTo > 0 {
+			if prev, _ := p.fileShare(f, k-1); prev < upTo {
+				out = append(out, fh.Path)
+			}
+		}
+	}
+	return out
+}
+
+func printSplitCommit(p *SplitPlan, k int) {
+	msg := p.Messages[k]
+	if msg == "" {
+		msg = ui.Gray("(message written when it's committed)")
+	}
+	fmt.Printf("%s %s\n", ui.Bold(fmt.Sprintf("Commit %d of %d:", k+1, len(p.Messages))), firstLine(msg))
+	for _, f := range p.CommitFiles(k) {
+		fmt.Printf("  %s\n", f)
+	}
+	fmt.Println()
+}
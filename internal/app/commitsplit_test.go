@@ -0,0 +1,148 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// stagedSplitPlan plans the staged changes with a canned AI answer
+func stagedSplitPlan(t *testing.T, r *testRepo, answer string) *SplitPlan {
+	t.Helper()
+	files := ParseHunks(r.git("diff", "--cached", "-U0", "--no-renames") + "\n")
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[f.Path] = true
+	}
+	for _, name := range strings.Fields(r.git("diff", "--cached", "--name-only", "--no-renames")) {
+		if !seen[name] {
+			files = append(files, FileHunks{Path: name})
+		}
+	}
+	p := newSplitPlan(r.rev("HEAD"), r.git("write-tree"), files)
+	if err := p.applySplitAnswer(answer); err != nil {
+		t.Fatalf("applySplitAnswer: %v", err)
+	}
+	return p
+}
+
+func TestApplySplitAnswer(t *testing.T) {
+	files := []FileHunks{
+		{Path: "a.go", Hunks: []Hunk{{Lines: []HunkLine{{Op: '+'}}}, {Lines: []HunkLine{{Op: '-'}}}}},
+		{Path: "logo.png"},
+		{Path: "b.go", Hunks: []Hunk{{Lines: []HunkLine{{Op: '+'}}}}},
+	}
+	p := newSplitPlan("base", "tree", files)
+	// Hunk 9 doesn't exist, 1 is given twice, 4 is forgotten and the
+	// second commit is left with nothing
+	answer := "Here you go:\n```json\n" +
+		`{"commits": [{"message": "feat: add a", "hunks": [1, 3, 9]}, {"message": "chore: nothing", "hunks": [1]}, {"message": "fix: b", "hunks": [2]}]}` +
+		"\n```"
+	if err := p.applySplitAnswer(answer); err != nil {
+		t.Fatalf("applySplitAnswer: %v", err)
+	}
+	if strings.Join(p.Messages, "|") != "feat: add a|fix: b" {
+		t.Fatalf("messages = %q", p.Messages)
+	}
+	if p.lines[0][0][0] != 0 || p.lines[0][1][0] != 1 || p.whole[1] != 0 || p.lines[2][0][0] != 1 {
+		t.Errorf("assigned lines %v, whole files %v", p.lines, p.whole)
+	}
+
+	if err := p.applySplitAnswer("I can't split this"); err == nil {
+		t.Error("an answer without a plan was accepted")
+	}
+}
+
+func TestSplitPlanReassign(t *testing.T) {
+	files := []FileHunks{{Path: "a.go", Hunks: []Hunk{
+		{OldStart: 1, OldCount: 1, NewStart: 1, NewCount: 2, Lines: []HunkLine{{Op: '-', Text: "x"}, {Op: '+', Text: "y"}, {Op: '+', Text: "z"}}},
+	}}}
+	p := newSplitPlan("base", "tree", files)
+	p.Messages = []string{"first", "second"}
+	p.lines[0][0] = []int{0, 0, 1}
+
+	// Commit 0 gives up "y" and takes "z"
+	err := p.Reassign(0, func(shown []FileHunks) ([]FileHunks, error) {
+		lines := shown[0].Hunks[0].Lines
+		if len(lines) != 3 || !lines[0].Selected || !lines[1].Selected || lines[2].Selected {
+			t.Fatalf("picker shown %+v", lines)
+		}
+		lines[1].Selected, lines[2].Selected = false, true
+		return shown, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.lines[0][0]; got[0] != 0 || got[1] != 1 || got[2] != 0 {
+		t.Errorf("lines go to commits %v, want [0 1 0]", got)
+	}
+
+	// Taking everything leaves the second commit empty, so it's dropped
+	_ = p.Reassign(0, func(shown []FileHunks) ([]FileHunks, error) {
+		for i := range shown[0].Hunks {
+			shown[0].Hunks[i].Select(true)
+		}
+		return shown, nil
+	})
+	if len(p.Messages) != 1 || p.Messages[0] != "first" {
+		t.Errorf("messages = %q, want only the first commit", p.Messages)
+	}
+}
+
+func TestSplitPlanCommitsInSeries(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.commit("list.txt", numberedLines(1, 20), "Add list")
+	r.commit("gone.txt", "bye\n", "Add gone")
+
+	content := strings.Replace(numberedLines(1, 20), "line 2\n", "line two\n", 1)
+	content = strings.Replace(content, "line 15\n", "line 15\nextra\n", 1)
+	r.write("list.txt", content)
+	r.write("new.txt", "new\n")
+	if err := os.Remove("gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	r.write("unstaged.txt", "left alone\n")
+	r.git("add", "list.txt", "new.txt", "gone.txt")
+
+	// Units in diff order: gone.txt, list.txt's two hunks, new.txt
+	p := stagedSplitPlan(t, r, `{"commits": [
+		{"message": "Rename line two", "hunks": [2]},
+		{"message": "Add new and extra", "hunks": [4, 3]},
+		{"message": "Drop gone", "hunks": [1]}]}`)
+	if len(p.Files) != 3 {
+		t.Fatalf("plan has files %+v", p.Files)
+	}
+	for k, msg := range p.Messages {
+		if err := p.stageUpTo(g, k); err != nil {
+			t.Fatalf("stageUpTo(%d): %v", k, err)
+		}
+		if err := g.Commit(msg, false, false); err != nil {
+			t.Fatalf("commit %d: %v", k, err)
+		}
+	}
+
+	if got := r.git("log", "--format=%s", "-3"); got != "Drop gone\nAdd new and extra\nRename line two" {
+		t.Fatalf("log:\n%s", got)
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD~2"); got != "list.txt" {
+		t.Errorf("first commit changed %s", got)
+	}
+	if !strings.Contains(r.git("show", "HEAD~2"), "+line two") || strings.Contains(r.git("show", "HEAD~2"), "extra") {
+		t.Errorf("first commit:\n%s", r.git("show", "HEAD~2"))
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD~1"); got != "list.txt\nnew.txt" {
+		t.Errorf("second commit changed %s", got)
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD"); got != "gone.txt" {
+		t.Errorf("last commit changed %s", got)
+	}
+	if r.git("write-tree") != p.Tree || r.git("rev-parse", "HEAD^{tree}") != p.Tree {
+		t.Error("the commits don't add up to what was staged")
+	}
+	if r.git("status", "--porcelain") != "?? unstaged.txt" {
+		t.Errorf("status:\n%s", r.git("status", "--porcelain"))
+	}
+}
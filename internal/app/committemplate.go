@@ -0,0 +1,150 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// commitTemplateFile shapes commit messages for the repository, e.g.
+//
+//	{{ticket}} {{scope}}: {{message}}
+//
+// {{ticket}} is the ticket ID in the branch name, {{scope}} the directory
+// the changes share, {{branch}} the branch and {{message}} the message from
+// -m, the prompt or AI. Without {{message}}, the template only seeds the
+// editor.
+const commitTemplateFile = ".sage/commit-template"
+
+// issueBranch finds GitHub issue numbers in branch names like fix/123-login
+var issueBranch = regexp.MustCompile(`(?:^|/)([0-9]+)(?:-|$)`)
+
+// These tidy up after placeholders that rendered to nothing
+var (
+	emptyPlaceholder = regexp.MustCompile(`\[\s*\]|\(\s*\)|\{\s*\}`)
+	spaceBeforePunct = regexp.MustCompile(`\s+([:,])`)
+)
+
+// commitTemplate is the repository's commit template, with everything but
+// {{message}} filled in
+type commitTemplate struct {
+	text   string
+	ticket string
+}
+
+// loadCommitTemplate reads .sage/commit-template, if there is one, and fills
+// it in for branch and the files being committed
+func loadCommitTemplate(g git.Service, branch string, files []string) (*commitTemplate, error) {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Join(root, commitTemplateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", commitTemplateFile, err)
+	}
+
+	ticket, err := ticketFromBranch(branch)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.NewReplacer(
+		"{{ticket}}", ticket,
+		"{{scope}}", commonScope(files),
+		"{{branch}}", branch,
+	).Replace(parseEditedMessage(string(b)))
+	return &commitTemplate{text: text, ticket: ticket}, nil
+}
+
+// ticketFromBranch returns the ticket ID in branch, matched by
+// commit.ticket_pattern, or a #number for issue branches
+func ticketFromBranch(branch string) (string, error) {
+	if id, err := issues.IDFromBranch(branch); err != nil || id != "" {
+		return id, err
+	}
+	if m := issueBranch.FindStringSubmatch(branch); m != nil {
+		return "#" + m[1], nil
+	}
+	return "", nil
+}
+
+// commonScope names the deepest directory every file is in, e.g. "app" for
+// internal/app/a.go and internal/app/b.go, or "" for files at the root
+func commonScope(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	common := path.Dir(filepath.ToSlash(files[0]))
+	for _, f := range files[1:] {
+		dir := path.Dir(filepath.ToSlash(f))
+		for common != "." && dir != common && !strings.HasPrefix(dir, common+"/") {
+			common = path.Dir(common)
+		}
+	}
+	if common == "." {
+		return ""
+	}
+	return path.Base(common)
+}
+
+// apply puts msg into the template, or fills in the editor's starting text
+// when msg is empty. Messages already mentioning the ticket, or with a
+// template lacking {{message}}, are left alone.
+func (t *commitTemplate) apply(msg string) string {
+	if msg != "" && (!strings.Contains(t.text, "{{message}}") || (t.ticket != "" && strings.Contains(msg, t.ticket))) {
+		return msg
+	}
+	subject, body, _ := strings.Cut(msg, "\n")
+	out := strings.ReplaceAll(t.text, "{{message}}", subject)
+
+	// Tidy up what empty placeholders leave behind in the subject
+	first, rest, _ := strings.Cut(out, "\n")
+	first = emptyPlaceholder.ReplaceAllString(first, "")
+	first = spaceBeforePunct.ReplaceAllString(strings.Join(strings.Fields(first), " "), "$1")
+	first = strings.TrimLeft(first, ":-| ")
+	out = strings.TrimSpace(first + "\n" + rest)
+	if body = strings.TrimSpace(body); body != "" {
+		out += "\n\n" + body
+	}
+	return out
+}
+
+// checkCommitMessage applies commit.message_pattern, which blocks messages
+// that don't match, and commit.message_warn_pattern, which only warns
+func checkCommitMessage(msg string) error {
+	if pattern := config.Get("commit.message_pattern", true); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid commit.message_pattern: %w", err)
+		}
+		if !re.MatchString(msg) {
+			return fmt.Errorf("commit message %q doesn't match commit.message_pattern %s", firstLine(msg), pattern)
+		}
+	}
+	if pattern := config.Get("commit.message_warn_pattern", true); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid commit.message_warn_pattern: %w", err)
+		}
+		if !re.MatchString(msg) {
+			ui.Warning(fmt.Sprintf("Commit message %q doesn't match commit.message_warn_pattern %s", firstLine(msg), pattern))
+		}
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"os"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestLoadCommitTemplate(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+
+	if tmpl, err := loadCommitTemplate(g, "main", nil); err != nil || tmpl != nil {
+		t.Fatalf("without a template got %+v, %v; want none", tmpl, err)
+	}
+
+	if err := os.Mkdir(".sage", 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.write(commitTemplateFile, "# Lines starting with # are dropped\n[{{ticket}}] {{scope}}: {{message}}\n\nBranch: {{branch}}\n")
+
+	tests := []struct {
+		branch string
+		files  []string
+		want   string
+	}{
+		{"feature/ABC-12-login", []string{"internal/app/a.go", "internal/app/b.go"}, "[ABC-12] app: {{message}}\n\nBranch: feature/ABC-12-login"},
+		{"fix/123-crash", []string{"README.md"}, "[#123] : {{message}}\n\nBranch: fix/123-crash"},
+		{"tidy-up", []string{"cmd/a.go", "internal/b.go"}, "[] : {{message}}\n\nBranch: tidy-up"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			tmpl, err := loadCommitTemplate(g, tt.branch, tt.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tmpl.text != tt.want {
+				t.Errorf("text = %q, want %q", tmpl.text, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitTemplateApply(t *testing.T) {
+	tests := []struct {
+		name, text, ticket, msg, want string
+	}{
+		{"fills in the message", "ABC-1 app: {{message}}", "ABC-1", "Fix login", "ABC-1 app: Fix login"},
+		{"keeps the body", "ABC-1: {{message}}", "ABC-1", "Fix login\n\nIt was broken.", "ABC-1: Fix login\n\nIt was broken."},
+		{"tidies empty placeholders", "[] : {{message}}", "", "Fix login", "Fix login"},
+		{"tidies an empty scope", "feat(): {{message}}", "", "Add search", "feat: Add search"},
+		{"message already has the ticket", "ABC-1: {{message}}", "ABC-1", "ABC-1 Fix login", "ABC-1 Fix login"},
+		{"template without a message", "ABC-1\n\nSigned-off-by: me", "ABC-1", "Fix login", "Fix login"},
+		{"seeds the editor", "ABC-1 app: {{message}}\n\nWhy:", "ABC-1", "", "ABC-1 app:\n\nWhy:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &commitTemplate{text: tt.text, ticket: tt.ticket}
+			if got := tmpl.apply(tt.msg); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommonScope(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"README.md"}, ""},
+		{[]string{"internal/app/a.go"}, "app"},
+		{[]string{"internal/app/a.go", "internal/app/sub/b.go"}, "app"},
+		{[]string{"internal/app/a.go", "internal/git/b.go"}, "internal"},
+		{[]string{"internal/app/a.go", "internal/application/b.go"}, "internal"},
+		{[]string{"cmd/a.go", "internal/b.go"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonScope(tt.files); got != tt.want {
+			t.Errorf("commonScope(%q) = %q, want %q", tt.files, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+)
+
+// ComparedFile is a file head changes relative to the merge base
+type ComparedFile struct {
+	Path    string `json:"path" yaml:"path"`
+	Added   int    `json:"added" yaml:"added"`
+	Deleted int    `json:"deleted" yaml:"deleted"`
+	Binary  bool   `json:"binary,omitempty" yaml:"binary,omitempty"`
+}
+
+// Comparison is how two branches stand against each other: what each has
+// that the other doesn't, what merging head into base would change, and
+// where that merge would conflict
+type Comparison struct {
+	Base      string         `json:"base" yaml:"base"`
+	Head      string         `json:"head" yaml:"head"`
+	MergeBase *CommitInfo    `json:"merge_base" yaml:"merge_base"` // nil for unrelated histories
+	BaseOnly  []CommitInfo   `json:"base_only" yaml:"base_only"`   // newest first
+	HeadOnly  []CommitInfo   `json:"head_only" yaml:"head_only"`   // newest first
+	Files     []ComparedFile `json:"files" yaml:"files"`
+	Added     int            `json:"added" yaml:"added"`
+	Deleted   int            `json:"deleted" yaml:"deleted"`
+	// Conflicts are the files merging head into base would conflict on.
+	// ConflictsChecked is false when git is too old to tell.
+	Conflicts        []string `json:"conflicts" yaml:"conflicts"`
+	ConflictsChecked bool     `json:"conflicts_checked" yaml:"conflicts_checked"`
+}
+
+// CompareRef returns the ref to compare for a branch name: the local
+// branch, or origin's when there is no local one
+func CompareRef(g git.Service, branch string) (string, error) {
+	for _, ref := range []string{branch, "origin/" + branch} {
+		if _, err := g.Run("rev-parse", "--verify", "--quiet", ref); err == nil {
+			return ref, nil
+		}
+	}
+	return "", exitcode.Errorf(exitcode.NotFound, "no branch or commit named %s", branch)
+}
+
+// CompareBranches compares head against base, both refs git can resolve
+func CompareBranches(g git.Service, base, head string) (*Comparison, error) {
+	c := &Comparison{
+		Base:      base,
+		Head:      head,
+		BaseOnly:  []CommitInfo{},
+		HeadOnly:  []CommitInfo{},
+		Files:     []ComparedFile{},
+		Conflicts: []string{},
+	}
+
+	baseOnly, err := g.Commits(git.CommitsOptions{Range: head + ".." + base})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits only on %s: %w", base, err)
+	}
+	headOnly, err := g.Commits(git.CommitsOptions{Range: base + ".." + head})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits only on %s: %w", head, err)
+	}
+	c.BaseOnly = append(c.BaseOnly, toCommitInfos(baseOnly)...)
+	c.HeadOnly = append(c.HeadOnly, toCommitInfos(headOnly)...)
+
+	mb, err := g.GetMergeBase(base, head)
+	if err != nil {
+		// Unrelated histories have nothing in common to diff against
+		return c, nil
+	}
+	if commits, err := g.Commits(git.CommitsOptions{Range: strings.TrimSpace(mb), Limit: 1}); err == nil && len(commits) == 1 {
+		c.MergeBase = &toCommitInfos(commits)[0]
+	}
+
+	out, err := g.Run("diff", "--numstat", "--no-renames", base+"..."+head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s against %s: %w", head, base, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.SplitN(line, "\t", 3)
+		if len(f) < 3 {
+			continue
+		}
+		file := ComparedFile{Path: f[2]}
+		if f[0] == "-" {
+			file.Binary = true
+		} else {
+			file.Added, _ = strconv.Atoi(f[0])
+			file.Deleted, _ = strconv.Atoi(f[1])
+		}
+		c.Added += file.Added
+		c.Deleted += file.Deleted
+		c.Files = append(c.Files, file)
+	}
+
+	if conflicts, err := g.MergeConflicts(base, head); err == nil {
+		c.Conflicts = append(c.Conflicts, conflicts...)
+		c.ConflictsChecked = true
+	}
+	return c, nil
+}
+
+// CompareFileDiffs returns what head changes since the merge base, one
+// diff per file keyed by its path
+func CompareFileDiffs(g git.Service, base, head string) (map[string]string, error) {
+	out, err := g.Run("diff", "--no-color", "--no-renames", base+"..."+head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s against %s: %w", head, base, err)
+	}
+	diffs := map[string]string{}
+	path := ""
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			if path != "" {
+				diffs[path] = cur.String()
+			}
+			cur.Reset()
+			path = ""
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				path = strings.TrimSpace(line[i+3:])
+			}
+		}
+		cur.WriteString(line)
+	}
+	if path != "" {
+		diffs[path] = cur.String()
+	}
+	return diffs, nil
+}
+
+// CompareURL returns the host's page comparing two branches, which GitHub
+// also offers to open a pull request from
+func CompareURL(r *repoinfo.Resolver, base, head string) (string, error) {
+	info, err := r.Repo()
+	if err != nil {
+		return "", err
+	}
+	host := info.Host
+	if host == "" {
+		host = repoinfo.DefaultHost
+	}
+	base = strings.TrimPrefix(base, "origin/")
+	head = strings.TrimPrefix(head, "origin/")
+	return fmt.Sprintf("https://%s/%s/compare/%s...%s", host, info.FullName(), base, head), nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestCompareBranches(t *testing.T) {
+	r := newTestRepo(t)
+	r.feature("README.md", "hello from feature\n", "README.md", "hello from main\n")
+	r.commit("feature.txt", "one\ntwo\n", "More feature work")
+	mergeBase := r.rev("main~1")
+
+	c, err := CompareBranches(git.NewShellGit(), "main", "feature")
+	if err != nil {
+		t.Fatalf("CompareBranches: %v", err)
+	}
+	if c.MergeBase == nil || c.MergeBase.Hash != mergeBase {
+		t.Errorf("merge base = %+v, want %s", c.MergeBase, mergeBase)
+	}
+	if len(c.HeadOnly) != 2 || c.HeadOnly[0].Message != "More feature work" {
+		t.Errorf("head only = %+v, want the two feature commits newest first", c.HeadOnly)
+	}
+	if len(c.BaseOnly) != 1 || c.BaseOnly[0].Message != "Main moves on" {
+		t.Errorf("base only = %+v, want the main commit", c.BaseOnly)
+	}
+	want := []ComparedFile{{Path: "README.md", Added: 1, Deleted: 1}, {Path: "feature.txt", Added: 2}}
+	if len(c.Files) != len(want) || c.Files[0] != want[0] || c.Files[1] != want[1] {
+		t.Errorf("files = %+v, want %+v", c.Files, want)
+	}
+	if c.Added != 3 || c.Deleted != 1 {
+		t.Errorf("diffstat = +%d -%d, want +3 -1", c.Added, c.Deleted)
+	}
+	if c.ConflictsChecked && (len(c.Conflicts) != 1 || c.Conflicts[0] != "README.md") {
+		t.Errorf("conflicts = %v, want README.md", c.Conflicts)
+	}
+
+	diffs, err := CompareFileDiffs(git.NewShellGit(), "main", "feature")
+	if err != nil {
+		t.Fatalf("CompareFileDiffs: %v", err)
+	}
+	if len(diffs) != 2 || !strings.Contains(diffs["feature.txt"], "+two") || strings.Contains(diffs["feature.txt"], "README.md") {
+		t.Errorf("diffs = %v, want one per file", diffs)
+	}
+}
+
+func TestCompareRefFallsBackToOrigin(t *testing.T) {
+	r := newTestRepo(t)
+	other := r.clone("other")
+	r.gitIn(other, "checkout", "-b", "elsewhere")
+	r.gitIn(other, "push", "origin", "elsewhere")
+	r.git("fetch", "origin")
+
+	g := git.NewShellGit()
+	if ref, err := CompareRef(g, "main"); err != nil || ref != "main" {
+		t.Errorf("CompareRef(main) = %q, %v", ref, err)
+	}
+	if ref, err := CompareRef(g, "elsewhere"); err != nil || ref != "origin/elsewhere" {
+		t.Errorf("CompareRef(elsewhere) = %q, %v", ref, err)
+	}
+	if _, err := CompareRef(g, "missing"); err == nil {
+		t.Error("CompareRef(missing) succeeded")
+	}
+}
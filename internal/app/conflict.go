@@ -7,7 +7,6 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 )
@@ -48,12 +47,10 @@ func ResolveConflicts(g git.Service, opts ConflictOptions) error {
 	fmt.Println()
 
 	// Ask which files to edit
-	var selectedFiles []string
-	prompt := &survey.MultiSelect{
-		Message: "Select files to edit:",
-		Options: conflicts,
+	selectedFiles, err := ui.AskMultiSelect("Select files to edit:", conflicts, "resolve them with 'sage resolve <file>' instead")
+	if err != nil {
+		return err
 	}
-	survey.AskOne(prompt, &selectedFiles)
 
 	if len(selectedFiles) == 0 {
 		ui.Warning("No files selected. You'll need to resolve conflicts manually.")
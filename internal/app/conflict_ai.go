@@ -0,0 +1,221 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// maxHunkPromptSize caps how much of a single conflict hunk is sent to the AI
+const maxHunkPromptSize = 8000
+
+// ErrHunkTooLarge means a side of a hunk doesn't fit in the prompt. A
+// proposal made from part of a hunk would still replace all of it, so
+// none is asked for.
+var ErrHunkTooLarge = errors.New("hunk is too large for an AI proposal, resolve it by hand")
+
+// ConflictHunk is a single conflicted region of a file
+type ConflictHunk struct {
+	Start       int // Line index of the <<<<<<< marker
+	End         int // Line index of the >>>>>>> marker
+	OursLabel   string
+	TheirsLabel string
+	Ours        []string
+	Base        []string // Only present for diff3-style conflicts
+	Theirs      []string
+}
+
+// ParseConflictHunks finds all conflict regions in a file's content
+func ParseConflictHunks(content string) []ConflictHunk {
+	lines := strings.Split(content, "\n")
+	var hunks []ConflictHunk
+	var cur *ConflictHunk
+	section := ""
+
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<<"):
+			cur = &ConflictHunk{Start: i, OursLabel: strings.TrimSpace(strings.TrimPrefix(line, "<<<<<<<"))}
+			section = "ours"
+		case cur != nil && strings.HasPrefix(line, "|||||||"):
+			section = "base"
+		case cur != nil && line == "=======":
+			section = "theirs"
+		case cur != nil && strings.HasPrefix(line, ">>>>>>>"):
+			cur.End = i
+			cur.TheirsLabel = strings.TrimSpace(strings.TrimPrefix(line, ">>>>>>>"))
+			hunks = append(hunks, *cur)
+			cur = nil
+			section = ""
+		case cur != nil:
+			switch section {
+			case "ours":
+				cur.Ours = append(cur.Ours, line)
+			case "base":
+				cur.Base = append(cur.Base, line)
+			case "theirs":
+				cur.Theirs = append(cur.Theirs, line)
+			}
+		}
+	}
+	return hunks
+}
+
+// LoadConflictBases fills in the base of hunks that came without one, as
+// they do unless merge.conflictStyle is diff3, from the path's index stages.
+// A hunk is matched to the redone merge's hunk holding both of its sides;
+// hunks that can't be matched, and paths with no base, are left as they are.
+func LoadConflictBases(g git.Service, path string, hunks []ConflictHunk) {
+	sg, ok := g.(*git.ShellGit)
+	if !ok || !slices.ContainsFunc(hunks, func(h ConflictHunk) bool { return h.Base == nil }) {
+		return
+	}
+	merged, err := sg.ConflictDiff3(path)
+	if err != nil {
+		return
+	}
+	full := ParseConflictHunks(merged)
+	for i := range hunks {
+		if hunks[i].Base != nil {
+			continue
+		}
+		for _, d := range full {
+			if containsLines(d.Ours, hunks[i].Ours) && containsLines(d.Theirs, hunks[i].Theirs) {
+				hunks[i].Base = d.Base
+				break
+			}
+		}
+	}
+}
+
+// containsLines reports whether sub appears as a run of lines in lines
+func containsLines(lines, sub []string) bool {
+	for i := 0; i+len(sub) <= len(lines); i++ {
+		if slices.Equal(lines[i:i+len(sub)], sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyHunkResolutions replaces conflict hunks with their chosen resolution.
+// Hunks without a resolution (keyed by index) keep their conflict markers.
+func ApplyHunkResolutions(content string, hunks []ConflictHunk, resolutions map[int][]string) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	next := 0
+
+	for i, h := range hunks {
+		out = append(out, lines[next:h.Start]...)
+		if res, ok := resolutions[i]; ok {
+			out = append(out, res...)
+		} else {
+			out = append(out, lines[h.Start:h.End+1]...)
+		}
+		next = h.End + 1
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, "\n")
+}
+
+// SuggestHunkResolution asks the AI to merge both sides of a conflict hunk.
+// The result is only a proposal and must be confirmed by the user before use.
+// Hunks with a side too large to send whole get ErrHunkTooLarge.
+func SuggestHunkResolution(client *ai.Client, path string, h ConflictHunk) ([]string, error) {
+	ours := strings.Join(h.Ours, "\n")
+	base := strings.Join(h.Base, "\n")
+	theirs := strings.Join(h.Theirs, "\n")
+	if max(len(ours), len(base), len(theirs)) > maxHunkPromptSize/3 {
+		return nil, ErrHunkTooLarge
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "File: %s\n\n", path)
+	fmt.Fprintf(&b, "OURS (%s):\n```\n%s\n```\n\n", h.OursLabel, ours)
+	if len(h.Base) > 0 {
+		fmt.Fprintf(&b, "BASE (common ancestor):\n```\n%s\n```\n\n", base)
+	}
+	fmt.Fprintf(&b, "THEIRS (%s):\n```\n%s\n```\n\n", h.TheirsLabel, theirs)
+	b.WriteString("Produce the merged code that keeps the intent of both sides. " +
+		"Respond with ONLY the resolved lines inside a single ``` code block, without conflict markers or explanation.")
+
+	resp, err := client.ForTask(ai.TaskReview).Complete(
+		"You are an expert software engineer resolving git merge conflicts. You preserve the intent of both changes and never invent unrelated code.",
+		b.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := extractCodeBlock(resp)
+	if strings.Contains(resolved, "<<<<<<<") || strings.Contains(resolved, ">>>>>>>") {
+		return nil, fmt.Errorf("AI response still contains conflict markers")
+	}
+	return strings.Split(resolved, "\n"), nil
+}
+
+// FormatHunkDiff renders what replacing the ours side with a resolution
+// would change, as unified diff lines without a header
+func FormatHunkDiff(ours, resolution []string) string {
+	// lcs[i][j] is the longest common run of ours[i:] and resolution[j:]
+	lcs := make([][]int, len(ours)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(resolution)+1)
+	}
+	for i := len(ours) - 1; i >= 0; i-- {
+		for j := len(resolution) - 1; j >= 0; j-- {
+			if ours[i] == resolution[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(ours) || j < len(resolution) {
+		switch {
+		case i < len(ours) && j < len(resolution) && ours[i] == resolution[j]:
+			b.WriteString(" " + ours[i] + "\n")
+			i++
+			j++
+		case j < len(resolution) && (i == len(ours) || lcs[i][j+1] > lcs[i+1][j]):
+			b.WriteString("+" + resolution[j] + "\n")
+			j++
+		default:
+			b.WriteString("-" + ours[i] + "\n")
+			i++
+		}
+	}
+	return b.String()
+}
+
+// extractCodeBlock returns the contents of the first fenced code block, or the whole text
+func extractCodeBlock(s string) string {
+	start := strings.Index(s, "```")
+	if start == -1 {
+		return strings.TrimSpace(s)
+	}
+	rest := s[start+3:]
+	// Skip the optional language tag
+	if nl := strings.Index(rest, "\n"); nl != -1 {
+		rest = rest[nl+1:]
+	}
+	if end := strings.LastIndex(rest, "```"); end != -1 {
+		rest = rest[:end]
+	}
+	return strings.TrimRight(rest, "\n")
+}
+
+// truncateForPrompt shortens text to at most max bytes, marking the cut
+func truncateForPrompt(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "\n[truncated]"
+}
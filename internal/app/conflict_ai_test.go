@@ -0,0 +1,49 @@
+package app
+
+import (
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestFormatHunkDiff(t *testing.T) {
+	ours := []string{"a", "b", "c"}
+	resolution := []string{"a", "B", "c", "d"}
+	want := " a\n-b\n+B\n c\n+d\n"
+	if got := FormatHunkDiff(ours, resolution); got != want {
+		t.Errorf("FormatHunkDiff = %q, want %q", got, want)
+	}
+}
+
+func TestSuggestHunkResolutionRefusesLargeHunks(t *testing.T) {
+	h := ConflictHunk{Ours: []string{strings.Repeat("x", maxHunkPromptSize)}, Theirs: []string{"y"}}
+	// The size check comes before the client is used
+	if _, err := SuggestHunkResolution(nil, "big.txt", h); !errors.Is(err, ErrHunkTooLarge) {
+		t.Errorf("got %v, want ErrHunkTooLarge", err)
+	}
+}
+
+func TestLoadConflictBases(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("app.txt", "one\ntwo\nthree\n", "Add app")
+	r.git("checkout", "-b", "feature")
+	r.commit("app.txt", "one\nTWO feature\nthree\n", "Feature change")
+	r.git("checkout", "main")
+	r.commit("app.txt", "one\nTWO main\nthree\n", "Main change")
+	if err := exec.Command("git", "-c", "merge.conflictStyle=merge", "merge", "feature").Run(); err == nil {
+		t.Fatal("expected the merge to conflict")
+	}
+
+	hunks := ParseConflictHunks(r.read("app.txt"))
+	if len(hunks) != 1 || hunks[0].Base != nil {
+		t.Fatalf("expected one hunk without a base, got %+v", hunks)
+	}
+	LoadConflictBases(git.NewShellGit(), "app.txt", hunks)
+	if !slices.Equal(hunks[0].Base, []string{"two"}) {
+		t.Errorf("base = %q, want [two]", hunks[0].Base)
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// dashCommits is how many recent commits 'sage dash' lists
+const dashCommits = 8
+
+// DashLocal is what 'sage dash' reads from git. It costs no API calls, so
+// it is refreshed every few seconds.
+type DashLocal struct {
+	Status  *StatusOverview
+	Commits []git.Commit // newest first
+	Stashes []string     // as 'git stash list' prints them
+}
+
+// GetDashLocal reads the branch status, recent commits and stashes
+func GetDashLocal(g git.Service) (*DashLocal, error) {
+	st, err := GetStatusOverview(g)
+	if err != nil {
+		return nil, err
+	}
+	d := &DashLocal{Status: st}
+	if d.Commits, err = g.Commits(git.CommitsOptions{Limit: dashCommits}); err != nil {
+		return nil, fmt.Errorf("failed to read recent commits: %w", err)
+	}
+	if d.Stashes, err = g.StashList(); err != nil {
+		return nil, fmt.Errorf("failed to list stashes: %w", err)
+	}
+	return d, nil
+}
+
+// DashPR is the branch's open pull request with its checks and reviews
+type DashPR struct {
+	PR      *gh.PullRequest
+	Checks  []gh.CheckRun
+	Reviews []gh.Review // each reviewer's latest decision
+}
+
+// CheckCounts tallies the checks that passed, failed and haven't finished
+func (d *DashPR) CheckCounts() (passed, failed, pending int) {
+	for _, c := range d.Checks {
+		switch {
+		case c.Status != "completed":
+			pending++
+		case c.Failed():
+			failed++
+		default:
+			passed++
+		}
+	}
+	return passed, failed, pending
+}
+
+// GetDashPR fetches the open PR for branch with its checks and reviews, or
+// nil when the branch has none
+func GetDashPR(ghc gh.Client, branch string) (*DashPR, error) {
+	pr, err := ghc.GetPRForBranch(branch)
+	if err != nil || pr == nil {
+		return nil, err
+	}
+	d := &DashPR{PR: pr}
+	if d.Checks, err = ghc.ListPRCheckRuns(pr.Number); err != nil {
+		return nil, fmt.Errorf("failed to list checks: %w", err)
+	}
+	reviews, err := ghc.ListPRReviews(pr.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	d.Reviews = LatestReviews(reviews)
+	return d, nil
+}
+
+// dashPRCalls is about how many API requests one GetDashPR makes
+const dashPRCalls = 4
+
+// DashPollInterval is how long 'sage dash' waits before fetching the PR
+// again. It polls every base while the rate limit allows, slows down so the
+// dashboard spends at most a quarter of the requests left before the limit
+// resets, and once they have run out waits for the reset.
+func DashPollInterval(base time.Duration, rl gh.RateLimit, known bool, now time.Time) time.Duration {
+	untilReset := rl.Reset.Sub(now)
+	if !known || untilReset <= 0 {
+		return base
+	}
+	refreshes := rl.Remaining / 4 / dashPRCalls
+	if refreshes == 0 {
+		return untilReset + time.Second
+	}
+	if every := untilReset / time.Duration(refreshes); every > base {
+		return every
+	}
+	return base
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestGetDashLocal(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "a\n", "Add a")
+	r.commit("b.txt", "b\n", "Add b")
+	r.write("a.txt", "changed\n")
+	r.git("stash", "push", "-m", "half-done")
+	r.write("c.txt", "c\n")
+
+	d, err := GetDashLocal(git.NewShellGit())
+	if err != nil {
+		t.Fatalf("GetDashLocal: %v", err)
+	}
+	if d.Status.Branch != "main" || len(d.Status.Untracked) != 1 {
+		t.Errorf("status = %s with %d untracked, want main with c.txt", d.Status.Branch, len(d.Status.Untracked))
+	}
+	if len(d.Commits) < 2 || d.Commits[0].Subject != "Add b" {
+		t.Errorf("recent commits = %+v, want Add b first", d.Commits)
+	}
+	if len(d.Stashes) != 1 || !strings.Contains(d.Stashes[0], "half-done") {
+		t.Errorf("stashes = %q, want the half-done stash", d.Stashes)
+	}
+}
+
+func TestDashPollInterval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	hour := gh.RateLimit{Limit: 5000, Reset: now.Add(time.Hour)}
+	quota := func(remaining int) gh.RateLimit {
+		rl := hour
+		rl.Remaining = remaining
+		return rl
+	}
+	tests := []struct {
+		name  string
+		rl    gh.RateLimit
+		known bool
+		want  time.Duration
+	}{
+		{"no quota seen yet", gh.RateLimit{}, false, 30 * time.Second},
+		{"plenty left", quota(4800), true, 30 * time.Second},
+		{"running low", quota(160), true, 6 * time.Minute},
+		{"run out", quota(3), true, time.Hour + time.Second},
+		{"reset already passed", gh.RateLimit{Remaining: 0, Reset: now.Add(-time.Minute)}, true, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DashPollInterval(30*time.Second, tt.rl, tt.known, now); got != tt.want {
+				t.Errorf("DashPollInterval = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestReviews(t *testing.T) {
+	review := func(login, state string) gh.Review {
+		var r gh.Review
+		r.User.Login, r.State = login, state
+		return r
+	}
+	got := LatestReviews([]gh.Review{
+		review("alice", "CHANGES_REQUESTED"),
+		review("bob", "COMMENTED"),
+		review("carol", "APPROVED"),
+		review("alice", "APPROVED"),
+	})
+	var states []string
+	for _, r := range got {
+		states = append(states, r.User.Login+":"+r.State)
+	}
+	if want := "alice:APPROVED carol:APPROVED"; strings.Join(states, " ") != want {
+		t.Errorf("LatestReviews = %v, want %s", states, want)
+	}
+}
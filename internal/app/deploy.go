@@ -0,0 +1,195 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/undo"
+)
+
+// defaultProtectedTargets are deploy targets that always need typed confirmation
+var defaultProtectedTargets = []string{"production", "prod"}
+
+// DeployTarget is a named push target such as "staging" → origin/deploy/staging
+type DeployTarget struct {
+	Name      string
+	Remote    string
+	Branch    string
+	Protected bool
+}
+
+// DeployResult describes what a deploy did to the target branch
+type DeployResult struct {
+	Target   DeployTarget
+	Previous string // remote commit before the deploy (empty if the branch was created)
+	Deployed string // remote commit after the deploy
+	Mode     string // "created", "fast-forward", "merge" or "up-to-date"
+}
+
+// ParseDeployTarget parses a config value of the form "<remote> <branch>" or "<branch>"
+func ParseDeployTarget(name, value string) (DeployTarget, error) {
+	fields := strings.Fields(value)
+	t := DeployTarget{Name: name, Remote: "origin"}
+	switch len(fields) {
+	case 1:
+		t.Branch = fields[0]
+	case 2:
+		t.Remote, t.Branch = fields[0], fields[1]
+	default:
+		return t, fmt.Errorf("invalid deploy target %q for %s (expected \"<remote> <branch>\")", value, name)
+	}
+
+	protected := defaultProtectedTargets
+	if v := config.Get("deploy.protected", true); v != "" {
+		protected = strings.Split(v, ",")
+	}
+	for _, p := range protected {
+		if strings.EqualFold(strings.TrimSpace(p), name) {
+			t.Protected = true
+		}
+	}
+	return t, nil
+}
+
+// ListDeployTargets returns all configured deploy targets sorted by name
+func ListDeployTargets() ([]DeployTarget, error) {
+	var targets []DeployTarget
+	for key, value := range config.GetPrefixed("deploy.", true) {
+		name := strings.TrimPrefix(key, "deploy.")
+		if name == "protected" {
+			continue
+		}
+		t, err := ParseDeployTarget(name, value)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// GetDeployTarget looks up a single configured deploy target by name
+func GetDeployTarget(name string) (DeployTarget, error) {
+	value := config.Get("deploy."+name, true)
+	if value == "" || name == "protected" {
+		return DeployTarget{}, fmt.Errorf("unknown deploy target '%s'; configure it with 'sage config set deploy.%s \"origin deploy/%s\"'", name, name, name)
+	}
+	return ParseDeployTarget(name, value)
+}
+
+// Deploy moves the target branch to include the current branch and pushes it.
+// Fast-forwards are pushed directly; otherwise the current branch is merged into
+// the target on a detached HEAD, pushed, and the original branch is restored.
+func Deploy(g git.Service, t DeployTarget) (*DeployResult, error) {
+	if err := verifyRepoState(g); err != nil {
+		return nil, err
+	}
+
+	curBranch, err := g.CurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if curBranch == t.Branch {
+		return nil, fmt.Errorf("already on the deploy branch '%s'", t.Branch)
+	}
+
+	head, err := g.GetCommitHash("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	result := &DeployResult{Target: t}
+	remoteRef := t.Remote + "/" + t.Branch
+	// Only a branch the remote doesn't have yet is created; any other
+	// failure would leave the push overwriting commits it never saw
+	if _, err := g.Run("fetch", t.Remote, t.Branch); err == nil {
+		if result.Previous, err = g.GetCommitHash(remoteRef); err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", remoteRef, err)
+		}
+	} else if !strings.Contains(err.Error(), "couldn't find remote ref") {
+		return nil, fmt.Errorf("failed to fetch %s: %w", remoteRef, err)
+	}
+
+	switch {
+	case result.Previous == "":
+		result.Mode = "created"
+		result.Deployed = head
+	case result.Previous == head:
+		result.Mode = "up-to-date"
+		result.Deployed = head
+		return result, nil
+	default:
+		ff, err := g.IsAncestor(result.Previous, head)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare with %s: %w", remoteRef, err)
+		}
+		if ff {
+			result.Mode = "fast-forward"
+			result.Deployed = head
+		} else {
+			merged, err := mergeForDeploy(g, curBranch, remoteRef, t.Branch)
+			if err != nil {
+				return nil, err
+			}
+			result.Mode = "merge"
+			result.Deployed = merged
+		}
+	}
+
+	if _, err := g.Run("push", t.Remote, result.Deployed+":refs/heads/"+t.Branch); err != nil {
+		return nil, fmt.Errorf("failed to push to %s: %w", remoteRef, err)
+	}
+
+	if err := recordDeploy(g, result); err != nil {
+		return result, fmt.Errorf("deployed, but failed to record undo history: %w", err)
+	}
+	return result, nil
+}
+
+// mergeForDeploy merges branch into remoteRef on a detached HEAD and returns the merge commit
+func mergeForDeploy(g git.Service, branch, remoteRef, target string) (string, error) {
+	clean, err := g.IsClean()
+	if err != nil {
+		return "", fmt.Errorf("failed to check working directory: %w", err)
+	}
+	if !clean {
+		return "", fmt.Errorf("%s has diverged and needs a merge; commit or stash your changes first", remoteRef)
+	}
+
+	if _, err := g.Run("checkout", "--detach", remoteRef); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %w", remoteRef, err)
+	}
+	defer g.Checkout(branch)
+
+	msg := fmt.Sprintf("Deploy %s to %s", branch, target)
+	if _, err := g.Run("merge", "--no-ff", "-m", msg, branch); err != nil {
+		_ = g.MergeAbort()
+		return "", fmt.Errorf("merging %s into %s conflicts; merge it manually and try again: %w", branch, remoteRef, err)
+	}
+	return g.GetCommitHash("HEAD")
+}
+
+// recordDeploy stores the deploy in the undo history so it can be rolled back
+func recordDeploy(g git.Service, r *DeployResult) error {
+	s := undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		return err
+	}
+	op := undo.Operation{}
+	op.Metadata.Branch = r.Target.Branch
+	op.Metadata.Message = fmt.Sprintf("%s (%s)", r.Target.Name, r.Mode)
+	op.Metadata.Extra = map[string]string{
+		"remote":   r.Target.Remote,
+		"previous": r.Previous,
+		"deployed": r.Deployed,
+	}
+	desc := fmt.Sprintf("Deploy to %s (%s/%s)", r.Target.Name, r.Target.Remote, r.Target.Branch)
+	if err := s.RecordOperation("deploy", desc, "sage deploy "+r.Target.Name, "deploy", op); err != nil {
+		return err
+	}
+	return s.SaveHistory(".")
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/undo"
+)
+
+func TestDeployCreatesMissingTarget(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+
+	res, err := Deploy(g, DeployTarget{Name: "staging", Remote: "origin", Branch: "deploy/staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mode != "created" || res.Deployed != r.rev("HEAD") {
+		t.Errorf("got %+v, want main created on deploy/staging", res)
+	}
+
+	s := undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		t.Fatal(err)
+	}
+	a := s.Analyze(s.GetHistory().Operations[0])
+	if !strings.Contains(a.Reason, "deleted") {
+		t.Errorf("undo reason %q doesn't say the branch is deleted", a.Reason)
+	}
+}
+
+func TestDeployFailsWhenFetchFails(t *testing.T) {
+	newTestRepo(t)
+	_, err := Deploy(git.NewShellGit(), DeployTarget{Name: "staging", Remote: "nowhere", Branch: "deploy/staging"})
+	if err == nil || !strings.Contains(err.Error(), "failed to fetch") {
+		t.Errorf("got %v, want the fetch failure", err)
+	}
+}
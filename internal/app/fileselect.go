@@ -0,0 +1,306 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+const (
+	// maxSelectOptions is how many entries a file selector lists before
+	// directories are collapsed into a single entry
+	maxSelectOptions = 200
+	// manyUntrackedFiles is the untracked file count above which sage offers
+	// to ignore the directories holding most of them
+	manyUntrackedFiles = 500
+	// stageBatchSize caps how many paths are passed to one git add
+	stageBatchSize = 100
+)
+
+// FileOption is one entry in a file selector: a single file, or a directory
+// standing in for every listed file below it
+type FileOption struct {
+	Label string
+	Paths []string
+}
+
+// optionDir returns the directory a selector entry sits in, "" for the root.
+// Collapsed directory entries end in a slash.
+func optionDir(key string) string {
+	dir := path.Dir(strings.TrimSuffix(key, "/"))
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// BuildFileOptions turns files into at most limit selector entries. While there
+// are too many, it collapses the smallest directory that brings the count under
+// the limit, or the largest one when none does. Files left over once nothing
+// can be collapsed are dropped and counted in hidden.
+func BuildFileOptions(files []FileStatus, limit int) (options []FileOption, hidden int) {
+	entries := make(map[string][]FileStatus, len(files))
+	for _, f := range files {
+		key := filepath.ToSlash(f.Path)
+		entries[key] = append(entries[key], f)
+	}
+
+	for len(entries) > limit {
+		under := map[string]int{}
+		for key := range entries {
+			for d := optionDir(key); d != ""; d = optionDir(d) {
+				under[d]++
+			}
+		}
+		dirs := make([]string, 0, len(under))
+		for d := range under {
+			dirs = append(dirs, d)
+		}
+		sort.Strings(dirs)
+
+		need := len(entries) - limit
+		fits, largest := "", ""
+		for _, d := range dirs {
+			n := under[d]
+			if n < 2 {
+				continue
+			}
+			if n-1 >= need && (fits == "" || n < under[fits]) {
+				fits = d
+			}
+			if largest == "" || n > under[largest] {
+				largest = d
+			}
+		}
+		dir := fits
+		if dir == "" {
+			dir = largest
+		}
+		if dir == "" {
+			break
+		}
+
+		var merged []FileStatus
+		for key, fs := range entries {
+			if strings.HasPrefix(key, dir+"/") {
+				merged = append(merged, fs...)
+				delete(entries, key)
+			}
+		}
+		entries[dir+"/"] = merged
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > limit {
+		for _, key := range keys[limit:] {
+			hidden += len(entries[key])
+		}
+		keys = keys[:limit]
+	}
+
+	for _, key := range keys {
+		fs := entries[key]
+		opt := FileOption{}
+		for _, f := range fs {
+			opt.Paths = append(opt.Paths, f.Path)
+		}
+		switch {
+		case strings.HasSuffix(key, "/"):
+			opt.Label = fmt.Sprintf("%s (%d files)", key, len(fs))
+		case fs[0].Status != "":
+			opt.Label = fmt.Sprintf("%s (%s)", key, fs[0].Status)
+		default:
+			opt.Label = key
+		}
+		options = append(options, opt)
+	}
+	return options, hidden
+}
+
+// selectFiles asks which files to stage, listing whole directories when there
+// are too many files to show one by one. Entries whose files all match
+// preselect start checked.
+func selectFiles(message string, files []FileStatus, preselect func(string) bool) ([]string, error) {
+	options, hidden := BuildFileOptions(files, maxSelectOptions)
+	if len(options) < len(files) {
+		fmt.Printf("%s %d changed files; directories are listed as one entry each\n", ui.Yellow("!"), len(files))
+	}
+	if hidden > 0 {
+		fmt.Printf("%s %d more files aren't listed; stage them by pattern instead\n", ui.Yellow("!"), hidden)
+	}
+
+	labels := make([]string, 0, len(options))
+	byLabel := make(map[string]FileOption, len(options))
+	var defaults []string
+	for _, opt := range options {
+		labels = append(labels, opt.Label)
+		byLabel[opt.Label] = opt
+		if preselect == nil {
+			continue
+		}
+		all := true
+		for _, p := range opt.Paths {
+			if !preselect(p) {
+				all = false
+				break
+			}
+		}
+		if all {
+			defaults = append(defaults, opt.Label)
+		}
+	}
+
+	var selected []string
+	prompt := &survey.MultiSelect{
+		Message:  message,
+		Options:  labels,
+		Default:  defaults,
+		PageSize: 15,
+	}
+	if err := survey.AskOne(prompt, &selected); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, label := range selected {
+		paths = append(paths, byLabel[label].Paths...)
+	}
+	return paths, nil
+}
+
+// stagePaths runs git add for paths in batches, so a collapsed directory
+// doesn't need one git process per file
+func stagePaths(g git.Service, paths []string) error {
+	for start := 0; start < len(paths); start += stageBatchSize {
+		end := start + stageBatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		args := append([]string{"add", "--"}, paths[start:end]...)
+		if _, err := g.Run(args...); err != nil {
+			return fmt.Errorf("failed to stage files: %w", err)
+		}
+	}
+	return nil
+}
+
+// IgnoreCandidate is an untracked directory and how many files it holds
+type IgnoreCandidate struct {
+	Dir   string
+	Files int
+}
+
+// LargestUntrackedDirs returns the wholly untracked directories holding the
+// most files, biggest first
+func LargestUntrackedDirs(g git.Service, untracked []string, n int) ([]IgnoreCandidate, error) {
+	// Without -uall git reports a wholly untracked directory as a single entry
+	out, err := g.Run("status", "--porcelain=v1", "--untracked-files=normal")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	var candidates []IgnoreCandidate
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "?? ") || !strings.HasSuffix(line, "/") {
+			continue
+		}
+		dir := strings.Trim(line[3:], `"`)
+		c := IgnoreCandidate{Dir: dir}
+		for _, p := range untracked {
+			if strings.HasPrefix(filepath.ToSlash(p), dir) {
+				c.Files++
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Files > candidates[j].Files
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+// AppendGitignore adds entries to the repository's .gitignore
+func AppendGitignore(g git.Service, entries []string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("append %s to .gitignore", strings.Join(entries, ", "))
+		return nil
+	}
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(root, ".gitignore")
+	existing, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	var b strings.Builder
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		b.WriteString("\n")
+	}
+	for _, e := range entries {
+		b.WriteString("/" + strings.TrimPrefix(e, "/") + "\n")
+	}
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open .gitignore: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
+// offerIgnores suggests ignoring the biggest untracked directories when there
+// are too many untracked files to pick through, and reports whether any were
+// ignored so the caller can re-read the status
+func offerIgnores(g git.Service, untracked []string) (bool, error) {
+	if len(untracked) <= manyUntrackedFiles || batch.Enabled() {
+		return false, nil
+	}
+	candidates, err := LargestUntrackedDirs(g, untracked, 5)
+	if err != nil || len(candidates) == 0 {
+		return false, err
+	}
+
+	fmt.Printf("%s %d untracked files, mostly in:\n", ui.Yellow("!"), len(untracked))
+	labels := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		fmt.Printf("  %s %s\n", c.Dir, ui.Gray(fmt.Sprintf("(%d files)", c.Files)))
+		labels = append(labels, c.Dir)
+	}
+
+	var chosen []string
+	prompt := &survey.MultiSelect{
+		Message: "Add any of these to .gitignore?",
+		Options: labels,
+	}
+	if err := survey.AskOne(prompt, &chosen); err != nil {
+		return false, err
+	}
+	if len(chosen) == 0 {
+		return false, nil
+	}
+	if err := AppendGitignore(g, chosen); err != nil {
+		return false, err
+	}
+	fmt.Printf("%s Added %s to .gitignore\n", ui.Green("✓"), strings.Join(chosen, ", "))
+	return !dryrun.Enabled(), nil
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// ForkSyncOptions contains all options for syncing a fork with its upstream
+type ForkSyncOptions struct {
+	Upstream string   // Name of the upstream remote (defaults to "upstream")
+	Rebase   bool     // Rebase feature branches onto the updated default branch
+	Branches []string // Feature branches to rebase (defaults to the current branch)
+	All      bool     // Rebase every local branch that tracks a remote branch
+	NoPush   bool     // Skip pushing the updated branches to origin
+}
+
+// ForkSyncResult describes what happened during a fork sync
+type ForkSyncResult struct {
+	DefaultBranch string
+	Updated       bool
+	Pushed        bool
+	Rebased       []string
+	Conflicted    []string
+	// PushedBranches were rebased and pushed to their remote with a lease
+	PushedBranches []string
+	// Unpushed were rebased but their remote copies still hold the old commits
+	Unpushed []string
+}
+
+// SyncFork fetches the upstream remote, fast-forwards the fork's default branch,
+// pushes it to origin, and optionally rebases feature branches onto it.
+// It mirrors GitHub's "Sync fork" button, but runs locally. Only the branch
+// you were on is rebased unless others are named or opts.All is set, and a
+// rebased branch that tracks a remote is pushed with --force-with-lease.
+func SyncFork(g git.Service, opts ForkSyncOptions) (*ForkSyncResult, error) {
+	if err := verifyRepoState(g); err != nil {
+		return nil, err
+	}
+
+	upstream := opts.Upstream
+	if upstream == "" {
+		upstream = "upstream"
+	}
+
+	remotes, err := g.Run("remote")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remotes: %w", err)
+	}
+	if !containsLine(remotes, upstream) {
+		return nil, fmt.Errorf("remote '%s' not found; add it with 'git remote add %s <url>'", upstream, upstream)
+	}
+
+	curBranch, err := g.CurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	db, err := g.DefaultBranch()
+	if err != nil {
+		db = "main"
+	}
+	result := &ForkSyncResult{DefaultBranch: db}
+
+	// Keep any work in progress safe while we move between branches
+	stashed, _, err := handleWorkingDirectory(g)
+	if err != nil {
+		return nil, err
+	}
+	restore := func() {
+		if cb, _ := g.CurrentBranch(); cb != curBranch {
+			_ = g.Checkout(curBranch)
+		}
+		if stashed {
+			if err := g.StashPop(); err != nil {
+				ui.Warning("Failed to restore your changes. Run 'git stash pop' to restore them.")
+			}
+		}
+	}
+	defer restore()
+
+	if _, err := g.Run("fetch", upstream, "--prune"); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", upstream, err)
+	}
+
+	if err := g.Checkout(db); err != nil {
+		return nil, fmt.Errorf("failed to checkout %s: %w", db, err)
+	}
+
+	before, _ := g.GetCommitHash(db)
+	upstreamRef := upstream + "/" + db
+	if _, err := g.Run("merge", "--ff-only", upstreamRef); err != nil {
+		return nil, fmt.Errorf("your fork's '%s' has commits that are not in '%s'; move them to a feature branch, then reset '%s' to '%s'",
+			db, upstreamRef, db, upstreamRef)
+	}
+	after, _ := g.GetCommitHash(db)
+	result.Updated = before != after
+
+	if !opts.NoPush {
+		if err := g.Push(db, false); err != nil {
+			return result, fmt.Errorf("failed to push %s to origin: %w", db, err)
+		}
+		result.Pushed = true
+	}
+
+	if !opts.Rebase {
+		return result, nil
+	}
+
+	branches, err := forkSyncBranches(g, opts, curBranch)
+	if err != nil {
+		return result, err
+	}
+
+	for _, br := range branches {
+		br = strings.TrimSpace(br)
+		if br == "" || br == db {
+			continue
+		}
+		if err := g.Checkout(br); err != nil {
+			return result, fmt.Errorf("failed to checkout %s: %w", br, err)
+		}
+		old, _ := g.GetCommitHash(br)
+		if _, err := g.Run("rebase", db); err != nil {
+			// Leave the branch as it was and let the user resolve it with 'sage sync'
+			if rebasing, _ := g.IsRebasing(); rebasing {
+				_ = g.RebaseAbort()
+			}
+			result.Conflicted = append(result.Conflicted, br)
+			continue
+		}
+		result.Rebased = append(result.Rebased, br)
+
+		// A rebased branch no longer matches its remote copy; replace it only
+		// if nobody else has pushed to it since we last fetched
+		if moved, _ := g.GetCommitHash(br); moved == old {
+			continue
+		}
+		if upstream, _ := g.Upstream(br); upstream == "" {
+			continue
+		}
+		if opts.NoPush {
+			result.Unpushed = append(result.Unpushed, br)
+			continue
+		}
+		if err := g.PushWithLease(br); err != nil {
+			result.Unpushed = append(result.Unpushed, br)
+			continue
+		}
+		result.PushedBranches = append(result.PushedBranches, br)
+	}
+
+	return result, nil
+}
+
+// forkSyncBranches picks the branches to rebase: those named, every branch
+// tracking a remote branch with opts.All, or else the branch you were on
+func forkSyncBranches(g git.Service, opts ForkSyncOptions, current string) ([]string, error) {
+	if len(opts.Branches) > 0 {
+		return opts.Branches, nil
+	}
+	if !opts.All {
+		return []string{current}, nil
+	}
+	all, err := g.ListBranches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	var tracked []string
+	for _, br := range all {
+		if upstream, _ := g.Upstream(br); upstream != "" {
+			tracked = append(tracked, br)
+		}
+	}
+	return tracked, nil
+}
+
+// containsLine reports whether out contains a line equal to want
+func containsLine(out, want string) bool {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// forkRepo turns the test repository into a fork: its origin is copied to a
+// bare upstream, which a maintainer then moves on by one commit. It returns
+// upstream's new main.
+func (r *testRepo) forkRepo() string {
+	r.t.Helper()
+	upstream := filepath.Join(r.root, "upstream.git")
+	r.gitIn(r.root, "clone", "--bare", r.origin, upstream)
+	r.git("remote", "add", "upstream", upstream)
+
+	maintainer := filepath.Join(r.root, "maintainer")
+	r.gitIn(r.root, "clone", upstream, maintainer)
+	r.commitIn(maintainer, "upstream.txt", "new\n", "Upstream moves on")
+	r.gitIn(maintainer, "push", "origin", "main")
+	return r.gitIn(maintainer, "rev-parse", "HEAD")
+}
+
+// branch starts name from main with one commit, pushing it if push is set,
+// and goes back to main
+func (r *testRepo) branch(name string, push bool) {
+	r.t.Helper()
+	r.git("checkout", "-b", name, "main")
+	r.commit(name+".txt", name+"\n", "Work on "+name)
+	if push {
+		r.git("push", "-u", "origin", name)
+	}
+	r.git("checkout", "main")
+}
+
+func TestSyncForkRebasesCurrentBranchOnly(t *testing.T) {
+	r := newTestRepo(t)
+	upstreamMain := r.forkRepo()
+	r.branch("feature", true)
+	r.branch("other", true)
+	other := r.rev("other")
+	r.git("checkout", "feature")
+
+	result, err := SyncFork(git.NewShellGit(), ForkSyncOptions{Rebase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Updated || !result.Pushed {
+		t.Errorf("result = %+v, want main updated and pushed", result)
+	}
+	if got := r.gitIn(r.origin, "rev-parse", "main"); got != upstreamMain {
+		t.Errorf("origin main = %s, want upstream's %s", got, upstreamMain)
+	}
+	if !slices.Equal(result.Rebased, []string{"feature"}) || !slices.Equal(result.PushedBranches, []string{"feature"}) {
+		t.Errorf("rebased %v and pushed %v, want only feature", result.Rebased, result.PushedBranches)
+	}
+	if !r.isAncestor(upstreamMain, "feature") {
+		t.Error("feature was not rebased onto upstream's main")
+	}
+	if got := r.gitIn(r.origin, "rev-parse", "feature"); got != r.rev("feature") {
+		t.Error("the rebased feature was not pushed")
+	}
+	if r.rev("other") != other {
+		t.Error("a branch that wasn't checked out was rebased")
+	}
+	if got := r.git("branch", "--show-current"); got != "feature" {
+		t.Errorf("left on %q, want feature", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncForkAllRebasesTrackedBranches(t *testing.T) {
+	r := newTestRepo(t)
+	upstreamMain := r.forkRepo()
+	r.branch("feature", true)
+	r.branch("other", true)
+	r.branch("scratch", false)
+	scratch := r.rev("scratch")
+
+	result, err := SyncFork(git.NewShellGit(), ForkSyncOptions{Rebase: true, All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(result.Rebased)
+	if !slices.Equal(result.Rebased, []string{"feature", "other"}) {
+		t.Errorf("rebased %v, want feature and other", result.Rebased)
+	}
+	for _, br := range []string{"feature", "other"} {
+		if !r.isAncestor(upstreamMain, br) {
+			t.Errorf("%s was not rebased onto upstream's main", br)
+		}
+	}
+	if r.rev("scratch") != scratch {
+		t.Error("a branch without a remote was rebased")
+	}
+}
+
+func TestSyncForkLeavesOthersPushesAlone(t *testing.T) {
+	r := newTestRepo(t)
+	r.forkRepo()
+	r.branch("feature", true)
+
+	// Someone else pushes to the fork's feature branch after we last fetched
+	teammate := r.clone("teammate")
+	r.gitIn(teammate, "checkout", "feature")
+	r.commitIn(teammate, "theirs.txt", "theirs\n", "Their work")
+	r.gitIn(teammate, "push", "origin", "feature")
+	theirs := r.gitIn(teammate, "rev-parse", "HEAD")
+	r.git("checkout", "feature")
+
+	result, err := SyncFork(git.NewShellGit(), ForkSyncOptions{Rebase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Unpushed, []string{"feature"}) || len(result.PushedBranches) != 0 {
+		t.Errorf("pushed %v and left %v, want feature left unpushed", result.PushedBranches, result.Unpushed)
+	}
+	if got := r.gitIn(r.origin, "rev-parse", "feature"); got != theirs {
+		t.Error("the push overwrote a commit it had not seen")
+	}
+}
+
+func TestSyncForkNoPush(t *testing.T) {
+	r := newTestRepo(t)
+	r.forkRepo()
+	r.branch("feature", true)
+	originMain, originFeature := r.rev("main"), r.rev("feature")
+	r.git("checkout", "feature")
+
+	result, err := SyncFork(git.NewShellGit(), ForkSyncOptions{Rebase: true, NoPush: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pushed || !slices.Equal(result.Unpushed, []string{"feature"}) {
+		t.Errorf("result = %+v, want nothing pushed and feature reported", result)
+	}
+	if r.gitIn(r.origin, "rev-parse", "main") != originMain || r.gitIn(r.origin, "rev-parse", "feature") != originFeature {
+		t.Error("origin changed with NoPush")
+	}
+}
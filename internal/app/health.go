@@ -0,0 +1,219 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// LargeFileSize is the size from which a file in a commit that hasn't been
+// pushed is worth a warning: once it's pushed, it's in every clone for good
+const LargeFileSize = 5 << 20
+
+// git gc --auto packs the repository once there are this many loose objects
+// or packs; sage doctor goes by the same limits
+const (
+	gcLooseLimit = 6700
+	gcPackLimit  = 50
+)
+
+// StaleBranches returns the local branches, other than base and current,
+// whose last commit is more than days old, oldest first
+func StaleBranches(g git.Service, base, current string, days int) ([]git.BranchSummary, error) {
+	summaries, err := g.BranchSummaries(base, false)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var stale []git.BranchSummary
+	for _, s := range summaries {
+		if s.Name != base && s.Name != current && s.LastCommit.Before(cutoff) {
+			stale = append(stale, s)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].LastCommit.Before(stale[j].LastCommit) })
+	return stale, nil
+}
+
+// UnpushedLargeFiles returns the files of LargeFileSize or more added by
+// commits that aren't on any remote yet
+func UnpushedLargeFiles(g git.Service) ([]git.Blob, error) {
+	return g.LargeBlobs(LargeFileSize, "HEAD", "--not", "--remotes")
+}
+
+// NeedsGC reports whether the repository has enough loose objects or packs
+// to slow git down
+func NeedsGC(c git.ObjectCounts) bool {
+	return c.Loose > gcLooseLimit || c.Packs > gcPackLimit
+}
+
+// BrokenHook is a git hook that won't run
+type BrokenHook struct {
+	Path    string
+	Problem string
+	// Fixable is true when making the hook executable is all it needs
+	Fixable bool
+}
+
+// FindBrokenHooks looks through the hooks directory for hooks git would
+// skip or fail to start: ones that aren't executable, dangling symlinks and
+// scripts whose interpreter is missing
+func FindBrokenHooks(g git.Service) ([]BrokenHook, error) {
+	dir, err := g.HooksDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var broken []BrokenHook
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".sample") || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			broken = append(broken, BrokenHook{Path: path, Problem: "points at a file that doesn't exist"})
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+			broken = append(broken, BrokenHook{Path: path, Problem: "isn't executable, so git skips it", Fixable: true})
+			continue
+		}
+		if interpreter := hookInterpreter(path); interpreter != "" {
+			if _, err := exec.LookPath(interpreter); err != nil {
+				broken = append(broken, BrokenHook{Path: path, Problem: fmt.Sprintf("runs %s, which isn't installed", interpreter)})
+			}
+		}
+	}
+	return broken, nil
+}
+
+// hookInterpreter returns the program a script's #! line runs, looking past
+// env to the command it starts
+func hookInterpreter(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if !strings.HasPrefix(line, "#!") || len(fields) == 0 {
+		return ""
+	}
+	if filepath.Base(fields[0]) == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				return f
+			}
+		}
+		return ""
+	}
+	return fields[0]
+}
+
+// FixHook makes a hook executable
+func FixHook(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, info.Mode().Perm()|0o111); err != nil {
+		return fmt.Errorf("failed to make %s executable: %w", path, err)
+	}
+	return nil
+}
+
+// EmailDomains is the user's commit email checked against the domains the
+// repository expects
+type EmailDomains struct {
+	Email    string
+	Expected []string // from doctor.email_domains, or else what other authors use
+	Mismatch bool
+}
+
+// recentAuthors is how many commits CheckEmailDomain looks back over when
+// doctor.email_domains isn't set
+const recentAuthors = 200
+
+// CheckEmailDomain compares the domain of user.email with the ones listed in
+// doctor.email_domains. Without that, it compares it with the domain most of
+// the recent commits by other people were made with, which catches a
+// personal address used on a work repository.
+func CheckEmailDomain(g git.Service) (EmailDomains, error) {
+	email, err := g.GetConfigValue("user.email")
+	if err != nil {
+		return EmailDomains{}, err
+	}
+	check := EmailDomains{Email: strings.TrimSpace(email)}
+	if check.Email == "" {
+		return check, nil
+	}
+	domain := emailDomain(check.Email)
+
+	if list := config.Get("doctor.email_domains", true); list != "" {
+		for _, d := range strings.Split(list, ",") {
+			if d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@")); d != "" {
+				check.Expected = append(check.Expected, d)
+			}
+		}
+		check.Mismatch = len(check.Expected) > 0 && !matchesDomain(domain, check.Expected)
+		return check, nil
+	}
+
+	out, err := g.Run("log", fmt.Sprintf("--max-count=%d", recentAuthors), "--format=%ae")
+	if err != nil {
+		return check, nil
+	}
+	counts := map[string]int{}
+	others := 0
+	for _, ae := range strings.Fields(out) {
+		if strings.EqualFold(ae, check.Email) || strings.Contains(ae, "noreply") {
+			continue
+		}
+		counts[emailDomain(ae)]++
+		others++
+	}
+	for d, n := range counts {
+		// Only a clear majority says what the repository expects
+		if n*2 > others && others >= 5 {
+			check.Expected = []string{d}
+			check.Mismatch = !matchesDomain(domain, check.Expected)
+		}
+	}
+	return check, nil
+}
+
+// matchesDomain reports whether domain is one of domains or below one
+func matchesDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func emailDomain(email string) string {
+	_, domain, _ := strings.Cut(email, "@")
+	return strings.ToLower(domain)
+}
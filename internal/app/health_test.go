@@ -0,0 +1,107 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestUnpushedLargeFiles(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.commit("small.txt", "small\n", "Add small file")
+	r.commit("video.bin", strings.Repeat("x", LargeFileSize+1), "Add video")
+
+	blobs, err := UnpushedLargeFiles(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].Path != "video.bin" || blobs[0].Size != LargeFileSize+1 {
+		t.Fatalf("large files = %+v", blobs)
+	}
+
+	r.git("push", "origin", "main")
+	if blobs, err = UnpushedLargeFiles(g); err != nil || len(blobs) != 0 {
+		t.Errorf("after pushing, large files = %+v, %v", blobs, err)
+	}
+}
+
+func TestFindBrokenHooks(t *testing.T) {
+	r := newTestRepo(t)
+	hooks := filepath.Join(r.dir, ".git", "hooks")
+	write := func(name, content string, mode os.FileMode) {
+		if err := os.WriteFile(filepath.Join(hooks, name), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pre-commit", "#!/bin/sh\nexit 0\n", 0o644)
+	write("commit-msg", "#!/usr/bin/env sage-no-such-interpreter\n", 0o755)
+	write("pre-push", "#!/bin/sh\nexit 0\n", 0o755)
+	write("post-merge.sample", "#!/bin/sh\n", 0o644)
+
+	broken, err := FindBrokenHooks(git.NewShellGit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := map[string]BrokenHook{}
+	for _, h := range broken {
+		problems[filepath.Base(h.Path)] = h
+	}
+	if len(problems) != 2 || !problems["pre-commit"].Fixable || problems["commit-msg"].Fixable {
+		t.Fatalf("broken hooks = %+v", broken)
+	}
+	if !strings.Contains(problems["commit-msg"].Problem, "sage-no-such-interpreter") {
+		t.Errorf("commit-msg problem = %q", problems["commit-msg"].Problem)
+	}
+
+	if err := FixHook(problems["pre-commit"].Path); err != nil {
+		t.Fatal(err)
+	}
+	if broken, _ = FindBrokenHooks(git.NewShellGit()); len(broken) != 1 {
+		t.Errorf("after fixing pre-commit, broken hooks = %+v", broken)
+	}
+}
+
+func TestStaleBranches(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "old")
+	t.Setenv("GIT_COMMITTER_DATE", "2001-01-01T00:00:00")
+	r.commit("old.txt", "old\n", "Old work")
+	os.Unsetenv("GIT_COMMITTER_DATE")
+	r.git("checkout", "main")
+	r.git("checkout", "-b", "fresh")
+	r.commit("fresh.txt", "fresh\n", "Fresh work")
+
+	stale, err := StaleBranches(git.NewShellGit(), "main", "fresh", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].Name != "old" {
+		t.Errorf("stale branches = %+v, want only old", stale)
+	}
+}
+
+func TestCheckEmailDomain(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	for i := 0; i < 6; i++ {
+		r.git("-c", "user.email=dev"+string(rune('a'+i))+"@corp.example", "commit", "--allow-empty", "-m", "Work")
+	}
+	r.git("-c", "user.email=1+bot@users.noreply.github.com", "commit", "--allow-empty", "-m", "Bot")
+
+	check, err := CheckEmailDomain(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Mismatch || check.Email != "sage@example.com" || len(check.Expected) != 1 || check.Expected[0] != "corp.example" {
+		t.Errorf("check = %+v, want a mismatch with corp.example", check)
+	}
+
+	r.git("config", "user.email", "me@eu.corp.example")
+	if check, _ = CheckEmailDomain(g); check.Mismatch {
+		t.Errorf("check = %+v; a subdomain was still a mismatch", check)
+	}
+}
@@ -2,8 +2,6 @@ package app
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/crazywolf132/sage/internal/git"
@@ -17,24 +15,24 @@ type HistoryOptions struct {
 }
 
 type CommitStats struct {
-	Added    int
-	Deleted  int
-	Modified int
-	Files    map[string]int // Maps file paths to number of changes
+	Added    int            `json:"added" yaml:"added"`
+	Deleted  int            `json:"deleted" yaml:"deleted"`
+	Modified int            `json:"modified" yaml:"modified"`
+	Files    map[string]int `json:"files,omitempty" yaml:"files,omitempty"` // Maps file paths to number of changes
 }
 
 type CommitInfo struct {
-	Hash       string
-	ShortHash  string
-	AuthorName string
-	Date       time.Time
-	Message    string
-	Stats      CommitStats
+	Hash       string      `json:"hash" yaml:"hash"`
+	ShortHash  string      `json:"short_hash" yaml:"short_hash"`
+	AuthorName string      `json:"author" yaml:"author"`
+	Date       time.Time   `json:"date" yaml:"date"`
+	Message    string      `json:"message" yaml:"message"`
+	Stats      CommitStats `json:"stats" yaml:"stats"`
 }
 
 type HistoryResult struct {
-	BranchName string
-	Commits    []CommitInfo
+	BranchName string       `json:"branch" yaml:"branch"`
+	Commits    []CommitInfo `json:"commits" yaml:"commits"`
 }
 
 func GetHistory(g git.Service, branch string, limit int, showStats, showAll bool) (*HistoryResult, error) {
@@ -49,80 +47,39 @@ func GetHistory(g git.Service, branch string, limit int, showStats, showAll bool
 			return nil, err
 		}
 	}
-	log, err := g.Log(branch, limit, showStats, showAll)
+	if showAll {
+		limit = 0
+	}
+	commits, err := g.Commits(git.CommitsOptions{Range: branch, Limit: limit, Stats: showStats})
 	if err != nil {
 		return nil, err
 	}
-	commits := parseGitLog(log, showStats)
 	return &HistoryResult{
 		BranchName: branch,
-		Commits:    commits,
+		Commits:    toCommitInfos(commits),
 	}, nil
 }
 
-func parseGitLog(log string, stats bool) []CommitInfo {
-	var commits []CommitInfo
-	var current *CommitInfo
-
-	lines := strings.Split(strings.TrimSpace(log), "\n")
-	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-		if strings.Contains(line, "\x00") {
-			// This is a commit line
-			if current != nil {
-				commits = append(commits, *current)
-			}
-
-			parts := strings.Split(line, "\x00")
-			if len(parts) < 4 {
-				continue
-			}
-
-			// Parse timestamp
-			timestamp, _ := strconv.ParseInt(parts[2], 10, 64)
-			date := time.Unix(timestamp, 0)
-
-			current = &CommitInfo{
-				Hash:       parts[0],
-				ShortHash:  parts[0][:7],
-				AuthorName: parts[1],
-				Date:       date,
-				Message:    parts[3],
-				Stats: CommitStats{
-					Files: make(map[string]int),
-				},
-			}
-
-			// Look ahead for stats if requested
-			if stats && i+3 < len(lines) {
-				// Skip blank line
-				i++
-				// Next lines should be stats until we hit a blank line or another commit
-				for i+1 < len(lines) {
-					statLine := lines[i+1]
-					if statLine == "" || strings.Contains(statLine, "\x00") {
-						break
-					}
-					parts := strings.Fields(statLine)
-					if len(parts) >= 3 {
-						added, _ := strconv.Atoi(parts[0])
-						deleted, _ := strconv.Atoi(parts[1])
-						file := parts[2]
-						current.Stats.Added += added
-						current.Stats.Deleted += deleted
-						current.Stats.Modified++
-						current.Stats.Files[file] = added + deleted
-					}
-					i++
-				}
-			}
+func toCommitInfos(commits []git.Commit) []CommitInfo {
+	infos := make([]CommitInfo, 0, len(commits))
+	for _, c := range commits {
+		info := CommitInfo{
+			Hash:       c.Hash,
+			ShortHash:  c.ShortHash(),
+			AuthorName: c.Author,
+			Date:       c.Date,
+			Message:    c.Subject,
+			Stats: CommitStats{
+				Files: make(map[string]int),
+			},
 		}
+		for _, f := range c.Files {
+			info.Stats.Added += f.Added
+			info.Stats.Deleted += f.Deleted
+			info.Stats.Modified++
+			info.Stats.Files[f.Path] = f.Added + f.Deleted
+		}
+		infos = append(infos, info)
 	}
-
-	// Don't forget the last commit
-	if current != nil {
-		commits = append(commits, *current)
-	}
-
-	return commits
+	return infos
 }
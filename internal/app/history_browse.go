@@ -0,0 +1,167 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+)
+
+// HistoryGraph draws a commit graph one row at a time, for commits read
+// newest first. Each lane is the hash of the commit it is waiting for.
+type HistoryGraph struct {
+	lanes []string
+}
+
+// Row returns the graph prefix for c and moves the lanes on to its parents
+func (h *HistoryGraph) Row(c git.Commit) string {
+	col := -1
+	for i, l := range h.lanes {
+		if l == c.Hash {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		col = len(h.lanes)
+		h.lanes = append(h.lanes, c.Hash)
+	}
+
+	var row strings.Builder
+	for i, l := range h.lanes {
+		switch {
+		case i == col:
+			row.WriteString("●")
+		case l == c.Hash:
+			// Another branch of history ends here too
+			row.WriteString("┘")
+		default:
+			row.WriteString("│")
+		}
+		row.WriteString(" ")
+	}
+
+	// Lanes that were waiting for c close; its first parent takes its lane
+	// and any others open new ones
+	next := make([]string, 0, len(h.lanes)+len(c.Parents))
+	for i, l := range h.lanes {
+		switch {
+		case i == col:
+			if len(c.Parents) > 0 {
+				next = append(next, c.Parents[0])
+			}
+		case l != c.Hash:
+			next = append(next, l)
+		}
+	}
+	for _, p := range c.Parents[min(1, len(c.Parents)):] {
+		if !slices.Contains(next, p) {
+			next = append(next, p)
+		}
+	}
+	h.lanes = next
+	return row.String()
+}
+
+// FuzzyMatch reports whether every word of query appears in text as a
+// subsequence, ignoring case, so "fx lgn" matches "fix login"
+func FuzzyMatch(query, text string) bool {
+	text = strings.ToLower(text)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !subsequence(word, text) {
+			return false
+		}
+	}
+	return true
+}
+
+func subsequence(word, text string) bool {
+	w := []rune(word)
+	i := 0
+	for _, r := range text {
+		if i < len(w) && r == w[i] {
+			i++
+		}
+	}
+	return i == len(w)
+}
+
+// CommitSearchText is what FuzzyMatch searches for a commit: its hash,
+// subject, author and the files it touched
+func CommitSearchText(c git.Commit) string {
+	parts := []string{c.ShortHash(), c.Subject, c.Author, c.Email}
+	for _, f := range c.Files {
+		parts = append(parts, f.Path)
+	}
+	return strings.Join(parts, " ")
+}
+
+// CommitDiff returns the changes c made, against its first parent for merges
+func CommitDiff(g git.Service, c git.Commit) (string, error) {
+	out, err := g.Run("show", "--format=", "--first-parent", "--no-color", c.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the diff of %s: %w", c.ShortHash(), err)
+	}
+	return out, nil
+}
+
+// CheckoutCommit checks out a commit with a detached HEAD
+func CheckoutCommit(g git.Service, hash string) error {
+	if _, err := g.Run("checkout", "--detach", hash); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", shortHash(hash), err)
+	}
+	return nil
+}
+
+// RevertCommit commits the inverse of c on the current branch. Merges are
+// reverted against their first parent.
+func RevertCommit(g git.Service, c git.Commit) error {
+	JournalSnapshot(g, "sage history revert "+c.ShortHash())
+	args := []string{"revert", "--no-edit"}
+	if len(c.Parents) > 1 {
+		args = append(args, "-m", "1")
+	}
+	if _, err := g.Run(append(args, c.Hash)...); err != nil {
+		return fmt.Errorf("failed to revert %s: %w", c.ShortHash(), err)
+	}
+	return nil
+}
+
+// CherryPickTo checks out branch and cherry-picks c onto it. On a conflict
+// the branch is left checked out mid cherry-pick for the user to resolve.
+func CherryPickTo(g git.Service, c git.Commit, branch string) error {
+	JournalSnapshot(g, "sage history cherry-pick "+c.ShortHash()+" to "+branch)
+	if err := g.Checkout(branch); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", branch, err)
+	}
+	args := []string{"cherry-pick"}
+	if len(c.Parents) > 1 {
+		args = append(args, "-m", "1")
+	}
+	if _, err := g.Run(append(args, c.Hash)...); err != nil {
+		return fmt.Errorf("failed to cherry-pick %s onto %s: %w", c.ShortHash(), branch, err)
+	}
+	return nil
+}
+
+// CommitURL returns the web page for a commit on the repository's host
+func CommitURL(r *repoinfo.Resolver, hash string) (string, error) {
+	info, err := r.Repo()
+	if err != nil {
+		return "", err
+	}
+	host := info.Host
+	if host == "" {
+		host = repoinfo.DefaultHost
+	}
+	return fmt.Sprintf("https://%s/%s/commit/%s", host, info.FullName(), hash), nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
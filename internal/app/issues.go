@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// ticketTrailer is the commit trailer carrying the link to the branch's ticket
+const ticketTrailer = "Refs"
+
+// branchTicket looks up the ticket named in branch. Lookups are a courtesy,
+// so failures are warnings and give nil.
+func branchTicket(t issues.Tracker, branch string) *issues.Ticket {
+	if t == nil {
+		return nil
+	}
+	id, err := issues.IDFromBranch(branch)
+	if err != nil {
+		ui.Warning(err.Error())
+		return nil
+	}
+	if id == "" {
+		return nil
+	}
+	ticket, err := t.Ticket(id)
+	if err != nil {
+		ui.Warnf("Couldn't look up %s: %v\n", id, err)
+		return nil
+	}
+	return ticket
+}
+
+// linkTicket adds a trailer linking ticket to msg, unless issues.link_commits
+// is false or the message links it already
+func linkTicket(msg string, ticket *issues.Ticket) string {
+	if ticket == nil || ticket.URL == "" || config.Get("issues.link_commits", true) == "false" ||
+		strings.Contains(msg, ticket.URL) {
+		return msg
+	}
+	msg = strings.TrimRight(msg, "\n")
+	// Join an existing trailer block rather than start a second one
+	lines := strings.Split(msg, "\n")
+	if last := lines[len(lines)-1]; len(lines) < 3 || !isTrailer(last) {
+		msg += "\n"
+	}
+	return msg + "\n" + ticketTrailer + ": " + ticket.URL
+}
+
+// isTrailer reports whether line looks like "Key: value" with a key git
+// would take for a trailer
+func isTrailer(line string) bool {
+	key, _, ok := strings.Cut(line, ": ")
+	return ok && key != "" && !strings.ContainsAny(key, " \t")
+}
+
+// ticketSection describes ticket for a PR body, unless issues.link_prs is
+// false or body links it already
+func ticketSection(body string, ticket *issues.Ticket) string {
+	if ticket == nil || config.Get("issues.link_prs", true) == "false" ||
+		(ticket.URL != "" && strings.Contains(body, ticket.URL)) {
+		return body
+	}
+	line := ticket.ID
+	if ticket.URL != "" {
+		line = fmt.Sprintf("[%s](%s)", ticket.ID, ticket.URL)
+	}
+	if ticket.Title != "" {
+		line += ": " + ticket.Title
+	}
+	if ticket.Status != "" {
+		line += fmt.Sprintf(" (%s)", ticket.Status)
+	}
+	section := "## Ticket\n\n" + line + "\n"
+	if body = strings.TrimRight(body, "\n"); body == "" {
+		return section
+	}
+	return body + "\n\n" + section
+}
+
+// TransitionBranchTicket moves the ticket named in branch to the status in
+// issues.transition_on_merge. It returns the ticket's ID and the status,
+// with an empty ID when there is no ticket or nothing to do.
+func TransitionBranchTicket(t issues.Tracker, branch string) (id, status string, err error) {
+	status = strings.TrimSpace(config.Get("issues.transition_on_merge", true))
+	if t == nil || status == "" {
+		return "", status, nil
+	}
+	if id, err = issues.IDFromBranch(branch); err != nil || id == "" {
+		return "", status, err
+	}
+	if err := t.Transition(id, status); err != nil {
+		return id, status, fmt.Errorf("failed to move %s to %s: %w", id, status, err)
+	}
+	return id, status, nil
+}
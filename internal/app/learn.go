@@ -0,0 +1,244 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// learnReadme is the file the tutorial has the learner and their teammate
+// both change, so that syncing runs into a conflict
+const learnReadme = `# Greeter
+
+A tiny project for learning sage.
+
+Greeting: hello
+`
+
+// Sandbox is the throwaway setup for 'sage learn': the learner's clone, a
+// bare repository acting as origin, and a teammate's clone that pushes
+// changes of its own
+type Sandbox struct {
+	Root     string // temporary directory holding everything
+	Repo     string // the learner's clone
+	Remote   string // bare repository used as origin
+	teammate string
+}
+
+// NewSandbox creates the tutorial repositories in a temporary directory
+func NewSandbox() (*Sandbox, error) {
+	root, err := os.MkdirTemp("", "sage-learn-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	// Resolve symlinks such as macOS's /var -> /private/var so paths match git's
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	s := &Sandbox{
+		Root:     root,
+		Repo:     filepath.Join(root, "greeter"),
+		Remote:   filepath.Join(root, "origin.git"),
+		teammate: filepath.Join(root, "teammate"),
+	}
+
+	steps := [][]string{
+		{"init", "--bare", "--initial-branch=main", s.Remote},
+		{"clone", "--quiet", s.Remote, s.Repo},
+		{"-C", s.Repo, "config", "user.name", "Sage Learner"},
+		{"-C", s.Repo, "config", "user.email", "learner@example.com"},
+		{"-C", s.Repo, "checkout", "--quiet", "-B", "main"},
+	}
+	for _, args := range steps {
+		if _, err := sandboxGit(args...); err != nil {
+			s.Cleanup()
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(s.Repo, "README.md"), []byte(learnReadme), 0644); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	steps = [][]string{
+		{"-C", s.Repo, "add", "README.md"},
+		{"-C", s.Repo, "commit", "--quiet", "-m", "Initial commit"},
+		{"-C", s.Repo, "push", "--quiet", "-u", "origin", "main"},
+		{"-C", s.Repo, "remote", "set-head", "origin", "main"},
+		{"clone", "--quiet", s.Remote, s.teammate},
+		{"-C", s.teammate, "config", "user.name", "Sam Teammate"},
+		{"-C", s.teammate, "config", "user.email", "sam@example.com"},
+	}
+	for _, args := range steps {
+		if _, err := sandboxGit(args...); err != nil {
+			s.Cleanup()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// sandboxGit runs git for the sandbox, outside the repository sage was started in
+func sandboxGit(args ...string) (string, error) {
+	cmd, err := git.SetupSecureCommand("git", args...)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// repoGit runs git in the learner's clone
+func (s *Sandbox) repoGit(args ...string) (string, error) {
+	return sandboxGit(append([]string{"-C", s.Repo}, args...)...)
+}
+
+// Cleanup removes the sandbox
+func (s *Sandbox) Cleanup() error {
+	return os.RemoveAll(s.Root)
+}
+
+// TeammatePushesConflict has the teammate change the same line the learner
+// did and push it to main, then brings the learner's main up to date with it
+func (s *Sandbox) TeammatePushesConflict() error {
+	content := strings.Replace(learnReadme, "Greeting: hello", "Greeting: howdy", 1)
+	if err := os.WriteFile(filepath.Join(s.teammate, "README.md"), []byte(content), 0644); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"pull", "--quiet", "--ff-only"},
+		{"commit", "--quiet", "-am", "Make the greeting friendlier"},
+		{"push", "--quiet", "origin", "main"},
+	} {
+		if _, err := sandboxGit(append([]string{"-C", s.teammate}, args...)...); err != nil {
+			return err
+		}
+	}
+	_, err := s.repoGit("fetch", "--quiet", "origin", "main:main")
+	return err
+}
+
+// VerifyBranch checks that the learner is on branch
+func (s *Sandbox) VerifyBranch(branch string) error {
+	cur, err := s.repoGit("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	if cur != branch {
+		return fmt.Errorf("you're on %s, not %s", cur, branch)
+	}
+	return nil
+}
+
+// VerifyStaged checks that path has staged changes
+func (s *Sandbox) VerifyStaged(path string) error {
+	out, err := s.repoGit("diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	for _, f := range strings.Split(out, "\n") {
+		if f == path {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no staged changes yet", path)
+}
+
+// VerifyCommitted checks that the branch has a commit changing path that
+// isn't on main
+func (s *Sandbox) VerifyCommitted(path string) error {
+	out, err := s.repoGit("diff", "--name-only", "main...HEAD")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains("\n"+out+"\n", "\n"+path+"\n") {
+		return fmt.Errorf("there's no commit on this branch changing %s yet", path)
+	}
+	return nil
+}
+
+// VerifySynced checks that the branch contains the teammate's work on
+// origin/main and that no merge or rebase is left half done
+func (s *Sandbox) VerifySynced() error {
+	for _, state := range []string{"MERGE_HEAD", "rebase-merge", "rebase-apply"} {
+		p, err := s.repoGit("rev-parse", "--git-path", state)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(s.Repo, p)
+		}
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("the sync is still in progress; resolve the conflict and continue it")
+		}
+	}
+	teammate, err := sandboxGit("-C", s.Remote, "rev-parse", "main")
+	if err != nil {
+		return err
+	}
+	if _, err := s.repoGit("merge-base", "--is-ancestor", teammate, "HEAD"); err != nil {
+		return fmt.Errorf("your branch doesn't include your teammate's change yet")
+	}
+	data, err := os.ReadFile(filepath.Join(s.Repo, "README.md"))
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), "<<<<<<<") {
+		return fmt.Errorf("README.md still has conflict markers")
+	}
+	return nil
+}
+
+// tutorialGitHub stands in for GitHub in the tutorial. Only the calls
+// CreatePullRequest makes are implemented.
+type tutorialGitHub struct {
+	gh.Client
+}
+
+func (tutorialGitHub) CreatePR(title, body, head, base string, draft bool) (*gh.PullRequest, error) {
+	pr := &gh.PullRequest{
+		Number:  1,
+		Title:   title,
+		Body:    body,
+		State:   "open",
+		Draft:   draft,
+		HTMLURL: "https://github.com/you/greeter/pull/1",
+	}
+	pr.Head.Ref = head
+	pr.Base.Ref = base
+	return pr, nil
+}
+
+func (tutorialGitHub) GetPRTemplate() (string, error)                          { return "", nil }
+func (tutorialGitHub) AddLabels(prNumber int, labels []string) error           { return nil }
+func (tutorialGitHub) RequestReviewers(prNumber int, reviewers []string) error { return nil }
+
+// CreatePR runs the real pull request flow in the sandbox, pushing the
+// branch to the sandbox's origin, against a pretend GitHub
+func (s *Sandbox) CreatePR(title, body string) (*gh.PullRequest, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(s.Repo); err != nil {
+		return nil, err
+	}
+	defer os.Chdir(wd)
+	git.InvalidateCache()
+	defer git.InvalidateCache()
+
+	pr, err := CreatePullRequest(git.NewShellGit(), tutorialGitHub{}, CreatePROpts{Title: title, Body: body, Base: "main"})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sandboxGit("-C", s.Remote, "rev-parse", "--verify", "refs/heads/"+pr.Head.Ref); err != nil {
+		return nil, fmt.Errorf("the branch wasn't pushed: %w", err)
+	}
+	return pr, nil
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// staleLockAge is how old a lock must be to count as stale where the
+// process holding it can't be identified
+const staleLockAge = 10 * time.Minute
+
+// GitLock is a lock file git leaves while it writes the index, a ref or the
+// config. One left by a crashed git makes every later command fail.
+type GitLock struct {
+	Path  string
+	Age   time.Duration
+	PID   int  // a process that may hold it, 0 if none was found
+	Stale bool // safe to remove
+}
+
+// FindLocks lists the lock files in the repository: the index, HEAD, the
+// config and packed refs at the top of the git directory, and any ref
+func FindLocks(g git.Service) ([]GitLock, error) {
+	dirs, err := gitDirs(g)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".lock") {
+				paths = append(paths, filepath.Join(dir, e.Name()))
+			}
+		}
+		_ = filepath.WalkDir(filepath.Join(dir, "refs"), func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(p, ".lock") {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+	}
+
+	var locks []GitLock
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if l, ok := inspectLock(p); ok {
+			locks = append(locks, l)
+		}
+	}
+	return locks, nil
+}
+
+// gitDirs returns the git directory and, in a linked worktree, the common
+// one that holds the refs and config
+func gitDirs(g git.Service) ([]string, error) {
+	out, err := g.Run("rev-parse", "--absolute-git-dir", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	dir := strings.TrimSpace(lines[0])
+	dirs := []string{dir}
+	if len(lines) > 1 {
+		// Relative to the working directory, like every path rev-parse prints
+		common, err := filepath.Abs(strings.TrimSpace(lines[1]))
+		if err != nil {
+			return nil, err
+		}
+		if common != dir {
+			dirs = append(dirs, common)
+		}
+	}
+	return dirs, nil
+}
+
+// inspectLock works out whether the lock at path is still in use. It
+// reports false if the lock has gone in the meantime.
+func inspectLock(path string) (GitLock, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return GitLock{}, false
+	}
+	l := GitLock{Path: path, Age: time.Since(info.ModTime())}
+	pid, known := lockHolder(path)
+	l.PID = pid
+	l.Stale = staleLock(pid, known, l.Age)
+	return l, true
+}
+
+// staleLock decides whether a lock is safe to remove: nothing holds it, and
+// either that is known for certain or the lock is older than a running git
+// would leave it
+func staleLock(pid int, known bool, age time.Duration) bool {
+	return pid == 0 && (known || age > staleLockAge)
+}
+
+// lockHolder finds a process that may hold path. On Linux it looks for the
+// process with the file open, and known is true; elsewhere any running git
+// may be the holder, and the age of the lock has to settle it.
+func lockHolder(path string) (pid int, known bool) {
+	if runtime.GOOS == "linux" {
+		if pid, ok := procHolder(path); ok {
+			return pid, true
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return 0, false
+	}
+	out, err := exec.Command("ps", "-A", "-o", "pid=,comm=").Output()
+	if err != nil {
+		return 0, false
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && filepath.Base(fields[1]) == "git" {
+			if n, err := strconv.Atoi(fields[0]); err == nil && n != os.Getpid() {
+				return n, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// procHolder scans /proc for a process with path open. ok is false when
+// /proc can't be read.
+func procHolder(path string) (pid int, ok bool) {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, false
+	}
+	for _, p := range procs {
+		n, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join("/proc", p.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join("/proc", p.Name(), "fd", fd.Name()))
+			if err == nil && target == path {
+				return n, true
+			}
+		}
+	}
+	return 0, true
+}
+
+// RemoveLock deletes a lock file after checking, again, that nothing holds it
+func RemoveLock(path string) error {
+	l, ok := inspectLock(path)
+	if !ok {
+		return nil
+	}
+	if !l.Stale {
+		if l.PID != 0 {
+			return fmt.Errorf("%s may still be in use by git (pid %d); let it finish or stop it first", path, l.PID)
+		}
+		return fmt.Errorf("%s is only %s old and may still be in use", path, l.Age.Round(time.Second))
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// BrokenRef is a ref git fsck found pointing at nothing usable
+type BrokenRef struct {
+	Ref      string
+	Problem  string
+	LastGood string // the newest commit from its reflog that still exists, if any
+}
+
+var fsckRefError = regexp.MustCompile(`(?m)^error: ((?:refs/\S+)|HEAD): (.+)$`)
+
+// FindBrokenRefs runs git fsck, checking connectivity only since blob
+// contents don't matter here, and returns the refs it complains about
+func FindBrokenRefs(g git.Service) ([]BrokenRef, error) {
+	out, err := g.Run("fsck", "--connectivity-only", "--no-dangling", "--no-progress")
+	if err != nil {
+		// fsck exits non-zero when it finds problems; they're on stderr
+		var gitErr *git.Error
+		if !errors.As(err, &gitErr) || gitErr.Status < 0 {
+			return nil, err
+		}
+		out += "\n" + gitErr.Stderr
+	}
+
+	dirs, _ := gitDirs(g)
+	var refs []BrokenRef
+	seen := map[string]bool{}
+	for _, m := range fsckRefError.FindAllStringSubmatch(out, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		refs = append(refs, BrokenRef{Ref: m[1], Problem: m[2], LastGood: lastGoodCommit(g, dirs, m[1])})
+	}
+	return refs, nil
+}
+
+// lastGoodCommit reads the reflog of ref, newest first, for a commit that
+// still exists
+func lastGoodCommit(g git.Service, dirs []string, ref string) string {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		b, err := os.ReadFile(filepath.Join(dirs[i], "logs", filepath.FromSlash(ref)))
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		for j := len(lines) - 1; j >= 0; j-- {
+			fields := strings.Fields(lines[j])
+			if len(fields) < 2 || strings.Trim(fields[1], "0") == "" {
+				continue
+			}
+			if kind, err := g.Run("cat-file", "-t", fields[1]); err == nil && strings.TrimSpace(kind) == "commit" {
+				return fields[1]
+			}
+		}
+	}
+	return ""
+}
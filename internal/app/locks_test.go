@@ -0,0 +1,97 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestStaleLock(t *testing.T) {
+	tests := []struct {
+		name  string
+		pid   int
+		known bool
+		age   time.Duration
+		want  bool
+	}{
+		{"held", 42, true, time.Hour, false},
+		{"maybe held by a running git", 42, false, time.Hour, false},
+		{"nothing holds it", 0, true, time.Second, true},
+		{"no holder found, but recent", 0, false, time.Minute, false},
+		{"no holder found and old", 0, false, staleLockAge + time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staleLock(tt.pid, tt.known, tt.age); got != tt.want {
+				t.Errorf("staleLock(%d, %v, %s) = %v, want %v", tt.pid, tt.known, tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindLocks(t *testing.T) {
+	r := newTestRepo(t)
+	gitDir := filepath.Join(r.dir, ".git")
+	index := filepath.Join(gitDir, "index.lock")
+	ref := filepath.Join(gitDir, "refs", "heads", "main.lock")
+	for _, p := range []string{index, ref} {
+		r.write(p, "")
+	}
+	// Backdate the index lock; the file times are what Age comes from
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(index, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	locks, err := FindLocks(git.NewShellGit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]GitLock{}
+	for _, l := range locks {
+		found[l.Path] = l
+	}
+	if len(found) != 2 {
+		t.Fatalf("found %+v, want the index and ref locks", locks)
+	}
+	if l := found[index]; l.Age < time.Hour-time.Minute || !l.Stale {
+		t.Errorf("index lock = %+v, want an hour old and stale", l)
+	}
+	if err := RemoveLock(index); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(index); !os.IsNotExist(err) {
+		t.Error("index.lock was not removed")
+	}
+}
+
+func TestLockHeldOpenIsNotStale(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("finding the holder needs /proc")
+	}
+	r := newTestRepo(t)
+	path := filepath.Join(r.dir, ".git", "index.lock")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	l, ok := inspectLock(path)
+	if !ok || l.Stale || l.PID != os.Getpid() {
+		t.Fatalf("got %+v, want held by this process", l)
+	}
+	if err := RemoveLock(path); err == nil {
+		t.Error("expected a lock in use to be kept")
+	}
+
+	// Once closed, nothing holds it and it can go however new it is
+	f.Close()
+	if l, _ := inspectLock(path); !l.Stale {
+		t.Errorf("got %+v after closing, want stale", l)
+	}
+}
@@ -0,0 +1,300 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// maxSeriesPromptSize caps how much of a patch series is sent to the AI
+const maxSeriesPromptSize = 12000
+
+// ErrPatchConflict means a patch stopped with conflicts; resolve them, then
+// continue the series
+var ErrPatchConflict = exitcode.New(exitcode.Conflict, errors.New("patch did not apply cleanly"))
+
+// MboxPatch is one patch of an emailed series
+type MboxPatch struct {
+	Subject string `json:"subject"`
+	Author  string `json:"author"`
+	File    string `json:"file"` // the patch, split out of the mailbox
+	raw     []byte
+}
+
+// MboxSeries is a patch series being applied. Patches are applied one git am
+// at a time so progress can be reported, and the queue is kept in
+// .git/.sage/mbox so the series survives a stop for conflicts.
+type MboxSeries struct {
+	Source   string      `json:"source"`
+	OrigHead string      `json:"orig_head"`
+	Patches  []MboxPatch `json:"patches"`
+	Next     int         `json:"next"`              // index of the first patch not yet applied
+	Stopped  bool        `json:"stopped,omitempty"` // git am stopped on patch Next
+}
+
+// patchPrefix matches the "[PATCH v2 3/7]" tag format-patch puts on subjects
+var patchPrefix = regexp.MustCompile(`^\[[^\]]*PATCH[^\]]*\]\s*`)
+
+// ParseMbox splits a mailbox into its patches. Messages without a diff, such
+// as cover letters, are left out.
+func ParseMbox(data []byte) ([]MboxPatch, error) {
+	var patches []MboxPatch
+	for _, msg := range splitMbox(data) {
+		headers := msg
+		if bytes.HasPrefix(headers, []byte("From ")) {
+			if i := bytes.IndexByte(headers, '\n'); i >= 0 {
+				headers = headers[i+1:]
+			}
+		}
+		m, err := mail.ReadMessage(bytes.NewReader(headers))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message %d: %w", len(patches)+1, err)
+		}
+		body := new(bytes.Buffer)
+		if _, err := body.ReadFrom(m.Body); err != nil {
+			return nil, err
+		}
+		if !bytes.Contains(body.Bytes(), []byte("\ndiff --git ")) && !bytes.HasPrefix(body.Bytes(), []byte("diff --git ")) {
+			continue
+		}
+
+		dec := new(mime.WordDecoder)
+		subject, err := dec.DecodeHeader(strings.Join(strings.Fields(m.Header.Get("Subject")), " "))
+		if err != nil {
+			subject = m.Header.Get("Subject")
+		}
+		author := m.Header.Get("From")
+		if addr, err := mail.ParseAddress(author); err == nil && addr.Name != "" {
+			author = addr.Name
+		}
+		patches = append(patches, MboxPatch{
+			Subject: patchPrefix.ReplaceAllString(subject, ""),
+			Author:  author,
+			raw:     msg,
+		})
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no patches found")
+	}
+	return patches, nil
+}
+
+// splitMbox cuts a mailbox at each "From " separator line, which stays at the
+// start of its message so git am reads each piece as a mailbox
+func splitMbox(data []byte) [][]byte {
+	var msgs [][]byte
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	var cur []byte
+	prevBlank := true
+	for _, line := range lines {
+		if prevBlank && bytes.HasPrefix(line, []byte("From ")) {
+			if len(bytes.TrimSpace(cur)) > 0 {
+				msgs = append(msgs, cur)
+			}
+			cur = nil
+		}
+		cur = append(cur, line...)
+		prevBlank = len(bytes.TrimSpace(line)) == 0
+	}
+	if len(bytes.TrimSpace(cur)) > 0 {
+		msgs = append(msgs, cur)
+	}
+	return msgs
+}
+
+func mboxDir(g git.Service) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "mbox"), nil
+}
+
+// LoadMboxSeries returns the series being applied, or nil if there is none
+func LoadMboxSeries(g git.Service) (*MboxSeries, error) {
+	dir, err := mboxDir(g)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "series.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read patch queue: %w", err)
+	}
+	var s MboxSeries
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse patch queue: %w", err)
+	}
+	return &s, nil
+}
+
+func saveMboxSeries(g git.Service, s *MboxSeries) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	dir, err := mboxDir(g)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "series.json"), data, 0644)
+}
+
+func clearMboxSeries(g git.Service) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	dir, err := mboxDir(g)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// StartMboxSeries queues the patches of a mailbox for ApplyMboxSeries
+func StartMboxSeries(g git.Service, source string, patches []MboxPatch) (*MboxSeries, error) {
+	if existing, err := LoadMboxSeries(g); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("already applying %s; use --continue, --skip or --abort", existing.Source)
+	}
+	if applying, _ := g.IsApplyingPatches(); applying {
+		return nil, fmt.Errorf("a git am session is in progress; finish it with 'git am --continue' or 'git am --abort'")
+	}
+	if clean, err := g.IsClean(); err != nil {
+		return nil, err
+	} else if !clean {
+		return nil, exitcode.Errorf(exitcode.DirtyTree, "commit or stash your changes before applying patches")
+	}
+
+	head, err := g.GetCommitHash("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	s := &MboxSeries{Source: source, OrigHead: strings.TrimSpace(head), Patches: patches}
+
+	dir, err := mboxDir(g)
+	if err != nil {
+		return nil, err
+	}
+	if !dryrun.Enabled() {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create patch queue: %w", err)
+		}
+	}
+	for i := range s.Patches {
+		s.Patches[i].File = filepath.Join(dir, fmt.Sprintf("%04d.patch", i+1))
+		if dryrun.Enabled() {
+			continue
+		}
+		if err := os.WriteFile(s.Patches[i].File, s.Patches[i].raw, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write patch queue: %w", err)
+		}
+	}
+	return s, saveMboxSeries(g, s)
+}
+
+// ApplyMboxSeries applies the remaining patches in order, calling applied
+// after each one. When a patch conflicts it returns ErrPatchConflict with the
+// series saved, ready for ContinueMboxSeries.
+func ApplyMboxSeries(g git.Service, s *MboxSeries, applied func(i int, p MboxPatch)) error {
+	for s.Next < len(s.Patches) {
+		p := s.Patches[s.Next]
+		if _, err := g.Run("am", "--3way", p.File); err != nil {
+			if applying, _ := g.IsApplyingPatches(); applying {
+				s.Stopped = true
+				if err := saveMboxSeries(g, s); err != nil {
+					return err
+				}
+				return fmt.Errorf("%w: %s", ErrPatchConflict, p.Subject)
+			}
+			return fmt.Errorf("failed to apply %q: %w", p.Subject, err)
+		}
+		s.Next++
+		if err := saveMboxSeries(g, s); err != nil {
+			return err
+		}
+		applied(s.Next-1, p)
+	}
+	return clearMboxSeries(g)
+}
+
+// ContinueMboxSeries commits the resolved patch and applies the rest
+func ContinueMboxSeries(g git.Service, s *MboxSeries, applied func(i int, p MboxPatch)) error {
+	if applying, _ := g.IsApplyingPatches(); applying {
+		if _, err := g.Run("am", "--continue"); err != nil {
+			return fmt.Errorf("failed to continue: %w", err)
+		}
+	}
+	// Also covers a stopped patch finished with git am --continue directly
+	if s.Stopped {
+		s.Next++
+		s.Stopped = false
+		if err := saveMboxSeries(g, s); err != nil {
+			return err
+		}
+		applied(s.Next-1, s.Patches[s.Next-1])
+	}
+	return ApplyMboxSeries(g, s, applied)
+}
+
+// SkipMboxPatch drops the patch that stopped and applies the rest
+func SkipMboxPatch(g git.Service, s *MboxSeries, applied func(i int, p MboxPatch)) error {
+	if applying, _ := g.IsApplyingPatches(); applying {
+		if _, err := g.Run("am", "--skip"); err != nil {
+			return fmt.Errorf("failed to skip: %w", err)
+		}
+	}
+	s.Next++
+	s.Stopped = false
+	if err := saveMboxSeries(g, s); err != nil {
+		return err
+	}
+	return ApplyMboxSeries(g, s, applied)
+}
+
+// AbortMboxSeries stops applying and moves the branch back to where it was
+// before the series, discarding the patches already applied
+func AbortMboxSeries(g git.Service, s *MboxSeries) error {
+	if applying, _ := g.IsApplyingPatches(); applying {
+		if _, err := g.Run("am", "--abort"); err != nil {
+			return fmt.Errorf("failed to abort: %w", err)
+		}
+	}
+	if _, err := g.Run("reset", "--keep", s.OrigHead); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", s.OrigHead[:7], err)
+	}
+	return clearMboxSeries(g)
+}
+
+// SummarizeMbox asks the AI for a short review-oriented summary of a series
+func SummarizeMbox(client *ai.Client, patches []MboxPatch) (string, error) {
+	var b strings.Builder
+	for i, p := range patches {
+		fmt.Fprintf(&b, "=== Patch %d/%d: %s (by %s)\n%s\n", i+1, len(patches), p.Subject, p.Author, p.raw)
+	}
+	series := b.String()
+	if len(series) > maxSeriesPromptSize {
+		series = series[:maxSeriesPromptSize] + "\n[truncated]"
+	}
+
+	system := `You review patch series sent to mailing lists. Summarize the series for a maintainer deciding whether to apply it: what it changes overall, what each patch does in one line, and anything that deserves a careful look (risky changes, missing tests, unrelated edits). Be brief and use plain text bullet points.`
+	return client.ForTask(ai.TaskReview).Complete(system, series)
+}
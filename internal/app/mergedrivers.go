@@ -0,0 +1,275 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeDriversFile is the per-repository opt-in file for lockfile merge drivers
+const mergeDriversFile = ".sage/mergedrivers.yaml"
+
+// Merge driver strategies
+const (
+	DriverUnion  = "union"  // Keep both sides of every conflict, then regenerate
+	DriverTheirs = "theirs" // Take the incoming side being synced in, then regenerate
+	DriverOurs   = "ours"   // Keep the branch's own side, then regenerate
+)
+
+// MergeDriver describes how to resolve conflicts for files matching Pattern
+type MergeDriver struct {
+	Pattern  string `yaml:"pattern"`
+	Strategy string `yaml:"strategy,omitempty"`
+	Command  string `yaml:"command,omitempty"` // Command that regenerates the file, e.g. "npm install"
+}
+
+type mergeDriversConfig struct {
+	Drivers []MergeDriver `yaml:"drivers"`
+}
+
+// builtinMergeDrivers are the defaults for well-known lockfiles. They are only
+// used for files that are listed in .sage/mergedrivers.yaml.
+var builtinMergeDrivers = map[string]MergeDriver{
+	"go.sum":            {Strategy: DriverUnion, Command: "go mod tidy"},
+	"package-lock.json": {Strategy: DriverTheirs, Command: "npm install"},
+	"yarn.lock":         {Strategy: DriverTheirs, Command: "yarn install"},
+	"pnpm-lock.yaml":    {Strategy: DriverTheirs, Command: "pnpm install"},
+	"Cargo.lock":        {Strategy: DriverTheirs, Command: "cargo generate-lockfile"},
+	"Gemfile.lock":      {Strategy: DriverTheirs, Command: "bundle install"},
+	"poetry.lock":       {Strategy: DriverTheirs, Command: "poetry lock --no-update"},
+}
+
+// LoadMergeDrivers reads .sage/mergedrivers.yaml from the repository root.
+// It returns nil if the file does not exist.
+func LoadMergeDrivers(g git.Service) ([]MergeDriver, error) {
+	root, err := g.Run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repository root: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(root), mergeDriversFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", mergeDriversFile, err)
+	}
+
+	var cfg mergeDriversConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", mergeDriversFile, err)
+	}
+
+	for i, d := range cfg.Drivers {
+		if d.Pattern == "" {
+			return nil, fmt.Errorf("%s: driver %d is missing a pattern", mergeDriversFile, i+1)
+		}
+		// Fill in anything left out from the built-in defaults
+		if def, ok := builtinMergeDrivers[filepath.Base(d.Pattern)]; ok {
+			if d.Strategy == "" {
+				d.Strategy = def.Strategy
+			}
+			if d.Command == "" {
+				d.Command = def.Command
+			}
+		}
+		switch d.Strategy {
+		case DriverUnion, DriverTheirs, DriverOurs:
+		case "":
+			return nil, fmt.Errorf("%s: no strategy for %q", mergeDriversFile, d.Pattern)
+		default:
+			return nil, fmt.Errorf("%s: unknown strategy %q for %q", mergeDriversFile, d.Strategy, d.Pattern)
+		}
+		cfg.Drivers[i] = d
+	}
+
+	return cfg.Drivers, nil
+}
+
+// matchMergeDriver returns the first driver whose pattern matches path.
+// Patterns without a slash match the file name in any directory.
+func matchMergeDriver(drivers []MergeDriver, path string) *MergeDriver {
+	for i, d := range drivers {
+		pattern := strings.TrimPrefix(d.Pattern, "**/")
+		target := path
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(path)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return &drivers[i]
+		}
+	}
+	return nil
+}
+
+// unionResolve removes conflict markers and keeps both sides of each conflict,
+// dropping the base section of diff3-style conflicts and duplicate lines.
+func unionResolve(content string) string {
+	var out []string
+	seen := map[string]bool{}
+	inConflict, inBase := false, false
+
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<< "), line == "<<<<<<<":
+			inConflict, inBase = true, false
+			continue
+		case inConflict && strings.HasPrefix(line, "|||||||"):
+			inBase = true
+			continue
+		case inConflict && line == "=======":
+			inBase = false
+			continue
+		case inConflict && (strings.HasPrefix(line, ">>>>>>> ") || line == ">>>>>>>"):
+			inConflict, inBase = false, false
+			continue
+		}
+		if inBase {
+			continue
+		}
+		if inConflict {
+			if seen[line] {
+				continue
+			}
+			seen[line] = true
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// applyMergeDriver resolves a single conflicted file and stages it
+func applyMergeDriver(g git.Service, root, path string, d *MergeDriver) error {
+	switch d.Strategy {
+	case DriverTheirs, DriverOurs:
+		// A rebase replays the branch's commits onto the incoming ones, so
+		// there git's --ours is the incoming side and --theirs the branch's
+		side := d.Strategy
+		if rebasing, _ := g.IsRebasing(); rebasing {
+			if side == DriverTheirs {
+				side = DriverOurs
+			} else {
+				side = DriverTheirs
+			}
+		}
+		if _, err := g.Run("checkout", "--"+side, "--", path); err != nil {
+			return fmt.Errorf("failed to take %s side of %s: %w", d.Strategy, path, err)
+		}
+	case DriverUnion:
+		full := filepath.Join(root, path)
+		data, err := os.ReadFile(full)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := os.WriteFile(full, []byte(unionResolve(string(data))), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	if d.Command != "" {
+		fields := strings.Fields(d.Command)
+		cmd, err := git.SetupSecureCommand(fields[0], fields[1:]...)
+		if err != nil {
+			return fmt.Errorf("invalid regenerate command %q: %w", d.Command, err)
+		}
+		cmd.Dir = filepath.Join(root, filepath.Dir(path))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("'%s' failed for %s: %v\n%s", d.Command, path, err, out)
+		}
+	}
+
+	if _, err := g.Run("add", "--", path); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", path, err)
+	}
+	return nil
+}
+
+// autoResolveConflicts tries to resolve an in-progress merge or rebase using the
+// configured lockfile merge drivers. Conflicts outside a non-empty scope are
+// settled with the parent's version first, if the scope allows it, and
+// otherwise stop the sync. It only succeeds when every other
+// conflicted file has a driver; otherwise the conflicts are left for the
+// user. It returns true if the merge/rebase was completed.
+func autoResolveConflicts(g git.Service, scope *SyncScope) bool {
+	drivers, err := LoadMergeDrivers(g)
+	if err != nil {
+		ui.Warning(err.Error())
+		return false
+	}
+	if len(drivers) == 0 && scope.Empty() {
+		return false
+	}
+
+	root, err := g.Run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	root = strings.TrimSpace(root)
+
+	// A rebase can stop on several commits in turn, so keep going while each
+	// stop only has lockfile conflicts.
+	for i := 0; i < 100; i++ {
+		out, err := g.ListConflictedFiles()
+		if err != nil || strings.TrimSpace(out) == "" {
+			return false
+		}
+		var files, outside []string
+		for _, f := range strings.Split(strings.TrimSpace(out), "\n") {
+			if scope.outside(f) {
+				outside = append(outside, f)
+			} else {
+				files = append(files, f)
+			}
+		}
+		if len(outside) > 0 && !scope.allowTakeIncoming(outside) {
+			return false
+		}
+		for _, f := range outside {
+			if err := takeIncoming(g, f); err != nil {
+				ui.Warning(err.Error())
+				return false
+			}
+			if !slices.Contains(scope.Taken, f) {
+				scope.Taken = append(scope.Taken, f)
+			}
+		}
+
+		for _, f := range files {
+			if matchMergeDriver(drivers, f) == nil {
+				return false
+			}
+		}
+		for _, f := range files {
+			d := matchMergeDriver(drivers, f)
+			ui.Info(fmt.Sprintf("Resolving %s with the %s merge driver", f, d.Strategy))
+			if err := applyMergeDriver(g, root, f, d); err != nil {
+				ui.Warning(err.Error())
+				return false
+			}
+		}
+
+		if rebasing, _ := g.IsRebasing(); rebasing {
+			if err := g.RebaseContinue(); err != nil {
+				if still, _ := g.IsRebasing(); still {
+					continue
+				}
+				return false
+			}
+			if still, _ := g.IsRebasing(); still {
+				continue
+			}
+			return true
+		}
+		if merging, _ := g.IsMerging(); merging {
+			return g.MergeContinue() == nil
+		}
+		return false
+	}
+	return false
+}
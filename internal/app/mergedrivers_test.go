@@ -0,0 +1,39 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// TestMergeDriverTheirsTakesIncoming checks that "theirs" means the side
+// being synced in whether the sync merges or rebases
+func TestMergeDriverTheirsTakesIncoming(t *testing.T) {
+	for _, op := range []string{"merge", "rebase"} {
+		t.Run(op, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("deps.lock", "base\n", "Add lockfile")
+			r.git("checkout", "-b", "feature")
+			r.commit("deps.lock", "feature\n", "Bump on feature")
+			r.git("checkout", "main")
+			r.commit("deps.lock", "main\n", "Bump on main")
+			r.git("checkout", "feature")
+			if err := os.Mkdir(".sage", 0755); err != nil {
+				t.Fatal(err)
+			}
+			r.write(".sage/mergedrivers.yaml", "drivers:\n  - pattern: deps.lock\n    strategy: theirs\n")
+
+			if err := exec.Command("git", op, "main").Run(); err == nil {
+				t.Fatalf("expected the %s to conflict", op)
+			}
+			if !autoResolveConflicts(git.NewShellGit(), nil) {
+				t.Fatal("expected the driver to resolve the conflict")
+			}
+			if got := r.read("deps.lock"); got != "main\n" {
+				t.Errorf("deps.lock = %q, want main's version", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchPathspec reports whether path matches a user-supplied pattern.
+// Supported forms:
+//   - "pkg/..."       everything under pkg/ (Go-style)
+//   - "pkg/"          everything under pkg/
+//   - "**/*.go"       glob where ** spans directories and * stays within one
+//   - "cmd/main.go"   exact path, or a directory prefix
+func MatchPathspec(pattern, path string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+	path = strings.TrimPrefix(path, "./")
+	if pattern == "" {
+		return false
+	}
+	if pattern == "." || pattern == "..." {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "/...") {
+		dir := strings.TrimSuffix(pattern, "/...")
+		return path == dir || strings.HasPrefix(path, dir+"/")
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		dir := strings.TrimSuffix(pattern, "/")
+		return path == dir || strings.HasPrefix(path, dir+"/")
+	}
+
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(path)
+}
+
+// FilterPathspecs returns the paths that match at least one of the patterns
+func FilterPathspecs(patterns, paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		for _, pat := range patterns {
+			if MatchPathspec(pat, p) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// globToRegexp converts a glob pattern to an anchored regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" matches zero or more directories
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(glob[i : i+end+1])
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
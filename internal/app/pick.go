@@ -0,0 +1,241 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// ErrNoPickInProgress is returned by --continue and --abort with no stopped
+// cherry-pick or revert to finish
+var ErrNoPickInProgress = errors.New("no cherry-pick or revert in progress")
+
+// PickError is a cherry-pick or revert stopped on a commit that needs the
+// user, usually for conflicts
+type PickError struct {
+	Op        string // git.OpCherryPick or git.OpRevert
+	Conflicts []string
+}
+
+func (e *PickError) Error() string {
+	cmd := "sage pick"
+	if e.Op == git.OpRevert {
+		cmd = "sage revert"
+	}
+	if len(e.Conflicts) == 0 {
+		// Git stops on commits that would change nothing, too
+		return fmt.Sprintf(`The %s stopped on a commit that changes nothing here; it may already be applied.
+
+To leave it out: 'git %s --skip'
+To start over: '%s --abort'`, e.Op, e.Op, cmd)
+	}
+	return fmt.Sprintf(`Conflicts found in these files:
+%s
+
+To resolve:
+1. Run 'sage resolve' for interactive conflict resolution
+2. Or resolve conflicts manually
+3. Run '%s --continue'
+
+To start over: '%s --abort'`, strings.Join(e.Conflicts, "\n"), cmd, cmd)
+}
+
+// ExitCode makes a stopped pick exit with exitcode.Conflict
+func (e *PickError) ExitCode() int {
+	return exitcode.Conflict
+}
+
+// PickResult is what a cherry-pick or revert did
+type PickResult struct {
+	Op      string
+	Commits []string // the commits picked or reverted, in the order applied
+}
+
+// PickCommits cherry-picks the commits revs name onto the current branch,
+// oldest first. A rev may be a range such as main~3..main. Uncommitted
+// changes are stashed for the pick and restored after it, and on a conflict
+// it stops for '--continue' or '--abort'.
+func PickCommits(g git.Service, revs []string) (*PickResult, error) {
+	return runSequence(g, git.OpCherryPick, revs)
+}
+
+// RevertCommits commits the inverse of the commits revs name, newest first
+// so later changes are undone before the ones they build on
+func RevertCommits(g git.Service, revs []string) (*PickResult, error) {
+	return runSequence(g, git.OpRevert, revs)
+}
+
+func runSequence(g git.Service, op string, revs []string) (*PickResult, error) {
+	if err := checkNoOperation(g); err != nil {
+		return nil, err
+	}
+	commits, err := expandPickRevs(g, op, revs)
+	if err != nil {
+		return nil, err
+	}
+
+	command := "sage pick"
+	if op == git.OpRevert {
+		command = "sage revert"
+	}
+	JournalSnapshot(g, command+" "+strings.Join(revs, " "))
+
+	clean, err := g.IsClean()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check working directory: %w", err)
+	}
+	if !clean && !dryrun.Enabled() {
+		if err := stashForPick(g); err != nil {
+			return nil, err
+		}
+	}
+
+	run := g.CherryPick
+	if op == git.OpRevert {
+		run = g.Revert
+	}
+	if err := run(commits...); err != nil {
+		if stopped, _ := g.SequencerInProgress(); stopped != "" {
+			return nil, pickStopped(g, stopped)
+		}
+		restorePickStash(g)
+		return nil, fmt.Errorf("failed to %s: %w", op, err)
+	}
+	return &PickResult{Op: op, Commits: commits}, restorePickStash(g)
+}
+
+// checkNoOperation refuses to start while git is in the middle of something
+func checkNoOperation(g git.Service) error {
+	if op, _ := g.SequencerInProgress(); op != "" {
+		return fmt.Errorf("a %s is already in progress; finish it with --continue or --abort", op)
+	}
+	if merging, _ := g.IsMerging(); merging {
+		return fmt.Errorf("a merge is in progress; finish it first")
+	}
+	if rebasing, _ := g.IsRebasing(); rebasing {
+		return fmt.Errorf("a rebase is in progress; finish it first")
+	}
+	return nil
+}
+
+// expandPickRevs turns revs into commit hashes in the order op applies them.
+// Ranges are walked oldest first for a cherry-pick and newest first for a
+// revert, as git itself would.
+func expandPickRevs(g git.Service, op string, revs []string) ([]string, error) {
+	var commits []string
+	for _, rev := range revs {
+		if !strings.Contains(rev, "..") {
+			hash, err := g.Run("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+			if err != nil || strings.TrimSpace(hash) == "" {
+				return nil, fmt.Errorf("%s is not a commit", rev)
+			}
+			commits = append(commits, strings.TrimSpace(hash))
+			continue
+		}
+		args := []string{"rev-list"}
+		if op == git.OpCherryPick {
+			args = append(args, "--reverse")
+		}
+		out, err := g.Run(append(args, rev)...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %s: %w", rev, err)
+		}
+		hashes := strings.Fields(out)
+		if len(hashes) == 0 {
+			return nil, fmt.Errorf("no commits in %s", rev)
+		}
+		commits = append(commits, hashes...)
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commits given")
+	}
+	return commits, nil
+}
+
+// ContinuePick finishes a stopped cherry-pick or revert once its conflicts
+// are resolved, and restores the changes stashed for it
+func ContinuePick(g git.Service) (string, error) {
+	op, err := g.SequencerInProgress()
+	if err != nil {
+		return "", err
+	}
+	if op == "" {
+		return "", ErrNoPickInProgress
+	}
+	if err := g.SequencerContinue(op); err != nil {
+		if stopped, _ := g.SequencerInProgress(); stopped != "" {
+			return "", pickStopped(g, stopped)
+		}
+		return "", fmt.Errorf("failed to continue the %s: %w", op, err)
+	}
+	return op, restorePickStash(g)
+}
+
+// AbortPick stops a cherry-pick or revert, returning the branch to where it
+// started, and restores the changes stashed for it
+func AbortPick(g git.Service) (string, error) {
+	op, err := g.SequencerInProgress()
+	if err != nil {
+		return "", err
+	}
+	if op == "" {
+		return "", ErrNoPickInProgress
+	}
+	if err := g.SequencerAbort(op); err != nil {
+		return "", fmt.Errorf("failed to abort the %s: %w", op, err)
+	}
+	return op, restorePickStash(g)
+}
+
+// pickStopped is the error for a pick waiting on the user
+func pickStopped(g git.Service, op string) error {
+	perr := &PickError{Op: op}
+	if out, err := g.Run("diff", "--name-only", "--diff-filter=U"); err == nil {
+		perr.Conflicts = strings.Fields(out)
+	}
+	if rec, _ := loadStashRecord(g, pickStashFile); rec != nil {
+		ui.Info("Your changes stay stashed until --continue or --abort finishes")
+	}
+	return perr
+}
+
+// stashForPick stashes uncommitted changes and records the stash, so they
+// come back once the pick is done, however many --continues that takes
+func stashForPick(g git.Service) error {
+	branch, _ := g.CurrentBranch()
+	rec := syncStash{Message: fmt.Sprintf("sage-pick-%d", time.Now().Unix()), Branch: branch}
+	if err := g.Stash(rec.Message); err != nil {
+		return fmt.Errorf("failed to stash changes: %w", err)
+	}
+	if err := saveStashRecord(g, pickStashFile, rec); err != nil {
+		ui.Warning(fmt.Sprintf("Couldn't record the stash: %v", err))
+	}
+	ui.Info("Stashed your uncommitted changes")
+	return nil
+}
+
+// restorePickStash pops the stash made for the pick, if it's still the
+// latest one
+func restorePickStash(g git.Service) error {
+	rec, err := loadStashRecord(g, pickStashFile)
+	if err != nil || rec == nil {
+		return err
+	}
+	defer clearStashRecord(g, pickStashFile)
+
+	stashes, err := g.StashList()
+	if err != nil || len(stashes) == 0 || !strings.HasSuffix(stashes[0], ": "+rec.Message) {
+		return fmt.Errorf("the changes stashed before the pick (%s) are no longer the latest stash; find them with 'git stash list' and restore them with 'git stash pop <stash>'", rec.Message)
+	}
+	if err := g.StashPop(); err != nil {
+		return fmt.Errorf("failed to restore your changes: %w\nThey are stashed as %s; run 'git stash pop' once the conflicts are dealt with", err, rec.Message)
+	}
+	ui.Info("Restored your uncommitted changes")
+	return nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestPickRangeWithDirtyTree(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("a.txt", "a\n", "Add a")
+	r.commit("b.txt", "b\n", "Add b")
+	r.commit("c.txt", "c\n", "Add c")
+	r.git("checkout", "main")
+	r.write("notes.txt", "work in progress\n")
+	r.git("add", "notes.txt")
+
+	res, err := PickCommits(git.NewShellGit(), []string{"feature~2..feature~1", "feature"})
+	if err != nil {
+		t.Fatalf("PickCommits: %v", err)
+	}
+	if len(res.Commits) != 2 {
+		t.Errorf("picked %d commits, want 2", len(res.Commits))
+	}
+	if got := r.git("log", "--format=%s", "-n", "3"); got != "Add c\nAdd b\nInitial commit" {
+		t.Errorf("log:\n%s", got)
+	}
+	if got := r.git("status", "--porcelain"); got != "A  notes.txt" {
+		t.Errorf("status = %q, want the staged change restored", got)
+	}
+	if got := r.git("stash", "list"); got != "" {
+		t.Errorf("stash list:\n%s", got)
+	}
+}
+
+func TestRevertConflictContinue(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("README.md", "one\n", "One")
+	r.commit("README.md", "two\n", "Two")
+	first := r.rev("HEAD~1")
+	g := git.NewShellGit()
+
+	_, err := RevertCommits(g, []string{first})
+	var perr *PickError
+	if !errors.As(err, &perr) || perr.Op != git.OpRevert || len(perr.Conflicts) != 1 {
+		t.Fatalf("err = %v, want a revert stopped on README.md", err)
+	}
+	if code := exitcode.Of(err); code != exitcode.Conflict {
+		t.Errorf("exit code = %d, want %d", code, exitcode.Conflict)
+	}
+	if _, err := PickCommits(g, []string{"HEAD"}); err == nil {
+		t.Error("a pick started while the revert was stopped")
+	}
+
+	r.write("README.md", "resolved\n")
+	r.git("add", "README.md")
+	op, err := ContinuePick(g)
+	if err != nil || op != git.OpRevert {
+		t.Fatalf("ContinuePick = %q, %v", op, err)
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != `Revert "One"` {
+		t.Errorf("subject = %q", got)
+	}
+	if _, err := ContinuePick(g); !errors.Is(err, ErrNoPickInProgress) {
+		t.Errorf("ContinuePick with nothing stopped: %v", err)
+	}
+	r.assertClean()
+}
+
+func TestPickAbortRestoresChanges(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("README.md", "from feature\n", "Feature readme")
+	r.git("checkout", "main")
+	r.commit("README.md", "from main\n", "Main readme")
+	before := r.rev("HEAD")
+	r.write("scratch.txt", "keep me\n")
+	r.git("add", "scratch.txt")
+
+	g := git.NewShellGit()
+	if _, err := PickCommits(g, []string{"feature~0"}); err == nil {
+		t.Fatal("the pick should stop on the conflict")
+	}
+	if got := r.git("stash", "list"); got == "" {
+		t.Error("the changes should stay stashed while the pick is stopped")
+	}
+	if op, err := AbortPick(g); err != nil || op != git.OpCherryPick {
+		t.Fatalf("AbortPick = %q, %v", op, err)
+	}
+	if r.rev("HEAD") != before {
+		t.Error("abort didn't put main back")
+	}
+	if got := r.read("scratch.txt"); got != "keep me\n" {
+		t.Errorf("scratch.txt = %q", got)
+	}
+}
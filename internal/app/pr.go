@@ -2,9 +2,12 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/issues"
+	"github.com/crazywolf132/sage/internal/ui"
 )
 
 // Create
@@ -18,6 +21,8 @@ type CreatePROpts struct {
 	Reviewers   []string
 	Labels      []string
 	UseTemplate bool
+	AllowWip    bool
+	Tracker     issues.Tracker // links the branch's ticket from the description
 }
 
 // CreatePullRequest orchestrates the entire creation process
@@ -30,17 +35,29 @@ func CreatePullRequest(g git.Service, ghc gh.Client, opts CreatePROpts) (*gh.Pul
 	if err != nil {
 		return nil, err
 	}
-	// push local changes first
-	if err := g.Push(curBranch, false); err != nil {
+	// Team rules for this kind of branch add to whatever was asked for
+	if d, ok, err := BranchPRDefaults(curBranch); err != nil {
 		return nil, err
+	} else if ok {
+		opts.Labels = mergeUnique(opts.Labels, d.Labels)
+		opts.Reviewers = mergeUnique(opts.Reviewers, d.Reviewers)
 	}
 	if opts.Base == "" {
-		def, err := g.DefaultBranch()
+		def, err := baseOrMain(g, curBranch)
 		if err != nil {
-			def = "main"
+			return nil, err
 		}
 		opts.Base = def
 	}
+	if !opts.AllowWip {
+		if err := ensureNoWipCommits(g, "origin/"+opts.Base); err != nil {
+			return nil, err
+		}
+	}
+	// push local changes first
+	if err := g.Push(curBranch, false); err != nil {
+		return nil, err
+	}
 
 	// If user wants to use GH PR template
 	if opts.UseTemplate && opts.Body == "" {
@@ -50,11 +67,24 @@ func CreatePullRequest(g git.Service, ghc gh.Client, opts CreatePROpts) (*gh.Pul
 		}
 	}
 
+	// Changelog entries added on this branch become part of the description;
+	// repositories that keep entries in .changes/ get a reminder when there are none
+	if frags, ok, err := BranchFragments(g, "origin/"+opts.Base); err == nil && ok {
+		if len(frags) == 0 {
+			ui.Warning("No changelog entry on this branch; add one with 'sage changelog add'")
+		} else if !strings.Contains(opts.Body, "## Changelog") {
+			opts.Body = strings.TrimRight(opts.Body, "\n") + "\n\n## Changelog\n\n" + RenderFragments(frags, 3)
+		}
+	}
+
+	opts.Body = ticketSection(opts.Body, branchTicket(opts.Tracker, curBranch))
+
 	// create the PR
 	pr, err := ghc.CreatePR(opts.Title, opts.Body, curBranch, opts.Base, opts.Draft)
 	if err != nil {
 		return nil, err
 	}
+	_ = CachePR(g, curBranch, pr)
 
 	// If we want to set labels and reviewers, some of these might require separate API calls:
 	if len(opts.Labels) > 0 {
@@ -81,11 +111,66 @@ func MergePR(ghc gh.Client, prNum int, method string) error {
 	return ghc.MergePR(prNum, method)
 }
 
+// MergeSquash squash-merges a PR with the given commit message
+func MergeSquash(ghc gh.Client, prNum int, msg SquashMessage) error {
+	return ghc.MergePRWithMessage(prNum, "squash", msg.Title, msg.Body)
+}
+
 // Close
 func ClosePR(ghc gh.Client, prNum int) error {
 	return ghc.ClosePR(prNum)
 }
 
+// Reopen
+func ReopenPR(ghc gh.Client, prNum int) error {
+	return ghc.ReopenPR(prNum)
+}
+
+// ClosedPRForBranch returns the PR from branch most recently closed without
+// being merged, or nil when there is none
+func ClosedPRForBranch(ghc gh.Client, branch string) (*gh.PullRequest, error) {
+	prs, err := ghc.ClosedPRsForBranch(branch)
+	if err != nil || len(prs) == 0 {
+		return nil, err
+	}
+	return &prs[0], nil
+}
+
+// ReopenBranchPR pushes the current branch and reopens its closed PR, for
+// when that's better than creating a duplicate
+func ReopenBranchPR(g git.Service, ghc gh.Client, pr *gh.PullRequest) error {
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	// The head branch has to exist again before GitHub will reopen the PR
+	if err := g.Push(branch, false); err != nil {
+		return err
+	}
+	if err := ghc.ReopenPR(pr.Number); err != nil {
+		return err
+	}
+	pr.State = "open"
+	_ = CachePR(g, branch, pr)
+	return nil
+}
+
+// MinePRs keeps the PRs opened by login
+func MinePRs(prs []gh.PullRequest, login string) []gh.PullRequest {
+	var mine []gh.PullRequest
+	for _, pr := range prs {
+		if strings.EqualFold(pr.User.Login, login) {
+			mine = append(mine, pr)
+		}
+	}
+	return mine
+}
+
+// Approve
+func ApprovePR(ghc gh.Client, prNum int) error {
+	return ghc.ApprovePR(prNum)
+}
+
 // Checkout
 func CheckoutPR(g git.Service, ghc gh.Client, prNum int) (string, error) {
 	return ghc.CheckoutPR(prNum)
@@ -96,6 +181,82 @@ func GetPRDetails(ghc gh.Client, prNum int) (*gh.PullRequest, error) {
 	return ghc.GetPRDetails(prNum)
 }
 
+// Levels for 'sage pr status --fail-on'
+const (
+	FailOnFailure = "failure" // a check failed or a reviewer requested changes
+	FailOnPending = "pending" // as failure, or a check is still running
+)
+
+// PRFailOnReasons lists why pr has reached level: failed checks and
+// outstanding change requests, plus unfinished checks for FailOnPending
+func PRFailOnReasons(ghc gh.Client, pr *gh.PullRequest, level string) ([]string, error) {
+	runs, err := ghc.ListPRCheckRuns(pr.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checks: %w", err)
+	}
+
+	var reasons []string
+	for _, run := range runs {
+		switch {
+		case run.Failed():
+			reasons = append(reasons, fmt.Sprintf("check %s: %s", run.Name, run.Conclusion))
+		case level == FailOnPending && run.Status != "completed":
+			reasons = append(reasons, fmt.Sprintf("check %s: %s", run.Name, strings.ReplaceAll(run.Status, "_", " ")))
+		}
+	}
+
+	for _, r := range LatestReviews(pr.Reviews) {
+		if r.State == "CHANGES_REQUESTED" {
+			reasons = append(reasons, fmt.Sprintf("@%s requested changes", r.User.Login))
+		}
+	}
+	return reasons, nil
+}
+
+// LatestReviews keeps each reviewer's latest decision (approved, changes
+// requested or dismissed), in the order they first reviewed; comments
+// decide nothing
+func LatestReviews(reviews []gh.Review) []gh.Review {
+	latest := map[string]int{}
+	var out []gh.Review
+	for _, r := range reviews {
+		if r.State != "APPROVED" && r.State != "CHANGES_REQUESTED" && r.State != "DISMISSED" {
+			continue
+		}
+		if i, seen := latest[r.User.Login]; seen {
+			out[i] = r
+			continue
+		}
+		latest[r.User.Login] = len(out)
+		out = append(out, r)
+	}
+	return out
+}
+
+// PRView bundles everything 'sage pr view' renders
+type PRView struct {
+	PR      *gh.PullRequest
+	Files   []gh.PRFile
+	Threads []gh.UnresolvedThread
+}
+
+// GetPRView fetches a PR with its changed files and open comment threads
+func GetPRView(ghc gh.Client, prNum int) (*PRView, error) {
+	pr, err := ghc.GetPRDetails(prNum)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ghc.ListPRFiles(prNum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PR files: %w", err)
+	}
+	threads, err := ghc.ListPRUnresolvedThreads(prNum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comment threads: %w", err)
+	}
+	return &PRView{PR: pr, Files: files, Threads: threads}, nil
+}
+
 // Todos
 func ListUnresolvedThreads(ghc gh.Client, prNum int) ([]gh.UnresolvedThread, error) {
 	return ghc.ListPRUnresolvedThreads(prNum)
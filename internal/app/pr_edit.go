@@ -0,0 +1,171 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// PREdit is a set of changes to make to a PR; nil and empty fields are
+// left alone
+type PREdit struct {
+	Title *string
+	Body  *string
+	Base  string
+
+	AddLabels       []string
+	RemoveLabels    []string
+	AddReviewers    []string
+	RemoveReviewers []string
+}
+
+// Empty reports whether the edit changes nothing
+func (e PREdit) Empty() bool {
+	return e.Title == nil && e.Body == nil && e.Base == "" &&
+		len(e.AddLabels) == 0 && len(e.RemoveLabels) == 0 &&
+		len(e.AddReviewers) == 0 && len(e.RemoveReviewers) == 0
+}
+
+// EditPR applies edit to the PR. The title, body and base are only sent
+// when one of them changes, and labels the PR doesn't have are skipped
+// rather than failing the removal.
+func EditPR(ghc gh.Client, num int, edit PREdit) error {
+	pr, err := ghc.GetPRDetails(num)
+	if err != nil {
+		return err
+	}
+
+	update := *pr
+	if edit.Title != nil {
+		if strings.TrimSpace(*edit.Title) == "" {
+			return fmt.Errorf("a PR's title can't be empty")
+		}
+		update.Title = *edit.Title
+	}
+	if edit.Body != nil {
+		update.Body = *edit.Body
+	}
+	if edit.Base != "" {
+		update.Base.Ref = edit.Base
+	}
+	if update.Title != pr.Title || update.Body != pr.Body || update.Base.Ref != pr.Base.Ref {
+		if err := ghc.UpdatePR(num, &update); err != nil {
+			return fmt.Errorf("failed to update PR #%d: %w", num, err)
+		}
+	}
+
+	if len(edit.AddLabels) > 0 {
+		if err := ghc.AddLabels(num, edit.AddLabels); err != nil {
+			return fmt.Errorf("failed to add labels: %w", err)
+		}
+	}
+	for _, l := range edit.RemoveLabels {
+		if !slices.ContainsFunc(pr.Labels, func(have gh.Label) bool { return strings.EqualFold(have.Name, l) }) {
+			continue
+		}
+		if err := ghc.RemoveLabel(num, l); err != nil {
+			return fmt.Errorf("failed to remove label %s: %w", l, err)
+		}
+	}
+
+	if len(edit.AddReviewers) > 0 {
+		if err := ghc.RequestReviewers(num, edit.AddReviewers); err != nil {
+			return fmt.Errorf("failed to request reviewers: %w", err)
+		}
+	}
+	if len(edit.RemoveReviewers) > 0 {
+		if err := ghc.RemoveReviewers(num, edit.RemoveReviewers); err != nil {
+			return fmt.Errorf("failed to remove reviewers: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetPRDraft converts an open PR to a draft or marks it ready for review,
+// reporting whether it wasn't in that state already
+func SetPRDraft(ghc gh.Client, num int, draft bool) (bool, error) {
+	pr, err := ghc.GetPRDetails(num)
+	if err != nil {
+		return false, err
+	}
+	if pr.State != "open" {
+		return false, fmt.Errorf("PR #%d is %s", num, pr.State)
+	}
+	if pr.Draft == draft {
+		return false, nil
+	}
+	if err := ghc.SetDraft(num, draft); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ComposePRInEditor opens editor on the PR's title and body, the title on
+// the first line, and returns them as edited. Markdown headings start with
+// '#', so instead of comment lines the instructions go below a scissors line.
+func ComposePRInEditor(editor, title, body string) (string, string, error) {
+	f, err := os.CreateTemp("", "PR_EDITMSG-*.md")
+	if err != nil {
+		return "", "", err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	_, err = f.WriteString(buildPREditBuffer(title, body))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("editor exited with an error: %w", err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	title, body = parsePREditBuffer(string(edited))
+	if title == "" {
+		return "", "", fmt.Errorf("aborting edit due to an empty title")
+	}
+	return title, body, nil
+}
+
+// buildPREditBuffer lays out the title, a blank line, then the body
+func buildPREditBuffer(title, body string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(title))
+	b.WriteString("\n\n")
+	if body = strings.TrimSpace(body); body != "" {
+		b.WriteString(body)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(scissorsLine + "\n")
+	b.WriteString("# The first line is the PR's title and the rest its description.\n")
+	b.WriteString("# Everything from the line above down is ignored, and an empty\n")
+	b.WriteString("# title aborts the edit.\n")
+	return b.String()
+}
+
+// parsePREditBuffer splits an edited buffer into the title and body
+func parsePREditBuffer(buf string) (string, string) {
+	if i := strings.Index(buf, scissorsLine); i >= 0 {
+		buf = buf[:i]
+	}
+	buf = strings.TrimSpace(strings.ReplaceAll(buf, "\r\n", "\n"))
+	title, body, _ := strings.Cut(buf, "\n")
+	return strings.TrimSpace(title), strings.TrimSpace(body)
+}
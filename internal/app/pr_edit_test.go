@@ -0,0 +1,89 @@
+package app
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// fakeEditPR records the edits made to one PR
+type fakeEditPR struct {
+	gh.Client
+	pr      gh.PullRequest
+	updated *gh.PullRequest
+	added   []string
+	removed []string
+	drafted []bool
+}
+
+func (f *fakeEditPR) GetPRDetails(int) (*gh.PullRequest, error) { pr := f.pr; return &pr, nil }
+func (f *fakeEditPR) UpdatePR(_ int, pr *gh.PullRequest) error  { f.updated = pr; return nil }
+func (f *fakeEditPR) AddLabels(_ int, l []string) error         { f.added = l; return nil }
+func (f *fakeEditPR) RemoveLabel(_ int, l string) error         { f.removed = append(f.removed, l); return nil }
+func (f *fakeEditPR) SetDraft(_ int, d bool) error              { f.drafted = append(f.drafted, d); return nil }
+
+func TestEditPR(t *testing.T) {
+	pr := gh.PullRequest{Title: "Old", Body: "body", State: "open", Labels: []gh.Label{{Name: "bug"}}}
+	pr.Base.Ref = "main"
+
+	// Labels alone don't resend the title and body
+	f := &fakeEditPR{pr: pr}
+	if err := EditPR(f, 1, PREdit{AddLabels: []string{"ui"}, RemoveLabels: []string{"Bug", "wontfix"}}); err != nil {
+		t.Fatal(err)
+	}
+	if f.updated != nil {
+		t.Errorf("expected no update, got %+v", f.updated)
+	}
+	if !slices.Equal(f.added, []string{"ui"}) || !slices.Equal(f.removed, []string{"Bug"}) {
+		t.Errorf("added %v and removed %v; want [ui] and [Bug]", f.added, f.removed)
+	}
+
+	title := "New"
+	f = &fakeEditPR{pr: pr}
+	if err := EditPR(f, 1, PREdit{Title: &title, Base: "develop"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.updated == nil || f.updated.Title != "New" || f.updated.Body != "body" || f.updated.Base.Ref != "develop" {
+		t.Errorf("got update %+v; want the new title and base with the old body", f.updated)
+	}
+
+	empty := " "
+	if err := EditPR(&fakeEditPR{pr: pr}, 1, PREdit{Title: &empty}); err == nil {
+		t.Error("expected an empty title to be refused")
+	}
+	if !(PREdit{}).Empty() || (PREdit{Base: "main"}).Empty() {
+		t.Error("Empty() is wrong")
+	}
+}
+
+func TestSetPRDraft(t *testing.T) {
+	f := &fakeEditPR{pr: gh.PullRequest{State: "open", Draft: true}}
+	if changed, err := SetPRDraft(f, 1, true); err != nil || changed {
+		t.Errorf("already a draft: got %v, %v", changed, err)
+	}
+	if changed, err := SetPRDraft(f, 1, false); err != nil || !changed {
+		t.Errorf("marking ready: got %v, %v", changed, err)
+	}
+	if !slices.Equal(f.drafted, []bool{false}) {
+		t.Errorf("SetDraft calls = %v, want [false]", f.drafted)
+	}
+
+	f = &fakeEditPR{pr: gh.PullRequest{State: "closed"}}
+	if _, err := SetPRDraft(f, 1, true); err == nil {
+		t.Error("expected a closed PR to be refused")
+	}
+}
+
+func TestPREditBuffer(t *testing.T) {
+	buf := buildPREditBuffer("Add login", "## Summary\n\nAdds login.")
+	title, body := parsePREditBuffer(buf)
+	if title != "Add login" || body != "## Summary\n\nAdds login." {
+		t.Errorf("round trip gave %q, %q", title, body)
+	}
+
+	title, body = parsePREditBuffer("Only a title\n")
+	if title != "Only a title" || body != "" {
+		t.Errorf("got %q, %q; want just the title", title, body)
+	}
+}
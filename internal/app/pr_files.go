@@ -0,0 +1,118 @@
+package app
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// FileTreeRow is one line of a PR's changed-file tree: a directory with the
+// totals of everything below it, or a file
+type FileTreeRow struct {
+	Name      string // path segment(s) shown on this row
+	Prefix    string // tree drawing, e.g. "│   ├── "
+	Additions int
+	Deletions int
+	File      *gh.PRFile // nil for directories
+}
+
+type fileTreeNode struct {
+	name     string
+	children map[string]*fileTreeNode
+	file     *gh.PRFile
+	adds     int
+	dels     int
+}
+
+// BuildFileTree arranges changed files into a directory tree, directories
+// first and each level sorted by name. Directories with a single child
+// directory are merged ("internal/app/") to keep deep trees short.
+func BuildFileTree(files []gh.PRFile) []FileTreeRow {
+	root := &fileTreeNode{children: map[string]*fileTreeNode{}}
+	for i := range files {
+		f := &files[i]
+		node := root
+		node.adds += f.Additions
+		node.dels += f.Deletions
+		parts := strings.Split(f.Filename, "/")
+		for j, part := range parts {
+			child, ok := node.children[part]
+			if !ok {
+				child = &fileTreeNode{name: part, children: map[string]*fileTreeNode{}}
+				node.children[part] = child
+			}
+			child.adds += f.Additions
+			child.dels += f.Deletions
+			if j == len(parts)-1 {
+				child.file = f
+			}
+			node = child
+		}
+	}
+
+	var rows []FileTreeRow
+	var walk func(n *fileTreeNode, indent string)
+	walk = func(n *fileTreeNode, indent string) {
+		children := sortedChildren(n)
+		for i, c := range children {
+			name := c.name
+			// Merge chains of single directories
+			for c.file == nil && len(c.children) == 1 {
+				only := sortedChildren(c)[0]
+				if only.file != nil {
+					break
+				}
+				name += "/" + only.name
+				c = only
+			}
+			if c.file == nil {
+				name += "/"
+			}
+
+			branch, next := "├── ", "│   "
+			if i == len(children)-1 {
+				branch, next = "└── ", "    "
+			}
+			rows = append(rows, FileTreeRow{Name: name, Prefix: indent + branch, Additions: c.adds, Deletions: c.dels, File: c.file})
+			if c.file == nil {
+				walk(c, indent+next)
+			}
+		}
+	}
+	walk(root, "")
+	return rows
+}
+
+func sortedChildren(n *fileTreeNode) []*fileTreeNode {
+	out := make([]*fileTreeNode, 0, len(n.children))
+	for _, c := range n.children {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].file == nil) != (out[j].file == nil) {
+			return out[i].file == nil
+		}
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+// FilePatch returns a PR file's changes as a unified diff
+func FilePatch(f gh.PRFile) string {
+	from, to := "a/"+f.Filename, "b/"+f.Filename
+	if f.PreviousFilename != "" {
+		from = "a/" + f.PreviousFilename
+	}
+	switch f.Status {
+	case "added":
+		from = "/dev/null"
+	case "removed":
+		to = "/dev/null"
+	}
+	header := "--- " + from + "\n+++ " + to + "\n"
+	if f.Patch == "" {
+		return header + "(no textual diff: binary or too large)\n"
+	}
+	return header + strings.TrimRight(f.Patch, "\n") + "\n"
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// PRForBranch returns the open PR whose head is branch. Fork PRs, whose
+// head lives under another owner, are found by scanning the open PRs.
+func PRForBranch(ghc gh.Client, branch string) (*gh.PullRequest, error) {
+	pr, err := ghc.GetPRForBranch(branch)
+	if err != nil || pr != nil {
+		return pr, err
+	}
+	prs, err := ghc.ListPRs("open")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range prs {
+		if p.Head.Ref == branch {
+			return &p, nil
+		}
+	}
+	return nil, exitcode.Errorf(exitcode.NotFound, "no open PR found for branch %q", branch)
+}
+
+// Reviewer states 'sage pr status' shows besides GitHub's own
+const (
+	ReviewPending   = "PENDING"   // asked to review and hasn't yet
+	ReviewCommented = "COMMENTED" // only left comments
+)
+
+// ReviewerStatus is where one reviewer stands on a PR
+type ReviewerStatus struct {
+	Login string
+	State string // APPROVED, CHANGES_REQUESTED, DISMISSED, COMMENTED or PENDING
+}
+
+// PRStatus is everything 'sage pr status' shows about a PR
+type PRStatus struct {
+	PR        *gh.PullRequest
+	Reviewers []ReviewerStatus
+	// RequiredApprovals is what the base branch's protection asks for; 0
+	// when it asks for none or can't be read
+	RequiredApprovals int
+}
+
+// GetPRStatus fetches a PR with its checks and reviews, and how many
+// approvals its base branch requires
+func GetPRStatus(ghc gh.Client, num int) (*PRStatus, error) {
+	pr, err := ghc.GetPRDetails(num)
+	if err != nil {
+		return nil, err
+	}
+	st := &PRStatus{PR: pr, Reviewers: ReviewerStatuses(pr)}
+	// Reading protection needs admin rights; without them the requirement
+	// is simply unknown
+	if bp, err := ghc.GetBranchProtection(pr.Base.Ref); err == nil && bp != nil {
+		st.RequiredApprovals = bp.RequiredReviews
+	}
+	return st, nil
+}
+
+// Refresh fetches the PR again, keeping the approvals required
+func (s *PRStatus) Refresh(ghc gh.Client) error {
+	pr, err := ghc.GetPRDetails(s.PR.Number)
+	if err != nil {
+		return err
+	}
+	s.PR, s.Reviewers = pr, ReviewerStatuses(pr)
+	return nil
+}
+
+// ReviewerStatuses lists each reviewer's latest decision, then those who
+// only commented, then those asked to review who haven't yet
+func ReviewerStatuses(pr *gh.PullRequest) []ReviewerStatus {
+	var out []ReviewerStatus
+	seen := map[string]bool{pr.User.Login: true} // authors can't review their own PR
+	for _, r := range LatestReviews(pr.Reviews) {
+		out = append(out, ReviewerStatus{Login: r.User.Login, State: r.State})
+		seen[r.User.Login] = true
+	}
+	for _, r := range pr.Reviews {
+		if !seen[r.User.Login] && r.User.Login != "" {
+			out = append(out, ReviewerStatus{Login: r.User.Login, State: ReviewCommented})
+			seen[r.User.Login] = true
+		}
+	}
+	for _, r := range pr.RequestedReviewers {
+		if !seen[r.Login] {
+			out = append(out, ReviewerStatus{Login: r.Login, State: ReviewPending})
+			seen[r.Login] = true
+		}
+	}
+	return out
+}
+
+// Approvals counts the reviewers whose latest decision is an approval
+func (s *PRStatus) Approvals() int {
+	n := 0
+	for _, r := range s.Reviewers {
+		if r.State == "APPROVED" {
+			n++
+		}
+	}
+	return n
+}
+
+// MissingApprovals is how many more approvals the base branch requires
+func (s *PRStatus) MissingApprovals() int {
+	return max(0, s.RequiredApprovals-s.Approvals())
+}
+
+// ChecksPending reports whether any check hasn't finished
+func (s *PRStatus) ChecksPending() bool {
+	for _, c := range s.PR.Checks {
+		if c.Status != "completed" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDuration is how long a check ran, or has been running; zero when
+// it hasn't started
+func CheckDuration(c gh.Check, now time.Time) time.Duration {
+	if c.StartedAt == nil {
+		return 0
+	}
+	end := now
+	if c.CompletedAt != nil && c.Status == "completed" {
+		end = *c.CompletedAt
+	}
+	return max(0, end.Sub(*c.StartedAt))
+}
+
+// MergeState describes whether the PR can be merged, and whether that is
+// known yet: GitHub works it out in the background after each push
+func (s *PRStatus) MergeState() (string, bool) {
+	pr := s.PR
+	switch {
+	case pr.Merged:
+		return "merged", true
+	case pr.State == "closed":
+		return "closed", true
+	case pr.Mergeable != nil && !*pr.Mergeable, pr.MergeableState == "dirty":
+		return fmt.Sprintf("has conflicts with %s", pr.Base.Ref), true
+	}
+	switch pr.MergeableState {
+	case "clean", "has_hooks":
+		return "ready to merge", true
+	case "behind":
+		return fmt.Sprintf("behind %s", pr.Base.Ref), true
+	case "blocked":
+		return "blocked by required reviews or checks", true
+	case "unstable":
+		return "mergeable, but some checks aren't passing", true
+	case "draft":
+		return "draft", true
+	}
+	if pr.Mergeable != nil {
+		return "no conflicts", true
+	}
+	return "still being worked out by GitHub", false
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// fakeBranchPRs answers the branch lookups from fixed PRs
+type fakeBranchPRs struct {
+	gh.Client
+	byHead *gh.PullRequest
+	open   []gh.PullRequest
+}
+
+func (f *fakeBranchPRs) GetPRForBranch(string) (*gh.PullRequest, error) { return f.byHead, nil }
+func (f *fakeBranchPRs) ListPRs(string) ([]gh.PullRequest, error)       { return f.open, nil }
+
+func TestPRForBranch(t *testing.T) {
+	fork := gh.PullRequest{Number: 8}
+	fork.Head.Ref = "fix/typo"
+
+	// Found by head; the open PRs aren't needed
+	pr, err := PRForBranch(&fakeBranchPRs{byHead: &gh.PullRequest{Number: 3}}, "feat/x")
+	if err != nil || pr.Number != 3 {
+		t.Fatalf("got %+v, %v; want #3", pr, err)
+	}
+
+	// A fork's PR is only found among the open PRs
+	pr, err = PRForBranch(&fakeBranchPRs{open: []gh.PullRequest{fork}}, "fix/typo")
+	if err != nil || pr.Number != 8 {
+		t.Fatalf("got %+v, %v; want #8", pr, err)
+	}
+
+	_, err = PRForBranch(&fakeBranchPRs{open: []gh.PullRequest{fork}}, "main")
+	var coded *exitcode.Error
+	if !errors.As(err, &coded) || coded.Code != exitcode.NotFound {
+		t.Errorf("expected a not-found error, got %v", err)
+	}
+}
+
+func review(login, state string) gh.Review {
+	r := gh.Review{State: state}
+	r.User.Login = login
+	return r
+}
+
+func TestReviewerStatuses(t *testing.T) {
+	pr := &gh.PullRequest{
+		Reviews: []gh.Review{
+			review("alice", "CHANGES_REQUESTED"),
+			review("bob", "COMMENTED"),
+			review("author", "COMMENTED"),
+			review("alice", "APPROVED"),
+		},
+		RequestedReviewers: []gh.Account{{Login: "carol"}, {Login: "bob"}},
+	}
+	pr.User.Login = "author"
+
+	got := ReviewerStatuses(pr)
+	want := []ReviewerStatus{{"alice", "APPROVED"}, {"bob", ReviewCommented}, {"carol", ReviewPending}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reviewer %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	st := &PRStatus{PR: pr, Reviewers: got, RequiredApprovals: 2}
+	if st.Approvals() != 1 || st.MissingApprovals() != 1 {
+		t.Errorf("approvals = %d, missing = %d; want 1 and 1", st.Approvals(), st.MissingApprovals())
+	}
+}
+
+func TestMergeState(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		mergeable *bool
+		state     string
+		want      string
+		known     bool
+	}{
+		{nil, "", "still being worked out by GitHub", false},
+		{&no, "dirty", "has conflicts with main", true},
+		{&yes, "clean", "ready to merge", true},
+		{&yes, "behind", "behind main", true},
+		{&yes, "blocked", "blocked by required reviews or checks", true},
+		{&yes, "", "no conflicts", true},
+	}
+	for _, tt := range tests {
+		pr := &gh.PullRequest{State: "open", Mergeable: tt.mergeable, MergeableState: tt.state}
+		pr.Base.Ref = "main"
+		got, known := (&PRStatus{PR: pr}).MergeState()
+		if got != tt.want || known != tt.known {
+			t.Errorf("MergeState(%v, %q) = %q, %v; want %q, %v", tt.mergeable, tt.state, got, known, tt.want, tt.known)
+		}
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Minute)
+	now := start.Add(10 * time.Minute)
+
+	if d := CheckDuration(gh.Check{Status: "completed", StartedAt: &start, CompletedAt: &end}, now); d != 3*time.Minute {
+		t.Errorf("finished check took %s, want 3m", d)
+	}
+	if d := CheckDuration(gh.Check{Status: "in_progress", StartedAt: &start}, now); d != 10*time.Minute {
+		t.Errorf("running check has taken %s, want 10m", d)
+	}
+	if d := CheckDuration(gh.Check{Status: "queued"}, now); d != 0 {
+		t.Errorf("queued check took %s, want 0", d)
+	}
+
+	st := &PRStatus{PR: &gh.PullRequest{Checks: []gh.Check{{Status: "completed"}, {Status: "queued"}}}}
+	if !st.ChecksPending() {
+		t.Error("expected a queued check to be pending")
+	}
+}
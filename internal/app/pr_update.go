@@ -25,12 +25,16 @@ func UpdatePR(ghc gh.Client, g git.Service, num int, opts PRUpdateOptions) error
 		return err
 	}
 
+	if opts.UseAI && !config.AIEnabled(pr.Head.Ref) {
+		return fmt.Errorf("AI features are disabled on %s (ai.enabled=false)", pr.Head.Ref)
+	}
 	if opts.UseAI {
 		// Get commit history since PR was created
-		commits, err := g.Log(pr.Head.Ref, 0, true, false)
+		log, err := g.Commits(git.CommitsOptions{Range: pr.Head.Ref, Stats: true})
 		if err != nil {
 			return fmt.Errorf("failed to get commit history: %w", err)
 		}
+		commits := git.FormatCommits(log)
 
 		// Get the diff for context
 		diff, err := g.GetDiff()
@@ -40,7 +44,7 @@ func UpdatePR(ghc gh.Client, g git.Service, num int, opts PRUpdateOptions) error
 
 		// Initialize AI client
 		client := ai.NewClient("", ai.NewConfigAdapter(config.Get))
-		if client.APIKey == "" {
+		if !client.Configured() {
 			return fmt.Errorf("AI API key not configured. Please set it using 'sage config set ai_api_key YOUR_KEY'")
 		}
 
@@ -75,6 +79,7 @@ func UpdatePR(ghc gh.Client, g git.Service, num int, opts PRUpdateOptions) error
 	if opts.Body != "" {
 		pr.Body = opts.Body
 	}
+	wasDraft := pr.Draft
 	pr.Draft = opts.Draft
 
 	// Update the PR
@@ -82,6 +87,13 @@ func UpdatePR(ghc gh.Client, g git.Service, num int, opts PRUpdateOptions) error
 		return err
 	}
 
+	// GitHub only changes draft state through its own endpoint
+	if opts.Draft && !wasDraft {
+		if err := ghc.SetDraft(num, true); err != nil {
+			return err
+		}
+	}
+
 	// Update labels if specified
 	if len(opts.Labels) > 0 {
 		if err := ghc.AddLabels(num, opts.Labels); err != nil {
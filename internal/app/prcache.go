@@ -0,0 +1,126 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// CachedPR is what sage last saw of a branch's pull request. It lets status
+// show PR state without a GitHub round trip.
+type CachedPR struct {
+	Number    int       `json:"number" yaml:"number"`
+	Title     string    `json:"title" yaml:"title"`
+	State     string    `json:"state" yaml:"state"`
+	Draft     bool      `json:"draft,omitempty" yaml:"draft,omitempty"`
+	Merged    bool      `json:"merged,omitempty" yaml:"merged,omitempty"`
+	URL       string    `json:"url" yaml:"url"`
+	CheckedAt time.Time `json:"checked_at" yaml:"checked_at"`
+	// HeadSHA is the commit ChecksFailing was seen for
+	HeadSHA       string `json:"head_sha,omitempty" yaml:"head_sha,omitempty"`
+	ChecksFailing bool   `json:"checks_failing,omitempty" yaml:"checks_failing,omitempty"`
+}
+
+func prCachePath(g git.Service) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "pr_cache.json"), nil
+}
+
+func loadPRCache(g git.Service) (map[string]CachedPR, error) {
+	path, err := prCachePath(g)
+	if err != nil {
+		return nil, err
+	}
+	cache := map[string]CachedPR{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, fmt.Errorf("failed to read PR cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse PR cache: %w", err)
+	}
+	return cache, nil
+}
+
+// GetCachedPR returns the cached pull request for branch, or nil if sage has
+// not seen one
+func GetCachedPR(g git.Service, branch string) (*CachedPR, error) {
+	cache, err := loadPRCache(g)
+	if err != nil {
+		return nil, err
+	}
+	if pr, ok := cache[branch]; ok {
+		return &pr, nil
+	}
+	return nil, nil
+}
+
+// CachePR records the pull request for branch. A nil pr removes the entry.
+func CachePR(g git.Service, branch string, pr *gh.PullRequest) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	cache, err := loadPRCache(g)
+	if err != nil {
+		return err
+	}
+	if pr == nil {
+		delete(cache, branch)
+	} else {
+		entry := CachedPR{
+			Number:        pr.Number,
+			Title:         pr.Title,
+			State:         pr.State,
+			Draft:         pr.Draft,
+			Merged:        pr.Merged,
+			URL:           pr.HTMLURL,
+			CheckedAt:     time.Now(),
+			HeadSHA:       pr.Head.SHA,
+			ChecksFailing: slices.ContainsFunc(pr.Checks, gh.Check.Failed),
+		}
+		// A lookup without checks keeps what was seen for the same head
+		if old, ok := cache[branch]; ok && pr.Checks == nil && old.Number == pr.Number && old.HeadSHA == pr.Head.SHA {
+			entry.ChecksFailing = old.ChecksFailing
+		}
+		cache[branch] = entry
+	}
+
+	path, err := prCachePath(g)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal PR cache: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RefreshCachedPR asks GitHub for branch's pull request and updates the cache
+func RefreshCachedPR(g git.Service, ghc gh.Client, branch string) (*CachedPR, error) {
+	pr, err := ghc.GetPRForBranch(branch)
+	if err != nil {
+		return nil, err
+	}
+	if err := CachePR(g, branch, pr); err != nil {
+		return nil, err
+	}
+	return GetCachedPR(g, branch)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+)
+
+// prDefaultsPrefix starts the config keys mapping branch patterns to PR
+// defaults, e.g. pr.branch_defaults.fix/* = "label:bug, reviewer:@org/maintainers"
+const prDefaultsPrefix = "pr.branch_defaults."
+
+// PRDefaults are the labels and reviewers a team wants on every pull request
+// from branches matching Pattern
+type PRDefaults struct {
+	Pattern   string
+	Labels    []string
+	Reviewers []string // users, or org/team for teams
+}
+
+// ParsePRDefaults parses a comma-separated list of label:NAME and
+// reviewer:LOGIN entries. A leading @ on reviewers is optional.
+func ParsePRDefaults(value string) (PRDefaults, error) {
+	var d PRDefaults
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, v, ok := strings.Cut(item, ":")
+		v = strings.TrimSpace(v)
+		if !ok || v == "" {
+			return d, fmt.Errorf("invalid PR default %q (expected label:NAME or reviewer:LOGIN)", item)
+		}
+		switch strings.TrimSpace(kind) {
+		case "label":
+			d.Labels = append(d.Labels, v)
+		case "reviewer":
+			d.Reviewers = append(d.Reviewers, strings.TrimPrefix(v, "@"))
+		default:
+			return d, fmt.Errorf("invalid PR default %q (expected label:NAME or reviewer:LOGIN)", item)
+		}
+	}
+	return d, nil
+}
+
+// BranchPRDefaults returns the PR defaults configured for branch, using the
+// most specific matching pattern, and whether any matched
+func BranchPRDefaults(branch string) (PRDefaults, bool, error) {
+	var patterns []string
+	rules := map[string]string{}
+	for key, value := range config.GetPrefixed(prDefaultsPrefix, true) {
+		pattern := strings.TrimPrefix(key, prDefaultsPrefix)
+		if config.MatchBranch(pattern, branch) {
+			patterns = append(patterns, pattern)
+			rules[pattern] = value
+		}
+	}
+	if len(patterns) == 0 {
+		return PRDefaults{}, false, nil
+	}
+	// Fewer wildcards and longer literal parts make a pattern more specific
+	sort.Slice(patterns, func(i, j int) bool {
+		si := len(patterns[i]) - strings.Count(patterns[i], "*")
+		sj := len(patterns[j]) - strings.Count(patterns[j], "*")
+		if si != sj {
+			return si > sj
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	d, err := ParsePRDefaults(rules[patterns[0]])
+	if err != nil {
+		return d, false, fmt.Errorf("%s%s: %w", prDefaultsPrefix, patterns[0], err)
+	}
+	d.Pattern = patterns[0]
+	return d, true, nil
+}
+
+// mergeUnique appends the entries of extra missing from list
+func mergeUnique(list, extra []string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		seen[strings.ToLower(v)] = true
+	}
+	for _, v := range extra {
+		if !seen[strings.ToLower(v)] {
+			seen[strings.ToLower(v)] = true
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// protectionFile declares the desired branch protection for a repository
+const protectionFile = ".sage/protection.yaml"
+
+type protectionConfig struct {
+	Branches map[string]gh.BranchProtection `yaml:"branches"`
+}
+
+// ProtectionChange is one setting that differs between current and desired protection
+type ProtectionChange struct {
+	Setting string
+	From    string
+	To      string
+}
+
+// LoadProtection reads the desired protection for branch from .sage/protection.yaml.
+// Keys are branch names or glob patterns such as release/*; an exact name wins.
+// It returns nil if the file does not exist or has no entry for the branch.
+func LoadProtection(g git.Service, branch string) (*gh.BranchProtection, error) {
+	root, err := g.Run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repository root: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(root), protectionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", protectionFile, err)
+	}
+
+	var cfg protectionConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", protectionFile, err)
+	}
+
+	if bp, ok := cfg.Branches[branch]; ok {
+		return validateProtection(bp)
+	}
+
+	patterns := make([]string, 0, len(cfg.Branches))
+	for p := range cfg.Branches {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, branch); ok {
+			return validateProtection(cfg.Branches[p])
+		}
+	}
+	return nil, nil
+}
+
+func validateProtection(bp gh.BranchProtection) (*gh.BranchProtection, error) {
+	if bp.RequiredReviews < 0 || bp.RequiredReviews > 6 {
+		return nil, fmt.Errorf("%s: required_reviews must be between 0 and 6", protectionFile)
+	}
+	return &bp, nil
+}
+
+// DiffProtection lists the settings that change when moving from current to
+// desired. A nil current means the branch is unprotected.
+func DiffProtection(current *gh.BranchProtection, desired gh.BranchProtection) []ProtectionChange {
+	if current == nil {
+		current = &gh.BranchProtection{}
+	}
+
+	var changes []ProtectionChange
+	add := func(setting string, from, to any) {
+		f, t := fmt.Sprint(from), fmt.Sprint(to)
+		if f != t {
+			changes = append(changes, ProtectionChange{Setting: setting, From: f, To: t})
+		}
+	}
+	checks := func(c []string) string {
+		if len(c) == 0 {
+			return "none"
+		}
+		sorted := append([]string(nil), c...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, ", ")
+	}
+
+	add("Required approving reviews", current.RequiredReviews, desired.RequiredReviews)
+	add("Dismiss stale reviews", current.DismissStaleReviews, desired.DismissStaleReviews)
+	add("Require code owner reviews", current.RequireCodeOwnerReviews, desired.RequireCodeOwnerReviews)
+	add("Required status checks", checks(current.RequiredChecks), checks(desired.RequiredChecks))
+	add("Require branch to be up to date", current.StrictChecks, desired.StrictChecks)
+	add("Include administrators", current.EnforceAdmins, desired.EnforceAdmins)
+	add("Require linear history", current.LinearHistory, desired.LinearHistory)
+	add("Allow force pushes", current.AllowForcePushes, desired.AllowForcePushes)
+	add("Allow deletions", current.AllowDeletions, desired.AllowDeletions)
+	return changes
+}
+
+// ApplyProtection replaces the branch's protection on GitHub with desired
+func ApplyProtection(ghc gh.Client, branch string, desired gh.BranchProtection) error {
+	if err := ghc.UpdateBranchProtection(branch, &desired); err != nil {
+		return fmt.Errorf("failed to update protection for %s: %w", branch, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+const (
+	// prSearchPageSize is the largest page the search API returns
+	prSearchPageSize = 100
+	// maxPRSearchResults is how far the search API lets results be paged
+	maxPRSearchResults = 1000
+)
+
+// PRSearchOptions filters a pull request search
+type PRSearchOptions struct {
+	Org        string
+	Author     string
+	Labels     []string
+	Text       string
+	InvolvesMe bool
+	State      string // open, closed, merged or all
+}
+
+// quoteQualifier quotes a search qualifier value containing spaces
+func quoteQualifier(v string) string {
+	if strings.ContainsAny(v, " \t") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// PRSearchQuery builds the GitHub search query for opts
+func PRSearchQuery(opts PRSearchOptions) (string, error) {
+	var q []string
+	if opts.Text != "" {
+		q = append(q, opts.Text)
+	}
+	if opts.Org != "" {
+		q = append(q, "org:"+opts.Org)
+	}
+	if opts.Author != "" {
+		q = append(q, "author:"+opts.Author)
+	}
+	for _, l := range opts.Labels {
+		q = append(q, "label:"+quoteQualifier(l))
+	}
+	if opts.InvolvesMe {
+		q = append(q, "involves:@me")
+	}
+	switch opts.State {
+	case "", "open":
+		q = append(q, "is:open")
+	case "closed":
+		q = append(q, "is:closed")
+	case "merged":
+		q = append(q, "is:merged")
+	case "all":
+	default:
+		return "", fmt.Errorf("unknown state %q (use open, closed, merged or all)", opts.State)
+	}
+	return strings.Join(q, " "), nil
+}
+
+// SearchPRs finds up to limit pull requests matching opts, paging through
+// the results. It also returns how many matched in total.
+func SearchPRs(ghc gh.Client, opts PRSearchOptions, limit int) ([]gh.PRSearchItem, int, error) {
+	query, err := PRSearchQuery(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 || limit > maxPRSearchResults {
+		limit = maxPRSearchResults
+	}
+
+	perPage := prSearchPageSize
+	if limit < perPage {
+		perPage = limit
+	}
+	var items []gh.PRSearchItem
+	total := 0
+	for page := 1; len(items) < limit; page++ {
+		res, err := ghc.SearchPRs(query, page, perPage)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to search pull requests: %w", err)
+		}
+		total = res.TotalCount
+		items = append(items, res.Items...)
+		if len(res.Items) < perPage || page*perPage >= total {
+			break
+		}
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, total, nil
+}
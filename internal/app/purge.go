@@ -0,0 +1,259 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// PurgeReplacement is what matched secrets are replaced with when purging by pattern
+const PurgeReplacement = "***REMOVED***"
+
+// PurgeOptions describes what to strip from history
+type PurgeOptions struct {
+	Paths    []string // files to remove from every commit
+	Patterns []string // regular expressions to redact from every file
+	Remote   string   // remote to force-push to (defaults to origin)
+}
+
+// PurgePlan is what a purge would touch, computed before anything is rewritten
+type PurgePlan struct {
+	Commits     []string          // short hashes of commits containing the paths or patterns
+	RemoteTips  map[string]string // branch → remote commit, used as the force-with-lease expectation
+	FilterRepo  bool              // whether git-filter-repo is installed
+	ManualSteps []string          // exact commands to run when git-filter-repo is unavailable
+	RemoteURL   string
+}
+
+// PlanPurge finds the commits affected by a purge and records remote state for the later push
+func PlanPurge(g git.Service, opts PurgeOptions) (*PurgePlan, error) {
+	if len(opts.Paths) == 0 && len(opts.Patterns) == 0 {
+		return nil, fmt.Errorf("nothing to purge: pass --path or --pattern")
+	}
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+
+	plan := &PurgePlan{RemoteTips: map[string]string{}}
+	seen := map[string]bool{}
+	addCommits := func(out string) {
+		for _, h := range strings.Fields(out) {
+			if !seen[h] {
+				seen[h] = true
+				plan.Commits = append(plan.Commits, h)
+			}
+		}
+	}
+
+	for _, p := range opts.Paths {
+		out, err := g.Run("log", "--all", "--format=%h", "--", p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search history for %s: %w", p, err)
+		}
+		addCommits(out)
+	}
+	for _, pat := range opts.Patterns {
+		out, err := g.Run("log", "--all", "--format=%h", "-G"+pat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search history for pattern %q: %w", pat, err)
+		}
+		addCommits(out)
+	}
+
+	if url, err := g.GetConfigValue("remote." + opts.Remote + ".url"); err == nil {
+		plan.RemoteURL = strings.TrimSpace(url)
+	}
+	local := map[string]bool{}
+	if branches, err := g.ListBranches(); err == nil {
+		for _, b := range branches {
+			local[b] = true
+		}
+	}
+	if out, err := g.Run("for-each-ref", "--format=%(refname:short) %(objectname)", "refs/remotes/"+opts.Remote); err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			parts := strings.Fields(line)
+			if len(parts) != 2 {
+				continue
+			}
+			branch := strings.TrimPrefix(parts[0], opts.Remote+"/")
+			// only branches that exist locally are rewritten and pushed back
+			if !local[branch] {
+				continue
+			}
+			plan.RemoteTips[branch] = parts[1]
+		}
+	}
+	_, err := exec.LookPath("git-filter-repo")
+	plan.FilterRepo = err == nil
+	if !plan.FilterRepo {
+		plan.ManualSteps = purgeManualSteps(opts, plan)
+	}
+	return plan, nil
+}
+
+// purgeManualSteps returns the exact commands to run by hand when git-filter-repo
+// is not installed, including lease-protected pushes of the current remote branches
+func purgeManualSteps(opts PurgeOptions, plan *PurgePlan) []string {
+	steps := []string{
+		"pip install git-filter-repo   # or: brew install git-filter-repo",
+	}
+	replacements := ""
+	if len(opts.Patterns) > 0 {
+		replacements = "replacements.txt"
+		var lines []string
+		for _, p := range opts.Patterns {
+			lines = append(lines, "regex:"+p+"==>"+PurgeReplacement)
+		}
+		steps = append(steps, fmt.Sprintf("printf '%%s\\n' %s > %s", shellQuoteAll(lines), replacements))
+	}
+	steps = append(steps, "git "+strings.Join(quoteArgs(filterRepoArgs(opts, replacements)), " "))
+
+	if plan.RemoteURL != "" {
+		steps = append(steps, fmt.Sprintf("git remote add %s %s", opts.Remote, shellQuote(plan.RemoteURL)))
+	}
+	branches := make([]string, 0, len(plan.RemoteTips))
+	for b := range plan.RemoteTips {
+		branches = append(branches, b)
+	}
+	sort.Strings(branches)
+	for _, b := range branches {
+		steps = append(steps, fmt.Sprintf("git push --force-with-lease=refs/heads/%s:%s %s %s:refs/heads/%s",
+			b, plan.RemoteTips[b], opts.Remote, b, b))
+	}
+	return steps
+}
+
+// Purge rewrites history with git-filter-repo, restoring the remote that
+// filter-repo removes as a safety measure.
+func Purge(g git.Service, opts PurgeOptions, plan *PurgePlan) error {
+	if !plan.FilterRepo {
+		return fmt.Errorf("git-filter-repo is not installed")
+	}
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+
+	clean, err := g.IsClean()
+	if err != nil {
+		return fmt.Errorf("failed to check working directory: %w", err)
+	}
+	if !clean {
+		return exitcode.Errorf(exitcode.DirtyTree, "commit or stash your changes before rewriting history")
+	}
+
+	replacements := ""
+	if len(opts.Patterns) > 0 {
+		f, err := os.CreateTemp("", "sage-purge-*.txt")
+		if err != nil {
+			return fmt.Errorf("failed to create replacements file: %w", err)
+		}
+		defer os.Remove(f.Name())
+		for _, p := range opts.Patterns {
+			fmt.Fprintf(f, "regex:%s==>%s\n", p, PurgeReplacement)
+		}
+		f.Close()
+		replacements = f.Name()
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("git %s", dryrun.Quote(filterRepoArgs(opts, replacements)))
+		return nil
+	}
+
+	cmd, err := git.SetupSecureCommand("git", filterRepoArgs(opts, replacements)...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git filter-repo failed: %w", err)
+	}
+
+	// filter-repo drops the origin remote so a rewritten repo isn't pushed by accident
+	if plan.RemoteURL != "" {
+		if _, err := g.Run("remote", "get-url", opts.Remote); err != nil {
+			if _, err := g.Run("remote", "add", opts.Remote, plan.RemoteURL); err != nil {
+				return fmt.Errorf("history rewritten, but failed to restore remote %s: %w", opts.Remote, err)
+			}
+		}
+	}
+	return nil
+}
+
+// PushPurged force-pushes every rewritten branch that exists on the remote, using
+// the pre-rewrite remote commits as the lease so nobody else's work is clobbered.
+func PushPurged(g git.Service, opts PurgeOptions, plan *PurgePlan) ([]string, error) {
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+	branches, err := g.ListBranches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	var pushed []string
+	for _, b := range branches {
+		old, ok := plan.RemoteTips[b]
+		if !ok {
+			continue
+		}
+		lease := fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", b, old)
+		if _, err := g.Run("push", lease, opts.Remote, b+":refs/heads/"+b); err != nil {
+			return pushed, fmt.Errorf("failed to push %s: %w", b, err)
+		}
+		pushed = append(pushed, b)
+	}
+	return pushed, nil
+}
+
+// PurgeReminders lists what still has to happen outside git after a purge
+func PurgeReminders() []string {
+	return []string{
+		"Rotate every credential that was committed. Rewriting history does not un-leak a secret.",
+		"Anyone with a clone, fork, or CI cache still has the old commits; ask collaborators to re-clone.",
+		"Open pull requests keep references to the old commits; GitHub support can purge cached views.",
+		"Add the purged paths to .gitignore so they are not committed again.",
+	}
+}
+
+func filterRepoArgs(opts PurgeOptions, replacements string) []string {
+	args := []string{"filter-repo", "--force"}
+	if len(opts.Paths) > 0 {
+		args = append(args, "--invert-paths")
+		for _, p := range opts.Paths {
+			args = append(args, "--path", p)
+		}
+	}
+	if replacements != "" {
+		args = append(args, "--replace-text", replacements)
+	}
+	return args
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellQuoteAll(ss []string) string {
+	return strings.Join(quoteArgs(ss), " ")
+}
+
+// quoteArgs shell-quotes any argument that is not a plain word
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a != "" && !strings.ContainsAny(a, " '\"$*?[]()|;&<>`\\") {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = shellQuote(a)
+	}
+	return quoted
+}
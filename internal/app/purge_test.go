@@ -0,0 +1,138 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestPlanPurgeFindsAffectedCommits(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("key.pem", "secret\n", "Add key")
+	withKey := r.git("rev-parse", "--short", "HEAD")
+	r.commit("app.txt", "token = ghp_abcdef\n", "Add app")
+	withToken := r.git("rev-parse", "--short", "HEAD")
+	r.commit("notes.txt", "notes\n", "Add notes")
+	r.git("push", "origin", "main")
+	// Only branches that exist locally are pushed back after the rewrite
+	r.git("push", "origin", "main:elsewhere")
+
+	g := git.NewShellGit()
+	tests := []struct {
+		name string
+		opts PurgeOptions
+		want []string
+	}{
+		{"path", PurgeOptions{Paths: []string{"key.pem"}}, []string{withKey}},
+		{"pattern", PurgeOptions{Patterns: []string{"ghp_[a-z]+"}}, []string{withToken}},
+		{"both", PurgeOptions{Paths: []string{"key.pem"}, Patterns: []string{"ghp_[a-z]+"}}, []string{withKey, withToken}},
+		{"nothing matches", PurgeOptions{Paths: []string{"missing.txt"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanPurge(g, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := slices.Clone(plan.Commits)
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("commits = %v, want %v", got, want)
+			}
+			if len(plan.RemoteTips) != 1 || plan.RemoteTips["main"] != r.rev("origin/main") {
+				t.Errorf("remote tips = %v, want only main at origin/main", plan.RemoteTips)
+			}
+		})
+	}
+
+	if _, err := PlanPurge(g, PurgeOptions{}); err == nil {
+		t.Error("expected an error with nothing to purge")
+	}
+}
+
+func TestFilterRepoArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         PurgeOptions
+		replacements string
+		want         []string
+	}{
+		{"paths", PurgeOptions{Paths: []string{"key.pem", "config/.env"}}, "",
+			[]string{"filter-repo", "--force", "--invert-paths", "--path", "key.pem", "--path", "config/.env"}},
+		{"patterns", PurgeOptions{Patterns: []string{"ghp_.*"}}, "r.txt",
+			[]string{"filter-repo", "--force", "--replace-text", "r.txt"}},
+		{"both", PurgeOptions{Paths: []string{"key.pem"}, Patterns: []string{"x"}}, "r.txt",
+			[]string{"filter-repo", "--force", "--invert-paths", "--path", "key.pem", "--replace-text", "r.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterRepoArgs(tt.opts, tt.replacements); !slices.Equal(got, tt.want) {
+				t.Errorf("filterRepoArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurgeManualStepsLeaseEachBranch(t *testing.T) {
+	opts := PurgeOptions{Paths: []string{"my key.pem"}, Remote: "origin"}
+	plan := &PurgePlan{RemoteURL: "git@example.com:o/r.git", RemoteTips: map[string]string{"main": "aaa", "dev": "bbb"}}
+	steps := strings.Join(purgeManualSteps(opts, plan), "\n")
+	for _, want := range []string{
+		"git filter-repo --force --invert-paths --path 'my key.pem'",
+		"git remote add origin 'git@example.com:o/r.git'",
+		"git push --force-with-lease=refs/heads/dev:bbb origin dev:refs/heads/dev",
+		"git push --force-with-lease=refs/heads/main:aaa origin main:refs/heads/main",
+	} {
+		if !strings.Contains(steps, want) {
+			t.Errorf("manual steps missing %q:\n%s", want, steps)
+		}
+	}
+}
+
+// TestPushPurgedLeasesOldTips stands in for filter-repo with an amend:
+// the rewritten branch replaces the remote one only while the remote is
+// still where it was when the purge was planned
+func TestPushPurgedLeasesOldTips(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("key.pem", "secret\n", "Add key")
+	r.git("push", "origin", "main")
+	g := git.NewShellGit()
+
+	plan, err := PlanPurge(g, PurgeOptions{Paths: []string{"key.pem"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.git("rm", "-q", "key.pem")
+	r.git("commit", "-q", "--amend", "--allow-empty", "-m", "Rewritten")
+
+	pushed, err := PushPurged(g, PurgeOptions{}, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pushed, []string{"main"}) || r.gitIn(r.origin, "rev-parse", "main") != r.rev("HEAD") {
+		t.Errorf("pushed %v; want main replaced on the remote", pushed)
+	}
+
+	// A teammate pushes after the plan was made, so the lease no longer holds
+	plan, err = PlanPurge(g, PurgeOptions{Paths: []string{"README.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := r.clone("other")
+	r.commitIn(other, "theirs.txt", "theirs\n", "Teammate work")
+	r.gitIn(other, "push", "origin", "main")
+	theirs := r.gitIn(other, "rev-parse", "HEAD")
+	r.git("fetch", "origin")
+	r.git("commit", "-q", "--amend", "--allow-empty", "-m", "Rewritten again")
+
+	if _, err := PushPurged(g, PurgeOptions{}, plan); err == nil {
+		t.Error("expected the push to be refused")
+	}
+	if got := r.gitIn(r.origin, "rev-parse", "main"); got != theirs {
+		t.Errorf("origin main = %s, want the teammate's %s kept", got, theirs)
+	}
+}
@@ -2,18 +2,127 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/crazywolf132/sage/internal/git"
 )
 
-func PushCurrentBranch(g git.Service, force bool) error {
+func PushCurrentBranch(g git.Service, force bool, allowWip bool) error {
 	repo, err := g.IsRepo()
 	if err != nil || !repo {
-		return fmt.Errorf("no a repo or error checking repo: %v", err)
+		return git.ErrNotARepo
 	}
 	br, err := g.CurrentBranch()
 	if err != nil {
 		return err
 	}
+	if br == "HEAD" {
+		return fmt.Errorf("cannot push from a %w; start a branch with 'sage start'", git.ErrDetachedHead)
+	}
+	if !allowWip {
+		if err := ensureNoWipCommits(g, pushBase(g, br)); err != nil {
+			return err
+		}
+	}
 	return g.Push(br, force)
 }
+
+// RefPushOptions selects refs to push other than the current branch
+type RefPushOptions struct {
+	Remote      string   // remote for refspecs and tags; defaults to origin
+	Refspecs    []string // explicit <src>[:<dst>] refspecs
+	Tags        bool     // push all tags
+	AllBranches bool     // push every local branch that has an upstream, to that upstream
+	Force       bool     // use --force-with-lease
+	AllowWip    bool
+}
+
+// PlanRefPush validates the requested refs and groups them into one push per remote
+func PlanRefPush(g git.Service, opts RefPushOptions) ([]git.PushRefsOptions, error) {
+	remote := opts.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+
+	byRemote := map[string]*git.PushRefsOptions{}
+	var order []string
+	add := func(r, spec string) {
+		p, ok := byRemote[r]
+		if !ok {
+			p = &git.PushRefsOptions{Remote: r, Force: opts.Force}
+			byRemote[r] = p
+			order = append(order, r)
+		}
+		if spec != "" {
+			p.Refspecs = append(p.Refspecs, spec)
+		}
+	}
+
+	var branches []string
+	for _, spec := range opts.Refspecs {
+		src, _, _ := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		if src == "" {
+			return nil, fmt.Errorf("refusing to delete remote refs with %q; use 'sage clean' or git push --delete", spec)
+		}
+		if _, err := g.Run("rev-parse", "--verify", "--quiet", src); err != nil {
+			return nil, fmt.Errorf("unknown ref %q in refspec %q", src, spec)
+		}
+		if _, err := g.Run("show-ref", "--verify", "--quiet", "refs/heads/"+src); err == nil {
+			branches = append(branches, src)
+		}
+		add(remote, spec)
+	}
+
+	if opts.AllBranches {
+		out, err := g.Run("for-each-ref", "--format=%(refname:short) %(upstream:remotename) %(upstream:remoteref)", "refs/heads")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branches: %w", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue // no upstream
+			}
+			branches = append(branches, fields[0])
+			add(fields[1], fields[0]+":"+fields[2])
+		}
+	}
+
+	if opts.Tags {
+		add(remote, "")
+		byRemote[remote].Tags = true
+	}
+
+	if len(order) == 0 {
+		return nil, fmt.Errorf("nothing to push")
+	}
+
+	if !opts.AllowWip {
+		for _, br := range branches {
+			wips, err := FindWipCommits(g, pushBase(g, br)+".."+br)
+			if err == nil && len(wips) > 0 {
+				return nil, fmt.Errorf("refusing to publish wip commits on %s:\n  %s\n\nRun 'sage unwip' and commit properly, or pass --allow-wip",
+					br, strings.Join(wips, "\n  "))
+			}
+		}
+	}
+
+	plans := make([]git.PushRefsOptions, 0, len(order))
+	for _, r := range order {
+		plans = append(plans, *byRemote[r])
+	}
+	return plans, nil
+}
+
+// PushRefs runs each planned push and collects the per-ref results
+func PushRefs(g git.Service, plans []git.PushRefsOptions) ([]git.PushRefResult, error) {
+	var results []git.PushRefResult
+	for _, p := range plans {
+		res, err := g.PushRefs(p)
+		if err != nil {
+			return results, fmt.Errorf("push to %s failed: %w", p.Remote, err)
+		}
+		results = append(results, res...)
+	}
+	return results, nil
+}
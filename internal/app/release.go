@@ -0,0 +1,215 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// maxUploadAttempts is how often an asset upload is tried before giving up
+const maxUploadAttempts = 3
+
+// archiveTypes covers build artifacts the system MIME tables often don't know
+var archiveTypes = map[string]string{
+	".gz":       "application/gzip",
+	".tgz":      "application/gzip",
+	".bz2":      "application/x-bzip2",
+	".xz":       "application/x-xz",
+	".zst":      "application/zstd",
+	".zip":      "application/zip",
+	".tar":      "application/x-tar",
+	".deb":      "application/vnd.debian.binary-package",
+	".rpm":      "application/x-rpm",
+	".apk":      "application/vnd.android.package-archive",
+	".dmg":      "application/x-apple-diskimage",
+	".msi":      "application/x-msi",
+	".exe":      "application/vnd.microsoft.portable-executable",
+	".sig":      "application/pgp-signature",
+	".asc":      "application/pgp-signature",
+	".sha256":   "text/plain",
+	".txt":      "text/plain",
+	".json":     "application/json",
+	".sbom":     "application/json",
+	".intoto":   "application/json",
+	".pem":      "application/x-pem-file",
+	".wasm":     "application/wasm",
+	".jar":      "application/java-archive",
+	".nupkg":    "application/zip",
+	".appimage": "application/x-executable",
+}
+
+// AssetFile is a local file to attach to a release
+type AssetFile struct {
+	Path        string
+	Name        string
+	Size        int64
+	ContentType string
+}
+
+// ReleaseAssetFiles expands paths and glob patterns into the files to
+// upload, skipping directories. Patterns are expanded here as well as by the
+// shell, so quoted globs work too.
+func ReleaseAssetFiles(patterns []string) ([]AssetFile, error) {
+	var files []AssetFile
+	names := map[string]string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				continue
+			}
+			name := filepath.Base(path)
+			if other, ok := names[name]; ok {
+				if other == path {
+					continue
+				}
+				return nil, fmt.Errorf("%s and %s would both be uploaded as %s", other, path, name)
+			}
+			names[name] = path
+			contentType, err := DetectContentType(path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, AssetFile{Path: path, Name: name, Size: info.Size(), ContentType: contentType})
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to upload")
+	}
+	return files, nil
+}
+
+// DetectContentType picks the MIME type for a file from its extension,
+// falling back to sniffing its first bytes
+func DetectContentType(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := archiveTypes[ext]; ok {
+		return t, nil
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// UploadProgress reports how many bytes of an upload have been sent
+type UploadProgress func(sent, total int64)
+
+// AssetUploadResult is what happened to one file
+type AssetUploadResult struct {
+	Asset    *gh.ReleaseAsset
+	Replaced bool // an asset with the same name was deleted first
+	Skipped  bool // the asset already existed and replace was off
+	Attempts int
+}
+
+// UploadReleaseAsset attaches file to release, replacing an asset with the
+// same name unless replace is false, in which case it is skipped. Failed
+// uploads are retried with a growing delay; any partial asset a failed
+// attempt left behind is removed before the next one.
+func UploadReleaseAsset(ghc gh.Client, release *gh.Release, file AssetFile, replace bool, progress UploadProgress) (AssetUploadResult, error) {
+	var res AssetUploadResult
+	if existing, ok := release.Asset(file.Name); ok {
+		if !replace && existing.State != "starter" {
+			res.Skipped = true
+			res.Asset = &existing
+			return res, nil
+		}
+		if err := ghc.DeleteReleaseAsset(existing.ID); err != nil {
+			return res, fmt.Errorf("failed to remove the existing %s: %w", file.Name, err)
+		}
+		res.Replaced = existing.State != "starter"
+	}
+
+	var lastErr error
+	for res.Attempts < maxUploadAttempts {
+		res.Attempts++
+		if res.Attempts > 1 {
+			time.Sleep(time.Duration(res.Attempts-1) * 2 * time.Second)
+			if err := removePartialAsset(ghc, release.TagName, file.Name); err != nil {
+				return res, err
+			}
+		}
+
+		asset, err := uploadFile(ghc, release, file, progress)
+		if err == nil {
+			res.Asset = asset
+			return res, nil
+		}
+		lastErr = err
+	}
+	return res, fmt.Errorf("failed to upload %s after %d attempts: %w", file.Name, res.Attempts, lastErr)
+}
+
+// uploadFile makes one upload attempt, reporting progress as it goes
+func uploadFile(ghc gh.Client, release *gh.Release, file AssetFile, progress UploadProgress) (*gh.ReleaseAsset, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var body io.Reader = f
+	if progress != nil {
+		progress(0, file.Size)
+		body = &progressReader{r: f, total: file.Size, report: progress}
+	}
+	return ghc.UploadReleaseAsset(release, file.Name, file.ContentType, body, file.Size)
+}
+
+// removePartialAsset deletes what an interrupted upload of name left on the release
+func removePartialAsset(ghc gh.Client, tag, name string) error {
+	release, err := ghc.GetReleaseByTag(tag)
+	if err != nil {
+		return fmt.Errorf("failed to reload release %s: %w", tag, err)
+	}
+	if existing, ok := release.Asset(name); ok {
+		if err := ghc.DeleteReleaseAsset(existing.ID); err != nil {
+			return fmt.Errorf("failed to remove the partial upload of %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	r      io.Reader
+	sent   int64
+	total  int64
+	report UploadProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	p.report(p.sent, p.total)
+	return n, err
+}
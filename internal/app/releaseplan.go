@@ -0,0 +1,309 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	goversion "github.com/hashicorp/go-version"
+)
+
+// conventionalHeader splits a conventional commit subject into its type,
+// scope, breaking marker and description
+var conventionalHeader = regexp.MustCompile(`^([a-z]+)(?:\(([^)]+)\))?(!)?: (.+)$`)
+
+// commitChangeTypes files conventional commit types under the changelog's
+// change types. Other types (chore, docs, ci, ...) stay out of the changelog.
+var commitChangeTypes = map[string]string{
+	"feat":     "added",
+	"fix":      "fixed",
+	"perf":     "changed",
+	"refactor": "changed",
+	"revert":   "changed",
+}
+
+// ReleaseCommit is a commit going into a release
+type ReleaseCommit struct {
+	Hash     string
+	Type     string // conventional commit type, empty when the subject isn't one
+	Scope    string
+	Subject  string // the description, without type and scope
+	Breaking bool
+}
+
+// ReleaseOptions configures PlanRelease
+type ReleaseOptions struct {
+	// Bump is "major", "minor", "patch" or an explicit version. Empty works it
+	// out from the commits: breaking changes bump the major version (the
+	// minor one before 1.0.0), features the minor one, anything else the patch.
+	Bump string
+	// PreRelease is the pre-release identifier, like "rc", for a release
+	// such as 1.3.0-rc.2. Empty makes a full release.
+	PreRelease string
+}
+
+// ReleasePlan is what a release will create
+type ReleasePlan struct {
+	Previous   string // the last full release's tag, empty for the first release
+	Version    string // the new version, without a leading v
+	Tag        string
+	Bump       string // major, minor, patch, or empty for an explicit version
+	PreRelease bool
+	Commits    []ReleaseCommit // commits since Previous, newest first
+	Fragments  []Fragment      // changelog entries: from commits, then pending .changes fragments
+	Notes      string          // the changelog section's body
+}
+
+// PlanRelease works out the next version from the conventional commits
+// since the last full release tag and the changelog entries for it
+func PlanRelease(g git.Service, opts ReleaseOptions) (*ReleasePlan, error) {
+	plan := &ReleasePlan{}
+	latest, stable, err := releaseTags(g)
+	if err != nil {
+		return nil, err
+	}
+	if stable != nil {
+		plan.Previous = stable.Original()
+	}
+
+	if plan.Commits, err = releaseCommits(g, plan.Previous); err != nil {
+		return nil, err
+	}
+	pending, err := ListFragments(g)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Commits) == 0 && len(pending) == 0 {
+		if plan.Previous == "" {
+			return nil, fmt.Errorf("nothing to release")
+		}
+		return nil, fmt.Errorf("nothing to release since %s", plan.Previous)
+	}
+
+	plan.Bump = opts.Bump
+	if plan.Bump == "" {
+		plan.Bump = bumpFor(plan.Commits, stable)
+	}
+	base := "0.0.0"
+	if stable != nil {
+		base = stable.String()
+	}
+	next, err := NextVersion(base, plan.Bump)
+	if err != nil {
+		return nil, err
+	}
+	switch plan.Bump {
+	case "major", "minor", "patch":
+	default:
+		plan.Bump = ""
+	}
+
+	if opts.PreRelease != "" && !strings.Contains(next, "-") {
+		next = fmt.Sprintf("%s-%s.%d", next, opts.PreRelease, nextPreRelease(latest, next, opts.PreRelease))
+	}
+	plan.Version = next
+	plan.Tag = "v" + next
+	plan.PreRelease = strings.Contains(next, "-")
+	if refExists(g, "refs/tags/"+plan.Tag) {
+		return nil, fmt.Errorf("tag %s already exists", plan.Tag)
+	}
+
+	for _, c := range plan.Commits {
+		if t, ok := commitChangeTypes[c.Type]; ok || c.Breaking {
+			if !ok {
+				t = "changed"
+			}
+			plan.Fragments = append(plan.Fragments, Fragment{Type: t, Summary: c.changelogLine()})
+		}
+	}
+	plan.Fragments = append(plan.Fragments, pending...)
+	plan.Notes = strings.TrimSpace(RenderFragments(plan.Fragments, 3))
+	return plan, nil
+}
+
+// releaseTags finds the newest version tag reachable from HEAD, and the
+// newest one that isn't a pre-release; either is nil when there's none
+func releaseTags(g git.Service) (latest, stable *goversion.Version, err error) {
+	out, err := g.Run("tag", "--list", "--merged", "HEAD")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	for _, tag := range strings.Fields(out) {
+		v, err := goversion.NewSemver(tag)
+		if err != nil {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+		}
+		if v.Prerelease() == "" && (stable == nil || v.GreaterThan(stable)) {
+			stable = v
+		}
+	}
+	return latest, stable, nil
+}
+
+// releaseCommits lists the commits since tag, or all of them without one,
+// leaving out the changelog commits of pre-releases in between
+func releaseCommits(g git.Service, tag string) ([]ReleaseCommit, error) {
+	rev := "HEAD"
+	if tag != "" {
+		rev = tag + "..HEAD"
+	}
+	out, err := g.Run("log", "--no-merges", "--format=%H%x00%s%x00%b%x1e", rev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commits: %w", err)
+	}
+	var commits []ReleaseCommit
+	for _, record := range strings.Split(out, "\x1e") {
+		f := strings.SplitN(strings.TrimSpace(record), "\x00", 3)
+		if len(f) < 2 {
+			continue
+		}
+		c := ReleaseCommit{Hash: f[0], Subject: f[1]}
+		if strings.HasPrefix(c.Subject, ReleaseCommitMessage("")) {
+			// An earlier pre-release's changelog commit
+			continue
+		}
+		if m := conventionalHeader.FindStringSubmatch(f[1]); m != nil {
+			c.Type, c.Scope, c.Breaking, c.Subject = m[1], m[2], m[3] == "!", m[4]
+		}
+		if len(f) == 3 && (strings.Contains(f[2], "BREAKING CHANGE:") || strings.Contains(f[2], "BREAKING-CHANGE:")) {
+			c.Breaking = true
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// bumpFor picks the bump the commits call for
+func bumpFor(commits []ReleaseCommit, stable *goversion.Version) string {
+	bump := "patch"
+	for _, c := range commits {
+		if c.Breaking {
+			// Before 1.0.0 anything may change, so breaking changes don't
+			// make it a 1.0.0
+			if stable != nil && stable.Segments()[0] == 0 {
+				return "minor"
+			}
+			return "major"
+		}
+		if c.Type == "feat" {
+			bump = "minor"
+		}
+	}
+	return bump
+}
+
+// nextPreRelease numbers the next id pre-release of version, following on
+// from latest when it's one of them
+func nextPreRelease(latest *goversion.Version, version, id string) int {
+	if latest == nil {
+		return 1
+	}
+	core, pre, ok := strings.Cut(latest.String(), "-")
+	if !ok || core != version {
+		return 1
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(pre, id+"."))
+	if err != nil {
+		return 1
+	}
+	return n + 1
+}
+
+// changelogLine is how the commit reads in the changelog
+func (c ReleaseCommit) changelogLine() string {
+	line := c.Subject
+	if c.Scope != "" {
+		line = fmt.Sprintf("**%s:** %s", c.Scope, line)
+	}
+	if c.Breaking {
+		line = "**Breaking:** " + line
+	}
+	if len(c.Hash) > 7 {
+		line += " (" + c.Hash[:7] + ")"
+	}
+	return line
+}
+
+// ApplyRelease adds the release to CHANGELOG.md, commits it along with the
+// removal of the pending fragments it used, and tags the commit with an
+// annotated tag carrying the notes
+func ApplyRelease(g git.Service, plan *ReleasePlan, date time.Time) error {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, ChangelogFile)
+	paths := []string{path}
+	for _, f := range plan.Fragments {
+		if f.Path != "" {
+			paths = append(paths, f.Path)
+		}
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("add the %s section to %s", plan.Version, ChangelogFile)
+	} else {
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		section := fmt.Sprintf("## [%s] - %s\n\n%s\n\n", plan.Version, date.Format("2006-01-02"), plan.Notes)
+		if err := os.WriteFile(path, []byte(insertRelease(string(existing), section)), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ChangelogFile, err)
+		}
+		for _, p := range paths[1:] {
+			if err := os.Remove(p); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", p, err)
+			}
+		}
+		if _, err := g.Run(append([]string{"add", "--"}, paths...)...); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", ChangelogFile, err)
+		}
+	}
+	if err := g.CommitPaths(ReleaseCommitMessage(plan.Version), false, paths); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", ChangelogFile, err)
+	}
+
+	msg, err := os.CreateTemp("", "sage-commit-msg-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(msg.Name())
+	if _, err := msg.WriteString(plan.Tag + "\n\n" + plan.Notes + "\n"); err != nil {
+		msg.Close()
+		return err
+	}
+	msg.Close()
+	// Keep the notes' headings, which git would take for comments
+	if _, err := g.Run("tag", "-a", "--cleanup=whitespace", plan.Tag, "-F", msg.Name()); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", plan.Tag, err)
+	}
+	return nil
+}
+
+// PublishRelease pushes the release commit and tag, then creates the GitHub
+// release with the notes
+func PublishRelease(g git.Service, ghc gh.Client, plan *ReleasePlan) (*gh.Release, error) {
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := g.Run("push", "origin", branch, "refs/tags/"+plan.Tag); err != nil {
+		return nil, fmt.Errorf("failed to push %s: %w", plan.Tag, err)
+	}
+	release, err := ghc.CreateRelease(plan.Tag, plan.Tag, plan.Notes, plan.PreRelease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the GitHub release: %w", err)
+	}
+	return release, nil
+}
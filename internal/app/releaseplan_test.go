@@ -0,0 +1,88 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestPlanReleaseFromConventionalCommits(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("tag", "v1.2.0")
+	r.commit("login.go", "package login\n", "feat(auth): add login")
+	r.commit("token.go", "package token\n", "fix: handle a missing token")
+	r.commit("README.md", "docs\n", "docs: explain login")
+	g := git.NewShellGit()
+
+	plan, err := PlanRelease(g, ReleaseOptions{})
+	if err != nil {
+		t.Fatalf("PlanRelease: %v", err)
+	}
+	if plan.Tag != "v1.3.0" || plan.Bump != "minor" || plan.Previous != "v1.2.0" || plan.PreRelease {
+		t.Errorf("plan = %s (%s since %s, pre-release %v), want v1.3.0, a minor bump since v1.2.0", plan.Tag, plan.Bump, plan.Previous, plan.PreRelease)
+	}
+	if len(plan.Commits) != 3 {
+		t.Errorf("%d commits, want 3", len(plan.Commits))
+	}
+	for _, want := range []string{"### Added", "**auth:** add login", "### Fixed", "handle a missing token"} {
+		if !strings.Contains(plan.Notes, want) {
+			t.Errorf("notes are missing %q:\n%s", want, plan.Notes)
+		}
+	}
+	if strings.Contains(plan.Notes, "explain login") {
+		t.Errorf("docs commits made it into the notes:\n%s", plan.Notes)
+	}
+
+	if err := ApplyRelease(g, plan, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("ApplyRelease: %v", err)
+	}
+	if got := r.read(ChangelogFile); !strings.HasPrefix(got, "# Changelog\n\n## [1.3.0] - 2026-03-01\n\n### Added") {
+		t.Errorf("%s =\n%s", ChangelogFile, got)
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != "chore(release): v1.3.0" {
+		t.Errorf("HEAD subject = %q", got)
+	}
+	if got := r.git("tag", "-l", "--format=%(objecttype) %(contents)", "v1.3.0"); !strings.HasPrefix(got, "tag v1.3.0\n\n### Added") {
+		t.Errorf("v1.3.0 isn't an annotated tag with the notes: %q", got)
+	}
+	r.assertClean()
+
+	if _, err := PlanRelease(g, ReleaseOptions{}); err == nil || !strings.Contains(err.Error(), "nothing to release since v1.3.0") {
+		t.Errorf("PlanRelease with nothing new = %v", err)
+	}
+}
+
+func TestPlanPreRelease(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("tag", "v0.4.1")
+	r.commit("api.go", "package api\n", "feat!: drop the v1 API")
+	g := git.NewShellGit()
+
+	plan, err := PlanRelease(g, ReleaseOptions{PreRelease: "rc"})
+	if err != nil {
+		t.Fatalf("PlanRelease: %v", err)
+	}
+	if plan.Tag != "v0.5.0-rc.1" || !plan.PreRelease {
+		t.Fatalf("tag = %s, want v0.5.0-rc.1: breaking changes before 1.0.0 bump the minor version", plan.Tag)
+	}
+	if !strings.Contains(plan.Notes, "**Breaking:** drop the v1 API") {
+		t.Errorf("notes don't flag the breaking change:\n%s", plan.Notes)
+	}
+	if err := ApplyRelease(g, plan, time.Now()); err != nil {
+		t.Fatalf("ApplyRelease: %v", err)
+	}
+
+	plan, err = PlanRelease(g, ReleaseOptions{PreRelease: "rc"})
+	if err != nil {
+		t.Fatalf("PlanRelease: %v", err)
+	}
+	if plan.Tag != "v0.5.0-rc.2" || len(plan.Commits) != 1 {
+		t.Errorf("next pre-release = %s with %d commits, want v0.5.0-rc.2 with the one feature", plan.Tag, len(plan.Commits))
+	}
+
+	if plan, err = PlanRelease(g, ReleaseOptions{Bump: "major"}); err != nil || plan.Tag != "v1.0.0" {
+		t.Errorf("PlanRelease major = %v, %v, want v1.0.0", plan, err)
+	}
+}
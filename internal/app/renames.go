@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Clash kinds
+const (
+	ClashCase    = "case"    // Foo.go and foo.go
+	ClashUnicode = "unicode" // the same name in NFC and NFD form
+)
+
+// PathClash is a set of paths that are the same file on a case-insensitive
+// or normalizing filesystem such as macOS's, so git sees a delete and an add
+// where the user renamed, or two files where the disk has room for one
+type PathClash struct {
+	Kind  string   `json:"kind" yaml:"kind"`
+	Paths []string `json:"paths" yaml:"paths"`
+}
+
+// foldPath is the key paths clash on: NFC form, case folded
+func foldPath(p string) string {
+	return strings.ToLower(norm.NFC.String(p))
+}
+
+// FindPathClashes groups paths that differ only in case or unicode form
+func FindPathClashes(paths []string) []PathClash {
+	groups := map[string][]string{}
+	var keys []string
+	for _, p := range paths {
+		k := foldPath(p)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		if !slices.Contains(groups[k], p) {
+			groups[k] = append(groups[k], p)
+		}
+	}
+	sort.Strings(keys)
+
+	var clashes []PathClash
+	for _, k := range keys {
+		ps := groups[k]
+		if len(ps) < 2 {
+			continue
+		}
+		kind := ClashCase
+		if norm.NFC.String(ps[0]) == norm.NFC.String(ps[1]) {
+			kind = ClashUnicode
+		}
+		clashes = append(clashes, PathClash{Kind: kind, Paths: ps})
+	}
+	return clashes
+}
+
+// IsCaseOrFormRename reports whether renaming src to dst only changes case
+// or unicode form, which a plain rename gets wrong on macOS
+func IsCaseOrFormRename(src, dst string) bool {
+	return src != dst && foldPath(src) == foldPath(dst)
+}
+
+// MovePath renames a tracked file like git mv, going through a temporary
+// name when only case or unicode form change so the filesystem can't treat
+// the rename as a no-op. If the file was already renamed outside git, the
+// rename is staged.
+func MovePath(g git.Service, src, dst string) error {
+	// Renamed already, outside git: record it
+	if !pathExists(src) && pathExists(dst) {
+		if _, err := g.Run("rm", "--cached", "--quiet", "--", src); err != nil {
+			return fmt.Errorf("failed to record the rename of %s: %w", src, err)
+		}
+		if _, err := g.Run("add", "--", dst); err != nil {
+			return fmt.Errorf("failed to record the rename to %s: %w", dst, err)
+		}
+		return nil
+	}
+
+	if !IsCaseOrFormRename(src, dst) {
+		if _, err := g.Run("mv", "--", src, dst); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+		}
+		return nil
+	}
+
+	tmp := dst + ".sage-mv"
+	for i := 1; pathExists(tmp); i++ {
+		tmp = fmt.Sprintf("%s.sage-mv%d", dst, i)
+	}
+	if _, err := g.Run("mv", "--", src, tmp); err != nil {
+		return fmt.Errorf("failed to move %s out of the way: %w", src, err)
+	}
+	if _, err := g.Run("mv", "--", tmp, dst); err != nil {
+		// Put it back rather than leave the temporary name behind
+		_, _ = g.Run("mv", "--", tmp, src)
+		return fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+func pathExists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+// IgnoreCaseMismatch checks core.ignorecase against the filesystem the
+// repository is on. It returns a description of the mismatch, or "" when
+// they agree.
+func IgnoreCaseMismatch(g git.Service) string {
+	gitDir, err := g.Run("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+
+	// HEAD always exists; if head finds the same file, case doesn't matter here
+	upper, err := os.Stat(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	lower, err := os.Stat(filepath.Join(gitDir, "head"))
+	insensitive := err == nil && os.SameFile(upper, lower)
+
+	value, _ := g.GetConfigValue("core.ignorecase")
+	ignoreCase := strings.TrimSpace(value) == "true"
+	switch {
+	case ignoreCase && !insensitive:
+		return "core.ignorecase is true but this filesystem is case-sensitive, so git will miss case-only renames; run 'git config core.ignorecase false'"
+	case !ignoreCase && insensitive:
+		return "core.ignorecase is false but this filesystem is case-insensitive, so case-only renames show up as a deleted and an untracked file; run 'git config core.ignorecase true'"
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// porcelainGit serves a fixed git status --porcelain
+type porcelainGit struct {
+	git.Service
+	status string
+}
+
+func (p porcelainGit) IsRepo() (bool, error)            { return true, nil }
+func (p porcelainGit) CurrentBranch() (string, error)   { return "main", nil }
+func (p porcelainGit) StatusPorcelain() (string, error) { return p.status, nil }
+
+func TestRepoStatusRenamesAndCopies(t *testing.T) {
+	tests := []struct {
+		name, line     string
+		file, symbol   string
+		staged, change bool
+	}{
+		{"rename", "R  old.go -> new.go", "new.go", "R", true, false},
+		{"rename then edited", "RM old.go -> new.go", "new.go", "R", true, true},
+		{"copy", "C  base.go -> copy.go", "copy.go", "C", true, false},
+		{"copy then edited", "CM base.go -> copy.go", "copy.go", "C", true, true},
+		{"case-only rename", "R  Foo.go -> foo.go", "foo.go", "R", true, false},
+		{"quoted unicode rename", `R  "cafe\314\201.txt" -> "caf\303\251.txt"`, "café.txt", "R", true, false},
+		{"new name with an arrow", `R  a.txt -> "b -> c.txt"`, "b -> c.txt", "R", true, false},
+		{"old name with an arrow", `R  "a -> b.txt" -> c.txt`, "c.txt", "R", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := GetRepoStatus(porcelainGit{status: tt.line + "\n"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(st.Changes) != 1 {
+				t.Fatalf("got %d changes, want 1", len(st.Changes))
+			}
+			c := st.Changes[0]
+			if c.File != tt.file || c.Symbol != tt.symbol || c.Staged != tt.staged || c.Unstaged != tt.change {
+				t.Errorf("got %+v, want %s %s staged=%v unstaged=%v", c, tt.symbol, tt.file, tt.staged, tt.change)
+			}
+		})
+	}
+}
+
+// TestRepoStatusDetectsEditedRename renames a file and changes part of it,
+// so git pairs the two by similarity rather than identical content
+func TestRepoStatusDetectsEditedRename(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("old.txt", "one\ntwo\nthree\nfour\nfive\nsix\n", "Add old")
+	r.git("mv", "old.txt", "new.txt")
+	r.write("new.txt", "one\ntwo\nthree\nfour\nfive\nSIX\n")
+	r.git("add", "new.txt")
+
+	st, err := GetRepoStatus(git.NewShellGit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Changes) != 1 || st.Changes[0].File != "new.txt" || st.Changes[0].Symbol != "R" {
+		t.Errorf("changes = %+v, want new.txt renamed", st.Changes)
+	}
+}
+
+func TestFindPathClashes(t *testing.T) {
+	nfc, nfd := "café.txt", "café.txt"
+	got := FindPathClashes([]string{"Foo.go", "bar.go", "foo.go", nfd, nfc, "foo.go"})
+	want := []PathClash{
+		{Kind: ClashUnicode, Paths: []string{nfd, nfc}},
+		{Kind: ClashCase, Paths: []string{"Foo.go", "foo.go"}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b PathClash) bool { return a.Kind == b.Kind && slices.Equal(a.Paths, b.Paths) }) {
+		t.Errorf("FindPathClashes = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		src, dst string
+		want     bool
+	}{
+		{"Foo.go", "foo.go", true},
+		{nfd, nfc, true},
+		{"foo.go", "foo.go", false},
+		{"foo.go", "bar.go", false},
+	}
+	for _, tt := range tests {
+		if got := IsCaseOrFormRename(tt.src, tt.dst); got != tt.want {
+			t.Errorf("IsCaseOrFormRename(%q, %q) = %v, want %v", tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func TestMovePathCaseOnly(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Foo.go", "package foo\n", "Add Foo")
+
+	if err := MovePath(git.NewShellGit(), "Foo.go", "foo.go"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("ls-files"); got != "README.md\nfoo.go" {
+		t.Errorf("tracked files = %q, want foo.go in place of Foo.go", got)
+	}
+	if _, err := os.Stat("foo.go.sage-mv"); !os.IsNotExist(err) {
+		t.Error("the temporary name was left behind")
+	}
+
+	// A rename made outside git is recorded as it stands
+	r.git("commit", "-q", "-m", "Rename")
+	if err := os.Rename("foo.go", "bar.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MovePath(git.NewShellGit(), "foo.go", "bar.go"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("status", "--porcelain"); got != "R  foo.go -> bar.go" {
+		t.Errorf("status = %q, want the rename staged", got)
+	}
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// reviewContextLines is how much of the diff above a comment is shown
+const reviewContextLines = 6
+
+// ReviewThreads returns the PR's review threads by file and line, leaving
+// out resolved ones unless all is set
+func ReviewThreads(ghc gh.Client, num int, all bool) ([]gh.ReviewThread, error) {
+	threads, err := ghc.ListReviewThreads(num)
+	if err != nil {
+		return nil, err
+	}
+	kept := threads[:0]
+	for _, t := range threads {
+		if (all || !t.Resolved) && len(t.Comments) > 0 {
+			kept = append(kept, t)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].Path != kept[j].Path {
+			return kept[i].Path < kept[j].Path
+		}
+		return kept[i].Line < kept[j].Line
+	})
+	return kept, nil
+}
+
+// ThreadContext returns the end of the diff hunk a thread is on: GitHub's
+// hunks stop at the commented line, so these are the lines leading to it
+func ThreadContext(t gh.ReviewThread) []string {
+	lines := strings.Split(strings.TrimRight(t.CodeContext, "\n"), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "@@") {
+		lines = lines[1:]
+	}
+	if len(lines) > reviewContextLines {
+		lines = lines[len(lines)-reviewContextLines:]
+	}
+	return lines
+}
+
+// ReplyToThread adds a comment to a thread. GitHub takes replies against the
+// comment that started it.
+func ReplyToThread(ghc gh.Client, num int, t gh.ReviewThread, body string) error {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return fmt.Errorf("reply is empty")
+	}
+	if len(t.Comments) == 0 {
+		return fmt.Errorf("thread on %s has no comments to reply to", t.Path)
+	}
+	return ghc.ReplyToReviewComment(num, t.Comments[0].ID, body)
+}
+
+// ResolveThread marks a thread resolved
+func ResolveThread(ghc gh.Client, t gh.ReviewThread) error {
+	if t.Resolved {
+		return nil
+	}
+	return ghc.ResolveReviewThread(t.ID)
+}
+
+// SubmitReview approves, requests changes on or comments on a PR
+func SubmitReview(ghc gh.Client, num int, event, body string) error {
+	return ghc.SubmitReview(num, event, strings.TrimSpace(body))
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+const (
+	// maxReviewerFiles caps how many changed files have their history read
+	maxReviewerFiles = 40
+	// reviewerHistoryDepth is how many past commits of each file are considered
+	reviewerHistoryDepth = 20
+	// reviewerHalfLife is how long it takes a past change to count half as much
+	reviewerHalfLife = 180 * 24 * time.Hour
+)
+
+// codeOwnersPaths are where GitHub looks for a CODEOWNERS file
+var codeOwnersPaths = []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}
+
+// noreplyEmail matches GitHub's private commit emails, which embed the login
+var noreplyEmail = regexp.MustCompile(`^(?:\d+\+)?([A-Za-z0-9-]+)@users\.noreply\.github\.com$`)
+
+// ReviewerSuggestion is someone who has worked on the files a branch changes
+type ReviewerSuggestion struct {
+	Login       string
+	Name        string
+	Files       int // changed files they have touched
+	Commits     int
+	LastTouched time.Time
+	Score       float64
+}
+
+// HasCodeOwners reports whether the repository has a CODEOWNERS file, in
+// which case GitHub picks reviewers itself
+func HasCodeOwners(g git.Service) bool {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return false
+	}
+	for _, p := range codeOwnersPaths {
+		if _, err := os.Stat(filepath.Join(root, p)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewerCandidate accumulates one author's history with the changed files
+type reviewerCandidate struct {
+	ReviewerSuggestion
+	email      string
+	lastCommit string
+	files      map[string]bool
+}
+
+// SuggestReviewers ranks the people who most recently and most often changed
+// the files that differ from base, skipping the current user and anyone
+// without access to the repository
+func SuggestReviewers(g git.Service, ghc gh.Client, base string, max int) ([]ReviewerSuggestion, error) {
+	out, err := g.Run("diff", "--name-only", base+"...HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	var files []string
+	for _, f := range strings.Split(strings.TrimSpace(out), "\n") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	if len(files) > maxReviewerFiles {
+		files = files[:maxReviewerFiles]
+	}
+	me, _ := g.GetConfigValue("user.email")
+	me = strings.ToLower(strings.TrimSpace(me))
+
+	// Read each file's history on the base branch in parallel
+	histories := make([][]git.Commit, len(files))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		wg.Add(1)
+		go func(i int, f string) {
+			defer wg.Done()
+			histories[i], _ = g.Commits(git.CommitsOptions{Range: base, Paths: []string{f}, Limit: reviewerHistoryDepth})
+		}(i, f)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	byEmail := map[string]*reviewerCandidate{}
+	for i, commits := range histories {
+		for _, c := range commits {
+			email := strings.ToLower(c.Email)
+			if email == "" || email == me {
+				continue
+			}
+			cand := byEmail[email]
+			if cand == nil {
+				cand = &reviewerCandidate{email: email, files: map[string]bool{}}
+				cand.Name = c.Author
+				byEmail[email] = cand
+			}
+			cand.Commits++
+			cand.files[files[i]] = true
+			cand.Score += math.Pow(0.5, float64(now.Sub(c.Date))/float64(reviewerHalfLife))
+			if c.Date.After(cand.LastTouched) {
+				cand.LastTouched = c.Date
+				cand.lastCommit = c.Hash
+			}
+		}
+	}
+
+	candidates := make([]*reviewerCandidate, 0, len(byEmail))
+	for _, cand := range byEmail {
+		cand.Files = len(cand.files)
+		candidates = append(candidates, cand)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].email < candidates[j].email
+	})
+
+	// Resolve GitHub logins for the best candidates, merging people who
+	// committed under several emails, until enough reviewers are found
+	if len(candidates) > max*4 {
+		candidates = candidates[:max*4]
+	}
+	var suggestions []ReviewerSuggestion
+	byLogin := map[string]int{}
+	for _, cand := range candidates {
+		login := ""
+		if m := noreplyEmail.FindStringSubmatch(cand.email); m != nil {
+			login = m[1]
+		} else if l, err := ghc.CommitAuthorLogin(cand.lastCommit); err == nil {
+			login = l
+		}
+		if login == "" || strings.HasSuffix(login, "[bot]") {
+			continue
+		}
+		if i, ok := byLogin[login]; ok {
+			s := &suggestions[i]
+			s.Score += cand.Score
+			s.Commits += cand.Commits
+			s.Files += cand.Files
+			if cand.LastTouched.After(s.LastTouched) {
+				s.LastTouched = cand.LastTouched
+			}
+			continue
+		}
+		if len(suggestions) >= max {
+			continue
+		}
+		if ok, err := ghc.IsCollaborator(login); err == nil && !ok {
+			continue
+		}
+		cand.Login = login
+		byLogin[login] = len(suggestions)
+		suggestions = append(suggestions, cand.ReviewerSuggestion)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	return suggestions, nil
+}
@@ -0,0 +1,245 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// ReviewSuggestion is a change a reviewer proposed in a ```suggestion block
+// of a review comment, which GitHub only lets you apply from the web
+type ReviewSuggestion struct {
+	Thread  gh.ReviewThread
+	Comment gh.ReviewComment
+	Path    string
+	Line    int      // first line it replaces, on the PR's head
+	Old     []string // the lines it replaces
+	New     []string // what it puts in their place; none deletes them
+}
+
+// Label names a suggestion for lists and prompts
+func (s ReviewSuggestion) Label() string {
+	lines := fmt.Sprintf("%d", s.Line)
+	if len(s.Old) > 1 {
+		lines = fmt.Sprintf("%d-%d", s.Line, s.Line+len(s.Old)-1)
+	}
+	return fmt.Sprintf("%s:%s from @%s", s.Path, lines, s.Comment.User)
+}
+
+var suggestionBlock = regexp.MustCompile("(?ms)^[ \t]*```suggestion[ \t]*\r?\n(.*?)^[ \t]*```")
+
+// parseSuggestion returns the lines of the first suggestion block in a
+// comment
+func parseSuggestion(body string) ([]string, bool) {
+	m := suggestionBlock.FindStringSubmatch(body)
+	if m == nil {
+		return nil, false
+	}
+	content := strings.TrimSuffix(strings.TrimSuffix(m[1], "\n"), "\r")
+	if content == "" {
+		return nil, true
+	}
+	return strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), true
+}
+
+// commentedLines returns the lines a thread is on, from the end of its diff
+// hunk: GitHub's hunks stop at the commented line
+func commentedLines(t gh.ReviewThread) ([]string, bool) {
+	n := 1
+	if t.StartLine > 0 && t.StartLine < t.Line {
+		n = t.Line - t.StartLine + 1
+	}
+	var head []string
+	for _, l := range strings.Split(strings.TrimRight(t.CodeContext, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"), strings.HasPrefix(l, "-"), strings.HasPrefix(l, `\`):
+			// Hunk headers, removed lines and "\ No newline at end of file"
+		case l == "":
+			head = append(head, "")
+		default:
+			head = append(head, l[1:])
+		}
+	}
+	if len(head) < n {
+		return nil, false
+	}
+	return head[len(head)-n:], true
+}
+
+// PendingSuggestions returns the suggestions in the PR's unresolved threads
+// that are still on the current code, by file and line
+func PendingSuggestions(ghc gh.Client, num int) ([]ReviewSuggestion, error) {
+	threads, err := ReviewThreads(ghc, num, false)
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []ReviewSuggestion
+	for _, t := range threads {
+		if t.Outdated {
+			continue
+		}
+		old, ok := commentedLines(t)
+		if !ok {
+			continue
+		}
+		for _, c := range t.Comments {
+			if lines, ok := parseSuggestion(c.Body); ok {
+				suggestions = append(suggestions, ReviewSuggestion{
+					Thread: t, Comment: c, Path: t.Path, Line: t.Line - len(old) + 1, Old: old, New: lines,
+				})
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// ApplySuggestion makes the suggested change to the file in the working tree
+// under root. The lines it replaces are looked for by content, nearest to
+// where the PR has them, so local commits that moved them don't matter.
+func ApplySuggestion(root string, s ReviewSuggestion) error {
+	path := filepath.Join(root, filepath.FromSlash(s.Path))
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+	lines := strings.Split(string(b), "\n")
+	at := findLines(lines, s.Old, s.Line-1)
+	if at < 0 {
+		return fmt.Errorf("%s no longer has the lines the suggestion replaces", s.Path)
+	}
+	if dryrun.Enabled() {
+		dryrun.Record("apply @%s's suggestion to %s", s.Comment.User, s.Label())
+		return nil
+	}
+
+	replacement := make([]string, len(s.New))
+	crlf := strings.HasSuffix(lines[at], "\r")
+	for i, l := range s.New {
+		if crlf {
+			l += "\r"
+		}
+		replacement[i] = l
+	}
+	out := append(append(append([]string{}, lines[:at]...), replacement...), lines[at+len(s.Old):]...)
+	if err := os.WriteFile(path, []byte(strings.Join(out, "\n")), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// findLines returns where want starts in lines, taking the match nearest to
+// hint when there are several, or -1
+func findLines(lines, want []string, hint int) int {
+	best := -1
+	for i := 0; i+len(want) <= len(lines); i++ {
+		match := true
+		for j, w := range want {
+			if strings.TrimSuffix(lines[i+j], "\r") != strings.TrimSuffix(w, "\r") {
+				match = false
+				break
+			}
+		}
+		if match && (best < 0 || distance(i, hint) < distance(best, hint)) {
+			best = i
+		}
+	}
+	return best
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// suggestionMessage is the commit message for an applied suggestion,
+// crediting the reviewer the way GitHub does
+func suggestionMessage(s ReviewSuggestion) string {
+	msg := fmt.Sprintf("Apply suggestion from @%s on %s", s.Comment.User, s.Path)
+	if s.Comment.UserID == 0 {
+		return msg
+	}
+	name := s.Comment.UserName
+	if name == "" {
+		name = s.Comment.User
+	}
+	return fmt.Sprintf("%s\n\nCo-authored-by: %s <%d+%s@users.noreply.github.com>\n", msg, name, s.Comment.UserID, s.Comment.User)
+}
+
+// SuggestionResult is what became of one suggestion
+type SuggestionResult struct {
+	Suggestion ReviewSuggestion
+	Err        error
+	Resolved   bool
+}
+
+// ApplySuggestions applies the suggestions to the working tree, or with
+// commit as a commit each co-authored by the reviewer, and resolves their
+// threads unless told not to. They're applied from the bottom of each file
+// up, so the line numbers of the rest still hold.
+func ApplySuggestions(g git.Service, ghc gh.Client, suggestions []ReviewSuggestion, commit, resolve bool) ([]SuggestionResult, error) {
+	root, err := g.Run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the repository root: %w", err)
+	}
+	root = strings.TrimSpace(root)
+
+	ordered := append([]ReviewSuggestion{}, suggestions...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Path != ordered[j].Path {
+			return ordered[i].Path < ordered[j].Path
+		}
+		return ordered[i].Line > ordered[j].Line
+	})
+
+	resolved := map[string]bool{}
+	results := make([]SuggestionResult, 0, len(ordered))
+	for _, s := range ordered {
+		r := SuggestionResult{Suggestion: s}
+		r.Err = applySuggestion(g, root, s, commit)
+		if r.Err == nil && resolve && !resolved[s.Thread.ID] {
+			if err := ResolveThread(ghc, s.Thread); err != nil {
+				r.Err = fmt.Errorf("applied, but failed to resolve the thread: %w", err)
+			} else {
+				resolved[s.Thread.ID], r.Resolved = true, true
+			}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// applySuggestion applies one suggestion, committing it on its own when
+// commit is set. A file with uncommitted changes can't be committed without
+// taking them along, so it's refused.
+func applySuggestion(g git.Service, root string, s ReviewSuggestion, commit bool) error {
+	if commit {
+		if out, err := g.Run("status", "--porcelain", "--", s.Path); err != nil {
+			return err
+		} else if strings.TrimSpace(out) != "" {
+			return fmt.Errorf("%s has uncommitted changes; commit or stash them, or apply without --commit", s.Path)
+		}
+	}
+	if err := ApplySuggestion(root, s); err != nil {
+		return err
+	}
+	if !commit {
+		return nil
+	}
+	if err := g.CommitPaths(suggestionMessage(s), false, []string{s.Path}); err != nil {
+		return fmt.Errorf("failed to commit the suggestion: %w", err)
+	}
+	return nil
+}
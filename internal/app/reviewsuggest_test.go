@@ -0,0 +1,113 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// fakeReviewGitHub serves review threads and records the ones resolved
+type fakeReviewGitHub struct {
+	gh.Client
+	threads  []gh.ReviewThread
+	resolved []string
+}
+
+func (f *fakeReviewGitHub) ListReviewThreads(num int) ([]gh.ReviewThread, error) {
+	return f.threads, nil
+}
+
+func (f *fakeReviewGitHub) ResolveReviewThread(id string) error {
+	f.resolved = append(f.resolved, id)
+	return nil
+}
+
+func TestParseSuggestion(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+		ok   bool
+	}{
+		{"Try this:\n```suggestion\nreturn nil\n```\nThoughts?", []string{"return nil"}, true},
+		{"```suggestion\r\na\r\nb\r\n```", []string{"a", "b"}, true},
+		{"Drop these\n```suggestion\n```", nil, true},
+		{"```go\nreturn nil\n```", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSuggestion(tt.body)
+		if ok != tt.ok || strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("parseSuggestion(%q) = %q, %v; want %q, %v", tt.body, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPendingSuggestions(t *testing.T) {
+	fake := &fakeReviewGitHub{threads: []gh.ReviewThread{
+		{ID: "T1", Path: "main.go", Line: 4, StartLine: 3, CodeContext: "@@ -1,4 +1,4 @@\n package main\n-old\n+func a() {}\n+func b() {}",
+			Comments: []gh.ReviewComment{{User: "rae", Body: "```suggestion\nfunc ab() {}\n```"}, {User: "me", Body: "Sure"}}},
+		{ID: "T2", Path: "main.go", Line: 9, Outdated: true, CodeContext: "@@ -9 +9 @@\n+x",
+			Comments: []gh.ReviewComment{{User: "rae", Body: "```suggestion\ny\n```"}}},
+		{ID: "T3", Path: "b.go", Line: 1, CodeContext: "@@ -1 +1 @@\n+x",
+			Comments: []gh.ReviewComment{{User: "rae", Body: "Just a question"}}},
+	}}
+
+	suggestions, err := PendingSuggestions(fake, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("suggestions = %+v, want only the one on a current thread", suggestions)
+	}
+	s := suggestions[0]
+	if s.Line != 3 || strings.Join(s.Old, "|") != "func a() {}|func b() {}" || strings.Join(s.New, "|") != "func ab() {}" {
+		t.Errorf("suggestion = %+v", s)
+	}
+	if s.Label() != "main.go:3-4 from @rae" {
+		t.Errorf("label = %q", s.Label())
+	}
+}
+
+func TestApplySuggestionsAsCommits(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("list.txt", "one\ntwo\nthree\nfour\ntwo\n", "Add list")
+	// The branch moved on since the review, shifting every line down
+	r.commit("list.txt", "zero\none\ntwo\nthree\nfour\ntwo\n", "Add zero")
+
+	fake := &fakeReviewGitHub{}
+	rae := gh.ReviewComment{User: "rae", UserID: 42, UserName: "Rae Viewer"}
+	suggestions := []ReviewSuggestion{
+		{Thread: gh.ReviewThread{ID: "T1"}, Comment: rae, Path: "list.txt", Line: 2, Old: []string{"two"}, New: []string{"2"}},
+		{Thread: gh.ReviewThread{ID: "T2"}, Comment: rae, Path: "list.txt", Line: 4, Old: []string{"four"}},
+		{Thread: gh.ReviewThread{ID: "T3"}, Comment: rae, Path: "list.txt", Line: 1, Old: []string{"five"}, New: []string{"5"}},
+	}
+
+	results, err := ApplySuggestions(git.NewShellGit(), fake, suggestions, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("results = %+v, want only the suggestion for missing lines to fail", results)
+	}
+	if got := r.read("list.txt"); got != "zero\none\n2\nthree\ntwo\n" {
+		t.Errorf("list.txt = %q; the nearest 'two' should be replaced and 'four' dropped", got)
+	}
+	if got := r.git("rev-list", "--count", "HEAD~2..HEAD"); got != "2" {
+		t.Errorf("%s commits, want one per applied suggestion", got)
+	}
+	msg := r.git("log", "-1", "--format=%B")
+	if !strings.HasPrefix(msg, "Apply suggestion from @rae on list.txt") || !strings.Contains(msg, "Co-authored-by: Rae Viewer <42+rae@users.noreply.github.com>") {
+		t.Errorf("message:\n%s", msg)
+	}
+	if strings.Join(fake.resolved, ",") != "T2,T1" {
+		t.Errorf("resolved %v, want the applied suggestions' threads", fake.resolved)
+	}
+	r.assertClean()
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// RewordOptions configures Reword
+type RewordOptions struct {
+	// Commit is the commit to reword, HEAD when empty. It must be on the
+	// current branch.
+	Commit string
+	// Message is the new message. Without one it's generated with UseAI, or
+	// written in the editor starting from the current message.
+	Message string
+	// UseAI rewrites the message from the commit's diff and current message
+	UseAI bool
+	// SubjectOnly replaces only the subject line, with Message or with AI,
+	// keeping the body
+	SubjectOnly bool
+	// AutoAccept takes the AI message without asking
+	AutoAccept bool
+}
+
+// RewordResult is the outcome of Reword
+type RewordResult struct {
+	// Hash is the reworded commit's new hash
+	Hash string
+	// Previous is the message it had
+	Previous string
+	// Message is the message it has now
+	Message string
+}
+
+// Reword changes the message of a commit on the current branch without
+// touching its changes, the working tree or the index.
+func Reword(g git.Service, opts RewordOptions) (RewordResult, error) {
+	var result RewordResult
+	if opts.Commit == "" {
+		opts.Commit = "HEAD"
+	}
+	if opts.UseAI && !config.AIEnabled("") {
+		return result, fmt.Errorf("AI features are disabled on this branch (ai.enabled=false)")
+	}
+
+	previous, err := g.Run("log", "-1", "--format=%B", opts.Commit)
+	if err != nil {
+		return result, fmt.Errorf("failed to read commit %s: %w", opts.Commit, err)
+	}
+	result.Previous = strings.TrimSpace(previous)
+
+	msg := opts.Message
+	if msg == "" {
+		if !opts.UseAI && batch.Enabled() {
+			return result, batch.NeedsInput("sage reword needs a message", "pass one, or --ai to generate it")
+		}
+		diff, err := g.Run("show", "--format=", opts.Commit)
+		if err != nil {
+			return result, fmt.Errorf("failed to get diff: %w", err)
+		}
+		if opts.UseAI {
+			msg, err = suggestMessage(diff, result.Previous, opts.SubjectOnly)
+			if errors.Is(err, context.Canceled) {
+				return result, fmt.Errorf("reword cancelled")
+			}
+			if err != nil {
+				return result, err
+			}
+			if !opts.AutoAccept && !batch.Enabled() {
+				if msg, err = confirmReword(g, msg, diff); err != nil {
+					return result, err
+				}
+			}
+		} else if msg, err = composeMessageInEditor(g, result.Previous, diff, false); err != nil {
+			return result, err
+		}
+	} else if opts.SubjectOnly {
+		if _, body, ok := strings.Cut(result.Previous, "\n"); ok && strings.TrimSpace(body) != "" {
+			msg = strings.TrimSpace(msg) + "\n\n" + strings.TrimSpace(body)
+		}
+	}
+	msg = strings.TrimSpace(msg)
+
+	if msg == "" {
+		return result, fmt.Errorf("aborting reword due to empty message")
+	}
+	if msg == result.Previous {
+		return result, fmt.Errorf("the message is unchanged")
+	}
+	if err := checkCommitMessage(msg); err != nil {
+		return result, err
+	}
+
+	JournalSnapshot(g, "sage reword")
+	if result.Hash, err = g.RewordCommit(opts.Commit, msg); err != nil {
+		return result, fmt.Errorf("failed to reword %s: %w", opts.Commit, err)
+	}
+	result.Message = msg
+	return result, nil
+}
+
+// confirmReword shows the generated message and lets the user take it, edit
+// it first or give up
+func confirmReword(g git.Service, msg, diff string) (string, error) {
+	fmt.Printf("%s\n%s\n\n", ui.Bold("Generated commit message:"), msg)
+	var choice string
+	if err := survey.AskOne(&survey.Select{
+		Message: "Choose an option:",
+		Options: []string{"Accept", "Edit", "Cancel"},
+	}, &choice); err != nil {
+		return "", err
+	}
+	switch choice {
+	case "Edit":
+		return composeMessageInEditor(g, msg, diff, false)
+	case "Cancel":
+		return "", fmt.Errorf("reword cancelled")
+	}
+	return msg, nil
+}
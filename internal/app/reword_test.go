@@ -0,0 +1,63 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestRewordOlderCommit(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "a\n", "Add a")
+	target := r.rev("HEAD")
+	r.commit("b.txt", "b\n", "Add b")
+	r.git("merge", "--no-ff", "-q", "-m", "Merge side", r.git("commit-tree", "-p", "HEAD", "-m", "Side", r.rev("HEAD")+"^{tree}"))
+	r.commit("c.txt", "c\n", "Add c")
+	r.write("c.txt", "uncommitted\n")
+	tree := r.rev("HEAD^{tree}")
+
+	res, err := Reword(git.NewShellGit(), RewordOptions{Commit: target, Message: "feat: add a"})
+	if err != nil {
+		t.Fatalf("Reword: %v", err)
+	}
+	if res.Previous != "Add a" || res.Hash == target {
+		t.Errorf("result = %+v", res)
+	}
+	if got := r.git("log", "--first-parent", "--format=%s", "-4"); got != "Add c\nMerge side\nAdd b\nfeat: add a" {
+		t.Errorf("history =\n%s", got)
+	}
+	if !r.isAncestor(res.Hash, "HEAD") || r.rev("HEAD^{tree}") != tree {
+		t.Error("the commits after the reworded one weren't rebuilt on it unchanged")
+	}
+	if r.read("c.txt") != "uncommitted\n" {
+		t.Error("working tree changes were lost")
+	}
+}
+
+func TestRewordSubjectOnlyKeepsBody(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "a\n", "Add a\n\nA hand-written explanation.")
+
+	if _, err := Reword(git.NewShellGit(), RewordOptions{Message: "feat: add a", SubjectOnly: true}); err != nil {
+		t.Fatalf("Reword: %v", err)
+	}
+	if got := r.git("log", "-1", "--format=%B"); got != "feat: add a\n\nA hand-written explanation." {
+		t.Errorf("message = %q", got)
+	}
+
+	if _, err := Reword(git.NewShellGit(), RewordOptions{Message: "feat: add a\n\nA hand-written explanation."}); err == nil {
+		t.Error("rewording to the same message succeeded")
+	}
+}
+
+func TestRewordRefusesCommitsOffBranch(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-q", "-b", "other")
+	r.commit("other.txt", "x\n", "Elsewhere")
+	other := r.rev("HEAD")
+	r.git("checkout", "-q", "main")
+
+	if _, err := Reword(git.NewShellGit(), RewordOptions{Commit: other, Message: "Moved"}); err == nil {
+		t.Error("reworded a commit that isn't on the current branch")
+	}
+}
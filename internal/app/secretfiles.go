@@ -0,0 +1,154 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// defaultBlockedFiles are the globs refused when secrets.blocked_files isn't set
+var defaultBlockedFiles = []string{".env*", "*.pem", "id_rsa*"}
+
+// secretAllowFile lists files that may be committed despite matching a
+// blocked glob, one path or glob per line
+const secretAllowFile = ".sage/secret-allow"
+
+// blockedFileGlobs returns the configured secrets.blocked_files globs
+func blockedFileGlobs() []string {
+	raw := config.Get("secrets.blocked_files", true)
+	if raw == "" {
+		return defaultBlockedFiles
+	}
+	var globs []string
+	for _, g := range strings.Split(raw, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+// loadSecretAllow reads .sage/secret-allow, skipping blank lines and comments
+func loadSecretAllow(g git.Service) []string {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(root, secretAllowFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var allow []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			allow = append(allow, line)
+		}
+	}
+	return allow
+}
+
+// matchesFileGlob matches a glob against the whole path or, for globs without
+// a slash, against the file name alone
+func matchesFileGlob(glob, p string) bool {
+	if ok, _ := path.Match(glob, p); ok {
+		return true
+	}
+	if !strings.Contains(glob, "/") {
+		ok, _ := path.Match(glob, path.Base(p))
+		return ok
+	}
+	return false
+}
+
+// FindBlockedFiles returns the paths that match secrets.blocked_files and
+// aren't allowed by .sage/secret-allow
+func FindBlockedFiles(g git.Service, paths []string) []string {
+	globs := blockedFileGlobs()
+	allow := loadSecretAllow(g)
+
+	var blocked []string
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		isBlocked := false
+		for _, glob := range globs {
+			if matchesFileGlob(glob, p) {
+				isBlocked = true
+				break
+			}
+		}
+		for _, a := range allow {
+			if isBlocked && matchesFileGlob(a, p) {
+				isBlocked = false
+			}
+		}
+		if isBlocked {
+			blocked = append(blocked, p)
+		}
+	}
+	return blocked
+}
+
+// blockedFilesError explains why files were refused and how to allow them
+func blockedFilesError(blocked []string) error {
+	return fmt.Errorf("refusing to commit files that usually hold secrets:\n  %s\n\n"+
+		"Add them to .gitignore, or list them in %s if they are safe to commit",
+		strings.Join(blocked, "\n  "), secretAllowFile)
+}
+
+// CheckBlockedFiles returns an error naming any of paths that must not be staged
+func CheckBlockedFiles(g git.Service, paths []string) error {
+	if blocked := FindBlockedFiles(g, paths); len(blocked) > 0 {
+		return blockedFilesError(blocked)
+	}
+	return nil
+}
+
+// StageAll stages every change like git add ., but first refuses if any
+// changed file matches secrets.blocked_files
+func StageAll(g git.Service) error {
+	status, err := g.StatusPorcelain()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+	var paths []string
+	for _, line := range porcelainLines(status) {
+		if len(line) < 4 || line[1] == 'D' {
+			continue
+		}
+		p := strings.TrimSpace(line[3:])
+		if i := strings.Index(p, " -> "); i >= 0 {
+			p = p[i+4:]
+		}
+		paths = append(paths, strings.Trim(p, `"`))
+	}
+	if err := CheckBlockedFiles(g, paths); err != nil {
+		return err
+	}
+	return g.StageAll()
+}
+
+// CheckStagedFiles refuses a commit when the index holds a blocked file,
+// however it got staged
+func CheckStagedFiles(g git.Service) error {
+	out, err := g.Run("diff", "--cached", "--name-only", "--diff-filter=ACMR")
+	if err != nil {
+		return fmt.Errorf("failed to list staged files: %w", err)
+	}
+	var paths []string
+	for _, p := range strings.Split(strings.TrimSpace(out), "\n") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return CheckBlockedFiles(g, paths)
+}
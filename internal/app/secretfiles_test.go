@@ -0,0 +1,26 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestStageAllRefusesModifiedEnvFile(t *testing.T) {
+	r := newTestRepo(t)
+	// Committed before the check existed; a change to it still mustn't be staged
+	r.commit(".env", "TOKEN=old\n", "Add env")
+
+	// .env sorts first in the status, as " M .env"
+	r.write(".env", "TOKEN=new\n")
+	r.write("README.md", "hello again\n")
+
+	err := StageAll(git.NewShellGit())
+	if err == nil || !strings.Contains(err.Error(), ".env") {
+		t.Fatalf("StageAll = %v, want .env refused", err)
+	}
+	if got := r.git("diff", "--cached", "--name-only"); got != "" {
+		t.Errorf("staged %q, want nothing", got)
+	}
+}
@@ -43,6 +43,8 @@ func SquashCommits(g git.Service, startCommit string, all bool) error {
 		return fmt.Errorf("no start commit specified")
 	}
 
+	JournalSnapshot(g, "sage squash")
+
 	// Start interactive rebase
 	if err := g.SquashCommits(startCommit); err != nil {
 		return fmt.Errorf("failed to start interactive rebase: %w", err)
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// maxSquashCommitsPrompt caps how much of the commit log is sent to the AI
+const maxSquashCommitsPrompt = 12000
+
+var (
+	htmlComment       = regexp.MustCompile(`(?s)<!--.*?-->`)
+	uncheckedCheckbox = regexp.MustCompile(`^\s*[-*] \[ \]`)
+	markdownHeading   = regexp.MustCompile(`^#{1,6}\s`)
+)
+
+// SquashMessage is the commit a squash merge creates
+type SquashMessage struct {
+	Title string
+	Body  string
+}
+
+// ComposeSquashMessage builds the squash commit for pr from its title, with
+// the PR number appended as GitHub does, and its cleaned-up description
+func ComposeSquashMessage(pr *gh.PullRequest) SquashMessage {
+	title := strings.TrimSpace(pr.Title)
+	if suffix := fmt.Sprintf("(#%d)", pr.Number); !strings.HasSuffix(title, suffix) {
+		title += " " + suffix
+	}
+	return SquashMessage{Title: title, Body: CleanPRBody(pr.Body)}
+}
+
+// CleanPRBody drops what doesn't belong in a commit message from a PR
+// description: HTML comments, unchecked template checkboxes, and headings
+// left with nothing under them
+func CleanPRBody(body string) string {
+	body = htmlComment.ReplaceAllString(strings.ReplaceAll(body, "\r\n", "\n"), "")
+
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		if uncheckedCheckbox.MatchString(line) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+
+	// Drop headings followed only by blank lines and the next heading
+	var kept []string
+	for i, line := range lines {
+		if markdownHeading.MatchString(line) {
+			empty := true
+			for _, next := range lines[i+1:] {
+				if markdownHeading.MatchString(next) {
+					break
+				}
+				if next != "" {
+					empty = false
+					break
+				}
+			}
+			if empty {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	// Collapse the blank lines left behind
+	var out []string
+	for _, line := range kept {
+		if line == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// ValidateSquashTitle checks that a squash commit title is a conventional
+// commit, type(scope): description
+func ValidateSquashTitle(title string) error {
+	if !conventionalSubject.MatchString(title) {
+		return fmt.Errorf("squash commit title %q is not a conventional commit (type(scope): description)", title)
+	}
+	return nil
+}
+
+// SummarizeSquashCommits asks the AI for a commit message body describing
+// what the PR's commits do as a whole
+func SummarizeSquashCommits(ghc gh.Client, client *ai.Client, pr *gh.PullRequest) (string, error) {
+	commits, err := ghc.ListPRCommits(pr.Number)
+	if err != nil {
+		return "", fmt.Errorf("failed to list the PR's commits: %w", err)
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("PR #%d has no commits", pr.Number)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pull request: %s\n\nCommits, oldest first:\n", pr.Title)
+	for _, c := range commits {
+		fmt.Fprintf(&b, "- %s\n", c.Subject)
+		if c.Body != "" {
+			fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(c.Body, "\n", "\n  "))
+		}
+	}
+
+	return client.ForTask(ai.TaskCommit).Complete(
+		`You write the body of a squash merge commit from the commits being squashed. Describe what the change does as a whole in a short paragraph, optionally followed by a few "- " bullet points for notable details. Leave out fixups, review back-and-forth and anything later commits undid. Plain text wrapped at 72 columns, no headings, no title line.`,
+		truncateForPrompt(b.String(), maxSquashCommitsPrompt),
+	)
+}
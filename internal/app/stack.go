@@ -0,0 +1,203 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/stack"
+)
+
+// StackCreate creates branch from the current branch, switches to it and
+// records it as stacked on the current branch
+func StackCreate(g git.Service, branch string) error {
+	parent, err := g.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	st, err := stack.Load(g)
+	if err != nil {
+		return err
+	}
+	base, err := g.Run("rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if err := st.Add(branch, parent, strings.TrimSpace(base)); err != nil {
+		return err
+	}
+	if err := g.CreateBranch(branch); err != nil {
+		return err
+	}
+	if err := g.Checkout(branch); err != nil {
+		return err
+	}
+	return st.Save(g)
+}
+
+// currentStack loads the stack and returns the root of the one the current
+// branch belongs to
+func currentStack(g git.Service) (*stack.Stack, string, string, error) {
+	cur, err := g.CurrentBranch()
+	if err != nil {
+		return nil, "", "", err
+	}
+	st, err := stack.Load(g)
+	if err != nil {
+		return nil, "", "", err
+	}
+	root := st.Root(cur)
+	if len(st.Descendants(root)) == 0 {
+		return nil, "", "", fmt.Errorf("%s isn't part of a stack; start one with 'sage stack create <branch>'", cur)
+	}
+	return st, cur, root, nil
+}
+
+// RestackResult is what happened to one branch during a stack sync
+type RestackResult struct {
+	Branch   string
+	Parent   string
+	Rebased  bool
+	Reparent string // the parent it used to have, when that branch was gone
+}
+
+// StackSync rebases every branch in the current stack whose parent has moved
+// onto the parent's new tip, parents first. Branches whose parent was deleted
+// move onto the next branch down. It stops at the first conflict, leaving the
+// rebase for the user to finish before running it again.
+func StackSync(g git.Service) ([]RestackResult, error) {
+	st, cur, root, err := currentStack(g)
+	if err != nil {
+		return nil, err
+	}
+	if clean, err := g.IsClean(); err != nil || !clean {
+		return nil, exitcode.Errorf(exitcode.DirtyTree, "commit or stash your changes before restacking")
+	}
+
+	// Drop branches that were deleted, moving their children down the stack
+	gone := map[string]string{}
+	for _, branch := range st.Descendants(root) {
+		if refExists(g, "refs/heads/"+branch) {
+			continue
+		}
+		for _, child := range st.Children(branch) {
+			gone[child] = branch
+		}
+		st.Remove(branch)
+	}
+
+	var results []RestackResult
+	for _, branch := range st.Descendants(root) {
+		res := RestackResult{Branch: branch, Reparent: gone[branch]}
+		entry := st.Branches[branch]
+		res.Parent = entry.Parent
+
+		out, err := g.Run("rev-parse", entry.Parent)
+		if err != nil {
+			return results, fmt.Errorf("failed to read %s: %w", entry.Parent, err)
+		}
+		tip := strings.TrimSpace(out)
+		if tip == entry.Base {
+			results = append(results, res)
+			continue
+		}
+		// Already contains the parent's tip, e.g. after finishing a rebase by hand
+		if _, err := g.Run("merge-base", "--is-ancestor", tip, branch); err == nil {
+			st.SetBase(branch, tip)
+			results = append(results, res)
+			continue
+		}
+
+		args := []string{"rebase", "--onto", tip, entry.Base, branch}
+		if entry.Base == "" {
+			args = []string{"rebase", tip, branch}
+		}
+		if err := g.RunInteractive(args[0], args[1:]...); err != nil {
+			_ = st.Save(g)
+			return results, exitcode.Errorf(exitcode.Conflict, "restacking %s onto %s stopped: %w\n"+
+				"Resolve the conflicts, run 'git rebase --continue', then 'sage stack sync' again", branch, entry.Parent, err)
+		}
+		st.SetBase(branch, tip)
+		res.Rebased = true
+		results = append(results, res)
+	}
+
+	if err := st.Save(g); err != nil {
+		return results, err
+	}
+	if err := g.Checkout(cur); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// StackSubmitResult is the pull request for one branch of a submitted stack
+type StackSubmitResult struct {
+	Branch     string
+	PR         *gh.PullRequest
+	Created    bool
+	Retargeted bool // an existing PR's base was changed
+}
+
+// StackSubmit pushes every branch of the current stack and opens or updates
+// their pull requests so each one targets the branch below it
+func StackSubmit(g git.Service, ghc gh.Client, draft bool) ([]StackSubmitResult, error) {
+	st, _, root, err := currentStack(g)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []StackSubmitResult
+	for _, branch := range st.Descendants(root) {
+		parent, _ := st.Parent(branch)
+		if err := g.PushWithLease(branch); err != nil {
+			return results, fmt.Errorf("failed to push %s: %w", branch, err)
+		}
+
+		res := StackSubmitResult{Branch: branch}
+		pr, err := ghc.GetPRForBranch(branch)
+		if err != nil {
+			return results, fmt.Errorf("failed to look up the PR for %s: %w", branch, err)
+		}
+		switch {
+		case pr == nil:
+			title := branch
+			if commits, err := g.Commits(git.CommitsOptions{Range: parent + ".." + branch}); err == nil && len(commits) > 0 {
+				title = commits[len(commits)-1].Subject
+			}
+			body := ""
+			if parentPR := prFor(results, parent); parentPR != nil {
+				body = fmt.Sprintf("Stacked on #%d.", parentPR.Number)
+			}
+			if pr, err = ghc.CreatePR(title, body, branch, parent, draft); err != nil {
+				return results, fmt.Errorf("failed to create a PR for %s: %w", branch, err)
+			}
+			res.Created = true
+		case pr.Base.Ref != parent:
+			pr.Base.Ref = parent
+			if dryrun.Enabled() {
+				dryrun.Record("retarget PR #%d onto %s", pr.Number, parent)
+			} else if err := ghc.UpdatePR(pr.Number, pr); err != nil {
+				return results, fmt.Errorf("failed to retarget PR #%d onto %s: %w", pr.Number, parent, err)
+			}
+			res.Retargeted = true
+		}
+		_ = CachePR(g, branch, pr)
+		res.PR = pr
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// prFor returns the pull request already submitted for branch, if any
+func prFor(results []StackSubmitResult, branch string) *gh.PullRequest {
+	for _, r := range results {
+		if r.Branch == branch {
+			return r.PR
+		}
+	}
+	return nil
+}
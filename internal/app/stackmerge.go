@@ -0,0 +1,381 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/stack"
+)
+
+// StackMerge is a 'sage stack merge' in progress. It is saved to
+// .git/.sage/stack-merge.json after every step, so a merge that stops on a
+// failed check or a conflict can carry on where it left off.
+type StackMerge struct {
+	Root     string   `json:"root"`     // the branch the stack merges into
+	Branches []string `json:"branches"` // bottom to top
+	Method   string   `json:"method"`
+	Original string   `json:"original"` // the branch checked out when it started
+
+	Next int `json:"next"` // index of the first branch not yet merged
+	// Cleanup is a merged branch whose children haven't been restacked
+	// and retargeted yet, and which hasn't been deleted
+	Cleanup string `json:"cleanup,omitempty"`
+
+	Merged  []MergedPR `json:"merged"`
+	Deleted []string   `json:"deleted"`
+}
+
+// MergedPR is a pull request a stack merge merged
+type MergedPR struct {
+	Branch string `json:"branch"`
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// StackMergeOptions say how long to wait for each PR's checks
+type StackMergeOptions struct {
+	Timeout time.Duration // give up on a PR whose checks take longer
+	Poll    time.Duration // how often to look at the checks
+}
+
+func stackMergePath(g git.Service) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "stack-merge.json"), nil
+}
+
+// LoadStackMerge returns the stack merge in progress, or nil if there is none
+func LoadStackMerge(g git.Service) (*StackMerge, error) {
+	path, err := stackMergePath(g)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the stack merge: %w", err)
+	}
+	var m StackMerge
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse the stack merge: %w", err)
+	}
+	return &m, nil
+}
+
+func (m *StackMerge) save(g git.Service) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	path, err := stackMergePath(g)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to save the stack merge: %w", err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// AbortStackMerge forgets the stack merge in progress. What was merged
+// stays merged.
+func AbortStackMerge(g git.Service) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	path, err := stackMergePath(g)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StartStackMerge plans merging branch and everything below it in its
+// stack, bottom up. Every branch needs an open PR, and none may have changes
+// requested.
+func StartStackMerge(g git.Service, ghc gh.Client, branch, method string) (*StackMerge, error) {
+	if existing, err := LoadStackMerge(g); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("already merging the stack under %s; use --continue or --abort", existing.Branches[len(existing.Branches)-1])
+	}
+	if clean, err := g.IsClean(); err != nil {
+		return nil, err
+	} else if !clean {
+		return nil, exitcode.Errorf(exitcode.DirtyTree, "commit or stash your changes before merging the stack")
+	}
+	cur, err := g.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" {
+		branch = cur
+	}
+	st, err := stack.Load(g)
+	if err != nil {
+		return nil, err
+	}
+	chain := st.Chain(branch)
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s isn't part of a stack; merge it with 'sage pr merge'", branch)
+	}
+
+	var problems []string
+	for _, b := range chain {
+		pr, err := ghc.GetPRForBranch(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the PR for %s: %w", b, err)
+		}
+		if pr == nil {
+			problems = append(problems, fmt.Sprintf("%s has no open PR; run 'sage stack submit'", b))
+			continue
+		}
+		reviews, err := ghc.ListPRReviews(pr.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the reviews of #%d: %w", pr.Number, err)
+		}
+		for _, r := range LatestReviews(reviews) {
+			if r.State == "CHANGES_REQUESTED" {
+				problems = append(problems, fmt.Sprintf("#%d (%s): @%s requested changes", pr.Number, b, r.User.Login))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("the stack isn't ready to merge:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	m := &StackMerge{Root: st.Root(branch), Branches: chain, Method: method, Original: cur}
+	return m, m.save(g)
+}
+
+// RunStackMerge merges the remaining PRs of m bottom up. After each merge
+// the branches above are restacked onto the updated root, pushed, and their
+// PRs pointed at it, then the merged branch is deleted. It waits for each
+// PR's checks before merging it, and stops with m saved when they fail, a
+// restack conflicts or a merge is refused; run it again to carry on.
+func RunStackMerge(g git.Service, ghc gh.Client, m *StackMerge, opts StackMergeOptions, report func(string)) error {
+	if cur, err := g.CurrentBranch(); err != nil {
+		return err
+	} else if cur != m.Root {
+		if err := g.Checkout(m.Root); err != nil {
+			return fmt.Errorf("failed to switch to %s: %w", m.Root, err)
+		}
+	}
+
+	if m.Cleanup != "" {
+		if err := finishStackMerge(g, ghc, m, report); err != nil {
+			return err
+		}
+	}
+	for m.Next < len(m.Branches) {
+		branch := m.Branches[m.Next]
+		merged, err := mergeStackPR(g, ghc, m, branch, opts, report)
+		if err != nil {
+			return err
+		}
+		m.Merged = append(m.Merged, merged)
+		m.Next++
+		m.Cleanup = branch
+		if err := m.save(g); err != nil {
+			return err
+		}
+		if err := finishStackMerge(g, ghc, m, report); err != nil {
+			return err
+		}
+	}
+
+	if m.Original != m.Root && refExists(g, "refs/heads/"+m.Original) {
+		if err := g.Checkout(m.Original); err != nil {
+			return err
+		}
+	}
+	return AbortStackMerge(g)
+}
+
+// mergeStackPR waits for branch's PR to be ready and merges it into the root
+func mergeStackPR(g git.Service, ghc gh.Client, m *StackMerge, branch string, opts StackMergeOptions, report func(string)) (MergedPR, error) {
+	pr, err := ghc.GetPRForBranch(branch)
+	if err != nil {
+		return MergedPR{}, fmt.Errorf("failed to look up the PR for %s: %w", branch, err)
+	}
+	if pr == nil {
+		// Merged by hand, or by an earlier run that stopped before saving
+		closed, err := ghc.ClosedPRsForBranch(branch)
+		if err != nil {
+			return MergedPR{}, fmt.Errorf("failed to look up the PR for %s: %w", branch, err)
+		}
+		for _, c := range closed {
+			if c.Merged || c.MergedAt != nil {
+				report(fmt.Sprintf("#%d (%s) was already merged", c.Number, branch))
+				return MergedPR{Branch: branch, Number: c.Number, URL: c.HTMLURL}, nil
+			}
+		}
+		return MergedPR{}, fmt.Errorf("%s has no open PR", branch)
+	}
+
+	if err := retargetPR(ghc, pr, m.Root); err != nil {
+		return MergedPR{}, err
+	}
+	report(fmt.Sprintf("Waiting for the checks on #%d (%s)", pr.Number, branch))
+	if err := waitForStackChecks(g, ghc, branch, pr.Number, opts); err != nil {
+		return MergedPR{}, err
+	}
+
+	if m.Method == "squash" {
+		err = MergeSquash(ghc, pr.Number, ComposeSquashMessage(pr))
+	} else {
+		err = ghc.MergePR(pr.Number, m.Method)
+	}
+	if err != nil {
+		return MergedPR{}, fmt.Errorf("failed to merge #%d (%s): %w", pr.Number, branch, err)
+	}
+	report(fmt.Sprintf("Merged #%d (%s) into %s", pr.Number, branch, m.Root))
+	return MergedPR{Branch: branch, Number: pr.Number, URL: pr.HTMLURL}, nil
+}
+
+// waitForStackChecks waits until GitHub has seen branch's latest push and
+// every check on it has passed
+func waitForStackChecks(g git.Service, ghc gh.Client, branch string, num int, opts StackMergeOptions) error {
+	local, err := g.GetCommitHash("refs/heads/" + branch)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		pr, err := ghc.GetPRForBranch(branch)
+		if err != nil {
+			return fmt.Errorf("failed to look up the PR for %s: %w", branch, err)
+		}
+		// Until GitHub sees the push, the checks are the old commit's
+		if pr != nil && (pr.Head.SHA == "" || pr.Head.SHA == local) {
+			runs, err := ghc.ListPRCheckRuns(num)
+			if err != nil {
+				return fmt.Errorf("failed to list the checks of #%d: %w", num, err)
+			}
+			var failed []string
+			pending := 0
+			for _, run := range runs {
+				switch {
+				case run.Failed():
+					failed = append(failed, run.Name)
+				case run.Status != "completed":
+					pending++
+				}
+			}
+			if len(failed) > 0 {
+				return exitcode.Errorf(exitcode.Threshold, "checks failed on #%d (%s): %s\nFix them, then run 'sage stack merge --continue'",
+					num, branch, strings.Join(failed, ", "))
+			}
+			if pending == 0 {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return exitcode.Errorf(exitcode.Threshold, "the checks on #%d (%s) didn't finish within %s; run 'sage stack merge --continue' to keep waiting",
+				num, branch, opts.Timeout)
+		}
+		time.Sleep(opts.Poll)
+	}
+}
+
+// retargetPR points pr at base, if it isn't already
+func retargetPR(ghc gh.Client, pr *gh.PullRequest, base string) error {
+	if pr.Base.Ref == base {
+		return nil
+	}
+	pr.Base.Ref = base
+	if dryrun.Enabled() {
+		dryrun.Record("retarget PR #%d onto %s", pr.Number, base)
+		return nil
+	}
+	if err := ghc.UpdatePR(pr.Number, pr); err != nil {
+		return fmt.Errorf("failed to retarget #%d onto %s: %w", pr.Number, base, err)
+	}
+	return nil
+}
+
+// finishStackMerge brings the root up to date after m.Cleanup was merged,
+// moves the branches above onto it, and deletes the merged branch
+func finishStackMerge(g git.Service, ghc gh.Client, m *StackMerge, report func(string)) error {
+	merged := m.Cleanup
+	if _, err := g.Run("fetch", "origin", m.Root); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", m.Root, err)
+	}
+	if _, err := g.Run("merge", "--ff-only", "origin/"+m.Root); err != nil {
+		return fmt.Errorf("failed to bring %s up to date with origin: %w", m.Root, err)
+	}
+
+	// Children of the merged branch now build on the root. Their PRs
+	// move first: deleting the branch a PR targets closes it.
+	st, err := stack.Load(g)
+	if err != nil {
+		return err
+	}
+	for _, child := range st.Children(merged) {
+		pr, err := ghc.GetPRForBranch(child)
+		if err != nil {
+			return fmt.Errorf("failed to look up the PR for %s: %w", child, err)
+		}
+		if pr != nil {
+			if err := retargetPR(ghc, pr, m.Root); err != nil {
+				return err
+			}
+		}
+	}
+	st.Remove(merged)
+	if err := st.Save(g); err != nil {
+		return err
+	}
+
+	if len(st.Descendants(m.Root)) > 0 {
+		results, err := StackSync(g)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if !r.Rebased {
+				continue
+			}
+			report(fmt.Sprintf("Restacked %s onto %s", r.Branch, r.Parent))
+			if err := g.PushWithLease(r.Branch); err != nil {
+				return fmt.Errorf("failed to push %s: %w", r.Branch, err)
+			}
+		}
+	}
+
+	if refExists(g, "refs/remotes/origin/"+merged) {
+		if err := g.DeleteRemoteBranch(merged); err != nil {
+			// GitHub may have deleted it on merge already
+			if !strings.Contains(err.Error(), "remote ref does not exist") {
+				return fmt.Errorf("failed to delete %s from origin: %w", merged, err)
+			}
+			_, _ = g.Run("branch", "-d", "-r", "origin/"+merged)
+		}
+	}
+	if refExists(g, "refs/heads/"+merged) {
+		if _, err := g.Run("branch", "-D", merged); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", merged, err)
+		}
+	}
+	m.Deleted = append(m.Deleted, merged)
+	m.Cleanup = ""
+	return m.save(g)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/stack"
+)
+
+// fakeStackGitHub plays GitHub for stack merges. PRs are kept by branch, and
+// merging one squashes its branch into its base on origin, working in a
+// clone of its own.
+type fakeStackGitHub struct {
+	gh.Client
+	r      *testRepo
+	dir    string
+	prs    map[string]*gh.PullRequest
+	checks map[string][]gh.CheckRun
+}
+
+func newFakeStackGitHub(r *testRepo) *fakeStackGitHub {
+	return &fakeStackGitHub{r: r, dir: r.clone("github"), prs: map[string]*gh.PullRequest{}, checks: map[string][]gh.CheckRun{}}
+}
+
+func (f *fakeStackGitHub) open(branch, base string) {
+	pr := &gh.PullRequest{Number: len(f.prs) + 1, Title: "Add " + branch, State: "open"}
+	pr.Head.Ref, pr.Base.Ref = branch, base
+	f.prs[branch] = pr
+}
+
+func (f *fakeStackGitHub) byNumber(num int) *gh.PullRequest {
+	for _, pr := range f.prs {
+		if pr.Number == num {
+			return pr
+		}
+	}
+	f.r.t.Fatalf("no PR #%d", num)
+	return nil
+}
+
+func (f *fakeStackGitHub) GetPRForBranch(branch string) (*gh.PullRequest, error) {
+	pr, ok := f.prs[branch]
+	if !ok || pr.Merged {
+		return nil, nil
+	}
+	c := *pr
+	c.Head.SHA = f.r.gitIn(f.r.origin, "rev-parse", "refs/heads/"+branch)
+	return &c, nil
+}
+
+func (f *fakeStackGitHub) ClosedPRsForBranch(branch string) ([]gh.PullRequest, error) {
+	if pr, ok := f.prs[branch]; ok && pr.Merged {
+		return []gh.PullRequest{*pr}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeStackGitHub) ListPRReviews(num int) ([]gh.Review, error) { return nil, nil }
+
+func (f *fakeStackGitHub) ListPRCheckRuns(num int) ([]gh.CheckRun, error) {
+	return f.checks[f.byNumber(num).Head.Ref], nil
+}
+
+func (f *fakeStackGitHub) UpdatePR(num int, pr *gh.PullRequest) error {
+	f.byNumber(num).Base.Ref = pr.Base.Ref
+	return nil
+}
+
+func (f *fakeStackGitHub) MergePRWithMessage(num int, method, title, message string) error {
+	pr := f.byNumber(num)
+	f.r.gitIn(f.dir, "fetch", "origin")
+	f.r.gitIn(f.dir, "checkout", "-B", pr.Base.Ref, "origin/"+pr.Base.Ref)
+	f.r.gitIn(f.dir, "merge", "--squash", "origin/"+pr.Head.Ref)
+	f.r.gitIn(f.dir, "commit", "-m", title)
+	f.r.gitIn(f.dir, "push", "origin", pr.Base.Ref)
+	pr.Merged, pr.State = true, "closed"
+	return nil
+}
+
+func TestStackMergeResumesAfterFailedChecks(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	fake := newFakeStackGitHub(r)
+	parent := "main"
+	for _, b := range []string{"a", "b", "c"} {
+		if err := StackCreate(g, b); err != nil {
+			t.Fatalf("StackCreate(%s): %v", b, err)
+		}
+		r.commit(b+".txt", b+"\n", "Add "+b)
+		r.git("push", "-u", "origin", b)
+		fake.open(b, parent)
+		parent = b
+	}
+	fake.checks["b"] = []gh.CheckRun{{Name: "test", Status: "completed", Conclusion: "failure"}}
+
+	m, err := StartStackMerge(g, fake, "", "squash")
+	if err != nil {
+		t.Fatalf("StartStackMerge: %v", err)
+	}
+	if strings.Join(m.Branches, " ") != "a b c" || m.Root != "main" {
+		t.Fatalf("planned %v into %s, want a b c into main", m.Branches, m.Root)
+	}
+	if _, err := StartStackMerge(g, fake, "", "squash"); err == nil {
+		t.Error("a second stack merge started while one is in progress")
+	}
+
+	opts := StackMergeOptions{Timeout: time.Second, Poll: time.Millisecond}
+	err = RunStackMerge(g, fake, m, opts, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "checks failed on #2") {
+		t.Fatalf("RunStackMerge = %v, want it to stop on b's failed checks", err)
+	}
+
+	// a is merged and gone, and b and c were moved onto main
+	if r.git("ls-tree", "--name-only", "origin/main", "a.txt") != "a.txt" {
+		t.Error("a wasn't merged into main")
+	}
+	if out := r.git("branch", "--list", "a"); out != "" {
+		t.Errorf("branch a is still there: %s", out)
+	}
+	if fake.prs["b"].Base.Ref != "main" {
+		t.Errorf("b's PR targets %s, want main", fake.prs["b"].Base.Ref)
+	}
+	if !r.isAncestor("origin/main", "origin/b") || !r.isAncestor("origin/b", "origin/c") {
+		t.Error("b and c weren't restacked onto the squashed main and pushed")
+	}
+
+	saved, err := LoadStackMerge(g)
+	if err != nil || saved == nil {
+		t.Fatalf("LoadStackMerge = %v, %v; want the checkpoint", saved, err)
+	}
+	if saved.Next != 1 || len(saved.Merged) != 1 || strings.Join(saved.Deleted, " ") != "a" {
+		t.Errorf("checkpoint = %+v, want a merged and deleted", saved)
+	}
+
+	fake.checks["b"] = nil
+	if err := RunStackMerge(g, fake, saved, opts, func(string) {}); err != nil {
+		t.Fatalf("RunStackMerge(--continue): %v", err)
+	}
+	for _, f := range []string{"a.txt", "b.txt", "c.txt"} {
+		if r.git("ls-tree", "--name-only", "origin/main", f) != f {
+			t.Errorf("%s isn't on main", f)
+		}
+	}
+	if out := r.git("branch", "--list", "a", "b", "c"); out != "" {
+		t.Errorf("merged branches are left:\n%s", out)
+	}
+	if m, _ := LoadStackMerge(g); m != nil {
+		t.Error("the checkpoint is left after finishing")
+	}
+	if st, _ := stack.Load(g); len(st.Branches) != 0 {
+		t.Errorf("the stack still has %v", st.Branches)
+	}
+}
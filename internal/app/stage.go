@@ -8,6 +8,7 @@ import (
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/crazywolf132/sage/internal/ai"
+	"github.com/crazywolf132/sage/internal/batch"
 	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
@@ -33,7 +34,8 @@ func StageFiles(g git.Service, patterns []string, useAI bool) error {
 
 	// Parse status into FileStatus structs
 	var files []FileStatus
-	for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+	var untracked []string
+	for _, line := range porcelainLines(status) {
 		if line == "" {
 			continue
 		}
@@ -53,6 +55,9 @@ func StageFiles(g git.Service, patterns []string, useAI bool) error {
 		// - Modified (M), Added/untracked (A/?), Deleted (D), or Renamed (R)
 		// We need to check both X and Y because files can be partially staged
 		x, y := statusCode[0], statusCode[1]
+		if x == '?' {
+			untracked = append(untracked, path)
+		}
 		isUnstaged := x == ' ' || x == '?' || y == 'M' || y == 'A' || y == '?' || y == 'D' || y == 'R'
 
 		if isUnstaged {
@@ -77,10 +82,30 @@ func StageFiles(g git.Service, patterns []string, useAI bool) error {
 		}
 	}
 
+	// Files that usually hold secrets are never offered for staging
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if blocked := FindBlockedFiles(g, paths); len(blocked) > 0 {
+		isBlocked := map[string]bool{}
+		for _, b := range blocked {
+			isBlocked[b] = true
+			fmt.Printf("%s Skipping %s: it usually holds secrets (allow it in %s)\n", ui.Yellow("!"), b, secretAllowFile)
+		}
+		kept := files[:0]
+		for _, f := range files {
+			if !isBlocked[filepath.ToSlash(f.Path)] {
+				kept = append(kept, f)
+			}
+		}
+		files = kept
+	}
+
 	if len(files) == 0 {
 		// Get currently staged files to show info about
 		var stagedFiles []string
-		for _, line := range strings.Split(strings.TrimSpace(status), "\n") {
+		for _, line := range porcelainLines(status) {
 			if line == "" {
 				continue
 			}
@@ -134,11 +159,42 @@ func StageFiles(g git.Service, patterns []string, useAI bool) error {
 		return nil
 	}
 
+	// Nobody to pick files: stage everything there is, as 'git add -A' would
+	if batch.Enabled() {
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		if err := stagePaths(g, paths); err != nil {
+			return err
+		}
+		batch.Note("staged every changed file; pass paths or patterns to stage fewer")
+		fmt.Printf("%s Staged %d files\n", ui.Green("✓"), len(paths))
+		return nil
+	}
+
+	// Thousands of untracked files are usually a directory that should be ignored
+	if ignored, err := offerIgnores(g, untracked); err != nil {
+		return fmt.Errorf("selection cancelled: %w", err)
+	} else if ignored {
+		return StageFiles(g, patterns, useAI)
+	}
+
+	if useAI && !config.AIEnabled("") {
+		fmt.Printf("%s AI is disabled on this branch, falling back to manual selection\n", ui.Yellow("!"))
+		return StageFiles(g, patterns, false)
+	}
+
+	if useAI && len(files) > maxSelectOptions {
+		fmt.Printf("%s Too many changed files to group with AI, falling back to manual selection\n", ui.Yellow("!"))
+		return StageFiles(g, patterns, false)
+	}
+
 	if useAI {
 		// Initialize AI client
 		client := ai.NewClient("", ai.NewConfigAdapter(config.Get))
-		if client.APIKey == "" {
-			fmt.Printf("%s No OpenAI API key found, falling back to manual selection\n", ui.Yellow("!"))
+		if !client.Configured() {
+			fmt.Printf("%s No AI provider configured, falling back to manual selection\n", ui.Yellow("!"))
 			return StageFiles(g, patterns, false)
 		}
 
@@ -287,13 +343,8 @@ Return only the exact name of the most appropriate group from the list above.`,
 		}
 
 		// Show interactive group selector
-		var selected []string
-		groupPrompt := &survey.MultiSelect{
-			Message:  "Select groups of changes to stage:",
-			Options:  options,
-			PageSize: 15,
-		}
-		err = survey.AskOne(groupPrompt, &selected)
+		selected, err := ui.AskMultiSelect("Select groups of changes to stage:", options,
+			"name the files to stage instead", survey.WithPageSize(15))
 		if err != nil {
 			return fmt.Errorf("selection cancelled: %w", err)
 		}
@@ -325,20 +376,8 @@ Return only the exact name of the most appropriate group from the list above.`,
 		return nil
 	}
 
-	// Create options for the interactive selector
-	var options []string
-	for _, file := range files {
-		options = append(options, fmt.Sprintf("%s (%s)", file.Path, file.Status))
-	}
-
 	// Show interactive selector
-	var selected []string
-	prompt := &survey.MultiSelect{
-		Message:  "Select files to stage:",
-		Options:  options,
-		PageSize: 15,
-	}
-	err = survey.AskOne(prompt, &selected)
+	selected, err := selectFiles("Select files to stage:", files, nil)
 	if err != nil {
 		return fmt.Errorf("selection cancelled: %w", err)
 	}
@@ -348,13 +387,8 @@ Return only the exact name of the most appropriate group from the list above.`,
 		return nil
 	}
 
-	// Stage selected files
-	for _, sel := range selected {
-		// Extract path from selection (remove status)
-		path := strings.TrimSpace(strings.Split(sel, " (")[0])
-		if err := g.RunInteractive("add", path); err != nil {
-			return fmt.Errorf("failed to stage %s: %w", path, err)
-		}
+	if err := stagePaths(g, selected); err != nil {
+		return err
 	}
 
 	fmt.Printf("%s Staged %d files\n", ui.Green("✓"), len(selected))
@@ -0,0 +1,357 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// HunkLine is one removed or added line of a hunk
+type HunkLine struct {
+	Op   byte // '-' or '+'
+	Text string
+	// NoNewline marks a file's last line when it has no newline after it
+	NoNewline bool
+	Selected  bool
+}
+
+// Hunk is one change as 'git diff -U0' shows it: the lines removed, then the
+// lines added in their place, with no context around them
+type Hunk struct {
+	OldStart, OldCount int
+	NewStart, NewCount int
+	// Section is what git shows after the header, usually the function
+	Section string
+	Lines   []HunkLine
+}
+
+// Selected counts the hunk's lines picked for staging
+func (h Hunk) Selected() int {
+	n := 0
+	for _, l := range h.Lines {
+		if l.Selected {
+			n++
+		}
+	}
+	return n
+}
+
+// Select picks or drops every line of the hunk
+func (h *Hunk) Select(on bool) {
+	for i := range h.Lines {
+		h.Lines[i].Selected = on
+	}
+}
+
+// Header is the hunk's @@ line as git printed it
+func (h Hunk) Header() string {
+	s := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldCount, h.NewStart, h.NewCount)
+	if h.Section != "" {
+		s += " " + h.Section
+	}
+	return s
+}
+
+// oldFirst is the 0-based index of the first old line the hunk touches. A
+// hunk that only adds names the line it adds after instead.
+func (h Hunk) oldFirst() int {
+	if h.OldCount == 0 {
+		return h.OldStart
+	}
+	return h.OldStart - 1
+}
+
+// FileHunks is one file's unstaged hunks
+type FileHunks struct {
+	Path string
+	// Header is the diff --git, index, --- and +++ lines before the hunks
+	Header []string
+	Hunks  []Hunk
+}
+
+var zeroHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseHunks reads the output of 'git diff -U0'. Files without hunks, such
+// as binary files and mode changes, are left out.
+func ParseHunks(diff string) []FileHunks {
+	var files []FileHunks
+	var cur *FileHunks
+	var hunk *Hunk
+	flush := func() {
+		if cur != nil && len(cur.Hunks) > 0 {
+			files = append(files, *cur)
+		}
+		cur, hunk = nil, nil
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			cur = &FileHunks{Header: []string{line}}
+		case cur == nil:
+		case hunk == nil && !strings.HasPrefix(line, "@@"):
+			cur.Header = append(cur.Header, line)
+			if name, ok := strings.CutPrefix(line, "+++ "); ok && name != "/dev/null" {
+				cur.Path = diffPath(name)
+			} else if name, ok := strings.CutPrefix(line, "--- "); ok && name != "/dev/null" {
+				cur.Path = diffPath(name)
+			}
+		case strings.HasPrefix(line, "@@"):
+			m := zeroHunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			cur.Hunks = append(cur.Hunks, Hunk{
+				OldStart: atoiOr(m[1], 0), OldCount: atoiOr(m[2], 1),
+				NewStart: atoiOr(m[3], 0), NewCount: atoiOr(m[4], 1),
+				Section: m[5],
+			})
+			hunk = &cur.Hunks[len(cur.Hunks)-1]
+		case strings.HasPrefix(line, "-"), strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, HunkLine{Op: line[0], Text: line[1:]})
+		case strings.HasPrefix(line, `\`):
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+		}
+	}
+	flush()
+	return files
+}
+
+// diffPath strips the a/ or b/ off a path in a diff header
+func diffPath(name string) string {
+	name = strings.Trim(name, `"`)
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func atoiOr(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// hunkGroupGap is how far apart hunks can be and still be shown as one, as
+// git's three lines of context on either side would join them
+const hunkGroupGap = 6
+
+// GroupHunks joins a file's hunks that sit close together, the way a plain
+// 'git diff' shows them, returning the indexes of each group's hunks.
+// Splitting a group shows its hunks one by one.
+func GroupHunks(f FileHunks) [][]int {
+	var groups [][]int
+	end := 0
+	for i, h := range f.Hunks {
+		if i > 0 && h.oldFirst()-end <= hunkGroupGap {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+		} else {
+			groups = append(groups, []int{i})
+		}
+		end = h.oldFirst() + h.OldCount
+	}
+	return groups
+}
+
+// HunkPatch builds a patch of the selected lines, for 'git apply --cached
+// --unidiff-zero'. Removed lines left out stay as context and added lines
+// left out are dropped, so what's staged is exactly what was picked.
+func HunkPatch(files []FileHunks) string {
+	var b strings.Builder
+	for _, f := range files {
+		selected, total := 0, 0
+		for _, h := range f.Hunks {
+			selected += h.Selected()
+			total += len(h.Lines)
+		}
+		if selected == 0 {
+			continue
+		}
+
+		for _, line := range f.Header {
+			// Staging part of a deletion leaves the file in place
+			if selected < total {
+				if strings.HasPrefix(line, "deleted file mode") {
+					continue
+				}
+				if line == "+++ /dev/null" {
+					line = "+++ b/" + f.Path
+				}
+			}
+			b.WriteString(line + "\n")
+		}
+
+		// How far the index's lines have moved from the hunks applied so far
+		delta := 0
+		for _, h := range f.Hunks {
+			if h.Selected() == 0 {
+				continue
+			}
+			var body strings.Builder
+			newCount := 0
+			for _, l := range h.Lines {
+				switch {
+				case l.Selected:
+					body.WriteString(string(l.Op) + l.Text + "\n")
+					if l.Op == '+' {
+						newCount++
+					}
+				case l.Op == '-':
+					body.WriteString(" " + l.Text + "\n")
+					newCount++
+				default:
+					continue
+				}
+				if l.NoNewline {
+					body.WriteString("\\ No newline at end of file\n")
+				}
+			}
+
+			newStart := h.oldFirst() + delta
+			if newCount > 0 {
+				newStart++
+			}
+			fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldCount, newStart, newCount)
+			b.WriteString(body.String())
+			delta += newCount - h.OldCount
+		}
+	}
+	return b.String()
+}
+
+// UnstagedHunks reads the hunks not yet staged in the files matching
+// patterns, or in every file without any. Files that usually hold secrets
+// are left out, as StageFiles leaves them.
+func UnstagedHunks(g git.Service, patterns []string) ([]FileHunks, error) {
+	diff, err := g.Run("diff", "-U0", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unstaged changes: %w", err)
+	}
+	files := ParseHunks(diff)
+
+	if len(patterns) > 0 {
+		kept := files[:0]
+		for _, f := range files {
+			for _, pattern := range patterns {
+				matched, err := filepath.Match(pattern, f.Path)
+				if err != nil {
+					return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+				}
+				if matched {
+					kept = append(kept, f)
+					break
+				}
+			}
+		}
+		files = kept
+	}
+
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if blocked := FindBlockedFiles(g, paths); len(blocked) > 0 {
+		isBlocked := map[string]bool{}
+		for _, b := range blocked {
+			isBlocked[b] = true
+		}
+		kept := files[:0]
+		for _, f := range files {
+			if !isBlocked[filepath.ToSlash(f.Path)] {
+				kept = append(kept, f)
+			}
+		}
+		files = kept
+	}
+	return files, nil
+}
+
+// ApplyHunks stages the selected lines of files and returns how many hunks
+// they came from
+func ApplyHunks(g git.Service, files []FileHunks) (int, error) {
+	patch := HunkPatch(files)
+	if patch == "" {
+		return 0, nil
+	}
+	n := 0
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			if h.Selected() > 0 {
+				n++
+			}
+		}
+	}
+
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Join(strings.TrimSpace(gitDir), ".sage")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	path := filepath.Join(dir, "stage.patch")
+	if err := os.WriteFile(path, []byte(patch), 0644); err != nil {
+		return 0, err
+	}
+	defer os.Remove(path)
+
+	if _, err := g.Run("apply", "--cached", "--unidiff-zero", "--whitespace=nowarn", filepath.ToSlash(path)); err != nil {
+		return 0, fmt.Errorf("failed to stage the selected hunks: %w", err)
+	}
+	return n, nil
+}
+
+// StageHunks lets pick choose hunks and lines of the unstaged changes in
+// files matching patterns, then stages them. pick returns nil when the
+// user cancels.
+func StageHunks(g git.Service, patterns []string, pick func([]FileHunks) ([]FileHunks, error)) error {
+	if batch.Enabled() {
+		return exitcode.Errorf(exitcode.Usage, "picking hunks needs someone to pick them; pass paths or patterns to stage whole files")
+	}
+
+	files, err := UnstagedHunks(g, patterns)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Printf("%s No unstaged changes to pick hunks from (new files are staged whole with 'sage stage')\n", ui.Yellow("!"))
+		return nil
+	}
+
+	files, err = pick(files)
+	if err != nil {
+		return fmt.Errorf("selection cancelled: %w", err)
+	}
+	if files == nil {
+		fmt.Printf("%s Nothing staged\n", ui.Yellow("!"))
+		return nil
+	}
+
+	n, err := ApplyHunks(g, files)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Printf("%s No hunks selected to stage\n", ui.Yellow("!"))
+		return nil
+	}
+	fmt.Printf("%s Staged %d hunks\n", ui.Green("✓"), n)
+	return nil
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestParseHunks(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3 +3,2 @@ func main() {
+-	old()
++	one()
++	two()
+@@ -10,0 +12 @@ func main() {
++	added()
+diff --git a/logo.png b/logo.png
+index 3333333..4444444 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/notes.txt b/notes.txt
+index 5555555..6666666 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-end
+\ No newline at end of file
++end
+`
+	files := ParseHunks(diff)
+	if len(files) != 2 {
+		t.Fatalf("parsed %d files, want main.go and notes.txt", len(files))
+	}
+	f := files[0]
+	if f.Path != "main.go" || len(f.Header) != 4 || len(f.Hunks) != 2 {
+		t.Fatalf("main.go = %+v", f)
+	}
+	h := f.Hunks[0]
+	if h.OldStart != 3 || h.OldCount != 1 || h.NewStart != 3 || h.NewCount != 2 || h.Section != "func main() {" {
+		t.Errorf("first hunk = %+v", h)
+	}
+	if len(h.Lines) != 3 || h.Lines[0].Op != '-' || h.Lines[2].Text != "\ttwo()" {
+		t.Errorf("first hunk lines = %+v", h.Lines)
+	}
+	if h := f.Hunks[1]; h.OldStart != 10 || h.OldCount != 0 || h.NewCount != 1 {
+		t.Errorf("second hunk = %+v", h)
+	}
+	if l := files[1].Hunks[0].Lines; !l[0].NoNewline || l[1].NoNewline {
+		t.Errorf("notes.txt lines = %+v, want only the removed one without a newline", l)
+	}
+}
+
+func TestGroupHunks(t *testing.T) {
+	f := FileHunks{Hunks: []Hunk{
+		{OldStart: 2, OldCount: 1},
+		{OldStart: 6, OldCount: 2},  // 3 lines after the first
+		{OldStart: 20, OldCount: 0}, // far away, adding after line 20
+		{OldStart: 24, OldCount: 1},
+	}}
+	got := fmt.Sprint(GroupHunks(f))
+	if got != "[[0 1] [2 3]]" {
+		t.Errorf("GroupHunks = %s, want [[0 1] [2 3]]", got)
+	}
+}
+
+func TestApplyHunksStagesSelectedLines(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.commit("list.txt", numberedLines(1, 20), "Add list")
+
+	// Replace 2-3, add after 10, delete 18
+	content := strings.Replace(numberedLines(1, 20), "line 2\nline 3\n", "line two\nline three\n", 1)
+	content = strings.Replace(content, "line 10\n", "line 10\nnew a\nnew b\n", 1)
+	content = strings.Replace(content, "line 18\n", "", 1)
+	r.write("list.txt", content)
+
+	files, err := UnstagedHunks(g, []string{"*.txt"})
+	if err != nil {
+		t.Fatalf("UnstagedHunks: %v", err)
+	}
+	if len(files) != 1 || len(files[0].Hunks) != 3 {
+		t.Fatalf("UnstagedHunks = %+v, want three hunks in list.txt", files)
+	}
+	hunks := files[0].Hunks
+
+	// Only line 3's replacement, the second added line and the deletion
+	hunks[0].Lines[1].Selected = true // -line 3
+	hunks[0].Lines[3].Selected = true // +line three
+	hunks[1].Lines[1].Selected = true // +new b
+	hunks[2].Select(true)
+
+	n, err := ApplyHunks(g, files)
+	if err != nil {
+		t.Fatalf("ApplyHunks: %v\n%s", err, HunkPatch(files))
+	}
+	if n != 3 {
+		t.Errorf("staged %d hunks, want 3", n)
+	}
+
+	want := strings.Replace(numberedLines(1, 20), "line 3\n", "line three\n", 1)
+	want = strings.Replace(want, "line 10\n", "line 10\nnew b\n", 1)
+	want = strings.Replace(want, "line 18\n", "", 1)
+	if got := r.git("show", ":list.txt") + "\n"; got != want {
+		t.Errorf("index has:\n%s\nwant:\n%s", got, want)
+	}
+	if r.read("list.txt") != content {
+		t.Error("the working tree changed")
+	}
+
+	// What's left unstaged is what wasn't picked
+	left, err := UnstagedHunks(g, nil)
+	if err != nil {
+		t.Fatalf("UnstagedHunks: %v", err)
+	}
+	if len(left) != 1 || len(left[0].Hunks) != 2 {
+		t.Errorf("left unstaged: %+v", left)
+	}
+}
+
+func TestApplyHunksPartOfADeletedFile(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.commit("old.txt", "a\nb\nc", "Add old")
+	if err := os.Remove("old.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := UnstagedHunks(g, nil)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("UnstagedHunks = %+v, %v", files, err)
+	}
+	files[0].Hunks[0].Lines[0].Selected = true
+	if _, err := ApplyHunks(g, files); err != nil {
+		t.Fatalf("ApplyHunks: %v\n%s", err, HunkPatch(files))
+	}
+	if got := r.git("show", ":old.txt"); got != "b\nc" {
+		t.Errorf("index has %q, want the file less its first line", got)
+	}
+}
@@ -2,31 +2,66 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/crazywolf132/sage/internal/config"
 	"github.com/crazywolf132/sage/internal/git"
 )
 
-func StartBranch(g git.Service, newBranch string, push bool) error {
+// How much StartBranch fetches before branching off the default branch
+const (
+	StartFetchFull    = "full"    // every remote, every ref, then pull
+	StartFetchMinimal = "minimal" // only the default branch from origin
+	StartFetchNone    = "none"    // branch from the local default branch as is
+)
+
+// StartFetchMode returns the start.fetch setting, falling back to minimal
+// when it's unset or not a mode sage knows
+func StartFetchMode() string {
+	switch mode := strings.TrimSpace(config.Get("start.fetch", true)); mode {
+	case StartFetchFull, StartFetchMinimal, StartFetchNone:
+		return mode
+	default:
+		return StartFetchMinimal
+	}
+}
+
+func StartBranch(g git.Service, newBranch string, push bool, fetch string) error {
 	repo, err := g.IsRepo()
 	if err != nil || !repo {
 		return fmt.Errorf("not a git repo")
 	}
 
-	db, err := g.DefaultBranch()
+	// New branches start from the trunk they will be synced against
+	db, err := baseOrMain(g, newBranch)
 	if err != nil {
-		db = "main"
+		return err
 	}
 
-	if err := g.FetchAll(); err != nil {
-		return err
+	if fetch == StartFetchFull {
+		if err := g.FetchAll(); err != nil {
+			return err
+		}
 	}
 
 	if err := g.Checkout(db); err != nil {
 		return err
 	}
 
-	if err := g.Pull(); err != nil {
-		return err
+	switch fetch {
+	case StartFetchFull:
+		if err := g.Pull(); err != nil {
+			return err
+		}
+	case StartFetchMinimal:
+		// Fetching one branch without tags is much cheaper than fetching
+		// everything, which is slow in repositories with many remotes
+		if _, err := g.Run("fetch", "origin", db, "--no-tags"); err != nil {
+			return fmt.Errorf("failed to fetch %s from origin: %w", db, err)
+		}
+		if err := g.Merge("origin/" + db); err != nil {
+			return fmt.Errorf("failed to update %s from origin: %w", db, err)
+		}
 	}
 
 	if err := g.CreateBranch(newBranch); err != nil {
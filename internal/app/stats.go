@@ -61,45 +61,43 @@ func GetStats(g git.Service, opts StatsOptions) error {
 	authorStats := make(map[string]*AuthorStats)
 	branchStats := make(map[string]*BranchStats)
 
-	// Get branch information
-	branches, err := g.ListBranches()
+	// Collect branch statistics in one pass against the default branch, or
+	// origin's copy of it; a branch's commits are the default branch's, less
+	// those it is behind, plus those it is ahead. Without one, count against
+	// HEAD and skip predicting conflicts.
+	candidates := []string{"HEAD"}
+	if db, err := g.DefaultBranch(); err == nil {
+		candidates = []string{db, "origin/" + db, "HEAD"}
+	}
+	var base string
+	var baseCount int
+	for _, base = range candidates {
+		if baseCount, err = g.GetCommitCount(base); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to count commits: %w", err)
+	}
+	branches, err := g.BranchSummaries(base, base != "HEAD")
 	if err != nil {
 		return fmt.Errorf("failed to list branches: %w", err)
 	}
-
-	// Collect branch statistics
-	for _, branch := range branches {
-		lastCommit, err := g.GetBranchLastCommit(branch)
-		if err != nil {
-			continue
-		}
-
-		commitCount, err := g.GetBranchCommitCount(branch)
-		if err != nil {
-			continue
-		}
-
-		conflicts, err := g.GetBranchMergeConflicts(branch)
-		if err != nil {
-			conflicts = 0
-		}
-
-		branchStats[branch] = &BranchStats{
-			Name:           branch,
-			LastCommit:     lastCommit,
-			CommitCount:    commitCount,
-			MergeConflicts: conflicts,
+	for _, b := range branches {
+		branchStats[b.Name] = &BranchStats{
+			Name:           b.Name,
+			LastCommit:     b.LastCommit,
+			CommitCount:    baseCount - b.Behind + b.Ahead,
+			MergeConflicts: b.Conflicts,
 		}
 	}
 
 	// Get all commits with stats
-	log, err := g.Log("", 0, true, true)
+	log, err := g.Commits(git.CommitsOptions{Stats: true})
 	if err != nil {
 		return fmt.Errorf("failed to get git log: %w", err)
 	}
-
-	// Parse the log and collect statistics
-	commits := parseGitLog(log, true)
+	commits := toCommitInfos(log)
 
 	// Process each commit
 	for _, commit := range commits {
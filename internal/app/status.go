@@ -2,20 +2,23 @@ package app
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/crazywolf132/sage/internal/git"
 )
 
 type FileChange struct {
-	Symbol      string
-	File        string
-	Description string
+	Symbol      string `json:"symbol" yaml:"symbol"`
+	File        string `json:"file" yaml:"file"`
+	Description string `json:"description" yaml:"description"`
+	Staged      bool   `json:"staged" yaml:"staged"`     // the index differs from HEAD
+	Unstaged    bool   `json:"unstaged" yaml:"unstaged"` // the working tree differs from the index
 }
 
 type RepoStatus struct {
-	Branch  string
-	Changes []FileChange
+	Branch  string       `json:"branch" yaml:"branch"`
+	Changes []FileChange `json:"changes" yaml:"changes"`
 }
 
 func GetRepoStatus(g git.Service) (*RepoStatus, error) {
@@ -54,26 +57,146 @@ func GetRepoStatus(g git.Service) (*RepoStatus, error) {
 				continue
 			}
 
-			path := ln[pathStart:]
-
-			if strings.Contains(path, " -> ") {
-				parts := strings.Split(path, " -> ")
-				if len(parts) == 2 {
-					path = parts[1]
-				}
-			}
+			path := unquotePath(renameTarget(ln[pathStart:]))
 
 			symbol, desc := interpretStatus(indexStatus, workTreeStatus)
 			changes = append(changes, FileChange{
 				Symbol:      symbol,
 				File:        path,
 				Description: desc,
+				Staged:      indexStatus != ' ' && indexStatus != '?',
+				Unstaged:    workTreeStatus != ' ' && workTreeStatus != '?',
 			})
 		}
 	}
 	return &RepoStatus{Branch: br, Changes: changes}, nil
 }
 
+// StatusOverview is everything `sage status` shows about the repository
+type StatusOverview struct {
+	RepoStatus `yaml:",inline"`
+	Staged     []FileChange `json:"staged" yaml:"staged"`
+	Unstaged   []FileChange `json:"unstaged" yaml:"unstaged"`
+	Untracked  []FileChange `json:"untracked" yaml:"untracked"`
+	Conflicts  []FileChange `json:"conflicts" yaml:"conflicts"`
+
+	Upstream string `json:"upstream,omitempty" yaml:"upstream,omitempty"` // empty when the branch has no upstream
+	Ahead    int    `json:"ahead" yaml:"ahead"`
+	Behind   int    `json:"behind" yaml:"behind"`
+
+	Operation string    `json:"operation,omitempty" yaml:"operation,omitempty"` // "merge", "rebase", "am" or empty
+	Continue  string    `json:"continue,omitempty" yaml:"continue,omitempty"`   // command that finishes Operation once conflicts are resolved
+	PR        *CachedPR `json:"pr,omitempty" yaml:"pr,omitempty"`               // from the PR cache; nil if sage hasn't seen one
+
+	Clashes    []PathClash `json:"clashes,omitempty" yaml:"clashes,omitempty"`         // changed paths differing only in case or unicode form
+	IgnoreCase string      `json:"ignore_case,omitempty" yaml:"ignore_case,omitempty"` // how core.ignorecase disagrees with the filesystem, if Clashes were found
+
+	Submodules []SubmoduleState `json:"submodules,omitempty" yaml:"submodules,omitempty"` // submodules not checked out at their recorded commit
+}
+
+// renameTarget returns the new path of a rename or copy line's "old -> new",
+// or path itself for other lines. git quotes paths with spaces, so only a
+// quoted old path can contain the arrow.
+func renameTarget(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if src, err := strconv.QuotedPrefix(path); err == nil {
+			if dst, ok := strings.CutPrefix(path[len(src):], " -> "); ok {
+				return dst
+			}
+			return path
+		}
+	}
+	if _, dst, ok := strings.Cut(path, " -> "); ok {
+		return dst
+	}
+	return path
+}
+
+// unquotePath undoes git's quoting of paths with unusual characters, such
+// as "caf\303\251.txt" for café.txt
+func unquotePath(p string) string {
+	if len(p) < 2 || p[0] != '"' || p[len(p)-1] != '"' {
+		return p
+	}
+	if unquoted, err := strconv.Unquote(p); err == nil {
+		return unquoted
+	}
+	return p
+}
+
+// GetStatusOverview gathers working tree, upstream, in-progress operation
+// and cached PR state without touching the network
+func GetStatusOverview(g git.Service) (*StatusOverview, error) {
+	st, err := GetRepoStatus(g)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, fmt.Errorf("not a git repository")
+	}
+
+	// Empty groups are empty lists, not null, for the JSON output
+	ov := &StatusOverview{
+		RepoStatus: *st,
+		Staged:     []FileChange{},
+		Unstaged:   []FileChange{},
+		Untracked:  []FileChange{},
+		Conflicts:  []FileChange{},
+	}
+	if ov.Changes == nil {
+		ov.Changes = []FileChange{}
+	}
+	for _, c := range st.Changes {
+		switch {
+		case c.Symbol == "?":
+			ov.Untracked = append(ov.Untracked, c)
+		case c.Symbol == "U":
+			ov.Conflicts = append(ov.Conflicts, c)
+		default:
+			// Partially staged files belong in both groups
+			if c.Staged {
+				ov.Staged = append(ov.Staged, c)
+			}
+			if c.Unstaged {
+				ov.Unstaged = append(ov.Unstaged, c)
+			}
+		}
+	}
+
+	if upstream, err := g.Run("for-each-ref", "--format=%(upstream:short)", "refs/heads/"+st.Branch); err == nil {
+		ov.Upstream = strings.TrimSpace(upstream)
+	}
+	if ov.Upstream != "" {
+		ov.Ahead, ov.Behind, _ = g.AheadBehind("@{u}", "HEAD")
+	}
+
+	if merging, _ := g.IsMerging(); merging {
+		ov.Operation = "merge"
+	} else if rebasing, _ := g.IsRebasing(); rebasing {
+		ov.Operation = "rebase"
+	} else if applying, _ := g.IsApplyingPatches(); applying {
+		ov.Operation = "am"
+	}
+	if ov.Operation != "" {
+		ov.Continue = "git " + ov.Operation + " --continue"
+		if series, _ := LoadMboxSeries(g); series != nil && ov.Operation == "am" {
+			ov.Continue = "sage apply-mbox --continue"
+		}
+	}
+
+	paths := make([]string, 0, len(st.Changes))
+	for _, c := range st.Changes {
+		paths = append(paths, c.File)
+	}
+	if ov.Clashes = FindPathClashes(paths); len(ov.Clashes) > 0 {
+		ov.IgnoreCase = IgnoreCaseMismatch(g)
+	}
+
+	ov.Submodules, _ = SubmoduleDrift(g)
+	ov.PR, _ = GetCachedPR(g, st.Branch)
+	return ov, nil
+}
+
 // interpretStatus interprets the status codes from git status --porcelain=v1
 // indexStatus is the status in the staging area (first character)
 // workTreeStatus is the status in the working tree (second character)
@@ -81,6 +204,11 @@ func interpretStatus(indexStatus, workTreeStatus byte) (string, string) {
 	if indexStatus == '?' && workTreeStatus == '?' {
 		return "?", "Untracked"
 	}
+	if indexStatus == 'U' || workTreeStatus == 'U' ||
+		(indexStatus == 'A' && workTreeStatus == 'A') ||
+		(indexStatus == 'D' && workTreeStatus == 'D') {
+		return "U", "Conflicted"
+	}
 
 	switch indexStatus {
 	case 'M':
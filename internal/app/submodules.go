@@ -0,0 +1,157 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// SubmoduleState is a submodule that isn't checked out at the commit the
+// repository records
+type SubmoduleState struct {
+	Path     string `json:"path" yaml:"path"`
+	Recorded string `json:"recorded" yaml:"recorded"`
+	Current  string `json:"current,omitempty" yaml:"current,omitempty"`
+	// State is drifted (checked out elsewhere), uninitialized or conflicted
+	State string `json:"state" yaml:"state"`
+	// Nested submodules belong to another submodule, so their pointer bumps
+	// are committed there
+	Nested bool `json:"nested,omitempty" yaml:"nested,omitempty"`
+}
+
+// SubmoduleDrift lists the submodules that aren't checked out at their
+// recorded commit
+func SubmoduleDrift(g git.Service) ([]SubmoduleState, error) {
+	subs, err := g.Submodules()
+	if err != nil {
+		return nil, err
+	}
+	var drift []SubmoduleState
+	for _, s := range subs {
+		st := SubmoduleState{Path: s.Path, Recorded: s.Recorded, Current: s.Current, Nested: nestedSubmodule(subs, s.Path)}
+		switch {
+		case s.Conflicted:
+			st.State = "conflicted"
+		case !s.Initialized:
+			st.State = "uninitialized"
+		case s.Drifted():
+			st.State = "drifted"
+		default:
+			continue
+		}
+		drift = append(drift, st)
+	}
+	return drift, nil
+}
+
+// nestedSubmodule reports whether path lies inside another submodule
+func nestedSubmodule(subs []git.Submodule, path string) bool {
+	for _, s := range subs {
+		if strings.HasPrefix(path, s.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CommitSubmoduleBumps records the commits the drifted top-level submodules
+// are checked out at, in a commit of their own. It returns what it bumped.
+func CommitSubmoduleBumps(g git.Service) ([]SubmoduleState, error) {
+	drift, err := SubmoduleDrift(g)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the submodules: %w", err)
+	}
+	var bumps []SubmoduleState
+	var paths, lines []string
+	for _, s := range drift {
+		if s.State != "drifted" || s.Nested {
+			continue
+		}
+		bumps = append(bumps, s)
+		paths = append(paths, s.Path)
+		lines = append(lines, fmt.Sprintf("- %s: %s..%s", s.Path, shortHash(s.Recorded), shortHash(s.Current)))
+	}
+	if len(bumps) == 0 {
+		return nil, nil
+	}
+
+	subject := "chore: bump submodules"
+	if len(bumps) == 1 {
+		subject = fmt.Sprintf("chore: bump %s to %s", bumps[0].Path, shortHash(bumps[0].Current))
+	}
+	msg := subject + "\n\n" + strings.Join(lines, "\n") + "\n"
+	if err := g.CommitPaths(msg, false, paths); err != nil {
+		return nil, fmt.Errorf("failed to commit the submodule bumps: %w", err)
+	}
+	return bumps, nil
+}
+
+// updateSubmodulesAfter checks out the commits a sync recorded for the
+// submodules, comparing HEAD with before, the commit the branch was at.
+// Submodules added since are cloned. One moved to another commit before the
+// sync is someone's work in progress, so it's left alone with a warning.
+func updateSubmodulesAfter(g git.Service, before string) {
+	subs, err := g.Submodules()
+	if err != nil || len(subs) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(subs))
+	for _, s := range subs {
+		if !nestedSubmodule(subs, s.Path) {
+			paths = append(paths, s.Path)
+		}
+	}
+	previous := recordedSubmodules(g, before, paths)
+
+	var update, kept []string
+	for _, s := range subs {
+		if nestedSubmodule(subs, s.Path) || s.Conflicted {
+			continue
+		}
+		old, existed := previous[s.Path]
+		switch {
+		case old == s.Recorded:
+			// The sync didn't move it
+		case !s.Initialized:
+			if !existed {
+				update = append(update, s.Path)
+			}
+		case s.Current == old || s.Current == s.Recorded:
+			update = append(update, s.Path)
+		default:
+			kept = append(kept, s.Path)
+		}
+	}
+
+	if len(update) > 0 {
+		if err := g.UpdateSubmodules(update...); err != nil {
+			ui.Warning(fmt.Sprintf("Couldn't update the submodules: %v", err))
+			ui.Info("Run 'git submodule update --init --recursive' to check them out")
+		} else {
+			ui.Info(fmt.Sprintf("Updated %d submodule%s: %s", len(update), ui.Plural(len(update)), strings.Join(update, ", ")))
+		}
+	}
+	for _, p := range kept {
+		ui.Warning(fmt.Sprintf("Left submodule %s where it was, as it had been moved from its recorded commit; run 'git submodule update %s' to check out the new one", p, p))
+	}
+}
+
+// recordedSubmodules returns the commits ref records for the submodules at
+// paths
+func recordedSubmodules(g git.Service, ref string, paths []string) map[string]string {
+	recorded := map[string]string{}
+	out, err := g.Run(append([]string{"ls-tree", ref, "--"}, paths...)...)
+	if err != nil {
+		return recorded
+	}
+	for _, line := range strings.Split(out, "\n") {
+		// <mode> commit <hash>\t<path>
+		meta, path, ok := strings.Cut(line, "\t")
+		if fields := strings.Fields(meta); ok && len(fields) == 3 && fields[1] == "commit" {
+			recorded[path] = fields[2]
+		}
+	}
+	return recorded
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// withSubmodule creates a bare lib repository with one commit and adds it
+// to r as the submodule lib, pushed to origin
+func (r *testRepo) withSubmodule() string {
+	r.t.Helper()
+	// Git refuses file:// submodules unless told otherwise
+	r.git("config", "--global", "protocol.file.allow", "always")
+	lib := filepath.Join(r.root, "lib.git")
+	r.gitIn(r.root, "init", "--bare", lib)
+	work := filepath.Join(r.root, "lib-work")
+	r.gitIn(r.root, "clone", lib, work)
+	r.commitIn(work, "lib.txt", "v1\n", "Lib v1")
+	r.gitIn(work, "push", "origin", "main")
+
+	r.git("submodule", "add", lib, "lib")
+	r.git("commit", "-m", "Add lib")
+	r.git("push", "origin", "main")
+	return work
+}
+
+func TestSyncUpdatesSubmodules(t *testing.T) {
+	r := newTestRepo(t)
+	libWork := r.withSubmodule()
+
+	// A teammate moves lib on and records the new commit
+	r.commitIn(libWork, "lib.txt", "v2\n", "Lib v2")
+	r.gitIn(libWork, "push", "origin", "main")
+	other := r.clone("other")
+	r.gitIn(other, "submodule", "update", "--init")
+	r.gitIn(filepath.Join(other, "lib"), "pull", "origin", "main")
+	r.gitIn(other, "commit", "-am", "Bump lib")
+	r.gitIn(other, "push", "origin", "main")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if got := r.read("lib/lib.txt"); got != "v2\n" {
+		t.Errorf("lib/lib.txt = %q, want the bumped submodule's v2", got)
+	}
+	r.assertClean()
+}
+
+func TestCommitSubmoduleBumps(t *testing.T) {
+	r := newTestRepo(t)
+	r.withSubmodule()
+	g := git.NewShellGit()
+	if drift, err := SubmoduleDrift(g); err != nil || len(drift) != 0 {
+		t.Fatalf("fresh submodule drift = %+v, %v", drift, err)
+	}
+
+	r.commitIn(filepath.Join(r.dir, "lib"), "lib.txt", "local\n", "Local lib work")
+	r.write("unrelated.txt", "staged but not part of the bump\n")
+	r.git("add", "unrelated.txt")
+	drift, err := SubmoduleDrift(g)
+	if err != nil || len(drift) != 1 || drift[0].Path != "lib" || drift[0].State != "drifted" {
+		t.Fatalf("drift = %+v, %v", drift, err)
+	}
+
+	bumps, err := CommitSubmoduleBumps(g)
+	if err != nil || len(bumps) != 1 {
+		t.Fatalf("CommitSubmoduleBumps = %+v, %v", bumps, err)
+	}
+	if got := r.git("log", "-1", "--format=%s"); !strings.HasPrefix(got, "chore: bump lib to ") {
+		t.Errorf("subject = %q", got)
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD"); got != "lib" {
+		t.Errorf("the bump commit changed %q", got)
+	}
+	if got := r.git("status", "--porcelain"); got != "A  unrelated.txt" {
+		t.Errorf("status:\n%s", got)
+	}
+	if drift, _ = SubmoduleDrift(g); len(drift) != 0 {
+		t.Errorf("drift after committing = %+v", drift)
+	}
+}
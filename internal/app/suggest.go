@@ -0,0 +1,212 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// Suggestion is a recommended next command with the reason it is being suggested
+type Suggestion struct {
+	Command string
+	Reason  string
+}
+
+// SuggestionState is a snapshot of the repository (and PR) state used by the suggestion rules
+type SuggestionState struct {
+	LastCommand   string
+	Branch        string
+	DefaultBranch string
+	Clean         bool
+	Merging       bool
+	Rebasing      bool
+	HasUpstream   bool
+	Ahead         int // Commits not yet pushed to the upstream
+	Behind        int // Commits on the default branch not in this branch
+	PR            *CachedPR
+}
+
+// suggestionRule inspects the state and returns a suggestion, or nil if it does not apply
+type suggestionRule func(s SuggestionState) *Suggestion
+
+// suggestionRules are evaluated in order; earlier rules take priority
+var suggestionRules = []suggestionRule{
+	func(s SuggestionState) *Suggestion {
+		if s.Merging || s.Rebasing {
+			return &Suggestion{"sage resolve", "an operation is in progress with conflicts to resolve"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if !s.Clean && s.LastCommand != "commit" {
+			return &Suggestion{"sage commit", "you have uncommitted changes"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.Branch == s.DefaultBranch {
+			return nil
+		}
+		if s.HasUpstream && s.Ahead > 0 {
+			return &Suggestion{"sage push", "your branch has unpushed commits"}
+		}
+		if !s.HasUpstream && s.LastCommand == "commit" {
+			return &Suggestion{"sage push", "your branch has not been pushed yet"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.Branch != s.DefaultBranch && s.Behind > 0 {
+			return &Suggestion{"sage sync", "'" + s.DefaultBranch + "' has new commits"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.Branch == s.DefaultBranch || !s.HasUpstream {
+			return nil
+		}
+		if s.PR == nil {
+			return &Suggestion{"sage pr create", "this branch has no pull request yet"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.PR != nil && s.PR.State == "open" && s.PR.ChecksFailing {
+			return &Suggestion{"sage ci why", "checks are failing on your pull request"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.PR != nil && s.PR.State == "open" && s.LastCommand != "pr status" {
+			return &Suggestion{"sage pr status", "check reviews and checks on your pull request"}
+		}
+		return nil
+	},
+	func(s SuggestionState) *Suggestion {
+		if s.Branch == s.DefaultBranch && s.Clean {
+			return &Suggestion{"sage start <branch>", "start a new piece of work"}
+		}
+		return nil
+	},
+}
+
+// GatherSuggestionState collects the repository state used to suggest next steps.
+// The PR comes from the cache; ghc is only called to refresh it, and its
+// checks, after commands that change the PR or what it points at. ghc may
+// return nil, in which case the cached PR is used as is.
+func GatherSuggestionState(g git.Service, ghc func() gh.Client, lastCommand string) (SuggestionState, error) {
+	state := SuggestionState{LastCommand: lastCommand}
+
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return state, err
+	}
+	state.Branch = branch
+
+	db, err := baseOrMain(g, branch)
+	if err != nil {
+		db = "main"
+	}
+	state.DefaultBranch = db
+
+	state.Clean, _ = g.IsClean()
+	state.Merging, _ = g.IsMerging()
+	state.Rebasing, _ = g.IsRebasing()
+
+	// The rules only look at upstream, counts and the PR off the default branch
+	if branch == db {
+		return state, nil
+	}
+
+	if ahead, err := g.GetCommitCount("@{u}..HEAD"); err == nil {
+		state.HasUpstream = true
+		state.Ahead = ahead
+	}
+	if behind, err := g.GetCommitCount("HEAD..origin/" + db); err == nil {
+		state.Behind = behind
+	}
+
+	if state.HasUpstream {
+		if refreshesPR(lastCommand) {
+			if client := ghc(); client != nil {
+				refreshPRWithChecks(g, client, branch)
+			}
+		}
+		state.PR, _ = GetCachedPR(g, branch)
+	}
+
+	return state, nil
+}
+
+// prChangingCommands can change the branch's PR or the commit its checks
+// run on, making it worth asking GitHub again
+var prChangingCommands = map[string]bool{
+	"push":      true,
+	"sync":      true,
+	"pr create": true,
+	"pr update": true,
+	"pr edit":   true,
+	"pr ready":  true,
+	"pr draft":  true,
+	"pr close":  true,
+	"pr reopen": true,
+	"pr merge":  true,
+}
+
+// refreshesPR reports whether a command can have changed the branch's PR
+// or its checks
+func refreshesPR(command string) bool {
+	return prChangingCommands[command]
+}
+
+// refreshPRWithChecks caches branch's PR along with whether its checks are
+// failing. Lookup errors leave the cache as it was.
+func refreshPRWithChecks(g git.Service, ghc gh.Client, branch string) {
+	pr, err := ghc.GetPRForBranch(branch)
+	if err != nil {
+		return
+	}
+	if pr != nil {
+		runs, err := ghc.ListPRCheckRuns(pr.Number)
+		if err != nil {
+			return
+		}
+		pr.Checks = []gh.Check{}
+		for _, r := range runs {
+			pr.Checks = append(pr.Checks, gh.Check{Name: r.Name, Status: r.Status, Conclusion: r.Conclusion, HTMLURL: r.HTMLURL})
+		}
+	}
+	_ = CachePR(g, branch, pr)
+}
+
+// SuggestNextSteps runs the suggestion rules against the state and returns
+// at most limit suggestions, highest priority first.
+func SuggestNextSteps(state SuggestionState, limit int) []Suggestion {
+	var out []Suggestion
+	for _, rule := range suggestionRules {
+		if s := rule(state); s != nil {
+			// Don't suggest the command the user just ran
+			if suggestedCommand(s.Command) == state.LastCommand {
+				continue
+			}
+			out = append(out, *s)
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// suggestedCommand returns the sage command a suggestion runs, without the
+// "sage" and any <placeholder> arguments, as in "pr status" or "start"
+func suggestedCommand(command string) string {
+	var words []string
+	for _, w := range strings.Fields(strings.TrimPrefix(command, "sage ")) {
+		if !strings.HasPrefix(w, "<") {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " ")
+}
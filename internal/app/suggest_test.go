@@ -0,0 +1,149 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// fakeSuggestGitHub serves one PR with failing checks and counts lookups
+type fakeSuggestGitHub struct {
+	gh.Client
+	lookups int
+}
+
+func (f *fakeSuggestGitHub) GetPRForBranch(string) (*gh.PullRequest, error) {
+	f.lookups++
+	pr := &gh.PullRequest{Number: 7, State: "open"}
+	pr.Head.SHA = "abc"
+	return pr, nil
+}
+
+func (f *fakeSuggestGitHub) ListPRCheckRuns(int) ([]gh.CheckRun, error) {
+	return []gh.CheckRun{{Name: "lint", Status: "completed", Conclusion: "success"}, {Name: "test", Status: "completed", Conclusion: "failure"}}, nil
+}
+
+func TestGatherSuggestionStateUsesCachedPR(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("app.txt", "app\n", "Add app")
+	r.git("push", "-u", "origin", "feature")
+
+	g := git.NewShellGit()
+	fake := &fakeSuggestGitHub{}
+	client := func() gh.Client { return fake }
+
+	state, err := GatherSuggestionState(g, client, "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.lookups != 0 || state.PR != nil {
+		t.Fatalf("status: %d lookups and PR %+v; want neither", fake.lookups, state.PR)
+	}
+
+	if _, err := GatherSuggestionState(g, client, "push"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.lookups != 1 {
+		t.Fatalf("push: %d lookups, want 1", fake.lookups)
+	}
+
+	// Later commands read what the push saw from the cache
+	state, err = GatherSuggestionState(g, client, "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.lookups != 1 || state.PR == nil || !state.PR.ChecksFailing {
+		t.Fatalf("status: %d lookups and PR %+v; want the cached PR with failing checks", fake.lookups, state.PR)
+	}
+	if s := SuggestNextSteps(state, 1); len(s) != 1 || s[0].Command != "sage ci why" {
+		t.Errorf("suggestions = %+v, want sage ci why", s)
+	}
+}
+
+func TestCachePRKeepsChecksForSameHead(t *testing.T) {
+	newTestRepo(t)
+	g := git.NewShellGit()
+
+	pr := &gh.PullRequest{Number: 7, State: "open", Checks: []gh.Check{{Conclusion: "failure"}}}
+	pr.Head.SHA = "abc"
+	if err := CachePR(g, "feature", pr); err != nil {
+		t.Fatal(err)
+	}
+
+	// A lookup without checks doesn't clear them for the same commit...
+	pr.Checks = nil
+	if err := CachePR(g, "feature", pr); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := GetCachedPR(g, "feature"); cached == nil || !cached.ChecksFailing {
+		t.Errorf("got %+v, want failing checks kept", cached)
+	}
+
+	// ...but a new head commit hasn't been checked yet
+	pr.Head.SHA = "def"
+	if err := CachePR(g, "feature", pr); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := GetCachedPR(g, "feature"); cached == nil || cached.ChecksFailing {
+		t.Errorf("got %+v, want no failing checks for the new head", cached)
+	}
+}
+
+func TestGatherSuggestionStateRefreshesAfterPRChanges(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("app.txt", "app\n", "Add app")
+	r.git("push", "-u", "origin", "feature")
+
+	g := git.NewShellGit()
+	fake := &fakeSuggestGitHub{}
+	client := func() gh.Client { return fake }
+
+	for _, command := range []string{"pr list", "pr status", "pr view", "log"} {
+		if _, err := GatherSuggestionState(g, client, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.lookups != 0 {
+		t.Errorf("%d lookups after read-only commands, want none", fake.lookups)
+	}
+	for _, command := range []string{"pr create", "sync"} {
+		if _, err := GatherSuggestionState(g, client, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.lookups != 2 {
+		t.Errorf("%d lookups after pr create and sync, want 2", fake.lookups)
+	}
+}
+
+func TestSuggestNextStepsSkipsCommandJustRun(t *testing.T) {
+	openPR := SuggestionState{Branch: "feature", DefaultBranch: "main", Clean: true, HasUpstream: true, PR: &CachedPR{State: "open"}}
+	onMain := SuggestionState{Branch: "main", DefaultBranch: "main", Clean: true}
+
+	tests := []struct {
+		last  string
+		state SuggestionState
+		want  string
+	}{
+		{"pr", openPR, "sage pr status"},
+		{"pr create", openPR, "sage pr status"},
+		{"pr status", openPR, ""},
+		{"status", onMain, "sage start <branch>"},
+		{"start", onMain, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.last, func(t *testing.T) {
+			tt.state.LastCommand = tt.last
+			got := ""
+			if s := SuggestNextSteps(tt.state, 1); len(s) > 0 {
+				got = s[0].Command
+			}
+			if got != tt.want {
+				t.Errorf("after %q suggested %q, want %q", tt.last, got, tt.want)
+			}
+		})
+	}
+}
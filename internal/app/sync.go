@@ -1,10 +1,13 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
 	"github.com/crazywolf132/sage/internal/ui"
 )
@@ -17,6 +20,14 @@ type SyncOptions struct {
 	Verbose      bool
 	Abort        bool
 	Continue     bool
+	// Paths limits the preview and the conflicts sync stops for to these
+	// directories or globs; see SyncScope
+	Paths []string
+	// Sparse adds the sparse checkout's directories to Paths
+	Sparse bool
+	// TakeIncoming settles conflicts outside Paths with the parent's
+	// version without asking
+	TakeIncoming bool
 }
 
 // SyncResult represents the outcome of a sync operation
@@ -28,6 +39,7 @@ type SyncResult struct {
 	Conflicts    []string
 	StashedFiles bool
 	StashRef     string    // Reference to created stash
+	StashBranch  string    // Branch the stashed changes came from
 	OriginalRef  string    // Original HEAD before sync
 	StartTime    time.Time // When sync started
 }
@@ -88,6 +100,16 @@ To start over: 'sage sync --abort'`)
 	}
 }
 
+// ExitCode makes sync conflicts exit with exitcode.Conflict
+func (e *SyncError) ExitCode() int {
+	switch e.Type {
+	case "conflict", "rebase", "merge":
+		return exitcode.Conflict
+	default:
+		return exitcode.Failure
+	}
+}
+
 // SyncBranch synchronizes the current branch with its parent (default) branch.
 // It handles all common scenarios automatically and provides clear guidance when manual intervention is needed.
 func SyncBranch(g git.Service, opts SyncOptions) error {
@@ -102,18 +124,38 @@ func SyncBranch(g git.Service, opts SyncOptions) error {
 		ui.Info("Verbose mode: Displaying detailed operation logs")
 	}
 
-	// Handle abort/continue flags first
-	if result := handleSyncFlags(g, opts.Abort, opts.Continue); result.NeedsAction {
-		return handleSyncResult(result)
+	scope := &SyncScope{Paths: opts.Paths}
+	if opts.Sparse {
+		sparse, err := SparseScope(g)
+		if err != nil {
+			return err
+		}
+		sparse.Paths = append(sparse.Paths, opts.Paths...)
+		scope = &sparse
 	}
+	scope.TakeIncoming = opts.TakeIncoming
 
-	return performSync(g, opts, progress)
+	// Handle abort/continue flags first; either one is the whole sync
+	if result := handleSyncFlags(g, opts.Abort, opts.Continue, scope); result.NeedsAction || opts.Abort || opts.Continue {
+		if err := handleSyncResult(result); err != nil {
+			return err
+		}
+		return restoreStoppedSync(g, progress)
+	}
+
+	if !opts.DryRun {
+		JournalSnapshot(g, "sage sync")
+	}
+	if err := performSync(g, opts, scope, progress); err != nil {
+		return handleSyncError(g, err, nil)
+	}
+	return nil
 }
 
 func verifyRepoState(g git.Service) error {
 	repo, err := g.IsRepo()
 	if err != nil || !repo {
-		return fmt.Errorf("not a git repository")
+		return git.ErrNotARepo
 	}
 
 	// Check if we're in a detached HEAD state
@@ -122,18 +164,18 @@ func verifyRepoState(g git.Service) error {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
 	if head == "HEAD" {
-		return fmt.Errorf("cannot sync in detached HEAD state")
+		return fmt.Errorf("cannot sync in a %w state", git.ErrDetachedHead)
 	}
 
 	return nil
 }
 
-func handleSyncFlags(g git.Service, abort, cont bool) SyncResult {
+func handleSyncFlags(g git.Service, abort, cont bool, scope *SyncScope) SyncResult {
 	if abort {
 		return handleAbort(g)
 	}
 	if cont {
-		return handleContinue(g)
+		return handleContinue(g, scope)
 	}
 	return SyncResult{Success: true}
 }
@@ -169,7 +211,10 @@ func handleAbort(g git.Service) SyncResult {
 	}
 }
 
-func handleContinue(g git.Service) SyncResult {
+// handleContinue finishes a stopped merge or rebase. With a scope, conflicts
+// later commits of a rebase run into outside it are settled as they were
+// when the sync started.
+func handleContinue(g git.Service, scope *SyncScope) SyncResult {
 	sg, ok := g.(*git.ShellGit)
 	if !ok {
 		return SyncResult{
@@ -180,7 +225,7 @@ func handleContinue(g git.Service) SyncResult {
 
 	if merging, _ := g.IsMerging(); merging {
 		err := sg.MergeContinue()
-		if err != nil {
+		if err != nil && !autoResolveConflicts(g, scope) {
 			conflicts, _ := sg.ListConflictedFiles()
 			return SyncResult{
 				Success:     false,
@@ -190,6 +235,8 @@ func handleContinue(g git.Service) SyncResult {
 				Conflicts:   strings.Split(conflicts, "\n"),
 			}
 		}
+		reportTaken(g, scope, "the parent branch")
+		updateSubmodulesAfter(g, "ORIG_HEAD")
 		return SyncResult{
 			Success: true,
 			Message: "Successfully continued merge",
@@ -198,7 +245,7 @@ func handleContinue(g git.Service) SyncResult {
 
 	if rebase, _ := g.IsRebasing(); rebase {
 		err := sg.RebaseContinue()
-		if err != nil {
+		if err != nil && !autoResolveConflicts(g, scope) {
 			conflicts, _ := sg.ListConflictedFiles()
 			return SyncResult{
 				Success:     false,
@@ -208,6 +255,8 @@ func handleContinue(g git.Service) SyncResult {
 				Conflicts:   strings.Split(conflicts, "\n"),
 			}
 		}
+		reportTaken(g, scope, "the parent branch")
+		updateSubmodulesAfter(g, "ORIG_HEAD")
 		return SyncResult{
 			Success: true,
 			Message: "Successfully continued rebase",
@@ -220,7 +269,7 @@ func handleContinue(g git.Service) SyncResult {
 	}
 }
 
-func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) error {
+func performSync(g git.Service, opts SyncOptions, scope *SyncScope, progress *ui.SyncProgress) error {
 	var result SyncResult
 	result.StartTime = time.Now()
 
@@ -252,6 +301,7 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 		return err
 	}
 
+	var stash syncStash
 	if hasChanges {
 		progress.StartStep("stash")
 		stashed, stashRef, err := handleWorkingDirectory(g)
@@ -261,6 +311,13 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 		}
 		result.StashedFiles = stashed
 		result.StashRef = stashRef
+		result.StashBranch = curBranch
+		stash = syncStash{Message: stashRef, Branch: curBranch}
+		if stashed {
+			if err := saveStashRecord(g, syncStashFile, stash); err != nil && opts.Verbose {
+				ui.Warning(fmt.Sprintf("Couldn't record the stash: %v", err))
+			}
+		}
 		progress.CompleteStep("stash", true)
 	} else {
 		progress.SkipStep("stash")
@@ -268,10 +325,14 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 
 	// 4. Remote Updates
 	progress.StartStep("fetch")
-	if err := g.FetchAll(); err != nil {
+	if err := g.FetchRemotes(nil, func(remote string, err error) {
+		if opts.Verbose && err == nil {
+			ui.Info(fmt.Sprintf("Fetched %s", remote))
+		}
+	}); err != nil {
 		progress.CompleteStep("fetch", false)
 		if result.StashedFiles {
-			restoreChanges(g, progress)
+			restoreChanges(g, progress, stash)
 		}
 		return fmt.Errorf("Failed to fetch updates: %w", err)
 	}
@@ -282,7 +343,7 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 	if err := g.Pull(); err != nil {
 		progress.CompleteStep("pull", false)
 		if result.StashedFiles {
-			restoreChanges(g, progress)
+			restoreChanges(g, progress, stash)
 		}
 		return fmt.Errorf("Failed to pull updates: %w", err)
 	}
@@ -309,6 +370,10 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 	// Determine if we have diverged (have unique commits)
 	hasDiverged := mergeBase != currentHead
 
+	if !scope.Empty() {
+		previewIncoming(g, scope, mergeBase, parentBranch)
+	}
+
 	// If we've diverged, choose strategy based on config or divergence
 	if hasDiverged {
 		progress.StartStep("integrate")
@@ -329,10 +394,10 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 			if opts.Verbose {
 				ui.Info("Using merge strategy based on configuration")
 			}
-			if err := g.Merge(parentBranch); err != nil {
+			if err := g.Merge(parentBranch); err != nil && !autoResolveConflicts(g, scope) {
 				progress.CompleteStep("integrate", false)
 				if result.StashedFiles {
-					restoreChanges(g, progress)
+					restoreChanges(g, progress, stash)
 				}
 				return fmt.Errorf("failed to merge %s: %w", parentBranch, err)
 			}
@@ -340,10 +405,10 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 			if opts.Verbose {
 				ui.Info("Using rebase strategy based on configuration")
 			}
-			if err := rebaseBranch(g, parentBranch); err != nil {
+			if err := rebaseBranch(g, parentBranch); err != nil && !autoResolveConflicts(g, scope) {
 				progress.CompleteStep("integrate", false)
 				if result.StashedFiles {
-					restoreChanges(g, progress)
+					restoreChanges(g, progress, stash)
 				}
 				return err
 			}
@@ -354,10 +419,10 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 					ui.Info("Using merge strategy to preserve branch history")
 				}
 				ui.Info("Branch has diverged significantly - using merge strategy")
-				if err := g.PullMerge(); err != nil {
+				if err := g.PullMerge(); err != nil && !autoResolveConflicts(g, scope) {
 					progress.CompleteStep("integrate", false)
 					if result.StashedFiles {
-						restoreChanges(g, progress)
+						restoreChanges(g, progress, stash)
 					}
 					return fmt.Errorf("failed to merge %s: %w", parentBranch, err)
 				}
@@ -365,20 +430,24 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 				if opts.Verbose {
 					ui.Info("Using rebase strategy for a clean history")
 				}
-				if err := rebaseBranch(g, parentBranch); err != nil {
+				if err := rebaseBranch(g, parentBranch); err != nil && !autoResolveConflicts(g, scope) {
 					progress.CompleteStep("integrate", false)
 					if result.StashedFiles {
-						restoreChanges(g, progress)
+						restoreChanges(g, progress, stash)
 					}
 					return err
 				}
 			}
 		}
 		progress.CompleteStep("integrate", true)
+		reportTaken(g, scope, parentBranch)
 	} else {
 		progress.SkipStep("integrate")
 	}
 
+	// The pull or the integration may have moved submodule pointers
+	updateSubmodulesAfter(g, origRef)
+
 	// Then check if we're behind remote (if it exists)
 	behind, err := isBehindRemote(g, curBranch)
 	if err == nil && behind {
@@ -391,7 +460,7 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 			if err := pushChanges(g, curBranch, opts); err != nil {
 				progress.CompleteStep("push", false)
 				if result.StashedFiles {
-					restoreChanges(g, progress)
+					restoreChanges(g, progress, stash)
 				}
 				return err
 			}
@@ -408,7 +477,7 @@ func performSync(g git.Service, opts SyncOptions, progress *ui.SyncProgress) err
 
 	// 7. Restore Changes
 	if result.StashedFiles {
-		return restoreChanges(g, progress)
+		return restoreChanges(g, progress, stash)
 	} else {
 		progress.SkipStep("restore")
 	}
@@ -434,7 +503,10 @@ func getBranchInfo(g git.Service, targetBranch string) (string, string, error) {
 
 	parentBranch := targetBranch
 	if parentBranch == "" {
-		parentBranch, err = g.DefaultBranch()
+		parentBranch, err = BaseBranch(g, curBranch)
+		if errors.Is(err, ErrBaseMissing) {
+			return "", "", err
+		}
 		if err != nil {
 			return "", "", fmt.Errorf("failed to get default branch: %w", err)
 		}
@@ -567,15 +639,17 @@ func integrateChanges(g git.Service, parentBranch string, opts SyncOptions) erro
 
 // getPreferredMergeStrategy gets the user's preferred merge strategy from config
 func getPreferredMergeStrategy(g git.Service) string {
-	sg, ok := g.(*git.ShellGit)
-	if !ok {
-		return "" // default to auto-selection
+	// sage config (which may be overridden per branch) wins over git config
+	strategy := config.Get("sync.strategy", true)
+	if strategy == "" {
+		sg, ok := g.(*git.ShellGit)
+		if !ok {
+			return "" // default to auto-selection
+		}
+		strategy, _ = sg.Run("config", "--get", "sage.merge.strategy")
+		strategy = strings.TrimSpace(strategy)
 	}
 
-	// Try to get config from git or environment
-	strategy, _ := sg.Run("config", "--get", "sage.merge.strategy")
-	strategy = strings.TrimSpace(strategy)
-
 	// Validate strategy
 	switch strategy {
 	case "merge", "rebase":
@@ -634,7 +708,7 @@ func handleSyncResult(result SyncResult) error {
 }
 
 func handleSyncError(g git.Service, err error, result *SyncResult) error {
-	if strings.Contains(err.Error(), "conflict") {
+	if errors.Is(err, git.ErrMergeConflict) {
 		sg, ok := g.(*git.ShellGit)
 		if !ok {
 			return err
@@ -647,13 +721,6 @@ func handleSyncError(g git.Service, err error, result *SyncResult) error {
 		}
 	}
 
-	if strings.Contains(err.Error(), "failed to rebase") || strings.Contains(err.Error(), "failed to merge") {
-		return &SyncError{
-			Type:    "conflict",
-			Message: "Merge conflicts detected. Please resolve conflicts and run 'sage sync --continue'",
-		}
-	}
-
 	return err
 }
 
@@ -676,16 +743,12 @@ func isBehindRemote(g git.Service, branch string) (bool, error) {
 	return behind, nil
 }
 
-// Helper to restore changes with progress tracking
-func restoreChanges(g git.Service, progress *ui.SyncProgress) error {
-	progress.StartStep("restore")
-	if err := g.StashPop(); err != nil {
-		progress.CompleteStep("restore", false)
-		return &SyncError{
-			Type:    "stash",
-			Message: "Failed to restore your changes",
-		}
+// restoreStoppedSync brings back the changes a sync stopped for conflicts
+// stashed, once --continue or --abort has finished it
+func restoreStoppedSync(g git.Service, progress *ui.SyncProgress) error {
+	stash, err := loadStashRecord(g, syncStashFile)
+	if err != nil || stash == nil {
+		return err
 	}
-	progress.CompleteStep("restore", true)
-	return nil
+	return restoreChanges(g, progress, *stash)
 }
@@ -0,0 +1,591 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// These tests drive SyncBranch end to end against real repositories: a bare
+// origin and clones of it in a temp dir, with git's config isolated from the
+// machine running them. SyncBranch works on the current directory, so they
+// can't run in parallel.
+
+// testRepo is a clone of a bare origin, and the current directory while a
+// test runs
+type testRepo struct {
+	t      *testing.T
+	root   string
+	origin string
+	dir    string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping test against a real repository in short mode")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitconfig := `[user]
+	name = Sage Test
+	email = sage@example.com
+[init]
+	defaultBranch = main
+[core]
+	editor = true
+[pull]
+	rebase = false
+[commit]
+	gpgsign = false
+`
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	r := &testRepo{t: t, root: root, origin: filepath.Join(root, "origin.git"), dir: filepath.Join(root, "work")}
+	r.gitIn(root, "init", "--bare", r.origin)
+	r.gitIn(root, "clone", r.origin, r.dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(r.dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	r.commit("README.md", "hello\n", "Initial commit")
+	r.git("push", "-u", "origin", "main")
+	r.git("remote", "set-head", "origin", "main")
+	return r
+}
+
+// gitIn runs git in dir and returns its trimmed output
+func (r *testRepo) gitIn(dir string, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	return r.gitIn(r.dir, args...)
+}
+
+// commitIn writes file in dir and commits it
+func (r *testRepo) commitIn(dir, file, content, msg string) {
+	r.t.Helper()
+	r.write(filepath.Join(dir, file), content)
+	r.gitIn(dir, "add", file)
+	r.gitIn(dir, "commit", "-m", msg)
+}
+
+func (r *testRepo) commit(file, content, msg string) {
+	r.t.Helper()
+	r.commitIn(r.dir, file, content, msg)
+}
+
+func (r *testRepo) write(path, content string) {
+	r.t.Helper()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.dir, path)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *testRepo) read(file string) string {
+	r.t.Helper()
+	b, err := os.ReadFile(filepath.Join(r.dir, file))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return string(b)
+}
+
+// clone makes a second clone of origin, standing in for a teammate
+func (r *testRepo) clone(name string) string {
+	r.t.Helper()
+	dir := filepath.Join(r.root, name)
+	r.gitIn(r.root, "clone", r.origin, dir)
+	return dir
+}
+
+func (r *testRepo) rev(ref string) string {
+	r.t.Helper()
+	return r.git("rev-parse", ref)
+}
+
+func (r *testRepo) isAncestor(ancestor, ref string) bool {
+	r.t.Helper()
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, ref)
+	cmd.Dir = r.dir
+	return cmd.Run() == nil
+}
+
+// feature starts a pushed feature branch with one commit, then moves main
+// on with another, so the two have diverged
+func (r *testRepo) feature(featFile, featContent, mainFile, mainContent string) {
+	r.t.Helper()
+	r.git("checkout", "-b", "feature")
+	r.commit(featFile, featContent, "Feature work")
+	r.git("push", "-u", "origin", "feature")
+	r.git("checkout", "main")
+	r.commit(mainFile, mainContent, "Main moves on")
+	r.git("push", "origin", "main")
+	r.git("checkout", "feature")
+}
+
+func (r *testRepo) assertClean() {
+	r.t.Helper()
+	if out := r.git("status", "--porcelain"); out != "" {
+		r.t.Errorf("working tree isn't clean:\n%s", out)
+	}
+	if out := r.git("stash", "list"); out != "" {
+		r.t.Errorf("stash isn't empty:\n%s", out)
+	}
+}
+
+func TestSyncUpToDate(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.commit("feature.txt", "feature\n", "Feature work")
+	r.git("push", "-u", "origin", "feature")
+	head := r.rev("HEAD")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if got := r.rev("HEAD"); got != head {
+		t.Errorf("HEAD moved from %s to %s", head, got)
+	}
+	if got := r.rev("origin/feature"); got != head {
+		t.Errorf("origin/feature = %s, want %s", got, head)
+	}
+	r.assertClean()
+}
+
+func TestSyncPullsTrunkBehindOrigin(t *testing.T) {
+	r := newTestRepo(t)
+	other := r.clone("other")
+	r.commitIn(other, "other.txt", "from a teammate\n", "Teammate work")
+	r.gitIn(other, "push", "origin", "main")
+	want := r.gitIn(other, "rev-parse", "HEAD")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if got := r.rev("HEAD"); got != want {
+		t.Errorf("HEAD = %s, want the teammate's commit %s", got, want)
+	}
+	if got := r.read("other.txt"); got != "from a teammate\n" {
+		t.Errorf("other.txt = %q", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncRebasesDivergedBranch(t *testing.T) {
+	r := newTestRepo(t)
+	r.feature("feature.txt", "feature\n", "main.txt", "main\n")
+	before := r.rev("HEAD")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	head := r.rev("HEAD")
+	if head == before {
+		t.Fatal("HEAD didn't move")
+	}
+	if !r.isAncestor("main", "HEAD") {
+		t.Error("feature isn't based on main after syncing")
+	}
+	if merges := r.git("rev-list", "--merges", "main..HEAD"); merges != "" {
+		t.Errorf("rebase left merge commits: %s", merges)
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != "Feature work" {
+		t.Errorf("HEAD subject = %q, want the feature commit", got)
+	}
+	if got := r.rev("origin/feature"); got != head {
+		t.Errorf("origin/feature = %s, want the rebased HEAD %s", got, head)
+	}
+	r.assertClean()
+}
+
+func TestSyncMergesDivergedBranchWhenConfigured(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("config", "sage.merge.strategy", "merge")
+	r.feature("feature.txt", "feature\n", "main.txt", "main\n")
+	before := r.rev("HEAD")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	head := r.rev("HEAD")
+	if got := r.git("rev-list", "--parents", "-n", "1", "HEAD"); got != head+" "+before+" "+r.rev("main") {
+		t.Errorf("HEAD isn't a merge of feature and main: %s", got)
+	}
+	if got := r.rev("origin/feature"); got != head {
+		t.Errorf("origin/feature = %s, want the merge %s", got, head)
+	}
+	r.assertClean()
+}
+
+func TestSyncKeepsLocalChanges(t *testing.T) {
+	r := newTestRepo(t)
+	r.feature("feature.txt", "feature\n", "main.txt", "main\n")
+	r.write("feature.txt", "feature\nnot committed yet\n")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if !r.isAncestor("main", "HEAD") {
+		t.Error("feature isn't based on main after syncing")
+	}
+	if got := r.read("feature.txt"); got != "feature\nnot committed yet\n" {
+		t.Errorf("feature.txt = %q, want the uncommitted change back", got)
+	}
+	if out := r.git("stash", "list"); out != "" {
+		t.Errorf("stash wasn't popped:\n%s", out)
+	}
+}
+
+// conflict sets up feature and main changing the same line of README.md and
+// syncs, which must stop on the conflict. It returns feature's head before.
+func (r *testRepo) conflict() string {
+	r.t.Helper()
+	r.feature("README.md", "hello from feature\n", "README.md", "hello from main\n")
+	before := r.rev("HEAD")
+
+	err := SyncBranch(git.NewShellGit(), SyncOptions{})
+	if err == nil {
+		r.t.Fatal("SyncBranch succeeded despite the conflict")
+	}
+	if code := exitcode.Of(err); code == exitcode.OK {
+		r.t.Errorf("exit code = %d, want a failure", code)
+	}
+	if files := r.git("diff", "--name-only", "--diff-filter=U"); files != "README.md" {
+		r.t.Errorf("conflicted files = %q, want README.md", files)
+	}
+	return before
+}
+
+func TestSyncConflictAbort(t *testing.T) {
+	r := newTestRepo(t)
+	before := r.conflict()
+	if rebasing, _ := git.NewShellGit().IsRebasing(); !rebasing {
+		t.Fatal("no rebase in progress after the conflict")
+	}
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{Abort: true}); err != nil {
+		t.Fatalf("SyncBranch --abort: %v", err)
+	}
+	if rebasing, _ := git.NewShellGit().IsRebasing(); rebasing {
+		t.Error("rebase still in progress after aborting")
+	}
+	if got := r.rev("HEAD"); got != before {
+		t.Errorf("HEAD = %s, want it back at %s", got, before)
+	}
+	if got := r.git("symbolic-ref", "--short", "HEAD"); got != "feature" {
+		t.Errorf("on %q after aborting, want feature", got)
+	}
+	if got := r.read("README.md"); got != "hello from feature\n" {
+		t.Errorf("README.md = %q, want the feature version", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncConflictContinue(t *testing.T) {
+	r := newTestRepo(t)
+	r.conflict()
+
+	r.write("README.md", "hello from both\n")
+	r.git("add", "README.md")
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{Continue: true}); err != nil {
+		t.Fatalf("SyncBranch --continue: %v", err)
+	}
+	if rebasing, _ := git.NewShellGit().IsRebasing(); rebasing {
+		t.Error("rebase still in progress after continuing")
+	}
+	if got := r.git("symbolic-ref", "--short", "HEAD"); got != "feature" {
+		t.Errorf("on %q after continuing, want feature", got)
+	}
+	if !r.isAncestor("main", "HEAD") {
+		t.Error("feature isn't based on main after continuing")
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != "Feature work" {
+		t.Errorf("HEAD subject = %q, want the feature commit", got)
+	}
+	if got := r.read("README.md"); got != "hello from both\n" {
+		t.Errorf("README.md = %q, want the resolution", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncConflictContinueWhileUnresolved(t *testing.T) {
+	r := newTestRepo(t)
+	r.conflict()
+
+	err := SyncBranch(git.NewShellGit(), SyncOptions{Continue: true})
+	if code := exitcode.Of(err); code != exitcode.Conflict {
+		t.Fatalf("exit code = %d (%v), want %d", code, err, exitcode.Conflict)
+	}
+	if rebasing, _ := git.NewShellGit().IsRebasing(); !rebasing {
+		t.Error("rebase was given up on while conflicts remained")
+	}
+}
+
+func TestSyncMergeConflictContinue(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("config", "sage.merge.strategy", "merge")
+	r.conflict()
+	if merging, _ := git.NewShellGit().IsMerging(); !merging {
+		t.Fatal("no merge in progress after the conflict")
+	}
+
+	r.write("README.md", "hello from both\n")
+	r.git("add", "README.md")
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{Continue: true}); err != nil {
+		t.Fatalf("SyncBranch --continue: %v", err)
+	}
+	if merging, _ := git.NewShellGit().IsMerging(); merging {
+		t.Error("merge still in progress after continuing")
+	}
+	if got := r.git("rev-list", "--merges", "-n", "1", "HEAD"); got != r.rev("HEAD") {
+		t.Error("HEAD isn't the merge commit")
+	}
+	if got := r.read("README.md"); got != "hello from both\n" {
+		t.Errorf("README.md = %q, want the resolution", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncFetchesEveryRemote(t *testing.T) {
+	r := newTestRepo(t)
+	upstream := filepath.Join(r.root, "upstream.git")
+	r.gitIn(r.root, "clone", "--bare", r.origin, upstream)
+	r.git("remote", "add", "upstream", upstream)
+	dir := filepath.Join(r.root, "other")
+	r.gitIn(r.root, "clone", upstream, dir)
+	r.commitIn(dir, "upstream.txt", "upstream\n", "Upstream work")
+	r.gitIn(dir, "push", "origin", "main")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if got, want := r.rev("upstream/main"), r.gitIn(dir, "rev-parse", "HEAD"); got != want {
+		t.Errorf("upstream/main = %s, want %s", got, want)
+	}
+}
+
+func TestSyncConflictKeepsStashUntilAbort(t *testing.T) {
+	r := newTestRepo(t)
+	r.feature("README.md", "hello from feature\n", "README.md", "hello from main\n")
+	r.write("notes.txt", "work in progress\n")
+	r.git("add", "notes.txt")
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{}); err == nil {
+		t.Fatal("SyncBranch succeeded despite the conflict")
+	}
+	if got := r.git("stash", "list"); !strings.Contains(got, "sage-sync-") {
+		t.Fatalf("changes weren't kept stashed during the conflict; stash list = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Error("the stash was popped into the rebase in progress")
+	}
+
+	if err := SyncBranch(git.NewShellGit(), SyncOptions{Abort: true}); err != nil {
+		t.Fatalf("SyncBranch --abort: %v", err)
+	}
+	if got := r.git("symbolic-ref", "--short", "HEAD"); got != "feature" {
+		t.Errorf("on %q after aborting, want feature", got)
+	}
+	if got := r.read("notes.txt"); got != "work in progress\n" {
+		t.Errorf("notes.txt = %q, want the stashed change back", got)
+	}
+	if out := r.git("stash", "list"); out != "" {
+		t.Errorf("stash wasn't popped:\n%s", out)
+	}
+}
+
+func TestRestoreChangesSwitchesBackToStashBranch(t *testing.T) {
+	r := newTestRepo(t)
+	r.git("checkout", "-b", "feature")
+	r.write("notes.txt", "work in progress\n")
+	r.git("add", "notes.txt")
+	g := git.NewShellGit()
+	if err := g.Stash("sage-sync-1"); err != nil {
+		t.Fatal(err)
+	}
+	r.git("checkout", "main")
+
+	if err := restoreChanges(g, ui.NewSyncProgress(), syncStash{Message: "sage-sync-1", Branch: "feature"}); err != nil {
+		t.Fatalf("restoreChanges: %v", err)
+	}
+	if got := r.git("symbolic-ref", "--short", "HEAD"); got != "feature" {
+		t.Errorf("changes restored on %q, want feature", got)
+	}
+	if got := r.read("notes.txt"); got != "work in progress\n" {
+		t.Errorf("notes.txt = %q", got)
+	}
+
+	// A stash that isn't sync's stays where it is
+	r.write("other.txt", "someone else's\n")
+	r.git("add", "other.txt")
+	if err := g.Stash("manual"); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreChanges(g, ui.NewSyncProgress(), syncStash{Message: "sage-sync-2", Branch: "feature"}); err == nil {
+		t.Error("restoreChanges popped a stash sync didn't make")
+	}
+	if got := r.git("stash", "list"); !strings.Contains(got, "manual") {
+		t.Errorf("stash list = %q, want the manual stash kept", got)
+	}
+}
+
+// monorepo commits a file each for two teams on main, then has feature and
+// main both change the search team's file, and main add one for payments
+func (r *testRepo) monorepo(paymentsOnMain string) {
+	r.t.Helper()
+	for _, dir := range []string{"services/payments", "services/search"} {
+		if err := os.MkdirAll(filepath.Join(r.dir, dir), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.commit("services/payments/pay.txt", "pay\n", "Add payments")
+	r.commit("services/search/find.txt", "find\n", "Add search")
+	r.git("push", "origin", "main")
+
+	r.git("checkout", "-b", "feature")
+	r.write("services/payments/pay.txt", "pay from feature\n")
+	r.commit("services/search/find.txt", "find from feature\n", "Touch both teams")
+	r.git("commit", "-am", "Change payments")
+	r.git("push", "-u", "origin", "feature")
+
+	r.git("checkout", "main")
+	r.commit("services/search/find.txt", "find from main\n", "Search moves on")
+	if paymentsOnMain != "" {
+		r.commit("services/payments/pay.txt", paymentsOnMain, "Payments moves on")
+	} else {
+		r.commit("services/payments/new.txt", "new\n", "Payments grows")
+	}
+	r.git("push", "origin", "main")
+	r.git("checkout", "feature")
+}
+
+func TestSyncPathsSettlesConflictsElsewhere(t *testing.T) {
+	r := newTestRepo(t)
+	r.monorepo("")
+
+	err := SyncBranch(git.NewShellGit(), SyncOptions{Paths: []string{"services/payments"}, TakeIncoming: true})
+	if err != nil {
+		t.Fatalf("SyncBranch: %v", err)
+	}
+	if !r.isAncestor("main", "HEAD") {
+		t.Error("feature isn't based on main after syncing")
+	}
+	if got := r.read("services/search/find.txt"); got != "find from main\n" {
+		t.Errorf("find.txt = %q, want main's version", got)
+	}
+	if got := r.read("services/payments/pay.txt"); got != "pay from feature\n" {
+		t.Errorf("pay.txt = %q, want the feature's change kept", got)
+	}
+	r.assertClean()
+}
+
+func TestSyncPathsKeepsChangesElsewhere(t *testing.T) {
+	r := newTestRepo(t)
+	r.monorepo("")
+	pushed := r.rev("origin/feature")
+
+	// Without prompts nobody agrees to drop the branch's changes
+	batch.Enable()
+	defer batch.Disable()
+
+	g := git.NewShellGit()
+	err := SyncBranch(g, SyncOptions{Paths: []string{"services/payments"}})
+	if code := exitcode.Of(err); code != exitcode.Conflict {
+		t.Fatalf("SyncBranch = %v (exit code %d), want a conflict", err, code)
+	}
+	if files := r.git("diff", "--name-only", "--diff-filter=U"); files != "services/search/find.txt" {
+		t.Errorf("conflicted files = %q, want the one outside the path", files)
+	}
+	if got := r.read("services/search/find.txt"); !strings.Contains(got, "find from feature") {
+		t.Errorf("find.txt = %q, want the feature's change kept in the conflict", got)
+	}
+
+	if err := SyncBranch(g, SyncOptions{Abort: true}); err != nil {
+		t.Fatalf("SyncBranch --abort: %v", err)
+	}
+	if got := r.read("services/search/find.txt"); got != "find from feature\n" {
+		t.Errorf("find.txt = %q after aborting, want the feature's version", got)
+	}
+	if r.rev("origin/feature") != pushed {
+		t.Error("the stopped sync pushed the branch")
+	}
+}
+
+func TestSyncPathsStopsForConflictsInside(t *testing.T) {
+	r := newTestRepo(t)
+	r.monorepo("pay from main\n")
+
+	err := SyncBranch(git.NewShellGit(), SyncOptions{Paths: []string{"services/pay*"}, TakeIncoming: true})
+	if code := exitcode.Of(err); code != exitcode.Conflict {
+		t.Fatalf("SyncBranch = %v (exit code %d), want a conflict", err, code)
+	}
+	if files := r.git("diff", "--name-only", "--diff-filter=U"); files != "services/payments/pay.txt" {
+		t.Errorf("conflicted files = %q, want only the one under the path", files)
+	}
+	if got := r.git("show", ":services/search/find.txt"); got != "find from main" {
+		t.Errorf("staged find.txt = %q, want main's version", got)
+	}
+}
+
+func TestSyncScopeContains(t *testing.T) {
+	scope := &SyncScope{Paths: []string{"./services/payments/", "libs/*/api"}}
+	for file, want := range map[string]bool{
+		"services/payments/pay.go":    true,
+		"services/payments":           true,
+		"services/payments-v2/pay.go": false,
+		"libs/auth/api/token.go":      true,
+		"libs/auth/internal/token.go": false,
+		"go.mod":                      false,
+	} {
+		if got := scope.Contains(file); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", file, got, want)
+		}
+	}
+
+	scope.TopLevel = true
+	if !scope.Contains("go.mod") {
+		t.Error("a sparse scope leaves out files at the root")
+	}
+	var none *SyncScope
+	if !none.Contains("anything") || !none.Empty() {
+		t.Error("no scope should cover everything")
+	}
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// SyncScope limits a sync to the parts of a monorepo someone works on. The
+// preview of incoming changes is about files under Paths. Conflicts
+// anywhere else stop the sync like any other, unless TakeIncoming is set or
+// the user agrees to settle them with the parent branch's version.
+type SyncScope struct {
+	Paths []string // directories or globs, relative to the repository root
+	// TopLevel also counts files at the root, which a cone-mode sparse
+	// checkout always has
+	TopLevel bool
+	Sparse   bool // the scope came from the sparse checkout
+	// TakeIncoming settles conflicts outside the scope with the parent's
+	// version without asking, dropping the branch's changes to them
+	TakeIncoming bool
+
+	// Taken lists the files outside the scope whose conflicts were settled
+	// with the parent's version
+	Taken []string
+}
+
+// Empty reports whether the scope covers the whole repository
+func (s *SyncScope) Empty() bool {
+	return s == nil || len(s.Paths) == 0
+}
+
+// Contains reports whether file, relative to the repository root, is in
+// the scope. A path covers everything below it, and a glob such as
+// services/*/api covers everything below the directories it matches.
+func (s *SyncScope) Contains(file string) bool {
+	if s.Empty() {
+		return true
+	}
+	if s.TopLevel && !strings.Contains(file, "/") {
+		return true
+	}
+	segments := strings.Split(file, "/")
+	for _, p := range s.Paths {
+		p = strings.Trim(path.Clean(strings.TrimPrefix(p, "./")), "/")
+		if p == "." || p == "" {
+			return true
+		}
+		depth := strings.Count(p, "/") + 1
+		if depth > len(segments) {
+			continue
+		}
+		if ok, _ := path.Match(p, strings.Join(segments[:depth], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// outside reports whether a conflict in file can be settled for the user
+func (s *SyncScope) outside(file string) bool {
+	return !s.Empty() && !s.Contains(file)
+}
+
+// allowTakeIncoming reports whether the conflicts in files, all outside the
+// scope, may be settled with the parent's version. Without TakeIncoming the
+// user is asked, and a yes holds for the rest of the sync; without prompts
+// the answer is no, so the sync stops for them.
+func (s *SyncScope) allowTakeIncoming(files []string) bool {
+	if s.TakeIncoming {
+		return true
+	}
+	ui.Warning(fmt.Sprintf("%d conflicted file%s outside %s:", len(files), ui.Plural(len(files)), s))
+	for _, f := range files {
+		fmt.Println("  " + f)
+	}
+	ok, err := ui.AskConfirm("Take the parent's version of them? Your branch's changes to them will be lost", false)
+	if err != nil || !ok {
+		return false
+	}
+	s.TakeIncoming = true
+	return true
+}
+
+func (s *SyncScope) String() string {
+	if s.Sparse {
+		return "the sparse checkout"
+	}
+	return strings.Join(s.Paths, ", ")
+}
+
+// SparseScope returns the directories of a cone-mode sparse checkout as a
+// scope, for syncing just what's checked out
+func SparseScope(g git.Service) (SyncScope, error) {
+	if on, _ := g.GetConfigValue("core.sparseCheckout"); strings.TrimSpace(on) != "true" {
+		return SyncScope{}, fmt.Errorf("this checkout isn't sparse; give the paths to sync with --paths")
+	}
+	if cone, _ := g.GetConfigValue("core.sparseCheckoutCone"); strings.TrimSpace(cone) == "false" {
+		return SyncScope{}, fmt.Errorf("the sparse checkout uses patterns rather than directories (cone mode); give the paths to sync with --paths")
+	}
+	out, err := g.Run("sparse-checkout", "list")
+	if err != nil {
+		return SyncScope{}, fmt.Errorf("failed to list the sparse checkout: %w", err)
+	}
+	return SyncScope{Paths: strings.Fields(out), TopLevel: true, Sparse: true}, nil
+}
+
+// takeIncoming settles a conflict in file with the version being brought
+// in: "theirs" when merging, but "ours" when rebasing onto the parent
+func takeIncoming(g git.Service, file string) error {
+	side := "--theirs"
+	if rebasing, _ := g.IsRebasing(); rebasing {
+		side = "--ours"
+	}
+	if _, err := g.Run("checkout", side, "--", file); err != nil {
+		// The parent deleted it
+		if _, err := g.Run("rm", "--quiet", "--", file); err != nil {
+			return fmt.Errorf("failed to take the parent's version of %s: %w", file, err)
+		}
+		return nil
+	}
+	if _, err := g.Run("add", "--", file); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", file, err)
+	}
+	return nil
+}
+
+// incomingCommit is a commit the parent has that the branch doesn't
+type incomingCommit struct {
+	Summary string // short hash and subject
+	Files   []string
+}
+
+// incomingChanges lists the commits in base..parent with the files they touch
+func incomingChanges(g git.Service, base, parent string) ([]incomingCommit, error) {
+	out, err := g.Run("log", "--no-renames", "--name-only", "--format=%x1e%h %s", base+".."+parent)
+	if err != nil {
+		return nil, err
+	}
+	var commits []incomingCommit
+	for _, entry := range strings.Split(out, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(entry), "\n")
+		if lines[0] == "" {
+			continue
+		}
+		c := incomingCommit{Summary: lines[0]}
+		for _, f := range lines[1:] {
+			if f = strings.TrimSpace(f); f != "" {
+				c.Files = append(c.Files, f)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// maxPreviewCommits caps the incoming commits previewed before a scoped sync
+const maxPreviewCommits = 10
+
+// previewIncoming shows what parent brings into the scope
+func previewIncoming(g git.Service, scope *SyncScope, base, parent string) {
+	commits, err := incomingChanges(g, base, parent)
+	if err != nil || len(commits) == 0 {
+		return
+	}
+	var inScope []incomingCommit
+	files := map[string]bool{}
+	for _, c := range commits {
+		touches := false
+		for _, f := range c.Files {
+			if scope.Contains(f) {
+				touches, files[f] = true, true
+			}
+		}
+		if touches {
+			inScope = append(inScope, c)
+		}
+	}
+
+	elsewhere := len(commits) - len(inScope)
+	if len(inScope) == 0 {
+		ui.Info(fmt.Sprintf("Nothing new from %s under %s (%d commit%s elsewhere)", parent, scope, elsewhere, ui.Plural(elsewhere)))
+		return
+	}
+	ui.Info(fmt.Sprintf("%d commit%s from %s change %d file%s under %s (%d more elsewhere)",
+		len(inScope), ui.Plural(len(inScope)), parent, len(files), ui.Plural(len(files)), scope, elsewhere))
+	for i, c := range inScope {
+		if i == maxPreviewCommits {
+			fmt.Println(ui.Gray(fmt.Sprintf("  ...and %d more", len(inScope)-i)))
+			break
+		}
+		fmt.Println("  " + ui.Gray(c.Summary))
+	}
+}
+
+// reportTaken says which conflicts outside the scope were settled with the
+// parent's version, as any changes the branch made to them are gone. Taking
+// them checked them out, so a sparse checkout is trimmed back.
+func reportTaken(g git.Service, scope *SyncScope, parent string) {
+	if scope.Empty() || len(scope.Taken) == 0 {
+		return
+	}
+	ui.Warning(fmt.Sprintf("Took %s's version of %d conflicted file%s outside %s:", parent, len(scope.Taken), ui.Plural(len(scope.Taken)), scope))
+	for _, f := range scope.Taken {
+		fmt.Println("  " + f)
+	}
+	if on, _ := g.GetConfigValue("core.sparseCheckout"); on == "true" {
+		if _, err := g.Run("sparse-checkout", "reapply"); err != nil {
+			ui.Warning(fmt.Sprintf("Couldn't trim the sparse checkout back: %v", err))
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// syncStash is the stash sync made of the user's changes. It's kept in
+// .git/.sage/sync-stash.json until the changes are back, so a sync stopped
+// for conflicts can restore them on the branch they came from once it's
+// continued or aborted. sage pick keeps its own in pick-stash.json.
+type syncStash struct {
+	Message string `json:"message"` // the stash's message, to recognise it by
+	Branch  string `json:"branch"`  // the branch the changes were made on
+}
+
+// Files in .git/.sage recording a stash until it's restored
+const (
+	syncStashFile = "sync-stash.json"
+	pickStashFile = "pick-stash.json"
+)
+
+func stashRecordPath(g git.Service, file string) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", file), nil
+}
+
+func saveStashRecord(g git.Service, file string, s syncStash) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	path, err := stashRecordPath(g, file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadStashRecord returns the stash a stopped sync or pick is holding, or nil
+func loadStashRecord(g git.Service, file string) (*syncStash, error) {
+	path, err := stashRecordPath(g, file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s syncStash
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to read the sync stash record: %w", err)
+	}
+	return &s, nil
+}
+
+func clearStashRecord(g git.Service, file string) {
+	if path, err := stashRecordPath(g, file); err == nil {
+		os.Remove(path)
+	}
+}
+
+// restoreChanges pops the sync's stash, but only where it belongs: not in
+// the middle of a merge or rebase, which --continue or --abort finish first,
+// and only on the branch it was made on. When that branch can't be checked
+// out again the stash is saved to a branch instead of landing elsewhere.
+func restoreChanges(g git.Service, progress *ui.SyncProgress, stash syncStash) error {
+	progress.StartStep("restore")
+	if dryrun.Enabled() {
+		// Nothing was stashed to check against; record the pop
+		if err := g.StashPop(); err != nil {
+			progress.CompleteStep("restore", false)
+			return err
+		}
+		progress.CompleteStep("restore", true)
+		return nil
+	}
+	fail := func(msg string) error {
+		progress.CompleteStep("restore", false)
+		return &SyncError{Message: msg}
+	}
+
+	merging, _ := g.IsMerging()
+	rebasing, _ := g.IsRebasing()
+	if merging || rebasing {
+		progress.SkipStep("restore")
+		ui.Info("Your changes stay stashed until 'sage sync --continue' or 'sage sync --abort' finishes")
+		return nil
+	}
+
+	// Only pop the stash if it's still the one sync made
+	stashes, err := g.StashList()
+	if err != nil || len(stashes) == 0 || !strings.HasSuffix(stashes[0], ": "+stash.Message) {
+		clearStashRecord(g, syncStashFile)
+		return fail(fmt.Sprintf("The changes sync stashed (%s) are no longer the latest stash.\n"+
+			"Find them with 'git stash list' and restore them on %s with 'git stash pop <stash>'.", stash.Message, stash.Branch))
+	}
+
+	cur, err := g.CurrentBranch()
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to check the current branch: %v\n"+
+			"Your changes are stashed as %s; restore them on %s with 'git stash pop'.", err, stash.Message, stash.Branch))
+	}
+	if cur != stash.Branch {
+		ui.Warning(fmt.Sprintf("Sync ended on %s, but your changes came from %s; switching back to restore them", cur, stash.Branch))
+		if err := g.Checkout(stash.Branch); err != nil {
+			branch, serr := stashToBranch(g, stash)
+			clearStashRecord(g, syncStashFile)
+			if serr != nil {
+				return fail(fmt.Sprintf("Couldn't switch back to %s: %v\n"+
+					"Your changes are stashed as %s; restore them there with 'git stash pop'.", stash.Branch, err, stash.Message))
+			}
+			return fail(fmt.Sprintf("Couldn't switch back to %s: %v\n"+
+				"Your changes were saved to the branch %s rather than restored here.\n"+
+				"On %s, restore them with 'git stash apply %s'.", stash.Branch, err, branch, stash.Branch, branch))
+		}
+	}
+
+	if err := g.StashPop(); err != nil {
+		clearStashRecord(g, syncStashFile)
+		return fail(fmt.Sprintf("Failed to restore your changes: %v\n"+
+			"They are stashed as %s; run 'git stash pop' on %s once the conflicts are dealt with.", err, stash.Message, stash.Branch))
+	}
+	clearStashRecord(g, syncStashFile)
+	progress.CompleteStep("restore", true)
+	return nil
+}
+
+// stashToBranch keeps the latest stash on a new branch and drops it from the
+// stash list, returning the branch's name
+func stashToBranch(g git.Service, stash syncStash) (string, error) {
+	hash, err := g.Run("rev-parse", "--verify", "refs/stash")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("sage/stash/%s-%d", stash.Branch, time.Now().Unix())
+	if _, err := g.Run("branch", name, strings.TrimSpace(hash)); err != nil {
+		return "", err
+	}
+	if _, err := g.Run("stash", "drop"); err != nil {
+		return "", err
+	}
+	return name, nil
+}
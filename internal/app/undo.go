@@ -3,7 +3,9 @@ package app
 import (
 	"fmt"
 
+	"github.com/crazywolf132/sage/internal/dryrun"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/ui"
 	"github.com/crazywolf132/sage/internal/undo"
 )
 
@@ -17,6 +19,24 @@ func Undo(g git.Service, count int) error {
 	return s.UndoLast(count)
 }
 
+// UndoByID undoes one recorded operation, even with later ones on top of it,
+// and saves the history. Commits with later work after them are reverted
+// with a new commit rather than reset away.
+func UndoByID(g git.Service, id string) (undo.Analysis, error) {
+	s := undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		return undo.Analysis{}, fmt.Errorf("failed to load undo history: %w", err)
+	}
+	a, err := s.Undo(id)
+	if err != nil {
+		return a, err
+	}
+	if err := s.SaveHistory("."); err != nil {
+		return a, fmt.Errorf("failed to save undo history: %w", err)
+	}
+	return a, nil
+}
+
 // RecordOperation records a Git operation in the undo history
 func RecordOperation(g git.Service, opType, description, command, category string, files []string, branch string, message string, stashed bool, stashRef string) error {
 	s := undo.NewService(g)
@@ -43,3 +63,39 @@ func RecordOperation(g git.Service, opType, description, command, category strin
 
 	return s.SaveHistory(".")
 }
+
+// JournalSnapshot records the repository's state in the undo journal before
+// command changes it. A failed snapshot only warns: it shouldn't stop the
+// command the user asked for.
+func JournalSnapshot(g git.Service, command string) {
+	if dryrun.Enabled() {
+		return
+	}
+	if ok, _ := g.HasCommits(); !ok {
+		return
+	}
+	j := undo.NewJournal(g)
+	if err := j.Load(); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to load the undo journal: %v", err))
+		return
+	}
+	if _, err := j.Take(command); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to snapshot the repository for undo: %v", err))
+		return
+	}
+	if err := j.Save(); err != nil {
+		ui.Warning(fmt.Sprintf("Failed to save the undo journal: %v", err))
+	}
+}
+
+// RestoreSnapshot puts the repository back to a journal snapshot, first
+// snapshotting the current state so the restore can be undone as well
+func RestoreSnapshot(g git.Service, j *undo.Journal, snap undo.Snapshot) error {
+	if _, err := j.Take("sage undo --interactive"); err != nil {
+		return fmt.Errorf("failed to snapshot the current state: %w", err)
+	}
+	if err := j.Save(); err != nil {
+		return err
+	}
+	return j.Restore(snap)
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/undo"
+)
+
+// commitRecorded commits file as sage commit does, recording it for undo
+func commitRecorded(t *testing.T, r *testRepo, file, content, msg string) string {
+	t.Helper()
+	r.commit(file, content, msg)
+	if err := RecordOperation(git.NewShellGit(), "commit", msg, "git commit", "commit", []string{file}, "main", msg, false, ""); err != nil {
+		t.Fatalf("RecordOperation: %v", err)
+	}
+	return r.rev("HEAD")
+}
+
+func TestUndoByIDRevertsBuriedCommit(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	commitRecorded(t, r, "parser.go", "package parser\n", "Add parser")
+	commitRecorded(t, r, "lexer.go", "package lexer\n", "Add lexer")
+	commitRecorded(t, r, "parser.go", "package parser\n\n// v2\n", "Rework parser")
+
+	s := undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		t.Fatal(err)
+	}
+	ops := s.GetHistory().Operations
+	rework, lexer, parser := ops[0], ops[1], ops[2]
+
+	deps := s.Dependencies()
+	if got := deps[rework.ID]; len(got) != 1 || got[0] != parser.ID {
+		t.Errorf("the rework depends on %v, want only the parser commit it changes", got)
+	}
+	if len(deps[lexer.ID]) != 0 {
+		t.Errorf("the lexer depends on %v, want nothing", deps[lexer.ID])
+	}
+
+	if a := s.Analyze(rework); a.Reversal != undo.ReversalReset {
+		t.Errorf("newest commit: %s (%s), want a reset", a.Reversal, a.Reason)
+	}
+	if a := s.Analyze(parser); a.Reversal != undo.ReversalRevert || len(a.Overlapping) != 1 || len(a.Dependents) != 1 {
+		t.Errorf("parser commit: %s, %d overlapping, %d dependents; want a revert that may conflict with the rework", a.Reversal, len(a.Overlapping), len(a.Dependents))
+	}
+
+	// The lexer commit comes out cleanly, keeping the commit after it
+	head := r.rev("HEAD")
+	a, err := UndoByID(g, lexer.ID[:8])
+	if err != nil {
+		t.Fatalf("UndoByID: %v", err)
+	}
+	if a.Reversal != undo.ReversalRevert {
+		t.Errorf("lexer undone by %s, want a revert", a.Reversal)
+	}
+	if got := r.rev("HEAD~1"); got != head {
+		t.Errorf("HEAD~1 = %s, want the revert on top of %s", got, head)
+	}
+	if out := r.git("ls-files"); strings.Contains(out, "lexer.go") {
+		t.Errorf("lexer.go still tracked after the revert:\n%s", out)
+	}
+	if r.read("parser.go") != "package parser\n\n// v2\n" {
+		t.Error("the later rework of parser.go was lost")
+	}
+
+	// The revert is recorded, and the lexer can't be undone twice
+	s = undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		t.Fatal(err)
+	}
+	ops = s.GetHistory().Operations
+	if ops[0].Metadata.Extra["reverts"] != lexer.ID {
+		t.Errorf("newest operation %q doesn't record the revert", ops[0].Description)
+	}
+	if _, err := UndoByID(g, lexer.ID); err == nil {
+		t.Error("undoing the lexer commit again succeeded")
+	}
+
+	// Undoing the revert, the newest commit, resets it away
+	if _, err := UndoByID(g, ops[0].ID); err != nil {
+		t.Fatalf("UndoByID(revert): %v", err)
+	}
+	if got := r.rev("HEAD"); got != head {
+		t.Errorf("HEAD = %s after undoing the revert, want %s", got, head)
+	}
+}
+
+func TestUndoByIDRefusesOtherBranch(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	commitRecorded(t, r, "a.txt", "a\n", "Add a")
+	r.git("checkout", "-b", "feature")
+
+	s := undo.NewService(g)
+	if err := s.LoadHistory("."); err != nil {
+		t.Fatal(err)
+	}
+	a := s.Analyze(s.GetHistory().Operations[0])
+	if a.Reversal != undo.ReversalBlocked || !strings.Contains(a.Reason, "switch to main") {
+		t.Errorf("got %s (%s), want it blocked until main is checked out", a.Reversal, a.Reason)
+	}
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	goversion "github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v3"
+)
+
+// versionFilesFile declares where a repository keeps its version
+const versionFilesFile = ".sage/versions.yaml"
+
+// VersionFile is one place the version is written. With neither Regex nor
+// JSON set, the whole file (trimmed) is the version.
+type VersionFile struct {
+	Path  string `yaml:"path"`
+	Regex string `yaml:"regex,omitempty"` // the first capture group is the version
+	JSON  string `yaml:"json,omitempty"`  // dot-separated path to a string value
+}
+
+type versionFilesConfig struct {
+	Files []VersionFile `yaml:"files"`
+}
+
+// VersionBump is a planned change to one version file
+type VersionBump struct {
+	File    VersionFile
+	Old     string
+	content []byte // file contents with the new version
+}
+
+// LoadVersionFiles reads .sage/versions.yaml from the repository root. When
+// it doesn't exist, a VERSION file at the root is used if there is one.
+func LoadVersionFiles(g git.Service) ([]VersionFile, error) {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repository root: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, versionFilesFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", versionFilesFile, err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "VERSION")); err == nil {
+			return []VersionFile{{Path: "VERSION"}}, nil
+		}
+		return nil, fmt.Errorf("no version files configured; list them in %s", versionFilesFile)
+	}
+
+	var cfg versionFilesConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", versionFilesFile, err)
+	}
+	if len(cfg.Files) == 0 {
+		return nil, fmt.Errorf("%s lists no files", versionFilesFile)
+	}
+	for i, f := range cfg.Files {
+		if f.Path == "" {
+			return nil, fmt.Errorf("%s: file %d is missing a path", versionFilesFile, i+1)
+		}
+		if f.Regex != "" && f.JSON != "" {
+			return nil, fmt.Errorf("%s: %s can't have both regex and json", versionFilesFile, f.Path)
+		}
+	}
+	return cfg.Files, nil
+}
+
+// NextVersion applies bump ("major", "minor", "patch" or an explicit version)
+// to current
+func NextVersion(current, bump string) (string, error) {
+	switch bump {
+	case "major", "minor", "patch":
+	default:
+		v, err := goversion.NewSemver(bump)
+		if err != nil {
+			return "", fmt.Errorf("invalid version %q: %w", bump, err)
+		}
+		return v.String(), nil
+	}
+
+	v, err := goversion.NewSemver(current)
+	if err != nil {
+		return "", fmt.Errorf("current version %q is not semantic: %w", current, err)
+	}
+	seg := v.Segments()
+	major, minor, patch := seg[0], seg[1], seg[2]
+	// A prerelease of the version a bump would reach is released as that
+	// version, so 1.3.0-rc.1 becomes 1.3.0 on a minor bump as on a patch one
+	pre := v.Prerelease() != ""
+	switch bump {
+	case "major":
+		if !pre || minor != 0 || patch != 0 {
+			major++
+		}
+		minor, patch = 0, 0
+	case "minor":
+		if !pre || patch != 0 {
+			minor++
+		}
+		patch = 0
+	case "patch":
+		if !pre {
+			patch++
+		}
+	}
+	return fmt.Sprintf("%d.%d.%d", major, minor, patch), nil
+}
+
+// PlanVersionBump reads the current version from every file and prepares the
+// new contents. All files must agree on the current version.
+func PlanVersionBump(g git.Service, files []VersionFile, next string) (current string, bumps []VersionBump, err error) {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(root, f.Path))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", f.Path, err)
+		}
+		start, end, err := locateVersion(f, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		old := string(data[start:end])
+		if current == "" {
+			current = strings.TrimPrefix(old, "v")
+		} else if strings.TrimPrefix(old, "v") != current {
+			return "", nil, fmt.Errorf("%s has version %s, but %s has %s", f.Path, old, files[0].Path, current)
+		}
+
+		// Keep a leading v if the file uses one
+		replacement := strings.TrimPrefix(next, "v")
+		if strings.HasPrefix(old, "v") {
+			replacement = "v" + replacement
+		}
+		content := make([]byte, 0, len(data)+len(replacement))
+		content = append(content, data[:start]...)
+		content = append(content, replacement...)
+		content = append(content, data[end:]...)
+		bumps = append(bumps, VersionBump{File: f, Old: old, content: content})
+	}
+	return current, bumps, nil
+}
+
+// ApplyVersionBump writes the planned files and, unless noCommit is set,
+// commits exactly those files as "chore(release): vX.Y.Z"
+func ApplyVersionBump(g git.Service, bumps []VersionBump, next string, noCommit bool) error {
+	root, err := g.GetRepoPath()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(bumps))
+	for _, b := range bumps {
+		path := filepath.Join(root, b.File.Path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, b.content, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", b.File.Path, err)
+		}
+		paths = append(paths, b.File.Path)
+	}
+	if noCommit {
+		return nil
+	}
+	return g.CommitPaths(ReleaseCommitMessage(next), false, paths)
+}
+
+// ReleaseCommitMessage is the subject of the commit that bumps the version
+func ReleaseCommitMessage(version string) string {
+	return "chore(release): v" + strings.TrimPrefix(version, "v")
+}
+
+// locateVersion returns the byte range of the version in data
+func locateVersion(f VersionFile, data []byte) (int, int, error) {
+	switch {
+	case f.Regex != "":
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid regex: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return 0, 0, fmt.Errorf("regex needs a capture group around the version")
+		}
+		m := re.FindSubmatchIndex(data)
+		if m == nil || m[2] < 0 {
+			return 0, 0, fmt.Errorf("regex %q doesn't match", f.Regex)
+		}
+		return m[2], m[3], nil
+	case f.JSON != "":
+		return locateJSONString(data, strings.Split(f.JSON, "."))
+	default:
+		trimmed := bytes.TrimSpace(data)
+		if len(trimmed) == 0 {
+			return 0, 0, fmt.Errorf("file is empty")
+		}
+		start := bytes.Index(data, trimmed)
+		return start, start + len(trimmed), nil
+	}
+}
+
+// locateJSONString finds the string value at path without re-encoding the
+// document, so formatting and key order are left untouched
+func locateJSONString(data []byte, path []string) (int, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	start, end := -1, -1
+
+	var walk func(at []string) error
+	walk = func(at []string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				for dec.More() {
+					key, err := dec.Token()
+					if err != nil {
+						return err
+					}
+					if err := walk(append(at, key.(string))); err != nil {
+						return err
+					}
+				}
+			case '[':
+				for i := 0; dec.More(); i++ {
+					if err := walk(append(at, strconv.Itoa(i))); err != nil {
+						return err
+					}
+				}
+			}
+			_, err := dec.Token() // closing delimiter
+			return err
+		case string:
+			if start < 0 && equalPath(at, path) {
+				end = int(dec.InputOffset()) - 1 // before the closing quote
+				start = end - len(t)
+				if start < 1 || string(data[start:end]) != t {
+					return fmt.Errorf("%s contains escape sequences", strings.Join(path, "."))
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(nil); err != nil {
+		return 0, 0, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if start < 0 {
+		return 0, 0, fmt.Errorf("no string at %s", strings.Join(path, "."))
+	}
+	return start, end, nil
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
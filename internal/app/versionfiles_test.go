@@ -0,0 +1,116 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestNextVersion(t *testing.T) {
+	tests := []struct {
+		current, bump, want string
+	}{
+		{"1.2.3", "patch", "1.2.4"},
+		{"1.2.3", "minor", "1.3.0"},
+		{"1.2.3", "major", "2.0.0"},
+		{"v1.2.3", "patch", "1.2.4"},
+		{"1.3.0-rc.1", "patch", "1.3.0"},
+		{"1.3.0-rc.1", "minor", "1.3.0"},
+		{"1.3.0-rc.1", "major", "2.0.0"},
+		{"1.3.2-rc.1", "minor", "1.4.0"},
+		{"2.0.0-rc.1", "major", "2.0.0"},
+		{"2.0.0-rc.1", "minor", "2.0.0"},
+		{"2.1.0-beta", "major", "3.0.0"},
+		{"1.2.3", "2.0.0-rc.1", "2.0.0-rc.1"},
+		{"anything", "v1.0.0", "1.0.0"},
+	}
+	for _, tt := range tests {
+		got, err := NextVersion(tt.current, tt.bump)
+		if err != nil {
+			t.Errorf("NextVersion(%q, %q): %v", tt.current, tt.bump, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NextVersion(%q, %q) = %q, want %q", tt.current, tt.bump, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"1.2.3", "huge"}, {"not-a-version", "patch"}} {
+		if _, err := NextVersion(bad[0], bad[1]); err == nil {
+			t.Errorf("NextVersion(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+}
+
+func TestLocateVersion(t *testing.T) {
+	pkg := `{
+  "name": "app",
+  "version": "1.2.3",
+  "nested": {"list": [{"v": "0.1.0"}, {"v": "0.2.0"}]}
+}`
+	tests := []struct {
+		name string
+		file VersionFile
+		data string
+		want string
+		err  bool
+	}{
+		{"whole file", VersionFile{Path: "VERSION"}, "\n 1.2.3 \n", "1.2.3", false},
+		{"empty file", VersionFile{Path: "VERSION"}, " \n", "", true},
+		{"regex", VersionFile{Regex: `Version = "([^"]+)"`}, "package v\n\nconst Version = \"v1.2.3\"\n", "v1.2.3", false},
+		{"regex first match", VersionFile{Regex: `v=(\S+)`}, "v=1.0.0 v=2.0.0", "1.0.0", false},
+		{"regex without group", VersionFile{Regex: `\d+\.\d+\.\d+`}, "1.2.3", "", true},
+		{"regex no match", VersionFile{Regex: `version: (\S+)`}, "name: app", "", true},
+		{"invalid regex", VersionFile{Regex: `(`}, "1.2.3", "", true},
+		{"json", VersionFile{JSON: "version"}, pkg, "1.2.3", false},
+		{"json nested array", VersionFile{JSON: "nested.list.1.v"}, pkg, "0.2.0", false},
+		{"json missing", VersionFile{JSON: "nested.version"}, pkg, "", true},
+		{"json not a string", VersionFile{JSON: "nested"}, pkg, "", true},
+		{"json escapes", VersionFile{JSON: "version"}, `{"version": "1.2.\u0033"}`, "", true},
+		{"invalid json", VersionFile{JSON: "version"}, `{"version": `, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := locateVersion(tt.file, []byte(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, found %q", tt.data[start:end])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.data[start:end]; got != tt.want {
+				t.Errorf("found %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanVersionBumpKeepsFormatting(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("VERSION", "v1.2.3\n")
+	r.write("package.json", "{\n    \"version\":   \"1.2.3\",\n    \"b\": 1\n}\n")
+	files := []VersionFile{{Path: "VERSION"}, {Path: "package.json", JSON: "version"}}
+
+	current, bumps, err := PlanVersionBump(git.NewShellGit(), files, "1.3.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != "1.2.3" {
+		t.Errorf("current = %q, want 1.2.3", current)
+	}
+	want := []string{"v1.3.0\n", "{\n    \"version\":   \"1.3.0\",\n    \"b\": 1\n}\n"}
+	for i, b := range bumps {
+		if string(b.content) != want[i] {
+			t.Errorf("%s = %q, want %q", b.File.Path, b.content, want[i])
+		}
+	}
+
+	// Files that disagree on the current version are refused
+	r.write("VERSION", "1.2.4\n")
+	if _, _, err := PlanVersionBump(git.NewShellGit(), files, "1.3.0"); err == nil {
+		t.Error("expected an error for mismatched versions")
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// WipPrefix marks checkpoint commits created by 'sage wip'
+const WipPrefix = "wip:"
+
+// IsWipMessage reports whether a commit subject is a wip checkpoint
+func IsWipMessage(subject string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), WipPrefix)
+}
+
+// CreateWip commits everything in the working tree as a wip checkpoint.
+// Hooks are skipped and no AI is involved so it is as fast as possible.
+func CreateWip(g git.Service, note string) (string, error) {
+	clean, err := g.IsClean()
+	if err != nil {
+		return "", fmt.Errorf("failed to check working directory: %w", err)
+	}
+	if clean {
+		return "", fmt.Errorf("nothing to checkpoint: working tree is clean")
+	}
+
+	msg := WipPrefix + " checkpoint"
+	if note = strings.TrimSpace(note); note != "" {
+		msg = WipPrefix + " " + note
+	}
+
+	JournalSnapshot(g, "sage wip")
+	if err := StageAll(g); err != nil {
+		return "", fmt.Errorf("failed to stage changes: %w", err)
+	}
+	if _, err := g.Run("commit", "--no-verify", "-m", msg); err != nil {
+		return "", fmt.Errorf("failed to create wip commit: %w", err)
+	}
+
+	branch, _ := g.CurrentBranch()
+	_ = RecordOperation(g, "commit", msg, "sage wip", "commit", nil, branch, msg, false, "")
+	return msg, nil
+}
+
+// Unwip soft-resets the contiguous run of wip commits at the tip of the current
+// branch, leaving their changes staged. It returns how many commits were removed.
+func Unwip(g git.Service) (int, error) {
+	commits, err := g.Commits(git.CommitsOptions{Limit: 200})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	count := 0
+	target := ""
+	for _, c := range commits {
+		if !IsWipMessage(c.Subject) {
+			target = c.Hash
+			break
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("no wip commits at the tip of this branch")
+	}
+	if target == "" {
+		return 0, fmt.Errorf("every recent commit is a wip commit; nothing to reset onto")
+	}
+
+	if err := g.ResetSoft(target); err != nil {
+		return 0, fmt.Errorf("failed to reset wip commits: %w", err)
+	}
+	return count, nil
+}
+
+// FindWipCommits returns the wip commits ("<hash> <subject>") in a revision range
+func FindWipCommits(g git.Service, revRange string) ([]string, error) {
+	commits, err := g.Commits(git.CommitsOptions{Range: revRange})
+	if err != nil {
+		return nil, err
+	}
+	var wips []string
+	for _, c := range commits {
+		if IsWipMessage(c.Subject) {
+			wips = append(wips, c.ShortHash()+" "+c.Subject)
+		}
+	}
+	return wips, nil
+}
+
+// pushBase returns the ref a push of branch is compared against: the remote
+// branch if it exists, otherwise the remote default branch.
+func pushBase(g git.Service, branch string) string {
+	base := "origin/" + branch
+	if _, err := g.GetCommitHash(base); err == nil {
+		return base
+	}
+	db, err := g.DefaultBranch()
+	if err != nil {
+		db = "main"
+	}
+	return "origin/" + db
+}
+
+// ensureNoWipCommits fails if the commits between base and HEAD include wip checkpoints
+func ensureNoWipCommits(g git.Service, base string) error {
+	wips, err := FindWipCommits(g, base+"..HEAD")
+	if err != nil || len(wips) == 0 {
+		return nil
+	}
+	return fmt.Errorf("refusing to publish wip commits:\n  %s\n\nRun 'sage unwip' and commit properly, or pass --allow-wip",
+		strings.Join(wips, "\n  "))
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+func TestWipRoundTrip(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.commit("a.txt", "one\n", "Add a")
+	base := r.rev("HEAD")
+
+	r.write("a.txt", "two\n")
+	r.write("b.txt", "new\n")
+	msg, err := CreateWip(g, "halfway there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "wip: halfway there" || r.git("log", "-1", "--format=%s") != msg {
+		t.Errorf("committed %q, want a wip checkpoint", msg)
+	}
+	r.assertClean()
+
+	r.write("a.txt", "three\n")
+	if _, err := CreateWip(g, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureNoWipCommits(g, base); err == nil {
+		t.Error("expected the wip commits to block publishing")
+	}
+
+	n, err := Unwip(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || r.rev("HEAD") != base {
+		t.Errorf("unwound %d commits to %s, want 2 back to %s", n, r.rev("HEAD"), base)
+	}
+	if got := r.git("status", "--porcelain"); got != "M  a.txt\nA  b.txt" {
+		t.Errorf("status = %q, want the checkpointed changes staged", got)
+	}
+	if r.read("a.txt") != "three\n" || r.read("b.txt") != "new\n" {
+		t.Error("the working tree lost checkpointed changes")
+	}
+}
+
+func TestUnwipRefusesOrdinaryCommit(t *testing.T) {
+	r := newTestRepo(t)
+	g := git.NewShellGit()
+	r.write("a.txt", "one\n")
+	if _, err := CreateWip(g, ""); err != nil {
+		t.Fatal(err)
+	}
+	// A wip commit under an ordinary one is not at the tip, so it stays
+	r.commit("b.txt", "two\n", "Add b")
+	head := r.rev("HEAD")
+
+	if _, err := Unwip(g); err == nil {
+		t.Fatal("expected unwip to refuse when HEAD isn't a wip commit")
+	}
+	if r.rev("HEAD") != head {
+		t.Error("unwip moved HEAD after refusing")
+	}
+	r.assertClean()
+
+	if _, err := CreateWip(g, ""); err == nil {
+		t.Error("expected wip to refuse a clean working tree")
+	}
+}
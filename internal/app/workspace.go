@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/config"
+)
+
+// WorkspaceFile is the workspace manifest's name in sage's config directory
+const WorkspaceFile = "workspace.toml"
+
+// defaultWorkspaceParallel is how many repositories are worked on at once
+// when the manifest doesn't say
+const defaultWorkspaceParallel = 8
+
+// Workspace is a set of repositories sage ws works across, read from a
+// manifest such as:
+//
+//	parallel = 4
+//
+//	[[repo]]
+//	path = "~/src/billing"
+//
+//	[[repo]]
+//	name = "web"
+//	path = "frontend/web"  # relative to the manifest
+type Workspace struct {
+	Parallel int             `toml:"parallel,omitzero"`
+	Repos    []WorkspaceRepo `toml:"repo"`
+}
+
+// WorkspaceRepo is a member repository. Name defaults to the last element
+// of Path.
+type WorkspaceRepo struct {
+	Name string `toml:"name,omitempty"`
+	Path string `toml:"path"`
+}
+
+// WorkspacePath returns the manifest to use: path when given, or
+// workspace.toml in sage's config directory
+func WorkspacePath(path string) (string, error) {
+	if path != "" {
+		return filepath.Abs(path)
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %w", err)
+	}
+	return filepath.Join(dir, WorkspaceFile), nil
+}
+
+// LoadWorkspace reads the manifest at path, expanding ~ and resolving member
+// paths relative to the manifest
+func LoadWorkspace(path string) (*Workspace, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no workspace manifest at %s; add repositories with 'sage ws add <path>'", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ws Workspace
+	if err := toml.Unmarshal(b, &ws); err != nil {
+		return nil, fmt.Errorf("invalid workspace manifest %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, r := range ws.Repos {
+		if r.Path == "" {
+			return nil, fmt.Errorf("invalid workspace manifest %s: repo %d has no path", path, i+1)
+		}
+		r.Path, err = expandWorkspacePath(r.Path, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		if r.Name == "" {
+			r.Name = filepath.Base(r.Path)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("invalid workspace manifest %s: two repos are named %s; give one a name", path, r.Name)
+		}
+		seen[r.Name] = true
+		ws.Repos[i] = r
+	}
+	return &ws, nil
+}
+
+func expandWorkspacePath(p, base string) (string, error) {
+	if rest, ok := strings.CutPrefix(p, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	return filepath.Clean(p), nil
+}
+
+// AddToWorkspace adds the repository at dir to the manifest at path,
+// creating the manifest if needed. It returns the member as added.
+func AddToWorkspace(path, dir, name string) (WorkspaceRepo, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return WorkspaceRepo{}, err
+	}
+	if !isRepoDir(abs) {
+		return WorkspaceRepo{}, fmt.Errorf("%s is not a git repository", abs)
+	}
+	repo := WorkspaceRepo{Name: name, Path: abs}
+	if repo.Name == "" {
+		repo.Name = filepath.Base(abs)
+	}
+
+	// The file as written, so relative paths and ~ survive
+	var ws Workspace
+	if b, err := os.ReadFile(path); err == nil {
+		if err := toml.Unmarshal(b, &ws); err != nil {
+			return WorkspaceRepo{}, fmt.Errorf("invalid workspace manifest %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return WorkspaceRepo{}, err
+	}
+	for _, r := range ws.Repos {
+		existing, _ := expandWorkspacePath(r.Path, filepath.Dir(path))
+		if r.Name == "" {
+			r.Name = filepath.Base(existing)
+		}
+		if existing == abs {
+			return WorkspaceRepo{}, fmt.Errorf("%s is already in the workspace as %s", abs, r.Name)
+		}
+		if r.Name == repo.Name {
+			return WorkspaceRepo{}, fmt.Errorf("the workspace already has a repo named %s; pick another with --name", repo.Name)
+		}
+	}
+
+	entry := repo
+	if name == "" {
+		entry.Name = ""
+	}
+	ws.Repos = append(ws.Repos, entry)
+	b, err := toml.Marshal(ws)
+	if err != nil {
+		return WorkspaceRepo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return WorkspaceRepo{}, err
+	}
+	return repo, os.WriteFile(path, b, 0644)
+}
+
+// Members returns the repos named in only, in manifest order, or all of them
+// when only is empty
+func (w *Workspace) Members(only []string) ([]WorkspaceRepo, error) {
+	if len(only) == 0 {
+		return w.Repos, nil
+	}
+	want := map[string]bool{}
+	for _, n := range only {
+		want[n] = true
+	}
+	var repos []WorkspaceRepo
+	for _, r := range w.Repos {
+		if want[r.Name] {
+			repos = append(repos, r)
+			delete(want, r.Name)
+		}
+	}
+	for n := range want {
+		return nil, fmt.Errorf("no repo named %s in the workspace", n)
+	}
+	return repos, nil
+}
+
+// WorkspaceResult is what a command did in one member repository
+type WorkspaceResult struct {
+	Repo   WorkspaceRepo
+	Output []byte // the command's standard output
+	Err    error
+}
+
+// Summary is the last line the command printed, without colors
+func (r WorkspaceResult) Summary() string {
+	return lastLine(string(r.Output))
+}
+
+// RunInWorkspace runs command in each repo, parallel at a time, with prompts
+// and colors off. A failure in one repo doesn't stop the others; its result
+// carries the error the command printed. Results are in the order of repos.
+func RunInWorkspace(repos []WorkspaceRepo, parallel int, command ...string) []WorkspaceResult {
+	if parallel <= 0 {
+		parallel = defaultWorkspaceParallel
+	}
+	results := make([]WorkspaceResult, len(repos))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, r := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = runInRepo(r, command)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func runInRepo(r WorkspaceRepo, command []string) WorkspaceResult {
+	res := WorkspaceResult{Repo: r}
+	if !isRepoDir(r.Path) {
+		res.Err = fmt.Errorf("%s is not a git repository", r.Path)
+		return res
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(command[0], command[1:]...)
+	c.Dir = r.Path
+	c.Env = append(os.Environ(), batch.EnvVar+"=1", "NO_COLOR=1")
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	res.Output = stdout.Bytes()
+	if err != nil {
+		msg := strings.TrimPrefix(lastLine(stderr.String()), "Error: ")
+		if msg == "" {
+			msg = res.Summary()
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		res.Err = errors.New(msg)
+	}
+	return res
+}
+
+// DecodeJSON reads the command's JSON output into v, skipping any warnings
+// printed ahead of it
+func (r WorkspaceResult) DecodeJSON(v any) error {
+	out := r.Output
+	for start := 0; start < len(out); {
+		if out[start] == '{' || out[start] == '[' {
+			out = out[start:]
+			break
+		}
+		next := bytes.IndexByte(out[start:], '\n')
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(v); err != nil {
+		return fmt.Errorf("unexpected output: %w", err)
+	}
+	return nil
+}
+
+func isRepoDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// lastLine returns the last non-blank line of s, without colors
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(ansiEscape.ReplaceAllString(s, "")), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	home, _ := os.UserHomeDir()
+	manifest := filepath.Join(dir, "workspace.toml")
+	os.WriteFile(manifest, []byte(`parallel = 2
+
+[[repo]]
+path = "~/src/billing"
+
+[[repo]]
+name = "web"
+path = "frontend/web"
+`), 0644)
+
+	ws, err := LoadWorkspace(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WorkspaceRepo{
+		{Name: "billing", Path: filepath.Join(home, "src", "billing")},
+		{Name: "web", Path: filepath.Join(dir, "frontend", "web")},
+	}
+	if ws.Parallel != 2 || len(ws.Repos) != 2 || ws.Repos[0] != want[0] || ws.Repos[1] != want[1] {
+		t.Errorf("workspace = %+v, want %+v", ws, want)
+	}
+
+	if only, err := ws.Members([]string{"web"}); err != nil || len(only) != 1 || only[0].Name != "web" {
+		t.Errorf("Members(web) = %+v, %v", only, err)
+	}
+	if _, err := ws.Members([]string{"nope"}); err == nil {
+		t.Error("Members should reject a name that isn't in the workspace")
+	}
+
+	os.WriteFile(manifest, []byte("[[repo]]\npath = \"a/api\"\n[[repo]]\npath = \"b/api\"\n"), 0644)
+	if _, err := LoadWorkspace(manifest); err == nil || !strings.Contains(err.Error(), "two repos are named api") {
+		t.Errorf("duplicate names: err = %v", err)
+	}
+}
+
+func TestRunInWorkspace(t *testing.T) {
+	r := newTestRepo(t)
+	other := r.clone("other")
+	r.gitIn(other, "checkout", "-b", "feature")
+	manifest := filepath.Join(r.root, "workspace.toml")
+
+	for _, dir := range []string{r.dir, other} {
+		if _, err := AddToWorkspace(manifest, dir, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := AddToWorkspace(manifest, other, "again"); err == nil {
+		t.Error("adding a repo twice should fail")
+	}
+	b, _ := os.ReadFile(manifest)
+	os.WriteFile(manifest, append(b, "\n[[repo]]\npath = \"gone\"\n"...), 0644)
+
+	ws, err := LoadWorkspace(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := RunInWorkspace(ws.Repos, 2, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if len(results) != 3 {
+		t.Fatalf("%d results, want one per repo", len(results))
+	}
+	if results[0].Err != nil || results[0].Summary() != "main" {
+		t.Errorf("first repo: %q, %v", results[0].Summary(), results[0].Err)
+	}
+	if results[1].Err != nil || results[1].Summary() != "feature" {
+		t.Errorf("second repo: %q, %v", results[1].Summary(), results[1].Err)
+	}
+	if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "not a git repository") {
+		t.Errorf("missing repo: err = %v", results[2].Err)
+	}
+
+	failed := RunInWorkspace(ws.Repos[:1], 0, "git", "rev-parse", "--verify", "nope")
+	if failed[0].Err == nil || !strings.Contains(failed[0].Err.Error(), "fatal") {
+		t.Errorf("failing command: err = %v, want git's message", failed[0].Err)
+	}
+}
+
+func TestWorkspaceResultDecodeJSON(t *testing.T) {
+	res := WorkspaceResult{Output: []byte("\x1b[31mWarning: \x1b[0mslow [config]\n{\"branch\": \"main\"}\n")}
+	var st RepoStatus
+	if err := res.DecodeJSON(&st); err != nil || st.Branch != "main" {
+		t.Errorf("DecodeJSON = %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// Entry is a single record in the audit log
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Duration  string    `json:"duration,omitempty"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Repo      string    `json:"repo,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	RefBefore string    `json:"ref_before,omitempty"`
+	RefAfter  string    `json:"ref_after,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Redacted stands in for values kept out of the log
+const Redacted = "<redacted>"
+
+// Flag is a flag set on the command line
+type Flag struct {
+	Name  string
+	Value string
+	Bool  bool
+}
+
+// RedactArgs describes a command line without anything typed into it, since
+// messages, titles and tokens given as flags or arguments can hold anything.
+// Boolean flags are kept as given, other flags keep only their name, and each
+// positional argument becomes Redacted.
+func RedactArgs(flags []Flag, args []string) []string {
+	var out []string
+	for _, f := range flags {
+		switch {
+		case f.Bool && f.Value == "true":
+			out = append(out, "--"+f.Name)
+		case f.Bool:
+			out = append(out, "--"+f.Name+"="+f.Value)
+		default:
+			out = append(out, "--"+f.Name+"="+Redacted)
+		}
+	}
+	for range args {
+		out = append(out, Redacted)
+	}
+	return out
+}
+
+// Path returns the location of the audit log for the current repository
+func Path(g git.Service) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "audit.log"), nil
+}
+
+// Append writes an entry to the end of the audit log. The log is only ever appended to.
+func Append(g git.Service, e Entry) error {
+	path, err := Path(g)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Read returns all entries recorded at or after since, oldest first.
+// A zero since returns every entry.
+func Read(g git.Service, since time.Time) ([]Entry, error) {
+	path, err := Path(g)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			// Skip corrupt lines rather than failing the whole read
+			continue
+		}
+		if !since.IsZero() && e.Timestamp.Before(since) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// ParseSince parses a --since value. It accepts durations such as "24h" or "7d",
+// dates like "2006-01-02", and RFC3339 timestamps. An empty value means no limit.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q (use e.g. 24h, 7d, 2024-01-31)", value)
+}
+
+// Forward posts an entry as JSON to a webhook URL
+func Forward(url string, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to forward audit entry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		value       string
+		expected    time.Time
+		expectError bool
+	}{
+		{name: "empty", value: "", expected: time.Time{}},
+		{name: "hours", value: "24h", expected: now.Add(-24 * time.Hour)},
+		{name: "days", value: "7d", expected: now.AddDate(0, 0, -7)},
+		{name: "date", value: "2024-03-01", expected: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "rfc3339", value: "2024-03-01T10:00:00Z", expected: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{name: "invalid", value: "last tuesday", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSince(tt.value, now)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.expected.Equal(got), "expected %v, got %v", tt.expected, got)
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	flags := []Flag{
+		{Name: "force", Value: "true", Bool: true},
+		{Name: "message", Value: "fix the token abc123"},
+		{Name: "push", Value: "false", Bool: true},
+		{Name: "reviewer", Value: "[alice,bob]"},
+	}
+	got := RedactArgs(flags, []string{"feature/secret-project", "my title"})
+	assert.Equal(t, []string{
+		"--force",
+		"--message=" + Redacted,
+		"--push=false",
+		"--reviewer=" + Redacted,
+		Redacted,
+		Redacted,
+	}, got)
+	assert.Nil(t, RedactArgs(nil, nil))
+}
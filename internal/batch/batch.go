@@ -0,0 +1,62 @@
+// Package batch switches prompts off so sage can run in CI and scripts. In
+// batch mode commands go ahead with what they were asked to do, take the safe
+// answer where a prompt is a safety net, and fail with an error naming the
+// missing flag where only a person can answer.
+package batch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+// EnvVar turns batch mode on when set to anything but "", "0" or "false"
+const EnvVar = "SAGE_NONINTERACTIVE"
+
+var (
+	mu      sync.Mutex
+	enabled bool
+)
+
+// Enable turns batch mode on
+func Enable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = true
+}
+
+// Disable turns batch mode off, though SAGE_NONINTERACTIVE still applies
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = false
+}
+
+// Enabled reports whether prompts are off, by flag or environment
+func Enabled() bool {
+	mu.Lock()
+	on := enabled
+	mu.Unlock()
+	if on {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(EnvVar))) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// NeedsInput is the error for a question batch mode can't answer. hint says
+// which flag or argument answers it instead.
+func NeedsInput(question, hint string) error {
+	return exitcode.Errorf(exitcode.Usage, "%s, but prompts are off (--non-interactive or %s): %s", question, EnvVar, hint)
+}
+
+// Note tells the user which answer batch mode took for them
+func Note(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "non-interactive: "+format+"\n", args...)
+}
@@ -0,0 +1,41 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+func TestEnabled(t *testing.T) {
+	defer Disable()
+
+	tests := []struct {
+		env  string
+		flag bool
+		want bool
+	}{
+		{"", false, false},
+		{"0", false, false},
+		{"false", false, false},
+		{"1", false, true},
+		{"true", false, true},
+		{"", true, true},
+		{"0", true, true},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvVar, tt.env)
+		Disable()
+		if tt.flag {
+			Enable()
+		}
+		assert.Equal(t, tt.want, Enabled(), "env %q, flag %v", tt.env, tt.flag)
+	}
+}
+
+func TestNeedsInput(t *testing.T) {
+	err := NeedsInput("sage commit needs a message", "pass -m")
+	assert.Equal(t, exitcode.Usage, exitcode.Of(err))
+	assert.Contains(t, err.Error(), "pass -m")
+}
@@ -0,0 +1,82 @@
+// Package clipboard copies text to the system clipboard through the platform's
+// clipboard tool: pbcopy on macOS, clip on Windows and WSL, and wl-copy, xclip
+// or xsel on Linux desktops. Where there is no clipboard, such as in an SSH
+// session without X forwarding, Copy returns ErrUnavailable and callers carry
+// on without it.
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard can be reached
+var ErrUnavailable = errors.New("no clipboard available")
+
+// tool is a command that reads text on stdin and puts it on the clipboard
+type tool struct {
+	name string
+	args []string
+}
+
+// lookPath is replaced in tests
+var lookPath = exec.LookPath
+
+// candidates returns the clipboard tools that can work on this system, best first
+func candidates(goos string, getenv func(string) string) []tool {
+	switch goos {
+	case "darwin":
+		return []tool{{name: "pbcopy"}}
+	case "windows":
+		return []tool{{name: "clip"}}
+	}
+
+	var tools []tool
+	if getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, tool{name: "wl-copy"})
+	}
+	if getenv("DISPLAY") != "" {
+		tools = append(tools,
+			tool{name: "xclip", args: []string{"-selection", "clipboard"}},
+			tool{name: "xsel", args: []string{"--clipboard", "--input"}},
+		)
+	}
+	if getenv("WSL_DISTRO_NAME") != "" {
+		tools = append(tools, tool{name: "clip.exe"})
+	}
+	return tools
+}
+
+// find returns the first installed clipboard tool
+func find(goos string, getenv func(string) string) (string, []string, bool) {
+	for _, t := range candidates(goos, getenv) {
+		if path, err := lookPath(t.name); err == nil {
+			return path, t.args, true
+		}
+	}
+	return "", nil, false
+}
+
+// Available reports whether Copy can reach a clipboard
+func Available() bool {
+	_, _, ok := find(runtime.GOOS, os.Getenv)
+	return ok
+}
+
+// Copy puts text on the system clipboard
+func Copy(text string) error {
+	path, args, ok := find(runtime.GOOS, os.Getenv)
+	if !ok {
+		return ErrUnavailable
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package clipboard
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func names(tools []tool) []string {
+	var out []string
+	for _, t := range tools {
+		out = append(out, t.name)
+	}
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want []string
+	}{
+		{"macOS", "darwin", nil, []string{"pbcopy"}},
+		{"windows", "windows", nil, []string{"clip"}},
+		{"wayland", "linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "xclip", "xsel"}},
+		{"x11", "linux", map[string]string{"DISPLAY": ":0"}, []string{"xclip", "xsel"}},
+		{"wsl", "linux", map[string]string{"WSL_DISTRO_NAME": "Ubuntu"}, []string{"clip.exe"}},
+		{"ssh session", "linux", map[string]string{"SSH_TTY": "/dev/pts/0"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, names(candidates(tt.goos, env(tt.env))))
+		})
+	}
+}
+
+func TestFindSkipsMissingTools(t *testing.T) {
+	orig := lookPath
+	defer func() { lookPath = orig }()
+	lookPath = func(name string) (string, error) {
+		if name == "xsel" {
+			return "/usr/bin/xsel", nil
+		}
+		return "", exec.ErrNotFound
+	}
+
+	path, args, ok := find("linux", env(map[string]string{"DISPLAY": ":0"}))
+	assert.True(t, ok)
+	assert.Equal(t, "/usr/bin/xsel", path)
+	assert.Equal(t, []string{"--clipboard", "--input"}, args)
+
+	_, _, ok = find("linux", env(nil))
+	assert.False(t, ok)
+}
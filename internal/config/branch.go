@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// Branch overrides live in their own tables in either config file:
+//
+//	[branch."release/*"]
+//	"ai.enabled" = "false"
+//
+//	[branch."hotfix/**"]
+//	"git.merge_method" = "squash"
+//
+// When Get consults the local layer, a matching override beats both the
+// local and global values. Local overrides beat global ones, and within a
+// file the most specific pattern wins.
+
+// MatchBranch reports whether branch matches pattern. Patterns use shell
+// globs, where * stops at a slash; a trailing /** matches any depth.
+func MatchBranch(pattern, branch string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return strings.HasPrefix(branch, prefix+"/")
+	}
+	ok, err := path.Match(pattern, branch)
+	return err == nil && ok
+}
+
+// specificity ranks patterns so that "release/1.*" beats "release/*"
+func specificity(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
+
+func isSensitive(key string) bool {
+	for _, k := range sensitiveKeys {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// branchOverride returns the override for key on branch (the current branch
+// when empty), if any
+func branchOverride(g git.Service, key, branch string) (string, bool) {
+	if len(localBranchData) == 0 && len(globalBranchData) == 0 {
+		return "", false
+	}
+	if isSensitive(key) {
+		return "", false
+	}
+	if branch == "" {
+		current, err := g.CurrentBranch()
+		if err != nil || current == "" {
+			return "", false
+		}
+		branch = current
+	}
+	return branchValue(branch, key)
+}
+
+// branchValue looks key up in the sections whose pattern matches branch
+func branchValue(branch, key string) (string, bool) {
+	for _, layer := range []map[string]map[string]string{localBranchData, globalBranchData} {
+		bestPattern, best := "", -1
+		for pattern, values := range layer {
+			if _, ok := values[key]; !ok || !MatchBranch(pattern, branch) {
+				continue
+			}
+			spec := specificity(pattern)
+			if spec > best || (spec == best && pattern < bestPattern) {
+				bestPattern, best = pattern, spec
+			}
+		}
+		if best >= 0 {
+			return layer[bestPattern][key], true
+		}
+	}
+	return "", false
+}
+
+// BranchOverrides returns the effective overrides for branch, keyed by config key
+func BranchOverrides(branch string) map[string]string {
+	keys := map[string]bool{}
+	for _, layer := range []map[string]map[string]string{localBranchData, globalBranchData} {
+		for pattern, values := range layer {
+			if MatchBranch(pattern, branch) {
+				for k := range values {
+					keys[k] = true
+				}
+			}
+		}
+	}
+	out := map[string]string{}
+	for k := range keys {
+		if v, ok := branchValue(branch, k); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// SetBranch sets key for branches matching pattern
+func SetBranch(pattern, key, value string, global bool) error {
+	if isSensitive(key) {
+		return fmt.Errorf("security: sensitive keys like %s can't be overridden per branch", key)
+	}
+	if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+		return fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+	}
+	layer := localBranchData
+	if global {
+		layer = globalBranchData
+	}
+	if layer[pattern] == nil {
+		layer[pattern] = map[string]string{}
+	}
+	layer[pattern][key] = value
+	if global {
+		return writeGlobalConfig()
+	}
+	return writeLocalConfig()
+}
+
+// UnsetBranch removes a per-branch override
+func UnsetBranch(pattern, key string, global bool) error {
+	layer := localBranchData
+	if global {
+		layer = globalBranchData
+	}
+	delete(layer[pattern], key)
+	if len(layer[pattern]) == 0 {
+		delete(layer, pattern)
+	}
+	if global {
+		return writeGlobalConfig()
+	}
+	return writeLocalConfig()
+}
+
+// decodeConfig splits a config file into plain keys and branch overrides.
+// Nested tables are flattened to dotted keys and non-string values are
+// stored in their string form, so `[ai]` + `model = "x"` reads as ai.model.
+func decodeConfig(b []byte) (map[string]string, map[string]map[string]string, error) {
+	raw := map[string]interface{}{}
+	if err := toml.Unmarshal(b, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	data := map[string]string{}
+	branches := map[string]map[string]string{}
+	for k, v := range raw {
+		table, ok := v.(map[string]interface{})
+		if !ok || k != branchTable {
+			flatten(k, v, data)
+			continue
+		}
+		for pattern, values := range table {
+			vt, ok := values.(map[string]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("[branch.%q] must be a table of overrides", pattern)
+			}
+			overrides := map[string]string{}
+			flatten("", vt, overrides)
+			branches[pattern] = overrides
+		}
+	}
+	return data, branches, nil
+}
+
+func flatten(prefix string, v interface{}, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, child, out)
+		}
+	case string:
+		out[prefix] = t
+	case []interface{}:
+		items := make([]string, len(t))
+		for i, item := range t {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	default:
+		out[prefix] = fmt.Sprint(t)
+	}
+}
+
+// encodeConfig is the inverse of decodeConfig
+func encodeConfig(data map[string]string, branches map[string]map[string]string) ([]byte, error) {
+	out := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	if len(branches) > 0 {
+		out[branchTable] = branches
+	}
+	return toml.Marshal(out)
+}
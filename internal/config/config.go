@@ -1,30 +1,30 @@
 package config
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
-	"github.com/BurntSushi/toml"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/secrets"
 	"github.com/crazywolf132/sage/internal/ui"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 var (
 	globalData = map[string]string{}
 	localData  = map[string]string{}
+
+	// Per-branch overrides from [branch."pattern"] tables: pattern -> key -> value
+	globalBranchData = map[string]map[string]string{}
+	localBranchData  = map[string]map[string]string{}
 )
 
+// branchTable is the TOML table holding per-branch overrides
+const branchTable = "branch"
+
 // LoadAllConfigs reads the global + local config and merges them.
 func LoadAllConfigs() error {
 	if err := loadGlobalConfig(); err != nil {
@@ -40,11 +40,24 @@ func LoadAllConfigs() error {
 }
 
 func Get(key string, useLocal bool) string {
-	// If useLocal is true and we're in a repo, check local first
+	return lookup(key, useLocal, "")
+}
+
+// GetForBranch is Get with local config, applying the overrides for branch
+// instead of the current branch's
+func GetForBranch(key, branch string) string {
+	return lookup(key, true, branch)
+}
+
+func lookup(key string, useLocal bool, branch string) string {
+	// If useLocal is true and we're in a repo, check branch overrides, then local
 	if useLocal {
 		g := git.NewShellGit()
 		repo, err := g.IsRepo()
 		if err == nil && repo {
+			if val, ok := branchOverride(g, key, branch); ok {
+				return val
+			}
 			if val, ok := localData[key]; ok {
 				return val
 			}
@@ -52,16 +65,8 @@ func Get(key string, useLocal bool) string {
 	}
 	// Otherwise check global
 	if val, ok := globalData[key]; ok {
-		// Check if this is a sensitive key that needs decryption
-		for _, k := range sensitiveKeys {
-			if strings.HasPrefix(strings.ToLower(key), strings.ToLower(k)) {
-				decrypted, err := decryptValue(val)
-				if err != nil {
-					ui.Warnf("Failed to decrypt sensitive value: %v\n", err)
-					return ""
-				}
-				return decrypted
-			}
+		if isSensitive(key) {
+			return readSecret(key, val)
 		}
 		return val
 	}
@@ -78,18 +83,13 @@ func Set(key, value string, global bool) error {
 		}
 	}
 
-	// For sensitive keys in global config, encrypt the value
-	if global {
-		for _, k := range sensitiveKeys {
-			if strings.HasPrefix(strings.ToLower(key), strings.ToLower(k)) {
-				encryptedValue, err := encryptValue(value)
-				if err != nil {
-					return fmt.Errorf("failed to secure sensitive value: %w", err)
-				}
-				value = encryptedValue
-				break
-			}
+	// Sensitive keys in global config go to the secrets store
+	if global && isSensitive(key) {
+		ref, err := storeSecret(key, value)
+		if err != nil {
+			return err
 		}
+		value = ref
 	}
 
 	if global {
@@ -100,9 +100,34 @@ func Set(key, value string, global bool) error {
 	return writeLocalConfig()
 }
 
+// GetPrefixed returns every key starting with prefix and its effective value
+func GetPrefixed(prefix string, useLocal bool) map[string]string {
+	out := map[string]string{}
+	keys := make([]string, 0, len(globalData)+len(localData))
+	for k := range globalData {
+		keys = append(keys, k)
+	}
+	if useLocal {
+		for k := range localData {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			out[k] = Get(k, useLocal)
+		}
+	}
+	return out
+}
+
 // Unset removes a configuration value
 func Unset(key string, global bool) error {
 	if global {
+		if globalData[key] == secretRef {
+			if err := secrets.Default().Delete(key); err != nil {
+				return err
+			}
+		}
 		delete(globalData, key)
 		return writeGlobalConfig()
 	}
@@ -112,7 +137,10 @@ func Unset(key string, global bool) error {
 
 // load / write global
 
-func globalPath() (string, error) {
+// Dir returns sage's config directory, creating it if needed: under
+// %APPDATA% on Windows, ~/Library/Application Support on macOS and
+// $XDG_CONFIG_HOME or ~/.config elsewhere
+func Dir() (string, error) {
 	var configDir string
 
 	switch runtime.GOOS {
@@ -151,8 +179,15 @@ func globalPath() (string, error) {
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return "", err
 	}
+	return configDir, nil
+}
 
-	return filepath.Join(configDir, "config.toml"), nil
+func globalPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
 }
 
 func loadGlobalConfig() error {
@@ -167,11 +202,11 @@ func loadGlobalConfig() error {
 		}
 		return err
 	}
-	tmp := map[string]string{}
-	if err := toml.Unmarshal(b, &tmp); err != nil {
+	data, branches, err := decodeConfig(b)
+	if err != nil {
 		return err
 	}
-	globalData = tmp
+	globalData, globalBranchData = data, branches
 	return nil
 }
 
@@ -180,7 +215,7 @@ func writeGlobalConfig() error {
 	if err != nil {
 		return err
 	}
-	b, err := toml.Marshal(globalData)
+	b, err := encodeConfig(globalData, globalBranchData)
 	if err != nil {
 		return err
 	}
@@ -207,11 +242,11 @@ func loadLocalConfig() error {
 	if err != nil {
 		return err
 	}
-	tmp := map[string]string{}
-	if err := toml.Unmarshal(b, &tmp); err != nil {
+	data, branches, err := decodeConfig(b)
+	if err != nil {
 		return err
 	}
-	localData = tmp
+	localData, localBranchData = data, branches
 	return nil
 }
 
@@ -219,6 +254,13 @@ func loadLocalConfig() error {
 var sensitiveKeys = []string{
 	"api.api_key",
 	"github.token",
+	"github.accounts.",
+	"github.app.private_key",
+	"gitlab.token",
+	"bitbucket.token",
+	"bitbucket.app_password",
+	"jira.token",
+	"linear.token",
 	"openai.api_key",
 	"ai.api_key",
 	"auth.token",
@@ -245,106 +287,18 @@ func writeLocalConfig() error {
 		}
 	}
 
-	b, err := toml.Marshal(localData)
+	b, err := encodeConfig(localData, localBranchData)
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(path, b, 0644)
 }
 
-// getMasterKey derives a master encryption key from system-specific data
-func getMasterKey() ([]byte, error) {
-	// Get system-specific data to derive the key from
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	// Get machine ID if available (usually in /etc/machine-id or /var/lib/dbus/machine-id)
-	var machineID string
-	if runtime.GOOS != "windows" {
-		if id, err := os.ReadFile("/etc/machine-id"); err == nil {
-			machineID = string(id)
-		} else if id, err := os.ReadFile("/var/lib/dbus/machine-id"); err == nil {
-			machineID = string(id)
-		}
-	}
-
-	// Combine system data into a unique string
-	systemData := fmt.Sprintf("%s:%s:%s:%s", homeDir, runtime.GOOS, runtime.GOARCH, machineID)
-
-	// Use PBKDF2 to derive a secure key
-	salt := []byte("sage-config-v1") // Version this so we can change it if needed
-	return pbkdf2.Key([]byte(systemData), salt, 100000, 32, sha256.New), nil
-}
-
-// encryptValue encrypts sensitive configuration values using AES-GCM
-func encryptValue(value string) (string, error) {
-	masterKey, err := getMasterKey()
-	if err != nil {
-		return "", fmt.Errorf("failed to get master key: %w", err)
-	}
-
-	block, err := aes.NewCipher(masterKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
-	}
-
-	// Generate a random nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
-	}
-
-	// Encrypt and authenticate the value
-	ciphertext := gcm.Seal(nonce, nonce, []byte(value), nil)
-
-	// Encode the result
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
-}
-
-// decryptValue decrypts sensitive configuration values
-func decryptValue(encrypted string) (string, error) {
-	masterKey, err := getMasterKey()
-	if err != nil {
-		return "", fmt.Errorf("failed to get master key: %w", err)
-	}
-
-	// Decode the base64 data
-	data, err := base64.StdEncoding.DecodeString(encrypted)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode encrypted value: %w", err)
-	}
-
-	block, err := aes.NewCipher(masterKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
-	}
-
-	if len(data) < gcm.NonceSize() {
-		return "", fmt.Errorf("invalid ciphertext length")
-	}
-
-	nonce := data[:gcm.NonceSize()]
-	ciphertext := data[gcm.NonceSize():]
-
-	// Decrypt and verify the value
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt value (possibly corrupted or from different system)")
-	}
-
-	return string(plaintext), nil
+// AIEnabled reports whether AI features may be used on branch (the current
+// branch when empty). Set ai.enabled to false, e.g. in a [branch."release/*"]
+// table, to turn them off.
+func AIEnabled(branch string) bool {
+	return strings.ToLower(lookup("ai.enabled", true, branch)) != "false"
 }
 
 // IsExperimentalFeatureEnabled checks if an experimental feature is enabled.
@@ -9,6 +9,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/secrets"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,6 +42,8 @@ func setupTestEnv(t *testing.T) *testEnv {
 	os.Setenv("HOME", tmpDir)
 	os.Setenv("APPDATA", tmpDir)
 	os.Setenv("XDG_CONFIG_HOME", tmpDir)
+	// Keep test secrets out of the real keychain
+	os.Setenv(secrets.BackendEnv, "file")
 
 	// Create directories
 	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, ".sage"), 0755))
@@ -101,6 +104,7 @@ func (env *testEnv) cleanup() {
 	os.Setenv("HOME", env.origHome)
 	os.Setenv("APPDATA", env.origAppData)
 	os.Setenv("XDG_CONFIG_HOME", env.origXdgConfig)
+	os.Unsetenv(secrets.BackendEnv)
 
 	// Clean up temporary directory
 	os.RemoveAll(env.tmpDir)
@@ -249,6 +253,19 @@ func TestConfigOperations(t *testing.T) {
 		require.NoError(t, Unset("unset.local", false))
 		assert.Empty(t, Get("unset.local", true))
 	})
+
+	t.Run("Prefixed lookup", func(t *testing.T) {
+		require.NoError(t, Set("deploy.staging", "origin deploy/staging", true))
+		require.NoError(t, Set("deploy.staging", "upstream deploy/staging", false))
+		require.NoError(t, Set("deploy.production", "origin deploy/prod", true))
+
+		got := GetPrefixed("deploy.", true)
+		assert.Equal(t, map[string]string{
+			"deploy.staging":    "upstream deploy/staging",
+			"deploy.production": "origin deploy/prod",
+		}, got)
+		assert.Equal(t, "origin deploy/staging", GetPrefixed("deploy.", false)["deploy.staging"])
+	})
 }
 
 func TestSensitiveKeyDetection(t *testing.T) {
@@ -308,3 +325,309 @@ func TestExperimentalFeatures(t *testing.T) {
 		assert.Contains(t, features, "maintenance")
 	})
 }
+
+func TestMatchBranch(t *testing.T) {
+	tests := []struct {
+		pattern, branch string
+		want            bool
+	}{
+		{"release/*", "release/1.2", true},
+		{"release/*", "release/1.2/fix", false},
+		{"release/**", "release/1.2/fix", true},
+		{"release/**", "release", false},
+		{"hotfix-?", "hotfix-1", true},
+		{"main", "main", true},
+		{"main", "maintenance", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchBranch(tt.pattern, tt.branch), "MatchBranch(%q, %q)", tt.pattern, tt.branch)
+	}
+}
+
+func TestBranchOverrides(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.cleanup()
+
+	globalData, localData = map[string]string{}, map[string]string{}
+	globalBranchData, localBranchData = map[string]map[string]string{}, map[string]map[string]string{}
+	defer func() {
+		globalBranchData, localBranchData = map[string]map[string]string{}, map[string]map[string]string{}
+	}()
+
+	branch, err := git.NewShellGit().CurrentBranch()
+	require.NoError(t, err)
+
+	require.NoError(t, Set("ai.enabled", "true", true))
+	require.NoError(t, SetBranch("*", "ai.enabled", "global-any", true))
+	assert.Equal(t, "global-any", Get("ai.enabled", true), "global branch override should apply")
+	assert.Equal(t, "true", Get("ai.enabled", false), "branch overrides only apply to the local layer")
+
+	require.NoError(t, SetBranch("*", "ai.enabled", "local-any", false))
+	assert.Equal(t, "local-any", Get("ai.enabled", true), "local overrides beat global ones")
+
+	require.NoError(t, SetBranch(branch, "ai.enabled", "exact", false))
+	assert.Equal(t, "exact", Get("ai.enabled", true), "the most specific pattern wins")
+	assert.Equal(t, map[string]string{"ai.enabled": "exact"}, BranchOverrides(branch))
+
+	assert.Error(t, SetBranch("*", "ai.api_key", "secret", true), "sensitive keys can't be overridden")
+
+	// Overrides survive a reload alongside plain keys
+	require.NoError(t, Set("plain.key", "value", false))
+	localData, localBranchData = map[string]string{}, map[string]map[string]string{}
+	require.NoError(t, LoadAllConfigs())
+	assert.Equal(t, "exact", Get("ai.enabled", true))
+	assert.Equal(t, "value", Get("plain.key", true))
+
+	require.NoError(t, UnsetBranch(branch, "ai.enabled", false))
+	assert.Equal(t, "local-any", Get("ai.enabled", true))
+}
+
+func TestDecodeConfig(t *testing.T) {
+	data, branches, err := decodeConfig([]byte(`
+"git.default_branch" = "main"
+
+[ai]
+model = "gpt-4"
+
+[pr]
+draft = true
+reviewers = ["alice", "bob"]
+
+[branch."release/*"]
+ai.enabled = false
+"pr.draft" = "false"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"git.default_branch": "main",
+		"ai.model":           "gpt-4",
+		"pr.draft":           "true",
+		"pr.reviewers":       "alice,bob",
+	}, data)
+	assert.Equal(t, map[string]map[string]string{
+		"release/*": {"ai.enabled": "false", "pr.draft": "false"},
+	}, branches)
+
+	_, _, err = decodeConfig([]byte(`[branch]
+"release/*" = "oops"`))
+	assert.Error(t, err)
+}
+
+func TestEnvName(t *testing.T) {
+	name, ok := EnvName("ai.model")
+	assert.True(t, ok)
+	assert.Equal(t, "SAGE_CONFIG_AI__MODEL", name)
+	key, ok := keyFromEnv(name)
+	assert.True(t, ok)
+	assert.Equal(t, "ai.model", key)
+
+	_, ok = EnvName("pr.branch_defaults.fix/*")
+	assert.False(t, ok, "globs can't be in variable names")
+	_, ok = keyFromEnv("GITHUB_TOKEN")
+	assert.False(t, ok)
+}
+
+func TestSettingsExport(t *testing.T) {
+	s := Settings{
+		Values:   map[string]string{"ai.model": "gpt-4", "github.token": "encrypted", "pr.branch_defaults.fix/*": "label:bug"},
+		Branches: map[string]map[string]string{"release/*": {"ai.enabled": "false"}},
+	}
+
+	b, err := s.TOML(SecretsReference)
+	require.NoError(t, err)
+	parsed, err := ParseSettings(b)
+	require.NoError(t, err)
+	assert.Equal(t, "env:SAGE_CONFIG_GITHUB__TOKEN", parsed.Values["github.token"])
+	assert.Equal(t, s.Branches, parsed.Branches)
+
+	b, err = s.TOML(SecretsOmit)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "github.token")
+
+	env := s.Env(SecretsMask)
+	assert.Contains(t, env, "export SAGE_CONFIG_AI__MODEL='gpt-4'\n")
+	assert.Contains(t, env, "export SAGE_CONFIG_GITHUB__TOKEN='********'\n")
+	assert.Contains(t, env, "# pr.branch_defaults.fix/* can't be set from the environment")
+	assert.NotContains(t, s.Env(SecretsReference), "encrypted")
+
+	fromEnv := SettingsFromEnv([]string{"SAGE_CONFIG_PR__DRAFT=true", "HOME=/home/me"})
+	assert.Equal(t, map[string]string{"pr.draft": "true"}, fromEnv.Values)
+}
+
+func TestImport(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.cleanup()
+
+	globalData, localData = map[string]string{}, map[string]string{}
+	globalBranchData, localBranchData = map[string]map[string]string{}, map[string]map[string]string{}
+	defer func() {
+		globalBranchData, localBranchData = map[string]map[string]string{}, map[string]map[string]string{}
+	}()
+
+	s := Settings{
+		Values: map[string]string{
+			"pr.draft":     "true",
+			"github.token": "env:SAGE_CONFIG_GITHUB__TOKEN",
+			"ai.api_key":   maskedValue,
+			"ai.base_url":  "env:UNSET_VAR",
+		},
+		Branches: map[string]map[string]string{"release/*": {"ai.enabled": "false"}},
+	}
+	getenv := func(name string) string {
+		if name == "SAGE_CONFIG_GITHUB__TOKEN" {
+			return "tok"
+		}
+		return ""
+	}
+
+	res, err := Import(s, true, getenv)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Imported)
+	assert.Len(t, res.Skipped, 2)
+	assert.Equal(t, "true", Get("pr.draft", false))
+	assert.Equal(t, "tok", Get("github.token", false))
+	assert.NotEqual(t, "tok", globalData["github.token"], "secrets are stored encrypted")
+	assert.Equal(t, "false", globalBranchData["release/*"]["ai.enabled"])
+
+	res, err = Import(Settings{Values: map[string]string{"github.token": "tok"}}, false, getenv)
+	require.NoError(t, err)
+	assert.Zero(t, res.Imported)
+	assert.Equal(t, []string{"github.token: secrets can only be imported globally"}, res.Skipped)
+}
+
+func TestSecretsStore(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.cleanup()
+
+	globalData, localData = map[string]string{}, map[string]string{}
+
+	require.NoError(t, Set("ai.api_key", "sk-live", true))
+	assert.Equal(t, secretRef, globalData["ai.api_key"], "the config file only refers to the store")
+	stored, err := secrets.Default().Get("ai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-live", stored)
+	assert.Equal(t, "sk-live", Get("ai.api_key", false))
+
+	// Values encrypted in the config file before the store still read, and
+	// move into it
+	legacy, err := encryptValue("ghp_old")
+	require.NoError(t, err)
+	globalData["github.token"] = legacy
+	assert.Equal(t, "ghp_old", Get("github.token", false))
+	moved, err := MigrateSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.token"}, moved)
+	assert.Equal(t, secretRef, globalData["github.token"])
+	assert.Equal(t, "ghp_old", Get("github.token", false))
+
+	require.NoError(t, Unset("ai.api_key", true))
+	_, err = secrets.Default().Get("ai.api_key")
+	assert.ErrorIs(t, err, secrets.ErrNotFound)
+	assert.Empty(t, Get("ai.api_key", false))
+}
+
+func TestCheckAndRepair(t *testing.T) {
+	env := setupTestEnv(t)
+	defer env.cleanup()
+
+	legacy, err := encryptValue("ghp_old")
+	require.NoError(t, err)
+	globalData = map[string]string{
+		"openai.model":    "gpt-4o",
+		"pr.draft":        "Yes",
+		"sync.strategy":   " Rebase",
+		"ai.temperature":  "warm",
+		"ai.legacy_flag":  "on",
+		"mytool.setting":  "keep",
+		"github.token":    legacy,
+		"jira.token":      "plain",
+		"linear.token":    secretRef,
+		"experimental.gc": "TRUE",
+	}
+	globalBranchData = map[string]map[string]string{"release/*": {"ai.enabled": "off"}}
+	localData = map[string]string{"gitlab.token": "glpat-local"}
+	localBranchData = map[string]map[string]string{}
+	require.NoError(t, writeGlobalConfig())
+	// Written by hand, as sage itself won't save a token locally
+	b, err := encodeConfig(map[string]string{"gitlab.token": "glpat-local"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(localPath()), 0755))
+	require.NoError(t, os.WriteFile(localPath(), b, 0644))
+	gp, err := globalPath()
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Chmod(gp, 0666))
+	}
+
+	issues := Check()
+	found := map[string]Issue{}
+	for _, i := range issues {
+		found[i.Scope+" "+i.Key] = i
+	}
+	assert.NotContains(t, found, "global mytool.setting", "keys in sections sage doesn't use are left alone")
+	assert.False(t, found["global ai.temperature"].Fixable(), "a value sage can't read is left to the user")
+	for _, key := range []string{"global openai.model", "global pr.draft", "global sync.strategy", "global ai.legacy_flag",
+		"global github.token", "global jira.token", "global linear.token", "global experimental.gc", "global ai.enabled", "local gitlab.token"} {
+		assert.True(t, found[key].Fixable(), "%s should be fixable: %+v", key, found[key])
+	}
+	if runtime.GOOS != "windows" {
+		assert.True(t, found["global "].Fixable(), "the world-writable config file")
+	}
+
+	backups, err := Repair(issues)
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "the global and local files")
+	for _, b := range backups {
+		assert.FileExists(t, b)
+	}
+
+	require.NoError(t, loadGlobalConfig())
+	require.NoError(t, loadLocalConfig())
+	assert.Equal(t, "gpt-4o", globalData["ai.model"])
+	assert.NotContains(t, globalData, "openai.model")
+	assert.NotContains(t, globalData, "ai.legacy_flag")
+	assert.NotContains(t, globalData, "linear.token")
+	assert.Equal(t, "true", globalData["pr.draft"])
+	assert.Equal(t, "rebase", globalData["sync.strategy"])
+	assert.Equal(t, "true", globalData["experimental.gc"])
+	assert.Equal(t, "warm", globalData["ai.temperature"])
+	assert.Equal(t, "keep", globalData["mytool.setting"])
+	assert.Equal(t, "false", globalBranchData["release/*"]["ai.enabled"])
+	for key, want := range map[string]string{"github.token": "ghp_old", "jira.token": "plain", "gitlab.token": "glpat-local"} {
+		assert.Equal(t, secretRef, globalData[key])
+		assert.Equal(t, want, Get(key, false))
+	}
+	assert.NotContains(t, localData, "gitlab.token")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(gp)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	}
+
+	left := Check()
+	require.Len(t, left, 1)
+	assert.Equal(t, "ai.temperature", left[0].Key)
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tt := range []struct {
+		spec  keySpec
+		in    string
+		want  string
+		valid bool
+	}{
+		{boolKey, "True", "true", true},
+		{boolKey, "0", "false", true},
+		{boolKey, "maybe", "", false},
+		{enumKey("merge", "rebase"), "MERGE ", "merge", true},
+		{enumKey("merge", "rebase"), "squash", "", false},
+		{intKey, " 14", "14", true},
+		{durationKey, "30", "", false},
+		{textKey, " Done ", " Done ", true},
+		{boolKey, "", "", true},
+	} {
+		got, ok := normalize(tt.spec, tt.in)
+		assert.Equal(t, tt.valid, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
@@ -0,0 +1,566 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/secrets"
+)
+
+// 'sage config doctor' looks through the config files for keys older
+// versions of sage left behind, values sage can't read, secrets kept
+// outside the secrets store and files other users can write to. Repair
+// fixes what it can, after backing the files up.
+
+type keyKind int
+
+const (
+	kindText keyKind = iota
+	kindBool
+	kindEnum
+	kindInt
+	kindFloat
+	kindDuration
+)
+
+// keySpec says what a key sage reads holds
+type keySpec struct {
+	kind   keyKind
+	values []string // what an enum may be
+}
+
+var (
+	textKey     = keySpec{}
+	boolKey     = keySpec{kind: kindBool}
+	intKey      = keySpec{kind: kindInt}
+	floatKey    = keySpec{kind: kindFloat}
+	durationKey = keySpec{kind: kindDuration}
+)
+
+func enumKey(values ...string) keySpec {
+	return keySpec{kind: kindEnum, values: values}
+}
+
+// knownKeys are the keys sage reads
+var knownKeys = map[string]keySpec{
+	"ai.api_key":                  textKey,
+	"ai.base_url":                 textKey,
+	"ai.context_tokens":           intKey,
+	"ai.enabled":                  boolKey,
+	"ai.max_tokens":               intKey,
+	"ai.model":                    textKey,
+	"ai.provider":                 enumKey("openai", "anthropic", "ollama"),
+	"ai.temperature":              floatKey,
+	"audit.enabled":               boolKey,
+	"audit.webhook":               textKey,
+	"bitbucket.app_password":      textKey,
+	"bitbucket.token":             textKey,
+	"bitbucket.username":          textKey,
+	"clean.abandoned_days":        intKey,
+	"commit.editor":               boolKey,
+	"commit.message_pattern":      textKey,
+	"commit.message_warn_pattern": textKey,
+	"commit.only_staged_default":  boolKey,
+	"commit.ticket_pattern":       textKey,
+	"commit.timestamp_round":      enumKey("none", "minute", "hour", "day"),
+	"commit.timezone":             textKey,
+	"deploy.protected":            textKey,
+	"doctor.email_domains":        textKey,
+	"forge.type":                  enumKey("github", "gitlab", "bitbucket"),
+	"git.default_branch":          textKey,
+	"git.merge_method":            enumKey("merge", "squash", "rebase"),
+	"github.app.id":               textKey,
+	"github.app.installation_id":  textKey,
+	"github.app.private_key":      textKey,
+	"github.auth":                 enumKey("token", "app"),
+	"github.token":                textKey,
+	"gitlab.token":                textKey,
+	"issues.link_commits":         boolKey,
+	"issues.link_prs":             boolKey,
+	"issues.provider":             enumKey("jira", "linear"),
+	"issues.transition_on_merge":  textKey,
+	"jira.base_url":               textKey,
+	"jira.email":                  textKey,
+	"jira.token":                  textKey,
+	"linear.token":                textKey,
+	"pr.draft":                    boolKey,
+	"pr.labels":                   textKey,
+	"pr.reviewers":                textKey,
+	"pr.squash_conventional":      boolKey,
+	"pr.suggest_reviewers":        boolKey,
+	"secrets.blocked_files":       textKey,
+	"start.fetch":                 enumKey("full", "minimal", "none"),
+	"sync.base":                   textKey,
+	"sync.push":                   boolKey,
+	"sync.strategy":               enumKey("merge", "rebase"),
+	"ui.notify":                   enumKey("off", "bell", "desktop", "all"),
+	"ui.notify_after":             durationKey,
+	"ui.suggestions":              boolKey,
+	"ui.syntax_theme":             textKey,
+	"update.channel":              enumKey("stable", "beta"),
+	"update.check_interval":       enumKey("daily", "weekly", "never"),
+}
+
+// knownPrefixes are families of keys, such as one per deploy target
+var knownPrefixes = map[string]keySpec{
+	"ai.model.":           textKey,
+	"deploy.":             textKey,
+	"experimental.":       boolKey,
+	"github.accounts.":    textKey,
+	"github.identity.":    textKey,
+	"pr.branch_defaults.": textKey,
+}
+
+// renamedKeys maps keys older versions of sage read to their names now
+var renamedKeys = map[string]string{
+	"api.api_key":        "ai.api_key",
+	"openai.api_key":     "ai.api_key",
+	"openai.base_url":    "ai.base_url",
+	"openai.model":       "ai.model",
+	"git.merge.strategy": "sync.strategy",
+}
+
+func specFor(key string) (keySpec, bool) {
+	if spec, ok := knownKeys[key]; ok {
+		return spec, true
+	}
+	for prefix, spec := range knownPrefixes {
+		if strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return spec, true
+		}
+	}
+	return keySpec{}, false
+}
+
+// ownedSection reports whether key's section, such as "ai" for ai.foo, is
+// one sage uses. Unknown keys there are leftovers or typos; keys in other
+// sections may be someone's own and are left alone.
+func ownedSection(key string) bool {
+	section, _, _ := strings.Cut(key, ".")
+	for k := range knownKeys {
+		if strings.HasPrefix(k, section+".") {
+			return true
+		}
+	}
+	for k := range renamedKeys {
+		if strings.HasPrefix(k, section+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns value in the form sage expects for spec, or false when
+// it can't be read at all. Empty values mean the default and are left be.
+func normalize(spec keySpec, value string) (string, bool) {
+	v := strings.TrimSpace(value)
+	if v == "" || spec.kind == kindText {
+		return value, true
+	}
+	switch spec.kind {
+	case kindBool:
+		switch strings.ToLower(v) {
+		case "true", "yes", "on", "1":
+			return "true", true
+		case "false", "no", "off", "0":
+			return "false", true
+		}
+	case kindEnum:
+		for _, allowed := range spec.values {
+			if strings.EqualFold(v, allowed) {
+				return allowed, true
+			}
+		}
+	case kindInt:
+		if _, err := strconv.Atoi(v); err == nil {
+			return v, true
+		}
+	case kindFloat:
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v, true
+		}
+	case kindDuration:
+		if _, err := time.ParseDuration(v); err == nil {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (s keySpec) describe() string {
+	switch s.kind {
+	case kindBool:
+		return "true or false"
+	case kindEnum:
+		return "one of " + strings.Join(s.values, ", ")
+	case kindInt:
+		return "a whole number"
+	case kindFloat:
+		return "a number"
+	case kindDuration:
+		return "a duration such as 30s or 2m"
+	}
+	return "text"
+}
+
+// Issue is a problem Check found in the config
+type Issue struct {
+	Scope   string // global, local or secrets
+	Branch  string // the [branch."pattern"] table holding Key, if any
+	Key     string // empty when the problem is with a file
+	Problem string
+	Fix     string // what Repair does about it; empty when it's left to you
+	repair  func() error
+}
+
+// Fixable reports whether Repair can fix the issue
+func (i Issue) Fixable() bool {
+	return i.repair != nil
+}
+
+// Check looks through the global config, this repository's when in one,
+// and the secrets store for problems
+func Check() []Issue {
+	var issues []Issue
+	fileErr := secretsFileError()
+	if fileErr != nil {
+		issues = append(issues, Issue{
+			Scope:   "secrets",
+			Problem: fmt.Sprintf("%s can't be read: %v", secrets.FilePath(), fileErr),
+			Fix:     "remove it and the keys whose secrets it held, so they can be set again",
+			repair:  dropSecretsFile,
+		})
+	}
+
+	for _, scope := range doctorScopes() {
+		data, branches := globalData, globalBranchData
+		if scope == "local" {
+			data, branches = localData, localBranchData
+		}
+		for _, key := range sortedKeys(data) {
+			if scope == "global" && isSensitive(key) && renamedKeys[key] == "" {
+				issues = append(issues, checkSecret(key, data[key], fileErr)...)
+				continue
+			}
+			issues = append(issues, checkKey(scope, "", data, key)...)
+		}
+		for _, pattern := range sortedPatterns(branches) {
+			for _, key := range sortedKeys(branches[pattern]) {
+				issues = append(issues, checkKey(scope, pattern, branches[pattern], key)...)
+			}
+		}
+	}
+	return append(issues, checkPermissions()...)
+}
+
+// doctorScopes are the config files to look through
+func doctorScopes() []string {
+	if repo, err := git.NewShellGit().IsRepo(); err == nil && repo {
+		return []string{"global", "local"}
+	}
+	return []string{"global"}
+}
+
+func sortedPatterns(m map[string]map[string]string) []string {
+	patterns := map[string]string{}
+	for p := range m {
+		patterns[p] = ""
+	}
+	return sortedKeys(patterns)
+}
+
+// checkKey checks a key of data, the plain values of scope's file or one
+// of its branch tables. Secrets in the global file are checkSecret's.
+func checkKey(scope, branch string, data map[string]string, key string) []Issue {
+	issue := Issue{Scope: scope, Branch: branch, Key: key}
+	value := data[key]
+	remove := func() error {
+		if scope == "global" && branch == "" && value == secretRef {
+			if err := secrets.Default().Delete(key); err != nil {
+				return err
+			}
+		}
+		delete(data, key)
+		return nil
+	}
+
+	if isSensitive(key) && (scope != "global" || branch != "") {
+		issue.Problem = "secrets can't be kept in the local config or per branch, so it's ignored"
+		issue.Fix = "remove it"
+		issue.repair = remove
+		target := key
+		if renamed, ok := renamedKeys[key]; ok {
+			target = renamed
+		}
+		if scope == "local" && branch == "" && Get(target, false) == "" {
+			issue.Fix = "move it to the " + SecretStore()
+			issue.repair = func() error {
+				ref, err := storeSecret(target, value)
+				if err != nil {
+					return err
+				}
+				globalData[target] = ref
+				delete(data, key)
+				return nil
+			}
+		}
+		return []Issue{issue}
+	}
+
+	if renamed, ok := renamedKeys[key]; ok {
+		if _, taken := data[renamed]; taken {
+			issue.Problem = fmt.Sprintf("replaced by %s, which is set too", renamed)
+			issue.Fix = "remove it"
+			issue.repair = remove
+			return []Issue{issue}
+		}
+		issue.Problem = "renamed to " + renamed
+		issue.Fix = "rename it"
+		issue.repair = func() error {
+			if scope == "global" && branch == "" && isSensitive(renamed) {
+				plain, err := plainSecret(key, value)
+				if err != nil {
+					return err
+				}
+				if data[renamed], err = storeSecret(renamed, plain); err != nil {
+					return err
+				}
+			} else {
+				data[renamed] = value
+			}
+			return remove()
+		}
+		return []Issue{issue}
+	}
+
+	spec, known := specFor(key)
+	if !known {
+		if !ownedSection(key) {
+			return nil
+		}
+		issue.Problem = "not a setting sage reads; left by an older version or misspelled"
+		issue.Fix = "remove it"
+		issue.repair = remove
+		return []Issue{issue}
+	}
+
+	normalized, ok := normalize(spec, value)
+	switch {
+	case !ok:
+		issue.Problem = fmt.Sprintf("%q isn't %s", value, spec.describe())
+		return []Issue{issue}
+	case normalized != value:
+		issue.Problem = fmt.Sprintf("%q should be written %q", value, normalized)
+		issue.Fix = fmt.Sprintf("change it to %q", normalized)
+		issue.repair = func() error {
+			data[key] = normalized
+			return nil
+		}
+		return []Issue{issue}
+	}
+	return nil
+}
+
+// checkSecret checks a sensitive key in the global config, which should
+// only hold a reference to the secrets store
+func checkSecret(key, value string, fileErr error) []Issue {
+	issue := Issue{Scope: "global", Key: key}
+	store := SecretStore()
+
+	if value == secretRef {
+		_, err := secrets.Default().Get(key)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, secrets.ErrNotFound):
+			issue.Problem = "kept in the " + store + ", but it isn't there"
+			issue.Fix = "remove it, so it can be set again"
+			issue.repair = func() error {
+				delete(globalData, key)
+				return nil
+			}
+		case fileErr != nil:
+			// The secrets file's issue covers it
+			return nil
+		default:
+			issue.Problem = fmt.Sprintf("can't be read from the %s: %v", store, err)
+		}
+		return []Issue{issue}
+	}
+
+	if _, err := decryptValue(value); err == nil {
+		issue.Problem = "encrypted in the config file by an older version of sage"
+		issue.Fix = "move it to the " + store
+	} else if looksEncrypted(value) {
+		issue.Problem = "encrypted with a key this machine doesn't have"
+		issue.Fix = "remove it, so it can be set again"
+		issue.repair = func() error {
+			delete(globalData, key)
+			return nil
+		}
+		return []Issue{issue}
+	} else {
+		issue.Problem = "kept in plain text in the config file"
+		issue.Fix = "move it to the " + store
+	}
+	issue.repair = func() error {
+		plain, err := decryptValue(value)
+		if err != nil {
+			plain = value
+		}
+		ref, err := storeSecret(key, plain)
+		if err != nil {
+			return err
+		}
+		globalData[key] = ref
+		return nil
+	}
+	return []Issue{issue}
+}
+
+// looksEncrypted tells ciphertext sage wrote from a token pasted into the
+// file by hand: base64 long enough for a nonce and tag. Tokens with -, _ or
+// a prefix such as ghp_ never pass.
+func looksEncrypted(value string) bool {
+	data, err := base64.StdEncoding.DecodeString(value)
+	return err == nil && len(data) >= 28
+}
+
+// plainSecret returns the secret a sensitive key in the global config
+// stands for
+func plainSecret(key, value string) (string, error) {
+	if value == secretRef {
+		return secrets.Default().Get(key)
+	}
+	return decryptValue(value)
+}
+
+// secretsFileError says why the encrypted secrets file can't be read, such
+// as after moving to another machine
+func secretsFileError() error {
+	_, err := secrets.NewFileStore(secrets.FilePath()).Get("")
+	if err == nil || errors.Is(err, secrets.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// dropSecretsFile removes the unreadable secrets file and the references
+// to secrets that went with it
+func dropSecretsFile() error {
+	if err := os.Remove(secrets.FilePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for key, value := range globalData {
+		if value != secretRef {
+			continue
+		}
+		if _, err := secrets.Default().Get(key); errors.Is(err, secrets.ErrNotFound) {
+			delete(globalData, key)
+		}
+	}
+	return nil
+}
+
+// checkPermissions looks for config files other users can write to, where
+// they could point ai.base_url at their own server, and a secrets file
+// they can read
+func checkPermissions() []Issue {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var issues []Issue
+	check := func(scope, path string, mask os.FileMode) {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm()&mask == 0 {
+			return
+		}
+		want := info.Mode().Perm() &^ mask
+		who := "writable"
+		if mask&0044 != 0 {
+			who = "readable"
+		}
+		issues = append(issues, Issue{
+			Scope:   scope,
+			Problem: fmt.Sprintf("%s is %s by other users (%04o)", path, who, info.Mode().Perm()),
+			Fix:     fmt.Sprintf("change its permissions to %04o", want),
+			repair: func() error {
+				return os.Chmod(path, want)
+			},
+		})
+	}
+	if p, err := globalPath(); err == nil {
+		check("global", p, 0022)
+	}
+	if len(doctorScopes()) > 1 {
+		if p := localPath(); p != "" {
+			check("local", p, 0022)
+		}
+	}
+	check("secrets", secrets.FilePath(), 0077)
+	return issues
+}
+
+// Repair backs up the config files and the secrets file, then fixes the
+// issues it can. It returns the backups it made, even when a fix fails.
+func Repair(issues []Issue) ([]string, error) {
+	globalBefore, _ := encodeConfig(globalData, globalBranchData)
+	localBefore, _ := encodeConfig(localData, localBranchData)
+
+	files := []string{secrets.FilePath()}
+	if p, err := globalPath(); err == nil {
+		files = append(files, p)
+	}
+	local := len(doctorScopes()) > 1
+	if local {
+		files = append(files, localPath())
+	}
+	var backups []string
+	stamp := time.Now().Format("20060102-150405")
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return backups, fmt.Errorf("failed to back up %s: %w", f, err)
+		}
+		backup := f + "." + stamp + ".bak"
+		if err := os.WriteFile(backup, b, 0600); err != nil {
+			return backups, fmt.Errorf("failed to back up %s: %w", f, err)
+		}
+		backups = append(backups, backup)
+	}
+
+	for _, i := range issues {
+		if i.repair == nil {
+			continue
+		}
+		if err := i.repair(); err != nil {
+			what := i.Key
+			if what == "" {
+				what = i.Scope
+			}
+			return backups, fmt.Errorf("failed to fix %s: %w", what, err)
+		}
+	}
+
+	if after, err := encodeConfig(globalData, globalBranchData); err != nil || string(after) != string(globalBefore) {
+		if err := writeGlobalConfig(); err != nil {
+			return backups, err
+		}
+	}
+	if after, err := encodeConfig(localData, localBranchData); local && (err != nil || string(after) != string(localBefore)) {
+		if err := writeLocalConfig(); err != nil {
+			return backups, err
+		}
+	}
+	return backups, nil
+}
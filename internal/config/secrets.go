@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/crazywolf132/sage/internal/secrets"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// secretRef stands in the config file for a sensitive value kept in the
+// secrets store, so the key is still listed there
+const secretRef = "secret:stored"
+
+// storeSecret puts a sensitive value in the secrets store, returning what
+// the config file keeps for it
+func storeSecret(key, value string) (string, error) {
+	if err := secrets.Default().Set(key, value); err != nil {
+		return "", fmt.Errorf("failed to secure sensitive value: %w", err)
+	}
+	return secretRef, nil
+}
+
+// readSecret returns a sensitive value from what the config file holds for
+// it: a reference to the secrets store or, from before there was one, the
+// value encrypted in place
+func readSecret(key, val string) string {
+	if val != secretRef {
+		decrypted, err := decryptValue(val)
+		if err != nil {
+			ui.Warnf("Failed to decrypt sensitive value: %v\n", err)
+			return ""
+		}
+		return decrypted
+	}
+	value, err := secrets.Default().Get(key)
+	if err != nil {
+		if !errors.Is(err, secrets.ErrNotFound) {
+			ui.Warnf("Failed to read %s: %v\n", key, err)
+		}
+		return ""
+	}
+	return value
+}
+
+// MigrateSecrets moves sensitive values still encrypted in the global config
+// file into the secrets store, returning the keys it moved
+func MigrateSecrets() ([]string, error) {
+	var moved []string
+	for _, key := range sortedKeys(globalData) {
+		val := globalData[key]
+		if !isSensitive(key) || val == secretRef {
+			continue
+		}
+		plain, err := decryptValue(val)
+		if err != nil {
+			return moved, fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		if globalData[key], err = storeSecret(key, plain); err != nil {
+			return moved, err
+		}
+		moved = append(moved, key)
+	}
+	if len(moved) == 0 {
+		return nil, nil
+	}
+	return moved, writeGlobalConfig()
+}
+
+// IsSensitive reports whether key holds a secret, kept in the secrets store
+func IsSensitive(key string) bool {
+	return isSensitive(key)
+}
+
+// SecretStore says where sensitive values are kept
+func SecretStore() string {
+	return secrets.Default().Name()
+}
+
+// encryptValue encrypts sensitive configuration values for this machine
+func encryptValue(value string) (string, error) {
+	return secrets.Encrypt(value)
+}
+
+// decryptValue decrypts sensitive configuration values
+func decryptValue(encrypted string) (string, error) {
+	return secrets.Decrypt(encrypted)
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// Settings is a whole configuration as shared between machines with
+// 'sage config export' and 'sage config import'
+type Settings struct {
+	Values   map[string]string
+	Branches map[string]map[string]string // pattern -> key -> value
+}
+
+// SecretMode says how exported settings carry sensitive values
+type SecretMode string
+
+const (
+	// SecretsReference writes env:<NAME>, read from the environment on import
+	SecretsReference SecretMode = "reference"
+	// SecretsMask writes a placeholder that import skips
+	SecretsMask SecretMode = "mask"
+	// SecretsOmit leaves sensitive keys out
+	SecretsOmit SecretMode = "omit"
+)
+
+const (
+	maskedValue  = "********"
+	envRefPrefix = "env:"
+	// EnvPrefix starts the environment variables settings are exported to
+	EnvPrefix = "SAGE_CONFIG_"
+)
+
+// Effective returns the settings in force here: global values with local
+// ones on top when in a repository, and the branch overrides of both
+func Effective() Settings {
+	s := Settings{Values: map[string]string{}, Branches: map[string]map[string]string{}}
+	add := func(values map[string]string, branches map[string]map[string]string) {
+		for k, v := range values {
+			s.Values[k] = v
+		}
+		for pattern, overrides := range branches {
+			if s.Branches[pattern] == nil {
+				s.Branches[pattern] = map[string]string{}
+			}
+			for k, v := range overrides {
+				s.Branches[pattern][k] = v
+			}
+		}
+	}
+	add(globalData, globalBranchData)
+	if repo, err := git.NewShellGit().IsRepo(); err == nil && repo {
+		add(localData, localBranchData)
+	}
+	return s
+}
+
+// secretValue is what an exported settings file holds for a sensitive key.
+// The real value is never written; global secrets live in the secrets
+// store anyway.
+func secretValue(key string, mode SecretMode) (string, bool) {
+	switch mode {
+	case SecretsOmit:
+		return "", false
+	case SecretsMask:
+		return maskedValue, true
+	}
+	name, ok := EnvName(key)
+	if !ok {
+		return maskedValue, true
+	}
+	return envRefPrefix + name, true
+}
+
+// TOML renders the settings as a config file. Sensitive values are handled
+// as mode says.
+func (s Settings) TOML(mode SecretMode) ([]byte, error) {
+	values := map[string]string{}
+	for k, v := range s.Values {
+		if isSensitive(k) {
+			var ok bool
+			if v, ok = secretValue(k, mode); !ok {
+				continue
+			}
+		}
+		values[k] = v
+	}
+	return encodeConfig(values, s.Branches)
+}
+
+var envSafeKey = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// EnvName is the environment variable a key is exported to: ai.model is
+// SAGE_CONFIG_AI__MODEL. Keys with characters a variable name can't carry,
+// such as globs, have none.
+func EnvName(key string) (string, bool) {
+	if !envSafeKey.MatchString(key) || strings.Contains(key, "__") {
+		return "", false
+	}
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "__")), true
+}
+
+// keyFromEnv is the inverse of EnvName
+func keyFromEnv(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, EnvPrefix)
+	if !ok || rest == "" {
+		return "", false
+	}
+	return strings.ToLower(strings.ReplaceAll(rest, "__", ".")), true
+}
+
+// Env renders the settings as shell export lines. Branch overrides and keys
+// without a variable name are listed as comments, since they can't be
+// carried this way.
+func (s Settings) Env(mode SecretMode) string {
+	var b strings.Builder
+	for _, k := range sortedKeys(s.Values) {
+		name, ok := EnvName(k)
+		if !ok {
+			fmt.Fprintf(&b, "# %s can't be set from the environment; import a TOML export for it\n", k)
+			continue
+		}
+		v := s.Values[k]
+		if isSensitive(k) {
+			switch mode {
+			case SecretsOmit:
+				continue
+			case SecretsMask:
+				v = maskedValue
+			default:
+				fmt.Fprintf(&b, "# %s is a secret; set %s from your secret store\n", k, name)
+				continue
+			}
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(v))
+	}
+	if len(s.Branches) > 0 {
+		fmt.Fprintf(&b, "# %d branch override table(s) can't be set from the environment; import a TOML export for them\n", len(s.Branches))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ParseSettings reads settings from a config file or TOML export
+func ParseSettings(b []byte) (Settings, error) {
+	values, branches, err := decodeConfig(b)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{Values: values, Branches: branches}, nil
+}
+
+// SettingsFromEnv collects the SAGE_CONFIG_* variables from environ, in the
+// form of os.Environ
+func SettingsFromEnv(environ []string) Settings {
+	s := Settings{Values: map[string]string{}, Branches: map[string]map[string]string{}}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if key, ok := keyFromEnv(name); ok {
+			s.Values[key] = value
+		}
+	}
+	return s
+}
+
+// ImportResult says what Import did
+type ImportResult struct {
+	Imported int
+	Skipped  []string // key and the reason it wasn't imported
+}
+
+// Import writes settings to the global or local config, over any values
+// already there. env:<NAME> values are read from getenv. Masked values,
+// unset references, and secrets bound for the local config are skipped.
+func Import(s Settings, global bool, getenv func(string) string) (ImportResult, error) {
+	var res ImportResult
+	resolve := func(key, value string) (string, bool) {
+		if value == maskedValue {
+			res.Skipped = append(res.Skipped, key+": masked in the export")
+			return "", false
+		}
+		if name, ok := strings.CutPrefix(value, envRefPrefix); ok {
+			if value = getenv(name); value == "" {
+				res.Skipped = append(res.Skipped, key+": "+name+" is not set")
+				return "", false
+			}
+		}
+		return value, true
+	}
+
+	values, branches := localData, localBranchData
+	if global {
+		values, branches = globalData, globalBranchData
+	}
+
+	for _, k := range sortedKeys(s.Values) {
+		v, ok := resolve(k, s.Values[k])
+		if !ok {
+			continue
+		}
+		if isSensitive(k) {
+			if !global {
+				res.Skipped = append(res.Skipped, k+": secrets can only be imported globally")
+				continue
+			}
+			ref, err := storeSecret(k, v)
+			if err != nil {
+				return res, fmt.Errorf("failed to secure %s: %w", k, err)
+			}
+			v = ref
+		}
+		values[k] = v
+		res.Imported++
+	}
+
+	for pattern, overrides := range s.Branches {
+		for _, k := range sortedKeys(overrides) {
+			if isSensitive(k) {
+				res.Skipped = append(res.Skipped, k+" for "+pattern+": secrets can't be branch overrides")
+				continue
+			}
+			v, ok := resolve(k, overrides[k])
+			if !ok {
+				continue
+			}
+			if branches[pattern] == nil {
+				branches[pattern] = map[string]string{}
+			}
+			branches[pattern][k] = v
+			res.Imported++
+		}
+	}
+
+	if global {
+		return res, writeGlobalConfig()
+	}
+	return res, writeLocalConfig()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
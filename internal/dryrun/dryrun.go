@@ -0,0 +1,96 @@
+// Package dryrun lets mutating commands describe what they would do instead of
+// doing it. When enabled, the git and GitHub layers print each command or API
+// call that would change something, with credentials redacted, and skip it.
+// Read-only operations still run so the plan reflects the real repository.
+package dryrun
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	mu      sync.Mutex
+	enabled bool
+	out     io.Writer = os.Stdout
+	actions []string
+)
+
+// Enable turns dry-run mode on, printing intercepted actions to w
+func Enable(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled, out, actions = true, w, nil
+}
+
+// Disable turns dry-run mode off
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled, out, actions = false, os.Stdout, nil
+}
+
+// Enabled reports whether dry-run mode is on
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// Record prints an action that was skipped because of dry-run mode
+func Record(format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	line := Redact(fmt.Sprintf(format, args...))
+	actions = append(actions, line)
+	fmt.Fprintf(out, "[dry-run] %s\n", line)
+}
+
+// Actions returns everything recorded since dry-run mode was enabled
+func Actions() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), actions...)
+}
+
+// shellSafe matches arguments a POSIX shell reads as they are
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote joins args into a command line that can be pasted into a shell,
+// single-quoting the arguments that need it
+func Quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if shellSafe.MatchString(a) {
+			quoted[i] = a
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+var (
+	urlCredentials = regexp.MustCompile(`(https?://)[^/\s@]+@`)
+	tokenLike      = regexp.MustCompile(`\b(ghp_|gho_|ghu_|ghs_|ghr_|github_pat_|sk-)[A-Za-z0-9_\-]{8,}`)
+	secretField    = regexp.MustCompile(`(?i)("?(?:token|password|secret|api[_-]?key|authorization)"?\s*[:=]\s*)("[^"]*"|[^\s,}&]+)`)
+)
+
+// Redact hides credentials embedded in URLs, well-known token formats and
+// values of secret-looking fields such as token=... or "password": "...".
+func Redact(s string) string {
+	s = urlCredentials.ReplaceAllString(s, "${1}***@")
+	s = tokenLike.ReplaceAllString(s, "${1}***")
+	s = secretField.ReplaceAllStringFunc(s, func(m string) string {
+		parts := secretField.FindStringSubmatch(m)
+		if strings.HasPrefix(parts[2], `"`) {
+			return parts[1] + `"***"`
+		}
+		return parts[1] + "***"
+	})
+	return s
+}
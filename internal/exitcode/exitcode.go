@@ -0,0 +1,84 @@
+// Package exitcode defines the exit status sage finishes with, so scripts and
+// CI pipelines can tell failures apart. The numbers are stable; new codes are
+// only ever added.
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes
+const (
+	OK        = 0
+	Failure   = 1 // anything without a more specific code
+	Usage     = 2 // invalid arguments or flags
+	Conflict  = 3 // a merge, rebase or patch stopped on conflicts
+	Auth      = 4 // credentials are missing or were rejected
+	DirtyTree = 5 // uncommitted changes are in the way
+	NotFound  = 6 // the PR, branch, release or other object doesn't exist
+	Threshold = 7 // a --fail-on threshold was reached
+)
+
+// Code describes one exit code
+type Code struct {
+	Code        int
+	Name        string
+	Description string
+}
+
+// All lists every exit code, for documentation
+var All = []Code{
+	{OK, "ok", "Success"},
+	{Failure, "failure", "Any error without a more specific code"},
+	{Usage, "usage", "Invalid arguments or flags"},
+	{Conflict, "conflict", "A merge, rebase or patch stopped on conflicts that need resolving"},
+	{Auth, "auth", "Credentials are missing or were rejected"},
+	{DirtyTree, "dirty-tree", "Uncommitted changes are in the way; commit or stash them"},
+	{NotFound, "not-found", "The PR, branch, release or other object doesn't exist"},
+	{Threshold, "threshold", "A --fail-on threshold was reached"},
+}
+
+// Error is an error carrying the exit code sage should finish with
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Coder is implemented by errors that know their own exit code
+type Coder interface {
+	ExitCode() int
+}
+
+// New attaches code to err, returning nil for a nil err
+func New(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf formats an error with the given exit code
+func Errorf(code int, format string, args ...any) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns the exit code for err: OK for nil, the outermost code attached
+// anywhere in its chain, or Failure
+func Of(err error) int {
+	if err == nil {
+		return OK
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch v := e.(type) {
+		case *Error:
+			return v.Code
+		case Coder:
+			return v.ExitCode()
+		}
+	}
+	return Failure
+}
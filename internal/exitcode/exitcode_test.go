@@ -0,0 +1,43 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type conflictError struct{}
+
+func (conflictError) Error() string { return "conflicts" }
+func (conflictError) ExitCode() int { return Conflict }
+
+func TestOf(t *testing.T) {
+	base := errors.New("boom")
+
+	assert.Equal(t, OK, Of(nil))
+	assert.Equal(t, Failure, Of(base))
+	assert.Equal(t, Auth, Of(New(Auth, base)))
+	assert.Equal(t, Auth, Of(fmt.Errorf("creating PR: %w", New(Auth, base))))
+	assert.Equal(t, Usage, Of(New(Usage, New(Auth, base))), "outermost code wins")
+	assert.Equal(t, Conflict, Of(fmt.Errorf("sync: %w", conflictError{})))
+	assert.Equal(t, DirtyTree, Of(Errorf(DirtyTree, "commit or stash %s", "it")))
+}
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(Auth, nil))
+
+	base := errors.New("boom")
+	err := New(NotFound, base)
+	assert.True(t, errors.Is(err, base))
+	assert.Equal(t, "boom", err.Error())
+}
+
+func TestAllIsComplete(t *testing.T) {
+	for i, c := range All {
+		assert.Equal(t, i, c.Code, "All is ordered by code without gaps")
+		assert.NotEmpty(t, c.Name)
+		assert.NotEmpty(t, c.Description)
+	}
+}
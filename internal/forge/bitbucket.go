@@ -0,0 +1,577 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// bitbucketAPI is the Bitbucket Cloud REST API root
+const bitbucketAPI = "https://api.bitbucket.org/2.0"
+
+// bitbucketClient talks to the Bitbucket Cloud API (2.0) for one repository
+type bitbucketClient struct {
+	client   *http.Client
+	apiURL   string
+	repo     string // workspace/repo_slug
+	username string // set with an app password; empty for OAuth/access tokens
+	secret   string
+}
+
+// bitbucketPR is a Bitbucket pull request as the API returns it
+type bitbucketPR struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	State       string    `json:"state"` // OPEN, MERGED, DECLINED or SUPERSEDED
+	Draft       bool      `json:"draft"`
+	UpdatedOn   time.Time `json:"updated_on"`
+	Author      struct {
+		Nickname string `json:"nickname"`
+	} `json:"author"`
+	Source      bitbucketEndpoint `json:"source"`
+	Destination bitbucketEndpoint `json:"destination"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// bitbucketEndpoint is one side of a pull request
+type bitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// pullRequest converts a Bitbucket pull request into the PR type the rest of sage uses
+func (b bitbucketPR) pullRequest() gh.PullRequest {
+	pr := gh.PullRequest{
+		Number:    b.ID,
+		Title:     b.Title,
+		Body:      b.Description,
+		State:     "open",
+		HTMLURL:   b.Links.HTML.Href,
+		Draft:     b.Draft,
+		UpdatedAt: b.UpdatedOn,
+	}
+	switch b.State {
+	case "MERGED":
+		pr.State, pr.Merged = "closed", true
+	case "DECLINED", "SUPERSEDED":
+		pr.State = "closed"
+	}
+	pr.User.Login = b.Author.Nickname
+	pr.Head.Ref = b.Source.Branch.Name
+	pr.Base.Ref = b.Destination.Branch.Name
+	return pr
+}
+
+// NewBitbucketClient returns a client for the Bitbucket Cloud repository
+// origin points at, panicking like gh.NewClient when the repository or
+// credentials can't be found
+func NewBitbucketClient() Provider {
+	remote, err := originURL()
+	if err != nil {
+		panic("Could not determine Bitbucket repository. Please ensure the repository has an origin remote")
+	}
+	_, repo, ok := parseRemote(remote)
+	if !ok || strings.Count(repo, "/") != 1 {
+		panic(fmt.Sprintf("Could not determine Bitbucket repository from origin URL %q", remote))
+	}
+
+	username, secret := bitbucketCredentials()
+	if secret == "" {
+		panic(`Bitbucket credentials not found. Please either:
+1. Set BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD environment variables
+2. Set BITBUCKET_TOKEN to a repository, workspace or OAuth access token
+3. Run 'sage config set bitbucket.username YOU' and 'sage config set bitbucket.app_password PASSWORD'
+	 `)
+	}
+
+	return &bitbucketClient{
+		client:   &http.Client{},
+		apiURL:   bitbucketAPI,
+		repo:     repo,
+		username: username,
+		secret:   secret,
+	}
+}
+
+// bitbucketCredentials returns an app password with its username, or an
+// access token with no username. Environment variables win over settings.
+func bitbucketCredentials() (username, secret string) {
+	if token := os.Getenv("BITBUCKET_TOKEN"); token != "" {
+		return "", token
+	}
+	username, secret = os.Getenv("BITBUCKET_USERNAME"), os.Getenv("BITBUCKET_APP_PASSWORD")
+	if username != "" && secret != "" {
+		return username, secret
+	}
+	if token := config.Get("bitbucket.token", true); token != "" {
+		return "", token
+	}
+	username, secret = config.Get("bitbucket.username", true), config.Get("bitbucket.app_password", true)
+	if username == "" || secret == "" {
+		return "", ""
+	}
+	return username, secret
+}
+
+// url returns the API URL for a path under the repository
+func (b *bitbucketClient) url(format string, args ...any) string {
+	return fmt.Sprintf("%s/repositories/%s", b.apiURL, b.repo) + fmt.Sprintf(format, args...)
+}
+
+func (b *bitbucketClient) do(method, u string, body any) ([]byte, error) {
+	var buf io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.NewReader(data)
+	}
+
+	// In dry-run mode only reads reach Bitbucket; writes are printed instead
+	if dryrun.Enabled() && method != "GET" {
+		if body != nil {
+			data, _ := json.Marshal(body)
+			dryrun.Record("Bitbucket API %s %s %s", method, u, data)
+		} else {
+			dryrun.Record("Bitbucket API %s %s", method, u)
+		}
+		return []byte("{}"), nil
+	}
+
+	req, err := http.NewRequest(method, u, buf)
+	if err != nil {
+		return nil, err
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.secret)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+b.secret)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, exitcode.New(gh.StatusExitCode(resp.StatusCode), fmt.Errorf("Bitbucket API %s %s returned %d:\n%s",
+			method, u, resp.StatusCode, string(msg)))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// getPR fetches pull request id
+func (b *bitbucketClient) getPR(id int) (*bitbucketPR, error) {
+	data, err := b.do("GET", b.url("/pullrequests/%d", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	var pr bitbucketPR
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// listPRs fetches every pull request matching query, following pagination
+func (b *bitbucketClient) listPRs(query url.Values) ([]bitbucketPR, error) {
+	query.Set("pagelen", "50")
+	next := b.url("/pullrequests?%s", query.Encode())
+	var prs []bitbucketPR
+	for page := 0; next != "" && page < 10; page++ {
+		data, err := b.do("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Values []bitbucketPR `json:"values"`
+			Next   string        `json:"next"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, err
+		}
+		prs = append(prs, resp.Values...)
+		next = resp.Next
+	}
+	return prs, nil
+}
+
+func (b *bitbucketClient) CreatePR(title, body, head, base string, draft bool) (*gh.PullRequest, error) {
+	payload := map[string]any{
+		"title":               title,
+		"description":         body,
+		"source":              map[string]any{"branch": map[string]string{"name": head}},
+		"destination":         map[string]any{"branch": map[string]string{"name": base}},
+		"close_source_branch": true,
+	}
+	if draft {
+		payload["draft"] = true
+	}
+	data, err := b.do("POST", b.url("/pullrequests"), payload)
+	if err != nil {
+		return nil, err
+	}
+	var created bitbucketPR
+	if err := json.Unmarshal(data, &created); err != nil {
+		return nil, err
+	}
+	pr := created.pullRequest()
+	return &pr, nil
+}
+
+// ListPRs lists pull requests in state open, closed (merged, declined or
+// superseded) or all
+func (b *bitbucketClient) ListPRs(state string) ([]gh.PullRequest, error) {
+	var states []string
+	switch state {
+	case "open", "":
+		states = []string{"OPEN"}
+	case "closed":
+		states = []string{"MERGED", "DECLINED", "SUPERSEDED"}
+	default:
+		states = []string{"OPEN", "MERGED", "DECLINED", "SUPERSEDED"}
+	}
+	found, err := b.listPRs(url.Values{"state": states})
+	if err != nil {
+		return nil, err
+	}
+	prs := make([]gh.PullRequest, 0, len(found))
+	for _, pr := range found {
+		prs = append(prs, pr.pullRequest())
+	}
+	return prs, nil
+}
+
+func (b *bitbucketClient) MergePR(num int, method string) error {
+	return b.MergePRWithMessage(num, method, "", "")
+}
+
+// MergePRWithMessage merges with a merge commit, a squash, or by rebasing
+// and fast-forwarding
+func (b *bitbucketClient) MergePRWithMessage(num int, method, title, message string) error {
+	strategies := map[string]string{
+		"merge":  "merge_commit",
+		"squash": "squash",
+		"rebase": "rebase_fast_forward",
+	}
+	strategy, ok := strategies[method]
+	if !ok {
+		return fmt.Errorf("invalid merge method: %s", method)
+	}
+	payload := map[string]any{"merge_strategy": strategy}
+	if commit := strings.TrimSpace(strings.TrimSpace(title) + "\n\n" + message); commit != "" {
+		payload["message"] = commit
+	}
+	_, err := b.do("POST", b.url("/pullrequests/%d/merge", num), payload)
+	return err
+}
+
+// ClosePR declines the pull request, Bitbucket's way of closing it
+func (b *bitbucketClient) ClosePR(num int) error {
+	_, err := b.do("POST", b.url("/pullrequests/%d/decline", num), nil)
+	return err
+}
+
+// ReopenPR fails: Bitbucket Cloud can't reopen a declined pull request, so
+// a new one has to be created instead
+func (b *bitbucketClient) ReopenPR(num int) error {
+	return fmt.Errorf("Bitbucket can't reopen declined pull requests; create a new one with 'sage pr create': %w", ErrUnsupported)
+}
+
+// ClosedPRsForBranch returns the branch's declined pull requests, most
+// recently updated first
+func (b *bitbucketClient) ClosedPRsForBranch(branch string) ([]gh.PullRequest, error) {
+	q := fmt.Sprintf(`source.branch.name="%s" AND state="DECLINED"`, strings.ReplaceAll(branch, `"`, `\"`))
+	found, err := b.listPRs(url.Values{"q": {q}, "state": {"DECLINED"}, "sort": {"-updated_on"}})
+	if err != nil {
+		return nil, err
+	}
+	prs := make([]gh.PullRequest, 0, len(found))
+	for _, pr := range found {
+		prs = append(prs, pr.pullRequest())
+	}
+	return prs, nil
+}
+
+// CurrentUser returns the nickname of the account the credentials belong to
+func (b *bitbucketClient) CurrentUser() (string, error) {
+	data, err := b.do("GET", b.apiURL+"/user", nil)
+	if err != nil {
+		return "", err
+	}
+	var user struct {
+		Nickname string `json:"nickname"`
+	}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return "", err
+	}
+	return user.Nickname, nil
+}
+
+func (b *bitbucketClient) ApprovePR(num int) error {
+	_, err := b.do("POST", b.url("/pullrequests/%d/approve", num), nil)
+	return err
+}
+
+func (b *bitbucketClient) GetPRDetails(num int) (*gh.PullRequest, error) {
+	found, err := b.getPR(num)
+	if err != nil {
+		return nil, err
+	}
+	pr := found.pullRequest()
+	return &pr, nil
+}
+
+// CheckoutPR checks out a pull request's source branch, fetching it from the
+// fork it comes from when that isn't this repository
+func (b *bitbucketClient) CheckoutPR(num int) (string, error) {
+	pr, err := b.getPR(num)
+	if err != nil {
+		return "", err
+	}
+	branch := pr.Source.Branch.Name
+
+	if fork := pr.Source.Repository.FullName; fork != "" && !strings.EqualFold(fork, b.repo) {
+		if out, err := runCmd("git", "fetch", "https://bitbucket.org/"+fork+".git", branch); err != nil {
+			return "", fmt.Errorf("fetch error: %s\n%s", err, out)
+		}
+		if out, err := runCmd("git", "switch", "-C", branch, "FETCH_HEAD"); err != nil {
+			return "", fmt.Errorf("switch error: %s\n%s", err, out)
+		}
+		return branch, nil
+	}
+	return branch, checkoutOriginBranch(branch)
+}
+
+func (b *bitbucketClient) ListPRUnresolvedThreads(prNum int) ([]gh.UnresolvedThread, error) {
+	return nil, ErrUnsupported
+}
+
+// GetPRTemplate returns nothing: Bitbucket Cloud keeps default descriptions
+// in repository settings, which the API doesn't expose
+func (b *bitbucketClient) GetPRTemplate() (string, error) {
+	return "", nil
+}
+
+func (b *bitbucketClient) AddLabels(prNumber int, labels []string) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) RequestReviewers(prNumber int, reviewers []string) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) GetPRForBranch(branchName string) (*gh.PullRequest, error) {
+	q := fmt.Sprintf(`source.branch.name="%s" AND state="OPEN"`, strings.ReplaceAll(branchName, `"`, `\"`))
+	found, err := b.listPRs(url.Values{"q": {q}})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	pr := found[0].pullRequest()
+	return &pr, nil
+}
+
+func (b *bitbucketClient) GetLatestRelease() (string, error) {
+	return "", ErrUnsupported
+}
+
+// UpdatePR updates the title, description and destination branch
+func (b *bitbucketClient) UpdatePR(num int, pr *gh.PullRequest) error {
+	payload := map[string]any{"title": pr.Title, "description": pr.Body}
+	if pr.Base.Ref != "" {
+		payload["destination"] = map[string]any{"branch": map[string]string{"name": pr.Base.Ref}}
+	}
+	_, err := b.do("PUT", b.url("/pullrequests/%d", num), payload)
+	return err
+}
+
+func (b *bitbucketClient) ListPRFiles(num int) ([]gh.PRFile, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) GetBranchProtection(branch string) (*gh.BranchProtection, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) UpdateBranchProtection(branch string, bp *gh.BranchProtection) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) ListPRCheckRuns(num int) ([]gh.CheckRun, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) GetJobLog(jobID int64) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (b *bitbucketClient) CreateIssue(title, body string, labels []string) (*gh.Issue, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) CommitAuthorLogin(sha string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (b *bitbucketClient) IsCollaborator(login string) (bool, error) {
+	return false, ErrUnsupported
+}
+
+func (b *bitbucketClient) SearchPRs(query string, page, perPage int) (*gh.PRSearchResult, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) GetCommit(sha string) (*gh.CommitInfo, error) {
+	return nil, ErrUnsupported
+}
+
+// OpenPRsByHead returns the open pull requests keyed by source branch
+func (b *bitbucketClient) OpenPRsByHead() (map[string]gh.PullRequest, error) {
+	found, err := b.listPRs(url.Values{"state": {"OPEN"}})
+	if err != nil {
+		return nil, err
+	}
+	byHead := make(map[string]gh.PullRequest, len(found))
+	for _, pr := range found {
+		byHead[pr.Source.Branch.Name] = pr.pullRequest()
+	}
+	return byHead, nil
+}
+
+// ListPRCommits returns a pull request's commits, oldest first
+func (b *bitbucketClient) ListPRCommits(num int) ([]gh.CommitInfo, error) {
+	data, err := b.do("GET", b.url("/pullrequests/%d/commits?pagelen=100", num), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Values []struct {
+			Hash    string    `json:"hash"`
+			Message string    `json:"message"`
+			Date    time.Time `json:"date"`
+			Author  struct {
+				Raw  string `json:"raw"`
+				User struct {
+					Nickname string `json:"nickname"`
+				} `json:"user"`
+			} `json:"author"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	// Bitbucket lists the newest commit first
+	out := make([]gh.CommitInfo, 0, len(resp.Values))
+	for i := len(resp.Values) - 1; i >= 0; i-- {
+		c := resp.Values[i]
+		subject, body, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		name, _, _ := strings.Cut(c.Author.Raw, " <")
+		out = append(out, gh.CommitInfo{
+			SHA:         c.Hash,
+			Subject:     subject,
+			Body:        strings.TrimSpace(body),
+			AuthorName:  name,
+			AuthorLogin: c.Author.User.Nickname,
+			Date:        c.Date,
+		})
+	}
+	return out, nil
+}
+
+func (b *bitbucketClient) GetReleaseByTag(tag string) (*gh.Release, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) CreateRelease(tag, name, body string, prerelease bool) (*gh.Release, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) DeleteReleaseAsset(id int64) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) UploadReleaseAsset(release *gh.Release, name, contentType string, body io.Reader, size int64) (*gh.ReleaseAsset, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) PRsForCommit(sha string) ([]gh.PullRequest, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) ListReviewThreads(num int) ([]gh.ReviewThread, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) ReplyToReviewComment(num int, commentID int64, body string) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) ResolveReviewThread(threadID string) error {
+	return ErrUnsupported
+}
+
+// SubmitReview approves pull requests, the only verdict sage sends to
+// Bitbucket
+func (b *bitbucketClient) SubmitReview(num int, event, body string) error {
+	if event != gh.ReviewEventApprove {
+		return ErrUnsupported
+	}
+	return b.ApprovePR(num)
+}
+
+func (b *bitbucketClient) ListPRReviews(num int) ([]gh.Review, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) GetMergeOptions(num int) (*gh.MergeOptions, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *bitbucketClient) EnableAutoMerge(num int, method, title, message string) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) EnqueuePR(num int) (int, error) {
+	return 0, ErrUnsupported
+}
+
+func (b *bitbucketClient) SetDraft(num int, draft bool) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) RemoveLabel(prNumber int, label string) error {
+	return ErrUnsupported
+}
+
+func (b *bitbucketClient) RemoveReviewers(prNumber int, reviewers []string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,98 @@
+package forge
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBitbucket(m *mockTransport) *bitbucketClient {
+	return &bitbucketClient{
+		client:   &http.Client{Transport: m},
+		apiURL:   "https://api.bitbucket.org/2.0",
+		repo:     "team/app",
+		username: "dev",
+		secret:   "app-password",
+	}
+}
+
+const repoPath = "/2.0/repositories/team/app"
+
+func TestBitbucketCreatePR(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"POST " + repoPath + "/pullrequests": `{"id": 12, "title": "Add thing", "state": "OPEN",
+			"author": {"nickname": "dev"},
+			"source": {"branch": {"name": "feature"}}, "destination": {"branch": {"name": "main"}},
+			"links": {"html": {"href": "https://bitbucket.org/team/app/pull-requests/12"}}}`,
+	}}
+	pr, err := newTestBitbucket(m).CreatePR("Add thing", "body", "feature", "main", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, pr.Number)
+	assert.Equal(t, "open", pr.State)
+	assert.Equal(t, "feature", pr.Head.Ref)
+	assert.Equal(t, "main", pr.Base.Ref)
+	assert.Equal(t, "https://bitbucket.org/team/app/pull-requests/12", pr.HTMLURL)
+	assert.Contains(t, m.bodies["POST "+repoPath+"/pullrequests"], `"destination":{"branch":{"name":"main"}}`)
+}
+
+func TestBitbucketListPRs(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + repoPath + "/pullrequests?pagelen=50&state=MERGED&state=DECLINED&state=SUPERSEDED": `{
+			"values": [{"id": 1, "state": "MERGED"}],
+			"next": "https://api.bitbucket.org/2.0/repositories/team/app/pullrequests?page=2"}`,
+		"GET " + repoPath + "/pullrequests?page=2": `{"values": [{"id": 2, "state": "DECLINED"}]}`,
+	}}
+	prs, err := newTestBitbucket(m).ListPRs("closed")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.True(t, prs[0].Merged)
+	assert.Equal(t, "closed", prs[1].State)
+	assert.False(t, prs[1].Merged)
+}
+
+func TestBitbucketMergeDeclineApprove(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"POST " + repoPath + "/pullrequests/3/merge":   `{}`,
+		"POST " + repoPath + "/pullrequests/3/decline": `{}`,
+		"POST " + repoPath + "/pullrequests/3/approve": `{}`,
+	}}
+	c := newTestBitbucket(m)
+
+	require.NoError(t, c.MergePRWithMessage(3, "squash", "feat: thing (#3)", ""))
+	assert.Equal(t, `{"merge_strategy":"squash","message":"feat: thing (#3)"}`, m.bodies["POST "+repoPath+"/pullrequests/3/merge"])
+	require.NoError(t, c.MergePR(3, "rebase"))
+	assert.Contains(t, m.bodies["POST "+repoPath+"/pullrequests/3/merge"], `"rebase_fast_forward"`)
+	assert.Error(t, c.MergePR(3, "octopus"))
+
+	require.NoError(t, c.ClosePR(3))
+	require.NoError(t, c.ApprovePR(3))
+}
+
+func TestBitbucketGetPRForBranch(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + repoPath + "/pullrequests?pagelen=50&q=source.branch.name%3D%22feature%22+AND+state%3D%22OPEN%22": `{
+			"values": [{"id": 9, "state": "OPEN", "source": {"branch": {"name": "feature"}}}]}`,
+	}}
+	pr, err := newTestBitbucket(m).GetPRForBranch("feature")
+	require.NoError(t, err)
+	require.NotNil(t, pr)
+	assert.Equal(t, 9, pr.Number)
+}
+
+func TestBitbucketListPRCommits(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + repoPath + "/pullrequests/4/commits?pagelen=100": `{"values": [
+			{"hash": "bbb", "message": "second\n\nMore detail\n", "author": {"raw": "Dev <dev@example.com>", "user": {"nickname": "dev"}}},
+			{"hash": "aaa", "message": "first\n", "author": {"raw": "Dev <dev@example.com>"}}]}`,
+	}}
+	commits, err := newTestBitbucket(m).ListPRCommits(4)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "first", commits[0].Subject)
+	assert.Equal(t, "More detail", commits[1].Body)
+	assert.Equal(t, "Dev", commits[1].AuthorName)
+	assert.Equal(t, "dev", commits[1].AuthorLogin)
+}
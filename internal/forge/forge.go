@@ -0,0 +1,147 @@
+// Package forge picks the code host sage manages pull requests on. GitHub is
+// the default; GitLab merge requests and Bitbucket Cloud pull requests are
+// supported through the same client interface, selected with the forge.type
+// setting or detected from origin.
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/gh"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
+)
+
+// Forge types accepted by the forge.type setting
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Bitbucket = "bitbucket"
+)
+
+// Provider is the pull request API a forge offers. GitHub's client defines
+// it; other forges return ErrUnsupported for what they can't do.
+type Provider = gh.Client
+
+// ErrUnsupported is returned for operations the current forge doesn't offer
+var ErrUnsupported = errors.New("not supported on this forge")
+
+// Type returns the configured forge. Without one it goes by origin's host:
+// Bitbucket for bitbucket.org, GitLab for hosts with "gitlab" in their name,
+// and GitHub otherwise.
+func Type() string {
+	if t := strings.ToLower(strings.TrimSpace(config.Get("forge.type", true))); t != "" {
+		return t
+	}
+	if url, err := originURL(); err == nil {
+		if host, _, ok := parseRemote(url); ok {
+			host = strings.ToLower(host)
+			switch {
+			case host == "bitbucket.org":
+				return Bitbucket
+			case strings.Contains(host, GitLab):
+				return GitLab
+			}
+		}
+	}
+	return GitHub
+}
+
+// NewClient returns the client for the configured forge. Like gh.NewClient,
+// it panics when the repository or a token can't be found.
+func NewClient() Provider {
+	switch t := Type(); t {
+	case GitHub:
+		return gh.NewClient()
+	case GitLab:
+		return NewGitLabClient()
+	case Bitbucket:
+		return NewBitbucketClient()
+	default:
+		panic(fmt.Sprintf("Unknown forge.type %q. Use %q, %q or %q", t, GitHub, GitLab, Bitbucket))
+	}
+}
+
+// originURL returns the URL of the origin remote
+func originURL() (string, error) {
+	cmd, err := git.SetupSecureCommand("git", "remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseRemote splits a remote URL into its host and project path. Unlike
+// repoinfo.ParseRemoteURL it keeps nested paths such as group/subgroup/project.
+func parseRemote(url string) (host, path string, ok bool) {
+	if info, ok := repoinfo.ParseRemoteURL(url); ok {
+		return info.Host, info.FullName(), true
+	}
+
+	url = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(url), "/"), ".git")
+	switch {
+	case strings.Contains(url, "://"):
+		rest := url[strings.Index(url, "://")+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return "", "", false
+		}
+		host, path = rest[:slash], rest[slash+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		if colon := strings.Index(host, ":"); colon >= 0 {
+			host = host[:colon]
+		}
+	case strings.Contains(url, ":"):
+		colon := strings.Index(url, ":")
+		host, path = url[:colon], url[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	default:
+		return "", "", false
+	}
+
+	path = strings.Trim(path, "/")
+	if host == "" || !strings.Contains(path, "/") || strings.Contains(path, "//") {
+		return "", "", false
+	}
+	return host, path, true
+}
+
+// checkoutOriginBranch fetches branch from origin and switches to it,
+// tracking origin's copy, resetting a local branch of that name to it
+func checkoutOriginBranch(branch string) error {
+	if out, err := runCmd("git", "fetch", "origin", branch); err != nil {
+		return fmt.Errorf("fetch error: %s\n%s", err, out)
+	}
+	if out, err := runCmd("git", "switch", "-c", branch, "--track", "origin/"+branch); err != nil {
+		if !strings.Contains(out, "already exists") {
+			return fmt.Errorf("switch error: %s\n%s", err, out)
+		}
+		if out, err := runCmd("git", "switch", branch); err != nil {
+			return fmt.Errorf("switch error: %s\n%s", err, out)
+		}
+		if out, err := runCmd("git", "reset", "--hard", "origin/"+branch); err != nil {
+			return fmt.Errorf("reset error: %s\n%s", err, out)
+		}
+	}
+	return nil
+}
+
+func runCmd(prog string, args ...string) (string, error) {
+	cmd, err := git.SetupSecureCommand(prog, args...)
+	if err != nil {
+		return "", err
+	}
+	b, err := cmd.CombinedOutput()
+	return string(b), err
+}
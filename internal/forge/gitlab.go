@@ -0,0 +1,722 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// gitLabAPI talks to the GitLab REST API (v4) for one project
+type gitLabAPI struct {
+	token   string
+	client  *http.Client
+	apiURL  string // e.g. https://gitlab.com/api/v4
+	project string // full path, e.g. group/subgroup/project
+}
+
+// mergeRequest is a GitLab merge request as the API returns it
+type mergeRequest struct {
+	IID             int       `json:"iid"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	State           string    `json:"state"` // opened, closed, locked or merged
+	WebURL          string    `json:"web_url"`
+	Draft           bool      `json:"draft"`
+	SourceBranch    string    `json:"source_branch"`
+	TargetBranch    string    `json:"target_branch"`
+	SourceProjectID int       `json:"source_project_id"`
+	TargetProjectID int       `json:"target_project_id"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Author          struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Reviewers []struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"reviewers"`
+}
+
+// pullRequest converts a merge request into the PR type the rest of sage uses
+func (mr mergeRequest) pullRequest() gh.PullRequest {
+	pr := gh.PullRequest{
+		Number:    mr.IID,
+		Title:     mr.Title,
+		Body:      mr.Description,
+		State:     "open",
+		HTMLURL:   mr.WebURL,
+		Draft:     mr.Draft,
+		UpdatedAt: mr.UpdatedAt,
+	}
+	switch mr.State {
+	case "merged":
+		pr.State, pr.Merged = "closed", true
+	case "closed", "locked":
+		pr.State = "closed"
+	}
+	pr.User.Login = mr.Author.Username
+	pr.Head.Ref = mr.SourceBranch
+	pr.Base.Ref = mr.TargetBranch
+	return pr
+}
+
+// NewGitLabClient returns a client for the GitLab project origin points at,
+// panicking like gh.NewClient when the project or a token can't be found
+func NewGitLabClient() Provider {
+	remote, err := originURL()
+	if err != nil {
+		panic("Could not determine GitLab project. Please ensure the repository has an origin remote")
+	}
+	host, project, ok := parseRemote(remote)
+	if !ok {
+		panic(fmt.Sprintf("Could not determine GitLab project from origin URL %q", remote))
+	}
+
+	token := gitLabToken()
+	if token == "" {
+		panic(`GitLab token not found. Please either:
+1. Set SAGE_GITLAB_TOKEN environment variable
+2. Set GITLAB_TOKEN environment variable
+3. Run 'sage config set gitlab.token YOUR_TOKEN'
+	 `)
+	}
+
+	return &gitLabAPI{
+		token:   token,
+		client:  &http.Client{},
+		apiURL:  "https://" + host + "/api/v4",
+		project: project,
+	}
+}
+
+// gitLabToken checks SAGE_GITLAB_TOKEN, GITLAB_TOKEN, then the gitlab.token setting
+func gitLabToken() string {
+	for _, env := range []string{"SAGE_GITLAB_TOKEN", "GITLAB_TOKEN"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return config.Get("gitlab.token", true)
+}
+
+// url returns the API URL for a path under the project
+func (g *gitLabAPI) url(format string, args ...any) string {
+	return fmt.Sprintf("%s/projects/%s", g.apiURL, url.PathEscape(g.project)) + fmt.Sprintf(format, args...)
+}
+
+func (g *gitLabAPI) do(method, u string, body any) ([]byte, error) {
+	var buf io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.NewReader(b)
+	}
+
+	// In dry-run mode only reads reach GitLab; writes are printed instead
+	if dryrun.Enabled() && method != "GET" {
+		if body != nil {
+			b, _ := json.Marshal(body)
+			dryrun.Record("GitLab API %s %s %s", method, u, b)
+		} else {
+			dryrun.Record("GitLab API %s %s", method, u)
+		}
+		return []byte("{}"), nil
+	}
+
+	req, err := http.NewRequest(method, u, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, exitcode.New(gh.StatusExitCode(resp.StatusCode), fmt.Errorf("GitLab API %s %s returned %d:\n%s",
+			method, u, resp.StatusCode, string(msg)))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// getMR fetches merge request iid
+func (g *gitLabAPI) getMR(iid int) (*mergeRequest, error) {
+	data, err := g.do("GET", g.url("/merge_requests/%d", iid), nil)
+	if err != nil {
+		return nil, err
+	}
+	var mr mergeRequest
+	if err := json.Unmarshal(data, &mr); err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+// listMRs fetches the merge requests matching query
+func (g *gitLabAPI) listMRs(query url.Values) ([]mergeRequest, error) {
+	data, err := g.do("GET", g.url("/merge_requests?%s", query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	var mrs []mergeRequest
+	if err := json.Unmarshal(data, &mrs); err != nil {
+		return nil, err
+	}
+	return mrs, nil
+}
+
+func (g *gitLabAPI) CreatePR(title, body, head, base string, draft bool) (*gh.PullRequest, error) {
+	if draft && !strings.HasPrefix(strings.ToLower(title), "draft:") {
+		title = "Draft: " + title
+	}
+	data, err := g.do("POST", g.url("/merge_requests"), map[string]any{
+		"title":                title,
+		"description":          body,
+		"source_branch":        head,
+		"target_branch":        base,
+		"remove_source_branch": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var mr mergeRequest
+	if err := json.Unmarshal(data, &mr); err != nil {
+		return nil, err
+	}
+	pr := mr.pullRequest()
+	return &pr, nil
+}
+
+// ListPRs lists merge requests in state open, closed (including merged) or all
+func (g *gitLabAPI) ListPRs(state string) ([]gh.PullRequest, error) {
+	query := url.Values{"per_page": {"100"}}
+	switch state {
+	case "open", "":
+		query.Set("state", "opened")
+	case "all":
+		query.Set("state", "all")
+	}
+	mrs, err := g.listMRs(query)
+	if err != nil {
+		return nil, err
+	}
+	prs := make([]gh.PullRequest, 0, len(mrs))
+	for _, mr := range mrs {
+		pr := mr.pullRequest()
+		if state == "closed" && pr.State != "closed" {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+func (g *gitLabAPI) MergePR(num int, method string) error {
+	return g.MergePRWithMessage(num, method, "", "")
+}
+
+// MergePRWithMessage merges with a merge commit or squashes. GitLab sets
+// fast-forward and rebase merges per project, so "rebase" isn't offered here.
+func (g *gitLabAPI) MergePRWithMessage(num int, method, title, message string) error {
+	payload := map[string]any{}
+	commit := strings.TrimSpace(strings.TrimSpace(title) + "\n\n" + message)
+	switch method {
+	case "merge":
+		if commit != "" {
+			payload["merge_commit_message"] = commit
+		}
+	case "squash":
+		payload["squash"] = true
+		if commit != "" {
+			payload["squash_commit_message"] = commit
+		}
+	case "rebase":
+		return fmt.Errorf("rebase merges are configured per project on GitLab. Use 'merge' or 'squash' instead")
+	default:
+		return fmt.Errorf("invalid merge method: %s", method)
+	}
+	_, err := g.do("PUT", g.url("/merge_requests/%d/merge", num), payload)
+	return err
+}
+
+func (g *gitLabAPI) ClosePR(num int) error {
+	_, err := g.do("PUT", g.url("/merge_requests/%d", num), map[string]string{"state_event": "close"})
+	return err
+}
+
+func (g *gitLabAPI) ReopenPR(num int) error {
+	_, err := g.do("PUT", g.url("/merge_requests/%d", num), map[string]string{"state_event": "reopen"})
+	return err
+}
+
+// ClosedPRsForBranch returns the branch's closed, unmerged merge requests,
+// most recently updated first
+func (g *gitLabAPI) ClosedPRsForBranch(branch string) ([]gh.PullRequest, error) {
+	mrs, err := g.listMRs(url.Values{"state": {"closed"}, "source_branch": {branch}, "order_by": {"updated_at"}})
+	if err != nil {
+		return nil, err
+	}
+	prs := make([]gh.PullRequest, 0, len(mrs))
+	for _, mr := range mrs {
+		prs = append(prs, mr.pullRequest())
+	}
+	return prs, nil
+}
+
+// CurrentUser returns the username the token belongs to
+func (g *gitLabAPI) CurrentUser() (string, error) {
+	data, err := g.do("GET", g.apiURL+"/user", nil)
+	if err != nil {
+		return "", err
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+func (g *gitLabAPI) ApprovePR(num int) error {
+	_, err := g.do("POST", g.url("/merge_requests/%d/approve", num), nil)
+	return err
+}
+
+func (g *gitLabAPI) GetPRDetails(num int) (*gh.PullRequest, error) {
+	mr, err := g.getMR(num)
+	if err != nil {
+		return nil, err
+	}
+	pr := mr.pullRequest()
+	return &pr, nil
+}
+
+// CheckoutPR checks out a merge request's source branch. Merge requests from
+// forks are fetched through the target project's merge-requests/N/head ref.
+func (g *gitLabAPI) CheckoutPR(num int) (string, error) {
+	mr, err := g.getMR(num)
+	if err != nil {
+		return "", err
+	}
+	branch := mr.SourceBranch
+
+	if mr.SourceProjectID != 0 && mr.SourceProjectID != mr.TargetProjectID {
+		if out, err := runCmd("git", "fetch", "origin", fmt.Sprintf("merge-requests/%d/head", num)); err != nil {
+			return "", fmt.Errorf("fetch error: %s\n%s", err, out)
+		}
+		if out, err := runCmd("git", "switch", "-C", branch, "FETCH_HEAD"); err != nil {
+			return "", fmt.Errorf("switch error: %s\n%s", err, out)
+		}
+		return branch, nil
+	}
+
+	return branch, checkoutOriginBranch(branch)
+}
+
+// ListPRUnresolvedThreads returns the merge request's unresolved discussions
+func (g *gitLabAPI) ListPRUnresolvedThreads(prNum int) ([]gh.UnresolvedThread, error) {
+	data, err := g.do("GET", g.url("/merge_requests/%d/discussions?per_page=100", prNum), nil)
+	if err != nil {
+		return nil, err
+	}
+	var discussions []struct {
+		Notes []struct {
+			Body   string `json:"body"`
+			System bool   `json:"system"`
+			Author struct {
+				Username string `json:"username"`
+			} `json:"author"`
+			CreatedAt  time.Time `json:"created_at"`
+			Resolvable bool      `json:"resolvable"`
+			Resolved   bool      `json:"resolved"`
+			Position   *struct {
+				NewPath string `json:"new_path"`
+				OldPath string `json:"old_path"`
+				NewLine int    `json:"new_line"`
+				OldLine int    `json:"old_line"`
+			} `json:"position"`
+		} `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &discussions); err != nil {
+		return nil, err
+	}
+
+	var threads []gh.UnresolvedThread
+	for _, d := range discussions {
+		if len(d.Notes) == 0 || d.Notes[0].System || !d.Notes[0].Resolvable || d.Notes[0].Resolved {
+			continue
+		}
+		var t gh.UnresolvedThread
+		if pos := d.Notes[0].Position; pos != nil {
+			t.Path, t.Line = pos.NewPath, pos.NewLine
+			if t.Line == 0 {
+				t.Path, t.Line = pos.OldPath, pos.OldLine
+			}
+		}
+		for _, n := range d.Notes {
+			t.Comments = append(t.Comments, gh.Comment{User: n.Author.Username, Body: n.Body, Time: n.CreatedAt})
+		}
+		threads = append(threads, t)
+	}
+	return threads, nil
+}
+
+// GetPRTemplate returns the project's "Default" merge request template, or
+// its first one when there is no default
+func (g *gitLabAPI) GetPRTemplate() (string, error) {
+	data, err := g.do("GET", g.url("/templates/merge_requests"), nil)
+	if err != nil {
+		return "", nil
+	}
+	var templates []struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &templates); err != nil || len(templates) == 0 {
+		return "", nil
+	}
+
+	key := templates[0].Key
+	for _, t := range templates {
+		if strings.EqualFold(t.Name, "default") {
+			key = t.Key
+			break
+		}
+	}
+	data, err = g.do("GET", g.url("/templates/merge_requests/%s", url.PathEscape(key)), nil)
+	if err != nil {
+		return "", nil
+	}
+	var template struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &template); err != nil {
+		return "", nil
+	}
+	return template.Content, nil
+}
+
+func (g *gitLabAPI) AddLabels(prNumber int, labels []string) error {
+	_, err := g.do("PUT", g.url("/merge_requests/%d", prNumber), map[string]string{
+		"add_labels": strings.Join(labels, ","),
+	})
+	return err
+}
+
+// RequestReviewers adds users as reviewers. GitLab can't request a review
+// from a group, so group/team entries are an error.
+func (g *gitLabAPI) RequestReviewers(prNumber int, reviewers []string) error {
+	mr, err := g.getMR(prNumber)
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for _, r := range mr.Reviewers {
+		ids = append(ids, r.ID)
+	}
+	for _, r := range reviewers {
+		r = strings.TrimPrefix(r, "@")
+		if strings.Contains(r, "/") {
+			return fmt.Errorf("can't request a review from %s: GitLab only accepts users as reviewers", r)
+		}
+		data, err := g.do("GET", fmt.Sprintf("%s/users?username=%s", g.apiURL, url.QueryEscape(r)), nil)
+		if err != nil {
+			return err
+		}
+		var users []struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(data, &users); err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return fmt.Errorf("GitLab user %s not found", r)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	_, err = g.do("PUT", g.url("/merge_requests/%d", prNumber), map[string][]int{"reviewer_ids": ids})
+	return err
+}
+
+func (g *gitLabAPI) GetPRForBranch(branchName string) (*gh.PullRequest, error) {
+	mrs, err := g.listMRs(url.Values{"state": {"opened"}, "source_branch": {branchName}})
+	if err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	pr := mrs[0].pullRequest()
+	return &pr, nil
+}
+
+func (g *gitLabAPI) GetLatestRelease() (string, error) {
+	return "", ErrUnsupported
+}
+
+// UpdatePR updates the title, description, draft state and target branch
+func (g *gitLabAPI) UpdatePR(num int, pr *gh.PullRequest) error {
+	title := pr.Title
+	if lower := strings.ToLower(title); pr.Draft && !strings.HasPrefix(lower, "draft:") {
+		title = "Draft: " + title
+	} else if !pr.Draft && strings.HasPrefix(lower, "draft:") {
+		title = strings.TrimSpace(title[len("draft:"):])
+	}
+	payload := map[string]string{"title": title, "description": pr.Body}
+	if pr.Base.Ref != "" {
+		payload["target_branch"] = pr.Base.Ref
+	}
+	_, err := g.do("PUT", g.url("/merge_requests/%d", num), payload)
+	return err
+}
+
+// ListPRFiles returns the files a merge request changes with their diffs
+func (g *gitLabAPI) ListPRFiles(num int) ([]gh.PRFile, error) {
+	data, err := g.do("GET", g.url("/merge_requests/%d/diffs?per_page=100", num), nil)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []struct {
+		OldPath     string `json:"old_path"`
+		NewPath     string `json:"new_path"`
+		Diff        string `json:"diff"`
+		NewFile     bool   `json:"new_file"`
+		RenamedFile bool   `json:"renamed_file"`
+		DeletedFile bool   `json:"deleted_file"`
+	}
+	if err := json.Unmarshal(data, &diffs); err != nil {
+		return nil, err
+	}
+
+	files := make([]gh.PRFile, 0, len(diffs))
+	for _, d := range diffs {
+		f := gh.PRFile{Filename: d.NewPath, Status: "modified", Patch: strings.TrimSuffix(d.Diff, "\n")}
+		switch {
+		case d.NewFile:
+			f.Status = "added"
+		case d.DeletedFile:
+			f.Status = "removed"
+		case d.RenamedFile:
+			f.Status, f.PreviousFilename = "renamed", d.OldPath
+		}
+		for _, line := range strings.Split(d.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++"):
+				f.Additions++
+			case strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---"):
+				f.Deletions++
+			}
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (g *gitLabAPI) GetBranchProtection(branch string) (*gh.BranchProtection, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) UpdateBranchProtection(branch string, bp *gh.BranchProtection) error {
+	return ErrUnsupported
+}
+
+func (g *gitLabAPI) ListPRCheckRuns(num int) ([]gh.CheckRun, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) GetJobLog(jobID int64) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (g *gitLabAPI) CreateIssue(title, body string, labels []string) (*gh.Issue, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) CommitAuthorLogin(sha string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (g *gitLabAPI) IsCollaborator(login string) (bool, error) {
+	return false, ErrUnsupported
+}
+
+func (g *gitLabAPI) SearchPRs(query string, page, perPage int) (*gh.PRSearchResult, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) GetCommit(sha string) (*gh.CommitInfo, error) {
+	return nil, ErrUnsupported
+}
+
+// PRsForCommit returns the merge requests a commit is part of
+func (g *gitLabAPI) PRsForCommit(sha string) ([]gh.PullRequest, error) {
+	data, err := g.do("GET", g.url("/repository/commits/%s/merge_requests", url.PathEscape(sha)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var mrs []mergeRequest
+	if err := json.Unmarshal(data, &mrs); err != nil {
+		return nil, err
+	}
+	prs := make([]gh.PullRequest, 0, len(mrs))
+	for _, mr := range mrs {
+		prs = append(prs, mr.pullRequest())
+	}
+	return prs, nil
+}
+
+// OpenPRsByHead returns the open merge requests keyed by source branch
+func (g *gitLabAPI) OpenPRsByHead() (map[string]gh.PullRequest, error) {
+	mrs, err := g.listMRs(url.Values{"state": {"opened"}, "per_page": {"100"}})
+	if err != nil {
+		return nil, err
+	}
+	byHead := make(map[string]gh.PullRequest, len(mrs))
+	for _, mr := range mrs {
+		byHead[mr.SourceBranch] = mr.pullRequest()
+	}
+	return byHead, nil
+}
+
+// ListPRCommits returns a merge request's commits, oldest first
+func (g *gitLabAPI) ListPRCommits(num int) ([]gh.CommitInfo, error) {
+	data, err := g.do("GET", g.url("/merge_requests/%d/commits?per_page=100", num), nil)
+	if err != nil {
+		return nil, err
+	}
+	var commits []struct {
+		ID         string    `json:"id"`
+		Title      string    `json:"title"`
+		Message    string    `json:"message"`
+		AuthorName string    `json:"author_name"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+	if err := json.Unmarshal(data, &commits); err != nil {
+		return nil, err
+	}
+	// GitLab lists the newest commit first
+	out := make([]gh.CommitInfo, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		_, body, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		out = append(out, gh.CommitInfo{
+			SHA:        c.ID,
+			Subject:    c.Title,
+			Body:       strings.TrimSpace(body),
+			AuthorName: c.AuthorName,
+			Date:       c.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func (g *gitLabAPI) GetReleaseByTag(tag string) (*gh.Release, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) CreateRelease(tag, name, body string, prerelease bool) (*gh.Release, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) DeleteReleaseAsset(id int64) error {
+	return ErrUnsupported
+}
+
+func (g *gitLabAPI) UploadReleaseAsset(release *gh.Release, name, contentType string, body io.Reader, size int64) (*gh.ReleaseAsset, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) ListReviewThreads(num int) ([]gh.ReviewThread, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) ReplyToReviewComment(num int, commentID int64, body string) error {
+	return ErrUnsupported
+}
+
+func (g *gitLabAPI) ResolveReviewThread(threadID string) error {
+	return ErrUnsupported
+}
+
+// SubmitReview approves merge requests; GitLab has no review verdicts
+// beyond approval
+func (g *gitLabAPI) SubmitReview(num int, event, body string) error {
+	if event != gh.ReviewEventApprove {
+		return ErrUnsupported
+	}
+	return g.ApprovePR(num)
+}
+
+func (g *gitLabAPI) ListPRReviews(num int) ([]gh.Review, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) GetMergeOptions(num int) (*gh.MergeOptions, error) {
+	return nil, ErrUnsupported
+}
+
+func (g *gitLabAPI) EnableAutoMerge(num int, method, title, message string) error {
+	return ErrUnsupported
+}
+
+func (g *gitLabAPI) EnqueuePR(num int) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// SetDraft adds or drops the "Draft: " title prefix GitLab marks drafts by
+func (g *gitLabAPI) SetDraft(num int, draft bool) error {
+	mr, err := g.getMR(num)
+	if err != nil {
+		return err
+	}
+	pr := mr.pullRequest()
+	pr.Draft = draft
+	return g.UpdatePR(num, &pr)
+}
+
+func (g *gitLabAPI) RemoveLabel(prNumber int, label string) error {
+	_, err := g.do("PUT", g.url("/merge_requests/%d", prNumber), map[string]string{
+		"remove_labels": label,
+	})
+	return err
+}
+
+// RemoveReviewers drops users from the merge request's reviewers
+func (g *gitLabAPI) RemoveReviewers(prNumber int, reviewers []string) error {
+	mr, err := g.getMR(prNumber)
+	if err != nil {
+		return err
+	}
+	drop := map[string]bool{}
+	for _, r := range reviewers {
+		drop[strings.TrimPrefix(r, "@")] = true
+	}
+	ids := []int{}
+	for _, r := range mr.Reviewers {
+		if !drop[r.Username] {
+			ids = append(ids, r.ID)
+		}
+	}
+	_, err = g.do("PUT", g.url("/merge_requests/%d", prNumber), map[string][]int{"reviewer_ids": ids})
+	return err
+}
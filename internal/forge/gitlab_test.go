@@ -0,0 +1,220 @@
+package forge
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTransport answers requests keyed by "METHOD escaped-path?query" and
+// records the bodies it was sent
+type mockTransport struct {
+	responses map[string]string
+	bodies    map[string]string
+}
+
+func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := fmt.Sprintf("%s %s", req.Method, req.URL.EscapedPath())
+	if req.URL.RawQuery != "" {
+		key += "?" + req.URL.RawQuery
+	}
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		if m.bodies == nil {
+			m.bodies = map[string]string{}
+		}
+		m.bodies[key] = string(b)
+	}
+	if body, ok := m.responses[key]; ok {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	}
+	return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{"message":"404 Not Found"}`)), Header: make(http.Header)}, nil
+}
+
+func newTestGitLab(m *mockTransport) *gitLabAPI {
+	return &gitLabAPI{
+		token:   "token",
+		client:  &http.Client{Transport: m},
+		apiURL:  "https://gitlab.example.com/api/v4",
+		project: "group/sub/app",
+	}
+}
+
+const projectPath = "/api/v4/projects/group%2Fsub%2Fapp"
+
+func TestGitLabCreatePR(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"POST " + projectPath + "/merge_requests": `{"iid": 7, "title": "Draft: Add thing", "state": "opened",
+			"web_url": "https://gitlab.example.com/group/sub/app/-/merge_requests/7", "draft": true,
+			"source_branch": "feature", "target_branch": "main", "author": {"username": "dev"}}`,
+	}}
+	pr, err := newTestGitLab(m).CreatePR("Add thing", "body", "feature", "main", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, pr.Number)
+	assert.Equal(t, "open", pr.State)
+	assert.True(t, pr.Draft)
+	assert.Equal(t, "feature", pr.Head.Ref)
+	assert.Equal(t, "main", pr.Base.Ref)
+	assert.Equal(t, "dev", pr.User.Login)
+	assert.Contains(t, m.bodies["POST "+projectPath+"/merge_requests"], `"title":"Draft: Add thing"`)
+}
+
+func TestGitLabListPRs(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/merge_requests?per_page=100": `[
+			{"iid": 1, "state": "opened"},
+			{"iid": 2, "state": "merged"},
+			{"iid": 3, "state": "closed"}]`,
+	}}
+	prs, err := newTestGitLab(m).ListPRs("closed")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.True(t, prs[0].Merged)
+	assert.Equal(t, "closed", prs[1].State)
+	assert.False(t, prs[1].Merged)
+}
+
+func TestGitLabMergeAndClose(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"PUT " + projectPath + "/merge_requests/4/merge": `{}`,
+		"PUT " + projectPath + "/merge_requests/4":       `{}`,
+	}}
+	c := newTestGitLab(m)
+
+	require.NoError(t, c.MergePRWithMessage(4, "squash", "feat: thing (#4)", "Details"))
+	body := m.bodies["PUT "+projectPath+"/merge_requests/4/merge"]
+	assert.Contains(t, body, `"squash":true`)
+	assert.Contains(t, body, `"squash_commit_message":"feat: thing (#4)\n\nDetails"`)
+
+	assert.Error(t, c.MergePR(4, "rebase"))
+
+	require.NoError(t, c.ClosePR(4))
+	assert.Equal(t, `{"state_event":"close"}`, m.bodies["PUT "+projectPath+"/merge_requests/4"])
+}
+
+func TestGitLabReopen(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/merge_requests?order_by=updated_at&source_branch=feature&state=closed": `[
+			{"iid": 5, "title": "Old try", "state": "closed", "source_branch": "feature", "target_branch": "main"}]`,
+		"PUT " + projectPath + "/merge_requests/5": `{}`,
+		"GET /api/v4/user":                         `{"username": "dev"}`,
+	}}
+	c := newTestGitLab(m)
+
+	prs, err := c.ClosedPRsForBranch("feature")
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, 5, prs[0].Number)
+	assert.Equal(t, "closed", prs[0].State)
+
+	require.NoError(t, c.ReopenPR(5))
+	assert.Equal(t, `{"state_event":"reopen"}`, m.bodies["PUT "+projectPath+"/merge_requests/5"])
+
+	login, err := c.CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, "dev", login)
+}
+
+func TestGitLabGetPRForBranch(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/merge_requests?source_branch=feature&state=opened": `[{"iid": 9, "state": "opened", "source_branch": "feature"}]`,
+		"GET " + projectPath + "/merge_requests?source_branch=other&state=opened":   `[]`,
+	}}
+	c := newTestGitLab(m)
+
+	pr, err := c.GetPRForBranch("feature")
+	require.NoError(t, err)
+	require.NotNil(t, pr)
+	assert.Equal(t, 9, pr.Number)
+
+	pr, err = c.GetPRForBranch("other")
+	require.NoError(t, err)
+	assert.Nil(t, pr)
+}
+
+func TestGitLabGetPRTemplate(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/templates/merge_requests":         `[{"key": "Bug", "name": "Bug"}, {"key": "Default", "name": "Default"}]`,
+		"GET " + projectPath + "/templates/merge_requests/Default": `{"name": "Default", "content": "## What\n"}`,
+	}}
+	tmpl, err := newTestGitLab(m).GetPRTemplate()
+	require.NoError(t, err)
+	assert.Equal(t, "## What\n", tmpl)
+}
+
+func TestGitLabListPRFilesAndThreads(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/merge_requests/5/diffs?per_page=100": `[
+			{"old_path": "a.go", "new_path": "b.go", "renamed_file": true, "diff": "@@ -1 +1,2 @@\n-x\n+y\n+z\n"}]`,
+		"GET " + projectPath + "/merge_requests/5/discussions?per_page=100": `[
+			{"notes": [{"body": "why?", "author": {"username": "rev"}, "resolvable": true, "resolved": false,
+				"position": {"new_path": "b.go", "new_line": 2}}]},
+			{"notes": [{"body": "done", "resolvable": true, "resolved": true}]},
+			{"notes": [{"body": "general", "resolvable": false}]}]`,
+	}}
+	c := newTestGitLab(m)
+
+	files, err := c.ListPRFiles(5)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "renamed", files[0].Status)
+	assert.Equal(t, "a.go", files[0].PreviousFilename)
+	assert.Equal(t, 2, files[0].Additions)
+	assert.Equal(t, 1, files[0].Deletions)
+
+	threads, err := c.ListPRUnresolvedThreads(5)
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.Equal(t, "b.go", threads[0].Path)
+	assert.Equal(t, 2, threads[0].Line)
+	assert.Equal(t, "rev", threads[0].Comments[0].User)
+}
+
+func TestGitLabListPRCommits(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/merge_requests/3/commits?per_page=100": `[
+			{"id": "bbb", "title": "second", "message": "second\n\nMore detail\n"},
+			{"id": "aaa", "title": "first", "message": "first\n"}]`,
+	}}
+	commits, err := newTestGitLab(m).ListPRCommits(3)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "first", commits[0].Subject)
+	assert.Equal(t, "second", commits[1].Subject)
+	assert.Equal(t, "More detail", commits[1].Body)
+}
+
+func TestGitLabPRsForCommit(t *testing.T) {
+	m := &mockTransport{responses: map[string]string{
+		"GET " + projectPath + "/repository/commits/abc/merge_requests": `[{"iid": 4, "title": "Add users", "state": "merged"}]`,
+	}}
+	prs, err := newTestGitLab(m).PRsForCommit("abc")
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, 4, prs[0].Number)
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		url, host, path string
+		ok              bool
+	}{
+		{"git@gitlab.com:group/app.git", "gitlab.com", "group/app", true},
+		{"https://gitlab.example.com/group/sub/app.git", "gitlab.example.com", "group/sub/app", true},
+		{"ssh://git@gitlab.example.com:2222/group/sub/app", "gitlab.example.com", "group/sub/app", true},
+		{"https://gitlab.com/app", "", "", false},
+		{"not a url", "", "", false},
+	}
+	for _, tt := range tests {
+		host, path, ok := parseRemote(tt.url)
+		assert.Equal(t, tt.ok, ok, tt.url)
+		assert.Equal(t, tt.host, host, tt.url)
+		assert.Equal(t, tt.path, path, tt.url)
+	}
+}
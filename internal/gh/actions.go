@@ -0,0 +1,85 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CheckRun is a check run on a commit. Runs created by GitHub Actions share
+// their ID with the job, so their logs can be fetched with GetJobLog.
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`     // queued, in_progress or completed
+	Conclusion string `json:"conclusion"` // success, failure, timed_out, ... once completed
+	HTMLURL    string `json:"html_url"`
+	App        struct {
+		Slug string `json:"slug"`
+	} `json:"app"`
+}
+
+// Failed reports whether the run finished unsuccessfully
+func (c CheckRun) Failed() bool {
+	return failedConclusion(c.Conclusion)
+}
+
+func failedConclusion(conclusion string) bool {
+	switch conclusion {
+	case "failure", "timed_out", "startup_failure", "action_required":
+		return true
+	}
+	return false
+}
+
+// FromActions reports whether the run is a GitHub Actions job
+func (c CheckRun) FromActions() bool {
+	return c.App.Slug == "github-actions"
+}
+
+// ListPRCheckRuns returns the check runs on a pull request's head commit
+func (p *pullRequestAPI) ListPRCheckRuns(num int) ([]CheckRun, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var pr struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if e := json.Unmarshal(data, &pr); e != nil {
+		return nil, e
+	}
+
+	const perPage = 100
+	var runs []CheckRun
+	for page := 1; page <= 10; page++ {
+		u = fmt.Sprintf("%s/repos/%s/%s/commits/%s/check-runs?per_page=%d&page=%d", p.api(), p.owner, p.repo, pr.Head.SHA, perPage, page)
+		data, err = p.do("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			CheckRuns []CheckRun `json:"check_runs"`
+		}
+		if e := json.Unmarshal(data, &resp); e != nil {
+			return nil, e
+		}
+		runs = append(runs, resp.CheckRuns...)
+		if len(resp.CheckRuns) < perPage {
+			break
+		}
+	}
+	return runs, nil
+}
+
+// GetJobLog downloads the plain-text log of a GitHub Actions job
+func (p *pullRequestAPI) GetJobLog(jobID int64) (string, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/actions/jobs/%d/logs", p.api(), p.owner, p.repo, jobID)
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,147 @@
+package gh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeOptions says how a pull request can be merged right now
+type MergeOptions struct {
+	AutoMergeAllowed bool   // the repository allows auto-merge
+	AutoMergeEnabled bool   // auto-merge is already on for the PR
+	MergeQueue       bool   // the base branch only merges through a merge queue
+	Queued           bool   // the PR is in the merge queue already
+	QueuePosition    int    // its place in the queue, as GitHub numbers it
+	Mergeable        string // MERGEABLE, CONFLICTING or UNKNOWN
+	MergeState       string // clean, dirty, blocked, behind, unstable, draft...
+}
+
+const mergeOptionsQuery = `query MergeOptions($owner: String!, $repo: String!, $num: Int!) {
+  repository(owner: $owner, name: $repo) {
+    autoMergeAllowed
+    pullRequest(number: $num) {
+      id mergeable mergeStateStatus baseRefName
+      autoMergeRequest { enabledAt }
+      mergeQueueEntry { position }
+    }
+  }
+}`
+
+const mergeQueueQuery = `query MergeQueue($owner: String!, $repo: String!, $branch: String!) {
+  repository(owner: $owner, name: $repo) {
+    mergeQueue(branch: $branch) { id }
+  }
+}`
+
+// prMergeNode is what mergeOptionsQuery reads about the PR
+type prMergeNode struct {
+	ID               string `json:"id"`
+	Mergeable        string `json:"mergeable"`
+	MergeStateStatus string `json:"mergeStateStatus"`
+	BaseRefName      string `json:"baseRefName"`
+	AutoMergeRequest *struct {
+		EnabledAt string `json:"enabledAt"`
+	} `json:"autoMergeRequest"`
+	MergeQueueEntry *struct {
+		Position int `json:"position"`
+	} `json:"mergeQueueEntry"`
+}
+
+func (p *pullRequestAPI) mergeNode(num int) (*prMergeNode, bool, error) {
+	var data struct {
+		Repository struct {
+			AutoMergeAllowed bool         `json:"autoMergeAllowed"`
+			PullRequest      *prMergeNode `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	vars := map[string]any{"owner": p.owner, "repo": p.repo, "num": num}
+	if err := p.graphql(mergeOptionsQuery, vars, &data); err != nil {
+		return nil, false, err
+	}
+	if data.Repository.PullRequest == nil {
+		return nil, false, fmt.Errorf("pull request #%d not found", num)
+	}
+	return data.Repository.PullRequest, data.Repository.AutoMergeAllowed, nil
+}
+
+// GetMergeOptions reports whether the PR can auto-merge or has to go
+// through a merge queue, and whether it has conflicts
+func (p *pullRequestAPI) GetMergeOptions(num int) (*MergeOptions, error) {
+	node, autoAllowed, err := p.mergeNode(num)
+	if err != nil {
+		return nil, err
+	}
+	opts := &MergeOptions{
+		AutoMergeAllowed: autoAllowed,
+		AutoMergeEnabled: node.AutoMergeRequest != nil,
+		Mergeable:        node.Mergeable,
+		MergeState:       strings.ToLower(node.MergeStateStatus),
+	}
+	if node.MergeQueueEntry != nil {
+		opts.MergeQueue, opts.Queued, opts.QueuePosition = true, true, node.MergeQueueEntry.Position
+		return opts, nil
+	}
+
+	var queue struct {
+		Repository struct {
+			MergeQueue *struct {
+				ID string `json:"id"`
+			} `json:"mergeQueue"`
+		} `json:"repository"`
+	}
+	vars := map[string]any{"owner": p.owner, "repo": p.repo, "branch": node.BaseRefName}
+	if err := p.graphql(mergeQueueQuery, vars, &queue); err != nil {
+		return nil, err
+	}
+	opts.MergeQueue = queue.Repository.MergeQueue != nil
+	return opts, nil
+}
+
+const enableAutoMergeMutation = `mutation EnableAutoMerge($id: ID!, $method: PullRequestMergeMethod!, $title: String, $body: String) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method, commitHeadline: $title, commitBody: $body}) {
+    clientMutationId
+  }
+}`
+
+// EnableAutoMerge has GitHub merge the PR with method once its required
+// checks and reviews pass. An empty title keeps GitHub's default message.
+func (p *pullRequestAPI) EnableAutoMerge(num int, method, title, message string) error {
+	node, _, err := p.mergeNode(num)
+	if err != nil {
+		return err
+	}
+	vars := map[string]any{"id": node.ID, "method": strings.ToUpper(method)}
+	if title != "" {
+		vars["title"], vars["body"] = title, message
+	}
+	return p.graphql(enableAutoMergeMutation, vars, nil)
+}
+
+const enqueueMutation = `mutation Enqueue($id: ID!) {
+  enqueuePullRequest(input: {pullRequestId: $id}) {
+    mergeQueueEntry { position }
+  }
+}`
+
+// EnqueuePR adds the PR to its base branch's merge queue, returning its
+// position there
+func (p *pullRequestAPI) EnqueuePR(num int) (int, error) {
+	node, _, err := p.mergeNode(num)
+	if err != nil {
+		return 0, err
+	}
+	var data struct {
+		EnqueuePullRequest struct {
+			MergeQueueEntry *struct {
+				Position int `json:"position"`
+			} `json:"mergeQueueEntry"`
+		} `json:"enqueuePullRequest"`
+	}
+	if err := p.graphql(enqueueMutation, map[string]any{"id": node.ID}, &data); err != nil {
+		return 0, err
+	}
+	if e := data.EnqueuePullRequest.MergeQueueEntry; e != nil {
+		return e.Position, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,132 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxOpenPRPages caps how many pages of open pull requests OpenPRsByHead reads
+const maxOpenPRPages = 10
+
+// CommitInfo is a commit as read from the API
+type CommitInfo struct {
+	SHA         string
+	Subject     string
+	Body        string
+	AuthorName  string
+	AuthorLogin string // "" when the author's email isn't linked to an account
+	Date        time.Time
+}
+
+// apiCommit is a commit as the REST API returns it
+type apiCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+func (c apiCommit) info() CommitInfo {
+	subject, body, _ := strings.Cut(c.Commit.Message, "\n")
+	info := CommitInfo{
+		SHA:        c.SHA,
+		Subject:    subject,
+		Body:       strings.TrimSpace(body),
+		AuthorName: c.Commit.Author.Name,
+		Date:       c.Commit.Author.Date,
+	}
+	if c.Author != nil {
+		info.AuthorLogin = c.Author.Login
+	}
+	return info
+}
+
+// GetCommit does GET /repos/:owner/:repo/commits/:sha
+func (p *pullRequestAPI) GetCommit(sha string) (*CommitInfo, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/commits/%s", p.api(), p.owner, p.repo, sha)
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var commit apiCommit
+	if e := json.Unmarshal(data, &commit); e != nil {
+		return nil, e
+	}
+	info := commit.info()
+	return &info, nil
+}
+
+// ListPRCommits does GET /repos/:owner/:repo/pulls/:pull_number/commits,
+// returning up to 250 commits oldest first
+func (p *pullRequestAPI) ListPRCommits(num int) ([]CommitInfo, error) {
+	var commits []CommitInfo
+	for page := 1; page <= 3; page++ {
+		u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/commits?per_page=100&page=%d", p.api(), p.owner, p.repo, num, page)
+		data, err := p.do("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []apiCommit
+		if e := json.Unmarshal(data, &batch); e != nil {
+			return nil, e
+		}
+		for _, c := range batch {
+			commits = append(commits, c.info())
+		}
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return commits, nil
+}
+
+// PRsForCommit does GET /repos/:owner/:repo/commits/:sha/pulls, the pull
+// requests that brought a commit in or still carry it. A squashed or rebased
+// commit belongs to the PR that merged it.
+func (p *pullRequestAPI) PRsForCommit(sha string) ([]PullRequest, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/commits/%s/pulls", p.api(), p.owner, p.repo, sha)
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var prs []PullRequest
+	if err := json.Unmarshal(data, &prs); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// OpenPRsByHead returns the repository's open pull requests keyed by head
+// branch, reading up to a thousand of them
+func (p *pullRequestAPI) OpenPRsByHead() (map[string]PullRequest, error) {
+	byHead := map[string]PullRequest{}
+	for page := 1; page <= maxOpenPRPages; page++ {
+		u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=open&per_page=100&page=%d", p.api(), p.owner, p.repo, page)
+		data, err := p.do("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var prs []PullRequest
+		if e := json.Unmarshal(data, &prs); e != nil {
+			return nil, e
+		}
+		for _, pr := range prs {
+			if _, ok := byHead[pr.Head.Ref]; !ok {
+				byHead[pr.Head.Ref] = pr
+			}
+		}
+		if len(prs) < 100 {
+			break
+		}
+	}
+	return byHead, nil
+}
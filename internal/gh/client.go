@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
 	"github.com/crazywolf132/sage/internal/git"
+	"github.com/crazywolf132/sage/internal/repoinfo"
 )
 
 const baseURL = "https://api.github.com"
@@ -23,6 +26,26 @@ type pullRequestAPI struct {
 	client *http.Client
 	owner  string
 	repo   string
+	apiURL string // REST API root; empty means api.github.com
+	// refresh, when set, returns the current token instead, for GitHub App
+	// installation tokens that expire within the hour
+	refresh func() string
+}
+
+// authToken returns the token to send
+func (p *pullRequestAPI) authToken() string {
+	if p.refresh != nil {
+		return p.refresh()
+	}
+	return p.token
+}
+
+// api returns the REST API root for this client
+func (p *pullRequestAPI) api() string {
+	if p.apiURL != "" {
+		return p.apiURL
+	}
+	return baseURL
 }
 
 // PullRequest is the domain object representing a PR
@@ -34,27 +57,63 @@ type PullRequest struct {
 	HTMLURL string `json:"html_url"`
 	Draft   bool   `json:"draft"`
 	Merged  bool   `json:"merged"`
-	Head    struct {
+	// MergedAt is set in PR lists too, which leave Merged out
+	MergedAt *time.Time `json:"merged_at"`
+	User     struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Head      struct {
 		Ref string `json:"ref"`
+		SHA string `json:"sha"`
 	} `json:"head"`
 	Base struct {
 		Ref string `json:"ref"`
 	} `json:"base"`
-	Reviews  []Review        `json:"reviews"`
-	Checks   []Check         `json:"checks"`
-	Timeline []TimelineEvent `json:"timeline"`
+	// Mergeable is nil while GitHub is still working it out;
+	// MergeableState is clean, dirty, blocked, behind, unstable, draft...
+	Mergeable          *bool           `json:"mergeable"`
+	MergeableState     string          `json:"mergeable_state"`
+	RequestedReviewers []Account       `json:"requested_reviewers"`
+	Labels             []Label         `json:"labels"`
+	Reviews            []Review        `json:"reviews"`
+	Checks             []Check         `json:"checks"`
+	Timeline           []TimelineEvent `json:"timeline"`
+	// Threads are the review threads, resolved ones included; only
+	// GetPRDetails fills them in, and only through GraphQL
+	Threads []ReviewThread `json:"review_threads,omitempty"`
+}
+
+// Account is a GitHub user
+type Account struct {
+	Login string `json:"login"`
+}
+
+// Label is a label on a pull request
+type Label struct {
+	Name string `json:"name"`
 }
 
 type Review struct {
 	State string `json:"state"`
+	Body  string `json:"body"`
 	User  struct {
 		Login string `json:"login"`
 	} `json:"user"`
 }
 
 type Check struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`     // queued, in_progress or completed
+	Conclusion  string     `json:"conclusion"` // success, failure, ... once completed
+	HTMLURL     string     `json:"html_url"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Failed reports whether the check finished unsuccessfully
+func (c Check) Failed() bool {
+	return failedConclusion(c.Conclusion)
 }
 
 type TimelineEvent struct {
@@ -67,6 +126,16 @@ type TimelineEvent struct {
 	} `json:"actor"`
 }
 
+// PRFile is a file changed by a pull request
+type PRFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename,omitempty"`
+	Status           string `json:"status"`
+	Additions        int    `json:"additions"`
+	Deletions        int    `json:"deletions"`
+	Patch            string `json:"patch,omitempty"` // unified diff hunks; empty for binary or very large files
+}
+
 // UnresolvedThread is a minimal structure for unresolved PR comment threads
 type UnresolvedThread struct {
 	Path        string
@@ -87,6 +156,7 @@ type Client interface {
 	CreatePR(title, body, head, base string, draft bool) (*PullRequest, error)
 	ListPRs(state string) ([]PullRequest, error)
 	MergePR(num int, method string) error
+	MergePRWithMessage(num int, method, title, message string) error
 	ClosePR(num int) error
 	GetPRDetails(num int) (*PullRequest, error)
 	CheckoutPR(num int) (string, error)
@@ -97,12 +167,59 @@ type Client interface {
 	GetPRForBranch(branchName string) (*PullRequest, error)
 	GetLatestRelease() (string, error)
 	UpdatePR(num int, pr *PullRequest) error
+	ListPRFiles(num int) ([]PRFile, error)
+	GetBranchProtection(branch string) (*BranchProtection, error)
+	UpdateBranchProtection(branch string, bp *BranchProtection) error
+	ListPRCheckRuns(num int) ([]CheckRun, error)
+	GetJobLog(jobID int64) (string, error)
+	CreateIssue(title, body string, labels []string) (*Issue, error)
+	CommitAuthorLogin(sha string) (string, error)
+	IsCollaborator(login string) (bool, error)
+	SearchPRs(query string, page, perPage int) (*PRSearchResult, error)
+	GetCommit(sha string) (*CommitInfo, error)
+	OpenPRsByHead() (map[string]PullRequest, error)
+	ListPRCommits(num int) ([]CommitInfo, error)
+	PRsForCommit(sha string) ([]PullRequest, error)
+	GetReleaseByTag(tag string) (*Release, error)
+	CreateRelease(tag, name, body string, prerelease bool) (*Release, error)
+	DeleteReleaseAsset(id int64) error
+	UploadReleaseAsset(release *Release, name, contentType string, body io.Reader, size int64) (*ReleaseAsset, error)
+	ApprovePR(num int) error
+	ReopenPR(num int) error
+	ClosedPRsForBranch(branch string) ([]PullRequest, error)
+	CurrentUser() (string, error)
+	ListReviewThreads(num int) ([]ReviewThread, error)
+	ReplyToReviewComment(num int, commentID int64, body string) error
+	ResolveReviewThread(threadID string) error
+	SubmitReview(num int, event, body string) error
+	ListPRReviews(num int) ([]Review, error)
+	GetMergeOptions(num int) (*MergeOptions, error)
+	EnableAutoMerge(num int, method, title, message string) error
+	EnqueuePR(num int) (int, error)
+	SetDraft(num int, draft bool) error
+	RemoveLabel(prNumber int, label string) error
+	RemoveReviewers(prNumber int, reviewers []string) error
 }
 
 // TokenSource represents where the GitHub token was obtained from
 type TokenSource struct {
-	Token  string
-	Source string
+	Token   string
+	Source  string
+	Expires bool  // the token runs out and is renewed as needed
+	Err     error // why the configured source gave no token
+}
+
+// StatusExitCode maps a failed API response's status to the exit code it
+// should cause: rejected credentials or permissions are auth failures
+func StatusExitCode(status int) int {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return exitcode.Auth
+	case http.StatusNotFound:
+		return exitcode.NotFound
+	default:
+		return exitcode.Failure
+	}
 }
 
 func (p *pullRequestAPI) do(method, url string, body any) ([]byte, error) {
@@ -114,14 +231,31 @@ func (p *pullRequestAPI) do(method, url string, body any) ([]byte, error) {
 		}
 		buf = bytes.NewReader(b)
 	}
+
+	// In dry-run mode only reads reach GitHub; writes are printed instead
+	if dryrun.Enabled() && method != "GET" {
+		if body != nil {
+			b, _ := json.Marshal(body)
+			dryrun.Record("GitHub API %s %s %s", method, url, b)
+		} else {
+			dryrun.Record("GitHub API %s %s", method, url)
+		}
+		return []byte("{}"), nil
+	}
+	return p.send(method, url, buf, body != nil)
+}
+
+// send makes a request whatever the dry-run mode, for reads that have to be
+// POSTs such as GraphQL queries
+func (p *pullRequestAPI) send(method, url string, buf io.Reader, hasBody bool) ([]byte, error) {
 	req, err := http.NewRequest(method, url, buf)
 	if err != nil {
 		return nil, err
 	}
-	if p.token != "" {
-		req.Header.Set("Authorization", "token "+p.token)
+	if token := p.authToken(); token != "" {
+		req.Header.Set("Authorization", "token "+token)
 	}
-	if body != nil {
+	if hasBody {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
@@ -130,18 +264,22 @@ func (p *pullRequestAPI) do(method, url string, body any) ([]byte, error) {
 		return nil, fmt.Errorf("request error: %w", err)
 	}
 	defer resp.Body.Close()
+	recordRateLimit(resp.Header)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		msg, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API %s %s returned %d:\n%s",
-			method, url, resp.StatusCode, string(msg))
+		if resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusForbidden && rateLimited(resp)) {
+			return nil, rateLimitError(resp.Header)
+		}
+		return nil, exitcode.New(StatusExitCode(resp.StatusCode), fmt.Errorf("GitHub API %s %s returned %d:\n%s",
+			method, url, resp.StatusCode, string(msg)))
 	}
 	return io.ReadAll(resp.Body)
 }
 
 // CreatePR does a POST /repos/:owner/:repo/pulls
 func (p *pullRequestAPI) CreatePR(title, body, head, base string, draft bool) (*PullRequest, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/pulls", baseURL, p.owner, p.repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", p.api(), p.owner, p.repo)
 	payload := map[string]any{
 		"title": title,
 		"body":  body,
@@ -162,7 +300,7 @@ func (p *pullRequestAPI) CreatePR(title, body, head, base string, draft bool) (*
 
 // ListPRs does GET /repos/:owner/:repo/pulls?state=STATE
 func (p *pullRequestAPI) ListPRs(state string) ([]PullRequest, error) {
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=%s", baseURL, p.owner, p.repo, state)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=%s", p.api(), p.owner, p.repo, state)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -176,8 +314,14 @@ func (p *pullRequestAPI) ListPRs(state string) ([]PullRequest, error) {
 
 // MergePR does PUT /repos/:owner/:repo/pulls/:pull_number/merge
 func (p *pullRequestAPI) MergePR(num int, method string) error {
+	return p.MergePRWithMessage(num, method, "", "")
+}
+
+// MergePRWithMessage merges like MergePR, using title and message for the
+// merge or squash commit when they aren't empty
+func (p *pullRequestAPI) MergePRWithMessage(num int, method, title, message string) error {
 	// First get the repository settings to check allowed merge methods
-	repoURL := fmt.Sprintf("%s/repos/%s/%s", baseURL, p.owner, p.repo)
+	repoURL := fmt.Sprintf("%s/repos/%s/%s", p.api(), p.owner, p.repo)
 	data, err := p.do("GET", repoURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to get repository settings: %w", err)
@@ -211,10 +355,16 @@ func (p *pullRequestAPI) MergePR(num int, method string) error {
 	}
 
 	// If the method is allowed, proceed with the merge
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/merge", baseURL, p.owner, p.repo, num)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/merge", p.api(), p.owner, p.repo, num)
 	payload := map[string]string{
 		"merge_method": method,
 	}
+	if title != "" {
+		payload["commit_title"] = title
+	}
+	if message != "" {
+		payload["commit_message"] = message
+	}
 	_, err = p.do("PUT", u, payload)
 	if err != nil {
 		// Check for specific error cases
@@ -238,7 +388,7 @@ func (p *pullRequestAPI) MergePR(num int, method string) error {
 
 // ClosePR does PATCH /repos/:owner/:repo/pulls/:pull_number (state=closed)
 func (p *pullRequestAPI) ClosePR(num int) error {
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", baseURL, p.owner, p.repo, num)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
 	payload := map[string]string{
 		"state": "closed",
 	}
@@ -246,10 +396,77 @@ func (p *pullRequestAPI) ClosePR(num int) error {
 	return err
 }
 
-// GetPRDetails does GET /repos/:owner/:repo/pulls/:pull_number and fetches additional data
+// ReopenPR does PATCH /repos/:owner/:repo/pulls/:pull_number (state=open)
+func (p *pullRequestAPI) ReopenPR(num int) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
+	_, err := p.do("PATCH", u, map[string]string{"state": "open"})
+	return err
+}
+
+// ClosedPRsForBranch returns the PRs from branch that were closed without
+// being merged, most recently updated first
+func (p *pullRequestAPI) ClosedPRsForBranch(branch string) ([]PullRequest, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=closed&sort=updated&direction=desc&head=%s:%s",
+		p.api(), p.owner, p.repo, p.owner, url.QueryEscape(branch))
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var prs []PullRequest
+	if e := json.Unmarshal(data, &prs); e != nil {
+		return nil, e
+	}
+	var closed []PullRequest
+	for _, pr := range prs {
+		if pr.MergedAt == nil && !pr.Merged {
+			closed = append(closed, pr)
+		}
+	}
+	return closed, nil
+}
+
+// CurrentUser returns the login the token belongs to
+func (p *pullRequestAPI) CurrentUser() (string, error) {
+	data, err := p.do("GET", p.api()+"/user", nil)
+	if err != nil {
+		return "", err
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if e := json.Unmarshal(data, &user); e != nil {
+		return "", e
+	}
+	return user.Login, nil
+}
+
+// ApprovePR submits an approving review
+func (p *pullRequestAPI) ApprovePR(num int) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews", p.api(), p.owner, p.repo, num)
+	_, err := p.do("POST", u, map[string]string{"event": "APPROVE"})
+	return err
+}
+
+// GetPRDetails fetches a PR with its labels, reviews, checks, commits and
+// review threads in one GraphQL query, falling back to REST calls when
+// GraphQL isn't available
 func (p *pullRequestAPI) GetPRDetails(num int) (*PullRequest, error) {
+	// GraphQL always needs a token, where REST can read public repositories
+	if p.authToken() == "" {
+		return p.prDetailsREST(num)
+	}
+	pr, err := p.prDetailsGraphQL(num)
+	if err == nil || !graphqlUnavailable.MatchString(err.Error()) {
+		return pr, err
+	}
+	logf("GraphQL is unavailable, fetching PR #%d over REST instead: %v", num, err)
+	return p.prDetailsREST(num)
+}
+
+// prDetailsREST does GET /repos/:owner/:repo/pulls/:pull_number and fetches additional data
+func (p *pullRequestAPI) prDetailsREST(num int) (*PullRequest, error) {
 	// Get basic PR info
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", baseURL, p.owner, p.repo, num)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -260,7 +477,7 @@ func (p *pullRequestAPI) GetPRDetails(num int) (*PullRequest, error) {
 	}
 
 	// Get reviews
-	reviews, err := p.getPRReviews(num)
+	reviews, err := p.ListPRReviews(num)
 	if err != nil {
 		// Don't fail if we can't get reviews
 		fmt.Printf("Warning: failed to get reviews: %v\n", err)
@@ -286,8 +503,9 @@ func (p *pullRequestAPI) GetPRDetails(num int) (*PullRequest, error) {
 	return &pr, nil
 }
 
-func (p *pullRequestAPI) getPRReviews(num int) ([]Review, error) {
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews", baseURL, p.owner, p.repo, num)
+// ListPRReviews does GET /repos/:owner/:repo/pulls/:num/reviews, oldest first
+func (p *pullRequestAPI) ListPRReviews(num int) ([]Review, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews", p.api(), p.owner, p.repo, num)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -301,7 +519,7 @@ func (p *pullRequestAPI) getPRReviews(num int) ([]Review, error) {
 
 func (p *pullRequestAPI) getPRChecks(num int) ([]Check, error) {
 	// First get the ref (SHA) for the PR head
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", baseURL, p.owner, p.repo, num)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -316,7 +534,7 @@ func (p *pullRequestAPI) getPRChecks(num int) ([]Check, error) {
 	}
 
 	// Then get check runs for that SHA
-	u = fmt.Sprintf("%s/repos/%s/%s/commits/%s/check-runs", baseURL, p.owner, p.repo, pr.Head.SHA)
+	u = fmt.Sprintf("%s/repos/%s/%s/commits/%s/check-runs", p.api(), p.owner, p.repo, pr.Head.SHA)
 	data, err = p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -331,7 +549,7 @@ func (p *pullRequestAPI) getPRChecks(num int) ([]Check, error) {
 }
 
 func (p *pullRequestAPI) getPRTimeline(num int) ([]TimelineEvent, error) {
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/commits", baseURL, p.owner, p.repo, num)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/commits", p.api(), p.owner, p.repo, num)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -369,6 +587,29 @@ func (p *pullRequestAPI) getPRTimeline(num int) ([]TimelineEvent, error) {
 	return timeline, nil
 }
 
+// ListPRFiles does a GET /repos/:owner/:repo/pulls/:num/files, following pagination
+func (p *pullRequestAPI) ListPRFiles(num int) ([]PRFile, error) {
+	const perPage = 100
+	var files []PRFile
+	// GitHub caps this endpoint at 3000 files
+	for page := 1; page <= 30; page++ {
+		u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/files?per_page=%d&page=%d", p.api(), p.owner, p.repo, num, perPage, page)
+		data, err := p.do("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []PRFile
+		if e := json.Unmarshal(data, &batch); e != nil {
+			return nil, e
+		}
+		files = append(files, batch...)
+		if len(batch) < perPage {
+			break
+		}
+	}
+	return files, nil
+}
+
 // CheckoutPR fetches the branch and switches locally
 // We do "git fetch origin HEAD-REF" then create a local branch
 func (p *pullRequestAPI) CheckoutPR(num int) (string, error) {
@@ -404,7 +645,7 @@ func (p *pullRequestAPI) CheckoutPR(num int) (string, error) {
 
 // ListPRUnresolvedThreads checks review comments for prNumber
 func (p *pullRequestAPI) ListPRUnresolvedThreads(prNum int) ([]UnresolvedThread, error) {
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/comments", baseURL, p.owner, p.repo, prNum)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/comments", p.api(), p.owner, p.repo, prNum)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -501,7 +742,7 @@ func (p *pullRequestAPI) getDirectoryContent(path string) ([]struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
 }, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", baseURL, p.owner, p.repo, path)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", p.api(), p.owner, p.repo, path)
 	data, err := p.do("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -519,7 +760,7 @@ func (p *pullRequestAPI) getDirectoryContent(path string) ([]struct {
 }
 
 func (p *pullRequestAPI) getContentFile(path string) (string, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", baseURL, p.owner, p.repo, path)
+	url := fmt.Sprintf("%s/repos/%s/%s/contents/%s", p.api(), p.owner, p.repo, path)
 	data, err := p.do("GET", url, nil)
 	if err != nil {
 		return "", err
@@ -539,7 +780,7 @@ func (p *pullRequestAPI) getContentFile(path string) (string, error) {
 }
 
 func (p *pullRequestAPI) AddLabels(prNumber int, labels []string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels", baseURL, p.owner, p.repo, prNumber)
+	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels", p.api(), p.owner, p.repo, prNumber)
 	payload := map[string][]string{
 		"labels": labels,
 	}
@@ -547,13 +788,32 @@ func (p *pullRequestAPI) AddLabels(prNumber int, labels []string) error {
 	return err
 }
 
+// RequestReviewers asks users, and teams given as org/team, for a review
 func (p *pullRequestAPI) RequestReviewers(prNumber int, reviewers []string) error {
-	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/requested_reviewers", baseURL, p.owner, p.repo, prNumber)
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/requested_reviewers", p.api(), p.owner, p.repo, prNumber)
+	_, err := p.do("POST", url, reviewersPayload(reviewers))
+	return err
+}
+
+// reviewersPayload splits reviewers into the users and team slugs the
+// requested_reviewers endpoints take
+func reviewersPayload(reviewers []string) map[string][]string {
+	users, teams := []string{}, []string{}
+	for _, r := range reviewers {
+		r = strings.TrimPrefix(r, "@")
+		if _, team, ok := strings.Cut(r, "/"); ok {
+			teams = append(teams, team)
+		} else {
+			users = append(users, r)
+		}
+	}
 	payload := map[string][]string{
-		"reviewers": reviewers,
+		"reviewers": users,
 	}
-	_, err := p.do("POST", url, payload)
-	return err
+	if len(teams) > 0 {
+		payload["team_reviewers"] = teams
+	}
+	return payload
 }
 
 // decodeBase64 is a minimal helper
@@ -582,7 +842,7 @@ func runCmd(prog string, args ...string) (string, error) {
 // Returns nil if no PR exists for the branch.
 func (p *pullRequestAPI) GetPRForBranch(branchName string) (*PullRequest, error) {
 	// List open PRs for this branch
-	u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=open&head=%s:%s", baseURL, p.owner, p.repo, p.owner, branchName)
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls?state=open&head=%s:%s", p.api(), p.owner, p.repo, p.owner, branchName)
 	data, err := p.do("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -599,7 +859,7 @@ func (p *pullRequestAPI) GetPRForBranch(branchName string) (*PullRequest, error)
 
 // GetLatestRelease returns the latest release version from GitHub
 func (p *pullRequestAPI) GetLatestRelease() (string, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", baseURL, p.owner, p.repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", p.api(), p.owner, p.repo)
 	data, err := p.do("GET", url, nil)
 	if err != nil {
 		return "", err
@@ -619,12 +879,16 @@ func (p *pullRequestAPI) GetLatestRelease() (string, error) {
 
 // NewClient creates a new GitHub client
 func NewClient() Client {
-	owner, repo := getOwnerAndRepo()
-	if owner == "" || repo == "" {
+	r := repoinfo.Default()
+	info, err := r.Repo()
+	if err != nil {
 		panic("Could not determine GitHub repository. Please ensure you have a valid git remote or set SAGE_GITHUB_OWNER and SAGE_GITHUB_REPO environment variables")
 	}
 
-	tokenSource := getToken()
+	tokenSource := getToken(r, info)
+	if tokenSource.Err != nil {
+		panic(fmt.Sprintf("Could not authenticate with the %s: %v", tokenSource.Source, tokenSource.Err))
+	}
 	if tokenSource.Token == "" {
 		panic(`GitHub token not found. Please either:
 1. Set SAGE_GITHUB_TOKEN environment variable
@@ -633,135 +897,44 @@ func NewClient() Client {
 	 `)
 	}
 
-	return &pullRequestAPI{
-		owner:  owner,
-		repo:   repo,
+	p := &pullRequestAPI{
+		owner:  info.Owner,
+		repo:   info.Repo,
 		token:  tokenSource.Token,
-		client: &http.Client{},
-	}
-}
-
-// getToken returns the GitHub token from various sources
-func getToken() TokenSource {
-	// Try environment variables first
-	token := os.Getenv("SAGE_GITHUB_TOKEN")
-	if token != "" {
-		return TokenSource{Token: token, Source: "SAGE_GITHUB_TOKEN environment variable"}
-	}
-
-	token = os.Getenv("GITHUB_TOKEN")
-	if token != "" {
-		return TokenSource{Token: token, Source: "GITHUB_TOKEN environment variable"}
-	}
-
-	// Try to get token from gh CLI
-	ghToken, err := getGHCliToken()
-	if err == nil && ghToken != "" {
-		return TokenSource{Token: ghToken, Source: "GitHub CLI"}
-	}
-
-	return TokenSource{}
-}
-
-// getGHCliToken attempts to get the GitHub token from the gh CLI configuration
-func getGHCliToken() (string, error) {
-	// Try to get token using gh CLI
-	cmd, err := git.SetupSecureCommand("gh", "auth", "token")
-	if err != nil {
-		return "", err
-	}
-
-	output, err := cmd.Output()
-	if err == nil {
-		token := strings.TrimSpace(string(output))
-		if token != "" {
-			return token, nil
+		client: &http.Client{Transport: newTransport(nil)},
+		apiURL: info.APIBaseURL(),
+	}
+	if tokenSource.Expires {
+		// The resolver mints a new installation token when this one is
+		// about to run out; keep the old one if that fails
+		p.refresh = func() string {
+			if t := getToken(r, info); t.Token != "" {
+				return t.Token
+			}
+			return p.token
 		}
 	}
-
-	return "", fmt.Errorf("no token found in gh CLI config")
+	return p
 }
 
-// getOwnerAndRepo extracts the owner and repo from the git remote URL
-func getOwnerAndRepo() (string, string) {
-	// Try environment variables first
-	owner := os.Getenv("SAGE_GITHUB_OWNER")
-	repo := os.Getenv("SAGE_GITHUB_REPO")
-	if owner != "" && repo != "" {
-		return owner, repo
-	}
-
-	// Fall back to git remote
-	cmd, err := git.SetupSecureCommand("git", "remote", "get-url", "origin")
-	if err != nil {
-		return "", ""
-	}
-
-	out, err := cmd.Output()
-	if err != nil {
-		return "", ""
-	}
-
-	url := strings.TrimSpace(string(out))
-	url = strings.TrimSuffix(url, ".git")
-
-	// Handle SSH URLs
-	if strings.HasPrefix(url, "git@") {
-		parts := strings.Split(strings.TrimPrefix(url, "git@github.com:"), "/")
-		if len(parts) >= 2 {
-			return parts[0], parts[1]
-		}
-		return "", ""
-	}
-
-	// Handle HTTPS URLs
-	if strings.HasPrefix(url, "https://") {
-		parts := strings.Split(strings.TrimPrefix(url, "https://github.com/"), "/")
-		if len(parts) >= 2 {
-			return parts[0], parts[1]
-		}
-	}
-
-	return "", ""
+// getToken returns the GitHub token for the repository from the resolver
+func getToken(r *repoinfo.Resolver, info repoinfo.Info) TokenSource {
+	t := r.TokenFor(info.Host, info.Owner)
+	return TokenSource{Token: t.Value, Source: t.Source, Expires: !t.Expires.IsZero(), Err: t.Err}
 }
 
 // UpdatePR updates the specified pull request with new details
 func (p *pullRequestAPI) UpdatePR(num int, pr *PullRequest) error {
-	// Build the update request
+	// Draft state can't be changed here; see SetDraft
 	data := map[string]interface{}{
 		"title": pr.Title,
 		"body":  pr.Body,
-		"draft": pr.Draft,
 	}
-
-	// Make the PATCH request to update the PR
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%d", p.owner, p.repo, num)
-	req, err := http.NewRequest("PATCH", url, strings.NewReader(jsonEncode(data)))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+p.token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
+	if pr.Base.Ref != "" {
+		data["base"] = pr.Base.Ref
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update PR: %s", resp.Status)
-	}
-
-	return nil
-}
-
-func jsonEncode(v interface{}) string {
-	data, err := json.Marshal(v)
-	if err != nil {
-		return "{}"
-	}
-	return string(data)
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls/%d", p.api(), p.owner, p.repo, num)
+	_, err := p.do("PATCH", url, data)
+	return err
 }
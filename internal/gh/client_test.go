@@ -7,7 +7,9 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/crazywolf132/sage/internal/repoinfo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -152,6 +154,76 @@ func TestMergePR(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestApprovePR(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /repos/owner/repo/pulls/123/reviews": {
+				statusCode: http.StatusOK,
+				body:       `{"id": 1, "state": "APPROVED"}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	require.NoError(t, client.ApprovePR(123))
+	assert.Error(t, client.ApprovePR(124))
+}
+
+func TestClosedPRsForBranch(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/pulls?state=closed&sort=updated&direction=desc&head=owner:feature%2Fx": {
+				statusCode: http.StatusOK,
+				body: `[
+					{"number": 3, "state": "closed", "merged_at": "2025-01-02T00:00:00Z"},
+					{"number": 2, "state": "closed", "merged_at": null},
+					{"number": 1, "state": "closed"}
+				]`,
+			},
+			"PATCH /repos/owner/repo/pulls/2": {
+				statusCode: http.StatusOK,
+				body:       `{"number": 2, "state": "open"}`,
+			},
+			"GET /user": {
+				statusCode: http.StatusOK,
+				body:       `{"login": "octocat"}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	prs, err := client.ClosedPRsForBranch("feature/x")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.Equal(t, 2, prs[0].Number)
+	assert.Equal(t, 1, prs[1].Number)
+
+	require.NoError(t, client.ReopenPR(2))
+	assert.Error(t, client.ReopenPR(3))
+
+	login, err := client.CurrentUser()
+	require.NoError(t, err)
+	assert.Equal(t, "octocat", login)
+}
+
 func TestGetPRDetails(t *testing.T) {
 	mock := &mockHTTPClient{
 		responses: map[string]struct {
@@ -190,6 +262,189 @@ func TestGetPRDetails(t *testing.T) {
 	assert.Equal(t, "main", pr.Base.Ref)
 }
 
+func TestGetPRDetailsGraphQL(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /graphql": {
+				statusCode: http.StatusOK,
+				body: `{"data": {"repository": {"pullRequest": {
+					"number": 9, "title": "Add cache", "state": "MERGED", "url": "https://github.com/owner/repo/pull/9",
+					"isDraft": false, "merged": true, "mergedAt": "2024-02-01T10:00:00Z", "author": {"login": "dev"},
+					"headRefName": "feat/cache", "headRefOid": "abc123", "baseRefName": "main",
+					"labels": {"nodes": [{"name": "perf"}]},
+					"reviews": {"nodes": [{"state": "APPROVED", "body": "", "author": {"login": "alice"}}, {"state": "COMMENTED", "author": null}]},
+					"commits": {"nodes": [{"commit": {"oid": "abc123", "message": "feat: cache", "committedDate": "2024-01-31T09:00:00Z", "author": {"user": {"login": "dev"}}}}]},
+					"headCommit": {"nodes": [{"commit": {"statusCheckRollup": {"contexts": {"nodes": [
+						{"__typename": "CheckRun", "name": "test", "status": "COMPLETED", "conclusion": "SUCCESS", "detailsUrl": "https://ci/1",
+						 "startedAt": "2024-01-31T09:01:00Z", "completedAt": "2024-01-31T09:05:00Z"},
+						{"__typename": "StatusContext", "context": "deploy/preview", "state": "PENDING", "targetUrl": "https://preview"}
+					]}}}}]},
+					"reviewThreads": {"nodes": [{"id": "T1", "isResolved": true, "path": "cache.go", "line": 4, "comments": {"nodes": [
+						{"databaseId": 5, "body": "nit", "createdAt": "2024-01-31T10:00:00Z", "author": {"login": "alice"}}
+					]}}]}
+				}}}}`,
+			},
+		},
+	}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", token: "test-token", client: &http.Client{Transport: mock}}
+
+	pr, err := client.GetPRDetails(9)
+	require.NoError(t, err)
+	assert.Equal(t, "closed", pr.State)
+	assert.True(t, pr.Merged)
+	assert.Equal(t, "dev", pr.User.Login)
+	assert.Equal(t, "feat/cache", pr.Head.Ref)
+	assert.Equal(t, "abc123", pr.Head.SHA)
+	assert.Equal(t, []Label{{Name: "perf"}}, pr.Labels)
+	require.Len(t, pr.Reviews, 2)
+	assert.Equal(t, "alice", pr.Reviews[0].User.Login)
+
+	require.Len(t, pr.Checks, 2)
+	assert.Equal(t, "completed", pr.Checks[0].Status)
+	assert.Equal(t, "success", pr.Checks[0].Conclusion)
+	assert.Equal(t, 4*time.Minute, pr.Checks[0].CompletedAt.Sub(*pr.Checks[0].StartedAt))
+	assert.Equal(t, "deploy/preview", pr.Checks[1].Name)
+	assert.Equal(t, "in_progress", pr.Checks[1].Status)
+
+	require.Len(t, pr.Timeline, 1)
+	assert.Equal(t, "dev", pr.Timeline[0].Actor.Login)
+	require.Len(t, pr.Threads, 1)
+	assert.True(t, pr.Threads[0].Resolved)
+	assert.Equal(t, "alice", pr.Threads[0].Comments[0].User)
+}
+
+func TestGetPRDetailsFallback(t *testing.T) {
+	var log strings.Builder
+	Verbose = &log
+	defer func() { Verbose = nil }()
+
+	rest := `{"number": 5, "title": "Over REST", "state": "open"}`
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /graphql":                 {statusCode: http.StatusBadGateway, body: `{"message": "Server Error"}`},
+			"GET /repos/owner/repo/pulls/5": {statusCode: http.StatusOK, body: rest},
+		},
+	}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", token: "test-token", client: &http.Client{Transport: mock}}
+
+	pr, err := client.GetPRDetails(5)
+	require.NoError(t, err)
+	assert.Equal(t, "Over REST", pr.Title)
+	assert.Contains(t, log.String(), "GraphQL is unavailable")
+
+	// A rejected token would fail over REST too, so it's reported as is
+	mock.responses["POST /graphql"] = struct {
+		statusCode int
+		body       string
+	}{http.StatusUnauthorized, `{"message": "Bad credentials"}`}
+	_, err = client.GetPRDetails(5)
+	assert.ErrorContains(t, err, "returned 401")
+}
+
+// graphqlPages answers successive GraphQL requests with one body each
+type graphqlPages struct {
+	bodies   []string
+	requests []string
+}
+
+func (g *graphqlPages) RoundTrip(req *http.Request) (*http.Response, error) {
+	b, _ := io.ReadAll(req.Body)
+	g.requests = append(g.requests, string(b))
+	body := g.bodies[0]
+	g.bodies = g.bodies[1:]
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+}
+
+func TestGetPRDetailsPagesThreads(t *testing.T) {
+	pages := &graphqlPages{bodies: []string{
+		`{"data": {"repository": {"pullRequest": {"number": 3, "state": "OPEN", "reviewThreads": {
+			"pageInfo": {"hasNextPage": true, "endCursor": "c1"},
+			"nodes": [{"id": "T1", "path": "a.go", "comments": {"nodes": []}}]}}}}}`,
+		`{"data": {"repository": {"pullRequest": {"reviewThreads": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [{"id": "T2", "path": "b.go", "comments": {"nodes": []}}]}}}}}`,
+	}}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", token: "test-token", client: &http.Client{Transport: pages}}
+
+	pr, err := client.GetPRDetails(3)
+	require.NoError(t, err)
+	require.Len(t, pr.Threads, 2)
+	assert.Equal(t, "T2", pr.Threads[1].ID)
+	require.Len(t, pages.requests, 2)
+	assert.Contains(t, pages.requests[1], `"after":"c1"`)
+}
+
+func TestMergeOptions(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			// Both queries read their own fields from the same answer
+			"POST /graphql": {
+				statusCode: http.StatusOK,
+				body: `{"data": {"repository": {"autoMergeAllowed": true, "mergeQueue": {"id": "MQ_1"},
+					"pullRequest": {"id": "PR_1", "mergeable": "MERGEABLE", "mergeStateStatus": "BLOCKED", "baseRefName": "main",
+						"autoMergeRequest": null, "mergeQueueEntry": null}}}}`,
+			},
+		},
+	}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", token: "test-token", client: &http.Client{Transport: mock}}
+
+	opts, err := client.GetMergeOptions(4)
+	require.NoError(t, err)
+	assert.True(t, opts.AutoMergeAllowed)
+	assert.False(t, opts.AutoMergeEnabled)
+	assert.True(t, opts.MergeQueue)
+	assert.False(t, opts.Queued)
+	assert.Equal(t, "MERGEABLE", opts.Mergeable)
+	assert.Equal(t, "blocked", opts.MergeState)
+}
+
+func TestEditPR(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /graphql": {
+				statusCode: http.StatusOK,
+				body:       `{"data": {"repository": {"pullRequest": {"id": "PR_1"}}}}`,
+			},
+			"PATCH /repos/owner/repo/pulls/4": {
+				statusCode: http.StatusOK,
+				body:       `{"number": 4}`,
+			},
+			"DELETE /repos/owner/repo/issues/4/labels/needs review": {
+				statusCode: http.StatusOK,
+				body:       `[]`,
+			},
+			"DELETE /repos/owner/repo/pulls/4/requested_reviewers": {
+				statusCode: http.StatusOK,
+				body:       `{"number": 4}`,
+			},
+		},
+	}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", token: "test-token", client: &http.Client{Transport: mock}}
+
+	require.NoError(t, client.SetDraft(4, true))
+	require.NoError(t, client.SetDraft(4, false))
+	require.NoError(t, client.UpdatePR(4, &PullRequest{Title: "New title"}))
+	require.NoError(t, client.RemoveLabel(4, "needs review"))
+	require.NoError(t, client.RemoveReviewers(4, []string{"@alice", "org/core"}))
+	assert.Error(t, client.RemoveLabel(5, "bug"))
+
+	payload := reviewersPayload([]string{"@alice", "org/core"})
+	assert.Equal(t, []string{"alice"}, payload["reviewers"])
+	assert.Equal(t, []string{"core"}, payload["team_reviewers"])
+}
+
 func TestListPRUnresolvedThreads(t *testing.T) {
 	mock := &mockHTTPClient{
 		responses: map[string]struct {
@@ -256,6 +511,341 @@ func TestGetLatestRelease(t *testing.T) {
 	assert.Equal(t, "1.2.3", version)
 }
 
+func TestGetBranchProtection(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/branches/main/protection": {
+				statusCode: http.StatusOK,
+				body: `{
+					"required_status_checks": {"strict": true, "contexts": ["build", "test"]},
+					"required_pull_request_reviews": {"required_approving_review_count": 2, "dismiss_stale_reviews": true},
+					"enforce_admins": {"enabled": false},
+					"required_linear_history": {"enabled": true},
+					"allow_force_pushes": {"enabled": false},
+					"allow_deletions": {"enabled": false}
+				}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	bp, err := client.GetBranchProtection("main")
+	require.NoError(t, err)
+	require.NotNil(t, bp)
+	assert.Equal(t, 2, bp.RequiredReviews)
+	assert.True(t, bp.DismissStaleReviews)
+	assert.Equal(t, []string{"build", "test"}, bp.RequiredChecks)
+	assert.True(t, bp.StrictChecks)
+	assert.True(t, bp.LinearHistory)
+	assert.False(t, bp.AllowForcePushes)
+
+	// Unprotected branches come back as 404
+	bp, err = client.GetBranchProtection("develop")
+	require.NoError(t, err)
+	assert.Nil(t, bp)
+}
+
+func TestListPRCheckRuns(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/pulls/7": {
+				statusCode: http.StatusOK,
+				body:       `{"number": 7, "head": {"ref": "feature", "sha": "abc123"}}`,
+			},
+			"GET /repos/owner/repo/commits/abc123/check-runs?per_page=100&page=1": {
+				statusCode: http.StatusOK,
+				body: `{"check_runs": [
+					{"id": 11, "name": "test", "status": "completed", "conclusion": "failure", "app": {"slug": "github-actions"}},
+					{"id": 12, "name": "lint", "status": "completed", "conclusion": "success", "app": {"slug": "github-actions"}},
+					{"id": 13, "name": "deploy", "status": "completed", "conclusion": "timed_out", "app": {"slug": "vercel"}}
+				]}`,
+			},
+			"GET /repos/owner/repo/actions/jobs/11/logs": {
+				statusCode: http.StatusOK,
+				body:       "2024-01-01T00:00:00.0000000Z ##[error]boom\n",
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	runs, err := client.ListPRCheckRuns(7)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	assert.True(t, runs[0].Failed())
+	assert.True(t, runs[0].FromActions())
+	assert.False(t, runs[1].Failed())
+	assert.True(t, runs[2].Failed())
+	assert.False(t, runs[2].FromActions())
+
+	log, err := client.GetJobLog(runs[0].ID)
+	require.NoError(t, err)
+	assert.Contains(t, log, "##[error]boom")
+}
+
+func TestCreateIssue(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /repos/owner/repo/issues": {
+				statusCode: http.StatusCreated,
+				body:       `{"number": 12, "title": "Unfinished: feature", "state": "open", "html_url": "https://github.com/owner/repo/issues/12"}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	issue, err := client.CreateIssue("Unfinished: feature", "body", []string{"abandoned"})
+	require.NoError(t, err)
+	assert.Equal(t, 12, issue.Number)
+	assert.Equal(t, "open", issue.State)
+	assert.Equal(t, "https://github.com/owner/repo/issues/12", issue.HTMLURL)
+}
+
+func TestReviewerLookups(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/commits/abc123": {
+				statusCode: http.StatusOK,
+				body:       `{"sha": "abc123", "author": {"login": "octocat"}}`,
+			},
+			"GET /repos/owner/repo/commits/def456": {
+				statusCode: http.StatusOK,
+				body:       `{"sha": "def456", "author": null}`,
+			},
+			"GET /repos/owner/repo/collaborators/octocat": {
+				statusCode: http.StatusNoContent,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	login, err := client.CommitAuthorLogin("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "octocat", login)
+
+	login, err = client.CommitAuthorLogin("def456")
+	require.NoError(t, err)
+	assert.Empty(t, login)
+
+	ok, err := client.IsCollaborator("octocat")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = client.IsCollaborator("stranger")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBranchLookups(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/commits/abc123": {
+				statusCode: http.StatusOK,
+				body: `{"sha": "abc123", "author": {"login": "octocat"},
+					"commit": {"message": "Fix login\n\nDetails", "author": {"name": "Mona", "date": "2024-03-01T10:00:00Z"}}}`,
+			},
+			"GET /repos/owner/repo/pulls?state=open&per_page=100&page=1": {
+				statusCode: http.StatusOK,
+				body:       `[{"number": 7, "title": "Fix login", "head": {"ref": "fix/login"}}]`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	commit, err := client.GetCommit("abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "Fix login", commit.Subject)
+	assert.Equal(t, "Mona", commit.AuthorName)
+	assert.Equal(t, "octocat", commit.AuthorLogin)
+	assert.Equal(t, 2024, commit.Date.Year())
+
+	prs, err := client.OpenPRsByHead()
+	require.NoError(t, err)
+	require.Contains(t, prs, "fix/login")
+	assert.Equal(t, 7, prs["fix/login"].Number)
+}
+
+func TestListPRCommits(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/pulls/5/commits?per_page=100&page=1": {
+				statusCode: http.StatusOK,
+				body: `[
+					{"sha": "a1", "commit": {"message": "Add users endpoint\n\nWith pagination.", "author": {"name": "Mona"}}},
+					{"sha": "b2", "commit": {"message": "Fix review comments", "author": {"name": "Mona"}}}
+				]`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	commits, err := client.ListPRCommits(5)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "Add users endpoint", commits[0].Subject)
+	assert.Equal(t, "With pagination.", commits[0].Body)
+	assert.Equal(t, "b2", commits[1].SHA)
+}
+
+func TestPRsForCommit(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/commits/a1/pulls": {
+				statusCode: http.StatusOK,
+				body:       `[{"number": 12, "title": "Add users endpoint", "state": "closed", "merged_at": "2024-03-01T10:00:00Z"}]`,
+			},
+		},
+	}
+	client := &pullRequestAPI{owner: "owner", repo: "repo", client: &http.Client{Transport: mock}}
+
+	prs, err := client.PRsForCommit("a1")
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, 12, prs[0].Number)
+	assert.NotNil(t, prs[0].MergedAt)
+}
+
+func TestReleaseAssets(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /repos/owner/repo/releases/tags/v1.2.3": {
+				statusCode: http.StatusOK,
+				body: `{"id": 7, "tag_name": "v1.2.3",
+					"upload_url": "https://uploads.github.com/repos/owner/repo/releases/7/assets{?name,label}",
+					"assets": [{"id": 70, "name": "sage.tar.gz", "state": "uploaded"}]}`,
+			},
+			"POST /repos/owner/repo/releases/7/assets?name=sage+linux.zip": {
+				statusCode: http.StatusCreated,
+				body:       `{"id": 71, "name": "sage linux.zip", "size": 5, "content_type": "application/zip"}`,
+			},
+			"DELETE /repos/owner/repo/releases/assets/70": {statusCode: http.StatusNoContent},
+			"POST /repos/owner/repo/releases": {
+				statusCode: http.StatusCreated,
+				body:       `{"id": 8, "tag_name": "v1.3.0-rc.1", "html_url": "https://github.com/owner/repo/releases/tag/v1.3.0-rc.1"}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	release, err := client.GetReleaseByTag("v1.2.3")
+	require.NoError(t, err)
+	existing, ok := release.Asset("sage.tar.gz")
+	require.True(t, ok)
+	assert.Equal(t, int64(70), existing.ID)
+	_, ok = release.Asset("missing")
+	assert.False(t, ok)
+
+	require.NoError(t, client.DeleteReleaseAsset(70))
+
+	asset, err := client.UploadReleaseAsset(release, "sage linux.zip", "application/zip", strings.NewReader("hello"), 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(71), asset.ID)
+	assert.Equal(t, "application/zip", asset.ContentType)
+
+	created, err := client.CreateRelease("v1.3.0-rc.1", "v1.3.0-rc.1", "### Added\n\n- Login", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), created.ID)
+	assert.Equal(t, "https://github.com/owner/repo/releases/tag/v1.3.0-rc.1", created.HTMLURL)
+}
+
+func TestSearchPRs(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"GET /search/issues?order=desc&page=2&per_page=50&q=org%3Aacme+author%3Aoctocat+is%3Apr&sort=updated": {
+				statusCode: http.StatusOK,
+				body: `{"total_count": 51, "incomplete_results": false, "items": [
+					{"number": 9, "title": "Fix login", "state": "closed", "user": {"login": "octocat"},
+					 "repository_url": "https://api.github.com/repos/acme/web",
+					 "pull_request": {"merged_at": "2024-01-02T00:00:00Z"}}
+				]}`,
+			},
+		},
+	}
+
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	res, err := client.SearchPRs("org:acme author:octocat", 2, 50)
+	require.NoError(t, err)
+	assert.Equal(t, 51, res.TotalCount)
+	require.Len(t, res.Items, 1)
+	assert.Equal(t, "acme/web", res.Items[0].Repo())
+	assert.True(t, res.Items[0].Merged())
+	assert.Equal(t, "octocat", res.Items[0].User.Login)
+}
+
 func TestNewClient(t *testing.T) {
 	// Save original environment
 	originalOwner := os.Getenv("SAGE_GITHUB_OWNER")
@@ -317,9 +907,151 @@ func TestGetToken(t *testing.T) {
 			os.Setenv("SAGE_GITHUB_TOKEN", tt.sageToken)
 			os.Setenv("GITHUB_TOKEN", tt.githubToken)
 
-			tokenSource := getToken()
+			tokenSource := getToken(repoinfo.NewResolver(repoinfo.Deps{}), repoinfo.Info{})
 			assert.Equal(t, tt.expectedToken, tokenSource.Token)
 			assert.Equal(t, tt.expectedSource, tokenSource.Source)
 		})
 	}
 }
+
+func TestReviewThreads(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /graphql": {
+				statusCode: http.StatusOK,
+				body: `{"data": {"repository": {"pullRequest": {"reviewThreads": {
+					"pageInfo": {"hasNextPage": false},
+					"nodes": [
+						{"id": "T1", "isResolved": false, "path": "main.go", "line": null, "originalLine": 12, "originalStartLine": 10,
+						 "comments": {"nodes": [
+							{"databaseId": 101, "body": "Why?", "createdAt": "2024-01-01T00:00:00Z", "diffHunk": "@@ -10,3 +10,3 @@", "author": {"login": "reviewer", "databaseId": 42, "name": "Rae Viewer"}},
+							{"databaseId": 102, "body": "Because", "createdAt": "2024-01-02T00:00:00Z", "author": null}
+						 ]}},
+						{"id": "T2", "isResolved": true, "path": "b.go", "line": 3, "comments": {"nodes": []}}
+					]}}}}}`,
+			},
+			"POST /repos/owner/repo/pulls/7/comments/101/replies": {statusCode: http.StatusCreated, body: `{"id": 103}`},
+			"POST /repos/owner/repo/pulls/7/reviews":              {statusCode: http.StatusOK, body: `{"id": 1}`},
+		},
+	}
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		token:  "test-token",
+		client: &http.Client{Transport: mock},
+	}
+
+	threads, err := client.ListReviewThreads(7)
+	require.NoError(t, err)
+	require.Len(t, threads, 2)
+	assert.Equal(t, "T1", threads[0].ID)
+	assert.Equal(t, 12, threads[0].Line, "outdated threads fall back to their original line")
+	assert.Equal(t, 10, threads[0].StartLine)
+	assert.Equal(t, "@@ -10,3 +10,3 @@", threads[0].CodeContext)
+	assert.Equal(t, int64(101), threads[0].Comments[0].ID)
+	assert.Equal(t, int64(42), threads[0].Comments[0].UserID)
+	assert.Equal(t, "Rae Viewer", threads[0].Comments[0].UserName)
+	assert.Equal(t, "ghost", threads[0].Comments[1].User)
+	assert.True(t, threads[1].Resolved)
+
+	require.NoError(t, client.ReplyToReviewComment(7, 101, "Fixed"))
+	require.NoError(t, client.SubmitReview(7, ReviewEventApprove, ""))
+	assert.Error(t, client.SubmitReview(7, ReviewEventRequestChanges, " "), "changes requested need a message")
+	assert.Error(t, client.SubmitReview(7, "LGTM", ""))
+}
+
+func TestResolveReviewThread(t *testing.T) {
+	mock := &mockHTTPClient{
+		responses: map[string]struct {
+			statusCode int
+			body       string
+		}{
+			"POST /api/graphql": {
+				statusCode: http.StatusOK,
+				body:       `{"data": null, "errors": [{"message": "Resource not accessible by integration"}]}`,
+			},
+		},
+	}
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		client: &http.Client{Transport: mock},
+		apiURL: "https://github.example.com/api/v3",
+	}
+
+	err := client.ResolveReviewThread("T1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Resource not accessible")
+}
+
+// authRecorder answers every request, keeping the Authorization headers sent
+type authRecorder struct{ auth []string }
+
+func (a *authRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	a.auth = append(a.auth, req.Header.Get("Authorization"))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"login": "sage[bot]"}`)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+func TestRefreshedToken(t *testing.T) {
+	rec := &authRecorder{}
+	current := "ghs_first"
+	client := &pullRequestAPI{
+		owner:   "owner",
+		repo:    "repo",
+		token:   "ghs_first",
+		client:  &http.Client{Transport: rec},
+		refresh: func() string { return current },
+	}
+
+	_, err := client.CurrentUser()
+	assert.NoError(t, err)
+	current = "ghs_second"
+	_, err = client.CurrentUser()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"token ghs_first", "token ghs_second"}, rec.auth)
+}
+
+// quotaTransport answers every request with the given rate limit headers
+type quotaTransport struct{ remaining string }
+
+func (q quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := make(http.Header)
+	h.Set("X-RateLimit-Limit", "5000")
+	h.Set("X-RateLimit-Remaining", q.remaining)
+	h.Set("X-RateLimit-Reset", "1767225600")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`[{"state": "APPROVED", "user": {"login": "alice"}}]`)),
+		Header:     h,
+	}, nil
+}
+
+func TestLastRateLimit(t *testing.T) {
+	client := &pullRequestAPI{
+		owner:  "owner",
+		repo:   "repo",
+		client: &http.Client{Transport: quotaTransport{remaining: "4321"}},
+	}
+	reviews, err := client.ListPRReviews(7)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, "alice", reviews[0].User.Login)
+
+	rl, ok := LastRateLimit()
+	require.True(t, ok)
+	assert.Equal(t, 5000, rl.Limit)
+	assert.Equal(t, 4321, rl.Remaining)
+	assert.Equal(t, int64(1767225600), rl.Reset.Unix())
+
+	// Responses without the headers leave the last known quota alone
+	_, _ = (&pullRequestAPI{owner: "owner", repo: "repo", client: &http.Client{Transport: &authRecorder{}}}).CurrentUser()
+	rl, _ = LastRateLimit()
+	assert.Equal(t, 4321, rl.Remaining)
+}
@@ -0,0 +1,241 @@
+package gh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const prDetailsQuery = `query PRDetails($owner: String!, $repo: String!, $num: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $num) {
+      number title body state url isDraft merged mergedAt updatedAt
+      author { login }
+      headRefName headRefOid baseRefName mergeable mergeStateStatus
+      reviewRequests(first: 50) { nodes { requestedReviewer { ... on User { login } } } }
+      labels(first: 50) { nodes { name } }
+      reviews(first: 100) { nodes { state body author { login } } }
+      commits(first: 100) {
+        nodes { commit { oid message committedDate author { user { login } } } }
+      }
+      headCommit: commits(last: 1) {
+        nodes { commit { statusCheckRollup { contexts(first: 100) { nodes {
+          __typename
+          ... on CheckRun { name status conclusion detailsUrl startedAt completedAt }
+          ... on StatusContext { context state targetUrl createdAt }
+        } } } } }
+      }
+      reviewThreads(first: 100) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          ` + reviewThreadFields + `
+        }
+      }
+    }
+  }
+}`
+
+// prDetailsNode is a pull request as prDetailsQuery returns it
+type prDetailsNode struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	State     string     `json:"state"` // OPEN, CLOSED or MERGED
+	URL       string     `json:"url"`
+	IsDraft   bool       `json:"isDraft"`
+	Merged    bool       `json:"merged"`
+	MergedAt  *time.Time `json:"mergedAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	Author    *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	HeadRefName string `json:"headRefName"`
+	HeadRefOid  string `json:"headRefOid"`
+	BaseRefName string `json:"baseRefName"`
+	Mergeable   string `json:"mergeable"` // MERGEABLE, CONFLICTING or UNKNOWN
+	MergeState  string `json:"mergeStateStatus"`
+	Requests    struct {
+		Nodes []struct {
+			RequestedReviewer *Account `json:"requestedReviewer"`
+		} `json:"nodes"`
+	} `json:"reviewRequests"`
+	Labels struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+	Reviews struct {
+		Nodes []struct {
+			State  string `json:"state"`
+			Body   string `json:"body"`
+			Author *struct {
+				Login string `json:"login"`
+			} `json:"author"`
+		} `json:"nodes"`
+	} `json:"reviews"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				OID           string    `json:"oid"`
+				Message       string    `json:"message"`
+				CommittedDate time.Time `json:"committedDate"`
+				Author        struct {
+					User *struct {
+						Login string `json:"login"`
+					} `json:"user"`
+				} `json:"author"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+	HeadCommit struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					Contexts struct {
+						Nodes []checkContextNode `json:"nodes"`
+					} `json:"contexts"`
+				} `json:"statusCheckRollup"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"headCommit"`
+	ReviewThreads struct {
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Nodes []reviewThreadNode `json:"nodes"`
+	} `json:"reviewThreads"`
+}
+
+// checkContextNode is a check run or a commit status on the head commit
+type checkContextNode struct {
+	Typename string `json:"__typename"`
+
+	// CheckRun
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Conclusion  string     `json:"conclusion"`
+	DetailsURL  string     `json:"detailsUrl"`
+	StartedAt   *time.Time `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+
+	// StatusContext
+	Context   string     `json:"context"`
+	State     string     `json:"state"`
+	TargetURL string     `json:"targetUrl"`
+	CreatedAt *time.Time `json:"createdAt"`
+}
+
+// check returns the context in the shape of a REST check run
+func (n checkContextNode) check() Check {
+	if n.Typename != "StatusContext" {
+		return Check{
+			Name:        n.Name,
+			Status:      strings.ToLower(n.Status),
+			Conclusion:  strings.ToLower(n.Conclusion),
+			HTMLURL:     n.DetailsURL,
+			StartedAt:   n.StartedAt,
+			CompletedAt: n.CompletedAt,
+		}
+	}
+	c := Check{Name: n.Context, HTMLURL: n.TargetURL, StartedAt: n.CreatedAt, Status: "completed"}
+	switch n.State {
+	case "PENDING", "EXPECTED":
+		c.Status = "in_progress"
+	case "SUCCESS":
+		c.Conclusion = "success"
+	default:
+		c.Conclusion = "failure"
+	}
+	return c
+}
+
+// prDetailsGraphQL fetches what prDetailsREST does, and the review threads,
+// in a single request. Threads past the first hundred are paged in after.
+func (p *pullRequestAPI) prDetailsGraphQL(num int) (*PullRequest, error) {
+	var data struct {
+		Repository struct {
+			PullRequest *prDetailsNode `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	vars := map[string]any{"owner": p.owner, "repo": p.repo, "num": num}
+	if err := p.graphql(prDetailsQuery, vars, &data); err != nil {
+		return nil, err
+	}
+	node := data.Repository.PullRequest
+	if node == nil {
+		return nil, fmt.Errorf("pull request #%d not found", num)
+	}
+	pr := node.pullRequest()
+	if page := node.ReviewThreads.PageInfo; page.HasNextPage {
+		rest, err := p.reviewThreadsAfter(num, page.EndCursor)
+		if err != nil {
+			return nil, err
+		}
+		pr.Threads = append(pr.Threads, rest...)
+	}
+	return pr, nil
+}
+
+// graphqlUnavailable matches failures of the GraphQL API itself, rather than
+// of the request: a missing or failing endpoint, as behind some proxies and
+// GitHub Enterprise servers, a server whose schema predates the query, or
+// GraphQL's own rate limit, which REST doesn't share
+var graphqlUnavailable = regexp.MustCompile(`returned (404|410|5\d\d)|doesn't exist on type|rate limit`)
+
+// pullRequest converts the node to the REST shape the rest of sage reads
+func (n *prDetailsNode) pullRequest() *PullRequest {
+	pr := &PullRequest{
+		Number:    n.Number,
+		Title:     n.Title,
+		Body:      n.Body,
+		State:     "open",
+		HTMLURL:   n.URL,
+		Draft:     n.IsDraft,
+		Merged:    n.Merged,
+		MergedAt:  n.MergedAt,
+		UpdatedAt: n.UpdatedAt,
+		Labels:    n.Labels.Nodes,
+	}
+	if n.State != "OPEN" {
+		pr.State = "closed"
+	}
+	if n.Author != nil {
+		pr.User.Login = n.Author.Login
+	}
+	pr.Head.Ref, pr.Head.SHA, pr.Base.Ref = n.HeadRefName, n.HeadRefOid, n.BaseRefName
+	if n.Mergeable != "UNKNOWN" && n.Mergeable != "" {
+		mergeable := n.Mergeable == "MERGEABLE"
+		pr.Mergeable = &mergeable
+	}
+	pr.MergeableState = strings.ToLower(n.MergeState)
+	for _, r := range n.Requests.Nodes {
+		// Teams have no login and are left out
+		if r.RequestedReviewer != nil && r.RequestedReviewer.Login != "" {
+			pr.RequestedReviewers = append(pr.RequestedReviewers, Account{Login: r.RequestedReviewer.Login})
+		}
+	}
+
+	for _, r := range n.Reviews.Nodes {
+		review := Review{State: r.State, Body: r.Body}
+		if r.Author != nil {
+			review.User.Login = r.Author.Login
+		}
+		pr.Reviews = append(pr.Reviews, review)
+	}
+	if len(n.HeadCommit.Nodes) > 0 && n.HeadCommit.Nodes[0].Commit.StatusCheckRollup != nil {
+		for _, c := range n.HeadCommit.Nodes[0].Commit.StatusCheckRollup.Contexts.Nodes {
+			pr.Checks = append(pr.Checks, c.check())
+		}
+	}
+	for _, c := range n.Commits.Nodes {
+		e := TimelineEvent{Event: "committed", CreatedAt: c.Commit.CommittedDate, Message: c.Commit.Message, SHA: c.Commit.OID}
+		if c.Commit.Author.User != nil {
+			e.Actor.Login = c.Commit.Author.User.Login
+		}
+		pr.Timeline = append(pr.Timeline, e)
+	}
+	for _, t := range n.ReviewThreads.Nodes {
+		pr.Threads = append(pr.Threads, t.thread())
+	}
+	return pr
+}
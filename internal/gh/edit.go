@@ -0,0 +1,47 @@
+package gh
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const convertToDraftMutation = `mutation ConvertToDraft($id: ID!) {
+  convertPullRequestToDraft(input: {pullRequestId: $id}) {
+    clientMutationId
+  }
+}`
+
+const markReadyMutation = `mutation MarkReady($id: ID!) {
+  markPullRequestReadyForReview(input: {pullRequestId: $id}) {
+    clientMutationId
+  }
+}`
+
+// SetDraft converts the PR to a draft, or marks it ready for review. The
+// REST API ignores draft on updates, so this goes through GraphQL.
+func (p *pullRequestAPI) SetDraft(num int, draft bool) error {
+	node, _, err := p.mergeNode(num)
+	if err != nil {
+		return err
+	}
+	mutation := markReadyMutation
+	if draft {
+		mutation = convertToDraftMutation
+	}
+	return p.graphql(mutation, map[string]any{"id": node.ID}, nil)
+}
+
+// RemoveLabel takes a label off the PR
+func (p *pullRequestAPI) RemoveLabel(prNumber int, label string) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels/%s", p.api(), p.owner, p.repo, prNumber, url.PathEscape(label))
+	_, err := p.do("DELETE", u, nil)
+	return err
+}
+
+// RemoveReviewers withdraws review requests from users, and teams given
+// as org/team
+func (p *pullRequestAPI) RemoveReviewers(prNumber int, reviewers []string) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/requested_reviewers", p.api(), p.owner, p.repo, prNumber)
+	_, err := p.do("DELETE", u, reviewersPayload(reviewers))
+	return err
+}
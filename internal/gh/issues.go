@@ -0,0 +1,36 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Issue is a GitHub issue
+type Issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateIssue opens an issue in the repository
+func (p *pullRequestAPI) CreateIssue(title, body string, labels []string) (*Issue, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/issues", p.api(), p.owner, p.repo)
+	payload := map[string]any{
+		"title": title,
+		"body":  body,
+	}
+	if len(labels) > 0 {
+		payload["labels"] = labels
+	}
+	data, err := p.do("POST", u, payload)
+	if err != nil {
+		return nil, err
+	}
+	var issue Issue
+	if e := json.Unmarshal(data, &issue); e != nil {
+		return nil, e
+	}
+	return &issue, nil
+}
@@ -0,0 +1,115 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BranchProtection is the subset of GitHub branch protection sage manages.
+// The yaml tags define the format of .sage/protection.yaml.
+type BranchProtection struct {
+	RequiredReviews         int      `yaml:"required_reviews"`
+	DismissStaleReviews     bool     `yaml:"dismiss_stale_reviews"`
+	RequireCodeOwnerReviews bool     `yaml:"require_code_owner_reviews"`
+	RequiredChecks          []string `yaml:"required_checks"`
+	StrictChecks            bool     `yaml:"strict_checks"` // branch must be up to date before merging
+	EnforceAdmins           bool     `yaml:"enforce_admins"`
+	LinearHistory           bool     `yaml:"linear_history"`
+	AllowForcePushes        bool     `yaml:"allow_force_pushes"`
+	AllowDeletions          bool     `yaml:"allow_deletions"`
+}
+
+// protectionAPI mirrors the shape returned by GET .../branches/:branch/protection
+type protectionAPI struct {
+	RequiredStatusChecks *struct {
+		Strict   bool     `json:"strict"`
+		Contexts []string `json:"contexts"`
+	} `json:"required_status_checks"`
+	RequiredPullRequestReviews *struct {
+		RequiredApprovingReviewCount int  `json:"required_approving_review_count"`
+		DismissStaleReviews          bool `json:"dismiss_stale_reviews"`
+		RequireCodeOwnerReviews      bool `json:"require_code_owner_reviews"`
+	} `json:"required_pull_request_reviews"`
+	EnforceAdmins         enabledFlag `json:"enforce_admins"`
+	RequiredLinearHistory enabledFlag `json:"required_linear_history"`
+	AllowForcePushes      enabledFlag `json:"allow_force_pushes"`
+	AllowDeletions        enabledFlag `json:"allow_deletions"`
+}
+
+type enabledFlag struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (p *pullRequestAPI) protectionURL(branch string) string {
+	return fmt.Sprintf("%s/repos/%s/%s/branches/%s/protection", p.api(), p.owner, p.repo, url.PathEscape(branch))
+}
+
+// GetBranchProtection does GET /repos/:owner/:repo/branches/:branch/protection.
+// It returns nil without an error when the branch is not protected.
+func (p *pullRequestAPI) GetBranchProtection(branch string) (*BranchProtection, error) {
+	data, err := p.do("GET", p.protectionURL(branch), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "returned 404") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var raw protectionAPI
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	bp := &BranchProtection{
+		EnforceAdmins:    raw.EnforceAdmins.Enabled,
+		LinearHistory:    raw.RequiredLinearHistory.Enabled,
+		AllowForcePushes: raw.AllowForcePushes.Enabled,
+		AllowDeletions:   raw.AllowDeletions.Enabled,
+	}
+	if r := raw.RequiredPullRequestReviews; r != nil {
+		bp.RequiredReviews = r.RequiredApprovingReviewCount
+		bp.DismissStaleReviews = r.DismissStaleReviews
+		bp.RequireCodeOwnerReviews = r.RequireCodeOwnerReviews
+	}
+	if c := raw.RequiredStatusChecks; c != nil {
+		bp.RequiredChecks = c.Contexts
+		bp.StrictChecks = c.Strict
+	}
+	return bp, nil
+}
+
+// UpdateBranchProtection does PUT /repos/:owner/:repo/branches/:branch/protection,
+// replacing the branch's protection with bp. Push restrictions are left unset.
+func (p *pullRequestAPI) UpdateBranchProtection(branch string, bp *BranchProtection) error {
+	payload := map[string]any{
+		"enforce_admins":                bp.EnforceAdmins,
+		"required_linear_history":       bp.LinearHistory,
+		"allow_force_pushes":            bp.AllowForcePushes,
+		"allow_deletions":               bp.AllowDeletions,
+		"restrictions":                  nil,
+		"required_status_checks":        nil,
+		"required_pull_request_reviews": nil,
+	}
+	if len(bp.RequiredChecks) > 0 || bp.StrictChecks {
+		checks := bp.RequiredChecks
+		if checks == nil {
+			checks = []string{}
+		}
+		payload["required_status_checks"] = map[string]any{
+			"strict":   bp.StrictChecks,
+			"contexts": checks,
+		}
+	}
+	if bp.RequiredReviews > 0 || bp.DismissStaleReviews || bp.RequireCodeOwnerReviews {
+		payload["required_pull_request_reviews"] = map[string]any{
+			"required_approving_review_count": bp.RequiredReviews,
+			"dismiss_stale_reviews":           bp.DismissStaleReviews,
+			"require_code_owner_reviews":      bp.RequireCodeOwnerReviews,
+		}
+	}
+
+	_, err := p.do("PUT", p.protectionURL(branch), payload)
+	return err
+}
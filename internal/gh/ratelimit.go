@@ -0,0 +1,64 @@
+package gh
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is GitHub's API quota, as its last response reported it
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time // when Remaining goes back up to Limit
+}
+
+var (
+	rateMu   sync.Mutex
+	lastRate *RateLimit
+)
+
+// LastRateLimit returns the quota reported by the latest API response, and
+// false before any response carried one
+func LastRateLimit() (RateLimit, bool) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	if lastRate == nil {
+		return RateLimit{}, false
+	}
+	return *lastRate, true
+}
+
+// recordRateLimit keeps the X-RateLimit headers of a response
+func recordRateLimit(h http.Header) {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	lastRate = &RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+}
+
+// rateLimitError explains a request GitHub turned away for going over the
+// rate limit, once retrying has given up
+func rateLimitError(h http.Header) error {
+	if s, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		return fmt.Errorf("GitHub's API rate limit was reached; try again in %s", time.Duration(s)*time.Second)
+	}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		at := time.Unix(reset, 0)
+		return fmt.Errorf("GitHub's API rate limit was reached; it resets at %s (in %s)", at.Format("15:04"), time.Until(at).Round(time.Minute))
+	}
+	return fmt.Errorf("GitHub's API rate limit was reached; try again later")
+}
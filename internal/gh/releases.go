@@ -0,0 +1,133 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+// uploadsURL is where github.com accepts release asset uploads
+const uploadsURL = "https://uploads.github.com"
+
+// Release is a GitHub release
+type Release struct {
+	ID        int64          `json:"id"`
+	TagName   string         `json:"tag_name"`
+	Name      string         `json:"name"`
+	HTMLURL   string         `json:"html_url"`
+	UploadURL string         `json:"upload_url"` // URI template, e.g. .../assets{?name,label}
+	Assets    []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	ContentType        string `json:"content_type"`
+	State              string `json:"state"` // "uploaded", or "starter" for an upload that never finished
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Asset returns the release's asset called name, if it has one
+func (r *Release) Asset(name string) (ReleaseAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// GetReleaseByTag returns the release for tag, including drafts the token can see
+func (p *pullRequestAPI) GetReleaseByTag(tag string) (*Release, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", p.api(), p.owner, p.repo, url.PathEscape(tag))
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var release Release
+	if e := json.Unmarshal(data, &release); e != nil {
+		return nil, e
+	}
+	return &release, nil
+}
+
+// CreateRelease publishes a release for tag, which must already be on GitHub
+func (p *pullRequestAPI) CreateRelease(tag, name, body string, prerelease bool) (*Release, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/releases", p.api(), p.owner, p.repo)
+	payload := map[string]any{
+		"tag_name":   tag,
+		"name":       name,
+		"body":       body,
+		"prerelease": prerelease,
+	}
+	data, err := p.do("POST", u, payload)
+	if err != nil {
+		return nil, err
+	}
+	var release Release
+	if e := json.Unmarshal(data, &release); e != nil {
+		return nil, e
+	}
+	return &release, nil
+}
+
+// DeleteReleaseAsset removes an asset from its release
+func (p *pullRequestAPI) DeleteReleaseAsset(id int64) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", p.api(), p.owner, p.repo, id)
+	_, err := p.do("DELETE", u, nil)
+	return err
+}
+
+// UploadReleaseAsset streams size bytes from body to the release as an asset
+// called name. GitHub refuses a name the release already has, so replacing an
+// asset means deleting it first.
+func (p *pullRequestAPI) UploadReleaseAsset(release *Release, name, contentType string, body io.Reader, size int64) (*ReleaseAsset, error) {
+	base := release.UploadURL
+	if i := strings.Index(base, "{"); i >= 0 {
+		base = base[:i]
+	}
+	if base == "" {
+		base = fmt.Sprintf("%s/repos/%s/%s/releases/%d/assets", uploadsURL, p.owner, p.repo, release.ID)
+	}
+	u := base + "?name=" + url.QueryEscape(name)
+
+	if dryrun.Enabled() {
+		dryrun.Record("GitHub API POST %s (%d bytes, %s)", u, size, contentType)
+		return &ReleaseAsset{Name: name, Size: size, ContentType: contentType}, nil
+	}
+
+	req, err := http.NewRequest("POST", u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", "token "+p.authToken())
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, exitcode.New(StatusExitCode(resp.StatusCode), fmt.Errorf("GitHub API POST %s returned %d:\n%s", u, resp.StatusCode, string(data)))
+	}
+	var asset ReleaseAsset
+	if e := json.Unmarshal(data, &asset); e != nil {
+		return nil, e
+	}
+	return &asset, nil
+}
@@ -0,0 +1,58 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/repoinfo"
+)
+
+// Repository is the subset of a GitHub repository sage needs after creating one
+type Repository struct {
+	FullName      string `json:"full_name"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+}
+
+// CreateRepository creates an empty repository on github.com. name is either
+// "repo" (created under the authenticated user) or "org/repo".
+//
+// Unlike NewClient this does not need an existing remote, so it can be used to
+// publish a repository that was just created with git init.
+func CreateRepository(name string, private bool) (*Repository, error) {
+	// Organisations may belong to another of the user's accounts
+	owner := ""
+	if org, _, ok := strings.Cut(name, "/"); ok {
+		owner = org
+	}
+	token := repoinfo.Default().TokenFor(repoinfo.DefaultHost, owner)
+	if token.Value == "" {
+		return nil, fmt.Errorf("GitHub token not found; set SAGE_GITHUB_TOKEN or GITHUB_TOKEN, or run 'gh auth login'")
+	}
+
+	p := &pullRequestAPI{token: token.Value, client: &http.Client{Transport: newTransport(nil)}}
+
+	url := p.api() + "/user/repos"
+	if org, repo, ok := strings.Cut(name, "/"); ok {
+		url = fmt.Sprintf("%s/orgs/%s/repos", p.api(), org)
+		name = repo
+	}
+
+	data, err := p.do("POST", url, map[string]any{
+		"name":    name,
+		"private": private,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var r Repository
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
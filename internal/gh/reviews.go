@@ -0,0 +1,231 @@
+package gh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+)
+
+// Review events accepted by SubmitReview
+const (
+	ReviewEventApprove        = "APPROVE"
+	ReviewEventRequestChanges = "REQUEST_CHANGES"
+	ReviewEventComment        = "COMMENT"
+)
+
+// ReviewThread is a review conversation on a line of a pull request
+type ReviewThread struct {
+	ID          string // GraphQL node ID, for resolving
+	Path        string
+	Line        int
+	StartLine   int // first line of a comment on several lines, 0 for one line
+	Resolved    bool
+	Outdated    bool
+	CodeContext string // diff hunk the thread started on
+	Comments    []ReviewComment
+}
+
+// ReviewComment is one comment in a review thread
+type ReviewComment struct {
+	ID   int64 // REST ID, for replying
+	User string
+	Body string
+	Time time.Time
+
+	// The author's account ID and display name, for crediting them as a
+	// co-author; 0 and "" for deleted accounts and apps
+	UserID   int64
+	UserName string
+}
+
+// graphqlURL is the GraphQL endpoint next to the REST API: api.github.com
+// serves it at /graphql, GitHub Enterprise at /api/graphql
+func (p *pullRequestAPI) graphqlURL() string {
+	return strings.TrimSuffix(p.api(), "/v3") + "/graphql"
+}
+
+// graphql runs a query or mutation and decodes its data into out
+func (p *pullRequestAPI) graphql(query string, vars map[string]any, out any) error {
+	// Mutations are writes, whatever the HTTP method
+	if dryrun.Enabled() && strings.HasPrefix(strings.TrimSpace(query), "mutation") {
+		b, _ := json.Marshal(vars)
+		dryrun.Record("GitHub GraphQL %s %s", strings.Fields(query)[1], b)
+		return nil
+	}
+	payload, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	data, err := p.send("POST", p.graphqlURL(), bytes.NewReader(payload), true)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("GitHub GraphQL: %s", strings.Join(msgs, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+// reviewThreadFields are the fields of a review thread reviewThreadNode reads
+const reviewThreadFields = `id isResolved isOutdated path line originalLine startLine originalStartLine
+          comments(first: 100) {
+            nodes { databaseId body createdAt diffHunk author { login ... on User { databaseId name } } }
+          }`
+
+const reviewThreadsQuery = `query ReviewThreads($owner: String!, $repo: String!, $num: Int!, $after: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $num) {
+      reviewThreads(first: 50, after: $after) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          ` + reviewThreadFields + `
+        }
+      }
+    }
+  }
+}`
+
+// reviewThreadNode is a review thread as GraphQL returns it
+type reviewThreadNode struct {
+	ID                string `json:"id"`
+	IsResolved        bool   `json:"isResolved"`
+	IsOutdated        bool   `json:"isOutdated"`
+	Path              string `json:"path"`
+	Line              int    `json:"line"`
+	OriginalLine      int    `json:"originalLine"`
+	StartLine         int    `json:"startLine"`
+	OriginalStartLine int    `json:"originalStartLine"`
+	Comments          struct {
+		Nodes []struct {
+			DatabaseID int64     `json:"databaseId"`
+			Body       string    `json:"body"`
+			CreatedAt  time.Time `json:"createdAt"`
+			DiffHunk   string    `json:"diffHunk"`
+			Author     *struct {
+				Login      string `json:"login"`
+				DatabaseID int64  `json:"databaseId"`
+				Name       string `json:"name"`
+			} `json:"author"`
+		} `json:"nodes"`
+	} `json:"comments"`
+}
+
+func (n reviewThreadNode) thread() ReviewThread {
+	t := ReviewThread{ID: n.ID, Path: n.Path, Line: n.Line, StartLine: n.StartLine, Resolved: n.IsResolved, Outdated: n.IsOutdated}
+	if t.Line == 0 {
+		t.Line, t.StartLine = n.OriginalLine, n.OriginalStartLine
+	}
+	for i, c := range n.Comments.Nodes {
+		if i == 0 {
+			t.CodeContext = c.DiffHunk
+		}
+		comment := ReviewComment{ID: c.DatabaseID, User: "ghost", Body: c.Body, Time: c.CreatedAt} // deleted accounts have no author
+		if c.Author != nil {
+			comment.User, comment.UserID, comment.UserName = c.Author.Login, c.Author.DatabaseID, c.Author.Name
+		}
+		t.Comments = append(t.Comments, comment)
+	}
+	return t
+}
+
+// ListReviewThreads returns the review threads of a pull request, resolved
+// ones included, in the order they were started
+func (p *pullRequestAPI) ListReviewThreads(num int) ([]ReviewThread, error) {
+	return p.reviewThreadsAfter(num, "")
+}
+
+// reviewThreadsAfter pages through the PR's review threads from the cursor
+// after, or from the start when it's empty
+func (p *pullRequestAPI) reviewThreadsAfter(num int, after string) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	vars := map[string]any{"owner": p.owner, "repo": p.repo, "num": num}
+	if after != "" {
+		vars["after"] = after
+	}
+	for page := 0; page < 20; page++ {
+		var data struct {
+			Repository struct {
+				PullRequest *struct {
+					ReviewThreads struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []reviewThreadNode `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		if err := p.graphql(reviewThreadsQuery, vars, &data); err != nil {
+			return nil, err
+		}
+		pr := data.Repository.PullRequest
+		if pr == nil {
+			return nil, fmt.Errorf("pull request #%d not found", num)
+		}
+		for _, n := range pr.ReviewThreads.Nodes {
+			threads = append(threads, n.thread())
+		}
+		if !pr.ReviewThreads.PageInfo.HasNextPage {
+			break
+		}
+		vars["after"] = pr.ReviewThreads.PageInfo.EndCursor
+	}
+	return threads, nil
+}
+
+// ReplyToReviewComment answers the thread commentID belongs to
+func (p *pullRequestAPI) ReplyToReviewComment(num int, commentID int64, body string) error {
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/comments/%d/replies", p.api(), p.owner, p.repo, num, commentID)
+	_, err := p.do("POST", u, map[string]string{"body": body})
+	return err
+}
+
+const resolveThreadMutation = `mutation ResolveThread($id: ID!) {
+  resolveReviewThread(input: {threadId: $id}) { thread { isResolved } }
+}`
+
+// ResolveReviewThread marks a review thread resolved
+func (p *pullRequestAPI) ResolveReviewThread(threadID string) error {
+	return p.graphql(resolveThreadMutation, map[string]any{"id": threadID}, nil)
+}
+
+// SubmitReview reviews a pull request with one of the Review* events. body
+// is required for changes requested and plain comments.
+func (p *pullRequestAPI) SubmitReview(num int, event, body string) error {
+	switch event {
+	case ReviewEventApprove, ReviewEventRequestChanges, ReviewEventComment:
+	default:
+		return fmt.Errorf("unknown review event %q", event)
+	}
+	if event != ReviewEventApprove && strings.TrimSpace(body) == "" {
+		return fmt.Errorf("a review that requests changes or comments needs a message")
+	}
+	payload := map[string]string{"event": event}
+	if body != "" {
+		payload["body"] = body
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/reviews", p.api(), p.owner, p.repo, num)
+	_, err := p.do("POST", u, payload)
+	return err
+}
@@ -0,0 +1,73 @@
+package gh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PRSearchItem is a pull request found by SearchPRs. The search API returns
+// pull requests as issues, so the repository comes as a URL.
+type PRSearchItem struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	RepositoryURL string    `json:"repository_url"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	PullRequest   struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+// Repo returns the owner/name of the item's repository
+func (i PRSearchItem) Repo() string {
+	parts := strings.Split(strings.TrimSuffix(i.RepositoryURL, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// Merged reports whether the pull request was merged
+func (i PRSearchItem) Merged() bool {
+	return i.PullRequest.MergedAt != nil
+}
+
+// PRSearchResult is one page of search results
+type PRSearchResult struct {
+	TotalCount int            `json:"total_count"`
+	Incomplete bool           `json:"incomplete_results"`
+	Items      []PRSearchItem `json:"items"`
+}
+
+// SearchPRs runs a GitHub issue search restricted to pull requests and
+// returns one page of results, most recently updated first
+func (p *pullRequestAPI) SearchPRs(query string, page, perPage int) (*PRSearchResult, error) {
+	q := url.Values{}
+	q.Set("q", strings.TrimSpace(query+" is:pr"))
+	q.Set("sort", "updated")
+	q.Set("order", "desc")
+	q.Set("per_page", fmt.Sprint(perPage))
+	q.Set("page", fmt.Sprint(page))
+	u := fmt.Sprintf("%s/search/issues?%s", p.api(), q.Encode())
+	data, err := p.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res PRSearchResult
+	if e := json.Unmarshal(data, &res); e != nil {
+		return nil, e
+	}
+	return &res, nil
+}
@@ -0,0 +1,246 @@
+package gh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verbose, when set, gets a line for every API response with the quota
+// left, and for every retry
+var Verbose io.Writer
+
+const (
+	// maxAttempts is how many times a request is sent before giving up
+	maxAttempts = 4
+	// maxRetryWait is the longest sage waits for a rate limit to lift; a
+	// quota that resets later than this fails the request instead
+	maxRetryWait = time.Minute
+	// retryBase is the first backoff; each retry doubles it
+	retryBase = time.Second
+	// maxCached is how many GET responses are kept for conditional requests
+	maxCached = 256
+	// maxCachedSize is the largest body kept for conditional requests
+	maxCachedSize = 1 << 20
+)
+
+// sleep waits between attempts; a variable so tests don't have to
+var sleep = time.Sleep
+
+// retryTransport sends GitHub API requests again when GitHub asks to slow
+// down or fails on its side, and revalidates repeated GETs with their ETag
+// so unchanged responses cost no quota
+type retryTransport struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// newTransport wraps base, http.DefaultTransport when nil
+func newTransport(base http.RoundTripper) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{base: base, cache: map[string]cachedResponse{}}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String() + " " + req.Header.Get("Authorization")
+	cached, haveCached := t.cached(req, key)
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 || haveCached {
+			var err error
+			if r, err = rewind(req); err != nil {
+				return nil, err
+			}
+			if haveCached {
+				r.Header.Set("If-None-Match", cached.etag)
+			}
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			if attempt < maxAttempts && idempotent(req.Method) && replayable(req) {
+				wait := backoff(attempt)
+				logf("GitHub API %s %s failed (%v); retrying in %s", req.Method, req.URL, err, wait)
+				sleep(wait)
+				continue
+			}
+			return nil, err
+		}
+		logf("GitHub API %s %s: %d%s", req.Method, req.URL, resp.StatusCode, quotaNote(resp.Header))
+
+		if resp.StatusCode == http.StatusNotModified && haveCached {
+			resp.Body.Close()
+			return cached.response(req, resp.Header), nil
+		}
+
+		wait, retry := retryAfter(resp, req.Method, attempt)
+		if retry && attempt < maxAttempts && replayable(req) {
+			resp.Body.Close()
+			logf("GitHub API %s %s: retrying in %s", req.Method, req.URL, wait.Round(time.Second))
+			sleep(wait)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return t.store(req, key, resp)
+		}
+		return resp, nil
+	}
+}
+
+// cached returns the response to revalidate req with
+func (t *retryTransport) cached(req *http.Request, key string) (cachedResponse, bool) {
+	if req.Method != "GET" {
+		return cachedResponse{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.cache[key]
+	return c, ok
+}
+
+// store keeps a GET response that has an ETag, and hands back an
+// equivalent one since reading the body uses it up
+func (t *retryTransport) store(req *http.Request, key string, resp *http.Response) (*http.Response, error) {
+	etag := resp.Header.Get("ETag")
+	if req.Method != "GET" || etag == "" || resp.ContentLength > maxCachedSize {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedSize+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxCachedSize {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.cache) >= maxCached {
+		clear(t.cache)
+	}
+	t.cache[key] = cachedResponse{etag: etag, header: resp.Header.Clone(), body: body}
+	return resp, nil
+}
+
+// response rebuilds a cached response, with the quota headers of the 304
+// that confirmed it
+func (c cachedResponse) response(req *http.Request, fresh http.Header) *http.Response {
+	h := c.header.Clone()
+	for k, v := range fresh {
+		if strings.HasPrefix(k, "X-Ratelimit-") {
+			h[k] = v
+		}
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// retryAfter reports whether resp is worth sending again, and how long to
+// wait first. Rate limits are retried for any method, since GitHub didn't
+// act on the request; server errors only for methods safe to repeat.
+func retryAfter(resp *http.Response, method string, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusForbidden && rateLimited(resp)):
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait := time.Duration(s) * time.Second
+			return wait, wait <= maxRetryWait
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			wait := time.Until(time.Unix(reset, 0)) + time.Second
+			return max(wait, time.Second), wait <= maxRetryWait
+		}
+		// Secondary limits without a Retry-After: GitHub asks for a minute
+		return backoff(attempt + 2), true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return backoff(attempt), idempotent(method)
+	}
+	return 0, false
+}
+
+// rateLimited reports whether a 403 is GitHub slowing sage down rather than
+// refusing it. Only the headers are looked at; the body is left for the
+// caller's error message.
+func rateLimited(resp *http.Response) bool {
+	return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+// backoff is the wait before the attempt after attempt: exponential, with
+// jitter so parallel requests don't retry in lockstep
+func backoff(attempt int) time.Duration {
+	d := retryBase << (attempt - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// replayable reports whether req's body can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind copies req with a fresh body for another attempt
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// quotaNote describes the quota a response reports, for Verbose
+func quotaNote(h http.Header) string {
+	remaining, limit := h.Get("X-RateLimit-Remaining"), h.Get("X-RateLimit-Limit")
+	if remaining == "" || limit == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s/%s requests left)", remaining, limit)
+}
+
+func logf(format string, args ...any) {
+	if Verbose != nil {
+		fmt.Fprintf(Verbose, format+"\n", args...)
+	}
+}
@@ -0,0 +1,138 @@
+package gh
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noSleep records the waits between attempts instead of waiting
+func noSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+func TestTransportRetriesRateLimits(t *testing.T) {
+	waits := noSleep(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"title":"x"}`, string(body))
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"You have exceeded a secondary rate limit"}`)
+			return
+		}
+		io.WriteString(w, `{"number":5}`)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newTransport(nil)}
+	resp, err := client.Post(srv.URL, "application/json", bytes.NewReader([]byte(`{"title":"x"}`)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []time.Duration{3 * time.Second}, *waits)
+}
+
+func TestTransportServerErrors(t *testing.T) {
+	noSleep(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: newTransport(nil)}
+
+	// Reads are retried until the attempts run out
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(maxAttempts), calls.Load())
+
+	// A POST may have been acted on, so it is sent once
+	calls.Store(0)
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestTransportGivesUpOnDistantReset(t *testing.T) {
+	waits := noSleep(t)
+	reset := time.Now().Add(time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	p := &pullRequestAPI{owner: "o", repo: "r", apiURL: srv.URL, client: &http.Client{Transport: newTransport(nil)}}
+	_, err := p.CurrentUser()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limit was reached; it resets at")
+	assert.Empty(t, *waits)
+}
+
+func TestTransportConditionalRequests(t *testing.T) {
+	var calls, revalidated atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1767225600")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, `{"login":"octocat"}`)
+	}))
+	defer srv.Close()
+
+	p := &pullRequestAPI{owner: "o", repo: "r", apiURL: srv.URL, client: &http.Client{Transport: newTransport(nil)}}
+	for i := 0; i < 2; i++ {
+		login, err := p.CurrentUser()
+		require.NoError(t, err)
+		assert.Equal(t, "octocat", login)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, int32(1), revalidated.Load())
+}
+
+func TestTransportVerbose(t *testing.T) {
+	var log bytes.Buffer
+	Verbose = &log
+	defer func() { Verbose = nil }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	resp, err := (&http.Client{Transport: newTransport(nil)}).Get(srv.URL + "/user")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Contains(t, log.String(), "GitHub API GET "+srv.URL+"/user: 200 (4321/5000 requests left)")
+}
@@ -0,0 +1,43 @@
+package gh
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CommitAuthorLogin returns the GitHub login of a commit's author, or "" when
+// the author's email isn't linked to an account
+func (p *pullRequestAPI) CommitAuthorLogin(sha string) (string, error) {
+	commit, err := p.GetCommit(sha)
+	if err != nil {
+		return "", err
+	}
+	return commit.AuthorLogin, nil
+}
+
+// IsCollaborator reports whether a user has access to the repository, either
+// directly or through its organization, and so can be asked for a review
+func (p *pullRequestAPI) IsCollaborator(login string) (bool, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/collaborators/%s", p.api(), p.owner, p.repo, login)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+	if token := p.authToken(); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("GitHub API GET %s returned %d", u, resp.StatusCode)
+	}
+}
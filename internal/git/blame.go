@@ -0,0 +1,70 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlameLine is a line of a file with the commit that last changed it
+type BlameLine struct {
+	Hash       string // all zeros for lines not committed yet
+	Line       int    // 1-based, in the working tree's copy
+	Author     string
+	AuthorTime time.Time
+	Summary    string // the commit's subject
+	Text       string
+}
+
+// Uncommitted reports whether the line has changes not committed yet
+func (b BlameLine) Uncommitted() bool {
+	return strings.Trim(b.Hash, "0") == ""
+}
+
+// BlamePorcelain blames every line of path in the working tree
+func (s *ShellGit) BlamePorcelain(path string) ([]BlameLine, error) {
+	out, err := s.run("blame", "--line-porcelain", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlamePorcelain(out), nil
+}
+
+// parseBlamePorcelain reads git blame --line-porcelain output, where each
+// line is a "<hash> <orig-line> <final-line>" header, then "key value"
+// lines about the commit, then the line itself prefixed with a tab
+func parseBlamePorcelain(out string) []BlameLine {
+	var lines []BlameLine
+	var cur BlameLine
+	header := true
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(l, "\t") {
+			cur.Text = l[1:]
+			lines = append(lines, cur)
+			header = true
+			continue
+		}
+		if header {
+			fields := strings.Fields(l)
+			if len(fields) < 3 {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[2])
+			cur = BlameLine{Hash: fields[0], Line: n}
+			header = false
+			continue
+		}
+		key, value, _ := strings.Cut(l, " ")
+		switch key {
+		case "author":
+			cur.Author = value
+		case "author-time":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.AuthorTime = time.Unix(secs, 0)
+			}
+		case "summary":
+			cur.Summary = value
+		}
+	}
+	return lines
+}
@@ -0,0 +1,46 @@
+package git
+
+import "sync"
+
+// refCache remembers lookups that many steps of one command repeat, such as
+// the default branch (every IsHeadBranch, sync step and PR base resolution).
+// It lives for the whole process and is dropped whenever a git command could
+// change the answer, e.g. a fetch or a remote update.
+var refCache struct {
+	mu            sync.Mutex
+	defaultBranch string
+	defaultErr    error
+	haveDefault   bool
+}
+
+// InvalidateCache forgets cached lookups so the next call asks git again
+func InvalidateCache() {
+	refCache.mu.Lock()
+	defer refCache.mu.Unlock()
+	refCache.defaultBranch, refCache.defaultErr, refCache.haveDefault = "", nil, false
+}
+
+// cachedDefaultBranch returns the cached default branch, calling lookup once
+func cachedDefaultBranch(lookup func() (string, error)) (string, error) {
+	refCache.mu.Lock()
+	defer refCache.mu.Unlock()
+	if !refCache.haveDefault {
+		refCache.defaultBranch, refCache.defaultErr = lookup()
+		refCache.haveDefault = true
+	}
+	return refCache.defaultBranch, refCache.defaultErr
+}
+
+// invalidatesCache reports whether a git invocation can change cached lookups
+func invalidatesCache(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "fetch", "pull", "clone", "init":
+		return true
+	case "remote", "symbolic-ref":
+		return !isReadOnly(args)
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is one commit read from git log
+type Commit struct {
+	Hash    string
+	Parents []string // only filled in by Log
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+	Body    string     // message after the subject, trimmed
+	Files   []FileStat // only filled in with CommitsOptions.Stats
+}
+
+// ShortHash returns the first seven characters of the hash
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// FileStat is a file's line counts in a commit. Binary files have no counts.
+type FileStat struct {
+	Path    string
+	Added   int
+	Deleted int
+	Binary  bool
+}
+
+// CommitsOptions selects the commits returned by Commits
+type CommitsOptions struct {
+	Range string   // revision or range, e.g. "main..feature"; HEAD when empty
+	Paths []string // only commits touching these paths
+	Limit int      // at most this many commits; 0 for all
+	Stats bool     // read per-file line counts
+}
+
+// Fields are separated by 0x1f and commits start with 0x1e, neither of which
+// appears in names or messages
+const commitFormat = "--format=%x1e%H%x1f%an%x1f%ae%x1f%at%x1f%s%x1f%b%x1f"
+
+// Commits returns commits newest first
+func (s *ShellGit) Commits(opts CommitsOptions) ([]Commit, error) {
+	args := []string{"log", commitFormat}
+	if opts.Limit > 0 {
+		args = append(args, "-n", strconv.Itoa(opts.Limit))
+	}
+	if opts.Stats {
+		args = append(args, "--numstat")
+	}
+	if opts.Range != "" {
+		args = append(args, opts.Range)
+	}
+	if len(opts.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Paths...)
+	}
+
+	out, err := s.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseCommits(out)
+}
+
+func parseCommits(out string) ([]Commit, error) {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		fields := strings.SplitN(record, "\x1f", 7)
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected git log output: %q", record)
+		}
+		ts, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected commit date %q: %w", fields[3], err)
+		}
+		c := Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    time.Unix(ts, 0),
+			Subject: fields[4],
+			Body:    strings.TrimSpace(fields[5]),
+		}
+		c.Files = parseNumstat(fields[6])
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// parseNumstat reads "added<TAB>deleted<TAB>path" lines; binary files show "-"
+func parseNumstat(out string) []FileStat {
+	var files []FileStat
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		f := FileStat{Path: parts[2]}
+		if parts[0] == "-" && parts[1] == "-" {
+			f.Binary = true
+		} else {
+			f.Added, _ = strconv.Atoi(parts[0])
+			f.Deleted, _ = strconv.Atoi(parts[1])
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// FormatCommits renders commits as plain text, oldest first, for use in
+// prompts and summaries
+func FormatCommits(commits []Commit) string {
+	var b strings.Builder
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		fmt.Fprintf(&b, "%s %s\n", c.ShortHash(), c.Subject)
+		if c.Body != "" {
+			for _, line := range strings.Split(c.Body, "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+		for _, f := range c.Files {
+			if f.Binary {
+				fmt.Fprintf(&b, "    %s (binary)\n", f.Path)
+			} else {
+				fmt.Fprintf(&b, "    %s +%d -%d\n", f.Path, f.Added, f.Deleted)
+			}
+		}
+	}
+	return b.String()
+}
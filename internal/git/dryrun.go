@@ -0,0 +1,99 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+)
+
+// readOnlyCommands never change the repository, so they still run in dry-run
+// mode. fetch is included because it only updates remote-tracking refs and
+// keeps the plan accurate.
+var readOnlyCommands = map[string]bool{
+	"status": true, "rev-parse": true, "log": true, "diff": true, "show": true,
+	"rev-list": true, "merge-base": true, "ls-files": true, "ls-remote": true,
+	"cat-file": true, "for-each-ref": true, "show-ref": true, "grep": true,
+	"blame": true, "describe": true, "name-rev": true, "check-ignore": true,
+	"check-attr": true, "var": true, "shortlog": true, "diff-tree": true,
+	"diff-index": true, "cherry": true, "fetch": true, "version": true,
+	"ls-tree": true, "count-objects": true,
+}
+
+// subcommandReads lists the read-only subcommands of commands that can also write
+var subcommandReads = map[string][]string{
+	"remote":    {"get-url", "show", "-v"},
+	"stash":     {"list", "show"},
+	"worktree":  {"list"},
+	"reflog":    {"show"},
+	"submodule": {"status", "summary"},
+}
+
+// isReadOnly reports whether a git invocation leaves the repository untouched
+func isReadOnly(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	cmd, rest := args[0], args[1:]
+	if readOnlyCommands[cmd] {
+		return true
+	}
+
+	positional := 0
+	for _, a := range rest {
+		if !strings.HasPrefix(a, "-") {
+			positional++
+		}
+	}
+
+	switch cmd {
+	case "config":
+		for _, a := range rest {
+			switch a {
+			case "--get", "--get-all", "--get-regexp", "--list", "-l":
+				return true
+			}
+		}
+		return positional <= 1
+	case "branch":
+		for _, a := range rest {
+			switch a {
+			case "-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy",
+				"-u", "--set-upstream-to", "--unset-upstream", "-f", "--force":
+				return false
+			case "--merged", "--no-merged", "--contains", "--no-contains", "--list", "-l",
+				"-a", "--all", "-r", "--remotes", "--show-current", "-v", "-vv":
+				return true
+			}
+		}
+		return positional == 0
+	case "symbolic-ref":
+		return positional <= 1
+	case "tag":
+		for _, a := range rest {
+			if a == "-l" || a == "--list" {
+				return true
+			}
+		}
+		return positional == 0
+	case "remote", "stash", "worktree", "reflog":
+		if len(rest) == 0 {
+			return cmd != "stash"
+		}
+		for _, sub := range subcommandReads[cmd] {
+			if rest[0] == sub {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipForDryRun records args instead of running them when dry-run mode is on
+// and the command would change the repository
+func skipForDryRun(args []string) bool {
+	if !dryrun.Enabled() || isReadOnly(args) {
+		return false
+	}
+	dryrun.Record("git %s", dryrun.Quote(args))
+	return true
+}
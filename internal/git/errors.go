@@ -0,0 +1,149 @@
+package git
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+// ErrorCode says what kind of failure a git command ran into
+type ErrorCode int
+
+const (
+	Unknown ErrorCode = iota
+	NotARepo
+	DetachedHead
+	NoUpstream
+	MergeConflict
+	DirtyWorktree
+	AuthFailure
+	Locked
+)
+
+// Error is a failed git command. Match it by kind with errors.Is against
+// the Err* values, or get at the details with errors.As.
+type Error struct {
+	Code   ErrorCode
+	Args   []string // the git arguments, empty for errors sage raises itself
+	Status int      // git's exit status, or -1 if it didn't run
+	Stderr string
+	Err    error
+	msg    string
+}
+
+// Errors to match with errors.Is
+var (
+	ErrNotARepo      = &Error{Code: NotARepo, msg: "not a git repository"}
+	ErrDetachedHead  = &Error{Code: DetachedHead, msg: "detached HEAD"}
+	ErrNoUpstream    = &Error{Code: NoUpstream, msg: "no upstream branch"}
+	ErrMergeConflict = &Error{Code: MergeConflict, msg: "merge conflicts"}
+	ErrDirtyWorktree = &Error{Code: DirtyWorktree, msg: "uncommitted changes"}
+	ErrAuthFailure   = &Error{Code: AuthFailure, msg: "authentication failed"}
+	ErrLocked        = &Error{Code: Locked, msg: "repository locked by another git process"}
+)
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.msg
+	}
+	if e.Stderr == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Stderr
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches errors of the same kind, so errors.Is(err, ErrNoUpstream)
+// holds for any git command that failed for want of an upstream
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != Unknown && t.Code == e.Code
+}
+
+// ExitCode gives the exit status sage finishes with for this error
+func (e *Error) ExitCode() int {
+	switch e.Code {
+	case MergeConflict:
+		return exitcode.Conflict
+	case DirtyWorktree:
+		return exitcode.DirtyTree
+	case AuthFailure:
+		return exitcode.Auth
+	}
+	return exitcode.Failure
+}
+
+var lockFile = regexp.MustCompile(`Unable to create '([^']+\.lock)': File exists`)
+
+// LockFile returns the lock file that stopped git, for Locked errors
+func (e *Error) LockFile() string {
+	if m := lockFile.FindStringSubmatch(e.Stderr); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// stderrPatterns recognise git's messages. Commands run with their messages
+// untranslated (see withEnglishMessages), so these hold whatever the user's
+// locale.
+var stderrPatterns = []struct {
+	code     ErrorCode
+	patterns []string
+}{
+	{NotARepo, []string{"not a git repository"}},
+	{Locked, []string{".lock': File exists", "Another git process seems to be running"}},
+	{DetachedHead, []string{"not currently on a branch", "HEAD detached"}},
+	{NoUpstream, []string{"no upstream branch", "has no upstream", "no tracking information", "--set-upstream"}},
+	{MergeConflict, []string{"CONFLICT", "unmerged files", "fix conflicts", "could not apply", "needs merge", "you need to resolve your current index first"}},
+	{DirtyWorktree, []string{"would be overwritten", "commit your changes or stash them", "You have unstaged changes", "Your index contains uncommitted changes", "cannot pull with rebase"}},
+	{AuthFailure, []string{"Authentication failed", "could not read Username", "could not read Password", "Permission denied (publickey", "returned error: 401", "returned error: 403", "Invalid username or password"}},
+}
+
+// classify works out what kind of failure stderr describes
+func classify(stderr string) ErrorCode {
+	for _, p := range stderrPatterns {
+		for _, s := range p.patterns {
+			if strings.Contains(stderr, s) {
+				return p.code
+			}
+		}
+	}
+	return Unknown
+}
+
+// newError wraps the failure of git args, classifying it from stderr
+func newError(args []string, err error, stderr string) *Error {
+	e := &Error{
+		Code:   classify(stderr),
+		Args:   args,
+		Status: -1,
+		Stderr: stderr,
+		Err:    err,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.Status = exitErr.ExitCode()
+	}
+	return e
+}
+
+// withEnglishMessages keeps git's messages untranslated so they can be
+// classified, leaving the character set the user's locale picks alone
+func withEnglishMessages(env []string) []string {
+	out := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "LC_ALL="); ok {
+			// LC_ALL would override LC_MESSAGES; keep the rest of it
+			if v != "" {
+				out = append(out, "LC_CTYPE="+v)
+			}
+			continue
+		}
+		out = append(out, kv)
+	}
+	return append(out, "LC_MESSAGES=C")
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   ErrorCode
+		is     error
+	}{
+		{"not a repo", "fatal: not a git repository (or any of the parent directories): .git", NotARepo, ErrNotARepo},
+		{"index lock", "fatal: Unable to create '/repo/.git/index.lock': File exists.\n\nAnother git process seems to be running in this repository", Locked, ErrLocked},
+		{"detached rebase", "fatal: You are not currently on a branch.\nTo push the history leading to the current (detached HEAD)\nstate now, use\n\n    git push origin HEAD:<name-of-remote-branch>", DetachedHead, ErrDetachedHead},
+		{"push without upstream", "fatal: The current branch feature has no upstream branch.\nTo push the current branch and set the remote as upstream, use\n\n    git push --set-upstream origin feature", NoUpstream, ErrNoUpstream},
+		{"pull without tracking", "There is no tracking information for the current branch.\nPlease specify which branch you want to merge with.", NoUpstream, ErrNoUpstream},
+		{"merge conflict", "CONFLICT (content): Merge conflict in app.txt\nAutomatic merge failed; fix conflicts and then commit the result.", MergeConflict, ErrMergeConflict},
+		{"rebase conflict", "error: could not apply 1a2b3c4... Feature work\nhint: Resolve all conflicts manually", MergeConflict, ErrMergeConflict},
+		{"unmerged index", "error: you need to resolve your current index first\napp.txt: needs merge", MergeConflict, ErrMergeConflict},
+		{"checkout over changes", "error: Your local changes to the following files would be overwritten by checkout:\n\tapp.txt\nPlease commit your changes or stash them before you switch branches.\nAborting", DirtyWorktree, ErrDirtyWorktree},
+		{"pull rebase dirty", "error: cannot pull with rebase: You have unstaged changes.\nerror: please commit or stash them.", DirtyWorktree, ErrDirtyWorktree},
+		{"https auth", "remote: Invalid username or password.\nfatal: Authentication failed for 'https://github.com/o/r.git/'", AuthFailure, ErrAuthFailure},
+		{"no terminal for credentials", "fatal: could not read Username for 'https://github.com': terminal prompts disabled", AuthFailure, ErrAuthFailure},
+		{"ssh key", "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", AuthFailure, ErrAuthFailure},
+		{"forbidden", "fatal: unable to access 'https://github.com/o/r.git/': The requested URL returned error: 403", AuthFailure, ErrAuthFailure},
+		{"unknown", "fatal: ambiguous argument 'nope': unknown revision or path not in the working tree.", Unknown, nil},
+		{"empty", "", Unknown, nil},
+	}
+	sentinels := []error{ErrNotARepo, ErrDetachedHead, ErrNoUpstream, ErrMergeConflict, ErrDirtyWorktree, ErrAuthFailure, ErrLocked}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.stderr); got != tt.want {
+				t.Errorf("classify = %d, want %d", got, tt.want)
+			}
+
+			// Wrapped the way callers return it, the error matches its own
+			// sentinel and no other
+			err := fmt.Errorf("failed to sync: %w", newError([]string{"pull"}, errors.New("exit status 1"), tt.stderr))
+			for _, s := range sentinels {
+				if got, want := errors.Is(err, s), s == tt.is; got != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", s, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorExitCode(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		want int
+	}{
+		{ErrMergeConflict, exitcode.Conflict},
+		{ErrDirtyWorktree, exitcode.DirtyTree},
+		{ErrAuthFailure, exitcode.Auth},
+		{ErrNoUpstream, exitcode.Failure},
+		{&Error{}, exitcode.Failure},
+	}
+	for _, tt := range tests {
+		if got := tt.err.ExitCode(); got != tt.want {
+			t.Errorf("%v: ExitCode = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNewErrorKeepsExitStatus(t *testing.T) {
+	runErr := exec.Command("sh", "-c", "exit 3").Run()
+	stderr := "fatal: Unable to create '/repo/.git/refs/heads/main.lock': File exists."
+	e := newError([]string{"commit"}, runErr, stderr)
+	if e.Status != 3 {
+		t.Errorf("Status = %d, want 3", e.Status)
+	}
+	if got := e.LockFile(); got != "/repo/.git/refs/heads/main.lock" {
+		t.Errorf("LockFile = %q", got)
+	}
+	if e.Error() != runErr.Error()+": "+stderr {
+		t.Errorf("Error = %q", e.Error())
+	}
+
+	if e := newError(nil, errors.New("not found"), ""); e.Status != -1 || e.Error() != "not found" {
+		t.Errorf("got status %d and %q for a command that didn't run", e.Status, e.Error())
+	}
+}
+
+func TestWithEnglishMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		want []string
+	}{
+		{"adds LC_MESSAGES", []string{"HOME=/h", "LANG=de_DE.UTF-8"}, []string{"HOME=/h", "LANG=de_DE.UTF-8", "LC_MESSAGES=C"}},
+		{"keeps the charset of LC_ALL", []string{"LC_ALL=fr_FR.UTF-8", "PATH=/bin"}, []string{"LC_CTYPE=fr_FR.UTF-8", "PATH=/bin", "LC_MESSAGES=C"}},
+		{"drops an empty LC_ALL", []string{"LC_ALL="}, []string{"LC_MESSAGES=C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withEnglishMessages(tt.env); !slices.Equal(got, tt.want) {
+				t.Errorf("withEnglishMessages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DiffMatch is an added line of a diff that matched a pattern
+type DiffMatch struct {
+	File    string // path of the file in the new tree
+	Line    int    // line number in the new version of the file
+	Text    string // the added line, without its leading "+"
+	Pattern int    // index of the matching pattern
+}
+
+// hunkHeader captures the new-file start line of "@@ -a,b +c,d @@"
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// ScanDiff matches patterns against the lines a unified diff adds. Files are
+// scanned in parallel; matches come back in diff order, one per line and
+// pattern. Removed and context lines are ignored, so taking a secret out
+// doesn't flag it again.
+func ScanDiff(diff string, patterns []*regexp.Regexp) []DiffMatch {
+	files := splitDiffFiles(diff)
+	results := make([][]DiffMatch, len(files))
+
+	var wg sync.WaitGroup
+	for i, f := range files {
+		wg.Add(1)
+		go func(i int, f string) {
+			defer wg.Done()
+			results[i] = scanDiffFile(f, patterns)
+		}(i, f)
+	}
+	wg.Wait()
+
+	var out []DiffMatch
+	for _, r := range results {
+		out = append(out, r...)
+	}
+	return out
+}
+
+// splitDiffFiles cuts a diff into one section per "diff --git" header
+func splitDiffFiles(diff string) []string {
+	var files []string
+	start := -1
+	for i := 0; i < len(diff); {
+		if strings.HasPrefix(diff[i:], "diff --git ") {
+			if start >= 0 {
+				files = append(files, diff[start:i])
+			}
+			start = i
+		}
+		next := strings.IndexByte(diff[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	if start >= 0 {
+		files = append(files, diff[start:])
+	} else if diff != "" {
+		// A bare patch without git headers
+		files = append(files, diff)
+	}
+	return files
+}
+
+func scanDiffFile(section string, patterns []*regexp.Regexp) []DiffMatch {
+	var out []DiffMatch
+	file := ""
+	line := 0
+	inHunk := false
+	for _, l := range strings.Split(section, "\n") {
+		switch {
+		case strings.HasPrefix(l, "+++ "):
+			if !inHunk {
+				file = strings.TrimPrefix(strings.TrimPrefix(l, "+++ "), "b/")
+				continue
+			}
+		case strings.HasPrefix(l, "@@"):
+			if m := hunkHeader.FindStringSubmatch(l); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				inHunk = true
+			}
+			continue
+		case strings.HasPrefix(l, "diff --git "):
+			inHunk = false
+			continue
+		}
+		if !inHunk || l == "" {
+			continue
+		}
+
+		switch l[0] {
+		case '+':
+			text := l[1:]
+			for i, re := range patterns {
+				if re.MatchString(text) {
+					out = append(out, DiffMatch{File: file, Line: line, Text: text, Pattern: i})
+				}
+			}
+			line++
+		case ' ':
+			line++
+		}
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ObjectCounts is what git count-objects -v reports about the object store
+type ObjectCounts struct {
+	Loose     int   // loose objects
+	LooseSize int64 // bytes they take
+	Packs     int
+	PackSize  int64 // bytes the packs take
+	Garbage   int   // files in the object store that aren't objects or packs
+}
+
+// Blob is a file's content at some point in history
+type Blob struct {
+	Hash string
+	Path string // the first path it was found at
+	Size int64
+}
+
+// Upstream returns the branch branch tracks, such as origin/feature, or ""
+// when it tracks none
+func (s *ShellGit) Upstream(branch string) (string, error) {
+	if err := validateRef(branch); err != nil {
+		return "", err
+	}
+	out, err := s.run("for-each-ref", "--format=%(upstream:short)", "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// BranchesAt returns the local branches pointing at ref
+func (s *ShellGit) BranchesAt(ref string) ([]string, error) {
+	out, err := s.run("for-each-ref", "--format=%(refname:short)", "--points-at", ref, "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// HooksDir returns the directory git runs hooks from, honouring
+// core.hooksPath
+func (s *ShellGit) HooksDir() (string, error) {
+	out, err := s.run("rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CountObjects reports how many loose objects and packs the repository has
+func (s *ShellGit) CountObjects() (ObjectCounts, error) {
+	out, err := s.run("count-objects", "-v")
+	if err != nil {
+		return ObjectCounts{}, err
+	}
+	var c ObjectCounts
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		// Sizes are in KiB
+		switch key {
+		case "count":
+			c.Loose = int(n)
+		case "size":
+			c.LooseSize = n * 1024
+		case "packs":
+			c.Packs = int(n)
+		case "size-pack":
+			c.PackSize = n * 1024
+		case "garbage":
+			c.Garbage = int(n)
+		}
+	}
+	return c, nil
+}
+
+// LargeBlobs returns the blobs of at least minSize bytes reachable from
+// revs, which may exclude commits with ^rev or --not, largest first
+func (s *ShellGit) LargeBlobs(minSize int64, revs ...string) ([]Blob, error) {
+	if minSize <= 0 {
+		return nil, fmt.Errorf("invalid size %d", minSize)
+	}
+	// Filtering out blobs of minSize or more prints them as omitted
+	args := append([]string{"rev-list", "--objects", "--filter-print-omitted", fmt.Sprintf("--filter=blob:limit=%d", minSize)}, revs...)
+	out, err := s.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	var large []string
+	for _, line := range strings.Split(out, "\n") {
+		if hash, ok := strings.CutPrefix(line, "~"); ok {
+			large = append(large, strings.TrimSpace(hash))
+		}
+	}
+	if len(large) == 0 {
+		return nil, nil
+	}
+
+	// Omitted blobs come without their paths, so look them up in the
+	// unfiltered listing
+	if out, err = s.run(append([]string{"rev-list", "--objects"}, revs...)...); err != nil {
+		return nil, err
+	}
+	paths := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if hash, path, ok := strings.Cut(line, " "); ok {
+			if _, seen := paths[hash]; !seen {
+				paths[hash] = path
+			}
+		}
+	}
+
+	blobs := make([]Blob, 0, len(large))
+	for _, hash := range large {
+		size, err := s.run("cat-file", "-s", hash)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		blobs = append(blobs, Blob{Hash: hash, Path: paths[hash], Size: n})
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Size > blobs[j].Size })
+	return blobs, nil
+}
@@ -0,0 +1,165 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogOptions selects the commits a LogReader reads
+type LogOptions struct {
+	Range string // revision or range; HEAD when empty
+	All   bool   // every branch, not just Range
+	Files bool   // read the paths each commit touches
+}
+
+// logFormat is commitFormat with the parents added, so a graph can be drawn
+const logFormat = "--format=%x1e%H%x1f%P%x1f%an%x1f%ae%x1f%at%x1f%s%x1f%b%x1f"
+
+// LogReader streams commits from a running git log, newest first, so long
+// histories can be shown a page at a time without reading them whole
+type LogReader struct {
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+	stderr  bytes.Buffer
+	scanner *bufio.Scanner
+	done    bool
+}
+
+// Log starts git log and returns a reader for its commits. Close it when
+// done, even if not everything was read.
+func (s *ShellGit) Log(opts LogOptions) (*LogReader, error) {
+	args := []string{"log", logFormat, "--date-order"}
+	if opts.Files {
+		args = append(args, "--name-only")
+	}
+	if opts.All {
+		args = append(args, "--all")
+	} else if opts.Range != "" {
+		if err := ValidateCommandArg(opts.Range); err != nil {
+			return nil, fmt.Errorf("invalid revision: %w", err)
+		}
+		args = append(args, opts.Range)
+	}
+	args = append(args, "--")
+
+	cmd, err := setupSecureCommand("git", args...)
+	if err != nil {
+		return nil, err
+	}
+	r := &LogReader{cmd: cmd}
+	cmd.Stderr = &r.stderr
+	if r.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	r.scanner = bufio.NewScanner(r.stdout)
+	r.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	r.scanner.Split(splitRecords)
+	return r, nil
+}
+
+// splitRecords splits git log output on the 0x1e that starts each commit
+func splitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && data[start] == '\x1e' {
+		start++
+	}
+	if i := bytes.IndexByte(data[start:], '\x1e'); i >= 0 {
+		return start + i, data[start : start+i], nil
+	}
+	if atEOF {
+		if start >= len(data) {
+			return len(data), nil, nil
+		}
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+// Next returns the next commit, or io.EOF after the last one
+func (r *LogReader) Next() (Commit, error) {
+	for !r.done {
+		if !r.scanner.Scan() {
+			r.done = true
+			if err := r.scanner.Err(); err != nil {
+				return Commit{}, err
+			}
+			if err := r.cmd.Wait(); err != nil {
+				return Commit{}, fmt.Errorf("%v: %s", err, strings.TrimSpace(r.stderr.String()))
+			}
+			break
+		}
+		record := r.scanner.Text()
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		return parseLogRecord(record)
+	}
+	return Commit{}, io.EOF
+}
+
+// Page reads up to n more commits. It returns fewer, and no error, when the
+// log runs out.
+func (r *LogReader) Page(n int) ([]Commit, error) {
+	var commits []Commit
+	for len(commits) < n {
+		c, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return commits, err
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// Close stops git log if it is still running
+func (r *LogReader) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	r.stdout.Close()
+	if r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+	_ = r.cmd.Wait()
+	return nil
+}
+
+func parseLogRecord(record string) (Commit, error) {
+	fields := strings.SplitN(record, "\x1f", 8)
+	if len(fields) != 8 {
+		return Commit{}, fmt.Errorf("unexpected git log output: %q", record)
+	}
+	ts, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return Commit{}, fmt.Errorf("unexpected commit date %q: %w", fields[4], err)
+	}
+	c := Commit{
+		Hash:    fields[0],
+		Parents: strings.Fields(fields[1]),
+		Author:  fields[2],
+		Email:   fields[3],
+		Date:    time.Unix(ts, 0),
+		Subject: fields[5],
+		Body:    strings.TrimSpace(fields[6]),
+	}
+	for _, line := range strings.Split(fields[7], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			c.Files = append(c.Files, FileStat{Path: line})
+		}
+	}
+	return c, nil
+}
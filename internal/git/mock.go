@@ -2,6 +2,8 @@ package git
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -44,6 +46,26 @@ func (m *MockGit) IsRepo() (bool, error) {
 	return m.isRepo, nil
 }
 
+// PushRefs implements Service.PushRefs
+func (m *MockGit) PushRefs(opts PushRefsOptions) ([]PushRefResult, error) {
+	m.trackCall("PushRefs")
+	var results []PushRefResult
+	for _, spec := range opts.Refspecs {
+		src, dst, ok := strings.Cut(spec, ":")
+		if !ok {
+			dst = src
+		}
+		results = append(results, PushRefResult{Source: src, Destination: dst, Status: "updated"})
+	}
+	return results, nil
+}
+
+// HasCommits implements Service.HasCommits
+func (m *MockGit) HasCommits() (bool, error) {
+	m.trackCall("HasCommits")
+	return true, nil
+}
+
 // IsClean implements Service.IsClean
 func (m *MockGit) IsClean() (bool, error) {
 	m.trackCall("IsClean")
@@ -115,6 +137,26 @@ func (m *MockGit) Commit(msg string, allowEmpty bool, stageAll bool) error {
 	return nil
 }
 
+// CommitPaths implements Service.CommitPaths
+func (m *MockGit) CommitPaths(msg string, allowEmpty bool, paths []string) error {
+	m.trackCall("CommitPaths")
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to commit")
+	}
+	m.commits["mock-hash"] = msg
+	for _, p := range paths {
+		delete(m.staged, p)
+	}
+	return nil
+}
+
+// RewordCommit implements Service.RewordCommit
+func (m *MockGit) RewordCommit(commit, message string) (string, error) {
+	m.trackCall("RewordCommit")
+	m.commits[commit] = message
+	return commit, nil
+}
+
 // GetCallCount returns the number of times a method was called
 func (m *MockGit) GetCallCount(method string) int {
 	return m.calls[method]
@@ -174,6 +216,11 @@ func (m *MockGit) FetchAll() error {
 	return nil
 }
 
+func (m *MockGit) FetchRemotes(remotes []string, progress FetchProgress) error {
+	m.trackCall("FetchRemotes")
+	return nil
+}
+
 func (m *MockGit) Pull() error {
 	m.trackCall("Pull")
 	return nil
@@ -238,9 +285,14 @@ func (m *MockGit) ListBranches() ([]string, error) {
 	return branches, nil
 }
 
-func (m *MockGit) Log(branch string, limit int, stats, all bool) (string, error) {
+func (m *MockGit) Commits(opts CommitsOptions) ([]Commit, error) {
+	m.trackCall("Commits")
+	return nil, nil
+}
+
+func (m *MockGit) Log(opts LogOptions) (*LogReader, error) {
 	m.trackCall("Log")
-	return "", nil
+	return nil, fmt.Errorf("log is not available in the mock")
 }
 
 func (m *MockGit) SquashCommits(startCommit string) error {
@@ -278,6 +330,21 @@ func (m *MockGit) GetBranchMergeConflicts(branch string) (int, error) {
 	return 0, nil
 }
 
+func (m *MockGit) AheadBehind(base, branch string) (int, int, error) {
+	m.trackCall("AheadBehind")
+	return 0, 0, nil
+}
+
+func (m *MockGit) BranchSummaries(base string, conflicts bool) ([]BranchSummary, error) {
+	m.trackCall("BranchSummaries")
+	return nil, nil
+}
+
+func (m *MockGit) MergeConflicts(ours, theirs string) ([]string, error) {
+	m.trackCall("MergeConflicts")
+	return nil, nil
+}
+
 func (m *MockGit) Stash(message string) error {
 	m.trackCall("Stash")
 	m.stashed = append(m.stashed, message)
@@ -339,10 +406,10 @@ func (m *MockGit) StagedDiff() (string, error) {
 	return "", nil
 }
 
-// GrepDiff searches for a pattern in a diff and returns matching lines
-func (m *MockGit) GrepDiff(diff string, pattern string) ([]string, error) {
+// GrepDiff matches patterns against the lines added by diff
+func (m *MockGit) GrepDiff(diff string, patterns []*regexp.Regexp) []DiffMatch {
 	m.trackCall("GrepDiff")
-	return []string{}, nil
+	return ScanDiff(diff, patterns)
 }
 
 // ListConflictedFiles returns a list of files with conflicts
@@ -368,3 +435,87 @@ func (m *MockGit) RebaseContinue() error {
 	m.trackCall("RebaseContinue")
 	return nil
 }
+
+// IsApplyingPatches reports whether a git am session is stopped on a patch
+func (m *MockGit) IsApplyingPatches() (bool, error) {
+	m.trackCall("IsApplyingPatches")
+	return false, nil
+}
+
+// Upstream returns the branch a branch tracks; mock branches track none
+func (m *MockGit) Upstream(branch string) (string, error) {
+	m.trackCall("Upstream")
+	return "", nil
+}
+
+// BranchesAt returns the local branches pointing at ref
+func (m *MockGit) BranchesAt(ref string) ([]string, error) {
+	m.trackCall("BranchesAt")
+	return nil, nil
+}
+
+// HooksDir returns the directory git runs hooks from
+func (m *MockGit) HooksDir() (string, error) {
+	m.trackCall("HooksDir")
+	return "", nil
+}
+
+// CountObjects reports the size of the object store
+func (m *MockGit) CountObjects() (ObjectCounts, error) {
+	m.trackCall("CountObjects")
+	return ObjectCounts{}, nil
+}
+
+// LargeBlobs returns the blobs of at least minSize bytes reachable from revs
+func (m *MockGit) LargeBlobs(minSize int64, revs ...string) ([]Blob, error) {
+	m.trackCall("LargeBlobs")
+	return nil, nil
+}
+
+// Submodules lists the submodules; mock repositories have none
+func (m *MockGit) Submodules() ([]Submodule, error) {
+	m.trackCall("Submodules")
+	return nil, nil
+}
+
+// UpdateSubmodules checks submodules out at the recorded commits
+func (m *MockGit) UpdateSubmodules(paths ...string) error {
+	m.trackCall("UpdateSubmodules")
+	return nil
+}
+
+// BlamePorcelain blames the lines of path; mock files have none
+func (m *MockGit) BlamePorcelain(path string) ([]BlameLine, error) {
+	m.trackCall("BlamePorcelain")
+	return nil, nil
+}
+
+// CherryPick applies commits onto HEAD
+func (m *MockGit) CherryPick(commits ...string) error {
+	m.trackCall("CherryPick")
+	return nil
+}
+
+// Revert commits the inverse of commits
+func (m *MockGit) Revert(commits ...string) error {
+	m.trackCall("Revert")
+	return nil
+}
+
+// SequencerInProgress reports a stopped cherry-pick or revert; mocks have none
+func (m *MockGit) SequencerInProgress() (string, error) {
+	m.trackCall("SequencerInProgress")
+	return "", nil
+}
+
+// SequencerContinue carries on with a stopped cherry-pick or revert
+func (m *MockGit) SequencerContinue(op string) error {
+	m.trackCall("SequencerContinue")
+	return nil
+}
+
+// SequencerAbort stops a cherry-pick or revert
+func (m *MockGit) SequencerAbort(op string) error {
+	m.trackCall("SequencerAbort")
+	return nil
+}
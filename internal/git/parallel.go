@@ -0,0 +1,172 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fetchWorkers caps how many remotes are fetched at once
+	fetchWorkers = 4
+	// queryWorkers caps how many per-branch git queries run at once
+	queryWorkers = 8
+)
+
+// FetchProgress is told about each remote as its fetch finishes
+type FetchProgress func(remote string, err error)
+
+// BranchSummary is what the dashboards show for a local branch
+type BranchSummary struct {
+	Name       string
+	LastCommit time.Time
+	Ahead      int // commits the branch has that base doesn't
+	Behind     int // commits base has that the branch doesn't
+	Conflicts  int // files merging the branch into base would conflict on
+}
+
+// parallel runs fn for 0..n-1 on at most workers goroutines
+func parallel(n, workers int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(n, workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// Remotes lists the repository's remotes
+func (s *ShellGit) Remotes() ([]string, error) {
+	out, err := s.run("remote")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// FetchRemotes fetches remotes side by side, pruning branches deleted on
+// them, and reports each to progress, which may be nil, as it finishes.
+// No remotes means all of them. Every remote is tried; the failures come
+// back together.
+func (s *ShellGit) FetchRemotes(remotes []string, progress FetchProgress) error {
+	if len(remotes) == 0 {
+		var err error
+		if remotes, err = s.Remotes(); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"fetch", "--prune"}
+	if len(remotes) > 1 {
+		// As fetch --all --jobs does: the fetches would race to write
+		// FETCH_HEAD and start gc, and nothing reads the result
+		args = append(args, "--no-write-fetch-head", "--no-auto-gc")
+	}
+
+	errs := make([]error, len(remotes))
+	var mu sync.Mutex
+	parallel(len(remotes), fetchWorkers, func(i int) {
+		_, err := s.run(append(args, remotes[i])...)
+		if err != nil {
+			err = fmt.Errorf("fetching %s: %w", remotes[i], err)
+		}
+		errs[i] = err
+		if progress != nil {
+			mu.Lock()
+			progress(remotes[i], err)
+			mu.Unlock()
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// AheadBehind counts the commits branch has that base doesn't, and those
+// base has that branch doesn't, in one pass
+func (s *ShellGit) AheadBehind(base, branch string) (ahead, behind int, err error) {
+	out, err := s.run("rev-list", "--left-right", "--count", base+"..."+branch)
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseLeftRight(out)
+}
+
+// parseLeftRight reads "<left>\t<right>" from rev-list --left-right --count
+// as behind and ahead
+func parseLeftRight(out string) (ahead, behind int, err error) {
+	f := strings.Fields(out)
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+	if behind, err = strconv.Atoi(f[0]); err != nil {
+		return 0, 0, err
+	}
+	if ahead, err = strconv.Atoi(f[1]); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// BranchSummaries describes every local branch against base, newest first.
+// Names, dates and, on git 2.41 or later, ahead/behind counts come from a
+// single for-each-ref; older gits count them with one rev-list per branch,
+// run side by side. Conflicts are only predicted when asked for, as that
+// takes a merge-tree per branch.
+func (s *ShellGit) BranchSummaries(base string, conflicts bool) ([]BranchSummary, error) {
+	if err := validateRef(base); err != nil {
+		return nil, fmt.Errorf("invalid base: %w", err)
+	}
+
+	counted := true
+	out, err := s.run("for-each-ref", "--sort=-committerdate", "--format=%(refname:short)%00%(committerdate:unix)%00%(ahead-behind:"+base+")", "refs/heads/")
+	if err != nil {
+		counted = false
+		if out, err = s.run("for-each-ref", "--sort=-committerdate", "--format=%(refname:short)%00%(committerdate:unix)", "refs/heads/"); err != nil {
+			return nil, err
+		}
+	}
+
+	var branches []BranchSummary
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.Split(line, "\x00")
+		if len(f) < 2 || f[0] == "" {
+			continue
+		}
+		b := BranchSummary{Name: f[0]}
+		if ts, err := strconv.ParseInt(f[1], 10, 64); err == nil {
+			b.LastCommit = time.Unix(ts, 0)
+		}
+		if counted && len(f) == 3 {
+			// %(ahead-behind:base) is "<ahead> <behind>"
+			if behind, ahead, err := parseLeftRight(f[2]); err == nil {
+				b.Ahead, b.Behind = ahead, behind
+			}
+		}
+		branches = append(branches, b)
+	}
+
+	parallel(len(branches), queryWorkers, func(i int) {
+		b := &branches[i]
+		if !counted {
+			b.Ahead, b.Behind, _ = s.AheadBehind(base, b.Name)
+		}
+		if conflicts && b.Name != base {
+			if files, err := s.MergeConflicts(base, b.Name); err == nil {
+				b.Conflicts = len(files)
+			}
+		}
+	})
+	return branches, nil
+}
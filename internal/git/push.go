@@ -0,0 +1,91 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PushRefsOptions describes a push of explicit refs rather than the current branch
+type PushRefsOptions struct {
+	Remote   string   // defaults to origin
+	Refspecs []string // <src>[:<dst>], e.g. main or feature:review/feature
+	Tags     bool     // also push all tags
+	Force    bool     // overwrite remote refs using --force-with-lease
+}
+
+// PushRefResult is the outcome for one ref, as reported by git push --porcelain
+type PushRefResult struct {
+	Source      string // local ref, empty for deletions
+	Destination string // remote ref
+	Status      string // new, updated, forced, deleted, up-to-date or rejected
+	Summary     string // git's summary, e.g. "abc123..def456" or "[rejected] (fetch first)"
+}
+
+// OK reports whether the ref was pushed or was already up to date
+func (r PushRefResult) OK() bool {
+	return r.Status != "rejected"
+}
+
+// PushRefs pushes the given refspecs and/or tags and reports the result for
+// each ref. Results are returned even when some refs are rejected.
+func (s *ShellGit) PushRefs(opts PushRefsOptions) ([]PushRefResult, error) {
+	remote := opts.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	if err := validateRef(remote); err != nil {
+		return nil, fmt.Errorf("invalid remote name: %w", err)
+	}
+	if len(opts.Refspecs) == 0 && !opts.Tags {
+		return nil, fmt.Errorf("nothing to push")
+	}
+
+	args := []string{"push", "--porcelain"}
+	if opts.Force {
+		args = append(args, "--force-with-lease")
+	}
+	if opts.Tags {
+		args = append(args, "--tags")
+	}
+	args = append(args, remote)
+	args = append(args, opts.Refspecs...)
+
+	out, err := s.runCapture(args...)
+	results := parsePushPorcelain(out)
+	if err != nil && len(results) == 0 {
+		return nil, err
+	}
+	return results, nil
+}
+
+// parsePushPorcelain parses "<flag>\t<from>:<to>\t<summary>" lines
+func parsePushPorcelain(out string) []PushRefResult {
+	statuses := map[byte]string{
+		' ': "updated",
+		'+': "forced",
+		'-': "deleted",
+		'*': "new",
+		'!': "rejected",
+		'=': "up-to-date",
+	}
+
+	var results []PushRefResult
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 || len(fields[0]) != 1 {
+			continue
+		}
+		status, ok := statuses[fields[0][0]]
+		if !ok {
+			continue
+		}
+		src, dst, _ := strings.Cut(fields[1], ":")
+		results = append(results, PushRefResult{
+			Source:      src,
+			Destination: dst,
+			Status:      status,
+			Summary:     strings.TrimSpace(fields[2]),
+		})
+	}
+	return results
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RewordCommit gives commit, which must be HEAD or one of its ancestors, a
+// new message. The commits after it are rebuilt on top with their trees,
+// authors and messages untouched, so the working tree and index are left
+// alone and nothing can conflict. Signatures on the rewritten commits are
+// dropped, as they would no longer verify. It returns the new hash of
+// commit.
+func (s *ShellGit) RewordCommit(commit, message string) (string, error) {
+	head, err := s.revParse("HEAD")
+	if err != nil {
+		return "", err
+	}
+	target, err := s.revParse(commit)
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %s: %w", commit, err)
+	}
+	if target != head {
+		if _, err := s.run("merge-base", "--is-ancestor", target, head); err != nil {
+			return "", fmt.Errorf("%s is not on the current branch", commit)
+		}
+	}
+
+	rewritten := map[string]string{}
+	reworded, err := s.rewriteCommit(target, message, rewritten)
+	if err != nil {
+		return "", err
+	}
+	rewritten[target] = reworded
+
+	if target != head {
+		out, err := s.run("rev-list", "--reverse", "--topo-order", "--ancestry-path", target+".."+head)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range strings.Fields(out) {
+			if rewritten[c], err = s.rewriteCommit(c, "", rewritten); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	ref := "HEAD"
+	if branch, err := s.CurrentBranch(); err == nil && branch != "" && branch != "HEAD" {
+		ref = "refs/heads/" + branch
+	}
+	if _, err := s.run("update-ref", "-m", "sage reword", ref, rewritten[head], head); err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", ref, err)
+	}
+	return reworded, nil
+}
+
+func (s *ShellGit) revParse(rev string) (string, error) {
+	out, err := s.run("rev-parse", "--verify", "--quiet", "--end-of-options", rev)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// rewriteCommit writes a copy of commit with its parents swapped for their
+// rewritten versions and, unless message is empty, a new message
+func (s *ShellGit) rewriteCommit(commit, message string, rewritten map[string]string) (string, error) {
+	raw, err := s.run("cat-file", "commit", commit)
+	if err != nil {
+		return "", err
+	}
+	header, body, _ := strings.Cut(raw, "\n\n")
+	if message != "" {
+		body = strings.TrimRight(message, "\n") + "\n"
+	}
+
+	var b strings.Builder
+	skipping := false
+	for _, line := range strings.Split(header, "\n") {
+		// Continuation lines of a multi-line header start with a space
+		if strings.HasPrefix(line, " ") {
+			if !skipping {
+				b.WriteString(line + "\n")
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		skipping = key == "gpgsig" || key == "gpgsig-sha256"
+		if skipping {
+			continue
+		}
+		if key == "parent" {
+			if p, ok := rewritten[value]; ok {
+				value = p
+			}
+		}
+		b.WriteString(key + " " + value + "\n")
+	}
+	b.WriteString("\n" + body)
+
+	tmpFile, err := os.CreateTemp("", "sage-commit-msg-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for commit: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(b.String()); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("failed to write commit: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	out, err := s.run("hash-object", "-t", "commit", "-w", "--", tmpFile.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to write commit: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"os"
+	"strings"
+)
+
+// The operations git's sequencer runs, as SequencerInProgress names them
+const (
+	OpCherryPick = "cherry-pick"
+	OpRevert     = "revert"
+)
+
+// CherryPick applies commits onto HEAD in the order given. Merges are picked
+// against their first parent.
+func (s *ShellGit) CherryPick(commits ...string) error {
+	return s.sequence(OpCherryPick, commits)
+}
+
+// Revert commits the inverse of each of commits, in the order given, with
+// git's default messages. Merges are reverted against their first parent.
+func (s *ShellGit) Revert(commits ...string) error {
+	return s.sequence(OpRevert, append([]string{"--no-edit"}, commits...))
+}
+
+func (s *ShellGit) sequence(op string, args []string) error {
+	// -m works for ordinary commits too since git 2.21, but older gits
+	// refuse it, so it's only passed when there's a merge to pick
+	revs := args[:0:0]
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			revs = append(revs, a)
+		}
+	}
+	merges, err := s.run(append([]string{"rev-list", "--no-walk", "--merges"}, revs...)...)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(merges) != "" {
+		args = append([]string{"-m", "1"}, args...)
+	}
+	_, err = s.run(append([]string{op}, args...)...)
+	return err
+}
+
+// SequencerInProgress returns OpCherryPick or OpRevert when one stopped on a
+// commit, or "" when neither is in progress
+func (s *ShellGit) SequencerInProgress() (string, error) {
+	if ok, err := s.gitPathExists("CHERRY_PICK_HEAD"); err != nil || ok {
+		return OpCherryPick, err
+	}
+	if ok, err := s.gitPathExists("REVERT_HEAD"); err != nil || ok {
+		return OpRevert, err
+	}
+	// Once the stopped commit is committed by hand only the todo list is
+	// left, naming the operation on each line
+	path, err := s.run("rev-parse", "--git-path", "sequencer/todo")
+	if err != nil {
+		return "", err
+	}
+	todo, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return "", nil
+	}
+	switch strings.SplitN(strings.TrimSpace(string(todo)), " ", 2)[0] {
+	case "pick", "p":
+		return OpCherryPick, nil
+	case "revert":
+		return OpRevert, nil
+	}
+	return "", nil
+}
+
+func (s *ShellGit) gitPathExists(name string) (bool, error) {
+	path, err := s.run("rev-parse", "--git-path", name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(strings.TrimSpace(path))
+	return err == nil, nil
+}
+
+// SequencerContinue carries on with the stopped op once its conflicts are
+// resolved, keeping the commit messages as there is no editor to change them
+func (s *ShellGit) SequencerContinue(op string) error {
+	_, err := s.run("-c", "core.editor=true", op, "--continue")
+	return err
+}
+
+// SequencerAbort stops op and puts the branch back where it started
+func (s *ShellGit) SequencerAbort(op string) error {
+	_, err := s.run(op, "--abort")
+	return err
+}
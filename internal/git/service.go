@@ -1,26 +1,32 @@
 package git
 
 import (
+	"regexp"
 	"strings"
 	"time"
 )
 
 type Service interface {
 	IsRepo() (bool, error)
+	HasCommits() (bool, error)
 	IsClean() (bool, error)
 	StageAll() error
 	StageAllExcept(excludePaths []string) error
 	IsPathStaged(path string) (bool, error)
 	Commit(msg string, allowEmpty bool, stageAll bool) error
+	CommitPaths(msg string, allowEmpty bool, paths []string) error
+	RewordCommit(commit, message string) (string, error)
 	CurrentBranch() (string, error)
 	Push(branch string, force bool) error
 	PushWithLease(branch string) error
+	PushRefs(opts PushRefsOptions) ([]PushRefResult, error)
 	GetDiff() (string, error)
 	DefaultBranch() (string, error)
 	MergedBranches(base string) ([]string, error)
 	DeleteBranch(name string) error
 	DeleteRemoteBranch(name string) error
 	FetchAll() error
+	FetchRemotes(remotes []string, progress FetchProgress) error
 	Checkout(name string) error
 	Pull() error
 	PullFF() error
@@ -35,7 +41,8 @@ type Service interface {
 	StatusPorcelain() (string, error)
 	ResetSoft(ref string) error
 	ListBranches() ([]string, error)
-	Log(branch string, limit int, stats, all bool) (string, error)
+	Commits(opts CommitsOptions) ([]Commit, error)
+	Log(opts LogOptions) (*LogReader, error)
 	SquashCommits(startCommit string) error
 	IsHeadBranch(branch string) (bool, error)
 	GetFirstCommit() (string, error)
@@ -43,6 +50,9 @@ type Service interface {
 	GetBranchLastCommit(branch string) (time.Time, error)
 	GetBranchCommitCount(branch string) (int, error)
 	GetBranchMergeConflicts(branch string) (int, error)
+	AheadBehind(base, branch string) (ahead, behind int, err error)
+	BranchSummaries(base string, conflicts bool) ([]BranchSummary, error)
+	MergeConflicts(ours, theirs string) ([]string, error)
 	Stash(message string) error
 	StashPop() error
 	StashList() ([]string, error)
@@ -55,11 +65,25 @@ type Service interface {
 	GetRepoPath() (string, error)
 	Run(args ...string) (string, error)
 	StagedDiff() (string, error)
-	GrepDiff(diff string, pattern string) ([]string, error)
+	GrepDiff(diff string, patterns []*regexp.Regexp) []DiffMatch
 	ListConflictedFiles() (string, error)
 	GetConfigValue(string) (string, error)
 	MergeContinue() error
 	RebaseContinue() error
+	IsApplyingPatches() (bool, error)
+	Upstream(branch string) (string, error)
+	BranchesAt(ref string) ([]string, error)
+	HooksDir() (string, error)
+	CountObjects() (ObjectCounts, error)
+	LargeBlobs(minSize int64, revs ...string) ([]Blob, error)
+	Submodules() ([]Submodule, error)
+	UpdateSubmodules(paths ...string) error
+	BlamePorcelain(path string) ([]BlameLine, error)
+	CherryPick(commits ...string) error
+	Revert(commits ...string) error
+	SequencerInProgress() (string, error)
+	SequencerContinue(op string) error
+	SequencerAbort(op string) error
 }
 
 // SetConfig sets a git config value
@@ -2,12 +2,18 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/crazywolf132/sage/internal/batch"
 )
 
 // ShellGit implements the Service interface using shell commands to interact with Git
@@ -187,9 +193,13 @@ func SetupSecureCommand(prog string, args ...string) (*exec.Cmd, error) {
 	// Add Git-specific environment variables for Git commands
 	if prog == "git" {
 		cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0") // Disable git credential prompting
+		if batch.Enabled() {
+			// Merges take git's message instead of opening an editor
+			cmd.Env = append(cmd.Env, "GIT_MERGE_AUTOEDIT=no")
+		}
 
 		// Add other necessary git environment variables if they exist
-		for _, envVar := range []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_CONFIG"} {
+		for _, envVar := range []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_CONFIG", "GIT_AUTHOR_DATE", "GIT_COMMITTER_DATE"} {
 			if val := os.Getenv(envVar); val != "" {
 				cmd.Env = append(cmd.Env, envVar+"="+val)
 			}
@@ -212,6 +222,16 @@ func (s *ShellGit) Run(args ...string) (string, error) {
 
 // run is the internal implementation of Run
 func (s *ShellGit) run(args ...string) (string, error) {
+	out, err := s.runCapture(args...)
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// runCapture is like run but also returns whatever git printed to stdout when
+// it fails, for commands such as push --porcelain that report per-ref results
+func (s *ShellGit) runCapture(args ...string) (string, error) {
 	// Validate all arguments
 	for i, arg := range args {
 		// Skip flags
@@ -229,13 +249,14 @@ func (s *ShellGit) run(args ...string) (string, error) {
 			continue
 		}
 
-		// Skip validation for temporary files used for commit messages with -F flag
-		if i > 0 && (args[i-1] == "-F" || args[i-1] == "--file") {
+		// Skip validation for temporary files used for commit messages with -F
+		// flag, or for whole commits written with hash-object
+		if i > 0 && (args[i-1] == "-F" || args[i-1] == "--file" || (args[0] == "hash-object" && args[i-1] == "--")) {
 			// Basic validation to ensure it's a temporary file path
 			if strings.Contains(arg, "/tmp/") || strings.Contains(arg, "\\Temp\\") || strings.HasPrefix(arg, "sage-commit-msg-") {
-				// Still perform basic command injection checks
-				if err := validatePath(arg); err != nil {
-					return "", fmt.Errorf("invalid file path: %w", err)
+				// Temp files are absolute, so only check for injection and traversal
+				if err := ValidateCommandArg(arg); err != nil || strings.Contains(arg, "..") {
+					return "", fmt.Errorf("invalid file path: %s", arg)
 				}
 				continue
 			}
@@ -252,6 +273,15 @@ func (s *ShellGit) run(args ...string) (string, error) {
 			continue
 		}
 
+		// rev-parse --verify only resolves what it's given, so revisions such
+		// as HEAD~2 or v1.2^{commit} are checked for injection only
+		if i > 1 && args[0] == "rev-parse" && args[1] == "--verify" {
+			if err := ValidateCommandArg(arg); err != nil {
+				return "", fmt.Errorf("invalid revision: %w", err)
+			}
+			continue
+		}
+
 		// Skip validation for commit messages that follow -m flag
 		if i > 0 && (args[i-1] == "-m" || args[i-1] == "--message") {
 			// For commit messages, use the less strict ValidateCommandArg
@@ -261,22 +291,53 @@ func (s *ShellGit) run(args ...string) (string, error) {
 			continue
 		}
 
+		// Remote URLs contain characters refs can't, so only check them for injection
+		if i == 3 && args[0] == "remote" && (args[1] == "add" || args[1] == "set-url") {
+			if err := ValidateCommandArg(arg); err != nil {
+				return "", fmt.Errorf("invalid remote URL: %w", err)
+			}
+			continue
+		}
+
+		// Allow <src>:<dst> refspecs for push, validating each side as a ref
+		if i > 0 && args[0] == "push" && strings.Count(arg, ":") == 1 {
+			parts := strings.SplitN(arg, ":", 2)
+			if err := validateRef(parts[0]); err != nil {
+				return "", fmt.Errorf("invalid refspec: %w", err)
+			}
+			if err := validateRef(parts[1]); err != nil {
+				return "", fmt.Errorf("invalid refspec: %w", err)
+			}
+			continue
+		}
+
 		if err := validateRef(arg); err != nil {
-			return "", fmt.Errorf("invalid argument: %w", err)
+			// Paths after a -- separator can be named what refs can't, such as yarn.lock
+			if !slices.Contains(args[:i], "--") || validatePath(arg) != nil {
+				return "", fmt.Errorf("invalid argument: %w", err)
+			}
 		}
 	}
 
+	if skipForDryRun(args) {
+		return "", nil
+	}
+	if invalidatesCache(args) {
+		defer InvalidateCache()
+	}
+
 	// Use our secure command setup function
 	cmd, err := setupSecureCommand("git", args...)
 	if err != nil {
 		return "", err
 	}
 
+	cmd.Env = withEnglishMessages(cmd.Env)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	out, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("%v: %s", err, stderr.String())
+		return string(out), newError(args, err, stderr.String())
 	}
 	return string(out), nil
 }
@@ -338,6 +399,13 @@ func (s *ShellGit) runInteractive(args ...string) error {
 		}
 	}
 
+	if skipForDryRun(args) {
+		return nil
+	}
+	if invalidatesCache(args) {
+		defer InvalidateCache()
+	}
+
 	// Use our secure command setup function
 	cmd, err := setupSecureCommand("git", args...)
 	if err != nil {
@@ -347,22 +415,49 @@ func (s *ShellGit) runInteractive(args ...string) error {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		// stderr went to the terminal, so judge the failure by what it left
+		// behind instead
+		e := newError(args, err, "")
+		if unmerged, lsErr := s.run("ls-files", "--unmerged"); lsErr == nil && strings.TrimSpace(unmerged) != "" {
+			e.Code = MergeConflict
+		}
+		return e
+	}
+	return nil
 }
 
 // IsRepo checks if the current directory is a git repository
 // Returns true if it is, false if not, and any error encountered
 func (s *ShellGit) IsRepo() (bool, error) {
 	_, err := s.run("rev-parse", "--git-dir")
-	if err != nil && strings.Contains(err.Error(), "not a git repository") {
+	if errors.Is(err, ErrNotARepo) {
 		return false, nil
 	}
 	return err == nil, nil
 }
 
+// HasCommits reports whether HEAD points at a commit. It is false in a freshly
+// initialised repository, where most history-based commands cannot run.
+func (s *ShellGit) HasCommits() (bool, error) {
+	if _, err := s.run("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		if inRepo, repoErr := s.IsRepo(); repoErr != nil || !inRepo {
+			return false, fmt.Errorf("not a git repository")
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
 // CurrentBranch returns the name of the current git branch
 func (s *ShellGit) CurrentBranch() (string, error) {
 	out, err := s.run("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		// An unborn branch (no commits yet) still has a name
+		if name, symErr := s.run("symbolic-ref", "--short", "HEAD"); symErr == nil {
+			return strings.TrimSpace(name), nil
+		}
+	}
 	return strings.TrimSpace(out), err
 }
 
@@ -387,7 +482,9 @@ func (s *ShellGit) StageAll() error {
 // If stageAll is true, automatically stages all changes before committing
 func (s *ShellGit) Commit(msg string, allowEmpty bool, stageAll bool) error {
 	// Check if the message contains newlines or other special characters
-	hasNewlines := strings.Contains(msg, "\n")
+	// Messages -m can't carry (newlines, or characters like the parentheses in
+	// "feat(scope): ...") go through a file instead
+	hasNewlines := strings.Contains(msg, "\n") || ValidateCommandArg(msg) != nil
 
 	args := []string{"commit"}
 	if stageAll {
@@ -428,7 +525,9 @@ func (s *ShellGit) Commit(msg string, allowEmpty bool, stageAll bool) error {
 // CommitAmend amends the last commit using '--amend'
 func (s *ShellGit) CommitAmend(msg string, allowEmpty bool, stageAll bool) error {
 	// Check if the message contains newlines or other special characters
-	hasNewlines := strings.Contains(msg, "\n")
+	// Messages -m can't carry (newlines, or characters like the parentheses in
+	// "feat(scope): ...") go through a file instead
+	hasNewlines := strings.Contains(msg, "\n") || ValidateCommandArg(msg) != nil
 
 	args := []string{"commit", "--amend"}
 	if stageAll {
@@ -466,6 +565,44 @@ func (s *ShellGit) CommitAmend(msg string, allowEmpty bool, stageAll bool) error
 	return err
 }
 
+// CommitPaths commits only the given paths using 'git commit --only',
+// leaving any other staged changes in the index
+func (s *ShellGit) CommitPaths(msg string, allowEmpty bool, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to commit")
+	}
+
+	args := []string{"commit", "--only"}
+	if allowEmpty {
+		args = append(args, "--allow-empty")
+	}
+
+	if !strings.Contains(msg, "\n") && ValidateCommandArg(msg) == nil {
+		args = append(args, "-m", msg, "--")
+		_, err := s.run(append(args, paths...)...)
+		return err
+	}
+
+	// For multi-line messages, use a temporary file with -F flag
+	tmpFile, err := os.CreateTemp("", "sage-commit-msg-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for commit message: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.WriteString(msg); err != nil {
+		return fmt.Errorf("failed to write commit message to temporary file: %w", err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	args = append(args, "-F", tmpFile.Name(), "--")
+	_, err = s.run(append(args, paths...)...)
+	return err
+}
+
 // Push pushes the specified branch to the remote repository
 // If force is true, performs a force push
 func (s *ShellGit) Push(branch string, force bool) error {
@@ -473,26 +610,19 @@ func (s *ShellGit) Push(branch string, force bool) error {
 		return fmt.Errorf("invalid branch name: %w", err)
 	}
 
-	// Try normal push first
-	args := []string{"push", "origin", branch}
+	args := []string{"push"}
+	// Track a branch's first push so later pulls (sage sync) know where to
+	// pull from, but leave an upstream the user already chose alone
+	if upstream, err := s.Upstream(branch); err == nil && upstream == "" {
+		args = append(args, "--set-upstream")
+	}
 	if force {
-		args = []string{"push", "--force", "origin", branch}
+		args = append(args, "--force")
 	}
+	args = append(args, "origin", branch)
 
 	_, err := s.run(args...)
-	if err != nil {
-		// If the error is about missing upstream, set it up automatically
-		if strings.Contains(err.Error(), "no upstream branch") ||
-			strings.Contains(err.Error(), "set-upstream") {
-			// Set the upstream branch and try again
-			if force {
-				return s.runInteractive("push", "--set-upstream", "--force", "origin", branch)
-			}
-			return s.runInteractive("push", "--set-upstream", "origin", branch)
-		}
-		return err
-	}
-	return nil
+	return err
 }
 
 // PushWithLease pushes the specified branch to the remote repository using --force-with-lease
@@ -506,8 +636,7 @@ func (s *ShellGit) PushWithLease(branch string) error {
 	_, err := s.run("push", "--force-with-lease", "origin", branch)
 	if err != nil {
 		// If the error is about missing upstream, set it up automatically
-		if strings.Contains(err.Error(), "no upstream branch") ||
-			strings.Contains(err.Error(), "set-upstream") {
+		if errors.Is(err, ErrNoUpstream) {
 			// Set the upstream branch and try again with force-with-lease
 			return s.runInteractive("push", "--set-upstream", "--force-with-lease", "origin", branch)
 		}
@@ -516,18 +645,21 @@ func (s *ShellGit) PushWithLease(branch string) error {
 	return nil
 }
 
-// DefaultBranch returns the name of the default branch (usually main or master)
+// DefaultBranch returns the name of the default branch (usually main or master).
+// The answer is cached until a fetch or remote change invalidates it.
 func (s *ShellGit) DefaultBranch() (string, error) {
-	out, err := s.run("symbolic-ref", "refs/remotes/origin/HEAD")
-	if err != nil {
-		return "", err
-	}
-	out = strings.TrimSpace(out)
-	parts := strings.Split(out, "/")
-	if len(parts) < 1 {
-		return "main", nil
-	}
-	return parts[len(parts)-1], nil
+	return cachedDefaultBranch(func() (string, error) {
+		out, err := s.run("symbolic-ref", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return "", err
+		}
+		out = strings.TrimSpace(out)
+		parts := strings.Split(out, "/")
+		if len(parts) < 1 {
+			return "main", nil
+		}
+		return parts[len(parts)-1], nil
+	})
 }
 
 // MergedBranches returns a list of branches that have been merged into the specified base branch
@@ -560,10 +692,10 @@ func (s *ShellGit) DeleteBranch(name string) error {
 	return err
 }
 
-// FetchAll fetches all remotes and prunes deleted remote branches
+// FetchAll fetches all remotes, in parallel, and prunes deleted remote
+// branches
 func (s *ShellGit) FetchAll() error {
-	_, err := s.run("fetch", "--all", "--prune")
-	return err
+	return s.FetchRemotes(nil, nil)
 }
 
 // Checkout switches to the specified branch or commit
@@ -628,13 +760,36 @@ func (s *ShellGit) RebaseAbort() error {
 	return s.runInteractive("rebase", "--abort")
 }
 
-// IsRebasing checks if a rebase is currently in progress
+// IsRebasing checks if a rebase is currently in progress. REBASE_HEAD is
+// left behind once a rebase that stopped finishes, so this looks for the
+// rebase's state directory instead; rebase-apply is also git am's.
 func (s *ShellGit) IsRebasing() (bool, error) {
-	_, err := s.run("rev-parse", "--verify", "REBASE_HEAD")
-	if err != nil && strings.Contains(err.Error(), "not a valid object name") {
-		return false, nil
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		path, err := s.run("rev-parse", "--git-path", dir)
+		if err != nil {
+			return false, err
+		}
+		if _, err := os.Stat(strings.TrimSpace(path)); err != nil {
+			continue
+		}
+		if dir == "rebase-apply" {
+			if am, _ := s.IsApplyingPatches(); am {
+				continue
+			}
+		}
+		return true, nil
 	}
-	return (err == nil), nil
+	return false, nil
+}
+
+// IsApplyingPatches checks if a git am session is stopped on a patch
+func (s *ShellGit) IsApplyingPatches() (bool, error) {
+	path, err := s.run("rev-parse", "--git-path", "rebase-apply/applying")
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(strings.TrimSpace(path))
+	return err == nil, nil
 }
 
 // StatusPorcelain returns the git status in porcelain format
@@ -660,37 +815,6 @@ func (s *ShellGit) ListBranches() ([]string, error) {
 	return lines, nil
 }
 
-// Log returns the git log with custom formatting
-// If branch is specified, shows log for that branch
-// If limit > 0, limits the number of entries (unless all is true)
-// If stats is true, includes numstat information
-func (s *ShellGit) Log(branch string, limit int, stats, all bool) (string, error) {
-	// Build the git log command with a custom format
-	args := []string{
-		"log",
-		"--pretty=format:%H%x00%an%x00%at%x00%s", // Use null bytes as separators
-	}
-
-	if limit > 0 && !all {
-		args = append(args, "-n", strconv.Itoa(limit))
-	}
-
-	if stats {
-		args = append(args, "--numstat")
-	}
-
-	if branch != "" {
-		args = append(args, branch)
-	}
-
-	out, err := s.run(args...)
-	if err != nil {
-		return "", err
-	}
-
-	return out, nil
-}
-
 // GetDiff returns the current diff
 // First checks for staged changes, then unstaged if no staged changes exist
 func (s *ShellGit) GetDiff() (string, error) {
@@ -858,6 +982,29 @@ func (s *ShellGit) GetBranchMergeConflicts(branch string) (int, error) {
 	return strings.Count(out, "<<<<<<<"), nil
 }
 
+// MergeConflicts returns the files that would conflict merging theirs into
+// ours, without touching the index or working tree. It needs git 2.38 or
+// later for merge-tree --write-tree.
+func (s *ShellGit) MergeConflicts(ours, theirs string) ([]string, error) {
+	out, err := s.runCapture("merge-tree", "--write-tree", "--name-only", "--no-messages", ours, theirs)
+	if err != nil {
+		// Status 1 means the merge has conflicts; anything else is a failure
+		var gitErr *Error
+		if !errors.As(err, &gitErr) || gitErr.Status != 1 {
+			return nil, err
+		}
+	}
+	// The first line is the merged tree, then one conflicted file per line
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var files []string
+	for _, f := range lines[1:] {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
 // MergeContinue continues a merge operation after conflicts are resolved
 // It runs: git commit --no-edit
 func (s *ShellGit) MergeContinue() error {
@@ -865,10 +1012,12 @@ func (s *ShellGit) MergeContinue() error {
 	return err
 }
 
-// RebaseContinue continues a rebase operation after conflicts are resolved
-// It runs: git rebase --continue
+// RebaseContinue continues a rebase operation after conflicts are resolved.
+// Like MergeContinue it keeps each commit's message: git runs without a
+// terminal here, so an editor would hang or fail the continue.
+// It runs: git -c core.editor=true rebase --continue
 func (s *ShellGit) RebaseContinue() error {
-	_, err := s.run("rebase", "--continue")
+	_, err := s.run("-c", "core.editor=true", "rebase", "--continue")
 	return err
 }
 
@@ -882,6 +1031,53 @@ func (s *ShellGit) ListConflictedFiles() (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// ConflictDiff3 redoes the three-way merge of a conflicted path from its
+// index stages and returns the result with diff3-style markers, so every
+// hunk carries its base whatever merge.conflictStyle is. The working tree
+// is left alone. Paths with no base, such as ones both sides added, fail.
+func (s *ShellGit) ConflictDiff3(path string) (string, error) {
+	if err := validatePath(path); err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "sage-conflict-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	// Stages 2, 1 and 3 are ours, the base and theirs, in merge-file's order
+	var files []string
+	for _, stage := range []string{"2", "1", "3"} {
+		cmd, err := setupSecureCommand("git", "show", ":"+stage+":"+path)
+		if err != nil {
+			return "", err
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", newError([]string{"show", ":" + stage + ":" + path}, err, stderr.String())
+		}
+		f := filepath.Join(dir, stage)
+		if err := os.WriteFile(f, out, 0600); err != nil {
+			return "", err
+		}
+		files = append(files, f)
+	}
+
+	cmd, err := setupSecureCommand("git", append([]string{"merge-file", "-p", "--diff3", "-L", "ours", "-L", "base", "-L", "theirs"}, files...)...)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	// merge-file exits with the number of conflicts it left
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128) {
+		return "", newError([]string{"merge-file"}, err, "")
+	}
+	return string(out), nil
+}
+
 // Stash saves the current changes to the stash with a message
 func (g *ShellGit) Stash(message string) error {
 	_, err := g.run("stash", "push", "-m", message)
@@ -981,36 +1177,9 @@ func (g *ShellGit) StagedDiff() (string, error) {
 	return g.Run("diff", "--cached")
 }
 
-// GrepDiff searches for a pattern in a diff and returns matching lines
-func (g *ShellGit) GrepDiff(diff string, pattern string) ([]string, error) {
-	// Validate the pattern for command injection
-	if err := validateCommandArg(pattern); err != nil {
-		return nil, fmt.Errorf("invalid grep pattern: %w", err)
-	}
-
-	// Use our secure command setup function
-	cmd, err := setupSecureCommand("grep", "-P", pattern)
-	if err != nil {
-		return nil, err
-	}
-
-	cmd.Stdin = strings.NewReader(diff)
-
-	output, err := cmd.Output()
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			// grep returns exit code 1 when no matches are found
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// Split output into lines and remove empty ones
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 1 && lines[0] == "" {
-		return nil, nil
-	}
-	return lines, nil
+// GrepDiff matches patterns against the lines added by diff
+func (g *ShellGit) GrepDiff(diff string, patterns []*regexp.Regexp) []DiffMatch {
+	return ScanDiff(diff, patterns)
 }
 
 // GetConfigValue returns the value of a Git configuration item
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"strings"
+)
+
+// Submodule is a repository checked out inside this one, as git submodule
+// status reports it. Nested submodules have paths from the top-level root.
+type Submodule struct {
+	Path        string
+	Recorded    string // the commit the superproject's index records
+	Current     string // the commit checked out, "" when not initialized
+	Initialized bool
+	Conflicted  bool // a merge left different commits on each side
+}
+
+// Drifted reports whether the submodule is checked out at a commit other
+// than the one recorded
+func (s Submodule) Drifted() bool {
+	return s.Initialized && !s.Conflicted && s.Current != s.Recorded
+}
+
+// Submodules lists the submodules, recursively
+func (s *ShellGit) Submodules() ([]Submodule, error) {
+	current, err := s.run("submodule", "status", "--recursive")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(current) == "" {
+		return nil, nil
+	}
+	recorded, err := s.run("submodule", "status", "--recursive", "--cached")
+	if err != nil {
+		return nil, err
+	}
+	commits := map[string]string{}
+	for _, line := range strings.Split(recorded, "\n") {
+		if _, hash, path, ok := parseSubmoduleLine(line); ok {
+			commits[path] = hash
+		}
+	}
+
+	var subs []Submodule
+	for _, line := range strings.Split(current, "\n") {
+		state, hash, path, ok := parseSubmoduleLine(line)
+		if !ok {
+			continue
+		}
+		sub := Submodule{Path: path, Recorded: commits[path], Initialized: state != '-', Conflicted: state == 'U'}
+		if sub.Initialized {
+			sub.Current = hash
+		}
+		if sub.Recorded == "" {
+			sub.Recorded = hash
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// parseSubmoduleLine splits a line of git submodule status, such as
+// "+1a2b3c... libs/core (v1.2-3-g1a2b3c)", into its state, commit and path
+func parseSubmoduleLine(line string) (state byte, hash, path string, ok bool) {
+	if len(line) < 3 {
+		return 0, "", "", false
+	}
+	state = line[0]
+	hash, path, ok = strings.Cut(line[1:], " ")
+	if !ok {
+		return 0, "", "", false
+	}
+	// What git describe says about the commit follows in parentheses
+	if i := strings.LastIndex(path, " ("); i > 0 && strings.HasSuffix(path, ")") {
+		path = path[:i]
+	}
+	return state, hash, path, path != ""
+}
+
+// UpdateSubmodules checks the submodules at paths, or all of them, out at
+// the commits recorded, initializing and cloning any that need it
+func (s *ShellGit) UpdateSubmodules(paths ...string) error {
+	args := []string{"submodule", "update", "--init", "--recursive"}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	_, err := s.run(args...)
+	return err
+}
@@ -0,0 +1,159 @@
+// Package issues links branches to tickets in an issue tracker. Ticket IDs
+// are read from branch names such as feat/PROJ-123-login, and looked up in
+// JIRA or Linear, chosen with the issues.provider setting.
+package issues
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+)
+
+// Providers accepted by the issues.provider setting
+const (
+	JIRA   = "jira"
+	Linear = "linear"
+)
+
+// DefaultPattern finds JIRA and Linear style IDs such as PROJ-123
+const DefaultPattern = `[A-Z][A-Z0-9]+-[0-9]+`
+
+// ErrNotConfigured is returned by New when no tracker is set up
+var ErrNotConfigured = errors.New("no issue tracker configured")
+
+// Ticket is an issue as the tracker describes it
+type Ticket struct {
+	ID     string
+	Title  string
+	Status string
+	URL    string
+}
+
+// Tracker is the issue tracker API sage uses
+type Tracker interface {
+	// Ticket fetches the ticket with the given ID, e.g. PROJ-123
+	Ticket(id string) (*Ticket, error)
+	// Transition moves the ticket to the named status, e.g. Done. For JIRA
+	// the transition's own name works too.
+	Transition(id, status string) error
+}
+
+// requestTimeout bounds tracker calls, which sit in the way of commits
+const requestTimeout = 10 * time.Second
+
+// IDFromBranch returns the ticket ID in branch, matched by
+// commit.ticket_pattern or DefaultPattern, or "" if there is none. A
+// pattern with a capture group returns the group.
+func IDFromBranch(branch string) (string, error) {
+	re, err := ticketPattern()
+	if err != nil {
+		return "", err
+	}
+	m := re.FindStringSubmatch(branch)
+	if m == nil {
+		return "", nil
+	}
+	return matchedID(m), nil
+}
+
+// IDsIn returns every distinct ticket ID in text, such as a commit message,
+// in the order they appear
+func IDsIn(text string) ([]string, error) {
+	re, err := ticketPattern()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		if id := matchedID(m); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ticketPattern compiles commit.ticket_pattern, or DefaultPattern
+func ticketPattern() (*regexp.Regexp, error) {
+	pattern := config.Get("commit.ticket_pattern", true)
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit.ticket_pattern: %w", err)
+	}
+	return re, nil
+}
+
+// matchedID picks the ID out of a match, preferring a capture group
+func matchedID(m []string) string {
+	if len(m) > 1 && m[1] != "" {
+		return m[1]
+	}
+	return m[0]
+}
+
+// Provider returns the configured tracker. Without issues.provider it goes
+// by which credentials are set, and returns "" when there are none.
+func Provider() string {
+	if p := strings.ToLower(strings.TrimSpace(config.Get("issues.provider", true))); p != "" {
+		return p
+	}
+	switch {
+	case setting("jira.base_url", "JIRA_BASE_URL") != "":
+		return JIRA
+	case linearToken() != "":
+		return Linear
+	}
+	return ""
+}
+
+// New returns a client for the configured tracker, or ErrNotConfigured
+func New() (Tracker, error) {
+	// The constructors return nil pointers on failure, which must not become
+	// non-nil Trackers
+	var (
+		t   Tracker
+		err error
+	)
+	switch p := Provider(); p {
+	case "":
+		return nil, ErrNotConfigured
+	case JIRA:
+		var c *jiraClient
+		if c, err = newJIRAClient(); err == nil {
+			t = c
+		}
+	case Linear:
+		var c *linearClient
+		if c, err = newLinearClient(); err == nil {
+			t = c
+		}
+	default:
+		err = fmt.Errorf("unknown issues.provider %q. Use %q or %q", p, JIRA, Linear)
+	}
+	return t, err
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
+
+// setting returns the first of the environment variables that is set, or
+// the config value for key
+func setting(key string, envVars ...string) string {
+	for _, name := range envVars {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return config.Get(key, true)
+}
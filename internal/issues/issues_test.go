@@ -0,0 +1,122 @@
+package issues
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDFromBranch(t *testing.T) {
+	tests := map[string]string{
+		"feat/PROJ-123-login": "PROJ-123",
+		"ENG-7":               "ENG-7",
+		"fix/typo":            "",
+		"proj-123-lowercase":  "",
+	}
+	for branch, want := range tests {
+		got, err := IDFromBranch(branch)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, branch)
+	}
+}
+
+func TestIDsIn(t *testing.T) {
+	ids, err := IDsIn("fix: PROJ-12 login (#40)\n\nRefs ENG-7, PROJ-12")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PROJ-12", "ENG-7"}, ids)
+
+	ids, err = IDsIn("chore: tidy up")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestJIRATicketAndTransition(t *testing.T) {
+	var moved string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "dev@acme.io" || pass != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /rest/api/2/issue/PROJ-1":
+			io.WriteString(w, `{"key": "PROJ-1", "fields": {"summary": "Fix login", "status": {"name": "In Review"}}}`)
+		case "GET /rest/api/2/issue/PROJ-1/transitions":
+			io.WriteString(w, `{"transitions": [{"id": "11", "name": "Start", "to": {"name": "In Progress"}},
+				{"id": "31", "name": "Ship it", "to": {"name": "Done"}}]}`)
+		case "POST /rest/api/2/issue/PROJ-1/transitions":
+			var body struct {
+				Transition struct{ ID string } `json:"transition"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			moved = body.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	j := &jiraClient{client: srv.Client(), baseURL: srv.URL, email: "dev@acme.io", token: "token"}
+
+	ticket, err := j.Ticket("PROJ-1")
+	require.NoError(t, err)
+	assert.Equal(t, Ticket{ID: "PROJ-1", Title: "Fix login", Status: "In Review", URL: srv.URL + "/browse/PROJ-1"}, *ticket)
+
+	require.NoError(t, j.Transition("PROJ-1", "done"))
+	assert.Equal(t, "31", moved)
+
+	err = j.Transition("PROJ-1", "Archived")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "In Progress, Done")
+
+	_, err = j.Ticket("PROJ-404")
+	assert.Error(t, err)
+}
+
+func TestLinearTicketAndTransition(t *testing.T) {
+	var vars map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_api_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Variables["stateId"] != nil {
+			vars = req.Variables
+			io.WriteString(w, `{"data": {"issueUpdate": {"success": true}}}`)
+			return
+		}
+		if req.Variables["id"] != "ENG-9" {
+			io.WriteString(w, `{"data": {"issue": null}, "errors": [{"message": "Entity not found"}]}`)
+			return
+		}
+		io.WriteString(w, `{"data": {"issue": {"id": "uuid-9", "identifier": "ENG-9", "title": "Speed up sync",
+			"url": "https://linear.app/acme/issue/ENG-9", "state": {"name": "Todo"},
+			"team": {"states": {"nodes": [{"id": "s1", "name": "Todo"}, {"id": "s2", "name": "Done"}]}}}}}`)
+	}))
+	defer srv.Close()
+
+	l := &linearClient{client: srv.Client(), apiURL: srv.URL, token: "lin_api_key"}
+
+	ticket, err := l.Ticket("ENG-9")
+	require.NoError(t, err)
+	assert.Equal(t, Ticket{ID: "ENG-9", Title: "Speed up sync", Status: "Todo", URL: "https://linear.app/acme/issue/ENG-9"}, *ticket)
+
+	require.NoError(t, l.Transition("ENG-9", "Done"))
+	assert.Equal(t, map[string]any{"id": "uuid-9", "stateId": "s2"}, vars)
+
+	assert.ErrorContains(t, l.Transition("ENG-9", "Shipped"), "Todo, Done")
+
+	_, err = l.Ticket("ENG-1")
+	assert.ErrorContains(t, err, "Entity not found")
+}
@@ -0,0 +1,154 @@
+package issues
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+// jiraClient talks to the JIRA REST API (v2), which Cloud and Server share
+type jiraClient struct {
+	client  *http.Client
+	baseURL string // e.g. https://acme.atlassian.net
+	email   string // with an API token on Cloud; empty for a Server access token
+	token   string
+}
+
+func newJIRAClient() (*jiraClient, error) {
+	baseURL := strings.TrimRight(setting("jira.base_url", "JIRA_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("JIRA needs jira.base_url, e.g. 'sage config set jira.base_url https://acme.atlassian.net'")
+	}
+	token := setting("jira.token", "JIRA_API_TOKEN")
+	if token == "" {
+		return nil, exitcode.Errorf(exitcode.Auth, `JIRA credentials not found. Please either:
+1. Set JIRA_EMAIL and JIRA_API_TOKEN environment variables
+2. Run 'sage config set jira.email YOU' and 'sage config set jira.token TOKEN'`)
+	}
+	return &jiraClient{
+		client:  newHTTPClient(),
+		baseURL: baseURL,
+		email:   setting("jira.email", "JIRA_EMAIL"),
+		token:   token,
+	}, nil
+}
+
+func (j *jiraClient) do(method, u string, body any) ([]byte, error) {
+	var buf io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		buf = bytes.NewReader(data)
+	}
+
+	if dryrun.Enabled() && method != "GET" {
+		data, _ := json.Marshal(body)
+		dryrun.Record("JIRA API %s %s %s", method, u, data)
+		return []byte("{}"), nil
+	}
+
+	req, err := http.NewRequest(method, u, buf)
+	if err != nil {
+		return nil, err
+	}
+	if j.email != "" {
+		req.SetBasicAuth(j.email, j.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, exitcode.New(gh.StatusExitCode(resp.StatusCode), fmt.Errorf("JIRA API %s %s returned %d:\n%s",
+			method, u, resp.StatusCode, string(msg)))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (j *jiraClient) issueURL(id string, suffix string) string {
+	return fmt.Sprintf("%s/rest/api/2/issue/%s%s", j.baseURL, url.PathEscape(id), suffix)
+}
+
+func (j *jiraClient) Ticket(id string) (*Ticket, error) {
+	data, err := j.do("GET", j.issueURL(id, "?fields=summary,status"), nil)
+	if err != nil {
+		return nil, err
+	}
+	var issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &issue); err != nil {
+		return nil, err
+	}
+	if issue.Key == "" {
+		issue.Key = id
+	}
+	return &Ticket{
+		ID:     issue.Key,
+		Title:  issue.Fields.Summary,
+		Status: issue.Fields.Status.Name,
+		URL:    j.baseURL + "/browse/" + url.PathEscape(issue.Key),
+	}, nil
+}
+
+// Transition looks for a transition named status, or one leading to a
+// status of that name, since workflows decide which moves are possible
+func (j *jiraClient) Transition(id, status string) error {
+	data, err := j.do("GET", j.issueURL(id, "/transitions"), nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.Name, status) || strings.EqualFold(t.To.Name, status) {
+			_, err := j.do("POST", j.issueURL(id, "/transitions"), map[string]any{
+				"transition": map[string]string{"id": t.ID},
+			})
+			return err
+		}
+		names = append(names, t.To.Name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%s can't be moved to %s: its workflow allows no transitions from here", id, status)
+	}
+	return fmt.Errorf("%s can't be moved to %s; it can go to %s", id, status, strings.Join(names, ", "))
+}
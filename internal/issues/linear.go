@@ -0,0 +1,172 @@
+package issues
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/exitcode"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+const linearAPI = "https://api.linear.app/graphql"
+
+// linearClient talks to the Linear GraphQL API
+type linearClient struct {
+	client *http.Client
+	apiURL string
+	token  string
+}
+
+func linearToken() string {
+	return setting("linear.token", "LINEAR_API_KEY")
+}
+
+func newLinearClient() (*linearClient, error) {
+	token := linearToken()
+	if token == "" {
+		return nil, exitcode.Errorf(exitcode.Auth, `Linear credentials not found. Please either:
+1. Set the LINEAR_API_KEY environment variable
+2. Run 'sage config set linear.token KEY' with a personal API key`)
+	}
+	return &linearClient{client: newHTTPClient(), apiURL: linearAPI, token: token}, nil
+}
+
+// query runs a GraphQL query or mutation and decodes its data into out
+func (l *linearClient) query(q string, vars map[string]any, out any) error {
+	payload, err := json.Marshal(map[string]any{"query": q, "variables": vars})
+	if err != nil {
+		return err
+	}
+
+	// Everything is a POST here; only mutations are held back in dry-run mode
+	if dryrun.Enabled() && strings.HasPrefix(strings.TrimSpace(q), "mutation") {
+		dryrun.Record("Linear API %s", payload)
+		return nil
+	}
+
+	req, err := http.NewRequest("POST", l.apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	// Personal API keys go without a Bearer prefix; OAuth tokens need one
+	if strings.HasPrefix(l.token, "lin_api_") {
+		req.Header.Set("Authorization", l.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return exitcode.New(gh.StatusExitCode(resp.StatusCode), fmt.Errorf("Linear API returned %d:\n%s", resp.StatusCode, string(body)))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		var msgs []string
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("Linear API: %s", strings.Join(msgs, "; "))
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// linearIssue is an issue with the workflow states of its team
+type linearIssue struct {
+	ID         string `json:"id"`
+	Identifier string `json:"identifier"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	State      struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Team struct {
+		States struct {
+			Nodes []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"nodes"`
+		} `json:"states"`
+	} `json:"team"`
+}
+
+const linearIssueQuery = `query Issue($id: String!) {
+  issue(id: $id) {
+    id identifier title url
+    state { name }
+    team { states { nodes { id name } } }
+  }
+}`
+
+func (l *linearClient) issue(id string) (*linearIssue, error) {
+	var data struct {
+		Issue *linearIssue `json:"issue"`
+	}
+	if err := l.query(linearIssueQuery, map[string]any{"id": id}, &data); err != nil {
+		return nil, err
+	}
+	if data.Issue == nil {
+		return nil, exitcode.Errorf(exitcode.NotFound, "Linear issue %s not found", id)
+	}
+	return data.Issue, nil
+}
+
+func (l *linearClient) Ticket(id string) (*Ticket, error) {
+	issue, err := l.issue(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Ticket{
+		ID:     issue.Identifier,
+		Title:  issue.Title,
+		Status: issue.State.Name,
+		URL:    issue.URL,
+	}, nil
+}
+
+const linearUpdateMutation = `mutation Move($id: String!, $stateId: String!) {
+  issueUpdate(id: $id, input: { stateId: $stateId }) { success }
+}`
+
+func (l *linearClient) Transition(id, status string) error {
+	issue, err := l.issue(id)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, s := range issue.Team.States.Nodes {
+		if strings.EqualFold(s.Name, status) {
+			var data struct {
+				IssueUpdate struct {
+					Success bool `json:"success"`
+				} `json:"issueUpdate"`
+			}
+			return l.query(linearUpdateMutation, map[string]any{"id": issue.ID, "stateId": s.ID}, &data)
+		}
+		names = append(names, s.Name)
+	}
+	return fmt.Errorf("%s's team has no %s state; it has %s", id, status, strings.Join(names, ", "))
+}
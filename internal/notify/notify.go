@@ -0,0 +1,150 @@
+// Package notify tells the user a long command has finished, by ringing the
+// terminal bell and by a desktop notification: osascript on macOS,
+// notify-send on Linux desktops, and PowerShell on Windows and WSL. Where
+// there is no desktop, such as in an SSH session, Send returns
+// ErrUnavailable and Notify rings the bell instead.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no desktop notifications can be shown
+var ErrUnavailable = errors.New("no desktop notifications available")
+
+// Mode is how the user is told, from the ui.notify setting
+type Mode string
+
+const (
+	Off     Mode = "off"
+	Bell    Mode = "bell"
+	Desktop Mode = "desktop"
+	All     Mode = "all" // the bell and a desktop notification
+)
+
+// ParseMode reads a ui.notify value. Empty is off and "true" is all.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "", "false":
+		return Off, nil
+	case "true":
+		return All, nil
+	case Off, Bell, Desktop, All:
+		return m, nil
+	}
+	return Off, fmt.Errorf("invalid ui.notify %q: use off, bell, desktop or all", s)
+}
+
+// tool is a command that shows a desktop notification
+type tool struct {
+	name string
+	args []string
+}
+
+// lookPath is replaced in tests
+var lookPath = exec.LookPath
+
+// candidates returns the notification tools that can work on this system,
+// best first
+func candidates(goos string, getenv func(string) string, title, message string) []tool {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleString(message), appleString(title))
+		return []tool{{name: "osascript", args: []string{"-e", script}}}
+	case "windows":
+		return []tool{powershell("powershell", title, message)}
+	}
+
+	var tools []tool
+	if getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != "" || getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		tools = append(tools, tool{name: "notify-send", args: []string{"--app-name=sage", title, message}})
+	}
+	if getenv("WSL_DISTRO_NAME") != "" {
+		tools = append(tools, powershell("powershell.exe", title, message))
+	}
+	return tools
+}
+
+// powershell shows a Windows toast notification as PowerShell itself, since
+// toasts need a registered app to come from
+func powershell(name, title, message string) tool {
+	xml := fmt.Sprintf("<toast><visual><binding template='ToastGeneric'><text>%s</text><text>%s</text></binding></visual></toast>",
+		xmlEscape(title), xmlEscape(message))
+	script := strings.Join([]string{
+		"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null",
+		"[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null",
+		"$x = New-Object Windows.Data.Xml.Dom.XmlDocument",
+		"$x.LoadXml(" + psString(xml) + ")",
+		`$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'`,
+		"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($x))",
+	}, "; ")
+	return tool{name: name, args: []string{"-NoProfile", "-NonInteractive", "-Command", script}}
+}
+
+// appleString quotes s for AppleScript
+func appleString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// psString quotes s for PowerShell
+func psString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "'", "&apos;", `"`, "&quot;").Replace(s)
+}
+
+// find returns the first installed notification tool
+func find(goos string, getenv func(string) string, title, message string) (tool, bool) {
+	for _, t := range candidates(goos, getenv, title, message) {
+		if path, err := lookPath(t.name); err == nil {
+			t.name = path
+			return t, true
+		}
+	}
+	return tool{}, false
+}
+
+// Send shows a desktop notification
+func Send(title, message string) error {
+	t, ok := find(runtime.GOOS, os.Getenv, title, message)
+	if !ok {
+		return ErrUnavailable
+	}
+	if out, err := exec.Command(t.name, t.args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", t.name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Ring rings the terminal bell
+func Ring(w io.Writer) {
+	fmt.Fprint(w, "\a")
+}
+
+// Notify tells the user as the mode says, ringing the bell on w when a
+// desktop notification was wanted but can't be shown
+func Notify(m Mode, w io.Writer, title, message string) error {
+	if m == Off {
+		return nil
+	}
+	var err error
+	if m == Desktop || m == All {
+		err = Send(title, message)
+		if errors.Is(err, ErrUnavailable) {
+			err = nil
+			m = Bell
+		}
+	}
+	if m == Bell || m == All {
+		Ring(w)
+	}
+	return err
+}
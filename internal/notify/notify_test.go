@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func names(tools []tool) []string {
+	var out []string
+	for _, t := range tools {
+		out = append(out, t.name)
+	}
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want []string
+	}{
+		{"macOS", "darwin", nil, []string{"osascript"}},
+		{"windows", "windows", nil, []string{"powershell"}},
+		{"x11", "linux", map[string]string{"DISPLAY": ":0"}, []string{"notify-send"}},
+		{"wayland", "linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, []string{"notify-send"}},
+		{"wsl", "linux", map[string]string{"WSL_DISTRO_NAME": "Ubuntu"}, []string{"powershell.exe"}},
+		{"ssh session", "linux", map[string]string{"SSH_TTY": "/dev/pts/0"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, names(candidates(tt.goos, env(tt.env), "sage sync", "Done")))
+		})
+	}
+}
+
+func TestQuoting(t *testing.T) {
+	mac := candidates("darwin", env(nil), `sage "sync"`, `C:\repo`)[0]
+	assert.Equal(t, `display notification "C:\\repo" with title "sage \"sync\""`, mac.args[1])
+
+	win := candidates("windows", env(nil), "it's <done>", "ok")[0]
+	assert.Contains(t, win.args[3], "<text>it&apos;s &lt;done&gt;</text>", "XML escaped")
+	assert.Contains(t, win.args[3], "template=''ToastGeneric''", "quoted for PowerShell")
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": Off, "false": Off, "true": All, "Bell": Bell, "desktop": Desktop, " all ": All} {
+		got, err := ParseMode(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseMode("loud")
+	assert.Error(t, err)
+}
+
+func TestNotifyRingsBellWithoutDesktop(t *testing.T) {
+	orig := lookPath
+	defer func() { lookPath = orig }()
+	lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+
+	var w bytes.Buffer
+	require.NoError(t, Notify(Desktop, &w, "sage sync", "Done"))
+	assert.Equal(t, "\a", w.String())
+
+	w.Reset()
+	require.NoError(t, Notify(Off, &w, "sage sync", "Done"))
+	assert.Empty(t, w.String())
+}
@@ -0,0 +1,137 @@
+package repoinfo
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appTokenMargin is how long before an installation token expires that a new
+// one is minted, so requests already under way don't use a stale token
+const appTokenMargin = 5 * time.Minute
+
+// App is a GitHub App installation to authenticate as, for organisations
+// that don't allow personal access tokens
+type App struct {
+	ID             string
+	InstallationID string
+	PrivateKey     []byte // the App's PEM-encoded RSA private key
+}
+
+// SetApp makes the resolver authenticate as a, minting installation tokens
+// instead of looking for personal ones. SAGE_GITHUB_TOKEN still wins. A nil
+// a goes back to personal tokens.
+func (r *Resolver) SetApp(a *App) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.app = a
+	r.tokens = map[string]Token{}
+}
+
+// appToken mints an installation token for the App on host
+func (r *Resolver) appToken(host string) Token {
+	source := fmt.Sprintf("GitHub App %s (installation %s)", r.app.ID, r.app.InstallationID)
+	if err := validApp(r.app); err != nil {
+		return Token{Source: source, Err: err}
+	}
+	jwt, err := appJWT(r.app, r.deps.Now())
+	if err != nil {
+		return Token{Source: source, Err: err}
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%s/access_tokens", Info{Host: host}.APIBaseURL(), r.app.InstallationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return Token{Source: source, Err: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := r.deps.HTTPClient.Do(req)
+	if err != nil {
+		return Token{Source: source, Err: fmt.Errorf("failed to mint an installation token: %w", err)}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{Source: source, Err: err}
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return Token{Source: source, Err: fmt.Errorf("GitHub refused to mint an installation token (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))}
+	}
+
+	var minted struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &minted); err != nil {
+		return Token{Source: source, Err: fmt.Errorf("unexpected installation token response: %w", err)}
+	}
+	return Token{Value: minted.Token, Source: source, Expires: minted.ExpiresAt}
+}
+
+// appJWT signs the short-lived JWT that identifies the App itself. It's
+// backdated a minute to allow for clock drift, and GitHub caps its life at
+// ten minutes.
+func appJWT(a *App, now time.Time) (string, error) {
+	key, err := parseAppKey(a.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.ID,
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the App JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parseAppKey reads an RSA key in the PKCS#1 form GitHub hands out, or PKCS#8
+func parseAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("the GitHub App private key isn't PEM-encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the GitHub App private key isn't an RSA key")
+	}
+	return key, nil
+}
+
+// validApp checks that an App's IDs are numbers, as GitHub shows them
+func validApp(a *App) error {
+	if _, err := strconv.ParseInt(a.ID, 10, 64); err != nil {
+		return fmt.Errorf("github.app.id %q isn't a GitHub App ID", a.ID)
+	}
+	if _, err := strconv.ParseInt(a.InstallationID, 10, 64); err != nil {
+		return fmt.Errorf("github.app.installation_id %q isn't an installation ID", a.InstallationID)
+	}
+	return nil
+}
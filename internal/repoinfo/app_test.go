@@ -0,0 +1,110 @@
+package repoinfo
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestResolverAppToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	minted := 0
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "https://api.github.com/app/installations/42/access_tokens", req.URL.String())
+
+		// The JWT is signed with the App's key and issued by the App
+		jwt, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		require.True(t, ok)
+		parts := strings.Split(jwt, ".")
+		require.Len(t, parts, 3)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims struct {
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+			Iss string `json:"iss"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		assert.Equal(t, "1001", claims.Iss)
+		assert.Less(t, claims.Iat, now.Unix())
+		assert.LessOrEqual(t, claims.Exp-claims.Iat, int64(600))
+
+		minted++
+		body := fmt.Sprintf(`{"token":"ghs_%d","expires_at":%q}`, minted, now.Add(time.Hour).Format(time.RFC3339))
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	r := NewResolver(Deps{
+		Getenv:     envMap(map[string]string{"GITHUB_TOKEN": "ambient"}),
+		GHCliToken: func(string) (string, error) { return "", fmt.Errorf("no gh") },
+		HTTPClient: client,
+		Now:        func() time.Time { return now },
+	})
+	r.SetApp(&App{ID: "1001", InstallationID: "42", PrivateKey: keyPEM})
+
+	token := r.TokenFor("github.com", "acme")
+	require.NoError(t, token.Err)
+	assert.Equal(t, "ghs_1", token.Value)
+	assert.Equal(t, "GitHub App 1001 (installation 42)", token.Source)
+	assert.True(t, token.Expires.Equal(now.Add(time.Hour)))
+
+	now = now.Add(50 * time.Minute)
+	assert.Equal(t, "ghs_1", r.TokenFor("github.com", "acme").Value, "still fresh, so cached")
+	now = now.Add(6 * time.Minute)
+	assert.Equal(t, "ghs_2", r.TokenFor("github.com", "acme").Value, "minted again near expiry")
+	assert.Equal(t, 2, minted)
+
+	r.SetApp(nil)
+	assert.Equal(t, "ambient", r.TokenFor("github.com", "acme").Value)
+}
+
+func TestResolverAppTokenErrors(t *testing.T) {
+	refused := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`{"message":"Bad credentials"}`))}, nil
+	})}
+	r := NewResolver(Deps{Getenv: envMap(nil), HTTPClient: refused})
+
+	r.SetApp(&App{ID: "my-app", InstallationID: "42"})
+	assert.ErrorContains(t, r.Token("").Err, "isn't a GitHub App ID")
+
+	r.SetApp(&App{ID: "1001", InstallationID: "42", PrivateKey: []byte("not a key")})
+	assert.ErrorContains(t, r.Token("").Err, "isn't PEM-encoded")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	r.SetApp(&App{ID: "1001", InstallationID: "42", PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})})
+	token := r.Token("")
+	assert.ErrorContains(t, token.Err, "Bad credentials")
+	assert.Empty(t, token.Value)
+
+	r = NewResolver(Deps{Getenv: envMap(map[string]string{"SAGE_GITHUB_TOKEN": "override"}), HTTPClient: refused})
+	r.SetApp(&App{ID: "1001", InstallationID: "42"})
+	assert.Equal(t, "override", r.Token("").Value, "SAGE_GITHUB_TOKEN beats the App")
+}
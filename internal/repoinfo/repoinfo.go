@@ -0,0 +1,400 @@
+// Package repoinfo resolves which GitHub repository the current checkout belongs
+// to and which token to talk to it with. Results are cached for the lifetime of
+// the process, so every command and client shares one answer and one lookup.
+package repoinfo
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// DefaultHost is the host used when nothing else is known
+const DefaultHost = "github.com"
+
+// Info identifies a GitHub repository
+type Info struct {
+	Host   string // e.g. github.com or a GitHub Enterprise host
+	Owner  string
+	Repo   string
+	Remote string // git remote it was resolved from, empty when set via environment
+}
+
+// FullName returns "owner/repo"
+func (i Info) FullName() string {
+	return i.Owner + "/" + i.Repo
+}
+
+// APIBaseURL returns the REST API root for the repository's host
+func (i Info) APIBaseURL() string {
+	if i.Host == "" || i.Host == DefaultHost {
+		return "https://api.github.com"
+	}
+	return "https://" + i.Host + "/api/v3"
+}
+
+// Token is a GitHub token and a description of where it came from
+type Token struct {
+	Value   string
+	Source  string
+	Account string    // the stored account it belongs to, if any
+	Expires time.Time // when a GitHub App installation token runs out; zero for tokens that don't
+	Err     error     // why no token could be had from the configured source
+}
+
+// fresh reports whether the token can still be used for a while
+func (t Token) fresh(now time.Time) bool {
+	return t.Expires.IsZero() || now.Add(appTokenMargin).Before(t.Expires)
+}
+
+// Accounts are the GitHub identities a user keeps, such as work and
+// personal, and which one to use where
+type Accounts struct {
+	Tokens map[string]string // account name -> token
+	Use    map[string]string // "host/owner", "owner", "host" or "*" -> account name
+	// Token is the github.token setting, for github.com repositories no
+	// account is mapped to
+	Token string
+}
+
+// For returns the account to use for owner's repositories on host, or ""
+// when none is mapped. The most specific mapping wins.
+func (a Accounts) For(host, owner string) string {
+	if host == "" {
+		host = DefaultHost
+	}
+	var keys []string
+	if owner != "" {
+		keys = append(keys, host+"/"+owner)
+		if host == DefaultHost {
+			keys = append(keys, owner)
+		}
+	}
+	keys = append(keys, host, "*")
+	for _, k := range keys {
+		for pattern, name := range a.Use {
+			if strings.EqualFold(pattern, k) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// Deps are the side effects a Resolver relies on. Nil fields use the real
+// implementations, so tests only need to stub what they care about.
+type Deps struct {
+	Getenv      func(key string) string
+	ListRemotes func() ([]string, error)
+	RemoteURL   func(remote string) (string, error)
+	GHCliToken  func(host string) (string, error)
+	HTTPClient  *http.Client // mints GitHub App installation tokens
+	Now         func() time.Time
+}
+
+// Resolver resolves and caches repository information and tokens
+type Resolver struct {
+	deps Deps
+
+	mu         sync.Mutex
+	info       *Info
+	infoErr    error
+	tokens     map[string]Token
+	accounts   Accounts
+	app        *App
+	resolved   bool
+	overridden bool
+}
+
+// NewResolver creates a resolver using deps, filling in defaults for nil fields
+func NewResolver(deps Deps) *Resolver {
+	if deps.Getenv == nil {
+		deps.Getenv = os.Getenv
+	}
+	if deps.ListRemotes == nil {
+		deps.ListRemotes = listRemotes
+	}
+	if deps.RemoteURL == nil {
+		deps.RemoteURL = remoteURL
+	}
+	if deps.GHCliToken == nil {
+		deps.GHCliToken = ghCliToken
+	}
+	if deps.HTTPClient == nil {
+		deps.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if deps.Now == nil {
+		deps.Now = time.Now
+	}
+	return &Resolver{deps: deps, tokens: map[string]Token{}}
+}
+
+var (
+	defaultResolver *Resolver
+	defaultOnce     sync.Once
+)
+
+// Default returns the process-wide resolver
+func Default() *Resolver {
+	defaultOnce.Do(func() {
+		defaultResolver = NewResolver(Deps{})
+	})
+	return defaultResolver
+}
+
+// Repo returns the repository for the current checkout.
+//
+// SAGE_GITHUB_OWNER and SAGE_GITHUB_REPO take precedence. Otherwise the remote
+// named by SAGE_GITHUB_REMOTE is used, then origin, then any other remote whose
+// URL looks like a GitHub repository.
+func (r *Resolver) Repo() (Info, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.resolved {
+		info, err := r.resolveRepo()
+		r.info, r.infoErr, r.resolved = info, err, true
+	}
+	if r.infoErr != nil {
+		return Info{}, r.infoErr
+	}
+	return *r.info, nil
+}
+
+// Override makes Repo return the repository named by spec, "owner/name" or
+// "host/owner/name", instead of resolving it from the checkout
+func (r *Resolver) Override(spec string) error {
+	parts := strings.Split(strings.Trim(spec, "/"), "/")
+	info := Info{Host: r.deps.Getenv("SAGE_GITHUB_HOST")}
+	switch len(parts) {
+	case 2:
+		info.Owner, info.Repo = parts[0], parts[1]
+	case 3:
+		info.Host, info.Owner, info.Repo = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid repository %q; use owner/name or host/owner/name", spec)
+	}
+	info.Repo = strings.TrimSuffix(info.Repo, ".git")
+	if info.Owner == "" || info.Repo == "" {
+		return fmt.Errorf("invalid repository %q; use owner/name or host/owner/name", spec)
+	}
+	if info.Host == "" {
+		info.Host = DefaultHost
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info, r.infoErr, r.resolved, r.overridden = &info, nil, true, true
+	return nil
+}
+
+// Overridden reports whether the repository was set with Override rather than
+// taken from the local checkout
+func (r *Resolver) Overridden() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.overridden
+}
+
+func (r *Resolver) resolveRepo() (*Info, error) {
+	owner, repo := r.deps.Getenv("SAGE_GITHUB_OWNER"), r.deps.Getenv("SAGE_GITHUB_REPO")
+	if owner != "" && repo != "" {
+		host := r.deps.Getenv("SAGE_GITHUB_HOST")
+		if host == "" {
+			host = DefaultHost
+		}
+		return &Info{Host: host, Owner: owner, Repo: repo}, nil
+	}
+
+	candidates := []string{}
+	if preferred := r.deps.Getenv("SAGE_GITHUB_REMOTE"); preferred != "" {
+		candidates = append(candidates, preferred)
+	}
+	candidates = append(candidates, "origin")
+	if remotes, err := r.deps.ListRemotes(); err == nil {
+		candidates = append(candidates, remotes...)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range candidates {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		url, err := r.deps.RemoteURL(name)
+		if err != nil {
+			continue
+		}
+		if info, ok := ParseRemoteURL(url); ok && r.isGitHubHost(info.Host) {
+			info.Remote = name
+			return &info, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not determine GitHub repository; add a GitHub remote or set SAGE_GITHUB_OWNER and SAGE_GITHUB_REPO")
+}
+
+// isGitHubHost accepts github.com, the host in SAGE_GITHUB_HOST, and hosts that
+// look like GitHub Enterprise (e.g. github.example.com)
+func (r *Resolver) isGitHubHost(host string) bool {
+	if custom := r.deps.Getenv("SAGE_GITHUB_HOST"); custom != "" && strings.EqualFold(host, custom) {
+		return true
+	}
+	return strings.EqualFold(host, DefaultHost) || strings.Contains(strings.ToLower(host), "github")
+}
+
+// SetAccounts gives the resolver the user's stored accounts
+func (r *Resolver) SetAccounts(a Accounts) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts = a
+	r.tokens = map[string]Token{}
+}
+
+// Accounts returns the stored accounts
+func (r *Resolver) Accounts() Accounts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.accounts
+}
+
+// Token returns a token for host (DefaultHost when empty), as TokenFor does
+// without an owner
+func (r *Resolver) Token(host string) Token {
+	return r.TokenFor(host, "")
+}
+
+// TokenFor returns a token for owner's repositories on host, checking
+// SAGE_GITHUB_TOKEN, then, when authenticating as a GitHub App, an
+// installation token minted for it (and minted again when it's about to
+// expire). Otherwise it checks the account mapped to them, github.token on
+// github.com, GITHUB_TOKEN (or GH_ENTERPRISE_TOKEN for other hosts), then
+// the GitHub CLI.
+func (r *Resolver) TokenFor(host, owner string) Token {
+	if host == "" {
+		host = DefaultHost
+	}
+	key := host + "/" + owner
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tokens[key]; ok && t.fresh(r.deps.Now()) {
+		return t
+	}
+
+	t := r.resolveToken(host, owner)
+	if t.Value != "" {
+		r.tokens[key] = t
+	}
+	return t
+}
+
+func (r *Resolver) resolveToken(host, owner string) Token {
+	if v := r.deps.Getenv("SAGE_GITHUB_TOKEN"); v != "" {
+		return Token{Value: v, Source: "SAGE_GITHUB_TOKEN environment variable"}
+	}
+	if r.app != nil {
+		return r.appToken(host)
+	}
+
+	if name := r.accounts.For(host, owner); name != "" {
+		if v := r.accounts.Tokens[name]; v != "" {
+			return Token{Value: v, Source: fmt.Sprintf("%q account", name), Account: name}
+		}
+	}
+	if r.accounts.Token != "" && host == DefaultHost {
+		return Token{Value: r.accounts.Token, Source: "github.token setting"}
+	}
+
+	envVar := "GITHUB_TOKEN"
+	if host != DefaultHost {
+		envVar = "GH_ENTERPRISE_TOKEN"
+	}
+	if v := r.deps.Getenv(envVar); v != "" {
+		return Token{Value: v, Source: envVar + " environment variable"}
+	}
+
+	if v, err := r.deps.GHCliToken(host); err == nil && v != "" {
+		return Token{Value: v, Source: "GitHub CLI"}
+	}
+	return Token{}
+}
+
+// ParseRemoteURL extracts host, owner and repo from an SSH, scp-style or HTTP(S) remote URL
+func ParseRemoteURL(url string) (Info, bool) {
+	url = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(url), "/"), ".git")
+
+	var host, path string
+	switch {
+	case strings.Contains(url, "://"):
+		rest := url[strings.Index(url, "://")+3:]
+		slash := strings.Index(rest, "/")
+		if slash < 0 {
+			return Info{}, false
+		}
+		host, path = rest[:slash], rest[slash+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		if colon := strings.Index(host, ":"); colon >= 0 {
+			host = host[:colon]
+		}
+	case strings.Contains(url, ":"):
+		// scp-style: git@github.com:owner/repo
+		colon := strings.Index(url, ":")
+		host, path = url[:colon], url[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	default:
+		return Info{}, false
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Info{}, false
+	}
+	return Info{Host: host, Owner: parts[0], Repo: parts[1]}, true
+}
+
+func listRemotes() ([]string, error) {
+	cmd, err := git.SetupSecureCommand("git", "remote")
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func remoteURL(remote string) (string, error) {
+	cmd, err := git.SetupSecureCommand("git", "remote", "get-url", remote)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func ghCliToken(host string) (string, error) {
+	cmd, err := git.SetupSecureCommand("gh", "auth", "token", "--hostname", host)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no token found in gh CLI config")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
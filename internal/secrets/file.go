@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// fileStore keeps secrets in one file, encrypted with a key derived from
+// this machine, for systems without a keychain
+type fileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore returns a store keeping its secrets encrypted in path
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+func (f *fileStore) Name() string {
+	return "encrypted file " + f.path
+}
+
+func (f *fileStore) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := all[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (f *fileStore) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return err
+	}
+	all[name] = value
+	return f.write(all)
+}
+
+func (f *fileStore) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[name]; !ok {
+		return nil
+	}
+	delete(all, name)
+	return f.write(all)
+}
+
+func (f *fileStore) read() (map[string]string, error) {
+	all := map[string]string{}
+	if f.path == "" {
+		return nil, fmt.Errorf("no config directory to keep secrets in")
+	}
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Decrypt(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if err := json.Unmarshal([]byte(plain), &all); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	return all, nil
+}
+
+func (f *fileStore) write(all map[string]string) error {
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	encrypted, err := Encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, []byte(encrypted), 0600)
+}
+
+// masterKey derives the encryption key from system-specific data
+func masterKey() ([]byte, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	// Get machine ID if available (usually in /etc/machine-id or /var/lib/dbus/machine-id)
+	var machineID string
+	if runtime.GOOS != "windows" {
+		if id, err := os.ReadFile("/etc/machine-id"); err == nil {
+			machineID = string(id)
+		} else if id, err := os.ReadFile("/var/lib/dbus/machine-id"); err == nil {
+			machineID = string(id)
+		}
+	}
+
+	systemData := fmt.Sprintf("%s:%s:%s:%s", homeDir, runtime.GOOS, runtime.GOARCH, machineID)
+
+	// The salt is versioned so the derivation can change; values encrypted
+	// in config files before the secrets store still use this one
+	salt := []byte("sage-config-v1")
+	return pbkdf2.Key([]byte(systemData), salt, 100000, 32, sha256.New), nil
+}
+
+// Encrypt encrypts value for this machine using AES-GCM
+func Encrypt(value string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts a value Encrypt produced on this machine
+func Decrypt(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid ciphertext length")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value (possibly corrupted or from different system)")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := masterKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get master key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
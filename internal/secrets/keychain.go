@@ -0,0 +1,140 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// toolError is a keychain tool exiting with an error
+type toolError struct {
+	code   int
+	stderr string
+}
+
+func (e *toolError) Error() string {
+	if e.stderr != "" {
+		return e.stderr
+	}
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// runner runs a keychain tool, feeding it stdin
+type runner func(stdin string, name string, args ...string) (string, error)
+
+func runTool(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return "", &toolError{code: exit.ExitCode(), stderr: strings.TrimSpace(stderr.String())}
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// platformKeychain returns the macOS Keychain through security(1), or a
+// Secret Service keyring through libsecret's secret-tool, when installed
+func platformKeychain() Store {
+	tool := "secret-tool"
+	if runtime.GOOS == "darwin" {
+		tool = "security"
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil
+	}
+	return &cliKeychain{tool: tool, run: runTool}
+}
+
+// cliKeychain keeps secrets in the keychain through its command-line tool.
+// Secrets go to the tools on stdin, never as arguments others could see.
+type cliKeychain struct {
+	tool string // security or secret-tool
+	run  runner
+}
+
+func (k *cliKeychain) Name() string {
+	if k.tool == "security" {
+		return "macOS Keychain"
+	}
+	return "Secret Service keyring"
+}
+
+func (k *cliKeychain) Get(name string) (string, error) {
+	var out string
+	var err error
+	if k.tool == "security" {
+		out, err = k.run("", k.tool, "find-generic-password", "-s", Service, "-a", name, "-w")
+	} else {
+		out, err = k.run("", k.tool, "lookup", "service", Service, "account", name)
+	}
+	if k.notFound(err) || (err == nil && out == "") {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from the %s: %w", name, k.Name(), err)
+	}
+	if k.tool == "security" {
+		// security ends the password with a newline of its own
+		out = strings.TrimSuffix(out, "\n")
+	}
+	return out, nil
+}
+
+func (k *cliKeychain) Set(name, value string) error {
+	var err error
+	if k.tool == "security" {
+		// security reads commands from stdin with -i, keeping the value out
+		// of the process list
+		cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -w %s\n",
+			securityQuote(Service), securityQuote(name), securityQuote("sage: "+name), securityQuote(value))
+		_, err = k.run(cmd, k.tool, "-i")
+	} else {
+		_, err = k.run(value, k.tool, "store", "--label", "sage: "+name, "service", Service, "account", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store %s in the %s: %w", name, k.Name(), err)
+	}
+	return nil
+}
+
+func (k *cliKeychain) Delete(name string) error {
+	var err error
+	if k.tool == "security" {
+		_, err = k.run("", k.tool, "delete-generic-password", "-s", Service, "-a", name)
+	} else {
+		_, err = k.run("", k.tool, "clear", "service", Service, "account", name)
+	}
+	if err != nil && !k.notFound(err) {
+		return fmt.Errorf("failed to remove %s from the %s: %w", name, k.Name(), err)
+	}
+	return nil
+}
+
+// notFound reports whether the tool failed for want of the item: security
+// exits 44, secret-tool exits 1 without a word
+func (k *cliKeychain) notFound(err error) bool {
+	var te *toolError
+	if !errors.As(err, &te) {
+		return false
+	}
+	if k.tool == "security" {
+		return te.code == 44
+	}
+	return te.code == 1 && te.stderr == ""
+}
+
+// securityQuote quotes an argument for security -i, which splits its
+// commands on spaces outside double quotes
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
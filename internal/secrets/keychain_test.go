@@ -0,0 +1,86 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTool records how a keychain tool is run and answers from items
+type fakeTool struct {
+	items map[string]string
+	calls []string
+	stdin []string
+}
+
+func (f *fakeTool) run(stdin string, name string, args ...string) (string, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	f.stdin = append(f.stdin, stdin)
+	account := args[len(args)-1]
+	switch args[0] {
+	case "find-generic-password":
+		account = args[4]
+		if v, ok := f.items[account]; ok {
+			return v + "\n", nil
+		}
+		return "", &toolError{code: 44, stderr: "security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain."}
+	case "lookup":
+		if v, ok := f.items[account]; ok {
+			return v, nil
+		}
+		return "", &toolError{code: 1}
+	case "store":
+		f.items[account] = stdin
+	case "clear":
+		delete(f.items, account)
+	}
+	return "", nil
+}
+
+func TestSecretTool(t *testing.T) {
+	tool := &fakeTool{items: map[string]string{}}
+	k := &cliKeychain{tool: "secret-tool", run: tool.run}
+
+	_, err := k.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, k.Set("ai.api_key", "sk-secret"))
+	assert.Equal(t, "secret-tool store --label sage: ai.api_key service sage account ai.api_key", tool.calls[1])
+	assert.Equal(t, "sk-secret", tool.stdin[1], "the secret goes on stdin")
+	assert.NotContains(t, tool.calls[1], "sk-secret")
+
+	got, err := k.Get("ai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-secret", got)
+
+	require.NoError(t, k.Delete("ai.api_key"))
+	_, err = k.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	broken := &cliKeychain{tool: "secret-tool", run: func(string, string, ...string) (string, error) {
+		return "", &toolError{code: 1, stderr: "Cannot autolaunch D-Bus without X11 $DISPLAY"}
+	}}
+	_, err = broken.Get("ai.api_key")
+	assert.ErrorContains(t, err, "D-Bus")
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestMacOSKeychain(t *testing.T) {
+	tool := &fakeTool{items: map[string]string{"github.token": "ghp_token"}}
+	k := &cliKeychain{tool: "security", run: tool.run}
+
+	got, err := k.Get("github.token")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_token", got)
+	_, err = k.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, k.Set("ai.api_key", `s3cret "quoted" \ value`))
+	assert.Equal(t, "security -i", tool.calls[2])
+	assert.Equal(t, `add-generic-password -U -s "sage" -a "ai.api_key" -l "sage: ai.api_key" -w "s3cret \"quoted\" \\ value"`+"\n", tool.stdin[2])
+	assert.Equal(t, "macOS Keychain", k.Name())
+}
@@ -0,0 +1,112 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential is the Win32 CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// platformKeychain returns the Windows Credential Manager
+func platformKeychain() Store {
+	if advapi32.Load() != nil {
+		return nil
+	}
+	return credentialManager{}
+}
+
+// credentialManager keeps secrets as generic credentials named sage:<name>
+type credentialManager struct{}
+
+func (credentialManager) Name() string { return "Windows Credential Manager" }
+
+func target(name string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(Service + ":" + name)
+}
+
+func (credentialManager) Get(name string) (string, error) {
+	t, err := target(name)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ok, _, err := procCredRead.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read %s from the Windows Credential Manager: %w", name, err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (credentialManager) Set(name, value string) error {
+	t, err := target(name)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(Service)
+	if err != nil {
+		return err
+	}
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         t,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if ok, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); ok == 0 {
+		return fmt.Errorf("failed to store %s in the Windows Credential Manager: %w", name, err)
+	}
+	return nil
+}
+
+func (credentialManager) Delete(name string) error {
+	t, err := target(name)
+	if err != nil {
+		return err
+	}
+	if ok, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(t)), credTypeGeneric, 0); ok == 0 && !errors.Is(err, errorNotFound) {
+		return fmt.Errorf("failed to remove %s from the Windows Credential Manager: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package secrets keeps credentials such as API keys and tokens out of
+// sage's config files: in the OS keychain (the macOS Keychain, the Windows
+// Credential Manager, or a Secret Service keyring such as GNOME Keyring
+// through libsecret), or, where there is none, in a file encrypted for this
+// machine.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// ErrNotFound is returned for a secret that isn't stored
+var ErrNotFound = errors.New("secret not found")
+
+// Service is what sage's secrets are filed under in the keychain
+const Service = "sage"
+
+// BackendEnv names the environment variable that picks the store: "file"
+// keeps secrets in the encrypted file even where there's a keychain
+const BackendEnv = "SAGE_SECRETS_BACKEND"
+
+// Store keeps secrets by name, such as ai.api_key
+type Store interface {
+	// Get returns the secret, or ErrNotFound
+	Get(name string) (string, error)
+	Set(name, value string) error
+	// Delete removes the secret; deleting one that isn't there is no error
+	Delete(name string) error
+	// Name says where the secrets are kept, for messages
+	Name() string
+}
+
+// Default returns the store to use here: the OS keychain, falling back to
+// the encrypted file when the keychain can't be reached, such as over SSH
+// without a desktop session
+func Default() Store {
+	file := NewFileStore(defaultFilePath())
+	if os.Getenv(BackendEnv) == "file" {
+		return file
+	}
+	if kc := platformKeychain(); kc != nil {
+		return &fallback{primary: kc, file: file}
+	}
+	return file
+}
+
+// FilePath is where the encrypted file keeps secrets: secrets.enc next to
+// the global config
+func FilePath() string {
+	return defaultFilePath()
+}
+
+func defaultFilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sage", "secrets.enc")
+}
+
+// fallback uses the keychain, and the file for whatever the keychain
+// couldn't take
+type fallback struct {
+	primary Store
+	file    Store
+}
+
+func (f *fallback) Get(name string) (string, error) {
+	value, err := f.primary.Get(name)
+	if err == nil {
+		return value, nil
+	}
+	value, ferr := f.file.Get(name)
+	if ferr == nil {
+		return value, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return "", ferr
+	}
+	return "", err
+}
+
+func (f *fallback) Set(name, value string) error {
+	err := f.primary.Set(name, value)
+	if err == nil {
+		// Don't leave an older copy behind to be found instead
+		f.file.Delete(name)
+		return nil
+	}
+	ui.Warnf("Couldn't use the %s (%v); keeping %s in the %s instead\n", f.primary.Name(), err, name, f.file.Name())
+	if ferr := f.file.Set(name, value); ferr != nil {
+		return fmt.Errorf("failed to store %s: %w", name, ferr)
+	}
+	return nil
+}
+
+func (f *fallback) Delete(name string) error {
+	err := f.primary.Delete(name)
+	if ferr := f.file.Delete(name); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+func (f *fallback) Name() string {
+	return f.primary.Name()
+}
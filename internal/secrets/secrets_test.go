@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sage", "secrets.enc")
+	s := NewFileStore(path)
+
+	_, err := s.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Set("ai.api_key", "sk-secret"))
+	require.NoError(t, s.Set("github.token", "ghp_token"))
+	got, err := NewFileStore(path).Get("ai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-secret", got)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "sk-secret", "the file is encrypted")
+	if info, err := os.Stat(path); err == nil && os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	require.NoError(t, s.Delete("ai.api_key"))
+	require.NoError(t, s.Delete("ai.api_key"), "deleting twice is fine")
+	_, err = s.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+	got, _ = s.Get("github.token")
+	assert.Equal(t, "ghp_token", got)
+}
+
+func TestEncrypt(t *testing.T) {
+	for _, value := range []string{"", "test-value", "Test123!@#$%^&*()", strings.Repeat("test", 100)} {
+		encrypted, err := Encrypt(value)
+		require.NoError(t, err)
+		decrypted, err := Decrypt(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, value, decrypted)
+	}
+	_, err := Decrypt("not base64!")
+	assert.Error(t, err)
+}
+
+// memStore is a keychain in memory, which can be made unreachable
+type memStore struct {
+	values map[string]string
+	broken error
+}
+
+func (m *memStore) Name() string { return "test keychain" }
+
+func (m *memStore) Get(name string) (string, error) {
+	if m.broken != nil {
+		return "", m.broken
+	}
+	v, ok := m.values[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (m *memStore) Set(name, value string) error {
+	if m.broken != nil {
+		return m.broken
+	}
+	m.values[name] = value
+	return nil
+}
+
+func (m *memStore) Delete(name string) error {
+	if m.broken != nil {
+		return m.broken
+	}
+	delete(m.values, name)
+	return nil
+}
+
+func TestFallback(t *testing.T) {
+	kc := &memStore{values: map[string]string{}, broken: errors.New("no D-Bus session")}
+	file := NewFileStore(filepath.Join(t.TempDir(), "secrets.enc"))
+	s := &fallback{primary: kc, file: file}
+
+	// The keychain can't be reached, so the secret lands in the file
+	require.NoError(t, s.Set("ai.api_key", "from-ssh"))
+	got, err := s.Get("ai.api_key")
+	require.NoError(t, err)
+	assert.Equal(t, "from-ssh", got)
+
+	// Once it can, the keychain takes over and the file's copy goes
+	kc.broken = nil
+	require.NoError(t, s.Set("ai.api_key", "from-desktop"))
+	assert.Equal(t, "from-desktop", kc.values["ai.api_key"])
+	_, err = file.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Delete("ai.api_key"))
+	_, err = s.Get("ai.api_key")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "test keychain", s.Name())
+}
+
+func TestDefaultFileBackend(t *testing.T) {
+	t.Setenv(BackendEnv, "file")
+	_, isFile := Default().(*fileStore)
+	assert.True(t, isFile)
+}
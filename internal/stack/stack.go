@@ -0,0 +1,195 @@
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+)
+
+// Entry records where a stacked branch sits: the branch it is built on, and
+// the parent commit it was last rebased onto, so it can be restacked when
+// the parent changes
+type Entry struct {
+	Parent string `json:"parent"`
+	Base   string `json:"base"`
+}
+
+// Stack maps each stacked branch to its entry. Branches that aren't in the
+// map, such as the default branch, are the roots stacks are built on.
+type Stack struct {
+	Branches map[string]Entry `json:"branches"`
+}
+
+// New returns an empty stack
+func New() *Stack {
+	return &Stack{Branches: map[string]Entry{}}
+}
+
+// Path returns the location of the stack file for the current repository
+func Path(g git.Service) (string, error) {
+	gitDir, err := g.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "stack.json"), nil
+}
+
+// Load reads the stack file, returning an empty stack if there is none
+func Load(g git.Service) (*Stack, error) {
+	path, err := Path(g)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(), nil
+		}
+		return nil, fmt.Errorf("failed to read stack: %w", err)
+	}
+	s := New()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse stack: %w", err)
+	}
+	if s.Branches == nil {
+		s.Branches = map[string]Entry{}
+	}
+	return s, nil
+}
+
+// Save writes the stack file
+func (s *Stack) Save(g git.Service) error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	path, err := Path(g)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create stack directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal stack: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write stack: %w", err)
+	}
+	return nil
+}
+
+// Parent returns the branch that branch is stacked on
+func (s *Stack) Parent(branch string) (string, bool) {
+	e, ok := s.Branches[branch]
+	return e.Parent, ok
+}
+
+// Children returns the branches stacked directly on branch, sorted by name
+func (s *Stack) Children(branch string) []string {
+	var children []string
+	for name, e := range s.Branches {
+		if e.Parent == branch {
+			children = append(children, name)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// Add stacks branch on parent, recording base as the parent commit it starts
+// from. It refuses to create a cycle.
+func (s *Stack) Add(branch, parent, base string) error {
+	if branch == parent {
+		return fmt.Errorf("a branch can't be stacked on itself")
+	}
+	for p, ok := parent, true; ok; p, ok = s.Parent(p) {
+		if p == branch {
+			return fmt.Errorf("stacking %s on %s would create a cycle", branch, parent)
+		}
+	}
+	s.Branches[branch] = Entry{Parent: parent, Base: base}
+	return nil
+}
+
+// SetBase records the parent commit branch is now based on
+func (s *Stack) SetBase(branch, base string) {
+	if e, ok := s.Branches[branch]; ok {
+		e.Base = base
+		s.Branches[branch] = e
+	}
+}
+
+// Remove takes branch out of the stack, moving its children onto its parent
+func (s *Stack) Remove(branch string) {
+	e, ok := s.Branches[branch]
+	if !ok {
+		return
+	}
+	for _, child := range s.Children(branch) {
+		c := s.Branches[child]
+		c.Parent = e.Parent
+		s.Branches[child] = c
+	}
+	delete(s.Branches, branch)
+}
+
+// Root returns the untracked branch at the bottom of branch's stack, which is
+// branch itself when it isn't stacked
+func (s *Stack) Root(branch string) string {
+	for {
+		parent, ok := s.Parent(branch)
+		if !ok {
+			return branch
+		}
+		branch = parent
+	}
+}
+
+// Roots returns every branch stacks are built on, sorted by name
+func (s *Stack) Roots() []string {
+	seen := map[string]bool{}
+	var roots []string
+	for name := range s.Branches {
+		if r := s.Root(name); !seen[r] {
+			seen[r] = true
+			roots = append(roots, r)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Descendants returns the branches stacked on branch, directly or not, with
+// every parent before its children
+func (s *Stack) Descendants(branch string) []string {
+	var out []string
+	queue := s.Children(branch)
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		out = append(out, b)
+		queue = append(queue, s.Children(b)...)
+	}
+	return out
+}
+
+// Chain returns the stacked branches from the bottom of branch's stack up to
+// branch, leaving out the root
+func (s *Stack) Chain(branch string) []string {
+	var chain []string
+	for {
+		parent, ok := s.Parent(branch)
+		if !ok {
+			return chain
+		}
+		chain = append([]string{branch}, chain...)
+		branch = parent
+	}
+}
@@ -0,0 +1,60 @@
+package stack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStack builds main <- a <- b <- c, with d also on a
+func newTestStack(t *testing.T) *Stack {
+	s := New()
+	require.NoError(t, s.Add("a", "main", "m1"))
+	require.NoError(t, s.Add("b", "a", "a1"))
+	require.NoError(t, s.Add("c", "b", "b1"))
+	require.NoError(t, s.Add("d", "a", "a1"))
+	return s
+}
+
+func TestStackRelationships(t *testing.T) {
+	s := newTestStack(t)
+
+	assert.Equal(t, []string{"b", "d"}, s.Children("a"))
+	assert.Equal(t, []string{"a", "b", "d", "c"}, s.Descendants("main"))
+	assert.Equal(t, []string{"c"}, s.Descendants("b"))
+	assert.Equal(t, []string{"a", "b", "c"}, s.Chain("c"))
+	assert.Empty(t, s.Chain("main"))
+	assert.Equal(t, "main", s.Root("c"))
+	assert.Equal(t, []string{"main"}, s.Roots())
+}
+
+func TestStackAddRejectsCycles(t *testing.T) {
+	s := newTestStack(t)
+
+	assert.Error(t, s.Add("a", "c", "c1"))
+	assert.Error(t, s.Add("b", "b", ""))
+	parent, _ := s.Parent("a")
+	assert.Equal(t, "main", parent)
+}
+
+func TestStackRemoveMovesChildrenDown(t *testing.T) {
+	s := newTestStack(t)
+	s.Remove("a")
+
+	_, ok := s.Parent("a")
+	assert.False(t, ok)
+	parent, _ := s.Parent("b")
+	assert.Equal(t, "main", parent)
+	assert.Equal(t, "a1", s.Branches["b"].Base, "the base stays so only the branch's own commits are restacked")
+	assert.Equal(t, []string{"b", "d"}, s.Children("main"))
+}
+
+func TestStackSetBase(t *testing.T) {
+	s := newTestStack(t)
+	s.SetBase("b", "a2")
+	s.SetBase("missing", "x")
+
+	assert.Equal(t, "a2", s.Branches["b"].Base)
+	assert.NotContains(t, s.Branches, "missing")
+}
@@ -1,15 +1,22 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/crazywolf132/sage/internal/ai"
 	"github.com/crazywolf132/sage/internal/gh"
 	"github.com/crazywolf132/sage/internal/git"
 )
 
 // GenerateAIPRContent uses git diff and commit history to generate PR content
-func GenerateAIPRContent(g git.Service, ghc gh.Client) (PRForm, error) {
+// for a PR into base, the default branch when empty. When client is
+// configured it writes the title and description, the description previewed
+// as it's written; otherwise, or if the preview is stopped, they're drawn up
+// from the branch and its commits.
+func GenerateAIPRContent(g git.Service, ghc gh.Client, base string, client *ai.Client) (PRForm, error) {
 	form := PRForm{}
 
 	// Get the current branch name
@@ -19,9 +26,11 @@ func GenerateAIPRContent(g git.Service, ghc gh.Client) (PRForm, error) {
 	}
 
 	// Get the default branch
-	defaultBranch, err := g.DefaultBranch()
-	if err != nil {
-		defaultBranch = "main" // fallback
+	defaultBranch := base
+	if defaultBranch == "" {
+		if defaultBranch, err = g.DefaultBranch(); err != nil {
+			defaultBranch = "main" // fallback
+		}
 	}
 
 	// Get all changes: both staged and unstaged
@@ -47,7 +56,7 @@ func GenerateAIPRContent(g git.Service, ghc gh.Client) (PRForm, error) {
 	}
 
 	// Get commit messages for this branch only (since branching from default branch)
-	commits, err := g.Log(fmt.Sprintf("%s..%s", defaultBranch, branch), 0, false, false)
+	commits, err := g.Commits(git.CommitsOptions{Range: fmt.Sprintf("%s..%s", defaultBranch, branch)})
 	if err != nil {
 		return form, fmt.Errorf("failed to get branch commit history: %w", err)
 	}
@@ -60,7 +69,7 @@ func GenerateAIPRContent(g git.Service, ghc gh.Client) (PRForm, error) {
 		Branch:        branch,
 		DefaultBranch: defaultBranch,
 		Diff:          allDiff.String(),
-		Commits:       commits,
+		Commits:       git.FormatCommits(commits),
 		Template:      template,
 	})
 	if err != nil {
@@ -72,14 +81,54 @@ func GenerateAIPRContent(g git.Service, ghc gh.Client) (PRForm, error) {
 	form.Labels = content.Labels
 	form.Base = defaultBranch
 
+	if client != nil && client.Configured() {
+		if err := writePRWithAI(g, client, &form, defaultBranch, branch, git.FormatCommits(commits)); err != nil {
+			return form, err
+		}
+	}
+
 	// Show preview of generated content
 	fmt.Printf("\n%s Generated PR Title: %s\n", Green("✓"), form.Title)
 	preview := truncateBody(form.Body, 10, 80)
-	fmt.Printf("\n%s Generated PR Description:\n%s\n\n", Green("✓"), preview)
+	fmt.Printf("\n%s Generated PR Description:\n%s\n\n", Green("✓"), RenderMarkdown(preview))
 
 	return form, nil
 }
 
+// writePRWithAI replaces the form's title and description with ones AI
+// writes from the branch's commits and diff against base
+func writePRWithAI(g git.Service, client *ai.Client, form *PRForm, base, branch, commits string) error {
+	diff, err := g.Run("diff", base+"..."+branch)
+	if err != nil {
+		// The base may only exist on origin
+		if diff, err = g.Run("diff", "origin/"+base+"..."+branch); err != nil {
+			return fmt.Errorf("failed to get branch diff: %w", err)
+		}
+	}
+	title, err := client.GeneratePRTitle(commits, diff)
+	if err != nil {
+		return fmt.Errorf("failed to generate PR title: %w", err)
+	}
+
+	var body string
+	err = StreamAI("Writing the PR description", func(ctx context.Context, onToken func(string)) error {
+		var err error
+		body, err = client.Streaming(ctx, onToken).GeneratePRDescription(commits, diff)
+		return err
+	})
+	if errors.Is(err, context.Canceled) {
+		fmt.Println(Yellow("Stopped generating; using a description drawn up from the commits"))
+		form.Title = title
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate PR description: %w", err)
+	}
+	form.Title = title
+	form.Body = body
+	return nil
+}
+
 type GenerateInput struct {
 	Branch        string
 	DefaultBranch string
@@ -117,8 +166,9 @@ func generatePRContent(input GenerateInput) (GenerateOutput, error) {
 		output.Body = generateComprehensiveBody(input)
 	}
 
-	// Suggest labels based on all available information
-	output.Labels = suggestLabels(finalType, input.Diff, input.Commits)
+	// Suggest a label for the kind of change; rules in pr.branch_defaults
+	// take over from this when the branch matches one
+	output.Labels = []string{finalType}
 
 	return output, nil
 }
@@ -499,20 +549,6 @@ func generateChanges(input GenerateInput) string {
 	return changes.String()
 }
 
-func suggestLabels(branchType string, diff string, commits string) []string {
-	labels := []string{branchType}
-
-	// Add more labels based on the changes
-	if strings.Contains(diff, "test") {
-		labels = append(labels, "tests")
-	}
-	if strings.Contains(diff, "doc") {
-		labels = append(labels, "documentation")
-	}
-
-	return labels
-}
-
 func hasBreakingChanges(input GenerateInput) bool {
 	commits := strings.Split(input.Commits, "\n")
 	for _, commit := range commits {
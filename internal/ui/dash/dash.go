@@ -0,0 +1,388 @@
+// Package dash draws 'sage dash', a full-screen view of the current branch,
+// its pull request, recent commits and stashes that keeps itself up to date.
+// Git is read every few seconds; the pull request is fetched on its own,
+// slower schedule so the dashboard stays within GitHub's rate limit.
+package dash
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// Options are where the dashboard gets its data
+type Options struct {
+	// Local reads the repository
+	Local         func() (*app.DashLocal, error)
+	LocalInterval time.Duration
+	// PR fetches the open pull request for a branch; nil without GitHub
+	PR func(branch string) (*app.DashPR, error)
+	// PRInterval says how long to wait before fetching the PR again
+	PRInterval func() time.Duration
+	// RateLimit describes the API quota left, for the footer
+	RateLimit func() string
+}
+
+// Run shows the dashboard until the user quits
+func Run(opts Options) error {
+	_, err := tea.NewProgram(newModel(opts), tea.WithAltScreen()).Run()
+	return err
+}
+
+type (
+	localMsg struct {
+		data *app.DashLocal
+		err  error
+	}
+	prMsg struct {
+		branch string
+		data   *app.DashPR
+		err    error
+	}
+	localTick struct{}
+	prTick    struct{ gen int }
+)
+
+type model struct {
+	opts Options
+
+	local        *app.DashLocal
+	localErr     error
+	localLoading bool
+	localAt      time.Time
+
+	pr        *app.DashPR
+	prErr     error
+	prBranch  string // the branch pr belongs to
+	prLoading bool
+	prNext    time.Time
+	prGen     int // bumped on every fetch, so stale ticks are dropped
+}
+
+func newModel(opts Options) model {
+	if opts.LocalInterval <= 0 {
+		opts.LocalInterval = 2 * time.Second
+	}
+	return model{opts: opts, localLoading: true}
+}
+
+func (m model) Init() tea.Cmd {
+	return m.loadLocal()
+}
+
+func (m model) loadLocal() tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.opts.Local()
+		return localMsg{data: data, err: err}
+	}
+}
+
+func (m model) loadPR(branch string) tea.Cmd {
+	fetch := m.opts.PR
+	return func() tea.Msg {
+		data, err := fetch(branch)
+		return prMsg{branch: branch, data: data, err: err}
+	}
+}
+
+// branch is the checked-out branch, once git has been read
+func (m model) branch() string {
+	if m.local == nil || m.local.Status == nil {
+		return ""
+	}
+	return m.local.Status.Branch
+}
+
+// fetchPR starts fetching the PR for the current branch, unless there's no
+// GitHub or a fetch is already running
+func (m *model) fetchPR() tea.Cmd {
+	branch := m.branch()
+	if m.opts.PR == nil || m.prLoading || branch == "" {
+		return nil
+	}
+	m.prLoading = true
+	m.prGen++
+	return m.loadPR(branch)
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "r":
+			var cmds []tea.Cmd
+			if !m.localLoading {
+				m.localLoading = true
+				cmds = append(cmds, m.loadLocal())
+			}
+			cmds = append(cmds, m.fetchPR())
+			return m, tea.Batch(cmds...)
+		}
+		return m, nil
+
+	case localMsg:
+		// A failed read keeps showing what was read before
+		m.localLoading = false
+		m.localErr, m.localAt = msg.err, time.Now()
+		if msg.data != nil {
+			m.local = msg.data
+		}
+		next := tea.Tick(m.opts.LocalInterval, func(time.Time) tea.Msg { return localTick{} })
+		// A new branch gets its PR straight away
+		if b := m.branch(); b != "" && b != m.prBranch {
+			return m, tea.Batch(next, m.fetchPR())
+		}
+		return m, next
+
+	case localTick:
+		if m.localLoading {
+			return m, nil
+		}
+		m.localLoading = true
+		return m, m.loadLocal()
+
+	case prMsg:
+		m.prLoading = false
+		m.pr, m.prErr, m.prBranch = msg.data, msg.err, msg.branch
+		if msg.branch != m.branch() {
+			// The branch was switched while fetching
+			return m, m.fetchPR()
+		}
+		wait := 30 * time.Second
+		if m.opts.PRInterval != nil {
+			wait = m.opts.PRInterval()
+		}
+		m.prNext = time.Now().Add(wait)
+		gen := m.prGen
+		return m, tea.Tick(wait, func(time.Time) tea.Msg { return prTick{gen: gen} })
+
+	case prTick:
+		if msg.gen != m.prGen {
+			return m, nil
+		}
+		return m, m.fetchPR()
+	}
+	return m, nil
+}
+
+func (m model) View() string {
+	var b strings.Builder
+	title := ui.Sage(ui.Bold(" sage dash"))
+	if br := m.branch(); br != "" {
+		title += ui.Gray(" · ") + ui.White(br)
+	}
+	if !m.localAt.IsZero() {
+		title += ui.Gray("  updated " + m.localAt.Format("15:04:05"))
+	}
+	b.WriteString(title + "\n\n")
+
+	switch {
+	case m.local == nil && m.localErr != nil:
+		b.WriteString(" " + ui.Red(m.localErr.Error()) + "\n")
+	case m.local == nil:
+		b.WriteString(ui.Gray(" Reading the repository…") + "\n")
+	default:
+		m.viewBranch(&b)
+		m.viewPR(&b)
+		m.viewCommits(&b)
+		m.viewStashes(&b)
+		if m.localErr != nil {
+			b.WriteString("\n " + ui.Red(m.localErr.Error()) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + ui.Gray(m.footer()))
+	return b.String()
+}
+
+func heading(b *strings.Builder, s string) {
+	b.WriteString(" " + ui.Bold(s) + "\n")
+}
+
+func (m model) viewBranch(b *strings.Builder) {
+	st := m.local.Status
+	heading(b, "Branch")
+	line := "   " + ui.Yellow(st.Branch)
+	if st.Upstream == "" {
+		line += ui.Gray(" (not pushed)")
+	} else {
+		line += ui.Gray(" → ") + st.Upstream + "  " + aheadBehind(st.Ahead, st.Behind)
+	}
+	b.WriteString(line + "\n")
+
+	var counts []string
+	for _, c := range []struct {
+		n    int
+		what string
+		col  func(string) string
+	}{
+		{len(st.Conflicts), "conflicted", ui.Red},
+		{len(st.Staged), "staged", ui.Green},
+		{len(st.Unstaged), "unstaged", ui.Yellow},
+		{len(st.Untracked), "untracked", ui.Gray},
+	} {
+		if c.n > 0 {
+			counts = append(counts, c.col(fmt.Sprintf("%d %s", c.n, c.what)))
+		}
+	}
+	if len(counts) == 0 {
+		counts = []string{ui.Green("clean")}
+	}
+	b.WriteString("   " + strings.Join(counts, ui.Gray(" · ")) + "\n")
+	if st.Operation != "" {
+		b.WriteString("   " + ui.Yellow(st.Operation+" in progress") + ui.Gray(" — "+st.Continue) + "\n")
+	}
+	b.WriteString("\n")
+}
+
+func aheadBehind(ahead, behind int) string {
+	if ahead == 0 && behind == 0 {
+		return ui.Green("up to date")
+	}
+	var parts []string
+	if ahead > 0 {
+		parts = append(parts, ui.Sage(fmt.Sprintf("↑%d", ahead)))
+	}
+	if behind > 0 {
+		parts = append(parts, ui.Yellow(fmt.Sprintf("↓%d", behind)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m model) viewPR(b *strings.Builder) {
+	heading(b, "Pull request")
+	defer b.WriteString("\n")
+	switch {
+	case m.opts.PR == nil:
+		b.WriteString(ui.Gray("   No GitHub access; see 'sage doctor'") + "\n")
+		return
+	case m.prBranch != m.branch():
+		b.WriteString(ui.Gray("   Fetching…") + "\n")
+		return
+	case m.prErr != nil:
+		b.WriteString("   " + ui.Red(firstLine(m.prErr.Error())) + "\n")
+		return
+	case m.pr == nil:
+		b.WriteString(ui.Gray("   None open; 'sage pr create' opens one") + "\n")
+		return
+	}
+
+	pr := m.pr.PR
+	state := ui.Sage("open")
+	if pr.Draft {
+		state = ui.Gray("draft")
+	}
+	fmt.Fprintf(b, "   #%d %s %s\n", pr.Number, ui.White(pr.Title), state)
+	b.WriteString("   " + ui.Blue(pr.HTMLURL) + "\n")
+
+	passed, failed, pending := m.pr.CheckCounts()
+	if len(m.pr.Checks) == 0 {
+		b.WriteString("   Checks: " + ui.Gray("none") + "\n")
+	} else {
+		var parts []string
+		if passed > 0 {
+			parts = append(parts, ui.Green(fmt.Sprintf("%d passed", passed)))
+		}
+		if failed > 0 {
+			parts = append(parts, ui.Red(fmt.Sprintf("%d failed", failed)))
+		}
+		if pending > 0 {
+			parts = append(parts, ui.Yellow(fmt.Sprintf("%d running", pending)))
+		}
+		b.WriteString("   Checks: " + strings.Join(parts, ui.Gray(" · ")) + "\n")
+		for _, c := range m.pr.Checks {
+			if c.Failed() {
+				b.WriteString("     " + ui.Red("✗ "+c.Name) + "\n")
+			}
+		}
+	}
+
+	if len(m.pr.Reviews) == 0 {
+		b.WriteString("   Reviews: " + ui.Gray("none yet") + "\n")
+		return
+	}
+	var reviews []string
+	for _, r := range m.pr.Reviews {
+		switch r.State {
+		case "APPROVED":
+			reviews = append(reviews, ui.Green("✓ @"+r.User.Login))
+		case "CHANGES_REQUESTED":
+			reviews = append(reviews, ui.Red("✗ @"+r.User.Login))
+		default:
+			reviews = append(reviews, ui.Gray("– @"+r.User.Login))
+		}
+	}
+	b.WriteString("   Reviews: " + strings.Join(reviews, "  ") + "\n")
+}
+
+func (m model) viewCommits(b *strings.Builder) {
+	heading(b, "Recent commits")
+	if len(m.local.Commits) == 0 {
+		b.WriteString(ui.Gray("   None yet") + "\n")
+	}
+	for _, c := range m.local.Commits {
+		fmt.Fprintf(b, "   %s %s %s\n", ui.Yellow(c.ShortHash()), c.Subject,
+			ui.Gray("· "+c.Author+", "+age(time.Since(c.Date))))
+	}
+	b.WriteString("\n")
+}
+
+// maxStashes is how many stashes are listed before the rest are counted
+const maxStashes = 5
+
+func (m model) viewStashes(b *strings.Builder) {
+	heading(b, "Stashes")
+	stashes := m.local.Stashes
+	if len(stashes) == 0 {
+		b.WriteString(ui.Gray("   None") + "\n")
+		return
+	}
+	for i, s := range stashes {
+		if i == maxStashes {
+			b.WriteString(ui.Gray(fmt.Sprintf("   …and %d more", len(stashes)-maxStashes)) + "\n")
+			break
+		}
+		b.WriteString("   " + s + "\n")
+	}
+}
+
+func (m model) footer() string {
+	parts := []string{" r refresh", "q quit"}
+	switch {
+	case m.opts.PR == nil:
+	case m.prLoading:
+		parts = append(parts, "fetching the PR…")
+	case !m.prNext.IsZero():
+		parts = append(parts, "PR refreshes in "+time.Until(m.prNext).Round(time.Second).String())
+	}
+	if m.opts.RateLimit != nil {
+		if rl := m.opts.RateLimit(); rl != "" {
+			parts = append(parts, rl)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
+
+func age(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
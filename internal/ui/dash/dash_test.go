@@ -0,0 +1,113 @@
+package dash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/gh"
+)
+
+func local(branch string) *app.DashLocal {
+	st := &app.StatusOverview{Upstream: "origin/" + branch, Ahead: 2}
+	st.Branch = branch
+	return &app.DashLocal{Status: st, Stashes: []string{"stash@{0}: On main: half-done"}}
+}
+
+// step feeds msg to m and runs the commands it returns, as the program
+// would, except for ticks
+func step(t *testing.T, m tea.Model, msg tea.Msg) tea.Model {
+	t.Helper()
+	m, cmd := m.Update(msg)
+	for _, next := range run(cmd) {
+		m = step(t, m, next)
+	}
+	return m
+}
+
+// run collects what cmd sends, skipping timers
+func run(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	select {
+	case msg := <-done:
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			var out []tea.Msg
+			for _, c := range batch {
+				out = append(out, run(c)...)
+			}
+			return out
+		}
+		return []tea.Msg{msg}
+	case <-time.After(50 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestDashFetchesPRForBranch(t *testing.T) {
+	branch := "feature"
+	var fetched []string
+	pr := &app.DashPR{PR: &gh.PullRequest{Number: 42, Title: "Add login"}}
+	pr.Checks = []gh.CheckRun{{Name: "lint", Status: "completed", Conclusion: "failure"}, {Name: "test", Status: "in_progress"}}
+	pr.Reviews = []gh.Review{{State: "APPROVED"}}
+	pr.Reviews[0].User.Login = "alice"
+
+	m := tea.Model(newModel(Options{
+		Local: func() (*app.DashLocal, error) { return local(branch), nil },
+		PR: func(b string) (*app.DashPR, error) {
+			fetched = append(fetched, b)
+			if b == "feature" {
+				return pr, nil
+			}
+			return nil, nil
+		},
+		PRInterval: func() time.Duration { return time.Hour },
+	}))
+	m = step(t, m, m.Init()())
+
+	require.Equal(t, []string{"feature"}, fetched)
+	view := m.View()
+	for _, want := range []string{"feature", "↑2", "#42", "Add login", "1 failed", "1 running", "✗ lint", "@alice", "half-done"} {
+		assert.Contains(t, view, want)
+	}
+
+	// Local refreshes don't refetch the PR, a new branch does
+	m = step(t, m, localTick{})
+	assert.Len(t, fetched, 1)
+	branch = "main"
+	m = step(t, m, localTick{})
+	assert.Equal(t, []string{"feature", "main"}, fetched)
+	assert.Contains(t, m.View(), "None open")
+
+	// r fetches again
+	m = step(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	assert.Len(t, fetched, 3)
+}
+
+func TestDashKeepsLastReadOnError(t *testing.T) {
+	fail := false
+	m := tea.Model(newModel(Options{Local: func() (*app.DashLocal, error) {
+		if fail {
+			return nil, errors.New("index.lock exists")
+		}
+		return local("main"), nil
+	}}))
+	m = step(t, m, m.Init()())
+	fail = true
+	m = step(t, m, localTick{})
+
+	view := m.View()
+	assert.Contains(t, view, "index.lock exists")
+	assert.Contains(t, view, "origin/main", "what was read before stays")
+	assert.Contains(t, view, "No GitHub access")
+	assert.False(t, strings.Contains(view, "Reading the repository"))
+}
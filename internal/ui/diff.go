@@ -0,0 +1,23 @@
+package ui
+
+import "strings"
+
+// ColorDiff colours a unified diff: additions green, deletions red, hunk
+// headers blue and file headers bold
+func ColorDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			lines[i] = Bold(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = Blue(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = Green(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = Red(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
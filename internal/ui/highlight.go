@@ -0,0 +1,251 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/term"
+)
+
+// DefaultSyntaxTheme matches sage's own colours
+const DefaultSyntaxTheme = "onedark"
+
+// syntaxStyle highlights code in diffs; nil turns highlighting off
+var syntaxStyle = styles.Get(DefaultSyntaxTheme)
+
+// SetSyntaxTheme picks the chroma style code in diffs is highlighted with.
+// "off" or "none" leaves diffs with ColorDiff's colours; an empty or unknown
+// name keeps the default.
+func SetSyntaxTheme(name string) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "off", "none", "false":
+		syntaxStyle = nil
+	case "":
+		syntaxStyle = styles.Get(DefaultSyntaxTheme)
+	default:
+		if s, ok := styles.Registry[name]; ok {
+			syntaxStyle = s
+		} else {
+			syntaxStyle = styles.Get(DefaultSyntaxTheme)
+		}
+	}
+}
+
+// SyntaxThemes lists the names SetSyntaxTheme knows
+func SyntaxThemes() []string {
+	return styles.Names()
+}
+
+// HighlightDiff colours a unified diff like ColorDiff and highlights the code
+// in it in the language of each file. Diffs written anywhere but a terminal
+// are left plain, so they still apply.
+func HighlightDiff(diff string) string {
+	if !colorOutput() {
+		return diff
+	}
+	return highlightDiff(diff, "")
+}
+
+// HighlightHunk is HighlightDiff for part of path's diff with no file headers
+func HighlightHunk(path, hunk string) string {
+	if !colorOutput() {
+		return hunk
+	}
+	return highlightDiff(hunk, path)
+}
+
+// colorOutput reports whether stdout takes colours
+func colorOutput() bool {
+	return os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// highlightDiff does the work of HighlightDiff, starting out in path's diff
+func highlightDiff(diff, path string) string {
+	if syntaxStyle == nil {
+		return ColorDiff(diff)
+	}
+
+	var out, body []string
+	flush := func() {
+		if len(body) > 0 {
+			out = append(out, highlightHunk(path, body)...)
+			body = nil
+		}
+	}
+
+	// Lines left in the current hunk, by side; with no hunk header yet every
+	// line that looks like one is taken as part of a hunk
+	oldLeft, newLeft := 0, 0
+	loose := true
+	for _, line := range strings.Split(diff, "\n") {
+		if oldLeft > 0 || newLeft > 0 || (loose && isHunkLine(line)) {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "\\"):
+			default:
+				oldLeft--
+				newLeft--
+			}
+			body = append(body, line)
+			continue
+		}
+		flush()
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			loose = false
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				oldLeft, newLeft = hunkSize(m[1]), hunkSize(m[2])
+			}
+			out = append(out, Blue(line))
+		case strings.HasPrefix(line, "diff "):
+			loose = false
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				path = line[i+3:]
+			}
+			out = append(out, Bold(line))
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			loose = false
+			if name := strings.TrimSpace(line[4:]); name != "/dev/null" {
+				path = strings.TrimPrefix(strings.TrimPrefix(name, "a/"), "b/")
+			}
+			out = append(out, Bold(line))
+		case strings.HasPrefix(line, "index "):
+			out = append(out, Bold(line))
+		case strings.HasPrefix(line, "\\"):
+			out = append(out, Gray(line))
+		default:
+			out = append(out, line)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+func isHunkLine(line string) bool {
+	return line != "" && strings.ContainsRune(" +-\\", rune(line[0])) &&
+		!strings.HasPrefix(line, "+++ ") && !strings.HasPrefix(line, "--- ")
+}
+
+func hunkSize(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// highlightHunk renders hunk lines. Each side is highlighted as a whole so
+// strings and comments spanning lines come out right.
+func highlightHunk(path string, lines []string) []string {
+	lexer := lexers.Match(path)
+	if lexer == nil {
+		return strings.Split(ColorDiff(strings.Join(lines, "\n")), "\n")
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	var oldSide, newSide []string
+	for _, line := range lines {
+		if line == "" {
+			line = " "
+		}
+		switch line[0] {
+		case '-':
+			oldSide = append(oldSide, line[1:])
+		case '+':
+			newSide = append(newSide, line[1:])
+		case ' ':
+			oldSide = append(oldSide, line[1:])
+			newSide = append(newSide, line[1:])
+		}
+	}
+	oldTokens, err1 := tokenLines(lexer, oldSide)
+	newTokens, err2 := tokenLines(lexer, newSide)
+	if err1 != nil || err2 != nil {
+		return strings.Split(ColorDiff(strings.Join(lines, "\n")), "\n")
+	}
+
+	addBg, delBg := "#23362A", "#3C2328"
+	if syntaxStyle.Get(chroma.Background).Background.Brightness() > 0.5 {
+		addBg, delBg = "#E6FFEC", "#FFEBE9"
+	}
+
+	out := make([]string, 0, len(lines))
+	o, n := 0, 0
+	for _, line := range lines {
+		if line == "" {
+			line = " "
+		}
+		switch line[0] {
+		case '-':
+			out = append(out, background(delBg)+red+"-\x1b[39m"+renderTokens(oldTokens[o])+reset)
+			o++
+		case '+':
+			out = append(out, background(addBg)+green+"+\x1b[39m"+renderTokens(newTokens[n])+reset)
+			n++
+		case ' ':
+			out = append(out, " "+renderTokens(newTokens[n])+reset)
+			o++
+			n++
+		default:
+			out = append(out, Gray(line))
+		}
+	}
+	return out
+}
+
+// tokenLines tokenises lines together and splits the tokens back into one
+// slice per line
+func tokenLines(lexer chroma.Lexer, lines []string) ([][]chroma.Token, error) {
+	it, err := lexer.Tokenise(nil, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return nil, err
+	}
+	split := chroma.SplitTokensIntoLines(it.Tokens())
+	for len(split) < len(lines) {
+		split = append(split, nil)
+	}
+	return split, nil
+}
+
+// renderTokens colours a line's tokens. Only the foreground and weight are
+// set, so a line's background shows through.
+func renderTokens(tokens []chroma.Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		text := strings.TrimSuffix(t.Value, "\n")
+		if text == "" {
+			continue
+		}
+		entry := syntaxStyle.Get(t.Type)
+		if !entry.Colour.IsSet() && entry.Bold != chroma.Yes {
+			b.WriteString(text)
+			continue
+		}
+		if c := entry.Colour; c.IsSet() {
+			fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm", c.Red(), c.Green(), c.Blue())
+		}
+		if entry.Bold == chroma.Yes {
+			b.WriteString("\x1b[1m")
+		}
+		b.WriteString(text)
+		b.WriteString("\x1b[39;22m")
+	}
+	return b.String()
+}
+
+func background(hex string) string {
+	c := chroma.MustParseColour(hex)
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", c.Red(), c.Green(), c.Blue())
+}
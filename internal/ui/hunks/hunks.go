@@ -0,0 +1,308 @@
+// Package hunks draws the picker behind 'sage stage --patch': the unstaged
+// hunks of every file, highlighted, to stage one by one, split apart or
+// line by line, like 'git add -p' but with everything on one screen.
+package hunks
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/crazywolf132/sage/internal/app"
+	"github.com/crazywolf132/sage/internal/ui"
+)
+
+// Pick shows files' hunks and returns them with the lines picked for
+// staging selected, or nil when the user cancels
+func Pick(files []app.FileHunks) ([]app.FileHunks, error) {
+	final, err := tea.NewProgram(newModel(files), tea.WithAltScreen()).Run()
+	if err != nil {
+		return nil, err
+	}
+	m := final.(model)
+	if !m.done {
+		return nil, nil
+	}
+	return m.files, nil
+}
+
+// item is one entry of the list: hunks of a file shown together until split
+type item struct {
+	file  int
+	hunks []int
+}
+
+type model struct {
+	files []app.FileHunks
+	items []item
+
+	cursor   int
+	lineMode bool
+	line     int // the line picked in line mode, counting the item's lines
+	height   int
+	note     string
+	done     bool
+}
+
+func newModel(files []app.FileHunks) model {
+	// Work on a copy, so cancelling leaves the caller's files alone
+	own := make([]app.FileHunks, len(files))
+	for i, f := range files {
+		own[i] = f
+		own[i].Hunks = make([]app.Hunk, len(f.Hunks))
+		for j, h := range f.Hunks {
+			own[i].Hunks[j] = h
+			own[i].Hunks[j].Lines = append([]app.HunkLine(nil), h.Lines...)
+		}
+	}
+
+	m := model{files: own}
+	for i, f := range own {
+		for _, group := range app.GroupHunks(f) {
+			m.items = append(m.items, item{file: i, hunks: group})
+		}
+	}
+	return m
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+// lines are the item's changed lines, as pointers into its hunks
+func (m model) lines(it item) []*app.HunkLine {
+	var out []*app.HunkLine
+	for _, h := range it.hunks {
+		hunk := &m.files[it.file].Hunks[h]
+		for i := range hunk.Lines {
+			out = append(out, &hunk.Lines[i])
+		}
+	}
+	return out
+}
+
+// selected counts the item's picked lines out of all of them
+func (m model) selected(it item) (picked, total int) {
+	for _, l := range m.lines(it) {
+		total++
+		if l.Selected {
+			picked++
+		}
+	}
+	return picked, total
+}
+
+func (m model) selectItem(it item, on bool) {
+	for _, h := range it.hunks {
+		m.files[it.file].Hunks[h].Select(on)
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		m.note = ""
+		if m.lineMode {
+			return m.updateLines(msg)
+		}
+		it := m.items[m.cursor]
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "enter":
+			m.done = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case " ":
+			picked, total := m.selected(it)
+			m.selectItem(it, picked < total)
+		case "y", "n":
+			m.selectItem(it, msg.String() == "y")
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case "a", "d":
+			for _, other := range m.items {
+				if other.file == it.file {
+					m.selectItem(other, msg.String() == "a")
+				}
+			}
+		case "s":
+			if len(it.hunks) == 1 {
+				m.note = "This hunk can't be split further; press l to pick its lines"
+				break
+			}
+			split := make([]item, 0, len(m.items)+len(it.hunks)-1)
+			split = append(split, m.items[:m.cursor]...)
+			for _, h := range it.hunks {
+				split = append(split, item{file: it.file, hunks: []int{h}})
+			}
+			m.items = append(split, m.items[m.cursor+1:]...)
+		case "l", "right":
+			m.lineMode, m.line = true, 0
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+func (m model) updateLines(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	lines := m.lines(m.items[m.cursor])
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "h", "left":
+		m.lineMode = false
+	case "enter":
+		m.done = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.line > 0 {
+			m.line--
+		}
+	case "down", "j":
+		if m.line < len(lines)-1 {
+			m.line++
+		}
+	case " ":
+		lines[m.line].Selected = !lines[m.line].Selected
+	case "y", "n":
+		lines[m.line].Selected = msg.String() == "y"
+		if m.line < len(lines)-1 {
+			m.line++
+		}
+	}
+	return m, nil
+}
+
+func (m model) View() string {
+	var b strings.Builder
+	picked, total := 0, 0
+	for _, it := range m.items {
+		if p, _ := m.selected(it); p > 0 {
+			picked++
+		}
+		total++
+	}
+	b.WriteString(ui.Sage(ui.Bold(" sage stage")) + ui.Gray(fmt.Sprintf(" · %d of %d hunks picked", picked, total)) + "\n\n")
+
+	// The list takes a third of the screen and the diff the rest
+	listRows, diffRows := len(m.items), 0
+	if m.height > 0 {
+		listRows = min(len(m.items), max(3, m.height/3))
+		diffRows = max(3, m.height-listRows-6)
+	}
+	first := min(max(0, m.cursor-listRows/2), len(m.items)-listRows)
+	for i := first; i < first+listRows; i++ {
+		b.WriteString(m.viewItem(i) + "\n")
+	}
+	b.WriteString("\n")
+
+	diff, row := m.viewDiff()
+	if diffRows > 0 && len(diff) > diffRows {
+		start := 0
+		if m.lineMode {
+			// Keep the picked line in sight
+			start = min(max(0, row-diffRows/2), len(diff)-diffRows)
+		}
+		diff = diff[start : start+diffRows]
+	}
+	for _, l := range diff {
+		b.WriteString(l + "\n")
+	}
+
+	b.WriteString("\n")
+	if m.note != "" {
+		b.WriteString(" " + ui.Yellow(m.note) + "\n")
+	}
+	b.WriteString(ui.Gray(m.footer()))
+	return b.String()
+}
+
+func (m model) viewItem(i int) string {
+	it := m.items[i]
+	picked, total := m.selected(it)
+	mark := "[ ]"
+	switch {
+	case picked == total:
+		mark = ui.Green("[✓]")
+	case picked > 0:
+		mark = ui.Yellow("[~]")
+	}
+
+	adds, dels := 0, 0
+	for _, l := range m.lines(it) {
+		if l.Op == '+' {
+			adds++
+		} else {
+			dels++
+		}
+	}
+	f := m.files[it.file]
+	h := f.Hunks[it.hunks[0]]
+	where := fmt.Sprintf("line %d", max(h.NewStart, 1))
+	if len(it.hunks) > 1 {
+		where += fmt.Sprintf(", %d hunks", len(it.hunks))
+	}
+	line := fmt.Sprintf("%s %s %s %s %s", mark, f.Path, ui.Gray(where),
+		ui.Green(fmt.Sprintf("+%d", adds)), ui.Red(fmt.Sprintf("-%d", dels)))
+	if h.Section != "" {
+		line += ui.Gray(" " + h.Section)
+	}
+	if i == m.cursor {
+		return ui.Sage("▸ ") + line
+	}
+	return "  " + line
+}
+
+// viewDiff renders the current item's hunks highlighted, with a gutter
+// showing which lines are picked, and says which row line mode is on
+func (m model) viewDiff() (out []string, row int) {
+	it := m.items[m.cursor]
+	f := m.files[it.file]
+	n := 0
+	for _, hi := range it.hunks {
+		h := f.Hunks[hi]
+		text := []string{h.Header()}
+		for _, l := range h.Lines {
+			text = append(text, string(l.Op)+l.Text)
+		}
+		rendered := strings.Split(ui.HighlightHunk(f.Path, strings.Join(text, "\n")), "\n")
+		if len(rendered) != len(text) {
+			rendered = text
+		}
+
+		out = append(out, "    "+rendered[0])
+		for i, l := range h.Lines {
+			cursor, tick := " ", " "
+			if m.lineMode && n == m.line {
+				cursor, row = ui.Sage("▸"), len(out)
+			}
+			if l.Selected {
+				tick = ui.Green("✓")
+			}
+			out = append(out, " "+cursor+tick+" "+rendered[i+1])
+			n++
+		}
+	}
+	return out, row
+}
+
+func (m model) footer() string {
+	if m.lineMode {
+		return " ↑↓ move · space toggle line · y/n pick/skip line · esc back to hunks · enter stage · ctrl+c cancel"
+	}
+	return " ↑↓ move · space toggle · y/n pick/skip · a/d whole file · s split · l pick lines · enter stage · q cancel"
+}
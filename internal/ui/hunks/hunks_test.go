@@ -0,0 +1,77 @@
+package hunks
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/crazywolf132/sage/internal/app"
+)
+
+func files() []app.FileHunks {
+	return []app.FileHunks{
+		{Path: "main.go", Hunks: []app.Hunk{
+			{OldStart: 3, OldCount: 1, NewStart: 3, NewCount: 1, Lines: []app.HunkLine{{Op: '-', Text: "old()"}, {Op: '+', Text: "new()"}}},
+			{OldStart: 5, OldCount: 0, NewStart: 6, NewCount: 2, Lines: []app.HunkLine{{Op: '+', Text: "one()"}, {Op: '+', Text: "two()"}}},
+		}},
+		{Path: "README.md", Hunks: []app.Hunk{
+			{OldStart: 1, OldCount: 1, NewStart: 1, NewCount: 1, Lines: []app.HunkLine{{Op: '-', Text: "# Old"}, {Op: '+', Text: "# New"}}},
+		}},
+	}
+}
+
+func press(t *testing.T, m tea.Model, keys ...string) tea.Model {
+	t.Helper()
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+		}
+		m, _ = m.Update(msg)
+	}
+	return m
+}
+
+func TestPickSplitsAndPicksLines(t *testing.T) {
+	in := files()
+	m := tea.Model(newModel(in))
+
+	// main.go's hunks are close enough to show as one
+	require.Len(t, m.(model).items, 2)
+	assert.Contains(t, m.View(), "2 hunks")
+
+	// Split them, skip the first, take only "two()" from the second, then
+	// take README.md whole
+	m = press(t, m, "s", "n", "l", "j", " ", "esc", "j", "y", "enter")
+	final := m.(model)
+	require.True(t, final.done)
+
+	got := final.files
+	assert.Equal(t, 0, got[0].Hunks[0].Selected())
+	assert.False(t, got[0].Hunks[1].Lines[0].Selected)
+	assert.True(t, got[0].Hunks[1].Lines[1].Selected)
+	assert.Equal(t, 2, got[1].Hunks[0].Selected())
+	assert.Equal(t, 0, in[1].Hunks[0].Selected(), "the caller's files are left alone")
+}
+
+func TestPickWholeFileAndCancel(t *testing.T) {
+	m := press(t, tea.Model(newModel(files())), "a")
+	for _, h := range m.(model).files[0].Hunks {
+		assert.Equal(t, len(h.Lines), h.Selected())
+	}
+	assert.Contains(t, m.View(), "1 of 2 hunks picked")
+
+	// A single hunk can't be split
+	m = press(t, m, "j", "s")
+	assert.Contains(t, m.View(), "can't be split")
+
+	m = press(t, m, "q")
+	assert.False(t, m.(model).done)
+}
@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// RawMarkdown disables markdown rendering so bodies are printed exactly as written
+var RawMarkdown bool
+
+const (
+	defaultWidth = 80
+	maxWidth     = 120
+)
+
+var (
+	mdHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdListItem  = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdCheckbox  = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	mdRule      = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdCodeSpan  = regexp.MustCompile("`([^`]+)`")
+	mdBold      = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdLink      = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)\)`)
+	mdComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+)
+
+// TerminalWidth returns the width to wrap rendered output at
+func TerminalWidth() int {
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return min(w, maxWidth)
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return min(cols, maxWidth)
+	}
+	return defaultWidth
+}
+
+// RenderMarkdown renders GitHub-flavoured markdown for the terminal, wrapped to
+// the terminal width. It returns the input unchanged when RawMarkdown is set.
+func RenderMarkdown(md string) string {
+	if RawMarkdown {
+		return md
+	}
+	return renderMarkdown(md, TerminalWidth())
+}
+
+// renderMarkdown handles headings, lists, checkboxes, code blocks, quotes, rules,
+// links and inline emphasis. Single newlines are kept, as GitHub does for PR bodies.
+func renderMarkdown(md string, width int) string {
+	md = mdComment.ReplaceAllString(strings.ReplaceAll(md, "\r\n", "\n"), "")
+
+	var out []string
+	inCode := false
+	blank := false
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			out = append(out, "    "+Gray(line))
+			continue
+		}
+
+		// Collapse runs of blank lines (often left behind by stripped comments)
+		if trimmed == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+
+		switch {
+		case mdHeading.MatchString(trimmed):
+			m := mdHeading.FindStringSubmatch(trimmed)
+			text := renderInline(m[2])
+			if len(m[1]) <= 2 {
+				out = append(out, Sage(Bold(text)))
+			} else {
+				out = append(out, Bold(text))
+			}
+		case mdRule.MatchString(line):
+			out = append(out, Gray(strings.Repeat("─", min(width, 40))))
+		case strings.HasPrefix(trimmed, ">"):
+			text := strings.TrimSpace(strings.TrimLeft(trimmed, ">"))
+			out = append(out, wrapLine(renderInline(text), width, Gray("│ "), Gray("│ "))...)
+		case mdListItem.MatchString(line):
+			m := mdListItem.FindStringSubmatch(line)
+			indent := strings.Repeat(" ", 2+len(strings.ReplaceAll(m[1], "\t", "  ")))
+			marker, text := m[2], m[3]
+			if strings.ContainsAny(marker, "-*+") {
+				marker = "•"
+			}
+			if cb := mdCheckbox.FindStringSubmatch(text); cb != nil {
+				text = cb[2]
+				if cb[1] == " " {
+					marker = "☐"
+				} else {
+					marker = Green("☑")
+				}
+			}
+			prefix := indent + marker + " "
+			hang := strings.Repeat(" ", visibleLen(prefix))
+			out = append(out, wrapLine(renderInline(text), width, prefix, hang)...)
+		default:
+			out = append(out, wrapLine(renderInline(trimmed), width, "", "")...)
+		}
+	}
+
+	return strings.TrimRight(strings.Join(out, "\n"), "\n")
+}
+
+// renderInline styles code spans, bold text and links within a line
+func renderInline(s string) string {
+	// Protect code spans from the other inline rules
+	var spans []string
+	s = mdCodeSpan.ReplaceAllStringFunc(s, func(m string) string {
+		spans = append(spans, Yellow(mdCodeSpan.FindStringSubmatch(m)[1]))
+		return "\x00" + strconv.Itoa(len(spans)-1) + "\x00"
+	})
+
+	s = mdLink.ReplaceAllStringFunc(s, func(m string) string {
+		parts := mdLink.FindStringSubmatch(m)
+		if parts[1] == "" || parts[1] == parts[2] {
+			return Blue(parts[2])
+		}
+		return Blue(parts[1]) + Gray(" ("+parts[2]+")")
+	})
+	s = mdBold.ReplaceAllStringFunc(s, func(m string) string {
+		parts := mdBold.FindStringSubmatch(m)
+		return Bold(parts[1] + parts[2])
+	})
+
+	for i, span := range spans {
+		s = strings.Replace(s, "\x00"+strconv.Itoa(i)+"\x00", span, 1)
+	}
+	return s
+}
+
+// wrapLine word-wraps styled text to width, measuring only visible characters.
+// The first line starts with prefix and continuation lines with hang.
+func wrapLine(s string, width int, prefix, hang string) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return []string{prefix}
+	}
+
+	var lines []string
+	cur := prefix + words[0]
+	curLen := visibleLen(cur)
+	for _, w := range words[1:] {
+		wl := visibleLen(w)
+		if curLen+1+wl > width {
+			lines = append(lines, cur)
+			cur = hang + w
+			curLen = visibleLen(hang) + wl
+			continue
+		}
+		cur += " " + w
+		curLen += 1 + wl
+	}
+	return append(lines, cur)
+}
+
+// visibleLen is the number of runes in s ignoring ANSI escape sequences
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+}
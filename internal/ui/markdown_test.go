@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "heading",
+			input:    "## Summary",
+			expected: "Summary",
+		},
+		{
+			name:     "bullets and checkboxes",
+			input:    "- one\n* two\n- [ ] todo\n- [x] done",
+			expected: "  • one\n  • two\n  ☐ todo\n  ☑ done",
+		},
+		{
+			name:     "numbered list",
+			input:    "1. first\n2. second",
+			expected: "  1. first\n  2. second",
+		},
+		{
+			name:     "code block kept verbatim",
+			input:    "```go\nfunc main() {}\n```",
+			expected: "    func main() {}",
+		},
+		{
+			name:     "inline styles and links",
+			input:    "Use **bold**, `code` and [docs](https://example.com)",
+			expected: "Use bold, code and docs (https://example.com)",
+		},
+		{
+			name:     "comments stripped and blank lines collapsed",
+			input:    "Intro\n<!-- describe your change -->\n\n\nMore",
+			expected: "Intro\n\nMore",
+		},
+		{
+			name:     "quote",
+			input:    "> note",
+			expected: "│ note",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, stripAnsi(renderMarkdown(tt.input, 80)))
+		})
+	}
+}
+
+func TestRenderMarkdownWraps(t *testing.T) {
+	out := stripAnsi(renderMarkdown("- "+strings.Repeat("word ", 12), 30))
+	lines := strings.Split(out, "\n")
+
+	assert.Greater(t, len(lines), 1)
+	for _, l := range lines {
+		assert.LessOrEqual(t, len([]rune(l)), 30)
+	}
+	assert.True(t, strings.HasPrefix(lines[1], "    word"), "continuation lines hang under the bullet text")
+}
+
+func TestRenderMarkdownRaw(t *testing.T) {
+	RawMarkdown = true
+	defer func() { RawMarkdown = false }()
+
+	assert.Equal(t, "## Title\n- item", RenderMarkdown("## Title\n- item"))
+}
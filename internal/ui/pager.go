@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/crazywolf132/sage/internal/git"
+	"golang.org/x/term"
+)
+
+// Page shows content through $PAGER (default "less -R") when stdout is a terminal,
+// and prints it directly otherwise or if the pager can't be started.
+func Page(content string) error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		_, err := fmt.Print(content)
+		return err
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less -R"
+	}
+	fields := strings.Fields(pager)
+	if len(fields) == 0 || fields[0] == "cat" {
+		_, err := fmt.Print(content)
+		return err
+	}
+
+	cmd, err := git.SetupSecureCommand(fields[0], fields[1:]...)
+	if err != nil {
+		_, err := fmt.Print(content)
+		return err
+	}
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		_, err := fmt.Print(content)
+		return err
+	}
+	// The pager exiting early (e.g. quitting before the end) isn't an error
+	_ = cmd.Wait()
+	return nil
+}
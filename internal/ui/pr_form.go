@@ -73,13 +73,13 @@ func AskPRForm(initial PRForm, ghc gh.Client) (PRForm, error) {
 		if err == nil && tmpl != "" {
 			// Show preview of the template that will be used
 			preview := truncateBody(tmpl, 10, 80)
-			fmt.Printf("\nUsing PR Template:\n%s\n\n", preview)
+			fmt.Printf("\nUsing PR Template:\n%s\n\n", RenderMarkdown(preview))
 			form.Body = tmpl
 		}
 	} else {
 		// Show preview of existing body (e.g., from AI generation)
 		preview := truncateBody(form.Body, 15, 100)
-		fmt.Printf("\nProposed PR Description:\n%s\n\n", preview)
+		fmt.Printf("\nProposed PR Description:\n%s\n\n", RenderMarkdown(preview))
 	}
 
 	qs := []*survey.Question{
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/batch"
+)
+
+// ConfirmAction asks before doing something the user asked for, defaulting
+// to no. Without prompts it goes ahead, as batch mode does for requested
+// actions.
+func ConfirmAction(msg string) (bool, error) {
+	if batch.Enabled() {
+		batch.Note("%s yes", msg)
+		return true, nil
+	}
+	var ok bool
+	err := survey.AskOne(&survey.Confirm{Message: msg}, &ok)
+	return ok, err
+}
+
+// AskConfirm asks a yes/no question. Without prompts it takes def.
+func AskConfirm(msg string, def bool) (bool, error) {
+	if batch.Enabled() {
+		batch.Note("%s %s", msg, yesNo(def))
+		return def, nil
+	}
+	ok := def
+	err := survey.AskOne(&survey.Confirm{Message: msg, Default: def}, &ok)
+	return ok, err
+}
+
+// AskSelect asks for one of options. Without prompts it takes def, or when
+// def is empty fails with hint naming the flag that answers instead. opts
+// such as survey.WithPageSize or survey.WithFilter apply to the prompt.
+func AskSelect(msg string, options []string, def, hint string, opts ...survey.AskOpt) (string, error) {
+	if batch.Enabled() {
+		if def == "" || !slices.Contains(options, def) {
+			return "", batch.NeedsInput(question(msg), hint)
+		}
+		batch.Note("%s %s", msg, def)
+		return def, nil
+	}
+	prompt := &survey.Select{Message: msg, Options: options}
+	if def != "" {
+		prompt.Default = def
+	}
+	var choice string
+	err := survey.AskOne(prompt, &choice, opts...)
+	return choice, err
+}
+
+// AskSelectIndex asks for one of options by position, for lists whose lines
+// may repeat. Without prompts it fails with hint.
+func AskSelectIndex(msg string, options []string, hint string, opts ...survey.AskOpt) (int, error) {
+	if batch.Enabled() {
+		return 0, batch.NeedsInput(question(msg), hint)
+	}
+	var choice int
+	err := survey.AskOne(&survey.Select{Message: msg, Options: options}, &choice, opts...)
+	return choice, err
+}
+
+// AskMultiSelect asks for any of options. Without prompts it fails with
+// hint, as only a person can pick.
+func AskMultiSelect(msg string, options []string, hint string, opts ...survey.AskOpt) ([]string, error) {
+	if batch.Enabled() {
+		return nil, batch.NeedsInput(question(msg), hint)
+	}
+	var chosen []string
+	err := survey.AskOne(&survey.MultiSelect{Message: msg, Options: options}, &chosen, opts...)
+	return chosen, err
+}
+
+// AskInput asks for a line of text. Without prompts it fails with hint.
+func AskInput(msg, def, hint string, opts ...survey.AskOpt) (string, error) {
+	if batch.Enabled() {
+		return "", batch.NeedsInput(question(msg), hint)
+	}
+	answer := def
+	err := survey.AskOne(&survey.Input{Message: msg, Default: def}, &answer, opts...)
+	return answer, err
+}
+
+// AskText asks for text that may run over several lines. Without prompts it
+// fails with hint.
+func AskText(msg, hint string, opts ...survey.AskOpt) (string, error) {
+	if batch.Enabled() {
+		return "", batch.NeedsInput(question(msg), hint)
+	}
+	var answer string
+	err := survey.AskOne(&survey.Multiline{Message: msg}, &answer, opts...)
+	return answer, err
+}
+
+// question turns a prompt such as "Pick a branch:" into the start of a
+// batch.NeedsInput error
+func question(msg string) string {
+	return fmt.Sprintf("%q needs an answer", strings.TrimRight(msg, ":? "))
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/crazywolf132/sage/internal/batch"
+	"github.com/crazywolf132/sage/internal/exitcode"
+)
+
+func TestPromptsWithoutInput(t *testing.T) {
+	batch.Enable()
+	defer batch.Disable()
+
+	if ok, err := ConfirmAction("Push these refs?"); err != nil || !ok {
+		t.Errorf("ConfirmAction = %v, %v; want to go ahead", ok, err)
+	}
+	if ok, err := AskConfirm("Force-push?", false); err != nil || ok {
+		t.Errorf("AskConfirm = %v, %v; want the default no", ok, err)
+	}
+	if Confirm("Delete it?") {
+		t.Error("Confirm should answer no")
+	}
+	if got, err := AskSelect("Pick one:", []string{"a", "b"}, "b", "pass --pick"); err != nil || got != "b" {
+		t.Errorf("AskSelect = %q, %v; want the default b", got, err)
+	}
+
+	var coded *exitcode.Error
+	if _, err := AskSelect("Pick a branch:", []string{"a", "b"}, "", "name it"); !errors.As(err, &coded) || coded.Code != exitcode.Usage {
+		t.Errorf("AskSelect without a default = %v, want a usage error", err)
+	}
+	if _, err := AskInput("Type 'prod' to deploy:", "", "pass --confirm prod"); err == nil {
+		t.Error("AskInput should need a flag")
+	}
+	if _, err := AskMultiSelect("Select files:", []string{"a"}, "name them"); err == nil {
+		t.Error("AskMultiSelect should need a flag")
+	}
+	if _, err := AskSelectIndex("Pick a line:", []string{"a", "a"}, "drop --interactive"); err == nil {
+		t.Error("AskSelectIndex should need a flag")
+	}
+	if _, err := AskText("Reply:", "pass --message"); err == nil {
+		t.Error("AskText should need a flag")
+	}
+}
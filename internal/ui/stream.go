@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// StreamAI runs generate, which should pass onToken to an AI client, and
+// shows the answer under title as it's written when stdout is a terminal.
+// Ctrl+C stops the answer instead of quitting sage: generate's context is
+// cancelled and StreamAI returns context.Canceled. The preview is cleared
+// once the answer is complete, for the caller to show it properly.
+func StreamAI(title string, generate func(ctx context.Context, onToken func(string)) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return streamResult(ctx, generate(ctx, nil))
+	}
+
+	fmt.Printf("%s %s\n", Sage("✨ "+title), Gray("(Ctrl+C to stop)"))
+	var written strings.Builder
+	err := generate(ctx, func(token string) {
+		written.WriteString(token)
+		fmt.Print(Gray(token))
+	})
+
+	// Clear the header and the preview, going back up to the header's row
+	width, _, werr := term.GetSize(int(os.Stdout.Fd()))
+	if werr != nil || width <= 0 {
+		width = 80
+	}
+	rows := previewRows("✨  "+title+" (Ctrl+C to stop)", width) + previewRows(written.String(), width) - 1
+	fmt.Printf("\r\x1b[%dA\x1b[J", rows)
+
+	return streamResult(ctx, err)
+}
+
+func streamResult(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Canceled
+	}
+	return err
+}
+
+// previewRows counts the terminal rows s takes up at width columns, taking
+// each rune as one column
+func previewRows(s string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(s, "\n") {
+		rows += max(1, (utf8.RuneCountInString(line)+width-1)/width)
+	}
+	return rows
+}
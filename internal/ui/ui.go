@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/crazywolf132/sage/internal/batch"
 	"github.com/crazywolf132/termchroma"
 )
 
@@ -33,6 +36,22 @@ func Gray(s string) string   { return gray + s + reset }
 func Sage(s string) string   { return sage + s + reset }
 func Bold(s string) string   { return bold + s + reset }
 
+var colorCode = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// VisibleLen is how many characters s takes up on screen, not counting
+// color codes, for lining up colored columns
+func VisibleLen(s string) int {
+	return utf8.RuneCountInString(colorCode.ReplaceAllString(s, ""))
+}
+
+// Plural is "s" unless n is 1, for words such as "file" in counts
+func Plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
 // Logging
 func Warnf(format string, args ...interface{}) {
 	fmt.Fprintf(stderr, Red("Warning: ")+format, args...)
@@ -124,8 +143,12 @@ func Error(msg string) {
 	fmt.Printf("%s %s\n", Red("✗"), msg)
 }
 
-// Confirm asks for user confirmation
+// Confirm asks for user confirmation. Without prompts it answers no.
 func Confirm(msg string) bool {
+	if batch.Enabled() {
+		batch.Note("%s: no", msg)
+		return false
+	}
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s %s [y/N]: ", Yellow("?"), msg)
 
@@ -254,3 +254,90 @@ func TestColorHeadings(t *testing.T) {
 		})
 	}
 }
+
+func TestColorDiff(t *testing.T) {
+	diff := "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n context\n-old\n+new"
+	got := ColorDiff(diff)
+
+	if stripAnsi(got) != diff {
+		t.Errorf("ColorDiff changed the text: %q", stripAnsi(got))
+	}
+	lines := strings.Split(got, "\n")
+	if lines[0] != Bold("--- a/f") || lines[1] != Bold("+++ b/f") {
+		t.Errorf("file headers should be bold, got %q and %q", lines[0], lines[1])
+	}
+	if lines[2] != Blue("@@ -1,2 +1,2 @@") {
+		t.Errorf("hunk header should be blue, got %q", lines[2])
+	}
+	if lines[3] != " context" {
+		t.Errorf("context lines should be left alone, got %q", lines[3])
+	}
+	if lines[4] != Red("-old") || lines[5] != Green("+new") {
+		t.Errorf("changes should be coloured, got %q and %q", lines[4], lines[5])
+	}
+}
+
+func TestHighlightDiff(t *testing.T) {
+	defer SetSyntaxTheme("")
+
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n /* a comment\n-   spanning */ func old() {}\n+   spanning */ func new() {}\n\\ No newline at end of file\n"
+	got := highlightDiff(diff, "")
+	if stripAnsi(got) != diff {
+		t.Fatalf("highlightDiff changed the text: %q", stripAnsi(got))
+	}
+	lines := strings.Split(got, "\n")
+	if lines[4] == " /* a comment" || lines[6] == "+   spanning */ func new() {}" {
+		t.Errorf("code should be highlighted, got %q and %q", lines[4], lines[6])
+	}
+	// The comment opened on the context line still colours the next lines
+	comment := lines[4][strings.Index(lines[4], "\x1b[38;2;"):strings.Index(lines[4], "/*")]
+	if !strings.Contains(lines[6], comment+"   spanning */") {
+		t.Errorf("multi-line comment lost its colour: %q", lines[6])
+	}
+
+	// Files without a known language keep the plain diff colours
+	plain := "--- a/notes\n+++ b/notes\n@@ -1 +1 @@\n-old\n+new"
+	if got := highlightDiff(plain, ""); got != ColorDiff(plain) {
+		t.Errorf("unknown languages should fall back to ColorDiff, got %q", got)
+	}
+
+	SetSyntaxTheme("off")
+	if got := highlightDiff(diff, ""); got != ColorDiff(diff) {
+		t.Errorf("highlighting off should fall back to ColorDiff, got %q", got)
+	}
+}
+
+func TestHighlightHunkNotATerminal(t *testing.T) {
+	// Test output isn't a terminal, so diffs stay as they are
+	hunk := "+x := 1"
+	if got := HighlightHunk("main.go", hunk); got != hunk {
+		t.Errorf("HighlightHunk should leave piped output alone, got %q", got)
+	}
+}
+
+func TestPreviewRows(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  int
+	}{
+		{"", 80, 1},
+		{"short", 80, 1},
+		{"one\ntwo", 80, 2},
+		{"ends with a newline\n", 80, 2},
+		{strings.Repeat("x", 80), 80, 1},
+		{strings.Repeat("x", 81), 80, 2},
+		{strings.Repeat("x", 100) + "\n\nend", 40, 5},
+	}
+	for _, tt := range tests {
+		if got := previewRows(tt.s, tt.width); got != tt.want {
+			t.Errorf("previewRows(%q, %d) = %d, want %d", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleLen(t *testing.T) {
+	if got := VisibleLen("\x1b[1m✓ ok\x1b[0m"); got != 4 {
+		t.Errorf("VisibleLen = %d, want 4", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/crazywolf132/sage/internal/dryrun"
 	"github.com/crazywolf132/sage/internal/git"
 )
 
@@ -20,6 +21,7 @@ type Operation struct {
 	Timestamp   time.Time `json:"timestamp"`
 	Ref         string    `json:"ref"`      // Git reference (commit hash, branch name, etc.)
 	Category    string    `json:"category"` // e.g., "commit", "merge", "rebase", etc.
+	Undone      bool      `json:"undone,omitempty"`
 	Metadata    struct {
 		Files    []string          `json:"files,omitempty"`     // Affected files
 		Branch   string            `json:"branch,omitempty"`    // Current branch
@@ -75,8 +77,12 @@ func (h *History) GetOperations(category string, since time.Time) []Operation {
 	return filtered
 }
 
-// Save persists the history to disk
+// Save persists the history to disk. Nothing is written in dry-run mode,
+// since nothing was done that could be undone.
 func (h *History) Save(repoPath string) error {
+	if dryrun.Enabled() {
+		return nil
+	}
 	if h.git == nil {
 		h.git = git.NewShellGit()
 	}
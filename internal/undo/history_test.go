@@ -3,9 +3,11 @@ package undo
 import (
 	"os"
 	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 
+	"github.com/crazywolf132/sage/internal/git"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -26,11 +28,13 @@ func (m *MockGit) Run(args ...string) (string, error) {
 
 // Implement other required methods from git.Service interface with empty implementations
 func (m *MockGit) IsRepo() (bool, error)                                         { return true, nil }
+func (m *MockGit) HasCommits() (bool, error)                                     { return true, nil }
 func (m *MockGit) IsClean() (bool, error)                                        { return true, nil }
 func (m *MockGit) StageAll() error                                               { return nil }
 func (m *MockGit) StageAllExcept(excludePaths []string) error                    { return nil }
 func (m *MockGit) IsPathStaged(path string) (bool, error)                        { return true, nil }
 func (m *MockGit) Commit(msg string, allowEmpty bool, stageAll bool) error       { return nil }
+func (m *MockGit) CommitPaths(msg string, allowEmpty bool, paths []string) error { return nil }
 func (m *MockGit) CurrentBranch() (string, error)                                { return "", nil }
 func (m *MockGit) Push(branch string, force bool) error                          { return nil }
 func (m *MockGit) PushWithLease(branch string) error                             { return nil }
@@ -54,7 +58,6 @@ func (m *MockGit) IsRebasing() (bool, error)
 func (m *MockGit) StatusPorcelain() (string, error)                              { return "", nil }
 func (m *MockGit) ResetSoft(ref string) error                                    { return nil }
 func (m *MockGit) ListBranches() ([]string, error)                               { return nil, nil }
-func (m *MockGit) Log(branch string, limit int, stats, all bool) (string, error) { return "", nil }
 func (m *MockGit) SquashCommits(startCommit string) error                        { return nil }
 func (m *MockGit) IsHeadBranch(branch string) (bool, error)                      { return false, nil }
 func (m *MockGit) GetFirstCommit() (string, error)                               { return "", nil }
@@ -62,6 +65,7 @@ func (m *MockGit) RunInteractive(cmd string, args ...string) error
 func (m *MockGit) GetBranchLastCommit(branch string) (time.Time, error)          { return time.Time{}, nil }
 func (m *MockGit) GetBranchCommitCount(branch string) (int, error)               { return 0, nil }
 func (m *MockGit) GetBranchMergeConflicts(branch string) (int, error)            { return 0, nil }
+func (m *MockGit) MergeConflicts(ours, theirs string) ([]string, error)          { return nil, nil }
 func (m *MockGit) Stash(message string) error                                    { return nil }
 func (m *MockGit) StashPop() error                                               { return nil }
 func (m *MockGit) StashList() ([]string, error)                                  { return nil, nil }
@@ -73,12 +77,99 @@ func (m *MockGit) IsAncestor(commit1, commit2 string) (bool, error)
 func (m *MockGit) SetConfig(key, value string, global bool) error                { return nil }
 func (m *MockGit) GetRepoPath() (string, error)                                  { return "", nil }
 func (m *MockGit) StagedDiff() (string, error)                                   { return "", nil }
-func (m *MockGit) GrepDiff(diff string, pattern string) ([]string, error)        { return nil, nil }
 func (m *MockGit) ListConflictedFiles() (string, error)                          { return "", nil }
 func (m *MockGit) GetConfigValue(key string) (string, error)                     { return "", nil }
 func (m *MockGit) MergeContinue() error                                          { return nil }
 func (m *MockGit) RebaseContinue() error                                         { return nil }
 
+func (m *MockGit) Commits(opts git.CommitsOptions) ([]git.Commit, error) {
+	return nil, nil
+}
+
+func (m *MockGit) Log(opts git.LogOptions) (*git.LogReader, error) {
+	return nil, nil
+}
+
+func (m *MockGit) IsApplyingPatches() (bool, error) {
+	return false, nil
+}
+
+func (m *MockGit) GrepDiff(diff string, patterns []*regexp.Regexp) []git.DiffMatch {
+	return nil
+}
+
+func (m *MockGit) PushRefs(opts git.PushRefsOptions) ([]git.PushRefResult, error) {
+	return nil, nil
+}
+
+func (m *MockGit) FetchRemotes(remotes []string, progress git.FetchProgress) error {
+	return nil
+}
+
+func (m *MockGit) AheadBehind(base, branch string) (int, int, error) {
+	return 0, 0, nil
+}
+
+func (m *MockGit) BranchSummaries(base string, conflicts bool) ([]git.BranchSummary, error) {
+	return nil, nil
+}
+
+func (m *MockGit) RewordCommit(commit, message string) (string, error) {
+	return commit, nil
+}
+
+func (m *MockGit) Upstream(branch string) (string, error) {
+	return "", nil
+}
+
+func (m *MockGit) BranchesAt(ref string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockGit) HooksDir() (string, error) {
+	return "", nil
+}
+
+func (m *MockGit) CountObjects() (git.ObjectCounts, error) {
+	return git.ObjectCounts{}, nil
+}
+
+func (m *MockGit) LargeBlobs(minSize int64, revs ...string) ([]git.Blob, error) {
+	return nil, nil
+}
+
+func (m *MockGit) Submodules() ([]git.Submodule, error) {
+	return nil, nil
+}
+
+func (m *MockGit) UpdateSubmodules(paths ...string) error {
+	return nil
+}
+
+func (m *MockGit) BlamePorcelain(path string) ([]git.BlameLine, error) {
+	return nil, nil
+}
+
+func (m *MockGit) CherryPick(commits ...string) error {
+	return nil
+}
+
+func (m *MockGit) Revert(commits ...string) error {
+	return nil
+}
+
+func (m *MockGit) SequencerInProgress() (string, error) {
+	return "", nil
+}
+
+func (m *MockGit) SequencerContinue(op string) error {
+	return nil
+}
+
+func (m *MockGit) SequencerAbort(op string) error {
+	return nil
+}
+
 func TestNewHistory(t *testing.T) {
 	h := NewHistory()
 	assert.NotNil(t, h)
@@ -0,0 +1,298 @@
+package undo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/dryrun"
+	"github.com/crazywolf132/sage/internal/git"
+	"github.com/google/uuid"
+)
+
+// journalRefPrefix keeps snapshot commits reachable so gc can't prune them
+// while the journal still points at them
+const journalRefPrefix = "refs/sage/journal/"
+
+// Snapshot is the state of the repository just before a sage command
+// changed it
+type Snapshot struct {
+	ID        string            `json:"id"`
+	Command   string            `json:"command"`
+	Timestamp time.Time         `json:"timestamp"`
+	Head      string            `json:"head"`                // commit HEAD pointed at
+	Branch    string            `json:"branch,omitempty"`    // checked out branch, empty when detached
+	Index     string            `json:"index,omitempty"`     // tree written from the index
+	WorkTree  string            `json:"work_tree,omitempty"` // stash-like commit of uncommitted changes, if any
+	Stash     string            `json:"stash,omitempty"`     // what refs/stash pointed at
+	Branches  map[string]string `json:"branches,omitempty"`  // local branch tips
+}
+
+// Journal is the list of snapshots, newest first
+type Journal struct {
+	Snapshots []Snapshot `json:"snapshots"`
+	MaxSize   int        `json:"max_size"`
+	git       git.Service
+}
+
+// NewJournal creates an empty journal
+func NewJournal(g git.Service) *Journal {
+	return &Journal{
+		Snapshots: make([]Snapshot, 0),
+		MaxSize:   50,
+		git:       g,
+	}
+}
+
+func (j *Journal) path() (string, error) {
+	gitDir, err := j.git.Run("rev-parse", "--git-dir")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(gitDir), ".sage", "journal.json"), nil
+}
+
+// Load reads the journal from disk
+func (j *Journal) Load() error {
+	path, err := j.path()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return fmt.Errorf("failed to parse journal: %w", err)
+	}
+	return nil
+}
+
+// Save writes the journal to disk
+func (j *Journal) Save() error {
+	if dryrun.Enabled() {
+		return nil
+	}
+	path, err := j.path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// Take snapshots the repository before command runs and adds it to the
+// journal, dropping the oldest snapshots past MaxSize
+func (j *Journal) Take(command string) (Snapshot, error) {
+	snap := Snapshot{
+		ID:        uuid.New().String(),
+		Command:   command,
+		Timestamp: time.Now(),
+		Branches:  map[string]string{},
+	}
+
+	head, err := j.git.Run("rev-parse", "HEAD")
+	if err != nil {
+		return snap, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	snap.Head = strings.TrimSpace(head)
+
+	// These fail for a detached HEAD, a conflicted index and an empty stash
+	if out, err := j.git.Run("symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		snap.Branch = strings.TrimSpace(out)
+	}
+	if out, err := j.git.Run("write-tree"); err == nil {
+		snap.Index = strings.TrimSpace(out)
+	}
+	if out, err := j.git.Run("stash", "create"); err == nil {
+		snap.WorkTree = strings.TrimSpace(out)
+	}
+	if out, err := j.git.Run("rev-parse", "-q", "--verify", "refs/stash"); err == nil {
+		snap.Stash = strings.TrimSpace(out)
+	}
+
+	out, err := j.git.Run("for-each-ref", "--format=%(refname:short) %(objectname)", "refs/heads")
+	if err != nil {
+		return snap, fmt.Errorf("failed to list branches: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if name, sha, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			snap.Branches[name] = sha
+		}
+	}
+
+	if snap.WorkTree != "" {
+		if _, err := j.git.Run("update-ref", journalRefPrefix+snap.ID, snap.WorkTree); err != nil {
+			return snap, fmt.Errorf("failed to keep uncommitted changes: %w", err)
+		}
+	}
+
+	j.Snapshots = append([]Snapshot{snap}, j.Snapshots...)
+	if len(j.Snapshots) > j.MaxSize {
+		for _, old := range j.Snapshots[j.MaxSize:] {
+			if old.WorkTree != "" {
+				_, _ = j.git.Run("update-ref", "-d", journalRefPrefix+old.ID)
+			}
+		}
+		j.Snapshots = j.Snapshots[:j.MaxSize]
+	}
+	return snap, nil
+}
+
+// Find returns the snapshot whose ID starts with id
+func (j *Journal) Find(id string) (Snapshot, bool) {
+	for _, s := range j.Snapshots {
+		if id != "" && strings.HasPrefix(s.ID, id) {
+			return s, true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// Restore puts the repository back the way snap recorded it: branch tips,
+// the checked out branch, staged and unstaged changes, and the latest
+// stash. Branches created since are left alone. The caller should Take a
+// snapshot first, so the restore itself can be undone.
+func (j *Journal) Restore(snap Snapshot) error {
+	current, err := j.git.Run("for-each-ref", "--format=%(refname:short) %(objectname)", "refs/heads")
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+	tips := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(current), "\n") {
+		if name, sha, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			tips[name] = sha
+		}
+	}
+
+	// Get off whatever is checked out, so every branch can be moved
+	if _, err := j.git.Run("checkout", "-f", "--detach", snap.Head); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", short(snap.Head), err)
+	}
+
+	for name, sha := range snap.Branches {
+		if tips[name] == sha {
+			continue
+		}
+		if _, err := j.git.Run("update-ref", "refs/heads/"+name, sha); err != nil {
+			return fmt.Errorf("failed to restore branch %s: %w", name, err)
+		}
+	}
+
+	if snap.Branch != "" {
+		if _, err := j.git.Run("checkout", "-f", snap.Branch); err != nil {
+			return fmt.Errorf("failed to check out %s: %w", snap.Branch, err)
+		}
+		if _, err := j.git.Run("reset", "--hard", snap.Head); err != nil {
+			return fmt.Errorf("failed to reset %s: %w", snap.Branch, err)
+		}
+	}
+
+	if snap.WorkTree != "" {
+		if _, err := j.git.Run("stash", "apply", "--index", snap.WorkTree); err != nil {
+			return fmt.Errorf("failed to restore uncommitted changes: %w", err)
+		}
+	} else if snap.Index != "" {
+		if _, err := j.git.Run("read-tree", snap.Index); err != nil {
+			return fmt.Errorf("failed to restore the index: %w", err)
+		}
+	}
+
+	if snap.Stash != "" {
+		list, _ := j.git.Run("log", "-g", "--format=%H", "refs/stash")
+		if !strings.Contains(list, snap.Stash) {
+			if _, err := j.git.Run("stash", "store", "-m", "sage undo: restored stash", snap.Stash); err != nil {
+				return fmt.Errorf("failed to restore the stash: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeletedBranch is a branch that no longer exists but that HEAD's reflog
+// remembers being on
+type DeletedBranch struct {
+	Name   string
+	Commit string // where the branch was when HEAD last left it
+}
+
+var reflogCheckout = regexp.MustCompile(`^checkout: moving from (\S+) to (\S+)$`)
+var fullHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// DeletedBranches finds branches HEAD's reflog has been on that aren't in
+// existing. reflog is the output of `git reflog show --format="%H %gs"`,
+// newest entry first.
+func DeletedBranches(reflog string, existing map[string]bool) []DeletedBranch {
+	lines := strings.Split(strings.TrimSpace(reflog), "\n")
+	seen := map[string]bool{}
+	var deleted []DeletedBranch
+	for i, line := range lines {
+		_, subject, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || i+1 >= len(lines) {
+			continue
+		}
+		m := reflogCheckout.FindStringSubmatch(subject)
+		if m == nil {
+			continue
+		}
+		name := m[1]
+		if existing[name] || seen[name] || fullHash.MatchString(name) {
+			continue
+		}
+		seen[name] = true
+		// The entry below is where HEAD was, on that branch, before moving
+		prev, _, _ := strings.Cut(strings.TrimSpace(lines[i+1]), " ")
+		if prev != "" {
+			deleted = append(deleted, DeletedBranch{Name: name, Commit: prev})
+		}
+	}
+	return deleted
+}
+
+// FindDeletedBranches lists branches recoverable from HEAD's reflog
+func (j *Journal) FindDeletedBranches() ([]DeletedBranch, error) {
+	branches, err := j.git.ListBranches()
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, b := range branches {
+		existing[b] = true
+	}
+	reflog, err := j.git.Run("reflog", "show", "--format=%H %gs", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the reflog: %w", err)
+	}
+	return DeletedBranches(reflog, existing), nil
+}
+
+// RecoverBranch recreates a deleted branch at the commit it was last on
+func (j *Journal) RecoverBranch(b DeletedBranch) error {
+	if _, err := j.git.Run("branch", b.Name, b.Commit); err != nil {
+		return fmt.Errorf("failed to recreate %s: %w", b.Name, err)
+	}
+	return nil
+}
+
+func short(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
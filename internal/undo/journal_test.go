@@ -0,0 +1,62 @@
+package undo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeletedBranches(t *testing.T) {
+	reflog := `c3 checkout: moving from feature/gone to main
+b2 commit: work on the feature
+a1 checkout: moving from main to feature/gone
+a1 checkout: moving from kept to main
+k9 commit: older work
+0123456789abcdef0123456789abcdef01234567 checkout: moving from 0123456789abcdef0123456789abcdef01234567 to kept
+a0 commit (initial): init`
+
+	deleted := DeletedBranches(reflog, map[string]bool{"main": true, "kept": true})
+	assert.Equal(t, []DeletedBranch{{Name: "feature/gone", Commit: "b2"}}, deleted)
+
+	assert.Empty(t, DeletedBranches("", nil))
+}
+
+func TestJournalTake(t *testing.T) {
+	m := &MockGit{}
+	m.On("Run", "rev-parse", "HEAD").Return("abc\n", nil)
+	m.On("Run", "symbolic-ref", "--short", "-q", "HEAD").Return("feat\n", nil)
+	m.On("Run", "write-tree").Return("tree1\n", nil)
+	m.On("Run", "stash", "create").Return("wip1\n", nil)
+	m.On("Run", "rev-parse", "-q", "--verify", "refs/stash").Return("", errors.New("no stash"))
+	m.On("Run", "for-each-ref", "--format=%(refname:short) %(objectname)", "refs/heads").Return("feat abc\nmain def\n", nil)
+	m.On("Run", "update-ref", "-d", "refs/sage/journal/old").Return("", nil)
+
+	j := NewJournal(m)
+	j.MaxSize = 1
+	j.Snapshots = []Snapshot{{ID: "old", WorkTree: "wip0"}}
+
+	// The new snapshot's ref name includes its random ID
+	m.On("Run", "update-ref", mock.MatchedBy(func(ref string) bool { return strings.HasPrefix(ref, journalRefPrefix) }), "wip1").Return("", nil)
+
+	snap, err := j.Take("sage commit")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", snap.Head)
+	assert.Equal(t, "feat", snap.Branch)
+	assert.Equal(t, "tree1", snap.Index)
+	assert.Equal(t, "wip1", snap.WorkTree)
+	assert.Empty(t, snap.Stash)
+	assert.Equal(t, map[string]string{"feat": "abc", "main": "def"}, snap.Branches)
+
+	assert.Len(t, j.Snapshots, 1)
+	assert.Equal(t, snap.ID, j.Snapshots[0].ID)
+	m.AssertCalled(t, "Run", "update-ref", "-d", "refs/sage/journal/old")
+
+	found, ok := j.Find(snap.ID[:8])
+	assert.True(t, ok)
+	assert.Equal(t, snap.ID, found.ID)
+	_, ok = j.Find("")
+	assert.False(t, ok)
+}
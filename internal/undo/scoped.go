@@ -0,0 +1,367 @@
+package undo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reversal is how undoing an operation puts things back
+type Reversal string
+
+const (
+	// ReversalReset moves the branch back, since nothing came after the
+	// operation's commit. Its changes stay staged.
+	ReversalReset Reversal = "reset"
+	// ReversalRevert adds a commit reverting the operation's, leaving the
+	// work that came after it in place
+	ReversalRevert Reversal = "revert"
+	// ReversalDirect undoes the operation as its type does, such as rolling
+	// a deploy back or aborting a merge
+	ReversalDirect Reversal = "direct"
+	// ReversalBlocked means the operation can't be undone on its own
+	ReversalBlocked Reversal = "blocked"
+)
+
+// Analysis says whether an operation can be undone on its own, and how
+type Analysis struct {
+	Op       Operation
+	Reversal Reversal
+	Reason   string // why, as a sentence for the user
+	// Dependents are the later recorded operations that build on it,
+	// newest first
+	Dependents []Operation
+	// Overlapping are later commits on the branch, recorded or not, that
+	// change the same files, so a revert may conflict with them
+	Overlapping []string
+}
+
+// Find returns the operation whose ID starts with id
+func (s *Service) Find(id string) (Operation, error) {
+	var found []Operation
+	for _, op := range s.history.Operations {
+		if id != "" && strings.HasPrefix(op.ID, id) {
+			found = append(found, op)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Operation{}, fmt.Errorf("no operation %s in the undo history", id)
+	case 1:
+		return found[0], nil
+	}
+	return Operation{}, fmt.Errorf("%s matches %d operations; give more of its ID", id, len(found))
+}
+
+// depGraph works out which operations build on which, reading what it
+// needs from git once
+type depGraph struct {
+	s       *Service
+	commits map[string]map[string]bool // branch -> the commits on it
+	files   map[string][]string        // commit -> the files it changed
+}
+
+func (s *Service) newDepGraph() *depGraph {
+	d := &depGraph{s: s, commits: map[string]map[string]bool{}, files: map[string][]string{}}
+	var refs []string
+	for _, op := range s.history.Operations {
+		if op.Type == "commit" && d.onBranch(op) {
+			refs = append(refs, op.Ref)
+		}
+	}
+	if len(refs) == 0 {
+		return d
+	}
+	out, err := s.git.Run(append([]string{"log", "--no-walk=unsorted", "--name-only", "--format=%x00%H"}, refs...)...)
+	if err != nil {
+		return d
+	}
+	for _, record := range strings.Split(out, "\x00")[1:] {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		for _, f := range lines[1:] {
+			if f = strings.TrimSpace(f); f != "" {
+				d.files[lines[0]] = append(d.files[lines[0]], f)
+			}
+		}
+	}
+	return d
+}
+
+// onBranch reports whether the operation's commit is still on the branch it
+// was made on
+func (d *depGraph) onBranch(op Operation) bool {
+	if op.Ref == "" || op.Metadata.Branch == "" {
+		return false
+	}
+	commits, ok := d.commits[op.Metadata.Branch]
+	if !ok {
+		commits = map[string]bool{}
+		if out, err := d.s.git.Run("rev-list", "refs/heads/"+op.Metadata.Branch); err == nil {
+			for _, c := range strings.Fields(out) {
+				commits[c] = true
+			}
+		}
+		d.commits[op.Metadata.Branch] = commits
+	}
+	return commits[op.Ref]
+}
+
+// changed returns the files an operation's commit changed
+func (d *depGraph) changed(op Operation) []string {
+	if files, ok := d.files[op.Ref]; ok {
+		return files
+	}
+	return op.Metadata.Files
+}
+
+// dependsOn reports whether later builds on earlier: it reverts it, deploys
+// over it, or comes after it on the same branch. Commits only depend on
+// earlier ones that changed some of the same files.
+func (d *depGraph) dependsOn(later, earlier Operation) bool {
+	if later.Metadata.Extra["reverts"] == earlier.ID {
+		return true
+	}
+	if later.Type == "deploy" || earlier.Type == "deploy" {
+		return later.Type == earlier.Type &&
+			later.Metadata.Branch == earlier.Metadata.Branch &&
+			later.Metadata.Extra["remote"] == earlier.Metadata.Extra["remote"] &&
+			later.Metadata.Extra["previous"] == earlier.Metadata.Extra["deployed"]
+	}
+	if earlier.Undone || later.Metadata.Branch != earlier.Metadata.Branch || !d.onBranch(earlier) || !d.onBranch(later) {
+		return false
+	}
+	if later.Type != "commit" || earlier.Type != "commit" {
+		return true
+	}
+	return overlap(d.changed(later), d.changed(earlier))
+}
+
+func overlap(a, b []string) bool {
+	seen := map[string]bool{}
+	for _, f := range a {
+		seen[f] = true
+	}
+	for _, f := range b {
+		if seen[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies maps each operation's ID to the IDs of the earlier operations
+// it builds on
+func (s *Service) Dependencies() map[string][]string {
+	return s.dependencies(s.newDepGraph())
+}
+
+func (s *Service) dependencies(d *depGraph) map[string][]string {
+	deps := map[string][]string{}
+	ops := s.history.Operations
+	for i, later := range ops {
+		for _, earlier := range ops[i+1:] {
+			if d.dependsOn(later, earlier) {
+				deps[later.ID] = append(deps[later.ID], earlier.ID)
+			}
+		}
+	}
+	return deps
+}
+
+// Analyze works out whether op can be undone on its own, and how
+func (s *Service) Analyze(op Operation) Analysis {
+	d := s.newDepGraph()
+	return s.analyze(op, d, s.dependencies(d))
+}
+
+// AnalyzeAll analyzes every recorded operation, newest first
+func (s *Service) AnalyzeAll() []Analysis {
+	d := s.newDepGraph()
+	deps := s.dependencies(d)
+	var all []Analysis
+	for _, op := range s.history.Operations {
+		all = append(all, s.analyze(op, d, deps))
+	}
+	return all
+}
+
+func (s *Service) analyze(op Operation, d *depGraph, deps map[string][]string) Analysis {
+	a := Analysis{Op: op}
+	for _, later := range s.history.Operations {
+		if later.Undone {
+			continue
+		}
+		for _, id := range deps[later.ID] {
+			if id == op.ID {
+				a.Dependents = append(a.Dependents, later)
+			}
+		}
+	}
+	blocked := func(reason string, args ...any) Analysis {
+		a.Reversal, a.Reason = ReversalBlocked, fmt.Sprintf(reason, args...)
+		return a
+	}
+
+	if op.Undone {
+		return blocked("It has already been undone")
+	}
+	switch op.Type {
+	case "deploy":
+		if len(a.Dependents) > 0 {
+			return blocked("%s was deployed again since (%s); undo that first", op.Metadata.Branch, shortID(a.Dependents[0].ID))
+		}
+		if op.Metadata.Extra["previous"] == "" {
+			a.Reversal, a.Reason = ReversalDirect, fmt.Sprintf("%s is deleted from %s, since the deploy created it", op.Metadata.Branch, op.Metadata.Extra["remote"])
+			return a
+		}
+		a.Reversal, a.Reason = ReversalDirect, fmt.Sprintf("%s goes back to the commit deployed before it", op.Metadata.Branch)
+		return a
+	case "merge", "rebase":
+		if len(a.Dependents) > 0 {
+			return blocked("Later operations build on it; restore a snapshot with 'sage undo --interactive' instead")
+		}
+		a.Reversal, a.Reason = ReversalDirect, fmt.Sprintf("The %s is aborted, or the branch is put back to before it", op.Type)
+		return a
+	case "commit":
+		return s.analyzeCommit(a, d)
+	}
+	return blocked("Operations of type %s can't be undone", op.Type)
+}
+
+func (s *Service) analyzeCommit(a Analysis, d *depGraph) Analysis {
+	op := a.Op
+	blocked := func(reason string, args ...any) Analysis {
+		a.Reversal, a.Reason = ReversalBlocked, fmt.Sprintf(reason, args...)
+		return a
+	}
+	if op.Ref == "" {
+		return blocked("Its commit wasn't recorded")
+	}
+	branch := op.Metadata.Branch
+	if cur, err := s.git.CurrentBranch(); err == nil && branch != "" && cur != branch {
+		return blocked("It was made on %s; switch to %s to undo it", branch, branch)
+	}
+	head, err := s.git.GetCommitHash("HEAD")
+	if err != nil {
+		return blocked("Couldn't read HEAD: %v", err)
+	}
+	if head == op.Ref {
+		if _, err := s.parent(op.Ref); err != nil {
+			return blocked("It's the repository's first commit")
+		}
+		a.Reversal, a.Reason = ReversalReset, "Nothing came after it, so the branch moves back and its changes stay staged"
+		return a
+	}
+	if ok, err := s.git.IsAncestor(op.Ref, "HEAD"); err != nil || !ok {
+		return blocked("Its commit is no longer on %s; it was rewritten or reset away", branch)
+	}
+
+	// Later commits that touch the same files, whether sage made them or not
+	mine := d.changed(op)
+	if out, err := s.git.Run("log", "--format=%x00%h", "--name-only", op.Ref+"..HEAD"); err == nil {
+		for _, record := range strings.Split(out, "\x00")[1:] {
+			lines := strings.Split(strings.TrimSpace(record), "\n")
+			if overlap(mine, lines[1:]) {
+				a.Overlapping = append(a.Overlapping, lines[0])
+			}
+		}
+	}
+	a.Reversal = ReversalRevert
+	if len(a.Overlapping) > 0 {
+		a.Reason = fmt.Sprintf("%d later commit(s) changed the same files, so a revert commit undoes it and may conflict with them", len(a.Overlapping))
+	} else {
+		a.Reason = "Later commits came after it but don't touch its files, so a revert commit undoes it cleanly"
+	}
+	return a
+}
+
+// Undo undoes the operation whose ID starts with id, even with later
+// operations on top of it: a commit nothing came after is reset away, any
+// other is reverted with a new commit, which is recorded too. The history
+// is updated but not saved.
+func (s *Service) Undo(id string) (Analysis, error) {
+	op, err := s.Find(id)
+	if err != nil {
+		return Analysis{}, err
+	}
+	a := s.Analyze(op)
+	switch a.Reversal {
+	case ReversalBlocked:
+		return a, fmt.Errorf("can't undo %s: %s", shortID(op.ID), strings.ToLower(a.Reason[:1])+a.Reason[1:])
+	case ReversalRevert:
+		if err := s.revertCommit(op); err != nil {
+			return a, err
+		}
+	default:
+		if err := s.UndoOperation(op.ID); err != nil {
+			return a, err
+		}
+	}
+	s.markUndone(op.ID)
+	return a, nil
+}
+
+// revertCommit adds a commit reverting op's and records it as an operation
+// of its own, so it can be undone in turn
+func (s *Service) revertCommit(op Operation) error {
+	args := []string{"revert", "--no-edit"}
+	if out, err := s.git.Run("rev-list", "--parents", "-n", "1", op.Ref); err == nil && len(strings.Fields(out)) > 2 {
+		// Revert a merge against the branch it was merged into
+		args = append(args, "-m", "1")
+	}
+	if _, err := s.git.Run(append(args, op.Ref)...); err != nil {
+		if _, verr := s.git.Run("rev-parse", "-q", "--verify", "REVERT_HEAD"); verr == nil {
+			s.git.Run("revert", "--abort")
+		}
+		return fmt.Errorf("reverting %s conflicts with later changes, so nothing was changed: %w", shortID(op.ID), err)
+	}
+
+	ref, err := s.git.GetCommitHash("HEAD")
+	if err != nil {
+		return err
+	}
+	revert := Operation{
+		ID:          uuid.New().String(),
+		Type:        "commit",
+		Description: "Revert: " + op.Description,
+		Command:     "sage undo " + shortID(op.ID),
+		Timestamp:   time.Now(),
+		Ref:         ref,
+		Category:    "commit",
+	}
+	revert.Metadata.Branch = op.Metadata.Branch
+	revert.Metadata.Files = op.Metadata.Files
+	revert.Metadata.Extra = map[string]string{"reverts": op.ID}
+	s.history.AddOperation(revert)
+	return nil
+}
+
+func (s *Service) markUndone(id string) {
+	for i := range s.history.Operations {
+		if s.history.Operations[i].ID == id {
+			s.history.Operations[i].Undone = true
+		}
+	}
+}
+
+// parent returns a commit's first parent
+func (s *Service) parent(ref string) (string, error) {
+	out, err := s.git.Run("rev-list", "--parents", "-n", "1", ref)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("%s has no parent", ref)
+	}
+	return fields[1], nil
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
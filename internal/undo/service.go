@@ -68,6 +68,8 @@ func (s *Service) UndoOperation(opID string) error {
 		return s.undoMerge(op)
 	case "rebase":
 		return s.undoRebase(op)
+	case "deploy":
+		return s.undoDeploy(op)
 	default:
 		return fmt.Errorf("unsupported operation type: %s", op.Type)
 	}
@@ -104,7 +106,11 @@ func (s *Service) undoCommit(op Operation) error {
 	}
 
 	// Reset to the previous commit
-	if err := s.git.ResetSoft(op.Ref + "~1"); err != nil {
+	parent, err := s.parent(op.Ref)
+	if err != nil {
+		return fmt.Errorf("failed to reset commit: %w", err)
+	}
+	if err := s.git.ResetSoft(parent); err != nil {
 		return fmt.Errorf("failed to reset commit: %w", err)
 	}
 
@@ -131,6 +137,26 @@ func (s *Service) undoRebase(op Operation) error {
 	return s.git.ResetSoft(op.Ref)
 }
 
+// undoDeploy moves the deploy branch back to where it was before the deploy,
+// refusing if someone else has pushed to it since
+func (s *Service) undoDeploy(op Operation) error {
+	remote := op.Metadata.Extra["remote"]
+	previous := op.Metadata.Extra["previous"]
+	deployed := op.Metadata.Extra["deployed"]
+	branch := op.Metadata.Branch
+	if remote == "" || branch == "" || deployed == "" {
+		return fmt.Errorf("deploy record is missing target information")
+	}
+
+	lease := "--force-with-lease=refs/heads/" + branch + ":" + deployed
+	if previous == "" {
+		_, err := s.git.Run("push", lease, remote, "--delete", branch)
+		return err
+	}
+	_, err := s.git.Run("push", lease, remote, previous+":refs/heads/"+branch)
+	return err
+}
+
 // GetHistory returns the undo history
 func (s *Service) GetHistory() *History {
 	return s.history
@@ -0,0 +1,104 @@
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/crazywolf132/sage/internal/config"
+	"github.com/hashicorp/go-version"
+)
+
+// Release channels, picked with update.channel
+const (
+	ChannelStable = "stable" // full releases only
+	ChannelBeta   = "beta"   // prereleases too
+)
+
+// releasesURL lists sage's releases, newest first
+const releasesURL = "https://api.github.com/repos/crazywolf132/sage/releases?per_page=30"
+
+// Check intervals, picked with update.check_interval
+const (
+	IntervalDaily  = "daily"
+	IntervalWeekly = "weekly"
+	IntervalNever  = "never"
+)
+
+// Channel returns the release channel update.channel names, stable when unset
+func Channel() (string, error) {
+	ch := strings.ToLower(strings.TrimSpace(config.Get("update.channel", false)))
+	switch ch {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelBeta:
+		return ch, nil
+	}
+	return "", fmt.Errorf("invalid update.channel %q: use %s or %s", ch, ChannelStable, ChannelBeta)
+}
+
+// CheckInterval returns how often to look for a new release, from
+// update.check_interval. Zero means never.
+func CheckInterval() time.Duration {
+	switch strings.ToLower(strings.TrimSpace(config.Get("update.check_interval", false))) {
+	case IntervalWeekly:
+		return 7 * 24 * time.Hour
+	case IntervalNever, "off":
+		return 0
+	}
+	return 24 * time.Hour
+}
+
+// LatestVersionOn returns the newest release on channel, without a "v"
+// prefix
+func LatestVersionOn(channel string) (string, error) {
+	if channel != ChannelBeta {
+		return getLatestReleasePublic()
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("GET", releasesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "sage-cli")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s returned %d", releasesURL, resp.StatusCode)
+	}
+
+	var releases []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return "", err
+	}
+	return newestRelease(releases, true)
+}
+
+// newestRelease returns the highest version among releases, leaving out
+// drafts, and prereleases unless prerelease is set
+func newestRelease(releases []githubRelease, prerelease bool) (string, error) {
+	var newest *version.Version
+	for _, r := range releases {
+		if r.Draft || (r.Prerelease && !prerelease) {
+			continue
+		}
+		v, err := version.NewVersion(strings.TrimPrefix(r.TagName, "v"))
+		if err != nil {
+			continue
+		}
+		if newest == nil || v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no releases found")
+	}
+	return newest.Original(), nil
+}
@@ -0,0 +1,50 @@
+package update
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewestRelease(t *testing.T) {
+	releases := []githubRelease{
+		{TagName: "v1.2.0"},
+		{TagName: "v1.3.0-beta.2", Prerelease: true},
+		{TagName: "v1.4.0", Draft: true},
+		{TagName: "v1.3.0-beta.10", Prerelease: true},
+		{TagName: "not-a-version"},
+		{TagName: "v1.1.5"},
+	}
+
+	got, err := newestRelease(releases, false)
+	if err != nil || got != "1.2.0" {
+		t.Errorf("stable: got %q, %v; want 1.2.0", got, err)
+	}
+	got, err = newestRelease(releases, true)
+	if err != nil || got != "1.3.0-beta.10" {
+		t.Errorf("beta: got %q, %v; want 1.3.0-beta.10", got, err)
+	}
+
+	if _, err := newestRelease([]githubRelease{{TagName: "v2.0.0", Draft: true}}, true); err == nil {
+		t.Error("expected an error with only drafts")
+	}
+}
+
+func TestSaveCheckStateKeepsNotice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "update_check.json")
+	noticed := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := writeCheckState(path, checkState{Channel: ChannelBeta, LastNotice: noticed}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveCheckState(path, "1.3.0-beta.1"); err != nil {
+		t.Fatal(err)
+	}
+	state := loadCheckState(path)
+	if state.Version != "1.3.0-beta.1" || state.Channel != ChannelBeta || !state.LastNotice.Equal(noticed) {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if time.Since(state.LastCheck) > time.Minute {
+		t.Errorf("LastCheck was not updated: %v", state.LastCheck)
+	}
+}
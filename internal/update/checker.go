@@ -15,8 +15,10 @@ import (
 )
 
 type checkState struct {
-	LastCheck time.Time `json:"last_check"`
-	Version   string    `json:"version"`
+	LastCheck  time.Time `json:"last_check"`
+	Version    string    `json:"version"`
+	Channel    string    `json:"channel,omitempty"`
+	LastNotice time.Time `json:"last_notice,omitempty"`
 }
 
 // CheckForUpdates checks if a newer version of sage is available
@@ -61,7 +63,7 @@ func CheckForUpdates(ghc gh.Client, currentVersion string) error {
 	// Compare versions
 	if latest.GreaterThan(current) {
 		ui.Info(fmt.Sprintf("A new version of sage is available: %s → %s", current, latest))
-		ui.Info("To update, run: go install github.com/crazywolf132/sage@latest")
+		ui.Info("To update, run: sage update (or go install github.com/crazywolf132/sage@latest)")
 		fmt.Println() // Add a blank line for better readability
 	}
 
@@ -93,30 +95,51 @@ func getConfigPath() (string, error) {
 }
 
 func shouldCheck(path string) bool {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return true // Check if we can't read the file
-	}
+	// Check if 24 hours have passed since last check
+	return time.Since(loadCheckState(path).LastCheck) >= 24*time.Hour
+}
 
+// loadCheckState reads the last check, or a zero state when there is none
+// or it can't be read
+func loadCheckState(path string) checkState {
 	var state checkState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return true // Check if we can't parse the file
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return checkState{}
+		}
 	}
-
-	// Check if 24 hours have passed since last check
-	return time.Since(state.LastCheck) >= 24*time.Hour
+	return state
 }
 
 func saveCheckState(path string, version string) error {
-	state := checkState{
-		LastCheck: time.Now(),
-		Version:   version,
-	}
+	state := loadCheckState(path)
+	state.LastCheck = time.Now()
+	state.Version = version
+	return writeCheckState(path, state)
+}
 
+// writeCheckState replaces the state file in one step, so a background
+// check finishing as another sage starts never leaves half a file
+func writeCheckState(path string, state checkState) error {
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".update_check-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
@@ -3,6 +3,7 @@ package update
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -71,6 +72,138 @@ func (m *mockGitHubClient) UpdatePR(num int, pr *gh.PullRequest) error {
 	return nil
 }
 
+func (m *mockGitHubClient) ListPRFiles(num int) ([]gh.PRFile, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) GetBranchProtection(branch string) (*gh.BranchProtection, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) UpdateBranchProtection(branch string, bp *gh.BranchProtection) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ListPRCheckRuns(num int) ([]gh.CheckRun, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) GetJobLog(jobID int64) (string, error) {
+	return "", nil
+}
+
+func (m *mockGitHubClient) CreateIssue(title, body string, labels []string) (*gh.Issue, error) {
+	return &gh.Issue{}, nil
+}
+
+func (m *mockGitHubClient) CommitAuthorLogin(sha string) (string, error) {
+	return "", nil
+}
+
+func (m *mockGitHubClient) IsCollaborator(login string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockGitHubClient) SearchPRs(query string, page, perPage int) (*gh.PRSearchResult, error) {
+	return &gh.PRSearchResult{}, nil
+}
+
+func (m *mockGitHubClient) GetCommit(sha string) (*gh.CommitInfo, error) {
+	return &gh.CommitInfo{SHA: sha}, nil
+}
+
+func (m *mockGitHubClient) OpenPRsByHead() (map[string]gh.PullRequest, error) {
+	return map[string]gh.PullRequest{}, nil
+}
+
+func (m *mockGitHubClient) MergePRWithMessage(num int, method, title, message string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ListPRCommits(num int) ([]gh.CommitInfo, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) GetReleaseByTag(tag string) (*gh.Release, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) CreateRelease(tag, name, body string, prerelease bool) (*gh.Release, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) DeleteReleaseAsset(id int64) error {
+	return nil
+}
+
+func (m *mockGitHubClient) UploadReleaseAsset(release *gh.Release, name, contentType string, body io.Reader, size int64) (*gh.ReleaseAsset, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) ApprovePR(num int) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ReopenPR(num int) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ClosedPRsForBranch(branch string) ([]gh.PullRequest, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) CurrentUser() (string, error) {
+	return "", nil
+}
+
+func (m *mockGitHubClient) PRsForCommit(sha string) ([]gh.PullRequest, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) ListReviewThreads(num int) ([]gh.ReviewThread, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) ReplyToReviewComment(num int, commentID int64, body string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ResolveReviewThread(threadID string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) SubmitReview(num int, event, body string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) ListPRReviews(num int) ([]gh.Review, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) GetMergeOptions(num int) (*gh.MergeOptions, error) {
+	return nil, nil
+}
+
+func (m *mockGitHubClient) EnableAutoMerge(num int, method, title, message string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) EnqueuePR(num int) (int, error) {
+	return 0, nil
+}
+
+func (m *mockGitHubClient) SetDraft(num int, draft bool) error {
+	return nil
+}
+
+func (m *mockGitHubClient) RemoveLabel(prNumber int, label string) error {
+	return nil
+}
+
+func (m *mockGitHubClient) RemoveReviewers(prNumber int, reviewers []string) error {
+	return nil
+}
+
 func TestCheckForUpdates(t *testing.T) {
 	// Create a temporary directory for test files
 	tmpDir, err := os.MkdirTemp("", "sage-test-*")
@@ -13,55 +13,63 @@ import (
 )
 
 type githubRelease struct {
-	TagName string `json:"tag_name"`
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
 }
 
-// CheckForUpdatesPublic checks if a newer version of sage is available using the public GitHub API
+// CheckForUpdatesPublic tells the user when a newer release is out on their
+// channel, at most once per update.check_interval. The notice comes from
+// the last check, so it never waits on the network; when a check is due, it
+// runs in the background and its result shows up on a later run.
 func CheckForUpdatesPublic(currentVersion string) error {
 	// Skip check for dev versions
 	if currentVersion == "dev" || currentVersion == "" {
 		return nil
 	}
-
-	// Get config file path
-	configPath, err := getConfigPath()
+	current, err := version.NewVersion(strings.TrimPrefix(currentVersion, "v"))
 	if err != nil {
-		return nil // Silently fail if we can't get config path
+		return nil // Silently fail for dev versions
 	}
 
-	// Check if we need to perform an update check
-	if !shouldCheck(configPath) {
+	interval := CheckInterval()
+	if interval == 0 {
 		return nil
 	}
-
-	// Get current version
-	current, err := version.NewVersion(strings.TrimPrefix(currentVersion, "v"))
+	channel, err := Channel()
 	if err != nil {
-		return nil // Silently fail for dev versions
+		return nil // 'sage config doctor' reports the bad value
 	}
-
-	// Get latest version from GitHub's public API
-	latestVersion, err := getLatestReleasePublic()
+	configPath, err := getConfigPath()
 	if err != nil {
-		return nil // Silently fail if we can't reach GitHub
+		return nil // Silently fail if we can't get config path
 	}
 
-	// Parse latest version
-	latest, err := version.NewVersion(latestVersion)
-	if err != nil {
-		return nil // Silently fail for invalid versions
+	state := loadCheckState(configPath)
+	if state.Channel != channel {
+		// What was found on another channel doesn't count
+		state = checkState{Channel: channel}
 	}
 
-	// Save check state
-	_ = saveCheckState(configPath, latestVersion)
-
-	// Compare versions
-	if latest.GreaterThan(current) {
+	if latest, err := version.NewVersion(state.Version); err == nil && latest.GreaterThan(current) && time.Since(state.LastNotice) >= interval {
 		ui.Info(fmt.Sprintf("A new version of sage is available: %s → %s", current, latest))
-		ui.Info("To update, run: go install github.com/crazywolf132/sage@latest")
+		ui.Info("To update, run: sage update (or go install github.com/crazywolf132/sage@latest)")
 		fmt.Println() // Add a blank line for better readability
+		state.LastNotice = time.Now()
+		_ = writeCheckState(configPath, state)
 	}
 
+	if time.Since(state.LastCheck) >= interval {
+		go func() {
+			latest, err := LatestVersionOn(channel)
+			if err != nil {
+				return // Try again next time
+			}
+			state.LastCheck = time.Now()
+			state.Version = latest
+			_ = writeCheckState(configPath, state)
+		}()
+	}
 	return nil
 }
 
@@ -105,3 +113,8 @@ func getLatestReleasePublic() (string, error) {
 
 	return strings.TrimPrefix(release.TagName, "v"), nil
 }
+
+// LatestVersion returns the newest released version, without a "v" prefix
+func LatestVersion() (string, error) {
+	return getLatestReleasePublic()
+}
@@ -0,0 +1,298 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// releaseDownloadURL is where release assets are published
+const releaseDownloadURL = "https://github.com/crazywolf132/sage/releases/download"
+
+// checksumsFile is the goreleaser checksum manifest; checksumsFile+".sig" and
+// checksumsFile+".pem" are its cosign signature and signing certificate.
+const checksumsFile = "checksums.txt"
+
+// signerIdentity is the release workflow that signs checksums.txt
+const (
+	signerIdentity = `^https://github\.com/crazywolf132/sage/\.github/workflows/release\.yml@refs/.+$`
+	signerIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// ErrUnverified means the signature of checksums.txt couldn't be checked,
+// because cosign isn't installed or the release has no signature
+var ErrUnverified = errors.New("could not verify the signature of " + checksumsFile)
+
+// downloadClient is used for release assets; a variable so tests can replace it
+var downloadClient = &http.Client{Timeout: 2 * time.Minute}
+
+// Release is a verified set of assets for one version and platform
+type Release struct {
+	Version   string
+	Archive   string            // archive file name for this platform
+	Checksums map[string]string // file name -> sha256
+	Signed    bool              // checksums.txt signature was verified with cosign
+}
+
+// ArchiveName returns the goreleaser archive name for a version and platform
+func ArchiveName(version, goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	}
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("sage_%s_%s_%s.%s", strings.TrimPrefix(version, "v"), strings.ToUpper(goos[:1])+goos[1:], arch, ext)
+}
+
+// ParseChecksums reads a "<sha256>  <file>" manifest
+func ParseChecksums(data []byte) map[string]string {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && len(fields[0]) == sha256.Size*2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	return sums
+}
+
+// SHA256 returns the hex digest of data
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileSHA256 returns the hex digest of the file at p
+func FileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FetchRelease downloads checksums.txt for version and verifies its cosign
+// signature. A signature that can't be checked is ErrUnverified unless
+// skipSignature accepts the checksums alone.
+func FetchRelease(version string, skipSignature bool) (*Release, error) {
+	tag := "v" + strings.TrimPrefix(version, "v")
+	sums, err := download(fmt.Sprintf("%s/%s/%s", releaseDownloadURL, tag, checksumsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s for %s: %w", checksumsFile, tag, err)
+	}
+
+	rel := &Release{
+		Version:   strings.TrimPrefix(version, "v"),
+		Archive:   ArchiveName(version, runtime.GOOS, runtime.GOARCH),
+		Checksums: ParseChecksums(sums),
+	}
+	if len(rel.Checksums) == 0 {
+		return nil, fmt.Errorf("%s for %s has no checksums", checksumsFile, tag)
+	}
+
+	err = verifySignature(tag, sums)
+	switch {
+	case err == nil:
+		rel.Signed = true
+	case !errors.Is(err, ErrUnverified) || !skipSignature:
+		return nil, err
+	}
+	return rel, nil
+}
+
+// verifySignature checks checksums.txt against its keyless cosign signature.
+// It returns ErrUnverified when cosign is not installed or the release has
+// no signature; a signature that fails to verify is a different error.
+func verifySignature(tag string, sums []byte) error {
+	cosign, err := exec.LookPath("cosign")
+	if err != nil {
+		return fmt.Errorf("%w: cosign is not installed", ErrUnverified)
+	}
+	sig, err := download(fmt.Sprintf("%s/%s/%s.sig", releaseDownloadURL, tag, checksumsFile))
+	if err != nil {
+		return fmt.Errorf("%w: no signature for %s: %v", ErrUnverified, tag, err)
+	}
+	cert, err := download(fmt.Sprintf("%s/%s/%s.pem", releaseDownloadURL, tag, checksumsFile))
+	if err != nil {
+		return fmt.Errorf("%w: no signing certificate for %s: %v", ErrUnverified, tag, err)
+	}
+
+	dir, err := os.MkdirTemp("", "sage-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{checksumsFile: sums, "sig": sig, "pem": cert}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command(cosign, "verify-blob",
+		"--signature", filepath.Join(dir, "sig"),
+		"--certificate", filepath.Join(dir, "pem"),
+		"--certificate-identity-regexp", signerIdentity,
+		"--certificate-oidc-issuer", signerIssuer,
+		filepath.Join(dir, checksumsFile))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("signature of %s did not verify; the release may have been tampered with:\n%s", checksumsFile, out)
+	}
+	return nil
+}
+
+// DownloadBinary downloads this platform's archive, checks it against the
+// release checksums and returns the sage binary inside it
+func (r *Release) DownloadBinary() ([]byte, error) {
+	want, ok := r.Checksums[r.Archive]
+	if !ok {
+		return nil, fmt.Errorf("no release archive for %s/%s (%s)", runtime.GOOS, runtime.GOARCH, r.Archive)
+	}
+	archive, err := download(fmt.Sprintf("%s/v%s/%s", releaseDownloadURL, r.Version, r.Archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", r.Archive, err)
+	}
+	if got := SHA256(archive); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", r.Archive, want, got)
+	}
+	return extractBinary(r.Archive, archive)
+}
+
+// extractBinary returns the sage executable from a .tar.gz or .zip archive
+func extractBinary(name string, archive []byte) ([]byte, error) {
+	binary := "sage"
+	if strings.HasSuffix(name, ".zip") {
+		binary = "sage.exe"
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) == binary {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s not found in %s", binary, name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binary {
+			return io.ReadAll(tr)
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %s", binary, name)
+}
+
+// VerifyInstalled compares the running executable with the binary from the
+// release it claims to be
+func VerifyInstalled(r *Release) (bool, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	installed, err := FileSHA256(exe)
+	if err != nil {
+		return false, err
+	}
+	released, err := r.DownloadBinary()
+	if err != nil {
+		return false, err
+	}
+	return installed == SHA256(released), nil
+}
+
+// InstallBinary replaces the running executable with data. The new binary is
+// written next to the old one and renamed into place so a failure never
+// leaves a half-written executable.
+func InstallBinary(data []byte) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return "", err
+	}
+
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write new binary: %w", err)
+	}
+	// Windows can't overwrite a running executable, but it can rename it
+	old := exe + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		_ = os.Rename(old, exe)
+		return "", fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	if runtime.GOOS != "windows" {
+		_ = os.Remove(old)
+	}
+	return exe, nil
+}
+
+func download(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "sage-cli")
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,154 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		version, goos, goarch, want string
+	}{
+		{"1.2.3", "linux", "amd64", "sage_1.2.3_Linux_x86_64.tar.gz"},
+		{"v1.2.3", "darwin", "arm64", "sage_1.2.3_Darwin_arm64.tar.gz"},
+		{"1.2.3", "windows", "amd64", "sage_1.2.3_Windows_x86_64.zip"},
+	}
+	for _, tt := range tests {
+		if got := ArchiveName(tt.version, tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("ArchiveName(%q, %q, %q) = %q, want %q", tt.version, tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	data := []byte(sum + "  sage_1.0.0_Linux_x86_64.tar.gz\n" +
+		strings.ToUpper(sum) + " *sage_1.0.0_Windows_x86_64.zip\n" +
+		"not a checksum line\n")
+
+	sums := ParseChecksums(data)
+	if len(sums) != 2 {
+		t.Fatalf("expected 2 checksums, got %d: %v", len(sums), sums)
+	}
+	if sums["sage_1.0.0_Linux_x86_64.tar.gz"] != sum {
+		t.Errorf("unexpected checksum for tarball: %q", sums["sage_1.0.0_Linux_x86_64.tar.gz"])
+	}
+	if sums["sage_1.0.0_Windows_x86_64.zip"] != sum {
+		t.Errorf("checksums should be normalised to lower case, got %q", sums["sage_1.0.0_Windows_x86_64.zip"])
+	}
+}
+
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestExtractBinary(t *testing.T) {
+	tarball := makeTarGz(t, map[string]string{"README.md": "docs", "sage": "binary"})
+	got, err := extractBinary("sage_1.0.0_Linux_x86_64.tar.gz", tarball)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "binary" {
+		t.Errorf("expected binary contents, got %q", got)
+	}
+
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	w, _ := zw.Create("sage.exe")
+	w.Write([]byte("windows binary"))
+	zw.Close()
+	got, err = extractBinary("sage_1.0.0_Windows_x86_64.zip", zbuf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "windows binary" {
+		t.Errorf("expected windows binary contents, got %q", got)
+	}
+
+	if _, err := extractBinary("x.tar.gz", makeTarGz(t, map[string]string{"other": "x"})); err == nil {
+		t.Error("expected an error when the archive has no sage binary")
+	}
+}
+
+type staticTransport map[string][]byte
+
+func (s staticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := s[req.URL.String()]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}, nil
+}
+
+func TestDownloadBinary(t *testing.T) {
+	archive := makeTarGz(t, map[string]string{"sage": "binary"})
+	rel := &Release{
+		Version:   "1.0.0",
+		Archive:   "sage_1.0.0_Linux_x86_64.tar.gz",
+		Checksums: map[string]string{"sage_1.0.0_Linux_x86_64.tar.gz": SHA256(archive)},
+	}
+
+	orig := downloadClient
+	defer func() { downloadClient = orig }()
+	downloadClient = &http.Client{Transport: staticTransport{
+		releaseDownloadURL + "/v1.0.0/sage_1.0.0_Linux_x86_64.tar.gz": archive,
+	}}
+
+	got, err := rel.DownloadBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "binary" {
+		t.Errorf("expected binary contents, got %q", got)
+	}
+
+	// A tampered archive must be rejected
+	rel.Checksums[rel.Archive] = strings.Repeat("0", 64)
+	if _, err := rel.DownloadBinary(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestFetchReleaseNeedsSignature(t *testing.T) {
+	sums := []byte(strings.Repeat("a", 64) + "  sage_1.0.0_Linux_x86_64.tar.gz\n")
+	orig := downloadClient
+	defer func() { downloadClient = orig }()
+	downloadClient = &http.Client{Transport: staticTransport{
+		releaseDownloadURL + "/v1.0.0/checksums.txt": sums,
+	}}
+	// Without cosign on the PATH the signature can't be checked
+	t.Setenv("PATH", t.TempDir())
+
+	if _, err := FetchRelease("1.0.0", false); !errors.Is(err, ErrUnverified) {
+		t.Fatalf("got %v, want ErrUnverified", err)
+	}
+	rel, err := FetchRelease("1.0.0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Signed || len(rel.Checksums) != 1 {
+		t.Errorf("got %+v, want the unsigned checksums", rel)
+	}
+}
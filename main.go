@@ -5,11 +5,12 @@ import (
 	"os"
 
 	"github.com/crazywolf132/sage/cmd"
+	"github.com/crazywolf132/sage/internal/exitcode"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.Of(err))
 	}
 }